@@ -0,0 +1,32 @@
+import { describe, it, expect } from "vitest";
+import { classifyModelError } from "../index.js";
+
+describe("classifyModelError", () => {
+  it("returns undefined for generic errors", () => {
+    expect(classifyModelError("connection refused")).toBeUndefined();
+  });
+
+  it("does not treat max-turns errors as model errors", () => {
+    expect(
+      classifyModelError("Reached maximum number of turns: 500")
+    ).toBeUndefined();
+  });
+
+  it("classifies overload responses", () => {
+    expect(
+      classifyModelError('API Error: 529 {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}')
+    ).toBe("overloaded");
+  });
+
+  it("classifies 5xx responses as server errors", () => {
+    expect(classifyModelError("API Error: 503 Service Unavailable")).toBe(
+      "server_error"
+    );
+  });
+
+  it("classifies api_error payloads", () => {
+    expect(
+      classifyModelError('{"type":"error","error":{"type":"api_error","message":"Internal server error"}}')
+    ).toBe("server_error");
+  });
+});
@@ -1,6 +1,7 @@
 import { query, startup, type McpServerConfig, type WarmQuery } from "@anthropic-ai/claude-agent-sdk";
 import { NatsBridge } from "./nats-bridge.js";
 import { applyExtensions } from "./extensions.js";
+import { sendIPC } from "./ipc.js";
 import { readFileSync, readdirSync, mkdirSync, writeFileSync, rmSync, symlinkSync, existsSync, lstatSync, readlinkSync, unlinkSync } from "fs";
 import { join } from "path";
 import { execSync } from "child_process";
@@ -21,12 +22,15 @@ console.warn = (...args: unknown[]) => origWarn(ts(), ...args);
 console.error = (...args: unknown[]) => origError(ts(), ...args);
 
 const NATS_URL = process.env.NATS_URL || "nats://localhost:4222";
+const NATS_SUBJECT_PREFIX = process.env.NATS_SUBJECT_PREFIX || "";
 const AGENT_ID = process.env.AGENT_ID || process.env.GROUP_ID || "default";
 const CLAUDE_MODEL = process.env.CLAUDE_MODEL || undefined;
 const ALLOWED_TOOLS_ENV = process.env.ALLOWED_TOOLS || "";
 const MAX_TURNS = parseInt(process.env.MAX_TURNS || "200", 10);
 const SWARM_CHAT_TOPIC = process.env.SWARM_CHAT_TOPIC || "";
 const SWARM_ROLE = process.env.SWARM_ROLE || "";
+// Set when the host injects USER.md and CLAUDE.md (defaults.inject_global_context).
+const GLOBAL_DIR = process.env.GLOBAL_CONTEXT_DIR || "/workspace/global";
 // agent-browser is driven through its typed MCP server (v0.28.0+).
 // AGENT_BROWSER_MCP selects the tool profile passed to `agent-browser mcp
 // --tools` (default "core"; composable, e.g. "core,network,react").
@@ -37,6 +41,10 @@ const AGENT_BROWSER_MCP_TOOLS = (process.env.AGENT_BROWSER_MCP || "core").trim()
 let bridge: NatsBridge;
 let isProcessing = false;
 let lastSessionId: string | undefined;
+// Session id praktor assigned to this agent (SESSION_ID). It is stable across
+// container restarts and replaced on clear_session, so conversations started
+// under it can be resumed from the transcript kept in the home volume.
+let assignedSessionId: string | undefined = process.env.SESSION_ID || undefined;
 let currentQueryIter: AsyncIterator<unknown> | null = null;
 let aborted = false;
 // Per-query background task counter. Incremented on SDK `task_started`,
@@ -75,7 +83,7 @@ function rewarm(): void {
   if (prev) { try { prev.close(); } catch { /* ignore */ } }
   warmForSessionId = target;
   // eslint-disable-next-line @typescript-eslint/no-explicit-any
-  startup({ options: buildRunOptions(target) as any })
+  startup({ options: buildRunOptions(target, undefined, assignedSessionId) as any })
     .then((w) => {
       // If session changed while we were warming, discard.
       if (warmForSessionId !== target) { try { w.close(); } catch { /* ignore */ } return; }
@@ -112,7 +120,7 @@ function installGlobalInstructions(): void {
   // so we only need to write the global one here. The per-agent CLAUDE.md in
   // /workspace/agent/ is loaded automatically as the project-level file.
   try {
-    const global = readFileSync("/workspace/global/CLAUDE.md", "utf-8");
+    const global = readFileSync(`${GLOBAL_DIR}/CLAUDE.md`, "utf-8");
     const userClaudeDir = "/home/praktor/.claude";
     mkdirSync(userClaudeDir, { recursive: true });
     writeFileSync(`${userClaudeDir}/CLAUDE.md`, global);
@@ -146,6 +154,34 @@ function ensureAgentMd(): void {
   }
 }
 
+// Registers the agent's slash commands from /workspace/agent/commands.json
+// ([{command, description, prompt_template}]). Sent on every start so
+// removing the file, or an entry, unregisters the commands.
+async function registerCommands(): Promise<void> {
+  const path = "/workspace/agent/commands.json";
+  let commands: unknown[] = [];
+  if (existsSync(path)) {
+    try {
+      const parsed = JSON.parse(readFileSync(path, "utf-8"));
+      if (!Array.isArray(parsed)) throw new Error("expected a JSON array");
+      commands = parsed;
+    } catch (err) {
+      console.warn("[agent] invalid commands.json:", err);
+      return;
+    }
+  }
+  try {
+    const resp = await sendIPC("register_commands", commands);
+    if (resp.error) {
+      console.warn(`[agent] command registration failed: ${resp.error}`);
+    } else if (commands.length > 0) {
+      console.log(`[agent] registered ${commands.length} commands`);
+    }
+  } catch (err) {
+    console.warn("[agent] command registration failed:", err);
+  }
+}
+
 function setupAgentBrowser(): void {
   // agent-browser is driven via its typed MCP server, so no usage-guide skill
   // is injected — only the config symlink (chromium path) is needed.
@@ -209,7 +245,7 @@ function loadSystemPrompt(includeIdentity = true): string {
 
   // User profile (loaded before global instructions so agents know the user)
   try {
-    const user = readFileSync("/workspace/global/USER.md", "utf-8");
+    const user = readFileSync(`${GLOBAL_DIR}/USER.md`, "utf-8");
     parts.push(user);
   } catch {
     // User profile not available
@@ -232,7 +268,7 @@ function loadSystemPrompt(includeIdentity = true): string {
 
   // Include global instructions in system prompt as well (belt and suspenders)
   try {
-    const global = readFileSync("/workspace/global/CLAUDE.md", "utf-8");
+    const global = readFileSync(`${GLOBAL_DIR}/CLAUDE.md`, "utf-8");
     parts.push(global);
   } catch {
     // Global instructions not available
@@ -396,7 +432,24 @@ export function inferTerminalReason(errorMsg: string): string | undefined {
   return undefined;
 }
 
-function buildRunOptions(sessionId?: string) {
+// Classifies errors the gateway can retry on another model. Anything else
+// (tool failures, max turns, auth) is not model-specific and returns undefined.
+export function classifyModelError(errorMsg: string): string | undefined {
+  if (/overloaded|API Error:\s*529\b/i.test(errorMsg)) return "overloaded";
+  if (/API Error:\s*5\d\d\b|"api_error"/i.test(errorMsg)) return "server_error";
+  return undefined;
+}
+
+// hasTranscript reports whether the CLI saved a session for the agent's cwd
+// under id, i.e. whether it must be resumed rather than created.
+function hasTranscript(id: string | undefined): boolean {
+  return !!id && existsSync(`/home/praktor/.claude/projects/-workspace-agent/${id}.jsonl`);
+}
+
+// newSessionId names the conversation when there is no session to resume;
+// an existing transcript under it (from before a restart) is resumed instead.
+function buildRunOptions(sessionId?: string, model?: string, newSessionId?: string) {
+  if (!sessionId && hasTranscript(newSessionId)) sessionId = newSessionId;
   const systemPrompt = loadSystemPrompt();
   const cwd = "/workspace/agent";
   const tools = parseAllowedTools(ALLOWED_TOOLS_ENV);
@@ -414,13 +467,13 @@ function buildRunOptions(sessionId?: string) {
       type: "stdio",
       command: "node",
       args: ["/app/mcp-tasks.mjs"],
-      env: { NATS_URL, AGENT_ID },
+      env: { NATS_URL, NATS_SUBJECT_PREFIX, AGENT_ID },
     },
     "praktor-profile": {
       type: "stdio",
       command: "node",
       args: ["/app/mcp-profile.mjs"],
-      env: { NATS_URL, AGENT_ID },
+      env: { NATS_URL, NATS_SUBJECT_PREFIX, AGENT_ID },
     },
     "praktor-memory": {
       type: "stdio",
@@ -438,13 +491,19 @@ function buildRunOptions(sessionId?: string) {
       type: "stdio",
       command: "node",
       args: ["/app/mcp-file.mjs"],
-      env: { NATS_URL, AGENT_ID },
+      env: { NATS_URL, NATS_SUBJECT_PREFIX, AGENT_ID },
     },
     "praktor-history": {
       type: "stdio",
       command: "node",
       args: ["/app/mcp-history.mjs"],
-      env: { NATS_URL, AGENT_ID },
+      env: { NATS_URL, NATS_SUBJECT_PREFIX, AGENT_ID },
+    },
+    "praktor-agents": {
+      type: "stdio",
+      command: "node",
+      args: ["/app/mcp-agents.mjs"],
+      env: { NATS_URL, NATS_SUBJECT_PREFIX, AGENT_ID },
     },
     ...extensionMcpServers,
   };
@@ -453,7 +512,7 @@ function buildRunOptions(sessionId?: string) {
       type: "stdio",
       command: "node",
       args: ["/app/mcp-swarm.mjs"],
-      env: { NATS_URL, AGENT_ID, SWARM_CHAT_TOPIC },
+      env: { NATS_URL, NATS_SUBJECT_PREFIX, AGENT_ID, SWARM_CHAT_TOPIC },
     };
   }
   // agent-browser typed MCP server (v0.28.0+). Tools surface as
@@ -468,11 +527,11 @@ function buildRunOptions(sessionId?: string) {
   }
 
   return {
-      model: CLAUDE_MODEL,
+      model: model || CLAUDE_MODEL,
       cwd,
       pathToClaudeCodeExecutable: "/usr/local/bin/claude",
       systemPrompt: systemPrompt || undefined,
-      ...(sessionId ? { resume: sessionId } : {}),
+      ...(sessionId ? { resume: sessionId } : newSessionId ? { sessionId: newSessionId } : {}),
       ...(tools ? { tools } : {}),
       maxTurns: MAX_TURNS,
       mcpServers,
@@ -484,8 +543,8 @@ function buildRunOptions(sessionId?: string) {
   };
 }
 
-function buildQueryOptions(prompt: string, sessionId?: string) {
-  return { prompt, options: buildRunOptions(sessionId) };
+function buildQueryOptions(prompt: string, sessionId?: string, model?: string, newSessionId?: string) {
+  return { prompt, options: buildRunOptions(sessionId, model, newSessionId) };
 }
 
 // Execute a scheduled task in parallel (fresh session, no resume)
@@ -519,9 +578,19 @@ export function decideTaskFinalResponse(
   return { content: "", warn: true };
 }
 
+// Prepends the prior turns the gateway injects when history is enabled
+// (defaults.history / agents.<name>.history).
+function withHistory(text: string, data: Record<string, unknown>): string {
+  const history = data.history as string | undefined;
+  if (!history) return text;
+  return `## Conversation History\n\n${history}\n\n---\n\n${text}`;
+}
+
 async function executeTask(data: Record<string, unknown>): Promise<void> {
   const text = data.text as string;
   const msgId = data.msg_id as string | undefined;
+  // Set by the gateway when retrying with a fallback model.
+  const model = data.model as string | undefined;
   const bgKey = msgId ?? `__task-${++taskKeyCounter}`;
   console.log(`[task] executing parallel task: ${text.substring(0, 100)}...`);
 
@@ -534,7 +603,7 @@ async function executeTask(data: Record<string, unknown>): Promise<void> {
   let hasFileSent = false;
 
   try {
-    const opts = buildQueryOptions(text);
+    const opts = buildQueryOptions(withHistory(text, data), undefined, model);
     const result = query(opts);
 
     const iter = result[Symbol.asyncIterator]();
@@ -600,7 +669,12 @@ async function executeTask(data: Record<string, unknown>): Promise<void> {
     const errorMsg = err instanceof Error ? err.message : String(err);
     const reason = terminalReason || inferTerminalReason(errorMsg);
     console.error(`[task] error:`, err);
-    await bridge.publishResult(`Error: ${errorMsg}`, msgId, reason);
+    const code = classifyModelError(errorMsg);
+    if (code) {
+      await bridge.publishModelError(`Error: ${errorMsg}`, code, msgId, model || CLAUDE_MODEL);
+    } else {
+      await bridge.publishResult(`Error: ${errorMsg}`, msgId, reason);
+    }
   } finally {
     if (msgId) activeQueries.delete(msgId);
     backgroundTasksByQuery.delete(bgKey);
@@ -621,6 +695,8 @@ async function handleMessage(data: Record<string, unknown>): Promise<void> {
 
   const sender = data.sender as string | undefined;
   const msgId = data.msg_id as string | undefined;
+  // Set by the gateway when retrying with a fallback model.
+  const model = data.model as string | undefined;
 
   // Scheduled tasks run in parallel with fresh sessions
   if (sender === "scheduler") {
@@ -656,12 +732,12 @@ async function handleMessage(data: Record<string, unknown>): Promise<void> {
 
   try {
     // Prepend swarm chat context if in collaborative mode
-    let augmentedText = text;
+    let augmentedText = withHistory(text, data);
     if (SWARM_CHAT_TOPIC && chatHistory.length > 0) {
       const chatContext = chatHistory
         .map((m) => `[${m.from}]: ${m.content}`)
         .join("\n");
-      augmentedText = `## Collaborative Chat History\n\n${chatContext}\n\n---\n\n${text}`;
+      augmentedText = `## Collaborative Chat History\n\n${chatContext}\n\n---\n\n${augmentedText}`;
       console.log(`[agent] prepended ${chatHistory.length} chat messages to prompt`);
     }
 
@@ -669,7 +745,8 @@ async function handleMessage(data: Record<string, unknown>): Promise<void> {
     // session; otherwise spawn a new one. The warm path skips the CLI
     // spawn + initialize handshake latency on the first token.
     let result;
-    if (warmHandle && warmForSessionId === lastSessionId && !SWARM_CHAT_TOPIC) {
+    // The warm subprocess was built for the default model.
+    if (warmHandle && warmForSessionId === lastSessionId && !SWARM_CHAT_TOPIC && !model) {
       console.log(`[agent] starting claude query (warm)`);
       const handle = warmHandle;
       warmHandle = null;
@@ -682,7 +759,7 @@ async function handleMessage(data: Record<string, unknown>): Promise<void> {
     }
     if (!result) {
       console.log(`[agent] starting claude query`);
-      const opts = buildQueryOptions(augmentedText, lastSessionId);
+      const opts = buildQueryOptions(augmentedText, lastSessionId, model, assignedSessionId);
       result = query(opts);
     }
 
@@ -749,7 +826,12 @@ async function handleMessage(data: Record<string, unknown>): Promise<void> {
     const errorMsg = err instanceof Error ? err.message : String(err);
     const reason = terminalReason || inferTerminalReason(errorMsg);
     console.error(`[agent] error processing message:`, err);
-    await bridge.publishResult(`Error: ${errorMsg}`, msgId, reason);
+    const code = classifyModelError(errorMsg);
+    if (code) {
+      await bridge.publishModelError(`Error: ${errorMsg}`, code, msgId, model || CLAUDE_MODEL);
+    } else {
+      await bridge.publishResult(`Error: ${errorMsg}`, msgId, reason);
+    }
   } finally {
     currentQueryIter = null;
     isProcessing = false;
@@ -885,6 +967,7 @@ async function handleControl(
     case "clear_session":
       console.log("[agent] clearing session...");
       lastSessionId = undefined;
+      assignedSessionId = (data.session_id as string) || undefined;
       // The warm handle was prepared for the old session; discard it.
       if (warmHandle) { try { warmHandle.close(); } catch { /* ignore */ } warmHandle = null; }
       for (const dir of [
@@ -899,6 +982,22 @@ async function handleControl(
       msg.respond(new TextEncoder().encode(JSON.stringify({ status: "ok" })));
       console.log("[agent] session cleared");
       break;
+    case "refresh_global_context":
+      // Only sent when the host injects the global files into GLOBAL_DIR.
+      try {
+        mkdirSync(GLOBAL_DIR, { recursive: true });
+        writeFileSync(`${GLOBAL_DIR}/USER.md`, (data.user_md as string) || "");
+        writeFileSync(`${GLOBAL_DIR}/CLAUDE.md`, (data.claude_md as string) || "");
+        installGlobalInstructions();
+        // The warm handle was built with the old system prompt.
+        if (warmHandle) { try { warmHandle.close(); } catch { /* ignore */ } warmHandle = null; }
+        rewarm();
+        msg.respond(new TextEncoder().encode(JSON.stringify({ status: "ok" })));
+        console.log("[agent] global context refreshed");
+      } catch (err) {
+        msg.respond(new TextEncoder().encode(JSON.stringify({ error: String(err) })));
+      }
+      break;
     default:
       console.warn(`[agent] unknown control command: ${command}`);
       msg.respond(new TextEncoder().encode(JSON.stringify({ error: `unknown command: ${command}` })));
@@ -934,6 +1033,8 @@ async function main(): Promise<void> {
     await bridge.publishOutput(errMsg, "text");
   }
 
+  await registerCommands();
+
   bridge.subscribeInput(handleMessage);
   bridge.subscribeControl(handleControl);
   bridge.subscribeRoute(handleRoute);
@@ -956,6 +1057,7 @@ async function main(): Promise<void> {
   // Flush to ensure subscriptions are registered with NATS server
   await bridge.flush();
 
+  await bridge.publishCapabilities(["ready_signal", "history_injection", "model_fallback", "session_resume"]);
   await bridge.publishReady();
   console.log(`[agent] ready and listening for messages`);
 
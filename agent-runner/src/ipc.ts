@@ -1,4 +1,5 @@
 import { connect, StringCodec } from "nats";
+import { subject } from "./nats-bridge.js";
 
 const sc = StringCodec();
 
@@ -10,21 +11,27 @@ export interface IPCResponse {
   error?: string;
   id?: string;
   content?: string;
+  name?: string;
+  size?: number;
+  sha256?: string;
   tasks?: Array<{
     id: string;
     name: string;
     schedule: string;
     prompt: string;
     status: string;
+    next_run?: string;
+    last_run?: string;
+    last_status?: string;
   }>;
 }
 
 export async function sendIPC(
   type: string,
-  payload: Record<string, unknown>
+  payload: Record<string, unknown> | unknown[]
 ): Promise<IPCResponse> {
   const conn = await connect({ servers: NATS_URL });
-  const topic = `host.ipc.${AGENT_ID}`;
+  const topic = subject(`host.ipc.${AGENT_ID}`);
   const data = sc.encode(JSON.stringify({ type, payload }));
   const resp = await conn.request(topic, data, { timeout: 30000 });
   const result: IPCResponse = JSON.parse(sc.decode(resp.data));
@@ -0,0 +1,82 @@
+import { McpServer } from "@modelcontextprotocol/sdk/server/mcp.js";
+import { StdioServerTransport } from "@modelcontextprotocol/sdk/server/stdio.js";
+import { z } from "zod";
+import { sendIPC } from "./ipc.js";
+
+const server = new McpServer({
+  name: "praktor-agents",
+  version: "1.0.0",
+});
+
+server.tool(
+  "agents_list",
+  "List the other agents in this Praktor instance with their descriptions and whether they are running. Use it to decide which agent to delegate to or to suggest one to the user (they can address an agent with @agent_name).",
+  {
+    group: z.string().optional().describe("Only agents in this group"),
+    tags: z.array(z.string()).optional().describe("Only agents carrying all of these tags"),
+    running: z.boolean().optional().describe("Only agents whose container is running"),
+  },
+  async ({ group, tags, running }) => {
+    const resp = await sendIPC("list_agents", { group, tags, running });
+    if (resp.error) {
+      return { content: [{ type: "text" as const, text: `Error: ${resp.error}` }] };
+    }
+    const agents = (resp as any).agents as Array<{
+      id: string; name: string; description?: string; tags?: string[]; group?: string; running: boolean; self?: boolean;
+    }>;
+    if (!agents || agents.length === 0) {
+      return { content: [{ type: "text" as const, text: "No agents found." }] };
+    }
+    const result = agents.map((a) => {
+      const flags = [a.running ? "running" : "stopped", ...(a.self ? ["you"] : [])];
+      const extra = [
+        ...(a.group ? [`group: ${a.group}`] : []),
+        ...(a.tags?.length ? [`tags: ${a.tags.join(", ")}`] : []),
+      ];
+      return `- **${a.id}** (${flags.join(", ")})${a.description ? `: ${a.description}` : ""}${extra.length ? ` [${extra.join("; ")}]` : ""}`;
+    }).join("\n");
+    return { content: [{ type: "text" as const, text: result }] };
+  }
+);
+
+server.tool(
+  "maintenance_enter",
+  "Start a maintenance window before long-running work (e.g. a batch job) that must not be interrupted. While it is open this agent is not stopped when idle and nix garbage collection skips it. It closes on maintenance_exit or after the duration, which the host caps at its configured maximum. Call maintenance_exit as soon as the work is done.",
+  {
+    duration: z.string().optional().describe("Go duration like '45m' or '2h' (default and maximum: the host's cap)"),
+    reason: z.string().optional().describe("What the window protects, for the logs"),
+  },
+  async ({ duration, reason }) => {
+    const resp = await sendIPC("enter_maintenance", { duration, reason });
+    if (resp.error) {
+      return { content: [{ type: "text" as const, text: `Error: ${resp.error}` }] };
+    }
+    const { until, capped } = resp as any;
+    const note = capped ? " (capped at the host's maximum)" : "";
+    return { content: [{ type: "text" as const, text: `Maintenance window open until ${until}${note}.` }] };
+  }
+);
+
+server.tool(
+  "maintenance_exit",
+  "End the maintenance window opened with maintenance_enter, so idle stops and nix garbage collection apply again.",
+  {},
+  async () => {
+    const resp = await sendIPC("exit_maintenance", {});
+    if (resp.error) {
+      return { content: [{ type: "text" as const, text: `Error: ${resp.error}` }] };
+    }
+    const text = (resp as any).was_in_maintenance ? "Maintenance window closed." : "No maintenance window was open.";
+    return { content: [{ type: "text" as const, text }] };
+  }
+);
+
+async function main(): Promise<void> {
+  const transport = new StdioServerTransport();
+  await server.connect(transport);
+}
+
+main().catch((err) => {
+  console.error("MCP agents server error:", err);
+  process.exit(1);
+});
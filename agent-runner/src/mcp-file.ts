@@ -6,7 +6,12 @@ import { basename, extname } from "path";
 import { pathToFileURL } from "url";
 import { sendIPC } from "./ipc.js";
 
-const MAX_FILE_SIZE = 12 * 1024 * 1024; // 12MB
+// Files up to MAX_INLINE_SIZE are base64-embedded in the IPC message (the
+// NATS max payload is 16MB). Larger files must live in the workspace volume
+// and are sent by path; the host copies them out and enforces
+// defaults.max_file_size_mb.
+const MAX_INLINE_SIZE = 8 * 1024 * 1024; // 8MB
+const WORKSPACE_DIR = "/workspace/agent/";
 
 const MIME_TYPES: Record<string, string> = {
   ".png": "image/png",
@@ -42,7 +47,7 @@ const server = new McpServer({
 
 server.tool(
   "file_send",
-  "Send a binary file to the user via Telegram (images, PDFs, documents, etc.). NOT for text messages — your text replies are already delivered to Telegram automatically. Never create .txt files to send text content. Max file size: 12MB.",
+  "Send a binary file to the user via Telegram (images, PDFs, documents, etc.). NOT for text messages — your text replies are already delivered to Telegram automatically. Never create .txt files to send text content. Files over 8MB must be inside /workspace/agent.",
   {
     path: z.string().describe("Absolute path to the file in the container"),
     caption: z.string().optional().describe("Optional caption for the file"),
@@ -58,29 +63,32 @@ server.tool(
       };
     }
 
-    if (stat.size > MAX_FILE_SIZE) {
-      const sizeMB = (stat.size / (1024 * 1024)).toFixed(1);
-      return {
-        content: [
-          {
-            type: "text" as const,
-            text: `Error: file too large (${sizeMB}MB). Maximum size is 12MB.`,
-          },
-        ],
-      };
-    }
-
-    // Read and encode
-    const data = readFileSync(path).toString("base64");
     const name = basename(path);
     const mimeType = detectMimeType(path);
-
-    const resp = await sendIPC("send_file", {
+    const payload: Record<string, unknown> = {
       name,
-      data,
       mime_type: mimeType,
       caption: caption || "",
-    });
+    };
+
+    if (stat.size > MAX_INLINE_SIZE) {
+      if (!path.startsWith(WORKSPACE_DIR)) {
+        const sizeMB = (stat.size / (1024 * 1024)).toFixed(1);
+        return {
+          content: [
+            {
+              type: "text" as const,
+              text: `Error: file too large to send inline (${sizeMB}MB). Move it under ${WORKSPACE_DIR} and retry.`,
+            },
+          ],
+        };
+      }
+      payload.path = path;
+    } else {
+      payload.data = readFileSync(path).toString("base64");
+    }
+
+    const resp = await sendIPC("send_file", payload);
 
     if (resp.error) {
       return {
@@ -96,6 +104,70 @@ server.tool(
   }
 );
 
+server.tool(
+  "artifact_save",
+  "Save a large output (report, dataset, log) as a downloadable artifact instead of sending it to the chat. Pass either text content or the path of a file. Saving the same name again replaces the artifact. Files over 8MB must be inside /workspace/agent.",
+  {
+    name: z.string().describe("Artifact file name, e.g. report.md"),
+    content: z.string().optional().describe("Text content of the artifact"),
+    path: z.string().optional().describe("Absolute path to a file in the container"),
+  },
+  async ({ name, content, path }) => {
+    if ((content === undefined) === (path === undefined)) {
+      return {
+        content: [{ type: "text" as const, text: "Error: pass exactly one of content or path" }],
+      };
+    }
+
+    const payload: Record<string, unknown> = { name };
+    if (path !== undefined) {
+      let stat;
+      try {
+        stat = statSync(path);
+      } catch {
+        return {
+          content: [{ type: "text" as const, text: `Error: file not found: ${path}` }],
+        };
+      }
+      if (stat.size > MAX_INLINE_SIZE) {
+        if (!path.startsWith(WORKSPACE_DIR)) {
+          const sizeMB = (stat.size / (1024 * 1024)).toFixed(1);
+          return {
+            content: [
+              {
+                type: "text" as const,
+                text: `Error: file too large to save inline (${sizeMB}MB). Move it under ${WORKSPACE_DIR} and retry.`,
+              },
+            ],
+          };
+        }
+        payload.path = path;
+      } else {
+        payload.data = readFileSync(path).toString("base64");
+      }
+    } else {
+      payload.content = content;
+    }
+
+    const resp = await sendIPC("save_artifact", payload);
+
+    if (resp.error) {
+      return {
+        content: [{ type: "text" as const, text: `Error: ${resp.error}` }],
+      };
+    }
+
+    return {
+      content: [
+        {
+          type: "text" as const,
+          text: `Artifact saved: ${resp.name} (${resp.size} bytes, sha256 ${resp.sha256})`,
+        },
+      ],
+    };
+  }
+);
+
 async function main(): Promise<void> {
   const transport = new StdioServerTransport();
   await server.connect(transport);
@@ -35,6 +35,33 @@ server.tool(
   }
 );
 
+server.tool(
+  "history_read",
+  "Read your most recent conversation messages in chronological order. Use after a restart to recover context. Page further back by passing the id of the oldest message you received as `before`.",
+  {
+    limit: z.number().optional().describe("Max messages to return (default: 50, max: 200)"),
+    before: z.number().optional().describe("Only return messages older than this message id"),
+  },
+  async ({ limit, before }) => {
+    console.error(`[mcp-history] read limit=${limit || 50} before=${before ?? "-"}`);
+    const resp = await sendIPC("read_history", { limit: limit || 50, ...(before ? { before } : {}) });
+    if (resp.error) {
+      return { content: [{ type: "text" as const, text: `Read error: ${resp.error}` }] };
+    }
+    const messages = (resp as any).messages as Array<{
+      id: number; sender: string; content: string; created_at: string;
+    }>;
+    if (!messages || messages.length === 0) {
+      return { content: [{ type: "text" as const, text: "No messages found." }] };
+    }
+    const result = messages.map((m) => {
+      const role = m.sender === "agent" ? "Assistant" : "User";
+      return `**${role}** #${m.id} (${m.created_at}):\n${m.content}`;
+    }).join("\n\n---\n\n");
+    return { content: [{ type: "text" as const, text: result }] };
+  }
+);
+
 async function main(): Promise<void> {
   const transport = new StdioServerTransport();
   await server.connect(transport);
@@ -24,6 +24,7 @@ Supported formats:
 - Relative delay: "+30s", "+5m", "+2h" (ALWAYS use this for "in X seconds/minutes/hours" requests)
 - 5-field cron: "minute hour day month weekday" (e.g. "0 9 * * *" for daily at 9am local)
 - 6-field cron with year (one-off): "minute hour day month weekday year" (e.g. "20 10 17 2 * 2026" for Feb 17 2026 at 10:20 local)
+- 6-field cron with seconds: "second minute hour day month weekday" (e.g. "*/30 * * * * *" for every 30 seconds). A 6th field with a 4-digit year always means the year form above
 - 7-field cron with seconds: "second minute hour day month weekday year" (e.g. "0 30 9 17 2 * 2026" for Feb 17 2026 at 9:30:00 local)
 - Preset tags: @yearly, @annually, @monthly, @weekly, @daily, @hourly, @5minutes, @10minutes, @15minutes, @30minutes, @always, @everysecond
 - Month names: JAN-DEC, Weekday names: SUN-SAT
@@ -65,7 +66,10 @@ server.tool(
       };
     }
     const lines = resp.tasks.map(
-      (t) => `- ${t.id} [${t.status}] "${t.name}" schedule=${t.schedule}`
+      (t) =>
+        `- ${t.id} [${t.status}] "${t.name}" schedule=${t.schedule}` +
+        (t.next_run ? ` next_run=${t.next_run}` : "") +
+        (t.last_run ? ` last_run=${t.last_run} (${t.last_status ?? "unknown"})` : "")
     );
     return { content: [{ type: "text" as const, text: lines.join("\n") }] };
   }
@@ -88,6 +92,40 @@ server.tool(
   }
 );
 
+server.tool(
+  "scheduled_task_pause",
+  "Pause one of this agent's scheduled tasks by ID. It stays paused until resumed.",
+  {
+    id: z.string().describe("Task ID to pause"),
+  },
+  async ({ id }) => {
+    const resp = await sendIPC("pause_task", { id });
+    if (resp.error) {
+      return { content: [{ type: "text" as const, text: `Error: ${resp.error}` }] };
+    }
+    return {
+      content: [{ type: "text" as const, text: "Task paused successfully." }],
+    };
+  }
+);
+
+server.tool(
+  "scheduled_task_resume",
+  "Resume a paused scheduled task by ID. The next run is computed from now; missed runs are skipped.",
+  {
+    id: z.string().describe("Task ID to resume"),
+  },
+  async ({ id }) => {
+    const resp = await sendIPC("resume_task", { id });
+    if (resp.error) {
+      return { content: [{ type: "text" as const, text: `Error: ${resp.error}` }] };
+    }
+    return {
+      content: [{ type: "text" as const, text: "Task resumed successfully." }],
+    };
+  }
+);
+
 async function main(): Promise<void> {
   const transport = new StdioServerTransport();
   await server.connect(transport);
@@ -2,6 +2,14 @@ import { connect, Msg, NatsConnection, Subscription, StringCodec } from "nats";
 
 const sc = StringCodec();
 
+// Deployment-wide prefix praktor puts in front of every subject (see
+// nats.subject_prefix); empty means the plain agent.* / host.ipc.* subjects.
+const SUBJECT_PREFIX = process.env.NATS_SUBJECT_PREFIX || "";
+
+export function subject(name: string): string {
+  return SUBJECT_PREFIX ? `${SUBJECT_PREFIX}.${name}` : name;
+}
+
 export class NatsBridge {
   private conn: NatsConnection | null = null;
   private subscriptions: Subscription[] = [];
@@ -26,12 +34,18 @@ export class NatsBridge {
     this.conn.publish(topic, sc.encode(JSON.stringify(data)));
   }
 
+  // Every output names the agent, so the gateway can drop outputs whose
+  // payload disagrees with the subject they were published on.
+  private async publishAgentOutput(data: Record<string, unknown>): Promise<void> {
+    await this.publish(subject(`agent.${this.agentId}.output`), { ...data, agent_id: this.agentId });
+  }
+
   async publishOutput(content: string, type: string = "text", msgId?: string): Promise<void> {
-    await this.publish(`agent.${this.agentId}.output`, { type, content, ...(msgId ? { msg_id: msgId } : {}) });
+    await this.publishAgentOutput({ type, content, ...(msgId ? { msg_id: msgId } : {}) });
   }
 
   async publishResult(content: string, msgId?: string, terminalReason?: string): Promise<void> {
-    await this.publish(`agent.${this.agentId}.output`, {
+    await this.publishAgentOutput({
       type: "result",
       content,
       ...(msgId ? { msg_id: msgId } : {}),
@@ -39,12 +53,32 @@ export class NatsBridge {
     });
   }
 
+  // Reports a model-level failure (overload, 5xx) before any output was
+  // produced. The gateway may re-send the prompt with a fallback model, so
+  // this is published instead of a result.
+  async publishModelError(content: string, code: string, msgId?: string, model?: string): Promise<void> {
+    await this.publishAgentOutput({
+      type: "error",
+      content,
+      code,
+      retryable: true,
+      ...(msgId ? { msg_id: msgId } : {}),
+      ...(model ? { model } : {}),
+    });
+  }
+
+  // Announce optional features (natsbus.Capabilities on the host). Sent
+  // after the subscriptions are flushed and before the ready marker.
+  async publishCapabilities(features: string[]): Promise<void> {
+    await this.publish(subject(`agent.${this.agentId}.capabilities`), { version: 1, features });
+  }
+
   async publishReady(): Promise<void> {
-    await this.publish(`agent.${this.agentId}.ready`, { status: "ready" });
+    await this.publish(subject(`agent.${this.agentId}.ready`), { status: "ready" });
   }
 
   async publishIPC(command: string, payload: unknown): Promise<void> {
-    await this.publish(`host.ipc.${this.agentId}`, {
+    await this.publish(subject(`host.ipc.${this.agentId}`), {
       type: command,
       payload,
     });
@@ -72,19 +106,19 @@ export class NatsBridge {
   }
 
   subscribeInput(handler: (data: Record<string, unknown>) => void): void {
-    this.subscribe(`agent.${this.agentId}.input`, (data) => handler(data));
+    this.subscribe(subject(`agent.${this.agentId}.input`), (data) => handler(data));
   }
 
   subscribeControl(
     handler: (data: Record<string, unknown>, msg: Msg) => void
   ): void {
-    this.subscribe(`agent.${this.agentId}.control`, handler);
+    this.subscribe(subject(`agent.${this.agentId}.control`), handler);
   }
 
   subscribeRoute(
     handler: (data: Record<string, unknown>, msg: Msg) => void
   ): void {
-    this.subscribe(`agent.${this.agentId}.route`, handler);
+    this.subscribe(subject(`agent.${this.agentId}.route`), handler);
   }
 
   subscribeSwarmChat(
@@ -109,7 +143,7 @@ export class NatsBridge {
     payload: Record<string, unknown>
   ): Promise<Record<string, unknown>> {
     if (!this.conn) throw new Error("Not connected to NATS");
-    const topic = `host.ipc.${this.agentId}`;
+    const topic = subject(`host.ipc.${this.agentId}`);
     const data = sc.encode(JSON.stringify({ type: command, payload }));
     const resp = await this.conn.request(topic, data, { timeout: 10000 });
     return JSON.parse(sc.decode(resp.data));
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/agent"
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+type adminResponse struct {
+	OK      bool               `json:"ok,omitempty"`
+	Error   string             `json:"error,omitempty"`
+	Content string             `json:"content,omitempty"`
+	Agents  []agent.AdminAgent `json:"agents,omitempty"`
+}
+
+// writeAdminToken writes a fresh random admin token to path, readable only
+// by the gateway's user, and returns it.
+func writeAdminToken(path string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate admin token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("create admin token dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("write admin token: %w", err)
+	}
+	return token, nil
+}
+
+// readAdminToken returns PRAKTOR_ADMIN_TOKEN, or the token the running
+// gateway wrote to config.AdminTokenPath.
+func readAdminToken() (string, error) {
+	if token := os.Getenv("PRAKTOR_ADMIN_TOKEN"); token != "" {
+		return token, nil
+	}
+	data, err := os.ReadFile(config.AdminTokenPath)
+	if err != nil {
+		return "", fmt.Errorf("read admin token (run from the gateway's directory or set PRAKTOR_ADMIN_TOKEN): %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// adminSocketPath returns PRAKTOR_ADMIN_SOCKET, or the socket the running
+// gateway serves at config.AdminSocketPath.
+func adminSocketPath() string {
+	if path := os.Getenv("PRAKTOR_ADMIN_SOCKET"); path != "" {
+		return path
+	}
+	return config.AdminSocketPath
+}
+
+// adminRequest sends an admin command to the running gateway over its
+// admin socket.
+func adminRequest(reqType string, payload map[string]any) (*adminResponse, error) {
+	token, err := readAdminToken()
+	if err != nil {
+		return nil, err
+	}
+
+	path := adminSocketPath()
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("connect to gateway admin socket (%s): %w", path, err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Minute))
+
+	if err := json.NewEncoder(conn).Encode(map[string]any{"type": reqType, "token": token, "payload": payload}); err != nil {
+		return nil, fmt.Errorf("send admin request: %w", err)
+	}
+
+	var resp adminResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("read admin response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("%s", resp.Error)
+	}
+	return &resp, nil
+}
+
+func runAgents(args []string) error {
+	if len(args) > 0 && args[0] != "list" {
+		printAgentUsage()
+		return fmt.Errorf("unknown agents command: %s", args[0])
+	}
+
+	resp, err := adminRequest("admin_list_agents", nil)
+	if err != nil {
+		return err
+	}
+	if len(resp.Agents) == 0 {
+		fmt.Println("No agents registered.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tSTATUS\tMODEL\tMESSAGES\tDESCRIPTION")
+	for _, a := range resp.Agents {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", a.ID, a.Status, a.Model, a.MessageCount, a.Description)
+	}
+	return w.Flush()
+}
+
+func runAgent(args []string) error {
+	if len(args) < 2 {
+		printAgentUsage()
+		return fmt.Errorf("missing agent id or command")
+	}
+
+	agentID, command := args[0], args[1]
+	switch command {
+	case "logs":
+		tail := 100
+		for i := 2; i < len(args); i++ {
+			if args[i] == "-tail" {
+				if i+1 >= len(args) {
+					return fmt.Errorf("missing value for -tail")
+				}
+				i++
+				n, err := strconv.Atoi(args[i])
+				if err != nil {
+					return fmt.Errorf("invalid -tail value %q", args[i])
+				}
+				tail = n
+			}
+		}
+		resp, err := adminRequest("admin_agent_logs", map[string]any{"agent_id": agentID, "tail": tail})
+		if err != nil {
+			return err
+		}
+		fmt.Print(resp.Content)
+	case "stop":
+		if _, err := adminRequest("admin_stop_agent", map[string]any{"agent_id": agentID}); err != nil {
+			return err
+		}
+		fmt.Printf("Agent %q stopped\n", agentID)
+	case "restart":
+		if _, err := adminRequest("admin_restart_agent", map[string]any{"agent_id": agentID}); err != nil {
+			return err
+		}
+		fmt.Printf("Agent %q restarted\n", agentID)
+	default:
+		printAgentUsage()
+		return fmt.Errorf("unknown agent command: %s", command)
+	}
+	return nil
+}
+
+func printAgentUsage() {
+	fmt.Fprintf(os.Stderr, `Usage:
+  praktor agents [list]                 List agents with status, model and message count
+  praktor agent <id> logs [-tail N]     Show container logs (default: last 100 lines, 0 = all)
+  praktor agent <id> stop               Stop the agent container
+  praktor agent <id> restart            Restart the agent container
+
+Commands talk to the running gateway over its admin socket, so run them
+from the gateway's directory (or container).
+
+Environment:
+  PRAKTOR_ADMIN_SOCKET                  Gateway admin socket (default: data/admin.sock)
+  PRAKTOR_ADMIN_TOKEN                   Admin token (default: read from data/admin.token)
+`)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdminRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var cmd struct {
+				Type    string         `json:"type"`
+				Token   string         `json:"token"`
+				Payload map[string]any `json:"payload"`
+			}
+			_ = json.NewDecoder(conn).Decode(&cmd)
+			var resp map[string]any
+			switch {
+			case cmd.Token != "tok":
+				resp = map[string]any{"error": "unauthorized"}
+			case cmd.Type == "admin_list_agents":
+				resp = map[string]any{"ok": true, "agents": []map[string]any{
+					{"id": "general", "model": "m", "status": "idle", "message_count": 3},
+				}}
+			default:
+				resp = map[string]any{"error": "agent not found: " + cmd.Payload["agent_id"].(string)}
+			}
+			_ = json.NewEncoder(conn).Encode(resp)
+			_ = conn.Close()
+		}
+	}()
+
+	t.Setenv("PRAKTOR_ADMIN_SOCKET", path)
+	t.Setenv("PRAKTOR_ADMIN_TOKEN", "tok")
+
+	resp, err := adminRequest("admin_list_agents", nil)
+	if err != nil {
+		t.Fatalf("admin_list_agents: %v", err)
+	}
+	if len(resp.Agents) != 1 || resp.Agents[0].ID != "general" || resp.Agents[0].MessageCount != 3 {
+		t.Errorf("unexpected agents: %+v", resp.Agents)
+	}
+
+	if _, err := adminRequest("admin_stop_agent", map[string]any{"agent_id": "nope"}); err == nil || err.Error() != "agent not found: nope" {
+		t.Errorf("expected gateway error to be surfaced, got %v", err)
+	}
+}
+
+func TestWriteAdminToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "admin.token")
+	token, err := writeAdminToken(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 64 {
+		t.Errorf("token %q, want 64 hex chars", token)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	if again, _ := writeAdminToken(path); again == token {
+		t.Error("expected a new token on every write")
+	}
+}
@@ -2,13 +2,15 @@ package main
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
-	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,6 +18,7 @@ import (
 	"github.com/moby/moby/api/pkg/stdcopy"
 	dockercontainer "github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/client"
+	"github.com/mtzanidakis/praktor/internal/container"
 )
 
 const (
@@ -23,9 +26,110 @@ const (
 	defaultHelperImage = "alpine:3"
 )
 
+// backupLevels maps -level values to zstd encoder levels.
+var backupLevels = map[string]zstd.EncoderLevel{
+	"fastest": zstd.SpeedFastest,
+	"default": zstd.SpeedDefault,
+	"better":  zstd.SpeedBetterCompression,
+	"best":    zstd.SpeedBestCompression,
+}
+
+// gzipLevels maps -level values to gzip levels for -format gzip.
+var gzipLevels = map[string]int{
+	"fastest": gzip.BestSpeed,
+	"default": gzip.DefaultCompression,
+	"better":  7,
+	"best":    gzip.BestCompression,
+}
+
+// Magic bytes at the start of each supported archive format.
+var (
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// newBackupEncoder returns a zstd writer for level ("" = default) using up
+// to threads goroutines (0 = zstd's default, GOMAXPROCS).
+func newBackupEncoder(w io.Writer, level string, threads int) (*zstd.Encoder, error) {
+	if level == "" {
+		level = "default"
+	}
+	lvl, ok := backupLevels[level]
+	if !ok {
+		return nil, fmt.Errorf("invalid -level %q (want fastest, default, better or best)", level)
+	}
+	opts := []zstd.EOption{zstd.WithEncoderLevel(lvl)}
+	if threads > 0 {
+		opts = append(opts, zstd.WithEncoderConcurrency(threads))
+	}
+	return zstd.NewWriter(w, opts...)
+}
+
+// newBackupWriter returns the compressor for format ("" = zstd). gzip is
+// single-threaded, so threads only applies to zstd.
+func newBackupWriter(w io.Writer, format, level string, threads int) (io.WriteCloser, error) {
+	switch format {
+	case "", "zstd":
+		return newBackupEncoder(w, level, threads)
+	case "gzip":
+		if level == "" {
+			level = "default"
+		}
+		lvl, ok := gzipLevels[level]
+		if !ok {
+			return nil, fmt.Errorf("invalid -level %q (want fastest, default, better or best)", level)
+		}
+		return gzip.NewWriterLevel(w, lvl)
+	default:
+		return nil, fmt.Errorf("invalid -format %q (want zstd or gzip)", format)
+	}
+}
+
+// newArchiveReader detects whether r holds a zstd or gzip stream from its
+// magic bytes and returns the matching decompressor.
+func newArchiveReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read archive header: %w", err)
+	}
+	switch {
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("create zstd reader: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("create gzip reader: %w", err)
+		}
+		return gr, nil
+	default:
+		return nil, fmt.Errorf("unrecognized archive format (want zstd or gzip)")
+	}
+}
+
+func writeBackupManifest(tw *tar.Writer, format, level string, volumes []string) error {
+	if format == "" {
+		format = "zstd"
+	}
+	if level == "" {
+		level = "default"
+	}
+	m := container.BackupManifest{CreatedAt: time.Now().UTC(), Volumes: volumes}
+	m.Compression.Algorithm = format
+	m.Compression.Level = level
+	return container.WriteBackupManifest(tw, m)
+}
+
 func runBackup(args []string) error {
 	var outputPath string
 	var helperImage string
+	var level string
+	var format string
+	threads := 0
 
 	for i := 0; i < len(args); i++ {
 		switch args[i] {
@@ -41,13 +145,39 @@ func runBackup(args []string) error {
 			}
 			i++
 			helperImage = args[i]
+		case "-level":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for -level")
+			}
+			i++
+			level = args[i]
+		case "-format":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for -format")
+			}
+			i++
+			format = args[i]
+		case "-threads":
+			if i+1 >= len(args) {
+				return fmt.Errorf("missing value for -threads")
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid -threads %q (want a positive integer)", args[i])
+			}
+			threads = n
 		}
 	}
 
 	if outputPath == "" {
-		fmt.Fprintf(os.Stderr, "Usage: praktor backup -f <output.tar.zst> [-image <helper-image>]\n")
+		fmt.Fprintf(os.Stderr, "Usage: praktor backup -f <output.tar.zst> [-format zstd|gzip] [-level fastest|default|better|best] [-threads N] [-image <helper-image>]\n")
 		return fmt.Errorf("missing -f flag")
 	}
+	// Reject bad options before touching docker or the output file.
+	if _, err := newBackupWriter(io.Discard, format, level, 1); err != nil {
+		return err
+	}
 	if helperImage == "" {
 		helperImage = defaultHelperImage
 	}
@@ -80,18 +210,22 @@ func runBackup(args []string) error {
 	}
 	defer func() { _ = f.Close() }()
 
-	zw, err := zstd.NewWriter(f)
+	zw, err := newBackupWriter(f, format, level, threads)
 	if err != nil {
-		return fmt.Errorf("create zstd writer: %w", err)
+		return fmt.Errorf("create compressor: %w", err)
 	}
 	defer func() { _ = zw.Close() }()
 
 	tw := tar.NewWriter(zw)
 	defer func() { _ = tw.Close() }()
 
+	if err := writeBackupManifest(tw, format, level, volumes); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+
 	for _, vol := range volumes {
 		slog.Info("backing up volume", "name", vol)
-		if err := backupVolume(ctx, docker, tw, vol, helperImage); err != nil {
+		if err := container.BackupVolume(ctx, docker, tw, vol, helperImage); err != nil {
 			return fmt.Errorf("backup volume %s: %w", vol, err)
 		}
 	}
@@ -101,7 +235,7 @@ func runBackup(args []string) error {
 		return fmt.Errorf("close tar: %w", err)
 	}
 	if err := zw.Close(); err != nil {
-		return fmt.Errorf("close zstd: %w", err)
+		return fmt.Errorf("close compressor: %w", err)
 	}
 	if err := f.Close(); err != nil {
 		return fmt.Errorf("close file: %w", err)
@@ -117,58 +251,6 @@ func runBackup(args []string) error {
 	return nil
 }
 
-func backupVolume(ctx context.Context, docker *client.Client, tw *tar.Writer, volName, image string) error {
-	containerName := fmt.Sprintf("praktor-backup-%d", time.Now().UnixNano())
-
-	resp, err := docker.ContainerCreate(ctx, client.ContainerCreateOptions{
-		Config:     &dockercontainer.Config{Image: image, Entrypoint: []string{"true"}},
-		HostConfig: &dockercontainer.HostConfig{Binds: []string{volName + ":/vol"}},
-		Name:       containerName,
-	})
-	if err != nil {
-		return fmt.Errorf("create temp container: %w", err)
-	}
-	defer func() {
-		_, _ = docker.ContainerRemove(ctx, resp.ID, client.ContainerRemoveOptions{Force: true})
-	}()
-
-	copyResp, err := docker.CopyFromContainer(ctx, resp.ID, client.CopyFromContainerOptions{SourcePath: "/vol/."})
-	if err != nil {
-		return fmt.Errorf("copy from container: %w", err)
-	}
-	defer func() { _ = copyResp.Content.Close() }()
-
-	// Re-write tar entries with volume name prefix
-	srcTar := tar.NewReader(copyResp.Content)
-	for {
-		hdr, err := srcTar.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("read tar entry: %w", err)
-		}
-
-		// Prefix entry name with volume name
-		hdr.Name = path.Join(volName, hdr.Name)
-		if hdr.Typeflag == tar.TypeDir && !strings.HasSuffix(hdr.Name, "/") {
-			hdr.Name += "/"
-		}
-
-		if err := tw.WriteHeader(hdr); err != nil {
-			return fmt.Errorf("write tar header: %w", err)
-		}
-
-		if hdr.Size > 0 {
-			if _, err := io.Copy(tw, srcTar); err != nil {
-				return fmt.Errorf("write tar data: %w", err)
-			}
-		}
-	}
-
-	return nil
-}
-
 func runRestore(args []string) error {
 	var inputPath string
 	var helperImage string
@@ -194,7 +276,7 @@ func runRestore(args []string) error {
 	}
 
 	if inputPath == "" {
-		fmt.Fprintf(os.Stderr, "Usage: praktor restore -f <backup.tar.zst> [-overwrite] [-image <helper-image>]\n")
+		fmt.Fprintf(os.Stderr, "Usage: praktor restore -f <backup.tar.zst|backup.tar.gz> [-overwrite] [-image <helper-image>]\n")
 		return fmt.Errorf("missing -f flag")
 	}
 	if helperImage == "" {
@@ -248,11 +330,11 @@ func runRestore(args []string) error {
 	}
 	defer func() { _ = f.Close() }()
 
-	zr, err := zstd.NewReader(f)
+	zr, err := newArchiveReader(f)
 	if err != nil {
-		return fmt.Errorf("create zstd reader: %w", err)
+		return err
 	}
-	defer zr.Close()
+	defer func() { _ = zr.Close() }()
 
 	tr := tar.NewReader(zr)
 
@@ -454,11 +536,11 @@ func scanArchiveVolumes(path string) ([]string, error) {
 	}
 	defer func() { _ = f.Close() }()
 
-	zr, err := zstd.NewReader(f)
+	zr, err := newArchiveReader(f)
 	if err != nil {
 		return nil, err
 	}
-	defer zr.Close()
+	defer func() { _ = zr.Close() }()
 
 	tr := tar.NewReader(zr)
 
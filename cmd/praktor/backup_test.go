@@ -3,12 +3,17 @@ package main
 import (
 	"archive/tar"
 	"bytes"
+	"encoding/json"
 	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/klauspost/compress/zstd"
+	"github.com/mtzanidakis/praktor/internal/container"
 )
 
 func TestSplitVolumePath(t *testing.T) {
@@ -188,7 +193,7 @@ func TestScanArchiveVolumes_InvalidZstd(t *testing.T) {
 // TestArchiveRoundTrip verifies that tar entries written with volume prefixes
 // can be correctly scanned and split back into volume + relative path.
 func TestArchiveRoundTrip(t *testing.T) {
-	// Simulate what backupVolume produces: entries with volume prefix
+	// Simulate what container.BackupVolume produces: entries with volume prefix
 	var buf bytes.Buffer
 	zw, _ := zstd.NewWriter(&buf)
 	tw := tar.NewWriter(zw)
@@ -281,3 +286,162 @@ func TestArchiveRoundTrip(t *testing.T) {
 		t.Errorf("expected EOF, got %v", err)
 	}
 }
+
+// writeLevelArchive builds a zstd archive the way runBackup does, using level.
+func writeLevelArchive(t *testing.T, level string, files map[string]string) []byte {
+	t.Helper()
+	return writeFormatArchive(t, "zstd", level, files)
+}
+
+// writeFormatArchive builds an archive the way runBackup does with -format.
+func writeFormatArchive(t *testing.T, format, level string, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw, err := newBackupWriter(&buf, format, level, 2)
+	if err != nil {
+		t.Fatalf("writer %s/%s: %v", format, level, err)
+	}
+	tw := tar.NewWriter(zw)
+	if err := writeBackupManifest(tw, format, level, []string{"praktor-data"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		content := files[name]
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))})
+		_, _ = tw.Write([]byte(content))
+	}
+	_ = tw.Close()
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+// readRestoredFiles decodes an archive with restore's reader and returns the
+// volume files it would extract, plus the manifest.
+func readRestoredFiles(t *testing.T, data []byte) (map[string]string, container.BackupManifest) {
+	t.Helper()
+	zr, err := newArchiveReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	files := map[string]string{}
+	var manifest container.BackupManifest
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(tr)
+		if hdr.Name == container.BackupManifestName {
+			if err := json.Unmarshal(content, &manifest); err != nil {
+				t.Fatalf("manifest: %v", err)
+			}
+			continue
+		}
+		if vol, _ := splitVolumePath(hdr.Name); vol != "" {
+			files[hdr.Name] = string(content)
+		}
+	}
+	return files, manifest
+}
+
+func TestBackupLevelsRestoreIdentically(t *testing.T) {
+	files := map[string]string{
+		"praktor-data/db.sqlite":      strings.Repeat("sqlite page data ", 4096),
+		"praktor-data/sub/record.txt": "hello",
+		"praktor-wk-coder/main.go":    "package main\n",
+	}
+
+	fastest, fastManifest := readRestoredFiles(t, writeLevelArchive(t, "fastest", files))
+	best, bestManifest := readRestoredFiles(t, writeLevelArchive(t, "best", files))
+
+	if !maps.Equal(fastest, files) {
+		t.Errorf("fastest archive restored %v", slices.Sorted(maps.Keys(fastest)))
+	}
+	if !maps.Equal(best, fastest) {
+		t.Error("best and fastest archives restore different content")
+	}
+	if fastManifest.Compression.Level != "fastest" || bestManifest.Compression.Level != "best" {
+		t.Errorf("manifest levels = %q, %q", fastManifest.Compression.Level, bestManifest.Compression.Level)
+	}
+	if bestManifest.Compression.Algorithm != "zstd" {
+		t.Errorf("manifest algorithm = %q", bestManifest.Compression.Algorithm)
+	}
+}
+
+func TestBackupManifestIgnoredByScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.tar.zst")
+	_ = os.WriteFile(path, writeLevelArchive(t, "", map[string]string{"praktor-data/a": "x"}), 0o644)
+
+	volumes, err := scanArchiveVolumes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes) != 1 || volumes[0] != "praktor-data" {
+		t.Errorf("volumes = %v, want [praktor-data]", volumes)
+	}
+}
+
+func TestNewBackupEncoderRejectsUnknownLevel(t *testing.T) {
+	if _, err := newBackupEncoder(io.Discard, "ultra", 0); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestRestoreZstdAndGzip(t *testing.T) {
+	files := map[string]string{
+		"praktor-data/db.sqlite":   strings.Repeat("sqlite page data ", 4096),
+		"praktor-wk-coder/main.go": "package main\n",
+	}
+
+	zstdData := writeFormatArchive(t, "zstd", "", files)
+	gzipData := writeFormatArchive(t, "gzip", "best", files)
+	if !bytes.HasPrefix(gzipData, gzipMagic) || !bytes.HasPrefix(zstdData, zstdMagic) {
+		t.Fatal("archives do not start with their format's magic bytes")
+	}
+
+	fromZstd, zstdManifest := readRestoredFiles(t, zstdData)
+	fromGzip, gzipManifest := readRestoredFiles(t, gzipData)
+	if !maps.Equal(fromZstd, files) {
+		t.Errorf("zstd archive restored %v", slices.Sorted(maps.Keys(fromZstd)))
+	}
+	if !maps.Equal(fromGzip, fromZstd) {
+		t.Error("gzip and zstd archives restore different content")
+	}
+	if zstdManifest.Compression.Algorithm != "zstd" || gzipManifest.Compression.Algorithm != "gzip" {
+		t.Errorf("manifest algorithms = %q, %q", zstdManifest.Compression.Algorithm, gzipManifest.Compression.Algorithm)
+	}
+
+	// Detection goes by content, not by file extension.
+	path := filepath.Join(t.TempDir(), "backup.tar.zst")
+	_ = os.WriteFile(path, gzipData, 0o644)
+	volumes, err := scanArchiveVolumes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(volumes, []string{"praktor-data", "praktor-wk-coder"}) {
+		t.Errorf("volumes = %v", volumes)
+	}
+}
+
+func TestNewArchiveReaderRejectsUnknownFormat(t *testing.T) {
+	for _, data := range []string{"", "x", "PK\x03\x04 not a tarball"} {
+		if _, err := newArchiveReader(strings.NewReader(data)); err == nil {
+			t.Errorf("newArchiveReader(%q): expected error", data)
+		}
+	}
+}
+
+func TestNewBackupWriterRejectsUnknownFormat(t *testing.T) {
+	if _, err := newBackupWriter(io.Discard, "bzip2", "", 0); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, err := newBackupWriter(io.Discard, "gzip", "ultra", 0); err == nil {
+		t.Error("expected error for unknown gzip level")
+	}
+}
@@ -3,10 +3,13 @@ package main
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -14,7 +17,9 @@ import (
 	"github.com/mtzanidakis/praktor/internal/agentmail"
 	"github.com/mtzanidakis/praktor/internal/config"
 	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/logring"
 	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/mtzanidakis/praktor/internal/notify"
 	"github.com/mtzanidakis/praktor/internal/registry"
 	"github.com/mtzanidakis/praktor/internal/router"
 	"github.com/mtzanidakis/praktor/internal/scheduler"
@@ -22,6 +27,7 @@ import (
 	"github.com/mtzanidakis/praktor/internal/store"
 	"github.com/mtzanidakis/praktor/internal/swarm"
 	"github.com/mtzanidakis/praktor/internal/telegram"
+	"github.com/mtzanidakis/praktor/internal/tracing"
 	"github.com/mtzanidakis/praktor/internal/vault"
 	"github.com/mtzanidakis/praktor/internal/web"
 )
@@ -38,7 +44,7 @@ func main() {
 	case "version":
 		fmt.Printf("praktor %s\n", version)
 	case "gateway":
-		if err := runGateway(); err != nil {
+		if err := runGateway(os.Args[2:]); err != nil {
 			slog.Error("gateway failed", "error", err)
 			os.Exit(1)
 		}
@@ -47,6 +53,16 @@ func main() {
 			slog.Error("vault command failed", "error", err)
 			os.Exit(1)
 		}
+	case "agents":
+		if err := runAgents(os.Args[2:]); err != nil {
+			slog.Error("agents command failed", "error", err)
+			os.Exit(1)
+		}
+	case "agent":
+		if err := runAgent(os.Args[2:]); err != nil {
+			slog.Error("agent command failed", "error", err)
+			os.Exit(1)
+		}
 	case "backup":
 		if err := runBackup(os.Args[2:]); err != nil {
 			slog.Error("backup failed", "error", err)
@@ -57,6 +73,16 @@ func main() {
 			slog.Error("restore failed", "error", err)
 			os.Exit(1)
 		}
+	case "snapshot":
+		if err := runSnapshot(os.Args[2:]); err != nil {
+			slog.Error("snapshot failed", "error", err)
+			os.Exit(1)
+		}
+	case "volumes":
+		if err := runVolumes(os.Args[2:]); err != nil {
+			slog.Error("volumes command failed", "error", err)
+			os.Exit(1)
+		}
 	default:
 		printUsage()
 		os.Exit(1)
@@ -64,34 +90,72 @@ func main() {
 }
 
 func printUsage() {
-	fmt.Fprintf(os.Stderr, "Usage: praktor <command>\n\nCommands:\n  gateway    Start the Praktor gateway service\n  vault      Manage encrypted secrets\n  backup     Back up all praktor Docker volumes\n  restore    Restore praktor Docker volumes from backup\n  version    Print version\n")
+	fmt.Fprintf(os.Stderr, "Usage: praktor <command>\n\nCommands:\n  gateway    Start the Praktor gateway service (--safe-mode: start nothing, read-only web UI)\n  vault      Manage encrypted secrets\n  agents     List agents of the running gateway\n  agent      Show logs, stop or restart an agent\n  backup     Back up all praktor Docker volumes\n  restore    Restore praktor Docker volumes from backup\n  snapshot   Archive one agent's workspace volume\n  volumes    Prune agent volumes no agent uses\n  version    Print version\n")
 }
 
-func runGateway() error {
+func runGateway(args []string) error {
+	safeMode, err := parseGatewayArgs(args, os.Getenv)
+	if err != nil {
+		return err
+	}
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("load config: %w", err)
 	}
 
-	slog.Info("starting praktor gateway", "version", version)
+	// Keep recent log records for the web UI
+	var logs *logring.Ring
+	if cfg.Web.Enabled {
+		logs = logring.New(cfg.Web.LogBuffer)
+		logs.SetDefault()
+	}
+
+	slog.Info("starting praktor gateway", "version", version, "safe_mode", safeMode)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Teardown runs in phases, before ctx is cancelled: see shutdown.go.
+	shutdown := newShutdownCoordinator()
+	defer shutdown.run()
+
+	// Telegram, web, scheduler and agentmail take new work; they are
+	// stopped first.
+	ingressCtx, cancelIngress := context.WithCancel(ctx)
+	var ingress sync.WaitGroup
+	shutdown.add(phaseIngress, "ingress", func(sctx context.Context) error {
+		cancelIngress()
+		return waitGroup(sctx, &ingress)
+	})
+
+	// Tracing (no-op unless an OTLP endpoint is configured)
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("init tracing: %w", err)
+	}
+	shutdown.add(phaseClose, "tracing", shutdownTracing)
+	if cfg.Tracing.OTLPEndpoint != "" {
+		slog.Info("tracing enabled", "endpoint", cfg.Tracing.OTLPEndpoint)
+	}
+
 	// SQLite store
 	db, err := store.New(config.StorePath)
 	if err != nil {
 		return fmt.Errorf("init store: %w", err)
 	}
-	defer func() { _ = db.Close() }()
+	shutdown.add(phaseClose, "store", func(context.Context) error { return db.Close() })
 	slog.Info("store initialized", "path", config.StorePath)
 
 	// Embedded NATS
+	natsbus.SetSubjectPrefix(cfg.NATS.SubjectPrefix)
 	bus, err := natsbus.New(cfg.NATS)
 	if err != nil {
 		return fmt.Errorf("init nats: %w", err)
 	}
-	defer bus.Close()
+	shutdown.add(phaseClose, "nats", func(context.Context) error {
+		bus.Close()
+		return nil
+	})
 	slog.Info("nats started", "port", config.NATSPort)
 
 	// Agent registry
@@ -106,33 +170,66 @@ func runGateway() error {
 	if err != nil {
 		return fmt.Errorf("init container manager: %w", err)
 	}
+	// Registered first, so it runs after "agents" below.
+	shutdown.add(phaseContainers, "volume helpers", func(sctx context.Context) error {
+		ctrMgr.ReleaseVolumeHelpers(sctx)
+		return nil
+	})
 
 	// Vault
 	if cfg.Vault.Passphrase == "" {
 		return fmt.Errorf("vault passphrase is required (set PRAKTOR_VAULT_PASSPHRASE or vault.passphrase in config)")
 	}
 	v := vault.New(cfg.Vault.Passphrase)
+	if err := checkVault(db, v, cfg.Vault.RequireVerify); err != nil {
+		return err
+	}
 	slog.Info("vault initialized")
 
 	// Agent orchestrator
 	orch := agent.NewOrchestrator(bus, ctrMgr, db, reg, cfg.Defaults, v)
+	orch.SetSafeMode(safeMode)
+	adminToken, err := writeAdminToken(config.AdminTokenPath)
+	if err != nil {
+		return err
+	}
+	orch.SetAdminToken(adminToken)
+
+	shutdown.add(phaseDrain, "in-flight messages", orch.WaitIdle)
+	shutdown.add(phaseContainers, "agents", func(sctx context.Context) error {
+		orch.StopAll(sctx)
+		return nil
+	})
+	// Runs before "agents": the reaper and heartbeat must not race the stops.
+	shutdown.add(phaseContainers, "background loops", func(context.Context) error {
+		cancel()
+		return nil
+	})
+	shutdown.add(phaseFlush, "orchestrator events", func(context.Context) error { return orch.Flush() })
 
 	// Message router
 	rtr := router.New(reg, cfg.Router)
 	rtr.SetOrchestrator(orch)
-	// Idle reaper
-	go orch.StartIdleReaper(ctx)
-
-	// Nix garbage collection
-	go orch.StartNixGC(ctx)
-
 	// Swarm coordinator
 	swarmCoord := swarm.NewCoordinator(bus, ctrMgr, db, reg, v)
 	orch.SetSwarmCoordinator(swarmCoord)
 
 	// Scheduler
-	sched := scheduler.New(db, orch, bus, cfg.Scheduler, cfg.Telegram.MainChatID)
-	go sched.Start(ctx)
+	sched := scheduler.New(db, orch, bus, cfg.Scheduler, cfg.Telegram.MainChatIDFor)
+
+	// Background loops, none of which run in safe mode
+	loops := []gatewayLoop{
+		{name: "idle reaper", run: func() { orch.StartIdleReaper(ctx) }},
+		{name: "heartbeat", run: func() { orch.StartHeartbeat(ctx) }},
+		{name: "nix gc", run: func() { orch.StartNixGC(ctx) }},
+		{name: "quota checker", run: func() { orch.StartQuotaChecker(ctx) }},
+		{name: "artifact pruner", run: func() { orch.StartArtifactPruner(ctx) }},
+		{name: "activity pruner", run: func() { orch.StartActivityPruner(ctx) }},
+		{name: "image watcher", run: func() { orch.StartImageWatcher(ctx) }},
+		{name: "swarm orphan sweep", run: func() { swarmCoord.StartOrphanSweep(ctx) }},
+		{name: "swarm retention sweep", run: func() { swarmCoord.StartRetentionSweep(ctx) }},
+		{name: "scheduler", run: func() { sched.Start(ingressCtx) }, wg: &ingress},
+	}
 
 	// Speech-to-text / text-to-speech (OpenAI API)
 	var speechClient *speech.Client
@@ -142,13 +239,15 @@ func runGateway() error {
 	}
 
 	// Telegram bot
-	if cfg.Telegram.Token != "" {
-		bot, err := telegram.NewBot(cfg.Telegram, orch, rtr, swarmCoord, reg, bus, db, speechClient, cfg.Speech)
+	if len(cfg.Telegram.BotConfigs()) > 0 {
+		bots, err := telegram.NewBots(cfg.Telegram, orch, rtr, swarmCoord, reg, bus, db, speechClient, cfg.Speech)
 		if err != nil {
 			return fmt.Errorf("init telegram bot: %w", err)
 		}
-		go func() { _ = bot.Start(ctx) }()
-		slog.Info("telegram bot started")
+		for _, bot := range bots {
+			ingress.Go(func() { _ = bot.Start(ingressCtx) })
+		}
+		slog.Info("telegram bot started", "bots", len(bots))
 	} else {
 		slog.Warn("telegram token not set, bot disabled")
 	}
@@ -156,19 +255,49 @@ func runGateway() error {
 	// AgentMail
 	if cfg.AgentMail.APIKey != "" {
 		orch.SetAgentMailAPIKey(cfg.AgentMail.APIKey)
-		amClient := agentmail.NewClient(cfg.AgentMail.APIKey, reg, orch.HandleMessage, cfg.Telegram.MainChatID)
-		go amClient.Run(ctx)
-		slog.Info("agentmail websocket client started")
+		amClient := agentmail.NewClient(cfg.AgentMail.APIKey, reg, orch.HandleMessage, cfg.Telegram.MainChatIDFor)
+		loops = append(loops, gatewayLoop{name: "agentmail", run: func() { amClient.Run(ingressCtx) }, wg: &ingress})
 	}
 
+	started := startLoops(loops, safeMode)
+	if safeMode {
+		slog.Warn("safe mode: agents, scheduler and background loops are not started; the web API is read-only")
+	} else {
+		slog.Info("background loops started", "loops", started)
+	}
+
+	// Event notifications (Slack, Discord, email)
+	if len(cfg.Notifications.Backends) > 0 {
+		notifier, err := notify.New(bus, cfg.Notifications)
+		if err != nil {
+			return fmt.Errorf("init notifications: %w", err)
+		}
+		go notifier.Run(ctx)
+		slog.Info("notifications enabled", "backends", len(cfg.Notifications.Backends))
+	}
+
+	// Reloads from the web API are handed to the main loop below
+	reloader := &reloadController{requests: make(chan chan reloadResult)}
+	reloader.setLoaded(cfg)
+
+	// Admin CLI socket
+	ingress.Go(func() {
+		if err := orch.ServeAdmin(ingressCtx, config.AdminSocketPath); err != nil {
+			slog.Error("admin socket error", "error", err)
+		}
+	})
+
 	// Web UI
 	if cfg.Web.Enabled {
 		srv := web.NewServer(db, bus, orch, reg, rtr, swarmCoord, cfg.Web, v, version)
-		go func() {
-			if err := srv.Start(ctx); err != nil {
+		srv.SetConfigReloader(reloader)
+		srv.SetLogRing(logs)
+		srv.SetSafeMode(safeMode)
+		ingress.Go(func() {
+			if err := srv.Start(ingressCtx); err != nil {
 				slog.Error("web server error", "error", err)
 			}
-		}()
+		})
 	}
 
 	// Config file watcher — polls mtime every 3s
@@ -181,26 +310,91 @@ func runGateway() error {
 
 	currentCfg := cfg
 	for {
+		var reply chan reloadResult
 		select {
 		case sig := <-sigCh:
 			if sig == syscall.SIGHUP {
 				slog.Info("received SIGHUP, reloading config")
 			} else {
 				slog.Info("shutting down", "signal", sig)
-				cancel()
-				ctrMgr.StopAll(context.Background())
 				return nil
 			}
 		case <-reloadCh:
 			slog.Info("config file changed, reloading")
+		case reply = <-reloader.requests:
+			slog.Info("config reload requested via API")
 		}
 
-		updated, err := reloadConfig(ctx, currentCfg, reg, orch, ctrMgr, rtr, sched)
+		updated, diff, err := reloadConfig(ctx, currentCfg, reg, orch, ctrMgr, rtr, sched)
+		if reply != nil {
+			reply <- reloadResult{diff: diff, err: err}
+		}
 		if err != nil {
 			slog.Error("config reload failed", "error", err)
 			continue
 		}
 		currentCfg = updated
+		reloader.setLoaded(updated)
+	}
+}
+
+type reloadResult struct {
+	diff config.ConfigDiff
+	err  error
+}
+
+// reloadController lets the web API trigger a reload on the main loop, so
+// API, SIGHUP and file-watcher reloads never run concurrently.
+type reloadController struct {
+	requests chan chan reloadResult // unbuffered: only accepted while the main loop is idle
+	current  atomic.Pointer[web.LoadedConfig]
+}
+
+// setLoaded records cfg as the running config, hashing the config file it
+// was just loaded from.
+func (c *reloadController) setLoaded(cfg *config.Config) {
+	loaded := &web.LoadedConfig{Config: cfg, Path: config.Path(), LoadedAt: time.Now()}
+	if h, err := hashFile(loaded.Path); err == nil {
+		loaded.Hash = hex.EncodeToString(h[:])
+	}
+	c.current.Store(loaded)
+}
+
+// LoadedConfig implements web.ConfigReloader.
+func (c *reloadController) LoadedConfig() web.LoadedConfig {
+	return *c.current.Load()
+}
+
+// PreviewConfig implements web.ConfigReloader. It diffs data (or the config
+// file when data is empty) against the running config without applying it.
+func (c *reloadController) PreviewConfig(data []byte) (config.ConfigDiff, error) {
+	var newCfg *config.Config
+	var err error
+	if len(data) > 0 {
+		newCfg, err = config.Parse(data)
+	} else {
+		newCfg, err = config.Load()
+	}
+	if err != nil {
+		return config.ConfigDiff{}, err
+	}
+	return config.Diff(c.current.Load().Config, newCfg), nil
+}
+
+// ReloadConfig implements web.ConfigReloader. It fails fast with
+// web.ErrReloadInProgress when the main loop is busy applying a reload.
+func (c *reloadController) ReloadConfig(ctx context.Context) (config.ConfigDiff, error) {
+	reply := make(chan reloadResult, 1)
+	select {
+	case c.requests <- reply:
+	default:
+		return config.ConfigDiff{}, web.ErrReloadInProgress
+	}
+	select {
+	case res := <-reply:
+		return res.diff, res.err
+	case <-ctx.Done():
+		return config.ConfigDiff{}, ctx.Err()
 	}
 }
 
@@ -271,10 +465,10 @@ func reloadConfig(
 	ctrMgr *container.Manager,
 	rtr *router.Router,
 	sched *scheduler.Scheduler,
-) (*config.Config, error) {
+) (*config.Config, config.ConfigDiff, error) {
 	newCfg, err := config.Load()
 	if err != nil {
-		return nil, fmt.Errorf("load config: %w", err)
+		return nil, config.ConfigDiff{}, fmt.Errorf("load config: %w", err)
 	}
 
 	diff := config.Diff(oldCfg, newCfg)
@@ -286,13 +480,13 @@ func reloadConfig(
 
 	if !diff.HasChanges() {
 		slog.Info("config reload: no reloadable changes detected")
-		return newCfg, nil
+		return newCfg, diff, nil
 	}
 
 	// Update registry (agents + defaults)
 	if len(diff.AgentsAdded) > 0 || len(diff.AgentsRemoved) > 0 || len(diff.AgentsChanged) > 0 || diff.DefaultsChanged {
 		if err := reg.Update(newCfg.Agents, newCfg.Defaults); err != nil {
-			return nil, fmt.Errorf("update registry: %w", err)
+			return nil, diff, fmt.Errorf("update registry: %w", err)
 		}
 		slog.Info("registry updated",
 			"added", diff.AgentsAdded,
@@ -311,24 +505,33 @@ func reloadConfig(
 	// Update router default agent and vector threshold
 	if diff.RouterChanged {
 		rtr.SetDefaultAgent(diff.NewDefaultAgent)
+		rtr.SetChatDefaults(diff.NewRouter.ChatDefaults, diff.NewRouter.UserDefaults)
 		slog.Info("router updated", "default_agent", diff.NewDefaultAgent)
 	}
 
 	// Update scheduler
 	if diff.SchedulerChanged || diff.MainChatIDChanged {
-		pollInterval := newCfg.Scheduler.PollInterval
-		mainChatID := newCfg.Telegram.MainChatID
-		sched.UpdateConfig(pollInterval, mainChatID)
-		slog.Info("scheduler config updated", "poll_interval", pollInterval, "main_chat_id", mainChatID)
+		sched.UpdateConfig(newCfg.Scheduler, newCfg.Telegram.MainChatIDFor)
+		slog.Info("scheduler config updated", "poll_interval", newCfg.Scheduler.PollInterval,
+			"concurrency", newCfg.Scheduler.Concurrency, "main_chat_id", newCfg.Telegram.MainChatID)
+	}
+
+	// Cached responses may no longer match what the agent would answer
+	if diff.DefaultsChanged {
+		for name := range newCfg.Agents {
+			orch.InvalidateResponseCache(name)
+		}
+	} else {
+		for _, agentID := range diff.AgentsChanged {
+			orch.InvalidateResponseCache(agentID)
+		}
 	}
 
-	// Stop running agents whose config changed (lazy restart on next message)
+	// Restart agents whose config changed once their in-flight messages are
+	// answered (lazy start on next message)
 	for _, agentID := range diff.AgentsChanged {
-		if ctrMgr.GetRunning(agentID) != nil {
-			slog.Info("stopping agent due to config change", "agent", agentID)
-			if err := orch.StopAgent(ctx, agentID); err != nil {
-				slog.Error("failed to stop changed agent", "agent", agentID, "error", err)
-			}
+		if err := orch.RestartAgent(ctx, agentID, "config_changed"); err != nil {
+			slog.Error("failed to restart changed agent", "agent", agentID, "error", err)
 		}
 	}
 
@@ -340,8 +543,9 @@ func reloadConfig(
 				slog.Error("failed to stop removed agent", "agent", agentID, "error", err)
 			}
 		}
+		ctrMgr.RemoveStopped(ctx, agentID)
 	}
 
 	slog.Info("config reload complete")
-	return newCfg, nil
+	return newCfg, diff, nil
 }
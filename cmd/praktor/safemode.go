@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// parseGatewayArgs reports whether the gateway should start in safe mode:
+// with --safe-mode, or with PRAKTOR_SAFE_MODE set to a true value (1, true).
+// Unknown arguments are an error, so a mistyped flag doesn't start agents.
+func parseGatewayArgs(args []string, getenv func(string) string) (safeMode bool, err error) {
+	for _, arg := range args {
+		if arg != "--safe-mode" {
+			return false, fmt.Errorf("unknown gateway argument %q (usage: praktor gateway [--safe-mode])", arg)
+		}
+		safeMode = true
+	}
+	if v := getenv("PRAKTOR_SAFE_MODE"); v != "" && !safeMode {
+		if safeMode, err = strconv.ParseBool(v); err != nil {
+			return false, fmt.Errorf("PRAKTOR_SAFE_MODE %q is not a boolean", v)
+		}
+	}
+	return safeMode, nil
+}
+
+// gatewayLoop is a background loop that acts on agents, tasks or swarms on
+// its own. Safe mode starts none of them.
+type gatewayLoop struct {
+	name string
+	run  func()
+	wg   *sync.WaitGroup // waited for at shutdown; nil = not tracked
+}
+
+// startLoops starts each loop in its own goroutine, or none in safe mode,
+// and returns the names of the loops started.
+func startLoops(loops []gatewayLoop, safeMode bool) []string {
+	if safeMode {
+		return nil
+	}
+	names := make([]string, 0, len(loops))
+	for _, l := range loops {
+		if l.wg != nil {
+			l.wg.Go(l.run)
+		} else {
+			go l.run()
+		}
+		names = append(names, l.name)
+	}
+	return names
+}
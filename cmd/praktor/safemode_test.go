@@ -0,0 +1,82 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseGatewayArgs(t *testing.T) {
+	env := func(v string) func(string) string {
+		return func(key string) string {
+			if key == "PRAKTOR_SAFE_MODE" {
+				return v
+			}
+			return ""
+		}
+	}
+	tests := []struct {
+		args    []string
+		env     string
+		want    bool
+		wantErr bool
+	}{
+		{nil, "", false, false},
+		{[]string{"--safe-mode"}, "", true, false},
+		{nil, "1", true, false},
+		{nil, "true", true, false},
+		{nil, "0", false, false},
+		{nil, "maybe", false, true},
+		{[]string{"--safemode"}, "", false, true},
+	}
+	for _, tt := range tests {
+		got, err := parseGatewayArgs(tt.args, env(tt.env))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseGatewayArgs(%v, %q) = %v, %v; want %v, error %v", tt.args, tt.env, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestStartLoopsSafeMode(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+	var tracked sync.WaitGroup
+	loop := func(name string, wg *sync.WaitGroup) gatewayLoop {
+		return gatewayLoop{name: name, wg: wg, run: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, name)
+		}}
+	}
+	loops := []gatewayLoop{loop("idle reaper", nil), loop("nix gc", nil), loop("scheduler", &tracked)}
+
+	if started := startLoops(loops, true); len(started) != 0 {
+		t.Errorf("safe mode started %v", started)
+	}
+	time.Sleep(20 * time.Millisecond)
+	tracked.Wait()
+	mu.Lock()
+	if len(ran) != 0 {
+		t.Errorf("safe mode ran %v", ran)
+	}
+	mu.Unlock()
+
+	started := startLoops(loops, false)
+	if want := []string{"idle reaper", "nix gc", "scheduler"}; !slices.Equal(started, want) {
+		t.Errorf("started %v, want %v", started, want)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(ran)
+		mu.Unlock()
+		if n == len(loops) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d loops ran", n, len(loops))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
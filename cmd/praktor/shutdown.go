@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// shutdownPhase orders the gateway's teardown; phases run in ascending
+// order.
+type shutdownPhase int
+
+const (
+	phaseIngress    shutdownPhase = iota + 1 // stop taking work: telegram, web, scheduler, agentmail
+	phaseDrain                               // let in-flight messages get their results
+	phaseContainers                          // stop agents, publishing agent_stopped
+	phaseFlush                               // push pending NATS publishes to the server
+	phaseClose                               // close the bus, store and tracing
+)
+
+var phaseNames = map[shutdownPhase]string{
+	phaseIngress:    "ingress",
+	phaseDrain:      "drain",
+	phaseContainers: "containers",
+	phaseFlush:      "flush",
+	phaseClose:      "close",
+}
+
+// defaultPhaseTimeouts keep the whole shutdown within about a minute.
+var defaultPhaseTimeouts = map[shutdownPhase]time.Duration{
+	phaseIngress:    5 * time.Second,
+	phaseDrain:      15 * time.Second,
+	phaseContainers: 30 * time.Second,
+	phaseFlush:      2 * time.Second,
+	phaseClose:      5 * time.Second,
+}
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// shutdownCoordinator runs registered teardown hooks phase by phase.
+type shutdownCoordinator struct {
+	hooks    map[shutdownPhase][]shutdownHook
+	timeouts map[shutdownPhase]time.Duration // replaced in tests
+}
+
+func newShutdownCoordinator() *shutdownCoordinator {
+	return &shutdownCoordinator{
+		hooks:    make(map[shutdownPhase][]shutdownHook),
+		timeouts: defaultPhaseTimeouts,
+	}
+}
+
+// add registers fn to run in phase. Within a phase hooks run one after
+// another in reverse order of registration, like defers, so a component
+// is torn down before the ones it was built on.
+func (c *shutdownCoordinator) add(phase shutdownPhase, name string, fn func(ctx context.Context) error) {
+	c.hooks[phase] = append(c.hooks[phase], shutdownHook{name, fn})
+}
+
+// run executes every phase. A phase whose hooks overrun its timeout is
+// abandoned, with the remaining hooks left running in the background, and
+// the next phase starts; failures are logged, never fatal.
+func (c *shutdownCoordinator) run() {
+	for _, phase := range slices.Sorted(maps.Keys(c.hooks)) {
+		hooks := c.hooks[phase]
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeouts[phase])
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for _, h := range slices.Backward(hooks) {
+				if err := h.fn(ctx); err != nil {
+					slog.Warn("shutdown step failed", "phase", phaseNames[phase], "step", h.name, "error", err)
+				}
+			}
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			slog.Warn("shutdown phase timed out", "phase", phaseNames[phase], "timeout", c.timeouts[phase])
+		}
+		cancel()
+	}
+}
+
+// waitGroup waits for wg, giving up when ctx is done.
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownPhaseOrder(t *testing.T) {
+	c := newShutdownCoordinator()
+	var mu sync.Mutex
+	var order []string
+	hook := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	// Registered in the order runGateway creates things, not the order
+	// they are torn down in.
+	c.add(phaseIngress, "ingress", hook("ingress"))
+	c.add(phaseClose, "tracing", hook("tracing"))
+	c.add(phaseClose, "store", hook("store"))
+	c.add(phaseClose, "nats", hook("nats"))
+	c.add(phaseDrain, "drain", hook("drain"))
+	c.add(phaseContainers, "agents", hook("agents"))
+	c.add(phaseContainers, "loops", hook("loops"))
+	c.add(phaseFlush, "flush", hook("flush"))
+	c.run()
+
+	want := []string{"ingress", "drain", "loops", "agents", "flush", "nats", "store", "tracing"}
+	if !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestShutdownPhaseTimeout(t *testing.T) {
+	c := newShutdownCoordinator()
+	c.timeouts = map[shutdownPhase]time.Duration{phaseDrain: 20 * time.Millisecond, phaseClose: time.Second}
+	hung := make(chan struct{})
+	defer close(hung)
+	c.add(phaseDrain, "stuck", func(context.Context) error {
+		<-hung
+		return nil
+	})
+	closed := false
+	c.add(phaseClose, "store", func(context.Context) error {
+		closed = true
+		return nil
+	})
+
+	start := time.Now()
+	c.run()
+	if !closed {
+		t.Error("a timed-out phase kept later phases from running")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("shutdown took %v, want the 20ms drain timeout to cut it short", d)
+	}
+}
+
+func TestWaitGroupTimeout(t *testing.T) {
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	wg.Go(func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := waitGroup(ctx, &wg); err == nil {
+		t.Error("expected a timeout while the group is busy")
+	}
+	close(release)
+	if err := waitGroup(context.Background(), &wg); err != nil {
+		t.Errorf("waitGroup after release: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/moby/moby/client"
+	"github.com/mtzanidakis/praktor/internal/container"
+)
+
+type snapshotOptions struct {
+	agentID     string
+	outputPath  string
+	workspace   string
+	helperImage string
+}
+
+func parseSnapshotArgs(args []string) (snapshotOptions, error) {
+	var opts snapshotOptions
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-f":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("missing value for -f")
+			}
+			i++
+			opts.outputPath = args[i]
+		case "-workspace":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("missing value for -workspace")
+			}
+			i++
+			opts.workspace = args[i]
+		case "-image":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("missing value for -image")
+			}
+			i++
+			opts.helperImage = args[i]
+		default:
+			if opts.agentID != "" {
+				return opts, fmt.Errorf("unexpected argument: %s", args[i])
+			}
+			opts.agentID = args[i]
+		}
+	}
+	if opts.agentID == "" {
+		return opts, fmt.Errorf("missing agent id")
+	}
+	if opts.outputPath == "" {
+		return opts, fmt.Errorf("missing -f flag")
+	}
+	if opts.workspace == "" {
+		opts.workspace = opts.agentID
+	}
+	if opts.helperImage == "" {
+		opts.helperImage = defaultHelperImage
+	}
+	return opts, nil
+}
+
+// runSnapshot archives one agent's workspace volume. The archive is a
+// single-volume backup whose manifest names the agent, so it can be restored
+// with `praktor restore` or POST /api/agents/definitions/{id}/restore-snapshot.
+func runSnapshot(args []string) error {
+	opts, err := parseSnapshotArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Usage: praktor snapshot <agent> -f <output.tar.zst> [-workspace <name>] [-image <helper-image>]\n")
+		return err
+	}
+	volName := "praktor-wk-" + container.SanitizeVolumeName(opts.workspace)
+
+	ctx := context.Background()
+	docker, err := client.New(client.FromEnv)
+	if err != nil {
+		return fmt.Errorf("docker client: %w", err)
+	}
+	defer func() { _ = docker.Close() }()
+
+	if _, err := docker.VolumeInspect(ctx, volName, client.VolumeInspectOptions{}); err != nil {
+		return fmt.Errorf("volume %s: %w", volName, err)
+	}
+	if err := ensureImage(ctx, docker, opts.helperImage); err != nil {
+		return fmt.Errorf("pull helper image: %w", err)
+	}
+
+	f, err := os.Create(opts.outputPath)
+	if err != nil {
+		return fmt.Errorf("create output file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := container.WriteSnapshot(ctx, docker, f, opts.agentID, volName, opts.helperImage); err != nil {
+		return fmt.Errorf("snapshot volume %s: %w", volName, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close file: %w", err)
+	}
+
+	size := int64(0)
+	if info, _ := os.Stat(opts.outputPath); info != nil {
+		size = info.Size()
+	}
+	fmt.Printf("Snapshot complete: %s, %s\n", volName, formatSize(size))
+	return nil
+}
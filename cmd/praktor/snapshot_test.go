@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestParseSnapshotArgs(t *testing.T) {
+	opts, err := parseSnapshotArgs([]string{"coder", "-f", "out.tar.zst"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.agentID != "coder" || opts.outputPath != "out.tar.zst" || opts.workspace != "coder" || opts.helperImage != defaultHelperImage {
+		t.Errorf("opts = %+v", opts)
+	}
+
+	opts, err = parseSnapshotArgs([]string{"-f", "out.tar.zst", "coder", "-workspace", "shared"})
+	if err != nil || opts.workspace != "shared" {
+		t.Errorf("opts = %+v, err = %v; want workspace shared", opts, err)
+	}
+
+	for _, args := range [][]string{
+		{"-f", "out.tar.zst"},
+		{"coder"},
+		{"coder", "other", "-f", "out.tar.zst"},
+		{"coder", "-f"},
+	} {
+		if _, err := parseSnapshotArgs(args); err == nil {
+			t.Errorf("parseSnapshotArgs(%q): expected error", args)
+		}
+	}
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"text/tabwriter"
 
 	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/registry"
 	"github.com/mtzanidakis/praktor/internal/store"
 	"github.com/mtzanidakis/praktor/internal/vault"
 )
@@ -32,7 +35,7 @@ func runVault(args []string) error {
 
 	switch args[0] {
 	case "list":
-		return vaultList(db)
+		return vaultList(db, args[1:])
 	case "set":
 		return vaultSet(db, v, args[1:])
 	case "get":
@@ -45,6 +48,14 @@ func runVault(args []string) error {
 		return vaultUnassign(db, args[1:])
 	case "global":
 		return vaultGlobal(db, args[1:])
+	case "export":
+		return vaultExportCmd(db, args[1:])
+	case "import":
+		return vaultImportCmd(db, v, args[1:])
+	case "import-env":
+		return vaultImportEnvCmd(db, v, args[1:])
+	case "import-json":
+		return vaultImportJSONCmd(db, v, args[1:])
 	default:
 		printVaultUsage()
 		return fmt.Errorf("unknown vault command: %s", args[0])
@@ -55,7 +66,7 @@ func printVaultUsage() {
 	fmt.Fprintf(os.Stderr, `Usage: praktor vault <command>
 
 Commands:
-  list                              List all secrets (metadata only)
+  list [--unused]                   List all secrets (metadata only); --unused keeps those no agent assigns or references
   set <name> --value <str> [--description <text>]   Store a string secret
   set <name> --file <path> [--description <text>]  Store a file secret
   get <name>                        Retrieve and decrypt a secret
@@ -63,17 +74,51 @@ Commands:
   assign <name> --agent <id>        Assign a secret to an agent
   unassign <name> --agent <id>      Remove a secret from an agent
   global <name> --enable|--disable  Toggle global access
+  export -f <file>                  Export all secrets (still encrypted) and assignments
+  import -f <file> [--overwrite]    Import an export; existing secrets are kept unless --overwrite
+  import-env -f <.env> [--global] [--agent <id>] [--overwrite]
+                                    Create a string secret per KEY=VALUE line
+  import-json -f <file> [--global] [--agent <id>] [--overwrite]
+                                    Create secrets from {"NAME": "value"} or [{name, value, kind, description}]
 
 Environment:
   PRAKTOR_VAULT_PASSPHRASE          Required. Encryption passphrase.
 `)
 }
 
-func vaultList(db *store.Store) error {
+func vaultList(db *store.Store, args []string) error {
+	unusedOnly := false
+	for _, a := range args {
+		if a != "--unused" {
+			return fmt.Errorf("usage: praktor vault list [--unused]")
+		}
+		unusedOnly = true
+	}
+
 	secrets, err := db.ListSecrets()
 	if err != nil {
 		return err
 	}
+	if unusedOnly {
+		// References live in the config and stored extensions, not just
+		// in assignments, so a secret is only unused if none of them name it.
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+		usage, err := registry.CollectSecretUsage(db, cfg.Agents)
+		if err != nil {
+			return fmt.Errorf("collect secret usage: %w", err)
+		}
+		secrets = slices.DeleteFunc(secrets, func(s store.Secret) bool {
+			u := usage[s.ID]
+			return u == nil || !u.Unused()
+		})
+		if len(secrets) == 0 {
+			fmt.Println("No unused secrets.")
+			return nil
+		}
+	}
 	if len(secrets) == 0 {
 		fmt.Println("No secrets stored.")
 		return nil
@@ -251,3 +296,41 @@ func vaultGlobal(db *store.Store, args []string) error {
 	fmt.Printf("Secret %q global=%v\n", name, sec.Global)
 	return nil
 }
+
+// verifyVault checks the passphrase by decrypting one stored secret. A
+// vault without secrets passes.
+func verifyVault(db *store.Store, v *vault.Vault) error {
+	secrets, err := db.ListSecrets()
+	if err != nil {
+		return err
+	}
+	if len(secrets) == 0 {
+		return nil
+	}
+	sec, err := db.GetSecret(secrets[0].ID)
+	if err != nil {
+		return err
+	}
+	if sec == nil {
+		return nil
+	}
+	if _, err := v.Decrypt(sec.Value, sec.Nonce); err != nil {
+		return fmt.Errorf("secret %q: %w", sec.Name, err)
+	}
+	return nil
+}
+
+// checkVault runs verifyVault at gateway start. A wrong passphrase fails
+// the start when require is set and is otherwise only logged, leaving
+// agents to start without their secrets.
+func checkVault(db *store.Store, v *vault.Vault, require bool) error {
+	err := verifyVault(db, v)
+	if err == nil {
+		return nil
+	}
+	if require {
+		return fmt.Errorf("vault passphrase check failed (vault.require_verify is set): %w", err)
+	}
+	slog.Error("VAULT PASSPHRASE CHECK FAILED: stored secrets cannot be decrypted and agents will start without them; check PRAKTOR_VAULT_PASSPHRASE", "error", err)
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+
+	"github.com/mtzanidakis/praktor/internal/dotenv"
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/vault"
+)
+
+// bulkSecretNameRe matches the names accepted from .env and JSON imports:
+// environment variable style identifiers, plus dots and dashes.
+var bulkSecretNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// bulkSecret is one plaintext secret read from an import file.
+type bulkSecret struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	Kind        string `json:"kind,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type bulkImportOptions struct {
+	path      string
+	global    bool
+	agent     string
+	overwrite bool
+}
+
+type bulkImportResult struct {
+	created, updated, skipped int
+}
+
+func parseBulkImportArgs(args []string) (bulkImportOptions, error) {
+	var opts bulkImportOptions
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-f":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("missing value for -f")
+			}
+			i++
+			opts.path = args[i]
+		case "--agent":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("missing value for --agent")
+			}
+			i++
+			opts.agent = args[i]
+		case "--global":
+			opts.global = true
+		case "--overwrite":
+			opts.overwrite = true
+		default:
+			return opts, fmt.Errorf("unknown flag: %s", args[i])
+		}
+	}
+	if opts.path == "" {
+		return opts, fmt.Errorf("missing -f flag")
+	}
+	return opts, nil
+}
+
+func vaultImportEnvCmd(db *store.Store, v *vault.Vault, args []string) error {
+	return vaultBulkImportCmd(db, v, args, "import-env", parseDotenv)
+}
+
+func vaultImportJSONCmd(db *store.Store, v *vault.Vault, args []string) error {
+	return vaultBulkImportCmd(db, v, args, "import-json", parseSecretsJSON)
+}
+
+func vaultBulkImportCmd(db *store.Store, v *vault.Vault, args []string, cmd string, parse func(io.Reader) ([]bulkSecret, error)) error {
+	opts, err := parseBulkImportArgs(args)
+	if err != nil {
+		return fmt.Errorf("%w\nusage: praktor vault %s -f <file> [--global] [--agent <id>] [--overwrite]", err, cmd)
+	}
+
+	f, err := os.Open(opts.path)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", opts.path, err)
+	}
+	secrets, err := parse(f)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", opts.path, err)
+	}
+
+	res, err := importBulkSecrets(db, v, secrets, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %d secret(s), updated %d, skipped %d existing\n", res.created, res.updated, res.skipped)
+	if res.skipped > 0 && !opts.overwrite {
+		fmt.Fprintln(os.Stderr, "hint: add --overwrite to replace existing secrets")
+	}
+	return nil
+}
+
+// importBulkSecrets encrypts and saves secrets. An existing secret is kept
+// unless opts.overwrite is set, and then keeps its global flag unless
+// opts.global turns it on. Nothing is written if the target agent doesn't
+// exist.
+func importBulkSecrets(db *store.Store, v *vault.Vault, secrets []bulkSecret, opts bulkImportOptions) (bulkImportResult, error) {
+	var res bulkImportResult
+	if opts.agent != "" {
+		a, err := db.GetAgent(opts.agent)
+		if err != nil {
+			return res, err
+		}
+		if a == nil {
+			return res, fmt.Errorf("agent %q not found", opts.agent)
+		}
+	}
+
+	for _, s := range secrets {
+		existing, err := db.GetSecret(s.Name)
+		if err != nil {
+			return res, err
+		}
+		if existing != nil && !opts.overwrite {
+			res.skipped++
+			continue
+		}
+
+		ciphertext, nonce, err := v.Encrypt([]byte(s.Value))
+		if err != nil {
+			return res, fmt.Errorf("encrypt %q: %w", s.Name, err)
+		}
+		sec := &store.Secret{
+			ID:          s.Name,
+			Name:        s.Name,
+			Description: s.Description,
+			Kind:        s.Kind,
+			Filename:    s.Filename,
+			Value:       ciphertext,
+			Nonce:       nonce,
+			Global:      opts.global || existing != nil && existing.Global,
+		}
+		if err := db.SaveSecret(sec); err != nil {
+			return res, err
+		}
+		if opts.agent != "" {
+			if err := db.AddAgentSecret(opts.agent, s.Name); err != nil {
+				return res, err
+			}
+		}
+		if existing != nil {
+			res.updated++
+		} else {
+			res.created++
+		}
+	}
+	return res, nil
+}
+
+// parseDotenv reads a .env file (see dotenv.Parse) into string secrets, in
+// file order.
+func parseDotenv(r io.Reader) ([]bulkSecret, error) {
+	vars, err := dotenv.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]bulkSecret, 0, len(vars))
+	for _, v := range vars {
+		out = append(out, bulkSecret{Name: v.Key, Value: v.Value, Kind: "string"})
+	}
+	return out, nil
+}
+
+// parseSecretsJSON reads either a {"NAME": "value"} object of string secrets
+// or a [{"name", "value", "kind", "filename", "description"}] list, where
+// kind is "string" (the default) or "file".
+func parseSecretsJSON(r io.Reader) ([]bulkSecret, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []bulkSecret
+	switch trimmed := bytes.TrimSpace(data); {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var m map[string]string
+		if err := json.Unmarshal(trimmed, &m); err != nil {
+			return nil, fmt.Errorf("decode object: %w", err)
+		}
+		for _, name := range slices.Sorted(maps.Keys(m)) {
+			out = append(out, bulkSecret{Name: name, Value: m[name]})
+		}
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		if err := json.Unmarshal(trimmed, &out); err != nil {
+			return nil, fmt.Errorf("decode list: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("expected a JSON object or list")
+	}
+
+	seen := make(map[string]bool, len(out))
+	for i := range out {
+		s := &out[i]
+		if !bulkSecretNameRe.MatchString(s.Name) {
+			return nil, fmt.Errorf("secret %d: invalid name %q", i+1, s.Name)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("secret %q listed twice", s.Name)
+		}
+		seen[s.Name] = true
+		switch s.Kind {
+		case "":
+			s.Kind = "string"
+		case "string":
+		case "file":
+			if s.Filename == "" {
+				s.Filename = s.Name
+			}
+		default:
+			return nil, fmt.Errorf("secret %q: kind must be string or file, got %q", s.Name, s.Kind)
+		}
+		if s.Kind == "string" && s.Filename != "" {
+			return nil, fmt.Errorf("secret %q: filename is only valid for file secrets", s.Name)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/vault"
+)
+
+func TestParseDotenv(t *testing.T) {
+	input := `# database
+DB_HOST=localhost
+export DB_USER = admin
+DB_URL=postgres://u:p@h/db?sslmode=require&x=1
+DOUBLE="line one\nline \"two\"" # trailing comment
+SINGLE='no $expansion \n here'
+HASH=abc#def
+COMMENTED=value # comment
+EMPTY=
+QUOTED_EMPTY=""
+
+DB_HOST=override
+`
+	got, err := parseDotenv(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DB_HOST":      "override",
+		"DB_USER":      "admin",
+		"DB_URL":       "postgres://u:p@h/db?sslmode=require&x=1",
+		"DOUBLE":       "line one\nline \"two\"",
+		"SINGLE":       `no $expansion \n here`,
+		"HASH":         "abc#def",
+		"COMMENTED":    "value",
+		"EMPTY":        "",
+		"QUOTED_EMPTY": "",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d secrets, want %d: %+v", len(got), len(want), got)
+	}
+	for _, s := range got {
+		if v, ok := want[s.Name]; !ok || v != s.Value || s.Kind != "string" {
+			t.Errorf("%s = %q (kind %q), want %q", s.Name, s.Value, s.Kind, v)
+		}
+	}
+	// File order is kept; a repeated key stays at its first position.
+	if got[0].Name != "DB_HOST" || got[1].Name != "DB_USER" {
+		t.Errorf("order = %s, %s", got[0].Name, got[1].Name)
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	for _, input := range []string{
+		"NOVALUE",
+		"1BAD=x",
+		"BAD KEY=x",
+		`OPEN="unterminated`,
+		`OPEN='unterminated`,
+		`JUNK="value" extra`,
+	} {
+		if _, err := parseDotenv(strings.NewReader(input)); err == nil {
+			t.Errorf("parseDotenv(%q): expected error", input)
+		} else if !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("parseDotenv(%q): error %q lacks the line number", input, err)
+		}
+	}
+}
+
+func TestParseSecretsJSON(t *testing.T) {
+	got, err := parseSecretsJSON(strings.NewReader(`{"B_KEY": "b", "A_KEY": "a=1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != (bulkSecret{Name: "A_KEY", Value: "a=1", Kind: "string"}) || got[1].Name != "B_KEY" {
+		t.Errorf("object form = %+v", got)
+	}
+
+	got, err = parseSecretsJSON(strings.NewReader(`[
+		{"name": "token", "value": "t", "description": "API token"},
+		{"name": "gcp-key", "value": "{}", "kind": "file"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Kind != "string" || got[0].Description != "API token" {
+		t.Errorf("list entry = %+v", got[0])
+	}
+	if got[1].Kind != "file" || got[1].Filename != "gcp-key" {
+		t.Errorf("file entry = %+v", got[1])
+	}
+
+	for _, bad := range []string{
+		`"just a string"`,
+		`{"A": 1}`,
+		`[{"name": "", "value": "x"}]`,
+		`[{"name": "a", "value": "x", "kind": "binary"}]`,
+		`[{"name": "a", "value": "x"}, {"name": "a", "value": "y"}]`,
+		`[{"name": "a", "value": "x", "filename": "a.txt"}]`,
+	} {
+		if _, err := parseSecretsJSON(strings.NewReader(bad)); err == nil {
+			t.Errorf("parseSecretsJSON(%s): expected error", bad)
+		}
+	}
+}
+
+func TestImportBulkSecrets(t *testing.T) {
+	db := newVaultTestStore(t, "coder")
+	v := vault.New("passphrase")
+	saveTestSecret(t, db, v, "EXISTING", "old")
+
+	secrets := []bulkSecret{
+		{Name: "EXISTING", Value: "new", Kind: "string"},
+		{Name: "FRESH", Value: "fresh", Kind: "string"},
+	}
+	res, err := importBulkSecrets(db, v, secrets, bulkImportOptions{agent: "coder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != (bulkImportResult{created: 1, skipped: 1}) {
+		t.Errorf("result = %+v, want 1 created, 1 skipped", res)
+	}
+	if ids, _ := db.GetSecretAgentIDs("FRESH"); !slices.Equal(ids, []string{"coder"}) {
+		t.Errorf("FRESH agents = %v, want [coder]", ids)
+	}
+	if got := decryptTestSecret(t, db, v, "EXISTING"); got != "old" {
+		t.Errorf("EXISTING = %q, want it kept without --overwrite", got)
+	}
+
+	res, err = importBulkSecrets(db, v, secrets, bulkImportOptions{overwrite: true, global: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != (bulkImportResult{updated: 2}) {
+		t.Errorf("overwrite result = %+v, want 2 updated", res)
+	}
+	if got := decryptTestSecret(t, db, v, "EXISTING"); got != "new" {
+		t.Errorf("EXISTING = %q after --overwrite", got)
+	}
+	if sec, _ := db.GetSecret("FRESH"); !sec.Global {
+		t.Error("--global not applied")
+	}
+
+	if _, err := importBulkSecrets(db, v, secrets, bulkImportOptions{agent: "nope"}); err == nil {
+		t.Error("expected error for unknown agent")
+	}
+}
+
+func decryptTestSecret(t *testing.T, db *store.Store, v *vault.Vault, name string) string {
+	t.Helper()
+	sec, err := db.GetSecret(name)
+	if err != nil || sec == nil {
+		t.Fatalf("get secret %s: %v", name, err)
+	}
+	plain, err := v.Decrypt(sec.Value, sec.Nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(plain)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/vault"
+)
+
+func TestCheckVault(t *testing.T) {
+	db := newVaultTestStore(t)
+	right := vault.New("right passphrase")
+	wrong := vault.New("wrong passphrase")
+
+	// Nothing to decrypt yet, so any passphrase passes.
+	if err := checkVault(db, wrong, true); err != nil {
+		t.Fatalf("empty vault: %v", err)
+	}
+
+	saveTestSecret(t, db, right, "api-key", "s3cret-value")
+	if err := checkVault(db, right, true); err != nil {
+		t.Errorf("right passphrase: %v", err)
+	}
+	if err := checkVault(db, wrong, true); err == nil {
+		t.Error("wrong passphrase with require_verify: expected an error")
+	}
+	if err := checkVault(db, wrong, false); err != nil {
+		t.Errorf("wrong passphrase without require_verify: %v, want it only logged", err)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/registry"
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/vault"
+)
+
+const (
+	vaultExportFormat  = "praktor-vault"
+	vaultExportVersion = 1
+)
+
+// vaultExport is the on-disk format of `praktor vault export`. Secret values
+// stay encrypted with the exporting host's passphrase; they are never
+// decrypted on the way out.
+type vaultExport struct {
+	Format  string                `json:"format"`
+	Version int                   `json:"version"`
+	Secrets []vaultExportedSecret `json:"secrets"`
+}
+
+type vaultExportedSecret struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Kind        string   `json:"kind"`
+	Filename    string   `json:"filename,omitempty"`
+	Value       []byte   `json:"value"`
+	Nonce       []byte   `json:"nonce"`
+	Global      bool     `json:"global,omitempty"`
+	Agents      []string `json:"agents,omitempty"`
+}
+
+func parseVaultFileArgs(args []string, allowOverwrite bool) (path string, overwrite bool, err error) {
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-f":
+			if i+1 >= len(args) {
+				return "", false, fmt.Errorf("missing value for -f")
+			}
+			i++
+			path = args[i]
+		case "--overwrite":
+			if !allowOverwrite {
+				return "", false, fmt.Errorf("unknown flag: %s", args[i])
+			}
+			overwrite = true
+		default:
+			return "", false, fmt.Errorf("unknown flag: %s", args[i])
+		}
+	}
+	if path == "" {
+		return "", false, fmt.Errorf("missing -f flag")
+	}
+	return path, overwrite, nil
+}
+
+func vaultExportCmd(db *store.Store, args []string) error {
+	path, _, err := parseVaultFileArgs(args, false)
+	if err != nil {
+		return fmt.Errorf("%w\nusage: praktor vault export -f <file>", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("create export file: %w", err)
+	}
+	n, err := exportVault(db, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	fmt.Printf("Exported %d secret(s) to %s\n", n, path)
+	return nil
+}
+
+func vaultImportCmd(db *store.Store, v *vault.Vault, args []string) error {
+	path, overwrite, err := parseVaultFileArgs(args, true)
+	if err != nil {
+		return fmt.Errorf("%w\nusage: praktor vault import -f <file> [--overwrite]", err)
+	}
+
+	// On a fresh host the gateway hasn't written the configured agents to
+	// the store yet, and assignments can only name agents it has.
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if err := registry.New(db, cfg.Agents, cfg.Defaults, config.AgentsBasePath).Sync(); err != nil {
+		return fmt.Errorf("sync agent registry: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open export file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	res, err := importVault(db, v, f, overwrite)
+	if err != nil {
+		return err
+	}
+	for _, a := range res.unknownAgents {
+		fmt.Fprintf(os.Stderr, "warning: agent %q does not exist here, skipped its assignments\n", a)
+	}
+	fmt.Printf("Imported %d secret(s), skipped %d existing\n", res.imported, res.skipped)
+	return nil
+}
+
+// exportVault writes every secret, still encrypted, together with its agent
+// assignments. It returns the number of secrets written.
+func exportVault(db *store.Store, w io.Writer) (int, error) {
+	list, err := db.ListSecrets()
+	if err != nil {
+		return 0, err
+	}
+
+	out := vaultExport{Format: vaultExportFormat, Version: vaultExportVersion, Secrets: []vaultExportedSecret{}}
+	for _, meta := range list {
+		// ListSecrets omits the ciphertext, so load each secret in full.
+		sec, err := db.GetSecret(meta.ID)
+		if err != nil {
+			return 0, err
+		}
+		if sec == nil {
+			continue
+		}
+		agents, err := db.GetSecretAgentIDs(sec.ID)
+		if err != nil {
+			return 0, err
+		}
+		out.Secrets = append(out.Secrets, vaultExportedSecret{
+			ID:          sec.ID,
+			Name:        sec.Name,
+			Description: sec.Description,
+			Kind:        sec.Kind,
+			Filename:    sec.Filename,
+			Value:       sec.Value,
+			Nonce:       sec.Nonce,
+			Global:      sec.Global,
+			Agents:      agents,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return 0, fmt.Errorf("write export: %w", err)
+	}
+	return len(out.Secrets), nil
+}
+
+type vaultImportResult struct {
+	imported      int
+	skipped       int
+	unknownAgents []string
+}
+
+// importVault loads an export produced by exportVault. Every secret is
+// test-decrypted first, so a passphrase mismatch fails before anything is
+// written, and all secrets are written in one transaction. Existing secrets
+// are kept unless overwrite is set; overwritten secrets keep their
+// assignments and gain the exported ones. Assignments to agents this host
+// doesn't know are dropped and reported.
+func importVault(db *store.Store, v *vault.Vault, r io.Reader, overwrite bool) (vaultImportResult, error) {
+	var res vaultImportResult
+
+	var in vaultExport
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return res, fmt.Errorf("read export: %w", err)
+	}
+	if in.Format != vaultExportFormat {
+		return res, fmt.Errorf("not a praktor vault export (format %q)", in.Format)
+	}
+	if in.Version != vaultExportVersion {
+		return res, fmt.Errorf("unsupported vault export version %d (want %d)", in.Version, vaultExportVersion)
+	}
+
+	for _, s := range in.Secrets {
+		if s.ID == "" {
+			return res, fmt.Errorf("export contains a secret without an id")
+		}
+		if _, err := v.Decrypt(s.Value, s.Nonce); err != nil {
+			return res, fmt.Errorf("cannot decrypt secret %q: PRAKTOR_VAULT_PASSPHRASE must match the one used on the exporting host", s.Name)
+		}
+	}
+
+	known := map[string]bool{}
+	reported := map[string]bool{}
+	secrets := make([]store.ImportedSecret, 0, len(in.Secrets))
+	for _, s := range in.Secrets {
+		var agents []string
+		for _, id := range s.Agents {
+			ok, seen := known[id]
+			if !seen {
+				a, err := db.GetAgent(id)
+				if err != nil {
+					return res, err
+				}
+				ok = a != nil
+				known[id] = ok
+			}
+			if ok {
+				agents = append(agents, id)
+			} else if !reported[id] {
+				reported[id] = true
+				res.unknownAgents = append(res.unknownAgents, id)
+			}
+		}
+		secrets = append(secrets, store.ImportedSecret{
+			Secret: store.Secret{
+				ID:          s.ID,
+				Name:        s.Name,
+				Description: s.Description,
+				Kind:        s.Kind,
+				Filename:    s.Filename,
+				Value:       s.Value,
+				Nonce:       s.Nonce,
+				Global:      s.Global,
+			},
+			Agents: agents,
+		})
+	}
+
+	imported, skipped, err := db.ImportSecrets(secrets, overwrite)
+	if err != nil {
+		return vaultImportResult{}, err
+	}
+	res.imported, res.skipped = imported, skipped
+	return res, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/vault"
+)
+
+func newVaultTestStore(t *testing.T, agentIDs ...string) *store.Store {
+	t.Helper()
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	for _, id := range agentIDs {
+		if err := db.SaveAgent(&store.Agent{ID: id, Name: id, Workspace: id}); err != nil {
+			t.Fatalf("save agent: %v", err)
+		}
+	}
+	return db
+}
+
+func saveTestSecret(t *testing.T, db *store.Store, v *vault.Vault, name, value string, agents ...string) {
+	t.Helper()
+	ct, nonce, err := v.Encrypt([]byte(value))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if err := db.SaveSecret(&store.Secret{ID: name, Name: name, Kind: "string", Value: ct, Nonce: nonce}); err != nil {
+		t.Fatalf("save secret: %v", err)
+	}
+	if err := db.SetSecretAgents(name, agents); err != nil {
+		t.Fatalf("set secret agents: %v", err)
+	}
+}
+
+func TestVaultExportImportRoundTrip(t *testing.T) {
+	v := vault.New("passphrase")
+	src := newVaultTestStore(t, "coder", "writer")
+	saveTestSecret(t, src, v, "github-token", "ghp_123", "coder", "writer")
+	saveTestSecret(t, src, v, "ssh-key", "-----BEGIN KEY-----")
+
+	var buf bytes.Buffer
+	n, err := exportVault(src, &buf)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("exported %d secrets, want 2", n)
+	}
+	if strings.Contains(buf.String(), "ghp_123") {
+		t.Fatal("export contains a plaintext secret value")
+	}
+
+	// The target host only knows one of the agents.
+	dst := newVaultTestStore(t, "coder")
+	res, err := importVault(dst, vault.New("passphrase"), bytes.NewReader(buf.Bytes()), false)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if res.imported != 2 || res.skipped != 0 {
+		t.Errorf("imported=%d skipped=%d, want 2/0", res.imported, res.skipped)
+	}
+	if !slices.Equal(res.unknownAgents, []string{"writer"}) {
+		t.Errorf("unknown agents = %v, want [writer]", res.unknownAgents)
+	}
+
+	orig, _ := src.GetSecret("github-token")
+	got, err := dst.GetSecret("github-token")
+	if err != nil || got == nil {
+		t.Fatalf("get imported secret: %v %v", got, err)
+	}
+	if !bytes.Equal(got.Value, orig.Value) || !bytes.Equal(got.Nonce, orig.Nonce) {
+		t.Error("imported ciphertext differs from the original")
+	}
+	plain, err := v.Decrypt(got.Value, got.Nonce)
+	if err != nil || string(plain) != "ghp_123" {
+		t.Errorf("decrypt imported secret = %q, %v", plain, err)
+	}
+	agents, _ := dst.GetSecretAgentIDs("github-token")
+	if !slices.Equal(agents, []string{"coder"}) {
+		t.Errorf("assignments = %v, want [coder]", agents)
+	}
+}
+
+func TestVaultImportOverwrite(t *testing.T) {
+	v := vault.New("passphrase")
+	src := newVaultTestStore(t)
+	saveTestSecret(t, src, v, "token", "new")
+	var buf bytes.Buffer
+	if _, err := exportVault(src, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dst := newVaultTestStore(t)
+	saveTestSecret(t, dst, v, "token", "old")
+
+	res, err := importVault(dst, v, bytes.NewReader(buf.Bytes()), false)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if res.imported != 0 || res.skipped != 1 {
+		t.Errorf("imported=%d skipped=%d, want 0/1", res.imported, res.skipped)
+	}
+	sec, _ := dst.GetSecret("token")
+	if plain, _ := v.Decrypt(sec.Value, sec.Nonce); string(plain) != "old" {
+		t.Errorf("existing secret replaced without --overwrite: %q", plain)
+	}
+
+	if _, err := importVault(dst, v, bytes.NewReader(buf.Bytes()), true); err != nil {
+		t.Fatalf("import with overwrite: %v", err)
+	}
+	sec, _ = dst.GetSecret("token")
+	if plain, _ := v.Decrypt(sec.Value, sec.Nonce); string(plain) != "new" {
+		t.Errorf("secret not replaced with --overwrite: %q", plain)
+	}
+}
+
+func TestVaultImportWrongPassphrase(t *testing.T) {
+	src := newVaultTestStore(t)
+	saveTestSecret(t, src, vault.New("passphrase"), "token", "value")
+	var buf bytes.Buffer
+	if _, err := exportVault(src, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dst := newVaultTestStore(t)
+	_, err := importVault(dst, vault.New("other"), bytes.NewReader(buf.Bytes()), false)
+	if err == nil || !strings.Contains(err.Error(), "PRAKTOR_VAULT_PASSPHRASE") {
+		t.Fatalf("expected passphrase error, got %v", err)
+	}
+	if list, _ := dst.ListSecrets(); len(list) != 0 {
+		t.Errorf("failed import wrote %d secrets", len(list))
+	}
+}
+
+func TestVaultImportRejectsUnknownVersion(t *testing.T) {
+	dst := newVaultTestStore(t)
+	_, err := importVault(dst, vault.New("p"), strings.NewReader(`{"format":"praktor-vault","version":99,"secrets":[]}`), false)
+	if err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Fatalf("expected version error, got %v", err)
+	}
+}
+
+func TestVaultImportOverwriteMergesAssignments(t *testing.T) {
+	v := vault.New("passphrase")
+	src := newVaultTestStore(t, "coder")
+	saveTestSecret(t, src, v, "token", "new", "coder")
+	var buf bytes.Buffer
+	if _, err := exportVault(src, &buf); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	dst := newVaultTestStore(t, "coder", "writer")
+	saveTestSecret(t, dst, v, "token", "old", "writer")
+	if _, err := importVault(dst, v, bytes.NewReader(buf.Bytes()), true); err != nil {
+		t.Fatalf("import with overwrite: %v", err)
+	}
+	agents, _ := dst.GetSecretAgentIDs("token")
+	slices.Sort(agents)
+	if !slices.Equal(agents, []string{"coder", "writer"}) {
+		t.Errorf("assignments = %v, want [coder writer]", agents)
+	}
+}
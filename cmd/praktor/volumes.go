@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/moby/moby/client"
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+// agentVolumePrefixes are the per-workspace volumes an agent container
+// mounts, each followed by the sanitized workspace name.
+var agentVolumePrefixes = []string{"praktor-wk-", "praktor-home-", "praktor-nix-"}
+
+// reservedVolumes belong to the gateway and are never pruned.
+var reservedVolumes = map[string]bool{
+	"praktor-data":      true,
+	"praktor-global":    true,
+	"praktor-artifacts": true,
+}
+
+// pruneCandidates returns the agent volumes whose workspace no agent uses,
+// in sorted order. Only praktor-wk-/home-/nix- volumes are considered, so
+// the gateway's volumes, swarm volumes and anything else are left alone.
+func pruneCandidates(volumes, workspaces []string) []string {
+	used := make(map[string]bool, len(workspaces))
+	for _, ws := range workspaces {
+		used[container.SanitizeVolumeName(ws)] = true
+	}
+
+	var candidates []string
+	for _, vol := range volumes {
+		if reservedVolumes[vol] {
+			continue
+		}
+		for _, prefix := range agentVolumePrefixes {
+			if ws, ok := strings.CutPrefix(vol, prefix); ok && ws != "" {
+				if !used[ws] {
+					candidates = append(candidates, vol)
+				}
+				break
+			}
+		}
+	}
+	slices.Sort(candidates)
+	return candidates
+}
+
+func runVolumes(args []string) error {
+	if len(args) == 0 || args[0] != "prune" {
+		printVolumesUsage()
+		if len(args) == 0 {
+			return nil
+		}
+		return fmt.Errorf("unknown volumes command: %s", args[0])
+	}
+
+	dryRun, force, allowEmpty := false, false, false
+	for _, arg := range args[1:] {
+		switch arg {
+		case "-dry-run":
+			dryRun = true
+		case "-force":
+			force = true
+		case "-allow-empty":
+			allowEmpty = true
+		default:
+			printVolumesUsage()
+			return fmt.Errorf("unexpected argument: %s", arg)
+		}
+	}
+
+	workspaces, err := agentWorkspaces(config.StorePath, allowEmpty)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	docker, err := client.New(client.FromEnv)
+	if err != nil {
+		return fmt.Errorf("docker client: %w", err)
+	}
+	defer func() { _ = docker.Close() }()
+
+	volumes, err := listPraktorVolumes(ctx, docker)
+	if err != nil {
+		return fmt.Errorf("list volumes: %w", err)
+	}
+
+	candidates := pruneCandidates(volumes, workspaces)
+	if len(candidates) == 0 {
+		fmt.Println("No unreferenced agent volumes.")
+		return nil
+	}
+	for _, vol := range candidates {
+		fmt.Println(vol)
+	}
+	if dryRun {
+		fmt.Printf("%d volumes would be removed.\n", len(candidates))
+		return nil
+	}
+	if !force && !confirm(os.Stdin, fmt.Sprintf("Remove %d volumes? [y/N] ", len(candidates))) {
+		fmt.Println("Aborted.")
+		return nil
+	}
+
+	removed := 0
+	for _, vol := range candidates {
+		if _, err := docker.VolumeRemove(ctx, vol, client.VolumeRemoveOptions{}); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to remove %s: %v\n", vol, err)
+			continue
+		}
+		removed++
+	}
+	fmt.Printf("Removed %d of %d volumes.\n", removed, len(candidates))
+	if removed < len(candidates) {
+		return fmt.Errorf("%d volumes could not be removed", len(candidates)-removed)
+	}
+	return nil
+}
+
+// agentWorkspaces returns the workspaces of the agents in the store at
+// path. A missing store or one without agents would make every agent
+// volume a prune candidate, most likely because prune runs from the wrong
+// directory, so both are refused unless allowEmpty is set. The store is
+// never created.
+func agentWorkspaces(path string, allowEmpty bool) ([]string, error) {
+	if _, err := os.Stat(path); err != nil {
+		if allowEmpty && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("store %s: %w (run from the gateway's working directory, or add -allow-empty to prune every agent volume)", path, err)
+	}
+	db, err := store.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("open store: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	agents, err := db.ListAgents()
+	if err != nil {
+		return nil, fmt.Errorf("list agents: %w", err)
+	}
+	if len(agents) == 0 && !allowEmpty {
+		return nil, fmt.Errorf("store %s lists no agents, add -allow-empty to prune every agent volume", path)
+	}
+	workspaces := make([]string, 0, len(agents))
+	for _, a := range agents {
+		workspaces = append(workspaces, a.Workspace)
+	}
+	return workspaces, nil
+}
+
+// confirm asks prompt on stdout and reports whether the answer read from r
+// is yes.
+func confirm(r io.Reader, prompt string) bool {
+	fmt.Print(prompt)
+	line, _ := bufio.NewReader(r).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+func printVolumesUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: praktor volumes prune [-dry-run] [-force] [-allow-empty]\n\nRemoves praktor-wk-, praktor-home- and praktor-nix- volumes whose workspace\nno agent uses. Asks for confirmation unless -force is given; -dry-run only\nlists them. Refuses to run when data/praktor.db is missing or lists no\nagents unless -allow-empty is given.\n")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+func TestPruneCandidates(t *testing.T) {
+	volumes := []string{
+		"praktor-data",
+		"praktor-global",
+		"praktor-artifacts",
+		"praktor-wk-coder",
+		"praktor-home-coder",
+		"praktor-nix-coder",
+		"praktor-wk-old",
+		"praktor-home-old",
+		"praktor-nix-gone",
+		"praktor-wk-my-notes", // workspace "my.notes", sanitized
+		"praktor-swarm-1f3c9a2e-researcher",
+		"praktor-wk-",
+	}
+	got := pruneCandidates(volumes, []string{"coder", "my.notes"})
+	want := []string{"praktor-home-old", "praktor-nix-gone", "praktor-wk-old"}
+	if !slices.Equal(got, want) {
+		t.Errorf("candidates = %v, want %v", got, want)
+	}
+
+	// With no agents every agent volume goes, but never the gateway's.
+	got = pruneCandidates(volumes, nil)
+	for _, vol := range got {
+		if reservedVolumes[vol] || strings.HasPrefix(vol, "praktor-swarm-") {
+			t.Errorf("candidate %s must never be pruned", vol)
+		}
+	}
+	if len(got) != 7 {
+		t.Errorf("got %d candidates with no agents, want 7: %v", len(got), got)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	for answer, want := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false} {
+		if got := confirm(strings.NewReader(answer), ""); got != want {
+			t.Errorf("confirm(%q) = %v, want %v", answer, got, want)
+		}
+	}
+}
+
+func TestAgentWorkspacesRefusesEmptyStore(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.db")
+	if _, err := agentWorkspaces(missing, false); err == nil {
+		t.Error("missing store: expected an error")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Error("agentWorkspaces created the missing store")
+	}
+	if ws, err := agentWorkspaces(missing, true); err != nil || len(ws) != 0 {
+		t.Errorf("missing store with -allow-empty = %v, %v; want no workspaces", ws, err)
+	}
+
+	path := filepath.Join(dir, "praktor.db")
+	db, err := store.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := agentWorkspaces(path, false); err == nil {
+		t.Error("store without agents: expected an error")
+	}
+	if err := db.SaveAgent(&store.Agent{ID: "coder", Name: "coder", Workspace: "code"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+	if ws, err := agentWorkspaces(path, false); err != nil || !slices.Equal(ws, []string{"code"}) {
+		t.Errorf("agentWorkspaces = %v, %v; want [code]", ws, err)
+	}
+}
@@ -21,12 +21,25 @@ type ipcResponse struct {
 	Tasks []task `json:"tasks,omitempty"`
 }
 
+// task is an entry of the list_tasks response. The run fields are absent
+// when talking to an older gateway.
 type task struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Schedule string `json:"schedule"`
-	Prompt   string `json:"prompt"`
-	Status   string `json:"status"`
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Schedule   string     `json:"schedule"`
+	Prompt     string     `json:"prompt"`
+	Status     string     `json:"status"`
+	NextRun    *time.Time `json:"next_run,omitempty"`
+	LastRun    *time.Time `json:"last_run,omitempty"`
+	LastStatus string     `json:"last_status,omitempty"`
+}
+
+// formatRun renders a run time for `ptask list`.
+func formatRun(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format("2006-01-02 15:04 UTC")
 }
 
 func sendIPC(natsURL, agentID, reqType string, payload map[string]any) (*ipcResponse, error) {
@@ -37,6 +50,9 @@ func sendIPC(natsURL, agentID, reqType string, payload map[string]any) (*ipcResp
 	defer conn.Close()
 
 	topic := fmt.Sprintf("host.ipc.%s", agentID)
+	if prefix := os.Getenv("NATS_SUBJECT_PREFIX"); prefix != "" {
+		topic = prefix + "." + topic
+	}
 	data, err := json.Marshal(ipcRequest{Type: reqType, Payload: payload})
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
@@ -71,6 +87,8 @@ func usage() {
 	fmt.Fprintln(os.Stderr, "  ptask list")
 	fmt.Fprintln(os.Stderr, `  ptask update --id "..." [--name "..."] [--schedule "..."] [--prompt "..."]`)
 	fmt.Fprintln(os.Stderr, `  ptask delete --id "..."`)
+	fmt.Fprintln(os.Stderr, `  ptask pause --id "..."`)
+	fmt.Fprintln(os.Stderr, `  ptask resume --id "..."`)
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Schedule examples:")
 	fmt.Fprintln(os.Stderr, `  ptask create --name "Quick reminder" --schedule "+30s" --prompt "..."`)
@@ -137,7 +155,10 @@ func main() {
 			fmt.Println("No tasks found.")
 		} else {
 			for _, t := range resp.Tasks {
-				fmt.Printf("  %s  %s  %s  [%s]\n", t.ID, t.Status, t.Name, t.Schedule)
+				fmt.Printf("  %s  %s  %s  [%s]  next: %s\n", t.ID, t.Status, t.Name, t.Schedule, formatRun(t.NextRun))
+				if t.LastRun != nil {
+					fmt.Printf("      last: %s (%s)\n", formatRun(t.LastRun), t.LastStatus)
+				}
 			}
 		}
 
@@ -181,6 +202,26 @@ func main() {
 		}
 		fmt.Println("Task deleted.")
 
+	case "pause", "resume":
+		args := parseArgs(rest)
+		if args["id"] == "" {
+			fatal("--id is required")
+		}
+		resp, err := sendIPC(natsURL, agentID, command+"_task", map[string]any{
+			"id": args["id"],
+		})
+		if err != nil {
+			fatal("%v", err)
+		}
+		if resp.Error != "" {
+			fatal("%s", resp.Error)
+		}
+		if command == "pause" {
+			fmt.Printf("Task paused: %s\n", resp.ID)
+		} else {
+			fmt.Printf("Task resumed: %s\n", resp.ID)
+		}
+
 	default:
 		fatal("unknown command: %s", command)
 	}
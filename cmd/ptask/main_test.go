@@ -160,6 +160,27 @@ func TestSendIPCListTasks(t *testing.T) {
 	}
 }
 
+func TestTaskRunFields(t *testing.T) {
+	var resp ipcResponse
+	data := `{"ok":true,"tasks":[
+		{"id":"t1","name":"a","schedule":"@hourly","prompt":"p","status":"active",
+		 "next_run":"2026-03-01T10:00:00Z","last_run":"2026-03-01T09:00:00Z","last_status":"success"},
+		{"id":"t2","name":"b","schedule":"@hourly","prompt":"p","status":"active"}]}`
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got := formatRun(resp.Tasks[0].NextRun); got != "2026-03-01 10:00 UTC" {
+		t.Errorf("next run = %q", got)
+	}
+	if resp.Tasks[0].LastRun == nil || resp.Tasks[0].LastStatus != "success" {
+		t.Errorf("last run not decoded: %+v", resp.Tasks[0])
+	}
+	// Responses from gateways without the run fields still decode.
+	if got := formatRun(resp.Tasks[1].NextRun); got != "-" {
+		t.Errorf("missing next run = %q, want -", got)
+	}
+}
+
 func TestSendIPCDeleteTask(t *testing.T) {
 	bus := startTestNATS(t)
 	url := bus.ClientURL()
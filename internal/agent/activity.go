@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// activityPruneInterval is how often expired lifecycle events are removed.
+const activityPruneInterval = time.Hour
+
+// StartActivityPruner periodically removes the agent lifecycle events
+// recorded for the activity feed once they are older than
+// defaults.agent_event_retention.
+func (o *Orchestrator) StartActivityPruner(ctx context.Context) {
+	ticker := time.NewTicker(activityPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		o.pruneAgentEvents(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o *Orchestrator) pruneAgentEvents(now time.Time) {
+	retention := o.defaults().AgentEventRetention
+	if retention <= 0 {
+		return
+	}
+	n, err := o.store.DeleteAgentEventsBefore(now.Add(-retention))
+	if err != nil {
+		slog.Error("agent event prune failed", "error", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("expired agent events removed", "count", n)
+	}
+}
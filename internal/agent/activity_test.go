@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+func TestPruneAgentEvents(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	o.cfg.AgentEventRetention = time.Hour
+	for _, typ := range []string{"agent_started", "agent_stopped"} {
+		if err := o.store.AddAgentEvent(&store.AgentEvent{AgentID: "alpha", Type: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := o.store.DB().Exec(`UPDATE agent_events SET created_at = datetime('now', '-2 hours') WHERE type = 'agent_started'`); err != nil {
+		t.Fatal(err)
+	}
+
+	o.pruneAgentEvents(time.Now())
+	items, err := o.store.ListActivity(store.ActivityFilter{Types: []string{store.ActivityLifecycle}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Status != "agent_stopped" {
+		t.Errorf("events after pruning = %+v, want only agent_stopped", items)
+	}
+
+	// retention 0 keeps everything.
+	o.cfg.AgentEventRetention = 0
+	o.pruneAgentEvents(time.Now().Add(24 * time.Hour))
+	if items, _ := o.store.ListActivity(store.ActivityFilter{Types: []string{store.ActivityLifecycle}}); len(items) != 1 {
+		t.Errorf("retention 0 pruned events: %+v", items)
+	}
+}
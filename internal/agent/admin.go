@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+)
+
+// AdminAgent is one entry of the admin_list_agents response.
+type AdminAgent struct {
+	ID           string `json:"id"`
+	Description  string `json:"description,omitempty"`
+	Model        string `json:"model"`
+	Status       string `json:"status"`
+	MessageCount int    `json:"message_count"`
+}
+
+// AdminCommand is an admin socket request: an IPC command plus the
+// gateway's admin token.
+type AdminCommand struct {
+	IPCCommand
+	Token string `json:"token"`
+}
+
+// adminTimeout bounds one admin socket connection, including the command.
+const adminTimeout = 3 * time.Minute
+
+// SetAdminToken sets the token admin commands must carry. Without a token
+// every admin command is refused.
+func (o *Orchestrator) SetAdminToken(token string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.adminToken = token
+}
+
+func (o *Orchestrator) validAdminToken(token string) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(o.adminToken)) == 1
+}
+
+// ServeAdmin serves the `praktor agents` and `praktor agent` CLI on a unix
+// socket at path until ctx is cancelled. Each connection carries one JSON
+// AdminCommand and gets one JSON response. The socket and the token file
+// live in the gateway's data directory, which agent containers can't see;
+// admin commands are not offered on NATS, which agents share.
+func (o *Orchestrator) ServeAdmin(ctx context.Context, path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove stale admin socket: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("listen on admin socket: %w", err)
+	}
+	defer func() { _ = os.Remove(path) }()
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = ln.Close()
+		return fmt.Errorf("chmod admin socket: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept admin connection: %w", err)
+		}
+		go o.serveAdminConn(ctx, conn)
+	}
+}
+
+func (o *Orchestrator) serveAdminConn(ctx context.Context, conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(adminTimeout))
+
+	var resp map[string]any
+	var cmd AdminCommand
+	if err := json.NewDecoder(conn).Decode(&cmd); err != nil {
+		resp = map[string]any{"error": "invalid command"}
+	} else {
+		resp = o.handleAdmin(ctx, cmd)
+	}
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		slog.Warn("failed to answer admin command", "type", cmd.Type, "error", err)
+	}
+}
+
+// handleAdmin runs one operator command and returns its response. These
+// are kept off host.ipc.* so an agent's own IPC namespace never gains
+// admin commands.
+func (o *Orchestrator) handleAdmin(ctx context.Context, cmd AdminCommand) map[string]any {
+	if !o.validAdminToken(cmd.Token) {
+		slog.Warn("admin command refused: bad token", "type", cmd.Type)
+		return map[string]any{"error": "unauthorized"}
+	}
+
+	var req struct {
+		AgentID string `json:"agent_id"`
+		Tail    int    `json:"tail"`
+	}
+	if len(cmd.Payload) > 0 {
+		if err := json.Unmarshal(cmd.Payload, &req); err != nil {
+			return map[string]any{"error": "invalid payload"}
+		}
+	}
+
+	slog.Info("admin command received", "type", cmd.Type, "agent", req.AgentID)
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	if cmd.Type != "admin_list_agents" {
+		if req.AgentID == "" {
+			return map[string]any{"error": "agent_id is required"}
+		}
+		if ag, err := o.registry.Get(req.AgentID); err != nil || ag == nil {
+			return map[string]any{"error": "agent not found: " + req.AgentID}
+		}
+	}
+
+	switch cmd.Type {
+	case "admin_list_agents":
+		return o.adminListAgents(ctx)
+	case "admin_agent_logs":
+		logs, err := o.containers.Logs(ctx, req.AgentID, req.Tail)
+		if err != nil {
+			return map[string]any{"error": err.Error()}
+		}
+		return map[string]any{"ok": true, "content": logs}
+	case "admin_stop_agent":
+		if err := o.StopAgent(ctx, req.AgentID); err != nil {
+			return map[string]any{"error": err.Error()}
+		}
+		return map[string]any{"ok": true}
+	case "admin_restart_agent":
+		if o.containers.GetRunning(req.AgentID) != nil {
+			if err := o.StopAgent(ctx, req.AgentID); err != nil {
+				return map[string]any{"error": err.Error()}
+			}
+		}
+		if err := o.EnsureAgent(ctx, req.AgentID); err != nil {
+			return map[string]any{"error": err.Error()}
+		}
+		return map[string]any{"ok": true}
+	default:
+		return map[string]any{"error": "unknown command: " + cmd.Type}
+	}
+}
+
+func (o *Orchestrator) adminListAgents(ctx context.Context) map[string]any {
+	agents, err := o.registry.List()
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+
+	running, _ := o.containers.ListRunning(ctx)
+	runningSet := make(map[string]bool, len(running))
+	for _, c := range running {
+		runningSet[c.AgentID] = true
+	}
+	msgStats, _ := o.store.GetAgentMessageStats()
+
+	out := make([]AdminAgent, 0, len(agents))
+	for _, a := range agents {
+		status := "stopped"
+		if runningSet[a.ID] {
+			status = "running"
+			if as := o.PingAgent(a.ID); as != nil {
+				status = "idle"
+				if as.ActiveJobs() > 0 {
+					status = "active"
+				}
+			}
+		}
+		out = append(out, AdminAgent{
+			ID:           a.ID,
+			Description:  a.Description,
+			Model:        o.registry.ResolveModel(a.ID),
+			Status:       status,
+			MessageCount: msgStats[a.ID].MessageCount,
+		})
+	}
+	return map[string]any{"ok": true, "agents": out}
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAdminRequiresToken(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- o.ServeAdmin(ctx, path) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("ServeAdmin: %v", err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("admin socket left behind: %v", err)
+		}
+	})
+
+	send := func(token string) map[string]any {
+		t.Helper()
+		var conn net.Conn
+		var err error
+		for range 50 {
+			if conn, err = net.Dial("unix", path); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("dial admin socket: %v", err)
+		}
+		defer func() { _ = conn.Close() }()
+		if err := json.NewEncoder(conn).Encode(AdminCommand{IPCCommand: IPCCommand{Type: "admin_list_agents"}, Token: token}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		var out map[string]any
+		if err := json.NewDecoder(conn).Decode(&out); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return out
+	}
+
+	// No token configured: nothing gets through.
+	if resp := send(""); resp["error"] != "unauthorized" {
+		t.Errorf("without a gateway token: %v, want unauthorized", resp)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("admin socket mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	o.SetAdminToken("s3cret")
+	for _, bad := range []string{"", "wrong"} {
+		if resp := send(bad); resp["error"] != "unauthorized" {
+			t.Errorf("token %q: %v, want unauthorized", bad, resp)
+		}
+	}
+	if resp := send("s3cret"); resp["ok"] != true {
+		t.Errorf("valid token: %v", resp)
+	}
+}
@@ -0,0 +1,241 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	// ErrArtifactNotFound is returned for an artifact name the agent never
+	// saved, or whose blob is gone.
+	ErrArtifactNotFound = errors.New("artifact not found")
+	// ErrArtifactCorrupt is returned when an artifact's content no longer
+	// matches the checksum recorded when it was saved.
+	ErrArtifactCorrupt = errors.New("artifact checksum mismatch")
+)
+
+// artifactPruneInterval is how often expired artifacts are removed.
+const artifactPruneInterval = time.Hour
+
+// ipcSaveArtifact stores an agent output outside the chat. The content is
+// given inline as text (content) or base64 (data), or, for large outputs,
+// as a file the agent left in its workspace (path). Saving a name again
+// replaces the earlier artifact.
+func (o *Orchestrator) ipcSaveArtifact(msg *nats.Msg, agentID string, payload json.RawMessage) {
+	var req struct {
+		Name    string  `json:"name"`
+		Content *string `json:"content"`
+		Data    string  `json:"data"`
+		Path    string  `json:"path"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		o.respondIPC(msg, map[string]any{"error": "invalid payload"})
+		return
+	}
+	if req.Name == "" || (req.Content == nil && req.Data == "" && req.Path == "") {
+		o.respondIPC(msg, map[string]any{"error": "name and content, data or path are required"})
+		return
+	}
+	name, err := SanitizeFileName(req.Name)
+	if err != nil {
+		o.respondIPC(msg, map[string]any{"error": err.Error()})
+		return
+	}
+
+	maxMB := o.defaults().ArtifactMaxSizeMB
+	maxSize := maxMB << 20
+	tooLarge := fmt.Sprintf("artifact too large (max %d MB)", maxMB)
+
+	var data []byte
+	switch {
+	case req.Path != "":
+		data, err = o.readWorkspaceFile(agentID, req.Path, maxSize)
+		if errors.Is(err, container.ErrFileTooLarge) {
+			o.respondIPC(msg, map[string]any{"error": tooLarge})
+			return
+		}
+		if err != nil {
+			o.respondIPC(msg, map[string]any{"error": err.Error()})
+			return
+		}
+	case req.Content != nil:
+		data = []byte(*req.Content)
+	default:
+		if maxSize > 0 && int64(base64.StdEncoding.DecodedLen(len(req.Data))) > maxSize+2 {
+			o.respondIPC(msg, map[string]any{"error": tooLarge})
+			return
+		}
+		data, err = base64.StdEncoding.DecodeString(req.Data)
+		if err != nil {
+			o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("base64 decode failed: %v", err)})
+			return
+		}
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		o.respondIPC(msg, map[string]any{"error": tooLarge})
+		return
+	}
+
+	a, err := o.saveArtifact(agentID, name, data)
+	if err != nil {
+		slog.Error("failed to save artifact", "agent", agentID, "name", name, "error", err)
+		o.respondIPC(msg, map[string]any{"error": "failed to save artifact"})
+		return
+	}
+	slog.Info("artifact saved via IPC", "agent", agentID, "name", name, "size", a.Size, "sha256", a.SHA256)
+	o.respondIPC(msg, map[string]any{"ok": true, "name": a.Name, "size": a.Size, "sha256": a.SHA256})
+}
+
+// blobPath is where content with the given hex SHA-256 is stored.
+func (o *Orchestrator) blobPath(sum string) string {
+	return filepath.Join(o.artifactsDir, sum[:2], sum)
+}
+
+func (o *Orchestrator) saveArtifact(agentID, name string, data []byte) (*store.Artifact, error) {
+	h := sha256.Sum256(data)
+	sum := hex.EncodeToString(h[:])
+
+	o.artifactMu.Lock()
+	defer o.artifactMu.Unlock()
+
+	path := o.blobPath(sum)
+	if _, err := os.Stat(path); err != nil {
+		if err := writeBlob(path, data); err != nil {
+			return nil, err
+		}
+	}
+	a := &store.Artifact{AgentID: agentID, Name: name, Size: int64(len(data)), SHA256: sum}
+	if err := o.store.SaveArtifact(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// writeBlob writes data to path through a temporary file, so a blob is
+// either complete or absent.
+func writeBlob(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create artifact dir: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create artifact: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write artifact: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write artifact: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("write artifact: %w", err)
+	}
+	return nil
+}
+
+// ListArtifacts returns the artifacts an agent saved, newest first.
+func (o *Orchestrator) ListArtifacts(agentID string) ([]store.Artifact, error) {
+	return o.store.ListArtifacts(agentID)
+}
+
+// OpenArtifact opens an agent's artifact for reading after checking its
+// content against the recorded checksum. The caller closes the file.
+func (o *Orchestrator) OpenArtifact(agentID, name string) (*store.Artifact, *os.File, error) {
+	a, err := o.store.GetArtifact(agentID, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a == nil {
+		return nil, nil, ErrArtifactNotFound
+	}
+	f, err := os.Open(o.blobPath(a.SHA256))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("open artifact: %w", err)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		_ = f.Close()
+		return nil, nil, fmt.Errorf("read artifact: %w", err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != a.SHA256 {
+		_ = f.Close()
+		slog.Error("artifact failed checksum verification", "agent", agentID, "name", name, "sha256", a.SHA256)
+		return nil, nil, ErrArtifactCorrupt
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, nil, fmt.Errorf("read artifact: %w", err)
+	}
+	return a, f, nil
+}
+
+// StartArtifactPruner periodically removes artifacts older than
+// defaults.artifact_retention, and blobs no artifact refers to any more.
+func (o *Orchestrator) StartArtifactPruner(ctx context.Context) {
+	ticker := time.NewTicker(artifactPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := o.pruneArtifacts(time.Now()); err != nil {
+			slog.Error("artifact prune failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o *Orchestrator) pruneArtifacts(now time.Time) error {
+	o.artifactMu.Lock()
+	defer o.artifactMu.Unlock()
+
+	if retention := o.defaults().ArtifactRetention; retention > 0 {
+		n, err := o.store.DeleteArtifactsBefore(now.Add(-retention))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			slog.Info("expired artifacts removed", "count", n)
+		}
+	}
+
+	keep, err := o.store.ArtifactBlobs()
+	if err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(o.artifactsDir, "*", "*"))
+	if err != nil {
+		return fmt.Errorf("list artifact blobs: %w", err)
+	}
+	for _, p := range paths {
+		if keep[filepath.Base(p)] {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			slog.Warn("failed to remove artifact blob", "path", p, "error", err)
+		}
+	}
+	return nil
+}
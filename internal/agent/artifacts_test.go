@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIPCSaveArtifact(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	o.artifactsDir = t.TempDir()
+	o.cfg.ArtifactMaxSizeMB = 1
+
+	content := "a long report\n"
+	sum := sha256.Sum256([]byte(content))
+	want := hex.EncodeToString(sum[:])
+
+	resp := sendTestIPC(t, o, "alpha", "save_artifact", map[string]any{"name": "report.txt", "content": content})
+	if resp["ok"] != true || resp["sha256"] != want || resp["size"] != float64(len(content)) {
+		t.Fatalf("save response = %v", resp)
+	}
+	// The same bytes as base64 under another name share the blob.
+	resp = sendTestIPC(t, o, "alpha", "save_artifact", map[string]any{"name": "../copy.txt", "data": base64.StdEncoding.EncodeToString([]byte(content))})
+	if resp["ok"] != true || resp["name"] != "copy.txt" || resp["sha256"] != want {
+		t.Fatalf("save base64 response = %v", resp)
+	}
+
+	a, f, err := o.OpenArtifact("alpha", "report.txt")
+	if err != nil {
+		t.Fatalf("open artifact: %v", err)
+	}
+	got, _ := io.ReadAll(f)
+	_ = f.Close()
+	if string(got) != content || a.SHA256 != want {
+		t.Errorf("artifact = %q (%s), want %q (%s)", got, a.SHA256, content, want)
+	}
+
+	if _, _, err := o.OpenArtifact("alpha", "missing.txt"); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("missing artifact error = %v", err)
+	}
+	if _, _, err := o.OpenArtifact("beta", "report.txt"); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("other agent's artifact error = %v", err)
+	}
+
+	big := base64.StdEncoding.EncodeToString(make([]byte, 2<<20))
+	if resp := sendTestIPC(t, o, "alpha", "save_artifact", map[string]any{"name": "big.bin", "data": big}); resp["error"] == nil {
+		t.Errorf("oversized artifact accepted: %v", resp)
+	}
+	if resp := sendTestIPC(t, o, "alpha", "save_artifact", map[string]any{"name": "empty.txt"}); resp["error"] == nil {
+		t.Errorf("artifact without content accepted: %v", resp)
+	}
+}
+
+func TestOpenArtifactVerifiesChecksum(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	o.artifactsDir = t.TempDir()
+
+	a, err := o.saveArtifact("alpha", "out.txt", []byte("original"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(o.blobPath(a.SHA256), []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := o.OpenArtifact("alpha", "out.txt"); !errors.Is(err, ErrArtifactCorrupt) {
+		t.Errorf("tampered artifact error = %v, want ErrArtifactCorrupt", err)
+	}
+}
+
+func TestPruneArtifacts(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	o.artifactsDir = t.TempDir()
+	o.cfg.ArtifactRetention = time.Hour
+
+	old, err := o.saveArtifact("alpha", "old.txt", []byte("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.saveArtifact("alpha", "new.txt", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	// Replacing an artifact leaves its previous blob unreferenced.
+	replaced, err := o.saveArtifact("alpha", "new.txt", []byte("newer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.store.DB().Exec(`UPDATE artifacts SET created_at = datetime('now', '-2 hours') WHERE name = 'old.txt'`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.pruneArtifacts(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := o.OpenArtifact("alpha", "old.txt"); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("expired artifact error = %v", err)
+	}
+	if _, err := os.Stat(o.blobPath(old.SHA256)); !os.IsNotExist(err) {
+		t.Errorf("expired blob still present: %v", err)
+	}
+	_, f, err := o.OpenArtifact("alpha", "new.txt")
+	if err != nil {
+		t.Fatalf("kept artifact: %v", err)
+	}
+	_ = f.Close()
+	if _, err := os.Stat(o.blobPath(replaced.SHA256)); err != nil {
+		t.Errorf("kept blob missing: %v", err)
+	}
+	files, _ := os.ReadDir(o.artifactsDir)
+	n := 0
+	for _, d := range files {
+		entries, _ := os.ReadDir(filepath.Join(o.artifactsDir, d.Name()))
+		n += len(entries)
+	}
+	if n != 1 {
+		t.Errorf("%d blobs left, want 1", n)
+	}
+}
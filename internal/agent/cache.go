@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+// responseCacheKey returns the response cache key for a message, or "" when
+// the message must not be served from cache. Only isolated, non-
+// conversational messages (scheduled tasks, webhooks) are cacheable: a
+// message that shares the agent's conversation context can legitimately
+// get a different answer for the same text. meta["no_cache"] = "true"
+// bypasses the cache.
+func responseCacheKey(text string, meta map[string]string, ttl time.Duration) string {
+	if ttl <= 0 || meta["context_mode"] != "isolated" || meta["no_cache"] == "true" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// serveCachedResponse delivers a cached result for the prompt, if one newer
+// than ttl exists, exactly as if the agent had answered, run completion
+// included. Reports whether the message was served. requestID is the stored
+// user message being answered.
+func (o *Orchestrator) serveCachedResponse(agentID, cacheKey string, ttl time.Duration, meta map[string]string, requestID int64) bool {
+	cached, err := o.store.GetCachedResponse(agentID, cacheKey, time.Now().Add(-ttl))
+	if err != nil {
+		slog.Warn("response cache lookup failed", "agent", agentID, "error", err)
+		return false
+	}
+	if cached == nil {
+		return false
+	}
+
+	slog.Info("serving cached response", "agent", agentID, "age", time.Since(cached.CreatedAt).Round(time.Second))
+
+	agentMsg := &store.Message{
+		AgentID: agentID,
+		Sender:  "agent",
+		Content: cached.Response,
+	}
+	if requestID != 0 {
+		agentMsg.ReplyTo = &requestID
+	}
+	_ = o.store.SaveMessage(agentMsg)
+	o.publishMessageEvent(agentMsg)
+
+	for _, l := range o.outputListeners() {
+		l(agentID, cached.Response, meta)
+	}
+	o.notifyRunComplete(agentID, meta, nil)
+	return true
+}
+
+func (o *Orchestrator) popPendingCacheKey(msgID string) string {
+	if msgID == "" {
+		return ""
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	key := o.pendingCache[msgID]
+	delete(o.pendingCache, msgID)
+	return key
+}
+
+// InvalidateResponseCache drops an agent's cached responses, e.g. after its
+// configuration changed.
+func (o *Orchestrator) InvalidateResponseCache(agentID string) {
+	if err := o.store.DeleteCachedResponses(agentID); err != nil {
+		slog.Warn("failed to invalidate response cache", "agent", agentID, "error", err)
+	}
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResponseCacheKey(t *testing.T) {
+	isolated := map[string]string{"sender": "scheduler", "context_mode": "isolated"}
+
+	key := responseCacheKey("summarize", isolated, time.Hour)
+	if key == "" {
+		t.Fatal("expected isolated message to be cacheable")
+	}
+	if again := responseCacheKey("summarize", isolated, time.Hour); again != key {
+		t.Errorf("key not stable: %q vs %q", key, again)
+	}
+	if other := responseCacheKey("summarize!", isolated, time.Hour); other == key {
+		t.Error("different prompts produced the same key")
+	}
+
+	tests := []struct {
+		name string
+		meta map[string]string
+		ttl  time.Duration
+	}{
+		{"caching disabled", isolated, 0},
+		{"conversational message", map[string]string{"sender": "user:1", "chat_id": "1"}, time.Hour},
+		{"shared context task", map[string]string{"sender": "scheduler", "context_mode": "group"}, time.Hour},
+		{"bypass flag", map[string]string{"context_mode": "isolated", "no_cache": "true"}, time.Hour},
+		{"nil meta", nil, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := responseCacheKey("summarize", tt.meta, tt.ttl); got != "" {
+				t.Errorf("expected no cache key, got %q", got)
+			}
+		})
+	}
+}
+
+func TestCachedResponseCompletesRun(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	meta := map[string]string{"sender": "scheduler", "task_id": "t1", "context_mode": "isolated"}
+	key := responseCacheKey("summarize", meta, time.Hour)
+	if err := o.store.SaveCachedResponse("alpha", key, "cached answer"); err != nil {
+		t.Fatal(err)
+	}
+
+	var completed []map[string]string
+	o.OnRunComplete(func(agentID string, meta map[string]string, err error) {
+		if err != nil {
+			t.Errorf("cache hit completed with %v", err)
+		}
+		completed = append(completed, meta)
+	})
+	if !o.serveCachedResponse("alpha", key, time.Hour, meta, 0) {
+		t.Fatal("expected a cache hit")
+	}
+	if len(completed) != 1 || completed[0]["task_id"] != "t1" {
+		t.Errorf("run listeners got %v, want the task's run", completed)
+	}
+}
+
+func TestAbortCompletesDroppedRuns(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	q := o.getQueue("alpha")
+	q.Enqueue(QueuedMessage{AgentID: "alpha", Text: "one", Meta: map[string]string{"task_id": "t1"}})
+	q.Enqueue(QueuedMessage{AgentID: "alpha", Text: "two", Meta: map[string]string{"task_id": "t2"}})
+
+	var dropped []string
+	o.OnRunComplete(func(agentID string, meta map[string]string, err error) {
+		if !errors.Is(err, ErrMessageDropped) {
+			t.Errorf("dropped run completed with %v", err)
+		}
+		dropped = append(dropped, meta["task_id"])
+	})
+	if err := o.AbortSession(context.Background(), "alpha"); err != nil {
+		t.Fatal(err)
+	}
+	if q.Len() != 0 || len(dropped) != 2 {
+		t.Errorf("queue len %d, dropped %v; want the queue drained and both runs completed", q.Len(), dropped)
+	}
+}
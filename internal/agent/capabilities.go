@@ -0,0 +1,20 @@
+package agent
+
+import "github.com/mtzanidakis/praktor/internal/natsbus"
+
+// Capabilities returns what the agent's running container announced at
+// startup, or nil if it is not running or announced nothing.
+func (o *Orchestrator) Capabilities(agentID string) *natsbus.Capabilities {
+	if s := o.sessions.Get(agentID); s != nil {
+		return s.Capabilities
+	}
+	return nil
+}
+
+// supports reports whether the agent's container can use f. Only a
+// container that announced capabilities without f is known not to; one
+// that announced nothing keeps the behaviour from before the handshake.
+func (o *Orchestrator) supports(agentID string, f natsbus.Capability) bool {
+	caps := o.Capabilities(agentID)
+	return caps == nil || caps.Has(f)
+}
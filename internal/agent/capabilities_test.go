@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+func TestCapabilitiesGateFeatures(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	d := o.defaults()
+	d.History = config.HistoryConfig{Turns: 3}
+	o.UpdateDefaults(d)
+	agents := map[string]config.AgentDefinition{"alpha": {Workspace: "alpha", ModelFallbacks: []string{"model-b"}}}
+	if err := o.registry.Update(agents, o.defaults()); err != nil {
+		t.Fatal(err)
+	}
+	_ = o.store.SaveMessage(&store.Message{AgentID: "alpha", Sender: "user", Content: "earlier"})
+
+	// Nothing announced: the host behaves as before the handshake.
+	o.sessions.Set("alpha", &Session{AgentID: "alpha"})
+	if o.historyContext("alpha", 0, nil) == "" {
+		t.Error("no history for an agent that announced nothing")
+	}
+	o.trackFallback("alpha", "m1", map[string]string{"text": "hi"})
+
+	// Announced without the features: neither is used.
+	o.sessions.Set("alpha", &Session{AgentID: "alpha", Capabilities: &natsbus.Capabilities{
+		Version: 1, Features: []natsbus.Capability{natsbus.CapReadySignal},
+	}})
+	if got := o.historyContext("alpha", 0, nil); got != "" {
+		t.Errorf("history %q sent to an agent without history_injection", got)
+	}
+	o.trackFallback("alpha", "m2", map[string]string{"text": "hi"})
+
+	o.mu.Lock()
+	_, tracked1 := o.pendingPrompts["m1"]
+	_, tracked2 := o.pendingPrompts["m2"]
+	o.mu.Unlock()
+	if !tracked1 || tracked2 {
+		t.Errorf("fallback tracked: unannounced %v, without model_fallback %v; want true, false", tracked1, tracked2)
+	}
+}
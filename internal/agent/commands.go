@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	maxAgentCommands = 20 // per agent; Telegram menus hold 100 in total
+	maxCommandName   = 32 // Telegram's limit, namespace included
+	maxCommandDesc   = 256
+)
+
+var commandNameRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// CommandsListener is notified after an agent replaced its registered
+// commands.
+type CommandsListener func(agentID string)
+
+func (o *Orchestrator) OnCommands(listener CommandsListener) {
+	o.listenerMu.Lock()
+	defer o.listenerMu.Unlock()
+	o.commandListeners = append(o.commandListeners, listener)
+}
+
+// CommandName is the slash command under which an agent's command is
+// exposed: the agent id, then the command, e.g. coder_deploy for "deploy"
+// on agent coder. Built-in commands contain no underscore, so agent
+// commands can never shadow them.
+func CommandName(agentID, command string) string {
+	prefix := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(agentID))
+	return prefix + "_" + command
+}
+
+// ExpandCommandPrompt fills a command's prompt template with the text
+// typed after the command. {{args}} marks where it goes; without the
+// placeholder, arguments are appended on a new paragraph.
+func ExpandCommandPrompt(template, args string) string {
+	args = strings.TrimSpace(args)
+	if strings.Contains(template, "{{args}}") {
+		return strings.TrimSpace(strings.ReplaceAll(template, "{{args}}", args))
+	}
+	if args == "" {
+		return template
+	}
+	return template + "\n\n" + args
+}
+
+// ipcRegisterCommands replaces the agent's slash commands. Agents call it
+// on startup; an empty list removes them.
+func (o *Orchestrator) ipcRegisterCommands(msg *nats.Msg, agentID string, payload json.RawMessage) {
+	var cmds []store.AgentCommand
+	if err := json.Unmarshal(payload, &cmds); err != nil {
+		o.respondIPC(msg, map[string]any{"error": "invalid payload: expected a list of commands"})
+		return
+	}
+	existing, err := o.store.ListAgentCommands()
+	if err != nil {
+		o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("list failed: %v", err)})
+		return
+	}
+	if err := validateCommands(agentID, cmds, existing); err != nil {
+		o.respondIPC(msg, map[string]any{"error": err.Error()})
+		return
+	}
+	for i := range cmds {
+		cmds[i].AgentID = agentID
+	}
+
+	// The runner registers on every start; only a change reaches the bots.
+	var current []store.AgentCommand
+	for _, c := range existing {
+		if c.AgentID == agentID {
+			current = append(current, c)
+		}
+	}
+	if !slices.Equal(current, cmds) {
+		if err := o.store.SetAgentCommands(agentID, cmds); err != nil {
+			o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("save failed: %v", err)})
+			return
+		}
+		slog.Info("agent commands registered", "agent", agentID, "count", len(cmds))
+		o.listenerMu.RLock()
+		listeners := o.commandListeners
+		o.listenerMu.RUnlock()
+		for _, l := range listeners {
+			l(agentID)
+		}
+	}
+
+	names := make([]string, 0, len(cmds))
+	for _, c := range cmds {
+		names = append(names, CommandName(agentID, c.Command))
+	}
+	o.respondIPC(msg, map[string]any{"ok": true, "commands": names})
+}
+
+// validateCommands checks an agent's commands against Telegram's rules and
+// the commands other agents already registered.
+func validateCommands(agentID string, cmds, existing []store.AgentCommand) error {
+	if len(cmds) > maxAgentCommands {
+		return fmt.Errorf("too many commands: %d (max %d)", len(cmds), maxAgentCommands)
+	}
+
+	// Names taken by other agents; distinct ids can sanitize alike.
+	taken := make(map[string]string)
+	for _, c := range existing {
+		if c.AgentID != agentID {
+			taken[CommandName(c.AgentID, c.Command)] = c.AgentID
+		}
+	}
+
+	seen := make(map[string]bool, len(cmds))
+	for _, c := range cmds {
+		if !commandNameRe.MatchString(c.Command) {
+			return fmt.Errorf("command %q: only lowercase letters, digits and underscores are allowed", c.Command)
+		}
+		name := CommandName(agentID, c.Command)
+		if len(name) > maxCommandName {
+			return fmt.Errorf("command %q: /%s is longer than %d characters", c.Command, name, maxCommandName)
+		}
+		if seen[c.Command] {
+			return fmt.Errorf("command %q registered twice", c.Command)
+		}
+		seen[c.Command] = true
+		if other, ok := taken[name]; ok {
+			return fmt.Errorf("command %q: /%s is already registered by %s", c.Command, name, other)
+		}
+		if d := strings.TrimSpace(c.Description); d == "" || len(d) > maxCommandDesc {
+			return fmt.Errorf("command %q: description is required (max %d characters)", c.Command, maxCommandDesc)
+		}
+		if strings.TrimSpace(c.PromptTemplate) == "" {
+			return fmt.Errorf("command %q: prompt_template is required", c.Command)
+		}
+	}
+	return nil
+}
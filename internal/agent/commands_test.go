@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+func TestIPCRegisterCommands(t *testing.T) {
+	o := newTestOrchestrator(t, "coder", "ops")
+	var notified []string
+	o.OnCommands(func(agentID string) { notified = append(notified, agentID) })
+
+	cmds := []any{
+		map[string]any{"command": "deploy", "description": "Deploy main", "prompt_template": "Deploy {{args}} to production"},
+		map[string]any{"command": "report", "description": "Weekly report", "prompt_template": "Write the weekly report"},
+	}
+	resp := sendTestIPCPayload(t, o, "coder", "register_commands", cmds)
+	if resp["ok"] != true {
+		t.Fatalf("register failed: %v", resp)
+	}
+	if names, _ := resp["commands"].([]any); len(names) != 2 || names[0] != "coder_deploy" {
+		t.Errorf("unexpected command names %v", resp["commands"])
+	}
+
+	stored, err := o.store.ListAgentCommands()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 || stored[0].AgentID != "coder" || stored[0].Command != "deploy" || stored[1].Command != "report" {
+		t.Fatalf("unexpected stored commands %+v", stored)
+	}
+
+	// Re-registering the same set on the next start is not a change.
+	sendTestIPCPayload(t, o, "coder", "register_commands", cmds)
+	if len(notified) != 1 || notified[0] != "coder" {
+		t.Errorf("listeners notified %v, want [coder] once", notified)
+	}
+
+	for name, bad := range map[string]any{
+		"uppercase":   map[string]any{"command": "Deploy", "description": "d", "prompt_template": "p"},
+		"no template": map[string]any{"command": "x", "description": "d"},
+		"too long":    map[string]any{"command": "a_really_long_command_name_here", "description": "d", "prompt_template": "p"},
+	} {
+		if resp := sendTestIPCPayload(t, o, "ops", "register_commands", []any{bad}); resp["error"] == nil {
+			t.Errorf("%s: expected an error, got %v", name, resp)
+		}
+	}
+
+	// An empty list unregisters.
+	if resp := sendTestIPCPayload(t, o, "coder", "register_commands", []any{}); resp["ok"] != true {
+		t.Fatalf("unregister failed: %v", resp)
+	}
+	if stored, _ := o.store.ListAgentCommands(); len(stored) != 0 {
+		t.Errorf("expected no commands, got %+v", stored)
+	}
+}
+
+func TestCommandNameClash(t *testing.T) {
+	existing := []store.AgentCommand{{AgentID: "team-a", Command: "b", Description: "d", PromptTemplate: "p"}}
+	// team-a/b and team/a_b both become /team_a_b.
+	err := validateCommands("team", []store.AgentCommand{{Command: "a_b", Description: "d", PromptTemplate: "p"}}, existing)
+	if err == nil {
+		t.Error("expected a clash with team-a's command")
+	}
+}
+
+func TestExpandCommandPrompt(t *testing.T) {
+	tests := []struct{ template, args, want string }{
+		{"Deploy {{args}} now", "api", "Deploy api now"},
+		{"Deploy {{args}}", "", "Deploy"},
+		{"Write the report", "", "Write the report"},
+		{"Write the report", " for Q3 ", "Write the report\n\nfor Q3"},
+	}
+	for _, tt := range tests {
+		if got := ExpandCommandPrompt(tt.template, tt.args); got != tt.want {
+			t.Errorf("ExpandCommandPrompt(%q, %q) = %q, want %q", tt.template, tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/mtzanidakis/praktor/internal/registry"
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/nats-io/nats.go"
+)
+
+// newTestOrchestrator builds an orchestrator on a real bus and store. The
+// docker client points at an unreachable host, so container starts fail fast
+// instead of touching a local daemon.
+func newTestOrchestrator(t *testing.T, agentIDs ...string) *Orchestrator {
+	t.Helper()
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:1")
+
+	dir := t.TempDir()
+	bus, err := natsbus.NewForTest(config.NATSConfig{DataDir: filepath.Join(dir, "nats")})
+	if err != nil {
+		t.Fatalf("failed to create bus: %v", err)
+	}
+	t.Cleanup(bus.Close)
+
+	s, err := store.New(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	defaults := config.DefaultsConfig{Image: "praktor-agent:latest", MaxRunning: 5, IdleTimeout: time.Millisecond}
+	agents := make(map[string]config.AgentDefinition, len(agentIDs))
+	for _, id := range agentIDs {
+		agents[id] = config.AgentDefinition{Workspace: id}
+	}
+	reg := registry.New(s, agents, defaults, filepath.Join(dir, "agents"))
+	if err := reg.Sync(); err != nil {
+		t.Fatalf("sync registry: %v", err)
+	}
+
+	ctr, err := container.NewManager(bus, defaults)
+	if err != nil {
+		t.Fatalf("failed to create container manager: %v", err)
+	}
+
+	o := NewOrchestrator(bus, ctr, s, reg, defaults, nil)
+	if o.client == nil {
+		t.Fatal("orchestrator has no nats client")
+	}
+	t.Cleanup(o.client.Close)
+	return o
+}
+
+// TestOrchestratorConcurrency hammers the message, output, stop and idle
+// reaper paths in parallel. Run with -race; it also fails on deadlock via the
+// test timeout.
+func TestOrchestratorConcurrency(t *testing.T) {
+	agentIDs := []string{"alpha", "beta", "gamma"}
+	o := newTestOrchestrator(t, agentIDs...)
+	o.reapInterval = time.Millisecond
+
+	// Listeners run unlocked and may call back into the orchestrator.
+	o.OnOutput(func(agentID, content string, meta map[string]string) {
+		_ = o.getQueue(agentID).Len()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.StartIdleReaper(ctx)
+
+	var wg sync.WaitGroup
+	for _, id := range agentIDs {
+		wg.Add(5)
+		go func() {
+			defer wg.Done()
+			for i := range 20 {
+				_ = o.HandleMessage(ctx, id, fmt.Sprintf("msg %d", i), map[string]string{"chat_id": "1"})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				data, _ := json.Marshal(map[string]string{
+					"type":    "result",
+					"content": fmt.Sprintf("reply %d", i),
+					"msg_id":  fmt.Sprintf("%s-%d", id, i),
+				})
+				o.handleAgentOutput(&nats.Msg{Subject: natsbus.TopicAgentOutput(id), Data: data})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				_ = o.StopAgent(ctx, id)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				o.sessions.Set(id, &Session{AgentID: id, LastActive: time.Now().Add(-time.Hour)})
+				o.sessions.Touch(id)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				o.UpdateDefaults(config.DefaultsConfig{MaxRunning: 5, IdleTimeout: time.Millisecond})
+				_ = o.resolveRateLimit(id)
+			}
+		}()
+	}
+	wg.Wait()
+	cancel()
+
+	// Every queue must drain: processors exit only once their queue is empty.
+	deadline := time.Now().Add(10 * time.Second)
+	for _, id := range agentIDs {
+		for o.getQueue(id).Busy() {
+			if time.Now().After(deadline) {
+				t.Fatalf("queue for %s did not drain", id)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestAgentQueueNextReleasesLock(t *testing.T) {
+	q := NewAgentQueue("a")
+	if !q.TryLock() {
+		t.Fatal("expected to acquire processing lock")
+	}
+	q.Enqueue(QueuedMessage{Text: "one"})
+
+	if msg, ok := q.Next(); !ok || msg.Text != "one" {
+		t.Fatalf("expected first message, got %+v %v", msg, ok)
+	}
+	if !q.Busy() {
+		t.Error("expected queue busy while the processor holds the lock")
+	}
+	if _, ok := q.Next(); ok {
+		t.Fatal("expected empty queue")
+	}
+
+	// Draining released the lock, so a new processor can start.
+	if !q.TryLock() {
+		t.Error("expected processing lock to be released once drained")
+	}
+}
+
+// TestEnsureAgentSharesStart has many callers ask for a stopped agent at
+// once: one container start runs, and every caller gets its result.
+func TestEnsureAgentSharesStart(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+
+	var starts atomic.Int32
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	errBoom := errors.New("boom")
+	o.startContainer = func(ctx context.Context, opts container.AgentOpts) (*container.ContainerInfo, error) {
+		if starts.Add(1) == 1 {
+			close(entered)
+		}
+		<-release
+		return nil, errBoom
+	}
+
+	const callers = 20
+	errs := make(chan error, callers)
+	var arrived, wg sync.WaitGroup
+	arrived.Add(callers)
+	for range callers {
+		wg.Go(func() {
+			arrived.Done()
+			errs <- o.EnsureAgent(context.Background(), "alpha")
+		})
+	}
+	<-entered
+	arrived.Wait()
+	time.Sleep(50 * time.Millisecond) // let the stragglers reach the in-flight start
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	if n := starts.Load(); n != 1 {
+		t.Errorf("container started %d times, want 1", n)
+	}
+	for err := range errs {
+		if !errors.Is(err, errBoom) {
+			t.Errorf("EnsureAgent error = %v, want %v", err, errBoom)
+		}
+	}
+}
+
+// TestStartRetriesAfterStarterCancelled checks that a caller whose start was
+// shared is not failed by the starter's own cancelled context.
+func TestStartRetriesAfterStarterCancelled(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+
+	var starts atomic.Int32
+	entered := make(chan struct{})
+	o.startContainer = func(ctx context.Context, opts container.AgentOpts) (*container.ContainerInfo, error) {
+		if starts.Add(1) == 1 {
+			close(entered)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, errors.New("boom")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	starter := make(chan error, 1)
+	go func() { starter <- o.EnsureAgent(ctx, "alpha") }()
+	<-entered
+
+	follower := make(chan error, 1)
+	go func() { follower <- o.EnsureAgent(context.Background(), "alpha") }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-starter; !errors.Is(err, context.Canceled) {
+		t.Errorf("starter error = %v, want context.Canceled", err)
+	}
+	if err := <-follower; err == nil || errors.Is(err, context.Canceled) {
+		t.Errorf("follower error = %v, want its own start's error", err)
+	}
+	if n := starts.Load(); n != 2 {
+		t.Errorf("container started %d times, want 2", n)
+	}
+}
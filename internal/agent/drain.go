@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/events"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+)
+
+// drain is a graceful restart in progress: the agent's in-flight messages
+// may finish before its container is stopped.
+type drain struct {
+	reason string
+	done   chan struct{} // closed once the old container is gone
+	timer  *time.Timer
+}
+
+// RestartAgent restarts an agent without losing in-flight replies. Messages
+// already sent to the container are allowed to finish, for up to
+// defaults.reload_drain_timeout, before it is stopped; new messages wait
+// for the drain and then start a fresh container. Once the timeout elapses
+// the container is stopped anyway and unfinished messages are dropped. A
+// zero timeout, or an agent with nothing in flight, stops at once.
+func (o *Orchestrator) RestartAgent(ctx context.Context, agentID, reason string) error {
+	if o.containers.GetRunning(agentID) == nil {
+		return nil
+	}
+	if o.startDrain(agentID, reason, o.defaults().ReloadDrainTimeout) {
+		return nil
+	}
+	slog.Info("restarting agent", "agent", agentID, "reason", reason)
+	return o.restartNow(ctx, agentID, reason, false)
+}
+
+// startDrain registers a drain for the agent if it has messages in flight
+// and timeout allows waiting for them. It reports whether the restart was
+// deferred, which includes a drain already being under way.
+func (o *Orchestrator) startDrain(agentID, reason string, timeout time.Duration) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.drains[agentID]; ok {
+		return true
+	}
+	inFlight := o.inFlightLocked(agentID)
+	if timeout <= 0 || inFlight == 0 {
+		return false
+	}
+	d := &drain{reason: reason, done: make(chan struct{})}
+	d.timer = time.AfterFunc(timeout, func() { o.finishDrain(agentID, true) })
+	o.drains[agentID] = d
+	slog.Info("draining agent before restart", "agent", agentID, "reason", reason,
+		"in_flight", inFlight, "timeout", timeout)
+	return true
+}
+
+func (o *Orchestrator) inFlightLocked(agentID string) int {
+	n := 0
+	for _, aid := range o.pendingMsgID {
+		if aid == agentID {
+			n++
+		}
+	}
+	return n
+}
+
+// maybeFinishDrain completes a drain once the agent's last in-flight
+// message has been answered.
+func (o *Orchestrator) maybeFinishDrain(agentID string) {
+	o.mu.RLock()
+	_, draining := o.drains[agentID]
+	idle := draining && o.inFlightLocked(agentID) == 0
+	o.mu.RUnlock()
+	if idle {
+		// Off the NATS callback: stopping the container takes a while.
+		go o.finishDrain(agentID, false)
+	}
+}
+
+func (o *Orchestrator) finishDrain(agentID string, timedOut bool) {
+	o.mu.Lock()
+	d, ok := o.drains[agentID]
+	if ok {
+		delete(o.drains, agentID)
+	}
+	o.mu.Unlock()
+	if !ok {
+		return
+	}
+	d.timer.Stop()
+	defer close(d.done)
+
+	if timedOut {
+		slog.Warn("drain timed out, stopping agent with messages in flight", "agent", agentID, "reason", d.reason)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := o.restartNow(ctx, agentID, d.reason, timedOut); err != nil {
+		slog.Error("failed to stop draining agent", "agent", agentID, "error", err)
+	}
+}
+
+// waitForDrain blocks while the agent is draining, so the next message is
+// served by a fresh container rather than the one being retired.
+func (o *Orchestrator) waitForDrain(ctx context.Context, agentID string) error {
+	o.mu.RLock()
+	d := o.drains[agentID]
+	o.mu.RUnlock()
+	if d == nil {
+		return nil
+	}
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (o *Orchestrator) restartNow(ctx context.Context, agentID, reason string, timedOut bool) error {
+	err := o.stopAgent(ctx, agentID, "restart")
+	o.publishRestartEvent(agentID, reason, timedOut)
+	return err
+}
+
+func (o *Orchestrator) publishRestartEvent(agentID, reason string, timedOut bool) {
+	if o.client == nil {
+		return
+	}
+	_ = o.client.PublishJSON(natsbus.TopicEventsAgent(agentID), events.NewAgentRestart(agentID, reason, timedOut))
+}
+
+// BounceAgent stops the agent's container at once, dropping any in-flight
+// messages, and starts a fresh one whose container id it returns. The
+// session id is kept, so the agent resumes its conversation, unless
+// clearSession rotates it first. An agent that isn't running is just started.
+func (o *Orchestrator) BounceAgent(ctx context.Context, agentID string, clearSession bool) (string, error) {
+	ag, err := o.registry.Get(agentID)
+	if err != nil {
+		return "", fmt.Errorf("get agent: %w", err)
+	}
+	if ag == nil {
+		return "", fmt.Errorf("%w: %s", ErrAgentNotFound, agentID)
+	}
+	if clearSession {
+		if _, err := o.store.RotateAgentSessionID(agentID); err != nil {
+			return "", fmt.Errorf("rotate session: %w", err)
+		}
+	}
+
+	var oldID string
+	if sess := o.sessions.Get(agentID); sess != nil {
+		oldID = sess.ContainerID
+	}
+	slog.Info("bouncing agent", "agent", agentID, "container", oldID, "clear_session", clearSession)
+	// A pending drain would otherwise stop the fresh container when it ends.
+	o.finishDrain(agentID, false)
+	if o.sessions.Get(agentID) != nil || o.containers.GetRunning(agentID) != nil {
+		if err := o.stopAgent(ctx, agentID, "restart"); err != nil {
+			return "", fmt.Errorf("stop agent: %w", err)
+		}
+	}
+
+	if err := o.startAgent(ctx, agentID); err != nil {
+		return "", err
+	}
+	var newID string
+	if sess := o.sessions.Get(agentID); sess != nil {
+		newID = sess.ContainerID
+	}
+	o.publishRestartedEvent(agentID, oldID, newID, clearSession)
+	return newID, nil
+}
+
+func (o *Orchestrator) publishRestartedEvent(agentID, oldID, newID string, sessionCleared bool) {
+	if o.client == nil {
+		return
+	}
+	_ = o.client.PublishJSON(natsbus.TopicEventsAgent(agentID), events.NewAgentRestarted(agentID, oldID, newID, sessionCleared))
+}
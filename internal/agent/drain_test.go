@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/nats-io/nats.go"
+)
+
+// watchRestarts collects agent_restart events for the agent.
+func watchRestarts(t *testing.T, o *Orchestrator, agentID string) <-chan map[string]any {
+	t.Helper()
+	ch := make(chan map[string]any, 4)
+	sub, err := o.client.Subscribe(natsbus.TopicEventsAgent(agentID), func(msg *nats.Msg) {
+		var ev map[string]any
+		if json.Unmarshal(msg.Data, &ev) == nil && ev["type"] == "agent_restart" {
+			ch <- ev
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+	_ = o.client.Flush()
+	return ch
+}
+
+// markInFlight records a message as sent to the container, as executeMessage does.
+func markInFlight(o *Orchestrator, agentID, msgID string) {
+	o.mu.Lock()
+	o.pendingMsgID[msgID] = agentID
+	o.pendingMeta[msgID] = map[string]string{}
+	o.mu.Unlock()
+}
+
+func TestDrainWaitsForInFlightResult(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	restarts := watchRestarts(t, o, "alpha")
+	delivered := make(chan string, 1)
+	o.OnOutput(func(_, content string, _ map[string]string) { delivered <- content })
+
+	markInFlight(o, "alpha", "m1")
+	if !o.startDrain("alpha", "config_changed", time.Minute) {
+		t.Fatal("expected the restart to wait for the in-flight message")
+	}
+
+	// A message arriving now must wait for the old container to go.
+	waited := make(chan error, 1)
+	go func() { waited <- o.waitForDrain(context.Background(), "alpha") }()
+	select {
+	case <-waited:
+		t.Fatal("waitForDrain returned before the drain finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_ = o.client.PublishJSON(natsbus.TopicAgentOutput("alpha"),
+		map[string]any{"type": "result", "content": "done", "msg_id": "m1"})
+
+	select {
+	case got := <-delivered:
+		if got != "done" {
+			t.Errorf("delivered %q, want %q", got, "done")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight result was not delivered")
+	}
+	select {
+	case ev := <-restarts:
+		if ev["timed_out"] != false || ev["reason"] != "config_changed" {
+			t.Errorf("restart event = %v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no restart after the drain")
+	}
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Errorf("waitForDrain: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForDrain still blocked after the restart")
+	}
+}
+
+func TestDrainTimesOut(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	restarts := watchRestarts(t, o, "alpha")
+
+	markInFlight(o, "alpha", "m1")
+	if !o.startDrain("alpha", "config_changed", 50*time.Millisecond) {
+		t.Fatal("expected a drain")
+	}
+	// A second reload while draining keeps the existing drain.
+	if !o.startDrain("alpha", "config_changed", time.Minute) {
+		t.Fatal("expected the second restart to join the drain")
+	}
+
+	select {
+	case ev := <-restarts:
+		if ev["timed_out"] != true {
+			t.Errorf("restart event = %v, want timed_out", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain never timed out")
+	}
+	_ = o.client.Flush()
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if n := o.inFlightLocked("alpha"); n != 0 {
+		t.Errorf("%d messages still pending after the restart", n)
+	}
+	if _, ok := o.drains["alpha"]; ok {
+		t.Error("drain not cleared")
+	}
+}
+
+func TestStartDrainSkipsIdleAgent(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	if o.startDrain("alpha", "config_changed", time.Minute) {
+		t.Error("idle agent should restart immediately")
+	}
+	markInFlight(o, "alpha", "m1")
+	if o.startDrain("alpha", "config_changed", 0) {
+		t.Error("zero drain timeout should restart immediately")
+	}
+}
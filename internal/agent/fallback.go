@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"encoding/json"
+	"log/slog"
+	"maps"
+
+	"github.com/mtzanidakis/praktor/internal/events"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+)
+
+// maxModelFallbacks caps how many fallback models are tried for a single
+// message, however long the agent's model_fallbacks list is.
+const maxModelFallbacks = 3
+
+// pendingPrompt is a published input payload kept so the same prompt can be
+// re-sent with a fallback model.
+type pendingPrompt struct {
+	payload  map[string]string
+	attempts int
+}
+
+// trackFallback remembers payload for msgID if the agent has fallback models
+// and its runner can report model errors.
+func (o *Orchestrator) trackFallback(agentID, msgID string, payload map[string]string) {
+	def, ok := o.registry.GetDefinition(agentID)
+	if !ok || len(def.ModelFallbacks) == 0 || !o.supports(agentID, natsbus.CapModelFallback) {
+		return
+	}
+	o.mu.Lock()
+	o.pendingPrompts[msgID] = &pendingPrompt{payload: maps.Clone(payload)}
+	o.mu.Unlock()
+}
+
+func (o *Orchestrator) dropFallback(msgID string) {
+	if msgID == "" {
+		return
+	}
+	o.mu.Lock()
+	delete(o.pendingPrompts, msgID)
+	o.mu.Unlock()
+}
+
+// retryWithFallback handles a retryable model error from the agent by
+// re-sending the original prompt with the next fallback model. It returns
+// false when the error should be surfaced instead: not retryable, unknown
+// message, or the fallback chain is exhausted.
+func (o *Orchestrator) retryWithFallback(agentID, msgID, code, failedModel string) bool {
+	if msgID == "" {
+		return false
+	}
+	def, _ := o.registry.GetDefinition(agentID)
+	limit := min(len(def.ModelFallbacks), maxModelFallbacks)
+	primary := o.registry.ResolveModel(agentID)
+
+	o.mu.Lock()
+	p, ok := o.pendingPrompts[msgID]
+	if !ok || p.attempts >= limit {
+		delete(o.pendingPrompts, msgID)
+		o.mu.Unlock()
+		return false
+	}
+	if failedModel == "" {
+		if p.attempts == 0 {
+			failedModel = primary
+		} else {
+			failedModel = def.ModelFallbacks[p.attempts-1]
+		}
+	}
+	model := def.ModelFallbacks[p.attempts]
+	p.attempts++
+	attempt := p.attempts
+	payload := maps.Clone(p.payload)
+	o.mu.Unlock()
+
+	payload["model"] = model
+	slog.Warn("model error, retrying with fallback", "agent", agentID, "code", code,
+		"from", failedModel, "to", model, "attempt", attempt, "max", limit)
+	o.publishFallbackEvent(agentID, msgID, code, failedModel, model, attempt)
+
+	data, _ := json.Marshal(payload)
+	err := o.client.Publish(natsbus.TopicAgentInput(agentID), data)
+	if err == nil {
+		err = o.client.Flush()
+	}
+	if err != nil {
+		slog.Error("failed to publish fallback message", "agent", agentID, "error", err)
+		o.dropFallback(msgID)
+		return false
+	}
+	return true
+}
+
+func (o *Orchestrator) publishFallbackEvent(agentID, msgID, code, from, to string, attempt int) {
+	if o.client == nil {
+		return
+	}
+	_ = o.client.PublishJSON(natsbus.TopicEventsAgent(agentID), events.NewModelFallback(agentID, msgID, code, from, to, attempt))
+}
@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/nats-io/nats.go"
+)
+
+// startFakeAgent answers prompts on the agent's input topic: it reports a
+// retryable model error unless the prompt carries one of the working models.
+func startFakeAgent(t *testing.T, o *Orchestrator, agentID string, working ...string) {
+	t.Helper()
+	c, err := natsbus.NewClient(o.bus)
+	if err != nil {
+		t.Fatalf("fake agent client: %v", err)
+	}
+	t.Cleanup(c.Close)
+
+	_, err = c.Subscribe(natsbus.TopicAgentInput(agentID), func(msg *nats.Msg) {
+		var in map[string]string
+		if err := json.Unmarshal(msg.Data, &in); err != nil {
+			return
+		}
+		model := in["model"]
+		if model == "" {
+			model = "model-a"
+		}
+		out := map[string]any{"type": "error", "code": "overloaded", "retryable": true,
+			"content": "Error: overloaded", "msg_id": in["msg_id"], "model": model}
+		for _, m := range working {
+			if m == model {
+				out = map[string]any{"type": "result", "content": "answered by " + model, "msg_id": in["msg_id"]}
+			}
+		}
+		_ = c.PublishJSON(natsbus.TopicAgentOutput(agentID), out)
+	})
+	if err != nil {
+		t.Fatalf("fake agent subscribe: %v", err)
+	}
+	_ = c.Flush()
+}
+
+// sendFallbackPrompt publishes a prompt the way executeMessage does and waits
+// for the message's final output.
+func sendFallbackPrompt(t *testing.T, o *Orchestrator, agentID string) (string, []map[string]any) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var events []map[string]any
+	sub, err := o.client.Subscribe(natsbus.TopicEventsAgent(agentID), func(msg *nats.Msg) {
+		var ev map[string]any
+		if json.Unmarshal(msg.Data, &ev) == nil && ev["type"] == "model_fallback" {
+			mu.Lock()
+			events = append(events, ev)
+			mu.Unlock()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	done := make(chan string, 1)
+	o.OnOutput(func(_, content string, _ map[string]string) { done <- content })
+
+	payload := map[string]string{"text": "hi", "agentID": agentID, "msg_id": "m1"}
+	o.trackFallback(agentID, "m1", payload)
+	data, _ := json.Marshal(payload)
+	if err := o.client.Publish(natsbus.TopicAgentInput(agentID), data); err != nil {
+		t.Fatal(err)
+	}
+
+	var content string
+	select {
+	case content = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no output delivered")
+	}
+	_ = o.client.Flush()
+
+	o.mu.Lock()
+	_, leaked := o.pendingPrompts["m1"]
+	o.mu.Unlock()
+	if leaked {
+		t.Error("expected pending prompt to be cleared")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return content, events
+}
+
+func setModelFallbacks(t *testing.T, o *Orchestrator, agentID string, fallbacks ...string) {
+	t.Helper()
+	agents := map[string]config.AgentDefinition{
+		agentID: {Workspace: agentID, Model: "model-a", ModelFallbacks: fallbacks},
+	}
+	if err := o.registry.Update(agents, o.defaults()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModelFallbackRetriesNextModel(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	setModelFallbacks(t, o, "alpha", "model-b", "model-c")
+	startFakeAgent(t, o, "alpha", "model-b")
+
+	content, events := sendFallbackPrompt(t, o, "alpha")
+	if content != "answered by model-b" {
+		t.Errorf("content = %q, want answer from model-b", content)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 fallback event, got %d", len(events))
+	}
+	if events[0]["from_model"] != "model-a" || events[0]["to_model"] != "model-b" {
+		t.Errorf("unexpected fallback event %v", events[0])
+	}
+}
+
+func TestModelFallbackExhausted(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	setModelFallbacks(t, o, "alpha", "model-b")
+	startFakeAgent(t, o, "alpha")
+
+	content, events := sendFallbackPrompt(t, o, "alpha")
+	if !strings.Contains(content, "model error") {
+		t.Errorf("expected model error notice, got %q", content)
+	}
+	if len(events) != 1 {
+		t.Errorf("expected 1 fallback event, got %d", len(events))
+	}
+}
+
+func TestModelFallbackCapsAttempts(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	setModelFallbacks(t, o, "alpha", "b", "c", "d", "e", "f")
+	startFakeAgent(t, o, "alpha")
+
+	_, events := sendFallbackPrompt(t, o, "alpha")
+	if len(events) != maxModelFallbacks {
+		t.Errorf("expected %d fallback events, got %d", maxModelFallbacks, len(events))
+	}
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+// SanitizeFileName reduces an agent- or user-supplied file name to a bare
+// base name: path components and control characters are dropped.
+func SanitizeFileName(name string) (string, error) {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "", fmt.Errorf("invalid file name")
+	}
+	return name, nil
+}
+
+// CheckFileFilter reports whether a file with the given name, MIME type and
+// content may be sent by an agent or attached to a web message. An empty
+// mimeType is inferred from the extension. Non-empty data is also sniffed
+// with http.DetectContentType and the sniffed type must pass the MIME lists
+// too, so a declared type or extension cannot disguise the content. Only the
+// sniffer's generic fallbacks (octet-stream, plain text) are exempt from the
+// allow list, since they say nothing about what the file is.
+func CheckFileFilter(f config.FileFilterConfig, name, mimeType string, data []byte) error {
+	ext := strings.ToLower(path.Ext(name))
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(ext)
+	}
+	mimeType = baseMIMEType(mimeType)
+
+	if matchExtension(f.DeniedExtensions, ext) {
+		return fmt.Errorf("file extension %q is not allowed", ext)
+	}
+	if mimeType != "" && matchMIMEType(f.DeniedMIMETypes, mimeType) {
+		return fmt.Errorf("mime type %q is not allowed", mimeType)
+	}
+	if len(f.AllowedExtensions) > 0 && !matchExtension(f.AllowedExtensions, ext) {
+		return fmt.Errorf("file extension %q is not allowed", ext)
+	}
+	if len(f.AllowedMIMETypes) > 0 && !matchMIMEType(f.AllowedMIMETypes, mimeType) {
+		return fmt.Errorf("mime type %q is not allowed", mimeType)
+	}
+
+	if len(data) == 0 {
+		return nil
+	}
+	sniffed := baseMIMEType(http.DetectContentType(data))
+	if matchMIMEType(f.DeniedMIMETypes, sniffed) {
+		return fmt.Errorf("file content type %q is not allowed", sniffed)
+	}
+	generic := sniffed == "application/octet-stream" || sniffed == "text/plain"
+	if len(f.AllowedMIMETypes) > 0 && !generic && !matchMIMEType(f.AllowedMIMETypes, sniffed) {
+		return fmt.Errorf("file content type %q is not allowed", sniffed)
+	}
+	return nil
+}
+
+// baseMIMEType lowercases mimeType and drops any parameters.
+func baseMIMEType(mimeType string) string {
+	if mt, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = mt
+	}
+	return strings.ToLower(mimeType)
+}
+
+func matchExtension(list []string, ext string) bool {
+	if ext == "" {
+		return false
+	}
+	for _, e := range list {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func matchMIMEType(list []string, mimeType string) bool {
+	if mimeType == "" {
+		return false
+	}
+	for _, m := range list {
+		m = strings.ToLower(strings.TrimSpace(m))
+		if prefix, ok := strings.CutSuffix(m, "/*"); ok {
+			if strings.HasPrefix(mimeType, prefix+"/") {
+				return true
+			}
+		} else if m == mimeType {
+			return true
+		}
+	}
+	return false
+}
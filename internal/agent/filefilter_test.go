@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{in: "report.pdf", want: "report.pdf"},
+		{in: "/etc/passwd", want: "passwd"},
+		{in: `..\..\evil.txt`, want: "evil.txt"},
+		{in: "bad\x00na\nme.png", want: "badname.png"},
+		{in: "..", wantErr: true},
+		{in: "dir/", wantErr: false, want: "dir"},
+		{in: "\x01\x02", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := SanitizeFileName(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SanitizeFileName(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("SanitizeFileName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCheckFileFilter(t *testing.T) {
+	f := config.FileFilterConfig{
+		AllowedMIMETypes: []string{"application/pdf", "image/*"},
+		DeniedExtensions: []string{"SVG"},
+	}
+	tests := []struct {
+		name, mime string
+		ok         bool
+	}{
+		{"doc.pdf", "application/pdf", true},
+		{"doc.pdf", "", true}, // inferred from extension
+		{"photo.jpg", "image/jpeg; q=1", true},
+		{"logo.svg", "image/svg+xml", false},
+		{"notes.txt", "text/plain", false},
+		{"blob", "", false},
+	}
+	for _, tt := range tests {
+		err := CheckFileFilter(f, tt.name, tt.mime, nil)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckFileFilter(%q, %q) = %v, want ok=%v", tt.name, tt.mime, err, tt.ok)
+		}
+	}
+
+	sniffed := []struct {
+		name, mime, data string
+		ok               bool
+	}{
+		{"doc.pdf", "application/pdf", "%PDF-1.4", true},
+		{"photo.jpg", "image/jpeg", "PK\x03\x04 not a photo", false}, // sniffed application/zip
+		{"photo.jpg", "image/jpeg", "\x00\x01\x02unknown", true},     // octet-stream says nothing
+	}
+	for _, tt := range sniffed {
+		err := CheckFileFilter(f, tt.name, tt.mime, []byte(tt.data))
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckFileFilter(%q, %q, %q) = %v, want ok=%v", tt.name, tt.mime, tt.data, err, tt.ok)
+		}
+	}
+	deny := config.FileFilterConfig{DeniedMIMETypes: []string{"text/html"}}
+	if err := CheckFileFilter(deny, "notes.txt", "text/plain", []byte("<!DOCTYPE html><html></html>")); err == nil {
+		t.Error("html content declared as text/plain should be denied")
+	}
+
+	if err := CheckFileFilter(config.FileFilterConfig{}, "anything.bin", "", nil); err != nil {
+		t.Errorf("empty filter should allow everything, got %v", err)
+	}
+}
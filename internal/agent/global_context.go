@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+)
+
+// globalContextDir is where injected global files land inside the
+// container. It is under the praktor home so a refresh can rewrite them.
+const globalContextDir = "/home/praktor/.praktor/global"
+
+// resolveGlobalContext adds the current USER.md and global CLAUDE.md to the
+// container's files when defaults.inject_global_context is on, and points
+// the agent runner at them with GLOBAL_CONTEXT_DIR.
+func (o *Orchestrator) resolveGlobalContext(opts *container.AgentOpts) {
+	if !o.defaults().InjectGlobalContext {
+		return
+	}
+	userMD, claudeMD := o.globalContext()
+	opts.SecretFiles = append(opts.SecretFiles,
+		container.SecretFile{Content: []byte(userMD), Target: globalContextDir + "/USER.md", Mode: 0o644},
+		container.SecretFile{Content: []byte(claudeMD), Target: globalContextDir + "/CLAUDE.md", Mode: 0o644},
+	)
+	if opts.Env == nil {
+		opts.Env = make(map[string]string)
+	}
+	opts.Env["GLOBAL_CONTEXT_DIR"] = globalContextDir
+}
+
+// globalContext reads the host's global files. A file that can't be read is
+// logged and injected empty rather than failing the start.
+func (o *Orchestrator) globalContext() (userMD, claudeMD string) {
+	userMD, err := o.registry.GetUserMD()
+	if err != nil {
+		slog.Warn("failed to read user profile", "error", err)
+	}
+	claudeMD, err = o.registry.GetGlobalClaudeMD()
+	if err != nil {
+		slog.Warn("failed to read global instructions", "error", err)
+	}
+	return userMD, claudeMD
+}
+
+// SaveUserProfile writes USER.md and, when global context injection is on,
+// sends the new files to every running agent.
+func (o *Orchestrator) SaveUserProfile(content string) error {
+	if err := o.registry.SaveUserMD(content); err != nil {
+		return err
+	}
+	if o.defaults().InjectGlobalContext {
+		o.refreshGlobalContext()
+	}
+	return nil
+}
+
+// SaveGlobalInstructions writes the global CLAUDE.md and, when global
+// context injection is on, sends the new files to every running agent.
+func (o *Orchestrator) SaveGlobalInstructions(content string) error {
+	if err := o.registry.SaveGlobalClaudeMD(content); err != nil {
+		return err
+	}
+	if o.defaults().InjectGlobalContext {
+		o.refreshGlobalContext()
+	}
+	return nil
+}
+
+// refreshGlobalContext sends a refresh_global_context control command with
+// the current global files to each running agent. Agents that don't answer
+// pick the files up on their next start.
+func (o *Orchestrator) refreshGlobalContext() {
+	userMD, claudeMD := o.globalContext()
+	data, _ := json.Marshal(map[string]string{
+		"command":   "refresh_global_context",
+		"user_md":   userMD,
+		"claude_md": claudeMD,
+	})
+	var wg sync.WaitGroup
+	for _, agentID := range o.sessions.List() {
+		wg.Go(func() {
+			if _, err := o.client.Request(natsbus.TopicAgentControl(agentID), data, 5*time.Second); err != nil {
+				slog.Warn("failed to refresh global context", "agent", agentID, "error", err)
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrGroupNotFound is returned when no agent belongs to the named group.
+var ErrGroupNotFound = errors.New("agent group not found")
+
+// StartGroup starts every agent in group that isn't running, in parallel,
+// and returns the members. A member that fails to start doesn't stop the
+// others; the failures are joined into the returned error.
+func (o *Orchestrator) StartGroup(ctx context.Context, group string) ([]string, error) {
+	return o.forGroup(group, func(agentID string) error {
+		return o.EnsureAgent(ctx, agentID)
+	})
+}
+
+// StopGroup stops every agent in group and returns the members.
+func (o *Orchestrator) StopGroup(ctx context.Context, group string) ([]string, error) {
+	return o.forGroup(group, func(agentID string) error {
+		return o.stopAgent(ctx, agentID, "group")
+	})
+}
+
+func (o *Orchestrator) forGroup(group string, fn func(agentID string) error) ([]string, error) {
+	members := o.registry.GroupMembers(group)
+	if len(members) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, group)
+	}
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, agentID := range members {
+		wg.Go(func() {
+			if err := fn(agentID); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", agentID, err)
+			}
+		})
+	}
+	wg.Wait()
+	return members, errors.Join(errs...)
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+)
+
+func TestGroupStartStop(t *testing.T) {
+	o := newTestOrchestrator(t)
+	defs := map[string]config.AgentDefinition{
+		"data":    {Workspace: "data", Group: "analytics"},
+		"analyst": {Workspace: "analyst", Group: "analytics"},
+		"ops":     {Workspace: "ops", Group: "infra"},
+		"solo":    {Workspace: "solo"},
+	}
+	if err := o.registry.Update(defs, o.defaults()); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var started []string
+	o.startContainer = func(ctx context.Context, opts container.AgentOpts) (*container.ContainerInfo, error) {
+		mu.Lock()
+		started = append(started, opts.AgentID)
+		mu.Unlock()
+		_ = o.client.Publish(natsbus.TopicAgentReady(opts.AgentID), []byte(`{}`))
+		return &container.ContainerInfo{ID: "c-" + opts.AgentID, AgentID: opts.AgentID}, nil
+	}
+	ctx := context.Background()
+	if err := o.EnsureAgent(ctx, "solo"); err != nil {
+		t.Fatal(err)
+	}
+
+	members, err := o.StartGroup(ctx, "analytics")
+	if err != nil {
+		t.Fatalf("StartGroup: %v", err)
+	}
+	if !slices.Equal(members, []string{"analyst", "data"}) {
+		t.Errorf("members = %v", members)
+	}
+	slices.Sort(started)
+	if !slices.Equal(started, []string{"analyst", "data", "solo"}) {
+		t.Errorf("started = %v, want the group plus solo only", started)
+	}
+
+	if _, err := o.StopGroup(ctx, "analytics"); err != nil {
+		t.Fatalf("StopGroup: %v", err)
+	}
+	running := o.sessions.List()
+	slices.Sort(running)
+	if !slices.Equal(running, []string{"solo"}) {
+		t.Errorf("running after group stop = %v, want [solo]", running)
+	}
+
+	if _, err := o.StartGroup(ctx, "nope"); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("unknown group: got %v, want ErrGroupNotFound", err)
+	}
+}
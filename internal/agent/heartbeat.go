@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+)
+
+// StartHeartbeat pings every running agent on its control topic each
+// defaults.heartbeat.interval. An agent that misses failure_threshold
+// consecutive pings is hung (its process may be alive but it produces no
+// output, so the idle reaper never fires): it is marked unhealthy, its queue
+// is aborted and it is stopped.
+func (o *Orchestrator) StartHeartbeat(ctx context.Context) {
+	if o.defaults().Heartbeat.Interval <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(o.defaults().Heartbeat.Interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			o.checkHeartbeats(ctx)
+			interval := o.defaults().Heartbeat.Interval
+			if interval <= 0 {
+				interval = time.Minute // disabled by reload; re-check later
+			}
+			timer.Reset(interval)
+		}
+	}
+}
+
+// checkHeartbeats pings all agents with a running session in parallel.
+func (o *Orchestrator) checkHeartbeats(ctx context.Context) {
+	hb := o.defaults().Heartbeat
+	if hb.Interval <= 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, agentID := range o.sessions.List() {
+		wg.Go(func() {
+			o.checkHeartbeat(ctx, agentID, hb.Timeout, hb.FailureThreshold)
+		})
+	}
+	wg.Wait()
+}
+
+func (o *Orchestrator) checkHeartbeat(ctx context.Context, agentID string, timeout time.Duration, threshold int) {
+	data, _ := json.Marshal(map[string]string{"command": "ping"})
+	_, err := o.client.Request(natsbus.TopicAgentControl(agentID), data, timeout)
+
+	o.mu.Lock()
+	if err == nil {
+		delete(o.heartbeatFails, agentID)
+		o.mu.Unlock()
+		return
+	}
+	o.heartbeatFails[agentID]++
+	fails := o.heartbeatFails[agentID]
+	if fails >= threshold {
+		delete(o.heartbeatFails, agentID)
+	}
+	o.mu.Unlock()
+
+	slog.Warn("agent missed heartbeat", "agent", agentID, "failures", fails, "threshold", threshold, "error", err)
+	if fails < threshold {
+		return
+	}
+
+	ev := natsbus.NewLifecycleEvent(natsbus.LifecycleUnhealthy, agentID)
+	if s := o.sessions.Get(agentID); s != nil {
+		ev.ContainerID = s.ContainerID
+	}
+	ev.Reason = "heartbeat_timeout"
+	o.publishLifecycleEvent(ev)
+
+	slog.Error("stopping unresponsive agent", "agent", agentID, "failures", fails)
+	o.dropQueued(agentID)
+	if err := o.stopAgent(ctx, agentID, "unhealthy"); err != nil {
+		slog.Error("failed to stop unresponsive agent", "agent", agentID, "error", err)
+	}
+}
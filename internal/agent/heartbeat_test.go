@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/nats-io/nats.go"
+)
+
+func TestHeartbeatStopsUnresponsiveAgent(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	o.UpdateDefaults(config.DefaultsConfig{
+		MaxRunning: 5,
+		Heartbeat:  config.HeartbeatConfig{Interval: time.Second, Timeout: 50 * time.Millisecond, FailureThreshold: 2},
+	})
+	o.sessions.Set("alpha", &Session{AgentID: "alpha", ContainerID: "c1", Status: "running", LastActive: time.Now()})
+	o.getQueue("alpha").Enqueue(QueuedMessage{Text: "stuck behind a hung agent"})
+
+	client, err := natsbus.NewClient(o.bus)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	lifecycle := make(chan natsbus.LifecycleEvent, 10)
+	if _, err := client.Subscribe(natsbus.TopicAgentLifecycle("alpha"), func(msg *nats.Msg) {
+		var ev natsbus.LifecycleEvent
+		if err := json.Unmarshal(msg.Data, &ev); err == nil {
+			lifecycle <- ev
+		}
+	}); err != nil {
+		t.Fatalf("subscribe lifecycle: %v", err)
+	}
+
+	// Fake agent that answers pings until it hangs.
+	agent, err := client.Subscribe(natsbus.TopicAgentControl("alpha"), func(msg *nats.Msg) {
+		_ = msg.Respond([]byte(`{"status":"ok"}`))
+	})
+	if err != nil {
+		t.Fatalf("subscribe control: %v", err)
+	}
+	if err := client.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	ctx := context.Background()
+	for range 3 {
+		o.checkHeartbeats(ctx)
+	}
+	if o.sessions.Get("alpha") == nil {
+		t.Fatal("responsive agent was stopped")
+	}
+
+	// The agent stops responding.
+	if err := agent.Unsubscribe(); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	o.checkHeartbeats(ctx)
+	if o.sessions.Get("alpha") == nil {
+		t.Fatal("agent stopped before reaching the failure threshold")
+	}
+	o.checkHeartbeats(ctx)
+	if o.sessions.Get("alpha") != nil {
+		t.Fatal("expected unresponsive agent to be stopped")
+	}
+	if n := o.getQueue("alpha").Len(); n != 0 {
+		t.Errorf("expected queue aborted, got %d pending", n)
+	}
+
+	want := []string{natsbus.LifecycleUnhealthy, natsbus.LifecycleStopped}
+	for _, typ := range want {
+		select {
+		case ev := <-lifecycle:
+			if ev.Type != typ {
+				t.Fatalf("expected %s, got %s", typ, ev.Type)
+			}
+			if typ == natsbus.LifecycleUnhealthy && ev.Reason != "heartbeat_timeout" {
+				t.Errorf("expected reason heartbeat_timeout, got %q", ev.Reason)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %s", typ)
+		}
+	}
+}
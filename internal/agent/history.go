@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+// defaultHistoryMaxChars bounds injected history when history.max_chars is
+// unset.
+const defaultHistoryMaxChars = 8000
+
+func (o *Orchestrator) resolveHistory(agentID string) config.HistoryConfig {
+	if def, ok := o.registry.GetDefinition(agentID); ok && def.History != nil {
+		return *def.History
+	}
+	return o.defaults().History
+}
+
+// historyContext returns the stored turns preceding the message requestID,
+// formatted for the "history" payload key, or "" when injection is disabled
+// for the agent, its runner announced no support, or the message runs in an
+// isolated context (including summary-mode tasks, which carry their own).
+func (o *Orchestrator) historyContext(agentID string, requestID int64, meta map[string]string) string {
+	if mode := meta["context_mode"]; mode == "isolated" || mode == "summary" {
+		return ""
+	}
+	h := o.resolveHistory(agentID)
+	if h.Turns <= 0 || !o.supports(agentID, natsbus.CapHistoryInjection) {
+		return ""
+	}
+	// Reading strictly before requestID leaves out the message being sent,
+	// which HandleMessage has already stored.
+	msgs, err := o.store.GetMessagesBefore(agentID, requestID, h.Turns)
+	if err != nil {
+		slog.Warn("failed to load history", "agent", agentID, "error", err)
+		return ""
+	}
+	maxChars := h.MaxChars
+	if maxChars <= 0 {
+		maxChars = defaultHistoryMaxChars
+	}
+	return formatHistory(msgs, maxChars)
+}
+
+// formatHistory renders msgs (chronological) as "sender: content" lines,
+// keeping the most recent turns that fit in maxChars.
+func formatHistory(msgs []store.Message, maxChars int) string {
+	var lines []string
+	total := 0
+	for _, m := range slices.Backward(msgs) {
+		if m.Content == "" {
+			continue
+		}
+		line := m.Sender + ": " + m.Content
+		if total+len(line)+1 > maxChars {
+			break
+		}
+		total += len(line) + 1
+		lines = append(lines, line)
+	}
+	slices.Reverse(lines)
+	return strings.Join(lines, "\n")
+}
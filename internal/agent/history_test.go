@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+func TestHistoryContextInjectsPriorTurns(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	d := o.defaults()
+	d.History = config.HistoryConfig{Turns: 3}
+	o.UpdateDefaults(d)
+
+	for _, m := range []store.Message{
+		{Sender: "user", Content: "first question"},
+		{Sender: "agent", Content: "first answer"},
+		{Sender: "user", Content: "second question"},
+		{Sender: "agent", Content: "second answer"},
+	} {
+		m.AgentID = "alpha"
+		if err := o.store.SaveMessage(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	current := &store.Message{AgentID: "alpha", Sender: "user", Content: "current question"}
+	if err := o.store.SaveMessage(current); err != nil {
+		t.Fatal(err)
+	}
+
+	got := o.historyContext("alpha", current.ID, nil)
+	want := "agent: first answer\nuser: second question\nagent: second answer"
+	if got != want {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+	if strings.Contains(got, "current question") {
+		t.Error("history includes the message being sent")
+	}
+
+	if got := o.historyContext("alpha", current.ID, map[string]string{"context_mode": "isolated"}); got != "" {
+		t.Errorf("isolated message got history %q", got)
+	}
+}
+
+func TestHistoryContextPerAgentOverride(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	d := o.defaults()
+	d.History = config.HistoryConfig{Turns: 10}
+	o.UpdateDefaults(d)
+	agents := map[string]config.AgentDefinition{"alpha": {Workspace: "alpha", History: &config.HistoryConfig{}}}
+	if err := o.registry.Update(agents, o.defaults()); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = o.store.SaveMessage(&store.Message{AgentID: "alpha", Sender: "user", Content: "earlier"})
+	if got := o.historyContext("alpha", 0, nil); got != "" {
+		t.Errorf("history = %q, want none with turns: 0 on the agent", got)
+	}
+}
+
+func TestFormatHistoryCharBudget(t *testing.T) {
+	msgs := []store.Message{
+		{Sender: "user", Content: strings.Repeat("a", 50)},
+		{Sender: "agent", Content: ""},
+		{Sender: "agent", Content: "short"},
+		{Sender: "user", Content: "latest"},
+	}
+	// Room for the two newest lines but not the long oldest one.
+	got := formatHistory(msgs, 30)
+	if want := "agent: short\nuser: latest"; got != want {
+		t.Errorf("formatHistory = %q, want %q", got, want)
+	}
+	if got := formatHistory(msgs, 5); got != "" {
+		t.Errorf("formatHistory with a tiny budget = %q, want empty", got)
+	}
+}
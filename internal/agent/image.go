@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/container"
+)
+
+// ImageRefresh reports the agents an image refresh restarted.
+type ImageRefresh struct {
+	Image     string   `json:"image"`
+	ImageID   string   `json:"image_id"`
+	Restarted []string `json:"restarted"`
+}
+
+// RefreshImage restarts the running agents whose container was created from
+// image (defaults.image when empty) before the tag last changed, so their
+// next container runs the current build. Agents on another image, including
+// a pinned per-agent image, and swarm members are left alone. Each restart
+// drains in-flight messages like a config reload and publishes an
+// agent_restart event with reason image_refresh; at most
+// defaults.image_refresh_concurrency agents are restarting at a time. The
+// agents start again on their next message, or at once with eager.
+func (o *Orchestrator) RefreshImage(ctx context.Context, image string, eager bool) (*ImageRefresh, error) {
+	if image == "" {
+		image = o.defaults().Image
+	}
+	imageID, err := o.containers.ImageID(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	running, err := o.containers.ListRunning(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list running: %w", err)
+	}
+
+	targets := staleImageAgents(running, image, imageID, o.isDefinedAgent)
+	res := &ImageRefresh{Image: image, ImageID: imageID, Restarted: targets}
+	if len(targets) == 0 {
+		return res, nil
+	}
+	slog.Info("refreshing agent image", "image", image, "image_id", imageID, "agents", targets)
+	err = forEachLimited(targets, o.defaults().ImageRefreshConcurrency, func(agentID string) error {
+		if err := o.RestartAgent(ctx, agentID, "image_refresh"); err != nil {
+			return err
+		}
+		// Hold the slot until the old container is gone.
+		if err := o.waitForDrain(ctx, agentID); err != nil {
+			return err
+		}
+		if eager {
+			return o.EnsureAgent(ctx, agentID)
+		}
+		return nil
+	})
+	return res, err
+}
+
+func (o *Orchestrator) isDefinedAgent(agentID string) bool {
+	_, ok := o.registry.GetDefinition(agentID)
+	return ok
+}
+
+// staleImageAgents returns, sorted, the defined agents in running whose
+// container was created from image but not from its current id imageID.
+// Containers whose image id couldn't be read at start are skipped, so the
+// watcher doesn't restart them on every check.
+func staleImageAgents(running []container.ContainerInfo, image, imageID string, defined func(string) bool) []string {
+	stale := []string{}
+	for _, info := range running {
+		if info.Image != image || info.ImageID == "" || info.ImageID == imageID || !defined(info.AgentID) {
+			continue
+		}
+		stale = append(stale, info.AgentID)
+	}
+	slices.Sort(stale)
+	return stale
+}
+
+// forEachLimited runs fn for every agent, limit at a time (at least one).
+// A failure doesn't stop the others; the failures are joined.
+func forEachLimited(agentIDs []string, limit int, fn func(agentID string) error) error {
+	slots := make(chan struct{}, max(1, limit))
+	errs := make([]error, len(agentIDs))
+	var wg sync.WaitGroup
+	for i, agentID := range agentIDs {
+		slots <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			if err := fn(agentID); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", agentID, err)
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// StartImageWatcher refreshes the images of running agents every
+// defaults.image_check_interval, restarting agents whose image tag has been
+// rebuilt or pulled since their container started. The interval is re-read
+// after each check, so a reload can turn the watcher on or off.
+func (o *Orchestrator) StartImageWatcher(ctx context.Context) {
+	timer := time.NewTimer(o.imageCheckInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			o.checkImages(ctx)
+			timer.Reset(o.imageCheckInterval())
+		}
+	}
+}
+
+// imageCheckInterval is defaults.image_check_interval, or a minute while it
+// is off so that enabling it by reload takes effect.
+func (o *Orchestrator) imageCheckInterval() time.Duration {
+	if interval := o.defaults().ImageCheckInterval; interval > 0 {
+		return interval
+	}
+	return time.Minute
+}
+
+func (o *Orchestrator) checkImages(ctx context.Context) {
+	if o.defaults().ImageCheckInterval <= 0 {
+		return
+	}
+	running, err := o.containers.ListRunning(ctx)
+	if err != nil {
+		return
+	}
+	var images []string
+	for _, info := range running {
+		if info.Image != "" && o.isDefinedAgent(info.AgentID) && !slices.Contains(images, info.Image) {
+			images = append(images, info.Image)
+		}
+	}
+	for _, image := range images {
+		if _, err := o.RefreshImage(ctx, image, false); err != nil {
+			slog.Warn("image refresh failed", "image", image, "error", err)
+		}
+	}
+}
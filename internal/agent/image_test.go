@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/container"
+)
+
+func TestStaleImageAgents(t *testing.T) {
+	running := []container.ContainerInfo{
+		{AgentID: "alpha", Image: "praktor-agent:latest", ImageID: "sha256:old"},
+		{AgentID: "beta", Image: "custom:1", ImageID: "sha256:custom"}, // pinned image
+		{AgentID: "gamma", Image: "praktor-agent:latest", ImageID: "sha256:new"},
+		{AgentID: "delta", Image: "praktor-agent:latest"}, // id unknown
+		{AgentID: "swarm-x-member", Image: "praktor-agent:latest", ImageID: "sha256:old"},
+	}
+	defined := func(id string) bool { return id != "swarm-x-member" }
+
+	got := staleImageAgents(running, "praktor-agent:latest", "sha256:new", defined)
+	if !slices.Equal(got, []string{"alpha"}) {
+		t.Errorf("stale agents = %v, want [alpha]", got)
+	}
+	if got := staleImageAgents(running, "custom:1", "sha256:custom", defined); len(got) != 0 {
+		t.Errorf("pinned image unchanged, got %v", got)
+	}
+	if got := staleImageAgents(running, "custom:1", "sha256:custom2", defined); !slices.Equal(got, []string{"beta"}) {
+		t.Errorf("pinned image rebuilt, got %v", got)
+	}
+}
+
+func TestForEachLimited(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	ids := []string{"a", "b", "c", "d", "e"}
+	err := forEachLimited(ids, 2, func(id string) error {
+		n := inFlight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		inFlight.Add(-1)
+		if id == "c" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if peak.Load() > 2 {
+		t.Errorf("%d ran at once, want at most 2", peak.Load())
+	}
+	if err == nil || err.Error() != "c: boom" {
+		t.Errorf("err = %v, want c: boom", err)
+	}
+}
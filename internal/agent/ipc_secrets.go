@@ -3,12 +3,17 @@ package agent
 import (
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/mtzanidakis/praktor/internal/config"
 	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/dotenv"
+	"github.com/mtzanidakis/praktor/internal/events"
 	"github.com/mtzanidakis/praktor/internal/extensions"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
 )
 
 const secretRefPrefix = "secret:"
@@ -50,6 +55,10 @@ func (o *Orchestrator) resolveSecrets(opts *container.AgentOpts, agentID string,
 		opts.Env[k] = string(plaintext)
 	}
 
+	if def.EnvFileSecret != "" {
+		o.resolveEnvFile(opts, agentID, def, accessible[def.EnvFileSecret])
+	}
+
 	// Prepare file secrets
 	for _, fm := range def.Files {
 		if !accessible[fm.Secret] {
@@ -78,6 +87,43 @@ func (o *Orchestrator) resolveSecrets(opts *container.AgentOpts, agentID string,
 	}
 }
 
+// resolveEnvFile adds the variables of the agent's env_file_secret, a
+// dotenv file, to opts.Env. Keys set in the agent's env win, including
+// secret: refs that were denied or failed to resolve.
+func (o *Orchestrator) resolveEnvFile(opts *container.AgentOpts, agentID string, def config.AgentDefinition, accessible bool) {
+	if !accessible {
+		slog.Error("secret access denied: agent not authorized",
+			"agent", agentID, "env_file_secret", def.EnvFileSecret)
+		return
+	}
+	vars, err := o.envFileVars(agentID, def.EnvFileSecret)
+	if err != nil {
+		slog.Warn("failed to resolve env file secret", "agent", agentID, "secret", def.EnvFileSecret, "error", err)
+		return
+	}
+	if opts.Env == nil {
+		opts.Env = make(map[string]string, len(vars))
+	}
+	for k, v := range vars {
+		if _, set := def.Env[k]; !set {
+			opts.Env[k] = v
+		}
+	}
+}
+
+// envFileVars decrypts and parses the dotenv secret name.
+func (o *Orchestrator) envFileVars(agentID, name string) (map[string]string, error) {
+	plaintext, err := o.decryptSecret(agentID, name)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := dotenv.Map(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("parse env file secret %q: %w", name, err)
+	}
+	return vars, nil
+}
+
 func (o *Orchestrator) decryptSecret(agentID, name string) ([]byte, error) {
 	sec, err := o.store.GetAgentSecretByName(agentID, name)
 	if err != nil {
@@ -86,20 +132,60 @@ func (o *Orchestrator) decryptSecret(agentID, name string) ([]byte, error) {
 	if sec == nil {
 		return nil, fmt.Errorf("secret %q not found or not accessible by agent %q", name, agentID)
 	}
-	return o.vault.Decrypt(sec.Value, sec.Nonce)
+	plaintext, err := o.vault.Decrypt(sec.Value, sec.Nonce)
+	o.noteDecrypt(agentID, name, err)
+	return plaintext, err
+}
+
+// noteDecrypt tracks which secrets fail to decrypt, which usually means
+// the vault passphrase changed. A secret that starts failing is logged and
+// gets a secret_decrypt_failed event carrying the running count; repeats
+// (redaction decrypts on every output) stay quiet.
+func (o *Orchestrator) noteDecrypt(agentID, name string, err error) {
+	o.mu.Lock()
+	if err == nil || o.decryptFailed[name] {
+		if err == nil {
+			delete(o.decryptFailed, name)
+		}
+		o.mu.Unlock()
+		return
+	}
+	o.decryptFailed[name] = true
+	failed := len(o.decryptFailed)
+	o.mu.Unlock()
+
+	slog.Error("secret failed to decrypt, check the vault passphrase", "agent", agentID, "secret", name, "error", err)
+	if o.client == nil {
+		return
+	}
+	_ = o.client.PublishJSON(natsbus.TopicEventsAgent(agentID), events.NewSecretDecryptFailed(agentID, name, failed))
+}
+
+// DecryptFailures returns the names of the secrets whose last decryption
+// failed, sorted.
+func (o *Orchestrator) DecryptFailures() []string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return slices.Sorted(maps.Keys(o.decryptFailed))
 }
 
 // redactSecrets replaces any plaintext secret values found in content with
 // [REDACTED]. This is a hard security barrier that prevents secret leakage
 // regardless of LLM behavior. Only secrets with values >= 8 bytes are checked
 // to avoid false positives with short strings.
+func (o *Orchestrator) redactSecrets(agentID, content string) string {
+	return o.secretRedactor(agentID)(content)
+}
+
+// secretRedactor decrypts the agent's secrets once and returns a function
+// that redacts them, for callers that redact more than one text.
 //
 // Secrets are collected from two sources:
 // 1. DB agent_secrets assignments + global secrets
-// 2. YAML config: secret:name env var refs + files section
-func (o *Orchestrator) redactSecrets(agentID, content string) string {
+// 2. YAML config: secret:name env var refs + files section + env_file_secret
+func (o *Orchestrator) secretRedactor(agentID string) func(content string) string {
 	if o.vault == nil {
-		return content
+		return func(content string) string { return content }
 	}
 
 	// Collect unique secret names to decrypt
@@ -113,53 +199,59 @@ func (o *Orchestrator) redactSecrets(agentID, content string) string {
 	}
 
 	// Source 2: YAML config references
+	var envFile string
 	if def, ok := o.registry.GetDefinition(agentID); ok {
-		for _, v := range def.Env {
-			if strings.HasPrefix(v, secretRefPrefix) {
-				secretNames[strings.TrimPrefix(v, secretRefPrefix)] = true
-			}
-		}
-		for _, fm := range def.Files {
-			secretNames[fm.Secret] = true
+		envFile = def.EnvFileSecret
+		for _, name := range def.SecretRefs() {
+			secretNames[name] = true
 		}
 	}
 
 	// Source 3: Extension MCP server env/header secret refs
 	if extJSON, err := o.store.GetAgentExtensions(agentID); err == nil {
 		if ext, err := extensions.Parse(extJSON); err == nil {
-			for _, srv := range ext.MCPServers {
-				for _, v := range srv.Env {
-					if name, ok := strings.CutPrefix(v, secretRefPrefix); ok {
-						secretNames[name] = true
-					}
-				}
-				for _, v := range srv.Headers {
-					if name, ok := strings.CutPrefix(v, secretRefPrefix); ok {
-						secretNames[name] = true
-					}
-				}
+			for _, name := range ext.SecretRefs() {
+				secretNames[name] = true
 			}
 		}
 	}
 
-	// Decrypt and redact vault secrets
+	// Decrypt vault secrets
+	type secretValue struct{ label, value string }
+	var values []secretValue
 	for name := range secretNames {
 		plaintext, err := o.decryptSecret(agentID, name)
 		if err != nil || len(plaintext) < 8 {
 			continue
 		}
-		content = redactValue(content, string(plaintext), name, agentID)
+		values = append(values, secretValue{name, string(plaintext)})
 	}
-
-	// Redact global credentials injected into all containers
-	for _, v := range []string{o.cfg.OAuthToken, o.cfg.AnthropicAPIKey} {
-		if len(v) >= 8 && strings.Contains(content, v) {
-			slog.Warn("redacted credential from agent output", "agent", agentID)
-			content = strings.ReplaceAll(content, v, "[REDACTED]")
+	if envFile != "" {
+		if vars, err := o.envFileVars(agentID, envFile); err == nil {
+			for k, v := range vars {
+				if len(v) >= 8 {
+					values = append(values, secretValue{envFile + "." + k, v})
+				}
+			}
 		}
 	}
 
-	return content
+	// Global credentials injected into all containers
+	cfg := o.defaults()
+	creds := []string{cfg.OAuthToken, cfg.AnthropicAPIKey}
+
+	return func(content string) string {
+		for _, v := range values {
+			content = redactValue(content, v.value, v.label, agentID)
+		}
+		for _, v := range creds {
+			if len(v) >= 8 && strings.Contains(content, v) {
+				slog.Warn("redacted credential from agent output", "agent", agentID)
+				content = strings.ReplaceAll(content, v, "[REDACTED]")
+			}
+		}
+		return content
+	}
 }
 
 // redactValue replaces a secret value in content. It tries exact match first,
@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/vault"
+)
+
+func TestDecryptFailures(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	ct, nonce, err := vault.New("old passphrase").Encrypt([]byte("s3cret-value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.store.SaveSecret(&store.Secret{ID: "token", Name: "token", Kind: "string", Value: ct, Nonce: nonce, Global: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	o.vault = vault.New("new passphrase")
+	for range 2 {
+		if _, err := o.decryptSecret("alpha", "token"); err == nil {
+			t.Fatal("expected decryption with the wrong passphrase to fail")
+		}
+	}
+	if got := o.DecryptFailures(); !slices.Equal(got, []string{"token"}) {
+		t.Errorf("DecryptFailures() = %v, want [token]", got)
+	}
+
+	o.vault = vault.New("old passphrase")
+	if _, err := o.decryptSecret("alpha", "token"); err != nil {
+		t.Fatalf("decrypt with the right passphrase: %v", err)
+	}
+	if got := o.DecryptFailures(); len(got) != 0 {
+		t.Errorf("DecryptFailures() after success = %v, want none", got)
+	}
+}
+
+func TestEnvFileSecret(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	o.vault = vault.New("passphrase")
+	dotenvFile := "# service credentials\nAPI_TOKEN=\"tok-from-env-file\"\nAPI_URL=https://api.example.com\nREGION=eu-west-1\n"
+	ct, nonce, err := o.vault.Encrypt([]byte(dotenvFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.store.SaveSecret(&store.Secret{ID: "svc-env", Name: "svc-env", Kind: "file", Value: ct, Nonce: nonce}); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.store.AddAgentSecret("alpha", "svc-env"); err != nil {
+		t.Fatal(err)
+	}
+	defs := map[string]config.AgentDefinition{"alpha": {
+		Workspace:     "alpha",
+		Env:           map[string]string{"REGION": "us-east-1"},
+		EnvFileSecret: "svc-env",
+	}}
+	if err := o.registry.Update(defs, o.defaults()); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := o.agentOpts("alpha", startOverrides{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(opts.Env, "AGENT_EXTENSIONS")
+	want := map[string]string{"API_TOKEN": "tok-from-env-file", "API_URL": "https://api.example.com", "REGION": "us-east-1"}
+	if !maps.Equal(opts.Env, want) {
+		t.Errorf("env = %v, want %v", opts.Env, want)
+	}
+
+	got := o.redactSecrets("alpha", "token tok-from-env-file at https://api.example.com in eu-west-1")
+	if got != "token [REDACTED] at [REDACTED] in [REDACTED]" {
+		t.Errorf("redacted = %q", got)
+	}
+}
+
+func TestEnvFileSecretNotAssigned(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	o.vault = vault.New("passphrase")
+	ct, nonce, err := o.vault.Encrypt([]byte("API_TOKEN=tok-from-env-file\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.store.SaveSecret(&store.Secret{ID: "svc-env", Name: "svc-env", Kind: "file", Value: ct, Nonce: nonce}); err != nil {
+		t.Fatal(err)
+	}
+	defs := map[string]config.AgentDefinition{"alpha": {Workspace: "alpha", EnvFileSecret: "svc-env"}}
+	if err := o.registry.Update(defs, o.defaults()); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := o.agentOpts("alpha", startOverrides{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := opts.Env["API_TOKEN"]; ok {
+		t.Errorf("env = %v, want no env file values for an unassigned secret", opts.Env)
+	}
+}
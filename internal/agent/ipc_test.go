@@ -0,0 +1,332 @@
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/mtzanidakis/praktor/internal/schedule"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+func sendTestIPC(t *testing.T, o *Orchestrator, agentID, cmdType string, payload map[string]any) map[string]any {
+	t.Helper()
+	return sendTestIPCPayload(t, o, agentID, cmdType, payload)
+}
+
+// sendTestIPCPayload is sendTestIPC for payloads that aren't objects.
+func sendTestIPCPayload(t *testing.T, o *Orchestrator, agentID, cmdType string, payload any) map[string]any {
+	t.Helper()
+	client, err := natsbus.NewClient(o.bus)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	p, _ := json.Marshal(payload)
+	data, _ := json.Marshal(IPCCommand{Type: cmdType, Payload: p})
+	resp, err := client.Request(natsbus.TopicIPC(agentID), data, 5*time.Second)
+	if err != nil {
+		t.Fatalf("%s request: %v", cmdType, err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(resp.Data, &out); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	return out
+}
+
+func TestIPCPauseResumeOwnTask(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	hourly, err := schedule.NormalizeSchedule("@hourly")
+	if err != nil {
+		t.Fatalf("normalize schedule: %v", err)
+	}
+	task := &store.ScheduledTask{ID: "t1", AgentID: "alpha", Name: "hourly", Schedule: hourly, Prompt: "p", Status: "active"}
+	if err := o.store.SaveTask(task); err != nil {
+		t.Fatalf("save task: %v", err)
+	}
+
+	if resp := sendTestIPC(t, o, "alpha", "pause_task", map[string]any{"id": "t1"}); resp["ok"] != true {
+		t.Fatalf("pause failed: %v", resp)
+	}
+	got, _ := o.store.GetTask("t1")
+	if got.Status != "paused" {
+		t.Fatalf("expected paused, got %s", got.Status)
+	}
+
+	if resp := sendTestIPC(t, o, "alpha", "resume_task", map[string]any{"id": "t1"}); resp["ok"] != true {
+		t.Fatalf("resume failed: %v", resp)
+	}
+	got, _ = o.store.GetTask("t1")
+	if got.Status != "active" {
+		t.Fatalf("expected active, got %s", got.Status)
+	}
+	if got.NextRunAt == nil || !got.NextRunAt.After(time.Now()) {
+		t.Errorf("expected next_run_at recomputed into the future, got %v", got.NextRunAt)
+	}
+}
+
+func TestIPCListTasksRunFields(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	hourly, err := schedule.NormalizeSchedule("@hourly")
+	if err != nil {
+		t.Fatalf("normalize schedule: %v", err)
+	}
+	for _, id := range []string{"ran", "fresh"} {
+		if err := o.store.SaveTask(&store.ScheduledTask{ID: id, AgentID: "alpha", Name: id, Schedule: hourly, Prompt: "p", Status: "active"}); err != nil {
+			t.Fatalf("save task: %v", err)
+		}
+	}
+	next := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := o.store.UpdateTaskRun("ran", "success", "", &next); err != nil {
+		t.Fatalf("update run: %v", err)
+	}
+
+	resp := sendTestIPC(t, o, "alpha", "list_tasks", map[string]any{})
+	tasks, _ := resp["tasks"].([]any)
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %v", resp)
+	}
+	byID := make(map[string]map[string]any)
+	for _, raw := range tasks {
+		e := raw.(map[string]any)
+		byID[e["id"].(string)] = e
+	}
+
+	ran := byID["ran"]
+	got, err := time.Parse(time.RFC3339, fmt.Sprint(ran["next_run"]))
+	if err != nil || !got.Equal(next) {
+		t.Errorf("next_run = %v, want %v", ran["next_run"], next.UTC().Format(time.RFC3339))
+	}
+	if _, err := time.Parse(time.RFC3339, fmt.Sprint(ran["last_run"])); err != nil {
+		t.Errorf("last_run = %v, want an RFC 3339 time", ran["last_run"])
+	}
+	if ran["last_status"] != "success" {
+		t.Errorf("last_status = %v, want success", ran["last_status"])
+	}
+
+	// Fields without a value are left out rather than sent empty.
+	for _, k := range []string{"last_run", "last_status"} {
+		if _, ok := byID["fresh"][k]; ok {
+			t.Errorf("task that never ran has %s: %v", k, byID["fresh"][k])
+		}
+	}
+}
+
+func TestIPCPauseOtherAgentsTask(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha", "beta")
+	task := &store.ScheduledTask{ID: "t1", AgentID: "beta", Name: "hourly", Schedule: "@hourly", Prompt: "p", Status: "active"}
+	if err := o.store.SaveTask(task); err != nil {
+		t.Fatalf("save task: %v", err)
+	}
+
+	for _, cmd := range []string{"pause_task", "resume_task"} {
+		resp := sendTestIPC(t, o, "alpha", cmd, map[string]any{"id": "t1"})
+		if resp["ok"] == true || resp["error"] == nil {
+			t.Errorf("%s: expected rejection, got %v", cmd, resp)
+		}
+	}
+	got, _ := o.store.GetTask("t1")
+	if got.Status != "active" {
+		t.Errorf("expected other agent's task untouched, got %s", got.Status)
+	}
+
+	if resp := sendTestIPC(t, o, "alpha", "pause_task", map[string]any{"id": "missing"}); resp["error"] == nil {
+		t.Errorf("expected error for missing task, got %v", resp)
+	}
+}
+
+func TestIPCSendFileFilter(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	defaults := o.defaults()
+	defaults.FileFilter = config.FileFilterConfig{DeniedExtensions: []string{".sh"}, DeniedMIMETypes: []string{"text/html"}}
+	o.UpdateDefaults(defaults)
+	o.mu.Lock()
+	o.lastMeta["alpha"] = map[string]string{"chat_id": "42"}
+	o.mu.Unlock()
+
+	var sent []string
+	o.OnFile(func(agentID string, chatID int64, data []byte, name, mimeType, caption string, meta map[string]string) {
+		sent = append(sent, name)
+	})
+	data := base64.StdEncoding.EncodeToString([]byte("%PDF-1.4"))
+
+	resp := sendTestIPC(t, o, "alpha", "send_file", map[string]any{
+		"name": "../../reports/summary.pdf", "data": data, "mime_type": "application/pdf",
+	})
+	if resp["ok"] != true {
+		t.Fatalf("expected pdf to be sent, got %v", resp)
+	}
+	if len(sent) != 1 || sent[0] != "summary.pdf" {
+		t.Fatalf("expected sanitized name summary.pdf, got %v", sent)
+	}
+
+	resp = sendTestIPC(t, o, "alpha", "send_file", map[string]any{
+		"name": "install.sh", "data": data, "mime_type": "text/plain",
+	})
+	if resp["error"] != `file extension ".sh" is not allowed` {
+		t.Fatalf("expected .sh to be rejected, got %v", resp)
+	}
+
+	resp = sendTestIPC(t, o, "alpha", "send_file", map[string]any{
+		"name": "notes.txt", "data": base64.StdEncoding.EncodeToString([]byte("<html><body>hi</body></html>")), "mime_type": "text/plain",
+	})
+	if resp["error"] != `file content type "text/html" is not allowed` {
+		t.Fatalf("expected html content to be rejected, got %v", resp)
+	}
+	if len(sent) != 1 {
+		t.Errorf("blocked file reached listeners: %v", sent)
+	}
+}
+
+func TestIPCSendFileSizeLimit(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	defaults := o.defaults()
+	defaults.MaxFileSizeMB = 1
+	o.UpdateDefaults(defaults)
+	o.mu.Lock()
+	o.lastMeta["alpha"] = map[string]string{"chat_id": "42"}
+	o.mu.Unlock()
+
+	var sent []string
+	o.OnFile(func(agentID string, chatID int64, data []byte, name, mimeType, caption string, meta map[string]string) {
+		sent = append(sent, name)
+	})
+	var limits []int64
+	o.readVolume = func(_ context.Context, workspace, filePath, _ string, maxSize int64) ([]byte, error) {
+		limits = append(limits, maxSize)
+		if filePath == "out/big.bin" {
+			return nil, fmt.Errorf("read: %w", container.ErrFileTooLarge)
+		}
+		return []byte("small"), nil
+	}
+	send := func(payload map[string]any) map[string]any {
+		return sendTestIPC(t, o, "alpha", "send_file", payload)
+	}
+	const tooLarge = "file too large (max 1 MB)"
+
+	// Embedded: the limit is exact on the decoded bytes.
+	if resp := send(map[string]any{"name": "fit.bin", "data": base64.StdEncoding.EncodeToString(make([]byte, 1<<20))}); resp["ok"] != true {
+		t.Errorf("1 MB embedded file: %v, want ok", resp)
+	}
+	if resp := send(map[string]any{"name": "over.bin", "data": base64.StdEncoding.EncodeToString(make([]byte, 1<<20+1))}); resp["error"] != tooLarge {
+		t.Errorf("1 MB + 1 embedded file: %v, want %q", resp, tooLarge)
+	}
+
+	// Path: the limit is handed to the volume read, which enforces it.
+	if resp := send(map[string]any{"name": "small.bin", "path": "/workspace/agent/out/small.bin"}); resp["ok"] != true {
+		t.Errorf("small path file: %v, want ok", resp)
+	}
+	if resp := send(map[string]any{"name": "big.bin", "path": "out/big.bin"}); resp["error"] != tooLarge {
+		t.Errorf("big path file: %v, want %q", resp, tooLarge)
+	}
+	if len(limits) != 2 || limits[0] != 1<<20 || limits[1] != 1<<20 {
+		t.Errorf("volume read limits = %v, want [1 MB, 1 MB]", limits)
+	}
+	if len(sent) != 2 || sent[0] != "fit.bin" || sent[1] != "small.bin" {
+		t.Errorf("sent = %v, want [fit.bin small.bin]", sent)
+	}
+}
+
+func TestIPCReadHistory(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha", "beta")
+	for i := range 5 {
+		_ = o.store.SaveMessage(&store.Message{AgentID: "alpha", Sender: "user", Content: fmt.Sprintf("alpha %d", i)})
+	}
+	_ = o.store.SaveMessage(&store.Message{AgentID: "beta", Sender: "user", Content: "beta secret"})
+
+	resp := sendTestIPC(t, o, "alpha", "read_history", map[string]any{"limit": 3})
+	msgs, _ := resp["messages"].([]any)
+	if resp["ok"] != true || len(msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %v", resp)
+	}
+	last := msgs[2].(map[string]any)
+	if last["content"] != "alpha 4" {
+		t.Errorf("expected newest message last, got %v", last["content"])
+	}
+
+	// Page back from the oldest returned message.
+	first := msgs[0].(map[string]any)
+	resp = sendTestIPC(t, o, "alpha", "read_history", map[string]any{"before": first["id"], "limit": 10})
+	older, _ := resp["messages"].([]any)
+	if len(older) != 2 {
+		t.Errorf("expected 2 older messages, got %d", len(older))
+	}
+	for _, m := range append(msgs, older...) {
+		if c := m.(map[string]any)["content"].(string); strings.HasPrefix(c, "beta") {
+			t.Errorf("read_history leaked another agent's message %q", c)
+		}
+	}
+}
+
+func TestIPCReadHistoryLimitCapped(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	for i := range maxReadHistoryLimit + 5 {
+		_ = o.store.SaveMessage(&store.Message{AgentID: "alpha", Sender: "user", Content: fmt.Sprintf("m%d", i)})
+	}
+
+	resp := sendTestIPC(t, o, "alpha", "read_history", map[string]any{"limit": 10000})
+	msgs, _ := resp["messages"].([]any)
+	if len(msgs) != maxReadHistoryLimit {
+		t.Errorf("expected %d messages, got %d", maxReadHistoryLimit, len(msgs))
+	}
+}
+
+func TestIPCListAgents(t *testing.T) {
+	o := newTestOrchestrator(t)
+	if err := o.registry.Update(map[string]config.AgentDefinition{
+		"alpha": {Workspace: "alpha", Description: "Writes code", Model: "claude-secret-model", Env: map[string]string{"API_TOKEN": "s3cret"}, Tags: []string{"team:dev"}},
+		"beta":  {Workspace: "beta", Description: "Reviews code", Image: "private/registry:tag", Group: "review"},
+	}, o.cfg); err != nil {
+		t.Fatalf("update registry: %v", err)
+	}
+	o.sessions.Set("beta", &Session{AgentID: "beta", Status: "running"})
+
+	resp := sendTestIPC(t, o, "alpha", "list_agents", map[string]any{})
+	if resp["ok"] != true {
+		t.Fatalf("list_agents failed: %v", resp)
+	}
+	raw, _ := json.Marshal(resp)
+	for _, secret := range []string{"s3cret", "API_TOKEN", "claude-secret-model", "private/registry", "workspace", "claude_md"} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("response leaks %q: %s", secret, raw)
+		}
+	}
+	byID := make(map[string]map[string]any)
+	for _, e := range resp["agents"].([]any) {
+		a := e.(map[string]any)
+		byID[a["id"].(string)] = a
+	}
+	if len(byID) != 2 {
+		t.Fatalf("expected 2 agents, got %v", resp["agents"])
+	}
+	if byID["alpha"]["description"] != "Writes code" || byID["alpha"]["self"] != true || byID["alpha"]["running"] != false {
+		t.Errorf("alpha = %v", byID["alpha"])
+	}
+	if byID["beta"]["description"] != "Reviews code" || byID["beta"]["running"] != true || byID["beta"]["group"] != "review" {
+		t.Errorf("beta = %v", byID["beta"])
+	}
+
+	for _, tc := range []struct {
+		payload map[string]any
+		want    string
+	}{
+		{map[string]any{"running": true}, "beta"},
+		{map[string]any{"group": "review"}, "beta"},
+		{map[string]any{"tags": []string{"team:dev"}}, "alpha"},
+	} {
+		resp := sendTestIPC(t, o, "alpha", "list_agents", tc.payload)
+		agents, _ := resp["agents"].([]any)
+		if len(agents) != 1 || agents[0].(map[string]any)["id"] != tc.want {
+			t.Errorf("list_agents %v = %v, want only %s", tc.payload, agents, tc.want)
+		}
+	}
+}
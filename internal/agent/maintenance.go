@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// maintenanceWindow is an agent's open maintenance window.
+type maintenanceWindow struct {
+	start time.Time // when the window was first entered
+	until time.Time
+}
+
+// ipcEnterMaintenance opens a maintenance window for the calling agent:
+// until it exits, the window ends or the agent is stopped, the idle reaper
+// and the nix GC sweep leave it alone. The window lasts the requested
+// duration, defaulting to defaults.maintenance_max_duration. Entering again
+// while in maintenance moves the end time, but never past the cap counted
+// from when the window opened, so an agent can't keep itself in
+// maintenance.
+func (o *Orchestrator) ipcEnterMaintenance(msg *nats.Msg, agentID string, payload json.RawMessage) {
+	var req struct {
+		Duration string `json:"duration"`
+		Reason   string `json:"reason"`
+	}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &req); err != nil {
+			o.respondIPC(msg, map[string]any{"error": "invalid payload"})
+			return
+		}
+	}
+	limit := o.defaults().MaintenanceMaxDuration
+	d, capped := limit, false
+	if req.Duration != "" {
+		parsed, err := time.ParseDuration(req.Duration)
+		if err != nil || parsed <= 0 {
+			o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("invalid duration %q", req.Duration)})
+			return
+		}
+		d, capped = min(parsed, limit), parsed > limit
+	}
+
+	now := time.Now()
+	o.mu.Lock()
+	w, open := o.maintenance[agentID]
+	if !open || !now.Before(w.until) {
+		w.start = now
+	}
+	w.until = now.Add(d)
+	if end := w.start.Add(limit); w.until.After(end) {
+		w.until, capped = end, true
+	}
+	o.maintenance[agentID] = w
+	o.mu.Unlock()
+	until := w.until
+	slog.Info("agent entered maintenance", "agent", agentID, "until", until, "reason", req.Reason)
+	o.respondIPC(msg, map[string]any{"ok": true, "until": until.UTC().Format(time.RFC3339), "capped": capped})
+}
+
+// ipcExitMaintenance closes the calling agent's maintenance window.
+func (o *Orchestrator) ipcExitMaintenance(msg *nats.Msg, agentID string) {
+	o.mu.Lock()
+	_, was := o.maintenance[agentID]
+	delete(o.maintenance, agentID)
+	o.mu.Unlock()
+	if was {
+		slog.Info("agent exited maintenance", "agent", agentID)
+	}
+	o.respondIPC(msg, map[string]any{"ok": true, "was_in_maintenance": was})
+}
+
+// inMaintenance reports whether the agent's maintenance window is open at
+// now, forgetting a window that has ended.
+func (o *Orchestrator) inMaintenance(agentID string, now time.Time) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	w, ok := o.maintenance[agentID]
+	if !ok {
+		return false
+	}
+	if now.Before(w.until) {
+		return true
+	}
+	delete(o.maintenance, agentID)
+	slog.Warn("agent maintenance window expired", "agent", agentID, "until", w.until)
+	return false
+}
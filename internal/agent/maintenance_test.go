@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/container"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha", "beta")
+	cfg := o.defaults()
+	cfg.MaintenanceMaxDuration = time.Hour
+	o.UpdateDefaults(cfg)
+
+	if resp := sendTestIPC(t, o, "alpha", "enter_maintenance", map[string]any{"duration": "soon"}); resp["error"] == nil {
+		t.Errorf("invalid duration accepted: %v", resp)
+	}
+	resp := sendTestIPC(t, o, "alpha", "enter_maintenance", map[string]any{"duration": "3h", "reason": "batch import"})
+	if resp["ok"] != true || resp["capped"] != true {
+		t.Fatalf("enter_maintenance = %v, want ok and capped", resp)
+	}
+	now := time.Now()
+	if !o.inMaintenance("alpha", now) {
+		t.Fatal("agent not in maintenance after entering it")
+	}
+	if o.inMaintenance("beta", now) {
+		t.Error("another agent's window covers beta")
+	}
+
+	// The window ends at the cap, not at the requested 3h.
+	if !o.inMaintenance("alpha", now.Add(59*time.Minute)) {
+		t.Error("window closed before the cap")
+	}
+	if o.inMaintenance("alpha", now.Add(61*time.Minute)) {
+		t.Error("window still open past the cap")
+	}
+	if o.inMaintenance("alpha", now) {
+		t.Error("expired window wasn't forgotten")
+	}
+
+	sendTestIPC(t, o, "alpha", "enter_maintenance", nil)
+	if resp := sendTestIPC(t, o, "alpha", "exit_maintenance", nil); resp["was_in_maintenance"] != true {
+		t.Errorf("exit_maintenance = %v", resp)
+	}
+	if o.inMaintenance("alpha", time.Now()) {
+		t.Error("agent still in maintenance after exiting it")
+	}
+}
+
+func TestMaintenanceCapCountsFromStart(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	cfg := o.defaults()
+	cfg.MaintenanceMaxDuration = time.Hour
+	o.UpdateDefaults(cfg)
+
+	// A window opened 50 minutes ago can only be extended to the hour.
+	start := time.Now().Add(-50 * time.Minute)
+	o.mu.Lock()
+	o.maintenance["alpha"] = maintenanceWindow{start: start, until: start.Add(55 * time.Minute)}
+	o.mu.Unlock()
+	resp := sendTestIPC(t, o, "alpha", "enter_maintenance", map[string]any{"duration": "30m"})
+	if resp["ok"] != true || resp["capped"] != true {
+		t.Fatalf("enter_maintenance = %v, want ok and capped", resp)
+	}
+	o.mu.RLock()
+	w := o.maintenance["alpha"]
+	o.mu.RUnlock()
+	if !w.start.Equal(start) || !w.until.Equal(start.Add(time.Hour)) {
+		t.Errorf("window = %v to %v, want %v to %v", w.start, w.until, start, start.Add(time.Hour))
+	}
+
+	// Stopping the agent closes its window.
+	_ = o.stopAgent(context.Background(), "alpha", "user")
+	if o.inMaintenance("alpha", time.Now()) {
+		t.Error("stopped agent still in maintenance")
+	}
+}
+
+func TestMaintenanceSkipsReaperAndGC(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha", "beta")
+	cfg := o.defaults() // idle_timeout 1ms
+	cfg.MaintenanceMaxDuration = time.Hour
+	o.UpdateDefaults(cfg)
+	sendTestIPC(t, o, "alpha", "enter_maintenance", map[string]any{"duration": "30m"})
+
+	idleSince := time.Now().Add(-time.Minute)
+	for _, id := range []string{"alpha", "beta"} {
+		o.sessions.Set(id, &Session{AgentID: id, Status: "running", StartedAt: idleSince, LastActive: idleSince})
+	}
+	o.reapIdle(context.Background())
+	if o.sessions.Get("alpha") == nil {
+		t.Error("agent in maintenance was reaped")
+	}
+	if o.sessions.Get("beta") != nil {
+		t.Error("idle agent outside maintenance wasn't reaped")
+	}
+
+	var started []string
+	o.startContainer = func(ctx context.Context, opts container.AgentOpts) (*container.ContainerInfo, error) {
+		started = append(started, opts.AgentID)
+		return nil, context.Canceled
+	}
+	o.nixGCAgent(context.Background(), "alpha")
+	if len(started) != 0 {
+		t.Errorf("nix GC started %v for an agent in maintenance", started)
+	}
+	o.nixGCAgent(context.Background(), "beta")
+	if len(started) != 1 || started[0] != "beta" {
+		t.Errorf("nix GC started %v, want [beta]", started)
+	}
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ErrMessageTooLarge is returned by HandleMessage when a message exceeds
+// defaults.max_message_bytes and defaults.oversized_input is "reject".
+var ErrMessageTooLarge = errors.New("message too large")
+
+// truncationMarker ends every message cut down to max_message_bytes.
+func truncationMarker(total int) string {
+	return fmt.Sprintf("\n\n[… truncated, %d bytes total]", total)
+}
+
+// truncateMessage cuts text to at most limit bytes, marker included, without
+// splitting a UTF-8 sequence. Text within the limit is returned unchanged.
+func truncateMessage(text string, limit int) (string, bool) {
+	if limit <= 0 || len(text) <= limit {
+		return text, false
+	}
+	marker := truncationMarker(len(text))
+	keep := max(0, limit-len(marker))
+	for keep > 0 && !utf8.RuneStart(text[keep]) {
+		keep--
+	}
+	return text[:keep] + marker, true
+}
+
+// limitInbound applies max_message_bytes to a message about to be stored
+// and queued for agentID.
+func (o *Orchestrator) limitInbound(agentID, text string) (string, error) {
+	cfg := o.defaults()
+	if cfg.MaxMessageBytes <= 0 || len(text) <= cfg.MaxMessageBytes {
+		return text, nil
+	}
+	if cfg.OversizedInput != "truncate" {
+		slog.Warn("message rejected by size limit", "agent", agentID, "bytes", len(text), "limit", cfg.MaxMessageBytes)
+		return "", fmt.Errorf("%w: %d bytes, limit is %d", ErrMessageTooLarge, len(text), cfg.MaxMessageBytes)
+	}
+	slog.Info("truncating oversized message", "agent", agentID, "bytes", len(text), "limit", cfg.MaxMessageBytes)
+	text, _ = truncateMessage(text, cfg.MaxMessageBytes)
+	return text, nil
+}
+
+// sendFullReply sends a reply that was truncated for storage to the chat
+// it answers as a Markdown file. Replies with no chat (web, scheduled
+// tasks without one) keep only the truncated text.
+func (o *Orchestrator) sendFullReply(agentID, content string, meta map[string]string) {
+	chatID, err := strconv.ParseInt(meta["chat_id"], 10, 64)
+	if err != nil {
+		return
+	}
+	o.sendFile(agentID, chatID, []byte(content), "reply.md", "text/markdown", "Full reply", meta)
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/nats-io/nats.go"
+)
+
+func TestTruncateMessage(t *testing.T) {
+	if got, cut := truncateMessage("short", 100); cut || got != "short" {
+		t.Errorf("within limit: got %q, %v", got, cut)
+	}
+	if got, cut := truncateMessage(strings.Repeat("x", 500), 0); cut || len(got) != 500 {
+		t.Errorf("zero limit should not truncate, got %d bytes", len(got))
+	}
+
+	text := strings.Repeat("αβγ", 100) // 600 bytes of 2-byte runes
+	got, cut := truncateMessage(text, 101)
+	if !cut || len(got) > 101 {
+		t.Fatalf("got %d bytes, cut=%v, want at most 101", len(got), cut)
+	}
+	if !utf8.ValidString(got) {
+		t.Errorf("truncated text is not valid UTF-8: %q", got)
+	}
+	if !strings.HasSuffix(got, truncationMarker(len(text))) {
+		t.Errorf("missing truncation marker: %q", got)
+	}
+}
+
+func setMessageLimit(o *Orchestrator, limit int, mode string) {
+	cfg := o.defaults()
+	cfg.MaxMessageBytes = limit
+	cfg.OversizedInput = mode
+	o.UpdateDefaults(cfg)
+}
+
+func TestHandleMessageRejectsOversized(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	setMessageLimit(o, 64, "reject")
+
+	err := o.HandleMessage(context.Background(), "alpha", strings.Repeat("x", 65), nil)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("HandleMessage: got %v, want ErrMessageTooLarge", err)
+	}
+	if msgs, _ := o.store.GetMessages("alpha", 10); len(msgs) != 0 {
+		t.Errorf("rejected message was stored: %+v", msgs)
+	}
+}
+
+func TestHandleMessageTruncatesOversized(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	setMessageLimit(o, 64, "truncate")
+
+	text := strings.Repeat("x", 200)
+	if err := o.HandleMessage(context.Background(), "alpha", text, nil); err != nil {
+		t.Fatalf("HandleMessage: %v", err)
+	}
+	msgs, err := o.store.GetMessages("alpha", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored string
+	for _, m := range msgs {
+		if m.Sender == "user" {
+			stored = m.Content
+		}
+	}
+	if len(stored) > 64 || !strings.HasSuffix(stored, truncationMarker(len(text))) {
+		t.Errorf("stored %d bytes %q, want at most 64 ending in the marker", len(stored), stored)
+	}
+}
+
+func TestAgentOutputTruncatedWithFullFile(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	setMessageLimit(o, 80, "reject")
+
+	var file []byte
+	var fileChat int64
+	o.OnFile(func(agentID string, chatID int64, data []byte, name, mimeType, caption string, meta map[string]string) {
+		file, fileChat = data, chatID
+	})
+	var sent string
+	o.OnOutput(func(agentID, content string, meta map[string]string) { sent = content })
+
+	o.mu.Lock()
+	o.pendingMeta["m1"] = map[string]string{"chat_id": "42"}
+	o.mu.Unlock()
+
+	reply := strings.Repeat("long reply line\n", 20)
+	data, _ := json.Marshal(map[string]string{"type": "result", "content": reply, "msg_id": "m1"})
+	o.handleAgentOutput(&nats.Msg{Subject: natsbus.TopicAgentOutput("alpha"), Data: data})
+
+	msgs, err := o.store.GetMessages("alpha", 10)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("messages = %+v, %v", msgs, err)
+	}
+	if c := msgs[0].Content; len(c) > 80 || !strings.HasSuffix(c, truncationMarker(len(reply))) {
+		t.Errorf("stored %d bytes %q, want at most 80 ending in the marker", len(c), c)
+	}
+	if sent != msgs[0].Content {
+		t.Errorf("listeners got %q, want the stored text", sent)
+	}
+	if string(file) != reply || fileChat != 42 {
+		t.Errorf("full reply file = %d bytes to chat %d, want %d bytes to 42", len(file), fileChat, len(reply))
+	}
+}
@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StartNixGC runs nix-collect-garbage -d once per day at a random time
+// in the agent containers that have nix_enabled, defaults.nix_gc_concurrency
+// agents at a time. Agents busy serving messages or in a maintenance window
+// are skipped for the day; containers started only for the sweep are stopped
+// again afterwards.
+func (o *Orchestrator) StartNixGC(ctx context.Context) {
+	for {
+		// Sleep for a random duration between 0 and 24 hours
+		delay := time.Duration(rand.Int64N(int64(24 * time.Hour)))
+		slog.Info("nix-collect-garbage scheduled", "in", delay.Round(time.Minute))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		o.runNixGC(ctx)
+	}
+}
+
+func (o *Orchestrator) runNixGC(ctx context.Context) {
+	agents, err := o.registry.List()
+	if err != nil {
+		slog.Error("nix-gc: failed to list agents", "error", err)
+		return
+	}
+
+	slots := make(chan struct{}, max(1, o.defaults().NixGCConcurrency))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for _, ag := range agents {
+		def, ok := o.registry.GetDefinition(ag.ID)
+		if !ok || !def.NixEnabled {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case slots <- struct{}{}:
+		}
+		wg.Go(func() {
+			defer func() { <-slots }()
+			// Stagger the agents sharing a slot 10-15 minutes apart.
+			stagger := time.Duration(rand.Int64N(int64(5*time.Minute))) + 10*time.Minute
+			slog.Info("nix-gc: next agent scheduled", "agent", ag.ID, "in", stagger.Round(time.Minute))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(stagger):
+			}
+			o.nixGCAgent(ctx, ag.ID)
+		})
+	}
+}
+
+// busyForGC reports whether the agent is serving messages: queued, in
+// flight, or (for a running container) still working on a query.
+func (o *Orchestrator) busyForGC(agentID string) bool {
+	if o.getQueue(agentID).Busy() {
+		return true
+	}
+	o.mu.RLock()
+	inFlight := o.inFlightLocked(agentID)
+	o.mu.RUnlock()
+	if inFlight > 0 {
+		return true
+	}
+	return o.isAgentBusy(agentID)
+}
+
+func (o *Orchestrator) nixGCAgent(ctx context.Context, agentID string) {
+	if o.busyForGC(agentID) {
+		slog.Info("nix-gc: skipping busy agent", "agent", agentID)
+		return
+	}
+	if o.inMaintenance(agentID, time.Now()) {
+		slog.Info("nix-gc: skipping agent in maintenance", "agent", agentID)
+		return
+	}
+
+	wasRunning := o.containers.GetRunning(agentID) != nil
+	if err := o.EnsureAgent(ctx, agentID); err != nil {
+		slog.Warn("nix-gc: failed to start agent", "agent", agentID, "error", err)
+		return
+	}
+	if !wasRunning {
+		defer func() {
+			// A message may have arrived during the sweep; leave it to the
+			// idle reaper then.
+			if o.busyForGC(agentID) {
+				return
+			}
+			if err := o.stopAgent(ctx, agentID, "nix_gc"); err != nil {
+				slog.Warn("nix-gc: failed to stop agent", "agent", agentID, "error", err)
+			}
+		}()
+	}
+
+	// Upgrade all nix packages first
+	output, err := o.containers.Exec(ctx, agentID, []string{"nix", "profile", "upgrade", "--all"}, o.defaults().ExecTimeout)
+	if err != nil {
+		slog.Warn("nix-upgrade failed, skipping agent", "agent", agentID, "error", err)
+		return
+	}
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) > 0 && lines[len(lines)-1] != "" {
+		slog.Info("nix-upgrade: [" + agentID + "] " + lines[len(lines)-1])
+	}
+
+	// Garbage collect old generations
+	output, err = o.containers.Exec(ctx, agentID, []string{"nix-collect-garbage", "-d"}, o.defaults().ExecTimeout)
+	if err != nil {
+		slog.Warn("nix-collect-garbage failed", "agent", agentID, "error", err)
+		return
+	}
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) > 0 && lines[len(lines)-1] != "" {
+		slog.Info("nix-collect-garbage: [" + agentID + "] " + lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,36 @@
+package agent
+
+import "testing"
+
+func TestBusyForGC(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha", "beta")
+
+	if o.busyForGC("alpha") {
+		t.Error("idle agent reported busy")
+	}
+
+	// A message being processed holds the queue lock.
+	q := o.getQueue("alpha")
+	if !q.TryLock() {
+		t.Fatal("queue already locked")
+	}
+	if !o.busyForGC("alpha") {
+		t.Error("agent processing a message not reported busy")
+	}
+	q.Unlock()
+
+	q.Enqueue(QueuedMessage{AgentID: "alpha", Text: "hi"})
+	if !o.busyForGC("alpha") {
+		t.Error("agent with queued messages not reported busy")
+	}
+	q.Clear()
+
+	markInFlight(o, "alpha", "m1")
+	if !o.busyForGC("alpha") {
+		t.Error("agent with an in-flight message not reported busy")
+	}
+
+	if o.busyForGC("beta") {
+		t.Error("another agent's work made beta busy")
+	}
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"maps"
-	"math/rand/v2"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,43 +17,99 @@ import (
 	"github.com/google/uuid"
 	"github.com/mtzanidakis/praktor/internal/config"
 	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/events"
 	"github.com/mtzanidakis/praktor/internal/natsbus"
 	"github.com/mtzanidakis/praktor/internal/registry"
 	"github.com/mtzanidakis/praktor/internal/schedule"
 	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/tracing"
 	"github.com/mtzanidakis/praktor/internal/vault"
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrAgentNotFound is returned for operations on an agent id that is not
+// in the registry.
+var ErrAgentNotFound = errors.New("agent not found")
+
 // SwarmCoordinator is the interface the orchestrator uses to handle swarm IPC.
 type SwarmCoordinator interface {
 	GetSwarmChatTopic(containerAgentID string) (swarmID, chatTopic string, ok bool)
 	PublishSwarmChat(topic, from, content string) error
 }
 
+// Orchestrator routes messages to agent containers and their results back to
+// listeners.
+//
+// Lock hierarchy (acquire left to right, never the reverse):
+//
+//	AgentQueue processing lock (TryLock/Next) → o.mu → leaf locks
+//	  (AgentQueue.mu, SessionTracker.mu, listenerMu, container.Manager's
+//	  internal lock)
+//
+// Concurrent starts of one agent wait on the in-flight startCall (starting)
+// with no lock held; the starting goroutine never waits on another start.
+//
+// o.mu guards the maps and cfg below and is held only for map/field access:
+// never while calling the container manager, NATS, the store or listeners.
+// listenerMu is only held to copy the listener slices; listeners run
+// unlocked so they may call back into the orchestrator.
 type Orchestrator struct {
-	bus             *natsbus.Bus
-	client          *natsbus.Client
-	containers      *container.Manager
-	store           *store.Store
-	registry        *registry.Registry
-	vault           *vault.Vault
-	cfg             config.DefaultsConfig
-	sessions        *SessionTracker
-	queues          map[string]*AgentQueue
-	lastMeta        map[string]map[string]string // agentID → last message meta (fallback for IPC)
-	pendingMeta     map[string]map[string]string // msgID → message meta
-	pendingMsgID    map[string]string            // msgID → agentID (track in-flight messages)
-	mu              sync.RWMutex
-	listeners       []OutputListener
-	fileListeners   []FileListener
-	listenerMu      sync.RWMutex
-	swarmCoord      SwarmCoordinator
-	agentMailAPIKey string
+	bus              *natsbus.Bus
+	client           *natsbus.Client
+	containers       *container.Manager
+	store            *store.Store
+	registry         *registry.Registry
+	vault            *vault.Vault
+	cfg              config.DefaultsConfig
+	sessions         *SessionTracker
+	queues           map[string]*AgentQueue
+	lastMeta         map[string]map[string]string // agentID → last message meta (fallback for IPC)
+	pendingMeta      map[string]map[string]string // msgID → message meta
+	pendingMsgID     map[string]string            // msgID → agentID (track in-flight messages)
+	pendingCache     map[string]string            // msgID → response cache key
+	pendingReply     map[string]int64             // msgID → stored id of the user message being answered
+	pendingPrompts   map[string]*pendingPrompt    // msgID → input payload, for model fallbacks
+	heartbeatFails   map[string]int               // agentID → consecutive missed heartbeats
+	pendingSpans     map[string]trace.Span        // msgID → agent.execute span, ended on result
+	starting         map[string]*startCall        // agentID → start in progress, shared by concurrent callers
+	drains           map[string]*drain            // agentID → graceful restart in progress
+	usage            map[string]workspaceUsage    // agentID → last measured workspace size
+	overQuota        map[string]bool              // agentID → workspace over its quota
+	decryptFailed    map[string]bool              // secret name → its last decryption failed
+	maintenance      map[string]maintenanceWindow // agentID → its open maintenance window
+	restoring        map[string]bool              // agentID → its workspace snapshot is being restored
+	slowTimers       map[string]*time.Timer       // msgID → slow_warning_after timer, stopped on result
+	seeded           map[string]bool              // workspace → already checked for workspace_template seeding
+	safeMode         bool                         // no messages or container starts; see SetSafeMode
+	adminToken       string                       // required on admin socket commands; see SetAdminToken
+	mu               sync.RWMutex
+	listeners        []OutputListener
+	fileListeners    []FileListener
+	commandListeners []CommandsListener
+	runListeners     []RunListener
+	slowListeners    []SlowListener
+	listenerMu       sync.RWMutex
+	transformers     map[string]TransformerFactory
+	pipelines        map[string]cachedPipeline // built output_transforms per agent
+	swarmCoord       SwarmCoordinator
+	agentMailAPIKey  string
+	limiter          *rateLimiter
+	reapInterval     time.Duration // idle reaper tick
+	quotaInterval    time.Duration // workspace quota checker tick
+	artifactsDir     string        // content-addressed artifact blobs
+	artifactMu       sync.Mutex    // orders artifact saves against pruning
+	volumeUsage      func(ctx context.Context, workspace, image string) (int64, error)
+	volumeEmpty      func(ctx context.Context, workspace, image string) (bool, error)
+	readVolume       func(ctx context.Context, workspace, filePath, image string, maxSize int64) ([]byte, error)
+	writeVolume      func(ctx context.Context, workspace, filePath string, data []byte, mode fs.FileMode, image string) error
+	startContainer   func(ctx context.Context, opts container.AgentOpts) (*container.ContainerInfo, error)
 }
 
 type OutputListener func(agentID, content string, meta map[string]string)
-type FileListener func(agentID string, chatID int64, data []byte, name, mimeType, caption string)
+type FileListener func(agentID string, chatID int64, data []byte, name, mimeType, caption string, meta map[string]string)
 
 type IPCCommand struct {
 	Type    string          `json:"type"`
@@ -61,17 +118,42 @@ type IPCCommand struct {
 
 func NewOrchestrator(bus *natsbus.Bus, ctr *container.Manager, s *store.Store, reg *registry.Registry, cfg config.DefaultsConfig, v *vault.Vault) *Orchestrator {
 	o := &Orchestrator{
-		bus:          bus,
-		containers:   ctr,
-		store:        s,
-		registry:     reg,
-		vault:        v,
-		cfg:          cfg,
-		sessions:     NewSessionTracker(),
-		queues:       make(map[string]*AgentQueue),
-		lastMeta:     make(map[string]map[string]string),
-		pendingMeta:  make(map[string]map[string]string),
-		pendingMsgID: make(map[string]string),
+		bus:            bus,
+		containers:     ctr,
+		store:          s,
+		registry:       reg,
+		vault:          v,
+		cfg:            cfg,
+		sessions:       NewSessionTracker(),
+		queues:         make(map[string]*AgentQueue),
+		lastMeta:       make(map[string]map[string]string),
+		pendingMeta:    make(map[string]map[string]string),
+		pendingMsgID:   make(map[string]string),
+		pendingCache:   make(map[string]string),
+		pendingReply:   make(map[string]int64),
+		pendingPrompts: make(map[string]*pendingPrompt),
+		heartbeatFails: make(map[string]int),
+		pendingSpans:   make(map[string]trace.Span),
+		slowTimers:     make(map[string]*time.Timer),
+		seeded:         make(map[string]bool),
+		starting:       make(map[string]*startCall),
+		drains:         make(map[string]*drain),
+		usage:          make(map[string]workspaceUsage),
+		overQuota:      make(map[string]bool),
+		decryptFailed:  make(map[string]bool),
+		maintenance:    make(map[string]maintenanceWindow),
+		restoring:      make(map[string]bool),
+		transformers:   maps.Clone(builtinTransformers),
+		pipelines:      make(map[string]cachedPipeline),
+		limiter:        newRateLimiter(),
+		reapInterval:   time.Minute,
+		quotaInterval:  15 * time.Minute,
+		artifactsDir:   config.ArtifactsPath,
+		volumeUsage:    ctr.VolumeUsage,
+		volumeEmpty:    ctr.VolumeEmpty,
+		readVolume:     ctr.ReadVolumeBytes,
+		writeVolume:    ctr.WriteVolumeBytesMode,
+		startContainer: ctr.StartAgent,
 	}
 
 	client, err := natsbus.NewClient(bus)
@@ -81,15 +163,15 @@ func NewOrchestrator(bus *natsbus.Bus, ctr *container.Manager, s *store.Store, r
 	}
 	o.client = client
 
-	// Subscribe to all agent output
-	_, _ = client.Subscribe("agent.*.output", func(msg *nats.Msg) {
-		o.handleAgentOutput(msg)
-	})
-
-	// Subscribe to all IPC commands
-	_, _ = client.Subscribe("host.ipc.*", func(msg *nats.Msg) {
-		o.handleIPC(msg)
-	})
+	subs := map[string]nats.MsgHandler{
+		natsbus.TopicAgentOutputAll(): o.handleAgentOutput, // all agent output
+		natsbus.TopicIPCAll():         o.handleIPC,         // all IPC commands
+	}
+	for topic, handler := range subs {
+		if _, err := client.Subscribe(topic, handler); err != nil {
+			slog.Error("orchestrator subscribe failed", "topic", topic, "error", err)
+		}
+	}
 
 	return o
 }
@@ -106,6 +188,21 @@ func (o *Orchestrator) UpdateDefaults(cfg config.DefaultsConfig) {
 	o.cfg = cfg
 }
 
+// defaults returns the current defaults config. Always read cfg through
+// here: UpdateDefaults replaces it on config reload.
+func (o *Orchestrator) defaults() config.DefaultsConfig {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.cfg
+}
+
+// outputListeners returns a snapshot of the output listeners.
+func (o *Orchestrator) outputListeners() []OutputListener {
+	o.listenerMu.RLock()
+	defer o.listenerMu.RUnlock()
+	return o.listeners
+}
+
 func (o *Orchestrator) OnOutput(listener OutputListener) {
 	o.listenerMu.Lock()
 	defer o.listenerMu.Unlock()
@@ -119,13 +216,27 @@ func (o *Orchestrator) OnFile(listener FileListener) {
 }
 
 func (o *Orchestrator) HandleMessage(ctx context.Context, agentID, text string, meta map[string]string) error {
+	if o.SafeMode() {
+		return ErrSafeMode
+	}
+
 	// Ensure agent exists
 	ag, err := o.registry.Get(agentID)
 	if err != nil {
 		return fmt.Errorf("get agent: %w", err)
 	}
 	if ag == nil {
-		return fmt.Errorf("agent not registered: %s", agentID)
+		return fmt.Errorf("%w: %s", ErrAgentNotFound, agentID)
+	}
+
+	if !o.limiter.Allow(agentID, o.resolveRateLimit(agentID)) {
+		slog.Warn("message rejected by rate limit", "agent", agentID)
+		return fmt.Errorf("%w: %s", ErrRateLimited, agentID)
+	}
+
+	text, err = o.limitInbound(agentID, text)
+	if err != nil {
+		return err
 	}
 
 	// Save incoming message
@@ -141,12 +252,21 @@ func (o *Orchestrator) HandleMessage(ctx context.Context, agentID, text string,
 	_ = o.store.SaveMessage(msg)
 	o.publishMessageEvent(msg)
 
+	def, _ := o.registry.GetDefinition(agentID)
+	cacheKey := responseCacheKey(text, meta, def.CacheTTL)
+	if cacheKey != "" && o.serveCachedResponse(agentID, cacheKey, def.CacheTTL, meta, msg.ID) {
+		return nil
+	}
+
 	// Enqueue message
 	q := o.getQueue(agentID)
 	q.Enqueue(QueuedMessage{
-		AgentID: agentID,
-		Text:    text,
-		Meta:    meta,
+		AgentID:     agentID,
+		Text:        text,
+		Meta:        meta,
+		CacheKey:    cacheKey,
+		RequestID:   msg.ID,
+		SpanContext: trace.SpanContextFromContext(ctx),
 	})
 
 	// Process queue
@@ -173,83 +293,47 @@ func (o *Orchestrator) processQueue(ctx context.Context, agentID string) {
 	if !q.TryLock() {
 		return // Already processing
 	}
-	defer q.Unlock()
 
+	// Next releases the processing lock once the queue is drained.
 	for {
-		msg, ok := q.Dequeue()
+		msg, ok := q.Next()
 		if !ok {
 			return
 		}
 
 		if err := o.executeMessage(ctx, agentID, msg); err != nil {
 			slog.Error("execute message failed", "agent", agentID, "error", err)
+			o.notifyRunComplete(agentID, msg.Meta, err)
 		}
 	}
 }
 
 func (o *Orchestrator) executeMessage(ctx context.Context, agentID string, msg QueuedMessage) error {
-	// Resolve agent config from registry
-	def, hasDef := o.registry.GetDefinition(agentID)
+	// The execute span lives until the agent's result arrives (see
+	// handleAgentOutput), so it is only ended here on failure.
+	ctx, span := tracing.Tracer().Start(trace.ContextWithSpanContext(ctx, msg.SpanContext), "agent.execute",
+		trace.WithAttributes(attribute.String("agent.id", agentID)))
 
-	ag, err := o.registry.Get(agentID)
-	if err != nil || ag == nil {
-		return fmt.Errorf("get agent: %w", err)
+	// A container being retired finishes its own work first
+	if err := o.waitForDrain(ctx, agentID); err != nil {
+		tracing.End(span, err)
+		return err
 	}
 
 	// Ensure container is running
-	info := o.containers.GetRunning(agentID)
-	if info == nil {
-		slog.Info("starting agent", "agent", agentID)
-
-		var waiter *natsbus.ReadyWaiter
-		waiter, err = natsbus.PrepareReadyWaiter(o.client, agentID)
-		if err != nil {
-			return fmt.Errorf("prepare ready waiter: %w", err)
-		}
-		defer waiter.Close()
-
-		opts := container.AgentOpts{
-			AgentID:   agentID,
-			Workspace: ag.Workspace,
-			Model:     o.registry.ResolveModel(agentID),
-			Image:     o.registry.ResolveImage(agentID),
-			NATSUrl:   o.bus.AgentNATSURL(),
-		}
-		if hasDef {
-			opts.Env = maps.Clone(def.Env)
-			opts.AllowedTools = def.AllowedTools
-
-			opts.NixEnabled = def.NixEnabled
-			opts.Security = def.Security
-		}
-
-		o.resolveSecrets(&opts, agentID, def, hasDef)
-		o.resolveExtensions(&opts, agentID)
-		o.resolveAgentMail(&opts, agentID)
-
-		info, err = o.containers.StartAgent(ctx, opts)
-		if err != nil {
-			return fmt.Errorf("start agent: %w", err)
-		}
-
-		if err := waiter.Wait(ctx, 30*time.Second); err != nil {
-			if ctx.Err() != nil {
-				return ctx.Err()
+	overrides := parseOverrides(agentID, msg.Meta, o.defaults().MessageOverrides)
+	if o.containers.GetRunning(agentID) == nil {
+		if err := o.startAgentWith(ctx, agentID, overrides); err != nil {
+			tracing.End(span, err)
+			reason := "start_failed"
+			if errors.Is(err, container.ErrImageArchMismatch) {
+				reason = "image_arch_mismatch"
 			}
-			// ErrReadyTimeout — log already emitted by waiter; proceed and publish anyway.
+			o.publishAgentErrorEvent(agentID, reason, err.Error(), span)
+			return err
 		}
-
-		now := time.Now()
-		o.sessions.Set(agentID, &Session{
-			ID:          info.ID,
-			AgentID:     agentID,
-			ContainerID: info.ID,
-			Status:      "running",
-			StartedAt:   now,
-			LastActive:  now,
-		})
-
-		o.publishAgentStartEvent(agentID)
+	} else if !overrides.empty() {
+		slog.Warn("message overrides ignored, agent already running", "agent", agentID)
 	}
 
 	// Send message to container via NATS
@@ -260,81 +344,58 @@ func (o *Orchestrator) executeMessage(ctx context.Context, agentID string, msg Q
 		"msg_id":  msgID,
 	}
 	maps.Copy(payload, msg.Meta)
+	maps.DeleteFunc(payload, func(k, _ string) bool { return isOverrideKey(k) })
+	if history := o.historyContext(agentID, msg.RequestID, msg.Meta); history != "" {
+		payload["history"] = history
+	}
+	span.SetAttributes(attribute.String("praktor.msg_id", msgID))
 
 	// Store meta so output handler can route responses back
 	o.mu.Lock()
 	o.lastMeta[agentID] = msg.Meta
 	o.pendingMeta[msgID] = msg.Meta
 	o.pendingMsgID[msgID] = agentID
+	o.pendingSpans[msgID] = span
+	if msg.CacheKey != "" {
+		o.pendingCache[msgID] = msg.CacheKey
+	}
+	if msg.RequestID != 0 {
+		o.pendingReply[msgID] = msg.RequestID
+	}
 	o.mu.Unlock()
 
-	data, _ := json.Marshal(payload)
 	topic := natsbus.TopicAgentInput(agentID)
+	pubCtx, pubSpan := tracing.Tracer().Start(ctx, "nats.publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", topic)))
+	tracing.Inject(pubCtx, payload)
+	o.trackFallback(agentID, msgID, payload)
+	o.armSlowWarning(agentID, msgID, msg.Meta)
+
+	data, _ := json.Marshal(payload)
 	slog.Info("publishing message to agent", "agent", agentID, "topic", topic)
-	if err := o.client.Publish(topic, data); err != nil {
+	err := o.client.Publish(topic, data)
+	if err == nil {
+		err = o.client.Flush()
+	}
+	tracing.End(pubSpan, err)
+	if err != nil {
+		o.popPendingSpan(msgID)
+		o.dropFallback(msgID)
+		o.stopSlowWarning(msgID)
+		tracing.End(span, err)
 		return fmt.Errorf("publish message: %w", err)
 	}
 	o.sessions.Touch(agentID)
-	return o.client.Flush()
+	return nil
 }
 
 func (o *Orchestrator) RouteQuery(ctx context.Context, agentID string, message string) (string, error) {
 	// Ensure the agent container is running
-	info := o.containers.GetRunning(agentID)
-	if info == nil {
-		ag, err := o.registry.Get(agentID)
-		if err != nil || ag == nil {
-			return "", fmt.Errorf("agent not found: %s", agentID)
-		}
-
-		var waiter *natsbus.ReadyWaiter
-		waiter, err = natsbus.PrepareReadyWaiter(o.client, agentID)
-		if err != nil {
-			return "", fmt.Errorf("prepare ready waiter: %w", err)
-		}
-		defer waiter.Close()
-
-		opts := container.AgentOpts{
-			AgentID:   agentID,
-			Workspace: ag.Workspace,
-			Model:     o.registry.ResolveModel(agentID),
-			Image:     o.registry.ResolveImage(agentID),
-			NATSUrl:   o.bus.AgentNATSURL(),
-		}
-		def, hasDef := o.registry.GetDefinition(agentID)
-		if hasDef {
-			opts.Env = maps.Clone(def.Env)
-			opts.NixEnabled = def.NixEnabled
-			opts.Security = def.Security
-		}
-
-		o.resolveSecrets(&opts, agentID, def, hasDef)
-		o.resolveExtensions(&opts, agentID)
-		o.resolveAgentMail(&opts, agentID)
-
-		info, err = o.containers.StartAgent(ctx, opts)
-		if err != nil {
+	if o.containers.GetRunning(agentID) == nil {
+		if err := o.startAgent(ctx, agentID); err != nil {
 			return "", fmt.Errorf("start agent for routing: %w", err)
 		}
-
-		if err := waiter.Wait(ctx, 30*time.Second); err != nil {
-			if ctx.Err() != nil {
-				return "", ctx.Err()
-			}
-			// ErrReadyTimeout — proceed; routing request will time out if agent is unresponsive.
-		}
-
-		now := time.Now()
-		o.sessions.Set(agentID, &Session{
-			ID:          info.ID,
-			AgentID:     agentID,
-			ContainerID: info.ID,
-			Status:      "running",
-			StartedAt:   now,
-			LastActive:  now,
-		})
-
-		o.publishAgentStartEvent(agentID)
 	}
 
 	o.sessions.Touch(agentID)
@@ -358,43 +419,77 @@ func (o *Orchestrator) RouteQuery(ctx context.Context, agentID string, message s
 }
 
 func (o *Orchestrator) handleAgentOutput(msg *nats.Msg) {
-	// Extract agentID from topic: agent.{agentID}.output
-	topic := msg.Subject
-	var agentID string
-	if _, err := fmt.Sscanf(topic, "agent.%s", &agentID); err != nil {
+	agentID, ok := natsbus.ParseAgentOutputSubject(msg.Subject)
+	if !ok {
+		slog.Warn("ignoring output on malformed subject", "subject", msg.Subject)
 		return
 	}
-	// Remove trailing ".output"
-	if len(agentID) > 7 && agentID[len(agentID)-7:] == ".output" {
-		agentID = agentID[:len(agentID)-7]
-	}
 
 	var output struct {
 		Type           string `json:"type"`
 		Content        string `json:"content"`
 		MsgID          string `json:"msg_id"`
 		TerminalReason string `json:"terminal_reason,omitempty"`
+		Code           string `json:"code,omitempty"`
+		Retryable      bool   `json:"retryable,omitempty"`
+		Model          string `json:"model,omitempty"`
+		AgentID        string `json:"agent_id,omitempty"`
 	}
 	if err := json.Unmarshal(msg.Data, &output); err != nil {
 		return
 	}
+	// The agent runner names itself in every output. Any client on the bus
+	// may publish to another agent's subject, so a payload that disagrees
+	// with the subject is dropped rather than delivered as that agent's
+	// reply. Older images send no agent_id.
+	if output.AgentID != "" && output.AgentID != agentID {
+		slog.Warn("dropping output with mismatched agent id", "subject_agent", agentID, "payload_agent", output.AgentID, "msg_id", output.MsgID)
+		return
+	}
 
 	o.sessions.Touch(agentID)
 
+	// A model error is retried on the next fallback model; once the chain
+	// is exhausted (or the error isn't retryable) it ends the message like
+	// an abnormal result.
+	if output.Type == "error" {
+		if output.Retryable && o.retryWithFallback(agentID, output.MsgID, output.Code, output.Model) {
+			return
+		}
+		output.Type = "result"
+		if output.TerminalReason == "" {
+			output.TerminalReason = "model_error"
+		}
+	}
+
 	if output.Type == "result" {
-		content := o.redactSecrets(agentID, output.Content)
+		o.dropFallback(output.MsgID)
+		o.stopSlowWarning(output.MsgID)
+		content := o.processOutput(agentID, output.Content)
+		full := content
+		content, truncated := truncateMessage(content, o.defaults().MaxMessageBytes)
 		abnormal := output.TerminalReason != "" && output.TerminalReason != "completed"
 
+		span := o.endExecuteSpan(output.MsgID, output.TerminalReason, abnormal)
 		if abnormal {
-			slog.Warn("agent query terminated abnormally", "agent", agentID, "terminal_reason", output.TerminalReason)
+			slog.Warn("agent query terminated abnormally", "agent", agentID, "terminal_reason", output.TerminalReason, "trace_id", traceID(span))
+			o.publishAgentErrorEvent(agentID, output.TerminalReason, "", span)
+		}
+
+		if cacheKey := o.popPendingCacheKey(output.MsgID); cacheKey != "" && content != "" && !abnormal {
+			if err := o.store.SaveCachedResponse(agentID, cacheKey, content); err != nil {
+				slog.Warn("failed to cache response", "agent", agentID, "error", err)
+			}
 		}
 
 		// Save to DB if there's content or an abnormal termination
+		replyTo := o.popPendingReply(output.MsgID)
 		if content != "" || abnormal {
 			agentMsg := &store.Message{
 				AgentID: agentID,
 				Sender:  "agent",
 				Content: content,
+				ReplyTo: replyTo,
 			}
 			if abnormal {
 				agentMsg.Metadata, _ = json.Marshal(map[string]string{"terminal_reason": output.TerminalReason})
@@ -405,6 +500,7 @@ func (o *Orchestrator) handleAgentOutput(msg *nats.Msg) {
 
 		// Get metadata: try msg_id first (parallel-safe), fall back to per-agent lastMeta
 		meta := o.popPendingMeta(output.MsgID)
+		runMeta := meta
 		if meta == nil {
 			meta = o.getLastMeta(agentID)
 		}
@@ -417,13 +513,38 @@ func (o *Orchestrator) handleAgentOutput(msg *nats.Msg) {
 		}
 
 		if listenerContent != "" {
-			o.listenerMu.RLock()
-			for _, l := range o.listeners {
+			for _, l := range o.outputListeners() {
 				l(agentID, listenerContent, meta)
 			}
-			o.listenerMu.RUnlock()
 		}
+		if truncated {
+			o.sendFullReply(agentID, full, meta)
+		}
+		if runMeta != nil {
+			var runErr error
+			if abnormal {
+				runErr = fmt.Errorf("agent stopped: %s", output.TerminalReason)
+			}
+			o.notifyRunComplete(agentID, runMeta, runErr)
+		}
+		o.maybeFinishDrain(agentID)
+	}
+}
+
+// popPendingReply returns the stored id of the user message that msgID
+// answers, or nil if it is unknown.
+func (o *Orchestrator) popPendingReply(msgID string) *int64 {
+	if msgID == "" {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	id, ok := o.pendingReply[msgID]
+	if !ok {
+		return nil
 	}
+	delete(o.pendingReply, msgID)
+	return &id
 }
 
 func (o *Orchestrator) getLastMeta(agentID string) map[string]string {
@@ -455,10 +576,7 @@ func (o *Orchestrator) handleIPC(msg *nats.Msg) {
 	}
 
 	// Extract agentID from subject: host.ipc.{agentID}
-	agentID := msg.Subject
-	if idx := len("host.ipc."); idx < len(agentID) {
-		agentID = agentID[idx:]
-	}
+	agentID := natsbus.AgentFromSubject(msg.Subject)
 
 	slog.Info("IPC command received", "type", cmd.Type, "agent", agentID)
 
@@ -471,6 +589,10 @@ func (o *Orchestrator) handleIPC(msg *nats.Msg) {
 		o.ipcUpdateTask(msg, cmd.Payload)
 	case "delete_task":
 		o.ipcDeleteTask(msg, cmd.Payload)
+	case "pause_task":
+		o.ipcSetTaskStatus(msg, agentID, cmd.Payload, "paused")
+	case "resume_task":
+		o.ipcSetTaskStatus(msg, agentID, cmd.Payload, "active")
 	case "read_user_md":
 		o.ipcReadUserMD(msg)
 	case "update_user_md":
@@ -481,8 +603,20 @@ func (o *Orchestrator) handleIPC(msg *nats.Msg) {
 		o.ipcExtensionStatus(msg, agentID, cmd.Payload)
 	case "send_file":
 		o.ipcSendFile(msg, agentID, cmd.Payload)
+	case "read_history":
+		o.ipcReadHistory(msg, agentID, cmd.Payload)
 	case "search_history":
 		o.ipcSearchHistory(msg, agentID, cmd.Payload)
+	case "register_commands":
+		o.ipcRegisterCommands(msg, agentID, cmd.Payload)
+	case "save_artifact":
+		o.ipcSaveArtifact(msg, agentID, cmd.Payload)
+	case "list_agents":
+		o.ipcListAgents(msg, agentID, cmd.Payload)
+	case "enter_maintenance":
+		o.ipcEnterMaintenance(msg, agentID, cmd.Payload)
+	case "exit_maintenance":
+		o.ipcExitMaintenance(msg, agentID)
 	default:
 		slog.Warn("unknown IPC command", "type", cmd.Type)
 		o.respondIPC(msg, map[string]any{"error": "unknown command: " + cmd.Type})
@@ -517,7 +651,7 @@ func (o *Orchestrator) ipcCreateTask(msg *nats.Msg, agentID string, payload json
 
 	normalized, err := schedule.NormalizeSchedule(req.Schedule)
 	if err != nil {
-		o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("invalid schedule: %v", err)})
+		o.respondIPC(msg, map[string]any{"error": err.Error()})
 		return
 	}
 
@@ -548,26 +682,42 @@ func (o *Orchestrator) ipcListTasks(msg *nats.Msg, agentID string) {
 		return
 	}
 
+	// Fields are only ever added: older ptask binaries ignore the rest.
 	type taskEntry struct {
-		ID       string `json:"id"`
-		Name     string `json:"name"`
-		Schedule string `json:"schedule"`
-		Prompt   string `json:"prompt"`
-		Status   string `json:"status"`
+		ID         string     `json:"id"`
+		Name       string     `json:"name"`
+		Schedule   string     `json:"schedule"`
+		Prompt     string     `json:"prompt"`
+		Status     string     `json:"status"`
+		NextRun    *time.Time `json:"next_run,omitempty"`
+		LastRun    *time.Time `json:"last_run,omitempty"`
+		LastStatus string     `json:"last_status,omitempty"`
 	}
 	out := make([]taskEntry, 0, len(tasks))
 	for _, t := range tasks {
 		out = append(out, taskEntry{
-			ID:       t.ID,
-			Name:     t.Name,
-			Schedule: t.Schedule,
-			Prompt:   t.Prompt,
-			Status:   t.Status,
+			ID:         t.ID,
+			Name:       t.Name,
+			Schedule:   t.Schedule,
+			Prompt:     t.Prompt,
+			Status:     t.Status,
+			NextRun:    utcTime(t.NextRunAt),
+			LastRun:    utcTime(t.LastRunAt),
+			LastStatus: t.LastStatus,
 		})
 	}
 	o.respondIPC(msg, map[string]any{"ok": true, "tasks": out})
 }
 
+// utcTime returns t in UTC, so IPC timestamps are always RFC 3339 with a Z.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
 func (o *Orchestrator) ipcUpdateTask(msg *nats.Msg, payload json.RawMessage) {
 	var req struct {
 		ID       string `json:"id"`
@@ -595,7 +745,7 @@ func (o *Orchestrator) ipcUpdateTask(msg *nats.Msg, payload json.RawMessage) {
 	if req.Schedule != "" {
 		normalized, err := schedule.NormalizeSchedule(req.Schedule)
 		if err != nil {
-			o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("invalid schedule: %v", err)})
+			o.respondIPC(msg, map[string]any{"error": err.Error()})
 			return
 		}
 		t.Schedule = normalized
@@ -627,6 +777,51 @@ func (o *Orchestrator) ipcDeleteTask(msg *nats.Msg, payload json.RawMessage) {
 	o.respondIPC(msg, map[string]any{"ok": true})
 }
 
+// ipcSetTaskStatus pauses or resumes one of the calling agent's own tasks.
+// Resuming recomputes next_run_at so a task paused across its slot doesn't
+// fire immediately for a missed run.
+func (o *Orchestrator) ipcSetTaskStatus(msg *nats.Msg, agentID string, payload json.RawMessage, status string) {
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil || req.ID == "" {
+		o.respondIPC(msg, map[string]any{"error": "id is required"})
+		return
+	}
+
+	t, err := o.store.GetTask(req.ID)
+	if err != nil || t == nil {
+		o.respondIPC(msg, map[string]any{"error": "task not found: " + req.ID})
+		return
+	}
+	if t.AgentID != agentID {
+		slog.Warn("rejected task status change for another agent's task", "agent", agentID, "task", t.ID, "owner", t.AgentID)
+		o.respondIPC(msg, map[string]any{"error": "task belongs to another agent: " + req.ID})
+		return
+	}
+	if t.Status == "completed" {
+		o.respondIPC(msg, map[string]any{"error": "task is completed"})
+		return
+	}
+
+	t.Status = status
+	if status == "active" {
+		t.NextRunAt = schedule.CalculateNextRun(t.Schedule)
+		if t.NextRunAt == nil {
+			o.respondIPC(msg, map[string]any{"error": "task has no future run to resume"})
+			return
+		}
+	}
+
+	if err := o.store.SaveTask(t); err != nil {
+		o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("save failed: %v", err)})
+		return
+	}
+
+	slog.Info("task status changed via IPC", "id", t.ID, "name", t.Name, "agent", agentID, "status", status)
+	o.respondIPC(msg, map[string]any{"ok": true, "id": t.ID})
+}
+
 func (o *Orchestrator) ipcReadUserMD(msg *nats.Msg) {
 	content, err := o.registry.GetUserMD()
 	if err != nil {
@@ -644,7 +839,7 @@ func (o *Orchestrator) ipcUpdateUserMD(msg *nats.Msg, payload json.RawMessage) {
 		o.respondIPC(msg, map[string]any{"error": "invalid payload"})
 		return
 	}
-	if err := o.registry.SaveUserMD(req.Content); err != nil {
+	if err := o.SaveUserProfile(req.Content); err != nil {
 		o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("save failed: %v", err)})
 		return
 	}
@@ -696,6 +891,7 @@ func (o *Orchestrator) ipcSendFile(msg *nats.Msg, agentID string, payload json.R
 	var req struct {
 		Name     string `json:"name"`
 		Data     string `json:"data"`
+		Path     string `json:"path"`
 		MimeType string `json:"mime_type"`
 		Caption  string `json:"caption"`
 	}
@@ -703,14 +899,52 @@ func (o *Orchestrator) ipcSendFile(msg *nats.Msg, agentID string, payload json.R
 		o.respondIPC(msg, map[string]any{"error": "invalid payload"})
 		return
 	}
-	if req.Name == "" || req.Data == "" {
-		o.respondIPC(msg, map[string]any{"error": "name and data are required"})
+	if req.Name == "" || (req.Data == "" && req.Path == "") {
+		o.respondIPC(msg, map[string]any{"error": "name and data or path are required"})
 		return
 	}
 
-	data, err := base64.StdEncoding.DecodeString(req.Data)
+	name, err := SanitizeFileName(req.Name)
 	if err != nil {
-		o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("base64 decode failed: %v", err)})
+		o.respondIPC(msg, map[string]any{"error": err.Error()})
+		return
+	}
+	defaults := o.defaults()
+	maxMB := defaults.MaxFileSizeMB
+	maxSize := maxMB << 20
+	tooLarge := fmt.Sprintf("file too large (max %d MB)", maxMB)
+
+	var data []byte
+	if req.Path != "" {
+		// Large files are not embedded in the IPC message: the agent leaves
+		// them in its workspace and the host copies them out of the volume.
+		data, err = o.readWorkspaceFile(agentID, req.Path, maxSize)
+		if errors.Is(err, container.ErrFileTooLarge) {
+			o.respondIPC(msg, map[string]any{"error": tooLarge})
+			return
+		}
+		if err != nil {
+			o.respondIPC(msg, map[string]any{"error": err.Error()})
+			return
+		}
+	} else {
+		if maxSize > 0 && int64(base64.StdEncoding.DecodedLen(len(req.Data))) > maxSize+2 {
+			o.respondIPC(msg, map[string]any{"error": tooLarge})
+			return
+		}
+		data, err = base64.StdEncoding.DecodeString(req.Data)
+		if err != nil {
+			o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("base64 decode failed: %v", err)})
+			return
+		}
+		if maxSize > 0 && int64(len(data)) > maxSize {
+			o.respondIPC(msg, map[string]any{"error": tooLarge})
+			return
+		}
+	}
+	if err := CheckFileFilter(defaults.FileFilter, name, req.MimeType, data); err != nil {
+		slog.Warn("file send blocked", "agent", agentID, "name", name, "mime", req.MimeType, "reason", err)
+		o.respondIPC(msg, map[string]any{"error": err.Error()})
 		return
 	}
 
@@ -730,16 +964,99 @@ func (o *Orchestrator) ipcSendFile(msg *nats.Msg, agentID string, payload json.R
 		return
 	}
 
+	o.sendFile(agentID, chatID, data, name, req.MimeType, req.Caption, meta)
+
+	slog.Info("file sent via IPC", "agent", agentID, "name", name, "size", len(data), "mime", req.MimeType)
+	o.respondIPC(msg, map[string]any{"ok": true})
+}
+
+func (o *Orchestrator) sendFile(agentID string, chatID int64, data []byte, name, mimeType, caption string, meta map[string]string) {
 	o.listenerMu.RLock()
 	listeners := o.fileListeners
 	o.listenerMu.RUnlock()
 
 	for _, l := range listeners {
-		l(agentID, chatID, data, req.Name, req.MimeType, req.Caption)
+		l(agentID, chatID, data, name, mimeType, caption, meta)
 	}
+}
 
-	slog.Info("file sent via IPC", "agent", agentID, "name", req.Name, "size", len(data), "mime", req.MimeType)
-	o.respondIPC(msg, map[string]any{"ok": true})
+// readWorkspaceFile reads a file an agent left in its workspace volume.
+// filePath is either absolute under /workspace/agent or relative to it.
+func (o *Orchestrator) readWorkspaceFile(agentID, filePath string, maxSize int64) ([]byte, error) {
+	if strings.HasPrefix(filePath, "/") {
+		rel, ok := strings.CutPrefix(filePath, "/workspace/agent/")
+		if !ok {
+			return nil, fmt.Errorf("path must be inside /workspace/agent")
+		}
+		filePath = rel
+	}
+
+	ag, err := o.registry.Get(agentID)
+	if err != nil {
+		return nil, fmt.Errorf("get agent: %w", err)
+	}
+	if ag == nil {
+		return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, agentID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	data, err := o.readVolume(ctx, ag.Workspace, filePath, o.registry.ResolveImage(agentID), maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("read workspace file: %w", err)
+	}
+	return data, nil
+}
+
+// maxReadHistoryLimit caps how many messages one read_history call returns.
+const maxReadHistoryLimit = 200
+
+// ipcReadHistory returns the calling agent's own recent messages, in
+// chronological order, so it can rehydrate context after a cold start.
+// before is a message id cursor for paging further back.
+func (o *Orchestrator) ipcReadHistory(msg *nats.Msg, agentID string, payload json.RawMessage) {
+	var req struct {
+		Limit  int   `json:"limit"`
+		Before int64 `json:"before"`
+	}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &req); err != nil {
+			o.respondIPC(msg, map[string]any{"error": "invalid payload"})
+			return
+		}
+	}
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	req.Limit = min(req.Limit, maxReadHistoryLimit)
+
+	messages, err := o.store.GetMessagesBefore(agentID, req.Before, req.Limit)
+	if err != nil {
+		o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("read failed: %v", err)})
+		return
+	}
+
+	type messageEntry struct {
+		ID        int64  `json:"id"`
+		Sender    string `json:"sender"`
+		Content   string `json:"content"`
+		ReplyTo   *int64 `json:"reply_to,omitempty"`
+		CreatedAt string `json:"created_at"`
+	}
+	out := make([]messageEntry, 0, len(messages))
+	for _, m := range messages {
+		out = append(out, messageEntry{
+			ID:        m.ID,
+			Sender:    m.Sender,
+			Content:   m.Content,
+			ReplyTo:   m.ReplyTo,
+			CreatedAt: m.CreatedAt.Format(time.RFC3339),
+		})
+	}
+
+	slog.Info("history read via IPC", "agent", agentID, "before", req.Before, "results", len(out))
+	o.respondIPC(msg, map[string]any{"ok": true, "messages": out, "limit": req.Limit})
 }
 
 func (o *Orchestrator) ipcSearchHistory(msg *nats.Msg, agentID string, payload json.RawMessage) {
@@ -776,6 +1093,63 @@ func (o *Orchestrator) ipcSearchHistory(msg *nats.Msg, agentID string, payload j
 	o.respondIPC(msg, map[string]any{"ok": true, "messages": out})
 }
 
+// ipcListAgents tells an agent which agents exist, for delegation and
+// routing suggestions. Only identity and status are returned, never model,
+// image, env or other configuration. The payload may narrow the list to a
+// group, agents carrying all of tags, or running agents.
+func (o *Orchestrator) ipcListAgents(msg *nats.Msg, agentID string, payload json.RawMessage) {
+	var req struct {
+		Group   string   `json:"group"`
+		Tags    []string `json:"tags"`
+		Running bool     `json:"running"`
+	}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &req); err != nil {
+			o.respondIPC(msg, map[string]any{"error": "invalid payload"})
+			return
+		}
+	}
+
+	agents, err := o.registry.List()
+	if err != nil {
+		o.respondIPC(msg, map[string]any{"error": fmt.Sprintf("list failed: %v", err)})
+		return
+	}
+	descs := o.registry.AgentDescriptions()
+
+	type agentEntry struct {
+		ID          string   `json:"id"`
+		Name        string   `json:"name"`
+		Description string   `json:"description,omitempty"`
+		Tags        []string `json:"tags,omitempty"`
+		Group       string   `json:"group,omitempty"`
+		Running     bool     `json:"running"`
+		Self        bool     `json:"self,omitempty"`
+	}
+	out := make([]agentEntry, 0, len(agents))
+	for _, a := range agents {
+		running := o.sessions.Get(a.ID) != nil
+		if (req.Group != "" && a.Group != req.Group) || !a.HasTags(req.Tags...) || (req.Running && !running) {
+			continue
+		}
+		desc := descs[a.ID]
+		if desc == "" {
+			desc = a.Description
+		}
+		out = append(out, agentEntry{
+			ID:          a.ID,
+			Name:        a.Name,
+			Description: desc,
+			Tags:        a.Tags,
+			Group:       a.Group,
+			Running:     running,
+			Self:        a.ID == agentID,
+		})
+	}
+
+	o.respondIPC(msg, map[string]any{"ok": true, "agents": out})
+}
+
 func (o *Orchestrator) publishMessageEvent(msg *store.Message, terminalReason ...string) {
 	if o.client == nil {
 		return
@@ -786,36 +1160,23 @@ func (o *Orchestrator) publishMessageEvent(msg *store.Message, terminalReason ..
 		role = "assistant"
 	}
 
-	now := time.Now()
 	timeStr := msg.CreatedAt.Format("15:04")
 	if msg.CreatedAt.IsZero() {
-		timeStr = now.Format("15:04")
+		timeStr = time.Now().Format("15:04")
 	}
 
-	data := map[string]any{
-		"id":   msg.ID,
-		"role": role,
-		"text": msg.Content,
-		"time": timeStr,
-	}
-	if len(terminalReason) > 0 && terminalReason[0] != "" {
-		data["terminal_reason"] = terminalReason[0]
+	data := events.MessageData{
+		ID:      msg.ID,
+		Role:    role,
+		Text:    msg.Content,
+		Time:    timeStr,
+		ReplyTo: msg.ReplyTo,
 	}
-
-	event := map[string]any{
-		"type":      "message",
-		"agent_id":  msg.AgentID,
-		"timestamp": now.UTC().Format(time.RFC3339),
-		"data":      data,
+	if len(terminalReason) > 0 {
+		data.TerminalReason = terminalReason[0]
 	}
 
-	eventJSON, err := json.Marshal(event)
-	if err != nil {
-		return
-	}
-
-	topic := natsbus.TopicEventsAgent(msg.AgentID)
-	_ = o.client.Publish(topic, eventJSON)
+	_ = o.client.PublishJSON(natsbus.TopicEventsAgent(msg.AgentID), events.NewMessage(msg.AgentID, data))
 }
 
 func (o *Orchestrator) EnsureAgent(ctx context.Context, agentID string) error {
@@ -823,71 +1184,195 @@ func (o *Orchestrator) EnsureAgent(ctx context.Context, agentID string) error {
 	if info := o.containers.GetRunning(agentID); info != nil {
 		return nil
 	}
+	return o.startAgent(ctx, agentID)
+}
 
-	def, hasDef := o.registry.GetDefinition(agentID)
-	ag, err := o.registry.Get(agentID)
-	if err != nil || ag == nil {
-		return fmt.Errorf("agent not found: %s", agentID)
+// startAgent starts the agent's container, waits for the ready handshake
+// and registers the session. Lifecycle events are published at each step.
+func (o *Orchestrator) startAgent(ctx context.Context, agentID string) error {
+	return o.startAgentWith(ctx, agentID, startOverrides{})
+}
+
+// startCall is one start of an agent, shared by every caller that asks for
+// the agent while it is in progress. err is set before done is closed.
+type startCall struct {
+	done chan struct{}
+	err  error
+}
+
+// startAgentWith starts the agent's container with per-message overrides
+// applied on top of its definition.
+//
+// The queue, RouteQuery and EnsureAgent can race to start the same
+// container. Only the first caller starts it; the others wait for that
+// start and its ready handshake and share its result. Their overrides are
+// ignored, as they would be for an agent that is already running.
+func (o *Orchestrator) startAgentWith(ctx context.Context, agentID string, overrides startOverrides) error {
+	for {
+		o.mu.Lock()
+		call, inFlight := o.starting[agentID]
+		if !inFlight {
+			call = &startCall{done: make(chan struct{})}
+			o.starting[agentID] = call
+		}
+		o.mu.Unlock()
+
+		if !inFlight {
+			call.err = o.doStartAgent(ctx, agentID, overrides)
+			o.mu.Lock()
+			delete(o.starting, agentID)
+			o.mu.Unlock()
+			close(call.done)
+			return call.err
+		}
+
+		if !overrides.empty() {
+			slog.Warn("message overrides ignored, agent already starting", "agent", agentID)
+		}
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		// The starter giving up on its own context says nothing about ours.
+		if errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded) {
+			continue
+		}
+		return call.err
 	}
+}
 
-	slog.Info("starting agent", "agent", agentID)
+func (o *Orchestrator) doStartAgent(ctx context.Context, agentID string, overrides startOverrides) error {
+	if o.containers.GetRunning(agentID) != nil {
+		return nil
+	}
+	if o.SafeMode() {
+		return ErrSafeMode
+	}
+	if o.isRestoring(agentID) {
+		return fmt.Errorf("%w: %s", ErrRestoreInProgress, agentID)
+	}
+	if err := o.checkWorkspaceQuota(ctx, agentID); err != nil {
+		return err
+	}
+	o.seedWorkspace(ctx, agentID)
 
-	var waiter *natsbus.ReadyWaiter
-	waiter, err = natsbus.PrepareReadyWaiter(o.client, agentID)
+	opts, err := o.agentOpts(agentID, overrides)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("starting agent", "agent", agentID, "model", opts.Model)
+
+	waiter, err := natsbus.PrepareReadyWaiter(o.client, agentID)
 	if err != nil {
 		return fmt.Errorf("prepare ready waiter: %w", err)
 	}
 	defer waiter.Close()
 
-	opts := container.AgentOpts{
-		AgentID:   agentID,
-		Workspace: ag.Workspace,
-		Model:     o.registry.ResolveModel(agentID),
-		Image:     o.registry.ResolveImage(agentID),
-		NATSUrl:   o.bus.AgentNATSURL(),
-	}
-	if hasDef {
-		opts.Env = maps.Clone(def.Env)
-		opts.AllowedTools = def.AllowedTools
-		opts.NixEnabled = def.NixEnabled
-		opts.Security = def.Security
-	}
-	o.resolveSecrets(&opts, agentID, def, hasDef)
-	o.resolveExtensions(&opts, agentID)
-	o.resolveAgentMail(&opts, agentID)
+	o.publishLifecycleEvent(natsbus.NewLifecycleEvent(natsbus.LifecycleStarting, agentID))
 
-	info, err := o.containers.StartAgent(ctx, opts)
+	timings := timingsFrom(ctx)
+	started := time.Now()
+	startCtx, startSpan := tracing.Tracer().Start(ctx, "container.start",
+		trace.WithAttributes(attribute.String("agent.id", agentID), attribute.String("container.image", opts.Image)))
+	info, err := o.startContainer(startCtx, opts)
+	tracing.End(startSpan, err)
+	if timings != nil {
+		timings.start = time.Since(started)
+	}
 	if err != nil {
+		ev := natsbus.NewLifecycleEvent(natsbus.LifecycleStopped, agentID)
+		ev.Reason = "start_failed"
+		o.publishLifecycleEvent(ev)
 		return fmt.Errorf("start agent: %w", err)
 	}
 
-	if err := waiter.Wait(ctx, 30*time.Second); err != nil {
+	ev := natsbus.NewLifecycleEvent(natsbus.LifecycleStarted, agentID)
+	ev.ContainerID = info.ID
+	o.publishLifecycleEvent(ev)
+
+	_, waitSpan := tracing.Tracer().Start(ctx, "agent.ready_wait")
+	err = waiter.Wait(ctx, o.defaults().ReadyTimeout)
+	tracing.End(waitSpan, err)
+	if timings != nil {
+		timings.ready = time.Since(started) - timings.start
+	}
+	if err != nil {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
 		// ErrReadyTimeout — log already emitted by waiter; proceed.
+		ev := natsbus.NewLifecycleEvent(natsbus.LifecycleUnhealthy, agentID)
+		ev.ContainerID = info.ID
+		ev.Reason = "ready_timeout"
+		o.publishLifecycleEvent(ev)
+	} else {
+		ev := natsbus.NewLifecycleEvent(natsbus.LifecycleReady, agentID)
+		ev.ContainerID = info.ID
+		o.publishLifecycleEvent(ev)
 	}
 
 	now := time.Now()
 	o.sessions.Set(agentID, &Session{
-		ID:          info.ID,
-		AgentID:     agentID,
-		ContainerID: info.ID,
-		Status:      "running",
-		StartedAt:   now,
-		LastActive:  now,
+		ID:           info.ID,
+		AgentID:      agentID,
+		ContainerID:  info.ID,
+		Status:       "running",
+		StartedAt:    now,
+		LastActive:   now,
+		Capabilities: waiter.Capabilities(),
 	})
 	o.publishAgentStartEvent(agentID)
 	return nil
 }
 
+// agentOpts builds the container options for agentID from its definition,
+// secrets and extensions, then applies per-message overrides.
+func (o *Orchestrator) agentOpts(agentID string, overrides startOverrides) (container.AgentOpts, error) {
+	def, hasDef := o.registry.GetDefinition(agentID)
+	ag, err := o.registry.Get(agentID)
+	if err != nil {
+		return container.AgentOpts{}, fmt.Errorf("get agent: %w", err)
+	}
+	if ag == nil {
+		return container.AgentOpts{}, fmt.Errorf("%w: %s", ErrAgentNotFound, agentID)
+	}
+
+	opts := container.AgentOpts{
+		AgentID:       agentID,
+		Workspace:     ag.Workspace,
+		Model:         o.registry.ResolveModel(agentID),
+		Image:         o.registry.ResolveImage(agentID),
+		NATSUrl:       o.bus.AgentNATSURL(),
+		ClaudeVersion: o.registry.ResolveClaudeVersion(agentID),
+	}
+	// The same session id on every start lets the agent resume its Claude
+	// conversation after idle or crash restarts.
+	if opts.SessionID, err = o.store.AgentSessionID(agentID); err != nil {
+		slog.Warn("failed to get agent session id", "agent", agentID, "error", err)
+	}
+	if hasDef {
+		opts.Env = maps.Clone(def.Env)
+		opts.AllowedTools = def.AllowedTools
+		opts.NixEnabled = def.NixEnabled
+		opts.Security = def.Security
+		opts.Labels = def.ContainerLabels
+		opts.Weight = def.Weight
+	}
+	o.resolveSecrets(&opts, agentID, def, hasDef)
+	o.resolveExtensions(&opts, agentID)
+	o.resolveAgentMail(&opts, agentID)
+	o.resolveGlobalContext(&opts)
+	overrides.apply(&opts)
+	return opts, nil
+}
+
 // AbortSession sends an abort control command to a running agent,
 // terminating the active Claude query without stopping the container.
 func (o *Orchestrator) AbortSession(ctx context.Context, agentID string) error {
 	// Drain pending messages so they don't run after the abort
-	if q := o.getQueue(agentID); q != nil {
-		q.Clear()
-	}
+	o.dropQueued(agentID)
 	if o.containers.GetRunning(agentID) == nil {
 		return nil
 	}
@@ -897,28 +1382,57 @@ func (o *Orchestrator) AbortSession(ctx context.Context, agentID string) error {
 	return err
 }
 
-// ClearSession sends a clear_session control command to a running agent,
-// resetting its conversation context without stopping the container.
+// ClearSession rotates the agent's persistent session id and sends a
+// clear_session control command to a running agent, resetting its
+// conversation context without stopping the container.
 func (o *Orchestrator) ClearSession(ctx context.Context, agentID string) error {
+	sessionID, err := o.store.RotateAgentSessionID(agentID)
+	if err != nil {
+		return fmt.Errorf("rotate session: %w", err)
+	}
 	if o.containers.GetRunning(agentID) == nil {
 		return nil
 	}
 	topic := natsbus.TopicAgentControl(agentID)
-	data, _ := json.Marshal(map[string]string{"command": "clear_session"})
-	_, err := o.client.Request(topic, data, 5*time.Second)
+	data, _ := json.Marshal(map[string]string{"command": "clear_session", "session_id": sessionID})
+	_, err = o.client.Request(topic, data, 5*time.Second)
 	return err
 }
 
 func (o *Orchestrator) StopAgent(ctx context.Context, agentID string) error {
+	return o.stopAgent(ctx, agentID, "manual")
+}
+
+func (o *Orchestrator) stopAgent(ctx context.Context, agentID, reason string) error {
 	o.sessions.Remove(agentID)
 	o.clearPendingMessages(agentID)
-	err := o.containers.StopAgent(ctx, agentID)
+	o.mu.Lock()
+	delete(o.heartbeatFails, agentID)
+	delete(o.maintenance, agentID)
+	o.mu.Unlock()
+	err := o.containers.StopAgentWith(ctx, agentID, o.stopMode(reason))
 	if err == nil {
-		o.publishAgentStopEvent(agentID, "manual")
+		o.publishAgentStopEvent(agentID, reason)
 	}
 	return err
 }
 
+// stopMode picks whether a stop for reason keeps the container: the idle
+// reaper follows defaults.idle_stop_mode, restarts and unhealthy agents
+// always get a fresh container, everything else follows defaults.stop_mode.
+func (o *Orchestrator) stopMode(reason string) string {
+	d := o.defaults()
+	switch reason {
+	case "restart", "unhealthy":
+		return "remove"
+	case "idle_timeout":
+		if d.IdleStopMode != "" {
+			return d.IdleStopMode
+		}
+	}
+	return d.StopMode
+}
+
 // isAgentBusy pings the agent container to check if it's actively processing.
 // Returns false (not busy) if the agent doesn't respond or reports idle.
 // AgentStatus holds the runtime status of an agent container.
@@ -981,16 +1495,24 @@ func (o *Orchestrator) clearPendingMessages(agentID string) {
 		if aid == agentID {
 			delete(o.pendingMsgID, msgID)
 			delete(o.pendingMeta, msgID)
+			delete(o.pendingCache, msgID)
+			delete(o.pendingReply, msgID)
+			delete(o.pendingPrompts, msgID)
+			o.stopSlowWarningLocked(msgID)
+			if span, ok := o.pendingSpans[msgID]; ok {
+				span.SetStatus(codes.Error, "cleared")
+				span.End()
+				delete(o.pendingSpans, msgID)
+			}
 		}
 	}
 }
 
+// StartIdleReaper stops agents idle for longer than their idle timeout
+// (agents.<id>.idle_timeout, else defaults.idle_timeout). Timeouts are
+// resolved on every tick, so reloads apply without a restart.
 func (o *Orchestrator) StartIdleReaper(ctx context.Context) {
-	if o.cfg.IdleTimeout == 0 {
-		return
-	}
-
-	ticker := time.NewTicker(1 * time.Minute)
+	ticker := time.NewTicker(o.reapInterval)
 	defer ticker.Stop()
 
 	for {
@@ -998,88 +1520,31 @@ func (o *Orchestrator) StartIdleReaper(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			idle := o.sessions.ListIdle(o.cfg.IdleTimeout)
-			for _, agentID := range idle {
-				if q := o.getQueue(agentID); q.Busy() {
-					slog.Info("skipping idle stop for busy agent", "agent", agentID, "reason", "queue")
-					o.sessions.Touch(agentID)
-					continue
-				}
-				if o.isAgentBusy(agentID) {
-					slog.Info("skipping idle stop for busy agent", "agent", agentID, "reason", "agent")
-					o.sessions.Touch(agentID)
-					continue
-				}
-				slog.Info("stopping idle agent", "agent", agentID, "timeout", o.cfg.IdleTimeout)
-				if err := o.StopAgent(ctx, agentID); err != nil {
-					slog.Error("failed to stop idle agent", "agent", agentID, "error", err)
-					continue
-				}
-				o.publishIdleStopEvent(agentID)
-			}
+			o.reapIdle(ctx)
 		}
 	}
 }
 
-// StartNixGC runs nix-collect-garbage -d once per day at a random time
-// in all running agent containers that have nix_enabled.
-func (o *Orchestrator) StartNixGC(ctx context.Context) {
-	for {
-		// Sleep for a random duration between 0 and 24 hours
-		delay := time.Duration(rand.Int64N(int64(24 * time.Hour)))
-		slog.Info("nix-collect-garbage scheduled", "in", delay.Round(time.Minute))
-
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(delay):
+func (o *Orchestrator) reapIdle(ctx context.Context) {
+	for _, agentID := range o.sessions.ListIdle(o.registry.ResolveIdleTimeout) {
+		if q := o.getQueue(agentID); q.Busy() {
+			slog.Info("skipping idle stop for busy agent", "agent", agentID, "reason", "queue")
+			o.sessions.Touch(agentID)
+			continue
 		}
-
-		agents, err := o.registry.List()
-		if err != nil {
-			slog.Error("nix-gc: failed to list agents", "error", err)
+		if o.isAgentBusy(agentID) {
+			slog.Info("skipping idle stop for busy agent", "agent", agentID, "reason", "agent")
+			o.sessions.Touch(agentID)
 			continue
 		}
-
-		for _, ag := range agents {
-			def, ok := o.registry.GetDefinition(ag.ID)
-			if !ok || !def.NixEnabled {
-				continue
-			}
-
-			// Stagger agents 10-15 minutes apart
-			stagger := time.Duration(rand.Int64N(int64(5*time.Minute))) + 10*time.Minute
-			slog.Info("nix-gc: next agent scheduled", "agent", ag.ID, "in", stagger.Round(time.Minute))
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(stagger):
-			}
-
-			if err := o.EnsureAgent(ctx, ag.ID); err != nil {
-				slog.Warn("nix-gc: failed to start agent", "agent", ag.ID, "error", err)
-				continue
-			}
-
-			// Upgrade all nix packages first
-			output, err := o.containers.Exec(ctx, ag.ID, []string{"nix", "profile", "upgrade", "--all"})
-			if err != nil {
-				slog.Warn("nix-upgrade failed, skipping agent", "agent", ag.ID, "error", err)
-				continue
-			}
-			if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) > 0 && lines[len(lines)-1] != "" {
-				slog.Info("nix-upgrade: [" + ag.ID + "] " + lines[len(lines)-1])
-			}
-
-			// Garbage collect old generations
-			output, err = o.containers.Exec(ctx, ag.ID, []string{"nix-collect-garbage", "-d"})
-			if err != nil {
-				slog.Warn("nix-collect-garbage failed", "agent", ag.ID, "error", err)
-				continue
-			}
-			if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) > 0 && lines[len(lines)-1] != "" {
-				slog.Info("nix-collect-garbage: [" + ag.ID + "] " + lines[len(lines)-1])
-			}
+		if o.inMaintenance(agentID, time.Now()) {
+			slog.Info("skipping idle stop for agent in maintenance", "agent", agentID)
+			o.sessions.Touch(agentID)
+			continue
+		}
+		slog.Info("stopping idle agent", "agent", agentID, "timeout", o.registry.ResolveIdleTimeout(agentID))
+		if err := o.stopAgent(ctx, agentID, "idle_timeout"); err != nil {
+			slog.Error("failed to stop idle agent", "agent", agentID, "error", err)
 		}
 	}
 }
@@ -1089,18 +1554,7 @@ func (o *Orchestrator) publishAgentStartEvent(agentID string) {
 		return
 	}
 
-	event := map[string]any{
-		"type":      "agent_started",
-		"agent_id":  agentID,
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-	}
-
-	data, err := json.Marshal(event)
-	if err != nil {
-		return
-	}
-
-	_ = o.client.Publish(natsbus.TopicEventsAgent(agentID), data)
+	_ = o.client.PublishJSON(natsbus.TopicEventsAgent(agentID), events.NewAgentStarted(agentID))
 }
 
 func (o *Orchestrator) publishAgentStopEvent(agentID, reason string) {
@@ -1108,23 +1562,28 @@ func (o *Orchestrator) publishAgentStopEvent(agentID, reason string) {
 		return
 	}
 
-	event := map[string]any{
-		"type":      "agent_stopped",
-		"agent_id":  agentID,
-		"reason":    reason,
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-	}
-
-	data, err := json.Marshal(event)
-	if err != nil {
-		return
-	}
+	_ = o.client.PublishJSON(natsbus.TopicEventsAgent(agentID), events.NewAgentStopped(agentID, reason))
 
-	_ = o.client.Publish(natsbus.TopicEventsAgent(agentID), data)
+	stopped := natsbus.NewLifecycleEvent(natsbus.LifecycleStopped, agentID)
+	stopped.Reason = reason
+	o.publishLifecycleEvent(stopped)
 }
 
-func (o *Orchestrator) publishIdleStopEvent(agentID string) {
-	o.publishAgentStopEvent(agentID, "idle_timeout")
+// publishLifecycleEvent records an agent lifecycle event for the activity
+// feed and publishes it for external supervisors on agent.lifecycle.<id>.
+func (o *Orchestrator) publishLifecycleEvent(ev natsbus.LifecycleEvent) {
+	if err := o.store.AddAgentEvent(&store.AgentEvent{
+		AgentID:     ev.AgentID,
+		Type:        ev.Type,
+		Reason:      ev.Reason,
+		ContainerID: ev.ContainerID,
+	}); err != nil {
+		slog.Warn("failed to record lifecycle event", "agent", ev.AgentID, "type", ev.Type, "error", err)
+	}
+	if o.client == nil {
+		return
+	}
+	_ = o.client.PublishJSON(natsbus.TopicAgentLifecycle(ev.AgentID), ev)
 }
 
 func (o *Orchestrator) ListRunning(ctx context.Context) ([]container.ContainerInfo, error) {
@@ -1143,6 +1602,8 @@ func (o *Orchestrator) WriteVolumeBytes(ctx context.Context, workspace, filePath
 	return o.containers.WriteVolumeBytes(ctx, workspace, filePath, data, image)
 }
 
+// ExecInAgent runs cmd in the agent's running container, killing it after
+// defaults.exec_timeout.
 func (o *Orchestrator) ExecInAgent(ctx context.Context, agentID string, cmd []string) (string, error) {
-	return o.containers.Exec(ctx, agentID, cmd)
+	return o.containers.Exec(ctx, agentID, cmd, o.defaults().ExecTimeout)
 }
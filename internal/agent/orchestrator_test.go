@@ -0,0 +1,297 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/nats-io/nats.go"
+)
+
+func TestHandleAgentOutputLinksReply(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+
+	req := &store.Message{AgentID: "alpha", Sender: "user", Content: "ping"}
+	if err := o.store.SaveMessage(req); err != nil {
+		t.Fatal(err)
+	}
+	o.mu.Lock()
+	o.pendingReply["m1"] = req.ID
+	o.mu.Unlock()
+
+	data, _ := json.Marshal(map[string]string{"type": "result", "content": "pong", "msg_id": "m1"})
+	o.handleAgentOutput(&nats.Msg{Subject: natsbus.TopicAgentOutput("alpha"), Data: data})
+
+	msgs, err := o.store.GetMessages("alpha", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply *store.Message
+	for i := range msgs {
+		if msgs[i].Sender == "agent" {
+			reply = &msgs[i]
+		}
+	}
+	if reply == nil {
+		t.Fatal("expected stored agent reply")
+	}
+	if reply.ReplyTo == nil || *reply.ReplyTo != req.ID {
+		t.Errorf("expected reply_to %d, got %v", req.ID, reply.ReplyTo)
+	}
+
+	o.mu.Lock()
+	_, leaked := o.pendingReply["m1"]
+	o.mu.Unlock()
+	if leaked {
+		t.Error("expected pending reply to be cleared")
+	}
+}
+
+func TestHandleAgentOutputRejectsForgedAgentID(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha", "beta")
+	var delivered []string
+	o.OnOutput(func(agentID, content string, _ map[string]string) {
+		delivered = append(delivered, agentID+": "+content)
+	})
+
+	forged, _ := json.Marshal(map[string]string{"type": "result", "content": "forged", "agent_id": "beta"})
+	o.handleAgentOutput(&nats.Msg{Subject: natsbus.TopicAgentOutput("alpha"), Data: forged})
+	genuine, _ := json.Marshal(map[string]string{"type": "result", "content": "genuine", "agent_id": "alpha"})
+	o.handleAgentOutput(&nats.Msg{Subject: natsbus.TopicAgentOutput("alpha"), Data: genuine})
+
+	msgs, err := o.store.GetMessages("alpha", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Content != "genuine" {
+		t.Errorf("stored messages = %+v, want only the genuine reply", msgs)
+	}
+	if len(delivered) != 1 || delivered[0] != "alpha: genuine" {
+		t.Errorf("delivered = %v, want only the genuine reply", delivered)
+	}
+}
+
+func TestUnknownAgentNotFound(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+
+	if err := o.HandleMessage(context.Background(), "nope", "hi", nil); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("HandleMessage: got %v, want ErrAgentNotFound", err)
+	}
+	if _, err := o.agentOpts("nope", startOverrides{}); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("agentOpts: got %v, want ErrAgentNotFound", err)
+	}
+}
+
+func TestSessionIDPersistsAcrossStarts(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	ctx := context.Background()
+
+	first, err := o.agentOpts("alpha", startOverrides{})
+	if err != nil || first.SessionID == "" {
+		t.Fatalf("agentOpts: session id %q, %v", first.SessionID, err)
+	}
+	// The container manager has no daemon here; stopping still runs the
+	// orchestrator's teardown between the two starts.
+	_ = o.StopAgent(ctx, "alpha")
+	second, err := o.agentOpts("alpha", startOverrides{})
+	if err != nil || second.SessionID != first.SessionID {
+		t.Fatalf("restart session id = %q (%v), want %q", second.SessionID, err, first.SessionID)
+	}
+
+	if err := o.ClearSession(ctx, "alpha"); err != nil {
+		t.Fatalf("ClearSession: %v", err)
+	}
+	cleared, _ := o.agentOpts("alpha", startOverrides{})
+	if cleared.SessionID == "" || cleared.SessionID == first.SessionID {
+		t.Errorf("session id after clear = %q, want a new one (was %q)", cleared.SessionID, first.SessionID)
+	}
+}
+
+func TestBounceAgentKeepsSession(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	ctx := context.Background()
+
+	var started []container.AgentOpts
+	o.startContainer = func(ctx context.Context, opts container.AgentOpts) (*container.ContainerInfo, error) {
+		started = append(started, opts)
+		_ = o.client.Publish(natsbus.TopicAgentReady(opts.AgentID), []byte(`{}`))
+		return &container.ContainerInfo{ID: fmt.Sprintf("c%d", len(started)), AgentID: opts.AgentID}, nil
+	}
+
+	events := make(chan map[string]any, 32)
+	sub, err := o.client.Subscribe(natsbus.TopicEventsAgent("alpha"), func(msg *nats.Msg) {
+		var ev map[string]any
+		if json.Unmarshal(msg.Data, &ev) == nil {
+			events <- ev
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	if err := o.startAgent(ctx, "alpha"); err != nil {
+		t.Fatalf("startAgent: %v", err)
+	}
+	id, err := o.BounceAgent(ctx, "alpha", false)
+	if err != nil {
+		t.Fatalf("BounceAgent: %v", err)
+	}
+	if id != "c2" || len(started) != 2 {
+		t.Fatalf("container id = %q after %d starts, want c2 after 2", id, len(started))
+	}
+	if started[1].SessionID == "" || started[1].SessionID != started[0].SessionID {
+		t.Errorf("session id %q after restart, want %q", started[1].SessionID, started[0].SessionID)
+	}
+
+	if _, err := o.BounceAgent(ctx, "alpha", true); err != nil {
+		t.Fatalf("BounceAgent clear: %v", err)
+	}
+	if started[2].SessionID == "" || started[2].SessionID == started[1].SessionID {
+		t.Errorf("session id %q after clear, want a new one", started[2].SessionID)
+	}
+
+	var restarted, stopped int
+	timeout := time.After(5 * time.Second)
+	for restarted < 2 {
+		var ev map[string]any
+		select {
+		case ev = <-events:
+		case <-timeout:
+			t.Fatalf("got %d agent_restarted events, want 2", restarted)
+		}
+		switch ev["type"] {
+		case "agent_restarted":
+			restarted++
+			if restarted == 1 && (ev["old_container_id"] != "c1" || ev["container_id"] != "c2" || ev["session_cleared"] != false) {
+				t.Errorf("agent_restarted event = %v", ev)
+			}
+		case "agent_stopped":
+			stopped++
+		}
+	}
+	if stopped != 2 {
+		t.Errorf("got %d agent_stopped events, want 2", stopped)
+	}
+
+	if _, err := o.BounceAgent(ctx, "ghost", false); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("BounceAgent(ghost) = %v, want ErrAgentNotFound", err)
+	}
+}
+
+func TestReapIdleUsesPerAgentTimeout(t *testing.T) {
+	o := newTestOrchestrator(t, "cold", "warm", "pinned")
+	defaults := o.defaults() // idle_timeout 1ms
+	if err := o.registry.Update(map[string]config.AgentDefinition{
+		"cold":   {Workspace: "cold"},
+		"warm":   {Workspace: "warm", IdleTimeout: time.Hour},
+		"pinned": {Workspace: "pinned", IdleTimeout: -1},
+	}, defaults); err != nil {
+		t.Fatal(err)
+	}
+
+	idleSince := time.Now().Add(-time.Minute)
+	for _, id := range []string{"cold", "warm", "pinned"} {
+		o.sessions.Set(id, &Session{AgentID: id, Status: "running", StartedAt: idleSince, LastActive: idleSince})
+	}
+
+	o.reapIdle(context.Background())
+
+	if o.sessions.Get("cold") != nil {
+		t.Error("default-timeout agent wasn't reaped")
+	}
+	if o.sessions.Get("warm") == nil {
+		t.Error("agent with a longer idle_timeout was reaped")
+	}
+	if o.sessions.Get("pinned") == nil {
+		t.Error("agent with a negative idle_timeout was reaped")
+	}
+}
+
+func TestStartInjectsGlobalContext(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	cfg := o.defaults()
+	cfg.InjectGlobalContext = true
+	o.UpdateDefaults(cfg)
+	if err := o.registry.SaveUserMD("# User\nName: Ada\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	var started container.AgentOpts
+	o.startContainer = func(ctx context.Context, opts container.AgentOpts) (*container.ContainerInfo, error) {
+		started = opts
+		_ = o.client.Publish(natsbus.TopicAgentReady(opts.AgentID), []byte(`{}`))
+		return &container.ContainerInfo{ID: "c1", AgentID: opts.AgentID}, nil
+	}
+	if err := o.startAgent(context.Background(), "alpha"); err != nil {
+		t.Fatalf("startAgent: %v", err)
+	}
+
+	files := map[string]string{}
+	for _, sf := range started.SecretFiles {
+		files[sf.Target] = string(sf.Content)
+	}
+	if got := files[globalContextDir+"/USER.md"]; got != "# User\nName: Ada\n" {
+		t.Errorf("injected USER.md = %q", got)
+	}
+	if _, ok := files[globalContextDir+"/CLAUDE.md"]; !ok {
+		t.Error("global CLAUDE.md not injected")
+	}
+	if started.Env["GLOBAL_CONTEXT_DIR"] != globalContextDir {
+		t.Errorf("GLOBAL_CONTEXT_DIR = %q", started.Env["GLOBAL_CONTEXT_DIR"])
+	}
+
+	// Saving the profile pushes the new content to the running agent.
+	refreshed := make(chan map[string]string, 1)
+	sub, err := o.client.Subscribe(natsbus.TopicAgentControl("alpha"), func(msg *nats.Msg) {
+		var cmd map[string]string
+		if json.Unmarshal(msg.Data, &cmd) == nil && cmd["command"] == "refresh_global_context" {
+			refreshed <- cmd
+		}
+		_ = msg.Respond([]byte(`{"status":"ok"}`))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	if err := o.SaveUserProfile("# User\nName: Grace\n"); err != nil {
+		t.Fatalf("SaveUserProfile: %v", err)
+	}
+	select {
+	case cmd := <-refreshed:
+		if cmd["user_md"] != "# User\nName: Grace\n" {
+			t.Errorf("refreshed user_md = %q", cmd["user_md"])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("running agent was not refreshed")
+	}
+}
+
+func TestStopMode(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	d := o.defaults()
+	d.StopMode = "stop"
+	o.UpdateDefaults(d)
+	for reason, want := range map[string]string{"manual": "stop", "idle_timeout": "stop", "restart": "remove", "unhealthy": "remove"} {
+		if got := o.stopMode(reason); got != want {
+			t.Errorf("stop_mode stop, %s: got %q, want %q", reason, got, want)
+		}
+	}
+
+	d.StopMode, d.IdleStopMode = "remove", "stop"
+	o.UpdateDefaults(d)
+	if got := o.stopMode("idle_timeout"); got != "stop" {
+		t.Errorf("idle_stop_mode stop: got %q", got)
+	}
+	if got := o.stopMode("manual"); got != "remove" {
+		t.Errorf("stop_mode remove: got %q", got)
+	}
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mtzanidakis/praktor/internal/container"
+)
+
+// Meta keys a message may use to tweak the container it starts. Only keys
+// listed in defaults.message_overrides ("model", "env.NAME") are honoured.
+const (
+	metaOverrideModel     = "override_model"
+	metaOverrideEnvPrefix = "override_env."
+)
+
+// startOverrides are per-message changes to the options of the container a
+// message starts. Env is create-time, so they have no effect on an agent
+// that is already running.
+type startOverrides struct {
+	Model string
+	Env   map[string]string
+}
+
+func (ov startOverrides) empty() bool {
+	return ov.Model == "" && len(ov.Env) == 0
+}
+
+func isOverrideKey(key string) bool {
+	return key == metaOverrideModel || strings.HasPrefix(key, metaOverrideEnvPrefix)
+}
+
+// parseOverrides extracts the overrides in meta that allowed permits.
+// Anything else is logged and dropped.
+func parseOverrides(agentID string, meta map[string]string, allowed []string) startOverrides {
+	var ov startOverrides
+	for key, value := range meta {
+		if !isOverrideKey(key) || value == "" {
+			continue
+		}
+		name := "model"
+		if key != metaOverrideModel {
+			name = "env." + strings.TrimPrefix(key, metaOverrideEnvPrefix)
+		}
+		if !slices.Contains(allowed, name) {
+			slog.Warn("message override not allowed", "agent", agentID, "key", name)
+			continue
+		}
+		if name == "model" {
+			ov.Model = value
+			continue
+		}
+		if ov.Env == nil {
+			ov.Env = make(map[string]string)
+		}
+		ov.Env[strings.TrimPrefix(name, "env.")] = value
+	}
+	return ov
+}
+
+// apply writes the overrides onto opts. It runs after secret resolution so
+// override values are always literal and can't pull in vault secrets.
+func (ov startOverrides) apply(opts *container.AgentOpts) {
+	if ov.Model != "" {
+		opts.Model = ov.Model
+	}
+	if len(ov.Env) == 0 {
+		return
+	}
+	if opts.Env == nil {
+		opts.Env = make(map[string]string, len(ov.Env))
+	}
+	maps.Copy(opts.Env, ov.Env)
+}
@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+func TestParseOverridesAllowList(t *testing.T) {
+	meta := map[string]string{
+		"chat_id":               "1",
+		"override_model":        "claude-opus-4-7",
+		"override_env.VERBOSE":  "1",
+		"override_env.SECRET_X": "nope",
+	}
+
+	ov := parseOverrides("alpha", meta, []string{"model", "env.VERBOSE"})
+	if ov.Model != "claude-opus-4-7" {
+		t.Errorf("model = %q", ov.Model)
+	}
+	if len(ov.Env) != 1 || ov.Env["VERBOSE"] != "1" {
+		t.Errorf("env = %v, want only VERBOSE", ov.Env)
+	}
+
+	if ov := parseOverrides("alpha", meta, nil); !ov.empty() {
+		t.Errorf("expected no overrides without an allow list, got %+v", ov)
+	}
+}
+
+func TestOverrideChangesResolvedModel(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	o.UpdateDefaults(config.DefaultsConfig{Model: "claude-sonnet-4-6", MessageOverrides: []string{"model", "env.DEBUG"}})
+	if err := o.registry.Update(map[string]config.AgentDefinition{"alpha": {Workspace: "alpha"}},
+		config.DefaultsConfig{Model: "claude-sonnet-4-6"}); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := o.agentOpts("alpha", startOverrides{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Model != "claude-sonnet-4-6" {
+		t.Fatalf("base model = %q", base.Model)
+	}
+
+	meta := map[string]string{"override_model": "claude-opus-4-7", "override_env.DEBUG": "secret:token"}
+	opts, err := o.agentOpts("alpha", parseOverrides("alpha", meta, o.defaults().MessageOverrides))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Model != "claude-opus-4-7" {
+		t.Errorf("overridden model = %q, want claude-opus-4-7", opts.Model)
+	}
+	// Override values are applied after secret resolution, so stay literal.
+	if opts.Env["DEBUG"] != "secret:token" {
+		t.Errorf("DEBUG = %q, want literal value", opts.Env["DEBUG"])
+	}
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/events"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+)
+
+// DryRunResult reports one DryRunAgent call. Durations are milliseconds;
+// StartMs and ReadyMs are zero when the agent was already running.
+type DryRunResult struct {
+	AgentID        string       `json:"agent_id"`
+	OK             bool         `json:"ok"`
+	Error          string       `json:"error,omitempty"`
+	AlreadyRunning bool         `json:"already_running"`
+	StartMs        int64        `json:"start_ms"` // container create, file copy and start
+	ReadyMs        int64        `json:"ready_ms"` // start until the ready handshake
+	AckMs          int64        `json:"ack_ms"`   // control ping round trip
+	TotalMs        int64        `json:"total_ms"`
+	Status         *AgentStatus `json:"status,omitempty"`
+}
+
+// startTimings is filled in by doStartAgent when the start context carries
+// one, so DryRunAgent can break down a start it triggered.
+type startTimings struct {
+	start time.Duration
+	ready time.Duration
+}
+
+type startTimingsKey struct{}
+
+func timingsFrom(ctx context.Context) *startTimings {
+	t, _ := ctx.Value(startTimingsKey{}).(*startTimings)
+	return t
+}
+
+// DryRunAgent checks the whole start path without sending a prompt: it
+// starts the agent if needed (image, secrets, mounts), waits for the ready
+// handshake and then for the answer to a control ping. Failures are
+// reported in the result; only an unknown agent is returned as an error.
+// The result is also published as an agent_dry_run event.
+func (o *Orchestrator) DryRunAgent(ctx context.Context, agentID string, timeout time.Duration) (*DryRunResult, error) {
+	if ag, err := o.registry.Get(agentID); err != nil {
+		return nil, fmt.Errorf("get agent: %w", err)
+	} else if ag == nil {
+		return nil, fmt.Errorf("%w: %s", ErrAgentNotFound, agentID)
+	}
+
+	res := &DryRunResult{AgentID: agentID}
+	begin := time.Now()
+	defer func() {
+		res.TotalMs = time.Since(begin).Milliseconds()
+		o.publishDryRunEvent(res)
+	}()
+
+	timings := &startTimings{}
+	res.AlreadyRunning = o.containers.GetRunning(agentID) != nil || o.sessions.Get(agentID) != nil
+	if !res.AlreadyRunning {
+		if err := o.startAgent(context.WithValue(ctx, startTimingsKey{}, timings), agentID); err != nil {
+			res.Error = err.Error()
+			return res, nil
+		}
+		res.StartMs = timings.start.Milliseconds()
+		res.ReadyMs = timings.ready.Milliseconds()
+	}
+
+	pinged := time.Now()
+	data, _ := json.Marshal(map[string]string{"command": "ping"})
+	resp, err := o.client.Request(natsbus.TopicAgentControl(agentID), data, timeout)
+	res.AckMs = time.Since(pinged).Milliseconds()
+	if err != nil {
+		res.Error = fmt.Sprintf("ping: %v", err)
+		return res, nil
+	}
+	var status AgentStatus
+	if err := json.Unmarshal(resp.Data, &status); err != nil {
+		res.Error = fmt.Sprintf("ping: invalid reply: %v", err)
+		return res, nil
+	}
+	res.Status = &status
+	res.OK = true
+	return res, nil
+}
+
+func (o *Orchestrator) publishDryRunEvent(res *DryRunResult) {
+	slog.Info("agent dry run", "agent", res.AgentID, "ok", res.OK, "error", res.Error, "total_ms", res.TotalMs)
+	if o.client == nil {
+		return
+	}
+	event := events.NewAgentDryRun(res.AgentID)
+	event.OK = res.OK
+	event.Error = res.Error
+	event.AlreadyRunning = res.AlreadyRunning
+	event.StartMs, event.ReadyMs, event.AckMs, event.TotalMs = res.StartMs, res.ReadyMs, res.AckMs, res.TotalMs
+	_ = o.client.PublishJSON(natsbus.TopicEventsAgent(res.AgentID), event)
+}
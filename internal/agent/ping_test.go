@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/nats-io/nats.go"
+)
+
+func TestDryRunAgent(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+
+	// The fake agent announces itself when started and acks pings.
+	o.startContainer = func(ctx context.Context, opts container.AgentOpts) (*container.ContainerInfo, error) {
+		time.Sleep(5 * time.Millisecond)
+		_ = o.client.Publish(natsbus.TopicAgentReady(opts.AgentID), []byte(`{}`))
+		return &container.ContainerInfo{ID: "c1", AgentID: opts.AgentID}, nil
+	}
+	sub, err := o.client.Subscribe(natsbus.TopicAgentControl("alpha"), func(msg *nats.Msg) {
+		_ = msg.Respond([]byte(`{"status":"ok","processing":false,"pending_messages":0}`))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	events := make(chan map[string]any, 8)
+	evSub, err := o.client.Subscribe(natsbus.TopicEventsAgent("alpha"), func(msg *nats.Msg) {
+		var ev map[string]any
+		if json.Unmarshal(msg.Data, &ev) == nil && ev["type"] == "agent_dry_run" {
+			events <- ev
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = evSub.Unsubscribe() }()
+
+	res, err := o.DryRunAgent(context.Background(), "alpha", 2*time.Second)
+	if err != nil {
+		t.Fatalf("DryRunAgent: %v", err)
+	}
+	if !res.OK || res.Error != "" || res.AlreadyRunning || res.Status == nil {
+		t.Fatalf("result = %+v", res)
+	}
+	if res.StartMs < 5 || res.TotalMs < res.StartMs+res.ReadyMs+res.AckMs {
+		t.Errorf("timings don't add up: %+v", res)
+	}
+
+	select {
+	case ev := <-events:
+		if ev["ok"] != true || ev["total_ms"] == nil {
+			t.Errorf("event = %v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no agent_dry_run event")
+	}
+
+	// Already running: only the ping is timed.
+	res, err = o.DryRunAgent(context.Background(), "alpha", 2*time.Second)
+	if err != nil || !res.OK || !res.AlreadyRunning || res.StartMs != 0 {
+		t.Errorf("second dry run = %+v, %v", res, err)
+	}
+}
+
+func TestDryRunAgentReportsFailures(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+
+	if _, err := o.DryRunAgent(context.Background(), "nope", time.Second); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("unknown agent: got %v, want ErrAgentNotFound", err)
+	}
+
+	o.startContainer = func(ctx context.Context, opts container.AgentOpts) (*container.ContainerInfo, error) {
+		return nil, container.ErrImageNotFound
+	}
+	res, err := o.DryRunAgent(context.Background(), "alpha", time.Second)
+	if err != nil {
+		t.Fatalf("DryRunAgent: %v", err)
+	}
+	if res.OK || res.Error == "" {
+		t.Errorf("result = %+v, want a start failure", res)
+	}
+}
@@ -1,11 +1,18 @@
 package agent
 
-import "sync"
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
 
 type QueuedMessage struct {
-	AgentID string
-	Text    string
-	Meta    map[string]string
+	AgentID     string
+	Text        string
+	Meta        map[string]string
+	CacheKey    string            // response cache key; empty = not cacheable
+	RequestID   int64             // stored id of the user message, linked as the reply's reply_to
+	SpanContext trace.SpanContext // span of the inbound message, parent of agent.execute
 }
 
 type AgentQueue struct {
@@ -38,6 +45,24 @@ func (q *AgentQueue) Dequeue() (QueuedMessage, bool) {
 	return msg, true
 }
 
+// Next dequeues the next message for the processor holding the lock. When
+// the queue is empty it releases the lock in the same critical section, so a
+// message enqueued after the last Dequeue cannot be stranded by a concurrent
+// TryLock failing just before Unlock.
+func (q *AgentQueue) Next() (QueuedMessage, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.pending) == 0 {
+		q.locked = false
+		return QueuedMessage{}, false
+	}
+
+	msg := q.pending[0]
+	q.pending = q.pending[1:]
+	return msg, true
+}
+
 func (q *AgentQueue) TryLock() bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -55,10 +80,13 @@ func (q *AgentQueue) Unlock() {
 	q.locked = false
 }
 
-func (q *AgentQueue) Clear() {
+// Clear drops the pending messages and returns them.
+func (q *AgentQueue) Clear() []QueuedMessage {
 	q.mu.Lock()
 	defer q.mu.Unlock()
+	dropped := q.pending
 	q.pending = nil
+	return dropped
 }
 
 func (q *AgentQueue) Len() int {
@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/events"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+)
+
+// workspaceUsageTTL is how long a measured workspace size is trusted. Each
+// measurement runs du in a helper container.
+const workspaceUsageTTL = 10 * time.Minute
+
+// ErrWorkspaceOverQuota is returned when an agent with an enforced
+// workspace_quota is asked to start while its workspace is over the limit.
+var ErrWorkspaceOverQuota = errors.New("workspace over quota")
+
+type workspaceUsage struct {
+	bytes int64
+	at    time.Time
+}
+
+// WorkspaceUsage returns the last measured size of the agent's workspace
+// volume in bytes, if one is cached. It never runs du itself.
+func (o *Orchestrator) WorkspaceUsage(agentID string) (int64, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	u, ok := o.usage[agentID]
+	return u.bytes, ok
+}
+
+// measureWorkspace returns the agent's workspace size, from the cache when
+// the cached value is younger than maxAge.
+func (o *Orchestrator) measureWorkspace(ctx context.Context, agentID string, maxAge time.Duration) (int64, error) {
+	o.mu.RLock()
+	u, ok := o.usage[agentID]
+	o.mu.RUnlock()
+	if ok && time.Since(u.at) < maxAge {
+		return u.bytes, nil
+	}
+
+	ag, err := o.registry.Get(agentID)
+	if err != nil {
+		return 0, fmt.Errorf("get agent: %w", err)
+	}
+	if ag == nil {
+		return 0, fmt.Errorf("%w: %s", ErrAgentNotFound, agentID)
+	}
+	n, err := o.volumeUsage(ctx, ag.Workspace, o.registry.ResolveImage(agentID))
+	if err != nil {
+		return 0, fmt.Errorf("measure workspace: %w", err)
+	}
+
+	o.mu.Lock()
+	o.usage[agentID] = workspaceUsage{bytes: n, at: time.Now()}
+	o.mu.Unlock()
+	return n, nil
+}
+
+// StartQuotaChecker periodically measures every agent's workspace, so
+// sizes are available to the API, and reports agents over their quota.
+func (o *Orchestrator) StartQuotaChecker(ctx context.Context) {
+	ticker := time.NewTicker(o.quotaInterval)
+	defer ticker.Stop()
+
+	o.checkQuotas(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.checkQuotas(ctx)
+		}
+	}
+}
+
+func (o *Orchestrator) checkQuotas(ctx context.Context) {
+	agents, err := o.registry.List()
+	if err != nil {
+		slog.Error("quota: failed to list agents", "error", err)
+		return
+	}
+	for _, ag := range agents {
+		if ctx.Err() != nil {
+			return
+		}
+		n, err := o.measureWorkspace(ctx, ag.ID, workspaceUsageTTL)
+		if err != nil {
+			slog.Warn("quota: failed to measure workspace", "agent", ag.ID, "error", err)
+			continue
+		}
+		o.updateQuotaState(ag.ID, n)
+	}
+}
+
+// updateQuotaState compares a measurement against the agent's quota and
+// reports crossing it, once per crossing rather than on every check.
+func (o *Orchestrator) updateQuotaState(agentID string, used int64) {
+	def, _ := o.registry.GetDefinition(agentID)
+	over := def.WorkspaceQuota != nil && used > def.WorkspaceQuota.MaxMB<<20
+
+	o.mu.Lock()
+	was := o.overQuota[agentID]
+	if over {
+		o.overQuota[agentID] = true
+	} else {
+		delete(o.overQuota, agentID)
+	}
+	o.mu.Unlock()
+
+	switch {
+	case over && !was:
+		limit := def.WorkspaceQuota.MaxMB << 20
+		slog.Warn("workspace over quota", "agent", agentID, "bytes", used, "quota_bytes", limit,
+			"enforced", def.WorkspaceQuota.Enforce)
+		o.publishQuotaEvent(agentID, used, limit, def.WorkspaceQuota.Enforce)
+	case !over && was:
+		slog.Info("workspace back under quota", "agent", agentID, "bytes", used)
+	}
+}
+
+// checkWorkspaceQuota refuses to start an agent whose enforced quota is
+// exceeded. Agents without an enforced quota are never measured here.
+func (o *Orchestrator) checkWorkspaceQuota(ctx context.Context, agentID string) error {
+	def, _ := o.registry.GetDefinition(agentID)
+	if def.WorkspaceQuota == nil || !def.WorkspaceQuota.Enforce {
+		return nil
+	}
+	used, err := o.measureWorkspace(ctx, agentID, workspaceUsageTTL)
+	if err == nil && used > def.WorkspaceQuota.MaxMB<<20 {
+		// Space may have been freed since; only a fresh size keeps it down.
+		used, err = o.measureWorkspace(ctx, agentID, 0)
+	}
+	if err != nil {
+		// Not knowing the size is no reason to keep the agent down.
+		slog.Warn("quota: failed to measure workspace before start", "agent", agentID, "error", err)
+		return nil
+	}
+	o.updateQuotaState(agentID, used)
+	if limit := def.WorkspaceQuota.MaxMB << 20; used > limit {
+		return fmt.Errorf("%w: %s uses %d MB of %d MB", ErrWorkspaceOverQuota, agentID, used>>20, def.WorkspaceQuota.MaxMB)
+	}
+	return nil
+}
+
+func (o *Orchestrator) publishQuotaEvent(agentID string, used, limit int64, enforced bool) {
+	if o.client == nil {
+		return
+	}
+	_ = o.client.PublishJSON(natsbus.TopicEventsAgent(agentID), events.NewWorkspaceQuotaExceeded(agentID, used, limit, enforced))
+}
@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/nats-io/nats.go"
+)
+
+func TestWorkspaceQuotaExceeded(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	agents := map[string]config.AgentDefinition{
+		"alpha": {Workspace: "alpha", WorkspaceQuota: &config.WorkspaceQuotaConfig{MaxMB: 1, Enforce: true}},
+	}
+	if err := o.registry.Update(agents, o.defaults()); err != nil {
+		t.Fatal(err)
+	}
+	var used, calls atomic.Int64
+	used.Store(2 << 20)
+	o.volumeUsage = func(_ context.Context, workspace, _ string) (int64, error) {
+		if workspace != "alpha" {
+			t.Errorf("measured workspace %q", workspace)
+		}
+		calls.Add(1)
+		return used.Load(), nil
+	}
+
+	events := make(chan map[string]any, 4)
+	sub, err := o.client.Subscribe(natsbus.TopicEventsAgent("alpha"), func(msg *nats.Msg) {
+		var ev map[string]any
+		if json.Unmarshal(msg.Data, &ev) == nil && ev["type"] == "workspace_quota_exceeded" {
+			events <- ev
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+	_ = o.client.Flush()
+
+	ctx := context.Background()
+	o.checkQuotas(ctx)
+	o.checkQuotas(ctx) // cached and still over: no second event
+
+	select {
+	case ev := <-events:
+		if ev["bytes"] != float64(2<<20) || ev["quota_bytes"] != float64(1<<20) || ev["enforced"] != true {
+			t.Errorf("unexpected event %v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no quota event")
+	}
+	_ = o.client.Flush()
+	select {
+	case ev := <-events:
+		t.Errorf("duplicate quota event %v", ev)
+	default:
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("du ran %d times, want 1 (cached)", n)
+	}
+	if n, ok := o.WorkspaceUsage("alpha"); !ok || n != 2<<20 {
+		t.Errorf("WorkspaceUsage = %d, %v", n, ok)
+	}
+
+	if err := o.checkWorkspaceQuota(ctx, "alpha"); !errors.Is(err, ErrWorkspaceOverQuota) {
+		t.Errorf("expected ErrWorkspaceOverQuota, got %v", err)
+	}
+
+	// Freed space is seen at the next start, despite the cached size.
+	used.Store(512 << 10)
+	if err := o.checkWorkspaceQuota(ctx, "alpha"); err != nil {
+		t.Errorf("start refused after freeing space: %v", err)
+	}
+}
+
+func TestWorkspaceQuotaUnset(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	o.volumeUsage = func(context.Context, string, string) (int64, error) {
+		t.Error("workspace measured for an agent without an enforced quota")
+		return 0, nil
+	}
+	if err := o.checkWorkspaceQuota(context.Background(), "alpha"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+// ErrRateLimited is returned by HandleMessage when an agent's message rate
+// limit is exceeded. Callers can match it with errors.Is and ask the user to
+// slow down.
+var ErrRateLimited = errors.New("agent rate limit exceeded")
+
+// tokenBucket is a classic token bucket: it holds up to burst tokens and
+// refills at rate tokens per second.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter tracks one token bucket per agent.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow consumes a token from the agent's bucket and reports whether the
+// message may proceed. A zero RatePerMinute disables limiting.
+func (rl *rateLimiter) Allow(agentID string, cfg config.RateLimitConfig) bool {
+	if cfg.RatePerMinute <= 0 {
+		return true
+	}
+	burst := float64(cfg.Burst)
+	if burst <= 0 {
+		burst = max(1, cfg.RatePerMinute)
+	}
+	rate := cfg.RatePerMinute / 60
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	b, ok := rl.buckets[agentID]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		rl.buckets[agentID] = b
+	}
+
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// resolveRateLimit returns the agent's rate limit override, falling back to
+// the defaults.
+func (o *Orchestrator) resolveRateLimit(agentID string) config.RateLimitConfig {
+	if def, ok := o.registry.GetDefinition(agentID); ok && def.RateLimit != nil {
+		return *def.RateLimit
+	}
+	return o.defaults().RateLimit
+}
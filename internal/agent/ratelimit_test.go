@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+func newTestLimiter(now *time.Time) *rateLimiter {
+	rl := newRateLimiter()
+	rl.now = func() time.Time { return *now }
+	return rl
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	now := time.Now()
+	rl := newTestLimiter(&now)
+	for i := range 100 {
+		if !rl.Allow("a", config.RateLimitConfig{}) {
+			t.Fatalf("message %d rejected with limiting disabled", i)
+		}
+	}
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	now := time.Now()
+	rl := newTestLimiter(&now)
+	cfg := config.RateLimitConfig{RatePerMinute: 6, Burst: 3}
+
+	for i := range 3 {
+		if !rl.Allow("a", cfg) {
+			t.Fatalf("message %d rejected within burst", i)
+		}
+	}
+	if rl.Allow("a", cfg) {
+		t.Fatal("message beyond burst was allowed")
+	}
+
+	// Buckets are per agent.
+	if !rl.Allow("b", cfg) {
+		t.Fatal("other agent should have its own bucket")
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	now := time.Now()
+	rl := newTestLimiter(&now)
+	cfg := config.RateLimitConfig{RatePerMinute: 6, Burst: 1} // one token every 10s
+
+	if !rl.Allow("a", cfg) {
+		t.Fatal("first message rejected")
+	}
+	if rl.Allow("a", cfg) {
+		t.Fatal("second message allowed before refill")
+	}
+
+	now = now.Add(5 * time.Second)
+	if rl.Allow("a", cfg) {
+		t.Fatal("message allowed after half a refill interval")
+	}
+
+	now = now.Add(5 * time.Second)
+	if !rl.Allow("a", cfg) {
+		t.Fatal("message rejected after full refill interval")
+	}
+
+	// A long pause never refills beyond the burst size.
+	now = now.Add(time.Hour)
+	if !rl.Allow("a", cfg) {
+		t.Fatal("message rejected after long pause")
+	}
+	if rl.Allow("a", cfg) {
+		t.Fatal("bucket refilled beyond burst")
+	}
+}
+
+func TestRateLimiterDefaultBurst(t *testing.T) {
+	now := time.Now()
+	rl := newTestLimiter(&now)
+	cfg := config.RateLimitConfig{RatePerMinute: 2}
+
+	for i := range 2 {
+		if !rl.Allow("a", cfg) {
+			t.Fatalf("message %d rejected within default burst", i)
+		}
+	}
+	if rl.Allow("a", cfg) {
+		t.Fatal("default burst should equal rate_per_minute")
+	}
+}
@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+)
+
+// ErrNothingToReplay is returned by ReplayLast when the agent has no stored
+// reply.
+var ErrNothingToReplay = errors.New("no reply to replay")
+
+// ReplayLast re-delivers the agent's most recent stored reply to the output
+// listeners, e.g. after a Telegram send failed. The agent is not run and
+// nothing is saved. meta addresses the delivery like a message's meta
+// (chat_id, telegram_bot); nil uses the agent's last message meta, and
+// listeners fall back to their sticky chat binding from there. Listeners
+// see meta["replay"] = "true".
+func (o *Orchestrator) ReplayLast(agentID string, meta map[string]string) error {
+	msg, err := o.store.GetLastAgentReply(agentID)
+	if err != nil {
+		return fmt.Errorf("load last reply: %w", err)
+	}
+	if msg == nil {
+		return fmt.Errorf("%w: %s", ErrNothingToReplay, agentID)
+	}
+
+	if meta == nil {
+		meta = o.getLastMeta(agentID)
+	}
+	meta = maps.Clone(meta)
+	if meta == nil {
+		meta = make(map[string]string)
+	}
+	meta["replay"] = "true"
+
+	slog.Info("replaying last reply", "agent", agentID, "message", msg.ID)
+	for _, l := range o.outputListeners() {
+		l(agentID, msg.Content, meta)
+	}
+	return nil
+}
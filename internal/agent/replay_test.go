@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+func TestReplayLast(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+
+	if err := o.ReplayLast("alpha", nil); !errors.Is(err, ErrNothingToReplay) {
+		t.Fatalf("expected ErrNothingToReplay, got %v", err)
+	}
+
+	_ = o.store.SaveMessage(&store.Message{AgentID: "alpha", Sender: "user", Content: "question"})
+	_ = o.store.SaveMessage(&store.Message{AgentID: "alpha", Sender: "agent", Content: "the answer"})
+	before, _ := o.store.GetMessages("alpha", 100)
+
+	type delivery struct {
+		content string
+		meta    map[string]string
+	}
+	var got []delivery
+	o.OnOutput(func(agentID, content string, meta map[string]string) {
+		got = append(got, delivery{content, meta})
+	})
+
+	if err := o.ReplayLast("alpha", map[string]string{"chat_id": "42"}); err != nil {
+		t.Fatalf("ReplayLast: %v", err)
+	}
+	if len(got) != 1 || got[0].content != "the answer" {
+		t.Fatalf("listeners got %+v, want the stored reply once", got)
+	}
+	if got[0].meta["chat_id"] != "42" || got[0].meta["replay"] != "true" {
+		t.Errorf("unexpected meta %v", got[0].meta)
+	}
+
+	if o.containers.GetRunning("alpha") != nil {
+		t.Error("replay started the container")
+	}
+	after, _ := o.store.GetMessages("alpha", 100)
+	if len(after) != len(before) {
+		t.Errorf("replay saved messages: %d before, %d after", len(before), len(after))
+	}
+}
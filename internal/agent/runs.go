@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"errors"
+	"log/slog"
+	"maps"
+)
+
+// ErrMessageDropped completes the run of a queued message that was dropped
+// before it reached the agent, e.g. by an abort.
+var ErrMessageDropped = errors.New("message dropped before it ran")
+
+// RunListener is notified when a message the orchestrator accepted has
+// finished: err is nil when the agent returned a normal result, and set when
+// the container failed to start or the query terminated abnormally. meta is
+// the message's own meta.
+type RunListener func(agentID string, meta map[string]string, err error)
+
+func (o *Orchestrator) OnRunComplete(listener RunListener) {
+	o.listenerMu.Lock()
+	defer o.listenerMu.Unlock()
+	o.runListeners = append(o.runListeners, listener)
+}
+
+func (o *Orchestrator) notifyRunComplete(agentID string, meta map[string]string, err error) {
+	o.listenerMu.RLock()
+	listeners := o.runListeners
+	o.listenerMu.RUnlock()
+	for _, l := range listeners {
+		l(agentID, meta, err)
+	}
+}
+
+// dropQueued drops agentID's pending messages and completes their runs with
+// ErrMessageDropped, so run listeners release what they hold for them.
+func (o *Orchestrator) dropQueued(agentID string) {
+	for _, msg := range o.getQueue(agentID).Clear() {
+		o.notifyRunComplete(agentID, msg.Meta, ErrMessageDropped)
+	}
+}
+
+// Notify delivers a gateway-generated notice to the output listeners as if
+// the agent had sent it, e.g. a scheduled task failure alert. Nothing is
+// saved and the agent is not run. Listeners see meta["notice"] = "true".
+func (o *Orchestrator) Notify(agentID, text string, meta map[string]string) {
+	meta = maps.Clone(meta)
+	if meta == nil {
+		meta = make(map[string]string)
+	}
+	meta["notice"] = "true"
+
+	slog.Info("delivering notice", "agent", agentID)
+	for _, l := range o.outputListeners() {
+		l(agentID, text, meta)
+	}
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/nats-io/nats.go"
+)
+
+func TestRunCompleteListener(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	type run struct {
+		meta map[string]string
+		err  error
+	}
+	var runs []run
+	o.OnRunComplete(func(_ string, meta map[string]string, err error) {
+		runs = append(runs, run{meta, err})
+	})
+
+	inFlight := func(msgID, taskID string) {
+		markInFlight(o, "alpha", msgID)
+		o.mu.Lock()
+		o.pendingMeta[msgID] = map[string]string{"task_id": taskID}
+		o.mu.Unlock()
+	}
+	deliver := func(msgID, reason string) {
+		data, _ := json.Marshal(map[string]string{"type": "result", "content": "x", "msg_id": msgID, "terminal_reason": reason})
+		o.handleAgentOutput(&nats.Msg{Subject: natsbus.TopicAgentOutput("alpha"), Data: data})
+	}
+
+	inFlight("m1", "t1")
+	deliver("m1", "completed")
+	inFlight("m2", "t2")
+	deliver("m2", "max_turns")
+	// Results that can't be matched to their message are not reported.
+	deliver("unknown", "")
+
+	if len(runs) != 2 {
+		t.Fatalf("got %d runs, want 2", len(runs))
+	}
+	if runs[0].meta["task_id"] != "t1" || runs[0].err != nil {
+		t.Errorf("first run = %+v, want success", runs[0])
+	}
+	if runs[1].meta["task_id"] != "t2" || runs[1].err == nil {
+		t.Errorf("second run = %+v, want failure", runs[1])
+	}
+}
+
+func TestNotify(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	var got map[string]string
+	o.OnOutput(func(_, content string, meta map[string]string) {
+		if content == "task failed" {
+			got = meta
+		}
+	})
+
+	meta := map[string]string{"chat_id": "42"}
+	o.Notify("alpha", "task failed", meta)
+
+	if got["chat_id"] != "42" || got["notice"] != "true" {
+		t.Errorf("listener meta = %v", got)
+	}
+	if _, ok := meta["notice"]; ok {
+		t.Error("caller's meta was modified")
+	}
+}
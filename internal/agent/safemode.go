@@ -0,0 +1,22 @@
+package agent
+
+import "errors"
+
+// ErrSafeMode is returned by HandleMessage and agent starts while the
+// gateway runs in safe mode, so callers can tell the user why nothing ran.
+var ErrSafeMode = errors.New("praktor is in safe mode: agents are not started")
+
+// SetSafeMode turns safe mode on or off. In safe mode no message is accepted
+// and no container is started, so state can be inspected after a crash.
+func (o *Orchestrator) SetSafeMode(on bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.safeMode = on
+}
+
+// SafeMode reports whether the orchestrator is in safe mode.
+func (o *Orchestrator) SafeMode() bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.safeMode
+}
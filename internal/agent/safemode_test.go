@@ -0,0 +1,33 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/container"
+)
+
+func TestSafeModeRejectsWork(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	started := false
+	o.startContainer = func(context.Context, container.AgentOpts) (*container.ContainerInfo, error) {
+		started = true
+		return &container.ContainerInfo{ID: "c1"}, nil
+	}
+	o.SetSafeMode(true)
+
+	if err := o.HandleMessage(context.Background(), "alpha", "hi", nil); !errors.Is(err, ErrSafeMode) {
+		t.Errorf("HandleMessage error = %v, want ErrSafeMode", err)
+	}
+	if err := o.startAgent(context.Background(), "alpha"); !errors.Is(err, ErrSafeMode) {
+		t.Errorf("startAgent error = %v, want ErrSafeMode", err)
+	}
+	if started {
+		t.Error("a container was started in safe mode")
+	}
+	msgs, err := o.store.GetMessages("alpha", 10)
+	if err != nil || len(msgs) != 0 {
+		t.Errorf("stored %d messages (%v), want none", len(msgs), err)
+	}
+}
@@ -3,6 +3,8 @@ package agent
 import (
 	"sync"
 	"time"
+
+	"github.com/mtzanidakis/praktor/internal/natsbus"
 )
 
 type Session struct {
@@ -12,6 +14,8 @@ type Session struct {
 	Status      string    `json:"status"`
 	StartedAt   time.Time `json:"started_at"`
 	LastActive  time.Time `json:"last_active"`
+	// Capabilities the container announced at startup; nil if none.
+	Capabilities *natsbus.Capabilities `json:"capabilities,omitempty"`
 }
 
 type SessionTracker struct {
@@ -51,14 +55,33 @@ func (t *SessionTracker) Touch(agentID string) {
 	}
 }
 
-func (t *SessionTracker) ListIdle(timeout time.Duration) []string {
+// List returns the IDs of all agents with a running session.
+func (t *SessionTracker) List() []string {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
+	ids := make([]string, 0, len(t.sessions))
+	for agentID := range t.sessions {
+		ids = append(ids, agentID)
+	}
+	return ids
+}
+
+// ListIdle returns the agents idle for longer than timeoutFor reports for
+// them. Agents whose timeout is zero or negative are never idle.
+// timeoutFor is called without the tracker lock held.
+func (t *SessionTracker) ListIdle(timeoutFor func(agentID string) time.Duration) []string {
+	t.mu.RLock()
+	lastActive := make(map[string]time.Time, len(t.sessions))
+	for agentID, s := range t.sessions {
+		lastActive[agentID] = s.LastActive
+	}
+	t.mu.RUnlock()
+
 	var idle []string
 	now := time.Now()
-	for agentID, s := range t.sessions {
-		if now.Sub(s.LastActive) > timeout {
+	for agentID, at := range lastActive {
+		if timeout := timeoutFor(agentID); timeout > 0 && now.Sub(at) > timeout {
 			idle = append(idle, agentID)
 		}
 	}
@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// WaitIdle blocks until no message sent to an agent is still waiting for
+// its result, or ctx is done.
+func (o *Orchestrator) WaitIdle(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		o.mu.RLock()
+		n := len(o.pendingMsgID)
+		o.mu.RUnlock()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d message(s) still in flight: %w", n, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// StopAll stops every running agent in parallel, publishing an
+// agent_stopped event (reason "shutdown") for each.
+func (o *Orchestrator) StopAll(ctx context.Context) {
+	running, _ := o.containers.ListRunning(ctx)
+	var wg sync.WaitGroup
+	for _, info := range running {
+		wg.Go(func() {
+			if err := o.stopAgent(ctx, info.AgentID, "shutdown"); err != nil {
+				slog.Warn("failed to stop agent", "agent", info.AgentID, "error", err)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// Flush waits until the orchestrator's published events have reached the
+// NATS server.
+func (o *Orchestrator) Flush() error {
+	if o.client == nil {
+		return nil
+	}
+	return o.client.Flush()
+}
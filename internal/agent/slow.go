@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/events"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+)
+
+// SlowListener is told, once per message, that the agent has not returned a
+// result within its slow_warning_after. meta is the message's own meta.
+type SlowListener func(agentID string, meta map[string]string, elapsed time.Duration)
+
+func (o *Orchestrator) OnSlow(listener SlowListener) {
+	o.listenerMu.Lock()
+	defer o.listenerMu.Unlock()
+	o.slowListeners = append(o.slowListeners, listener)
+}
+
+// armSlowWarning starts the agent's slow_warning_after timer for a message
+// just sent to it. The warning only informs; the message keeps running.
+func (o *Orchestrator) armSlowWarning(agentID, msgID string, meta map[string]string) {
+	def, _ := o.registry.GetDefinition(agentID)
+	after := def.SlowWarningAfter
+	if after <= 0 {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.slowTimers[msgID] = time.AfterFunc(after, func() { o.warnSlow(agentID, msgID, meta, after) })
+}
+
+// stopSlowWarning cancels a message's pending warning once its result is in.
+func (o *Orchestrator) stopSlowWarning(msgID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stopSlowWarningLocked(msgID)
+}
+
+// stopSlowWarningLocked is stopSlowWarning for callers holding o.mu.
+func (o *Orchestrator) stopSlowWarningLocked(msgID string) {
+	if t, ok := o.slowTimers[msgID]; ok {
+		t.Stop()
+		delete(o.slowTimers, msgID)
+	}
+}
+
+func (o *Orchestrator) warnSlow(agentID, msgID string, meta map[string]string, elapsed time.Duration) {
+	o.mu.Lock()
+	_, pending := o.slowTimers[msgID]
+	delete(o.slowTimers, msgID)
+	o.mu.Unlock()
+	if !pending {
+		return // the result won the race with the timer
+	}
+
+	slog.Info("agent slow to answer", "agent", agentID, "msg_id", msgID, "elapsed", elapsed)
+	if o.client != nil {
+		_ = o.client.PublishJSON(natsbus.TopicEventsAgent(agentID), events.NewAgentSlow(agentID, msgID, elapsed))
+	}
+	o.listenerMu.RLock()
+	listeners := o.slowListeners
+	o.listenerMu.RUnlock()
+	for _, l := range listeners {
+		l(agentID, meta, elapsed)
+	}
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/nats-io/nats.go"
+)
+
+// setSlowWarning gives the agent a slow_warning_after threshold.
+func setSlowWarning(t *testing.T, o *Orchestrator, agentID string, after time.Duration) {
+	t.Helper()
+	defs := map[string]config.AgentDefinition{agentID: {Workspace: agentID, SlowWarningAfter: after}}
+	if err := o.registry.Update(defs, o.defaults()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSlowWarningFiresOnce(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	setSlowWarning(t, o, "alpha", 30*time.Millisecond)
+
+	events := make(chan map[string]any, 4)
+	sub, err := o.client.Subscribe(natsbus.TopicEventsAgent("alpha"), func(msg *nats.Msg) {
+		var ev map[string]any
+		if json.Unmarshal(msg.Data, &ev) == nil && ev["type"] == "agent_slow" {
+			events <- ev
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sub.Unsubscribe() })
+	_ = o.client.Flush()
+
+	warned := make(chan string, 4)
+	o.OnSlow(func(agentID string, meta map[string]string, _ time.Duration) { warned <- meta["chat_id"] })
+
+	markInFlight(o, "alpha", "m1")
+	o.armSlowWarning("alpha", "m1", map[string]string{"chat_id": "42"})
+
+	select {
+	case chat := <-warned:
+		if chat != "42" {
+			t.Errorf("warned chat %q, want 42", chat)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no slow warning")
+	}
+	select {
+	case ev := <-events:
+		if ev["msg_id"] != "m1" {
+			t.Errorf("agent_slow event = %v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no agent_slow event")
+	}
+	select {
+	case <-warned:
+		t.Fatal("warned twice for one message")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSlowWarningStoppedByResult(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	setSlowWarning(t, o, "alpha", 50*time.Millisecond)
+
+	warned := make(chan struct{}, 1)
+	o.OnSlow(func(string, map[string]string, time.Duration) { warned <- struct{}{} })
+
+	markInFlight(o, "alpha", "m1")
+	o.armSlowWarning("alpha", "m1", map[string]string{"chat_id": "42"})
+	data, _ := json.Marshal(map[string]string{"type": "result", "content": "done", "msg_id": "m1"})
+	o.handleAgentOutput(&nats.Msg{Subject: natsbus.TopicAgentOutput("alpha"), Data: data})
+
+	select {
+	case <-warned:
+		t.Fatal("warned about a message that already had its result")
+	case <-time.After(150 * time.Millisecond):
+	}
+}
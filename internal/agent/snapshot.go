@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrRestoreInProgress is returned by starts of an agent whose workspace a
+// snapshot is being restored into, and by a second restore of it.
+var ErrRestoreInProgress = errors.New("workspace restore in progress")
+
+// SnapshotAgent writes a snapshot of the agent's workspace volume to w.
+// The agent may keep running; files it writes meanwhile may or may not be
+// included.
+func (o *Orchestrator) SnapshotAgent(ctx context.Context, agentID string, w io.Writer) error {
+	ag, err := o.registry.Get(agentID)
+	if err != nil {
+		return fmt.Errorf("get agent: %w", err)
+	}
+	if ag == nil {
+		return fmt.Errorf("%w: %s", ErrAgentNotFound, agentID)
+	}
+	if err := o.containers.SnapshotWorkspace(ctx, w, agentID, ag.Workspace, o.registry.ResolveImage(agentID)); err != nil {
+		return fmt.Errorf("snapshot workspace: %w", err)
+	}
+	return nil
+}
+
+// RestoreAgentSnapshot replaces the agent's workspace with a snapshot read
+// from r. A running agent is stopped first, aborting its current run. The
+// agent is not started while the restore runs; it starts again on the next
+// message after it.
+func (o *Orchestrator) RestoreAgentSnapshot(ctx context.Context, agentID string, r io.Reader) error {
+	ag, err := o.registry.Get(agentID)
+	if err != nil {
+		return fmt.Errorf("get agent: %w", err)
+	}
+	if ag == nil {
+		return fmt.Errorf("%w: %s", ErrAgentNotFound, agentID)
+	}
+	if err := o.beginRestore(ctx, agentID); err != nil {
+		return err
+	}
+	defer o.endRestore(agentID)
+
+	if o.containers.GetRunning(agentID) != nil {
+		if err := o.stopAgent(ctx, agentID, "restore_snapshot"); err != nil {
+			return fmt.Errorf("stop agent: %w", err)
+		}
+	}
+	if err := o.containers.RestoreWorkspace(ctx, r, agentID, ag.Workspace, o.registry.ResolveImage(agentID)); err != nil {
+		return fmt.Errorf("restore workspace: %w", err)
+	}
+	return nil
+}
+
+// beginRestore marks agentID as restoring, so doStartAgent refuses it, and
+// waits for a start that was already in progress to finish.
+func (o *Orchestrator) beginRestore(ctx context.Context, agentID string) error {
+	o.mu.Lock()
+	if o.restoring[agentID] {
+		o.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrRestoreInProgress, agentID)
+	}
+	o.restoring[agentID] = true
+	call := o.starting[agentID]
+	o.mu.Unlock()
+
+	if call != nil {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			o.endRestore(agentID)
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (o *Orchestrator) endRestore(agentID string) {
+	o.mu.Lock()
+	delete(o.restoring, agentID)
+	o.mu.Unlock()
+}
+
+// isRestoring reports whether a snapshot is being restored into agentID's
+// workspace.
+func (o *Orchestrator) isRestoring(agentID string) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.restoring[agentID]
+}
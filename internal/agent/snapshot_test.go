@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/container"
+)
+
+func TestRestoreBlocksStarts(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	entered, release := make(chan struct{}), make(chan struct{})
+	starts := 0
+	o.startContainer = func(context.Context, container.AgentOpts) (*container.ContainerInfo, error) {
+		starts++
+		close(entered)
+		<-release
+		return nil, errors.New("no docker")
+	}
+
+	go func() { _ = o.startAgent(context.Background(), "alpha") }()
+	<-entered
+
+	// A restore waits for the start already in progress.
+	begun := make(chan error, 1)
+	go func() { begun <- o.beginRestore(context.Background(), "alpha") }()
+	select {
+	case err := <-begun:
+		t.Fatalf("beginRestore returned %v during a start", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-begun; err != nil {
+		t.Fatalf("beginRestore: %v", err)
+	}
+
+	if err := o.startAgent(context.Background(), "alpha"); !errors.Is(err, ErrRestoreInProgress) {
+		t.Errorf("startAgent error = %v, want ErrRestoreInProgress", err)
+	}
+	if err := o.beginRestore(context.Background(), "alpha"); !errors.Is(err, ErrRestoreInProgress) {
+		t.Errorf("second restore error = %v, want ErrRestoreInProgress", err)
+	}
+	if starts != 1 {
+		t.Errorf("container started %d times, want 1", starts)
+	}
+
+	o.endRestore("alpha")
+	if o.isRestoring("alpha") {
+		t.Error("agent still marked restoring")
+	}
+}
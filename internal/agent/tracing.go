@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/mtzanidakis/praktor/internal/events"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/mtzanidakis/praktor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func (o *Orchestrator) popPendingSpan(msgID string) trace.Span {
+	if msgID == "" {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	span := o.pendingSpans[msgID]
+	delete(o.pendingSpans, msgID)
+	return span
+}
+
+// endExecuteSpan records receipt of an agent result as a child of the
+// message's agent.execute span and ends both. It returns the ended execute
+// span (nil if the message was not tracked) so callers can log its trace id.
+func (o *Orchestrator) endExecuteSpan(msgID, terminalReason string, abnormal bool) trace.Span {
+	span := o.popPendingSpan(msgID)
+	if span == nil {
+		return nil
+	}
+
+	ctx := trace.ContextWithSpan(context.Background(), span)
+	_, result := tracing.Tracer().Start(ctx, "agent.result",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("praktor.terminal_reason", terminalReason)))
+	if abnormal {
+		result.SetStatus(codes.Error, terminalReason)
+		span.SetStatus(codes.Error, terminalReason)
+	}
+	result.End()
+	span.End()
+	return span
+}
+
+// traceID returns the trace id of span, or "" when it is nil or not sampled
+// by a real tracer provider.
+func traceID(span trace.Span) string {
+	if span == nil {
+		return ""
+	}
+	return tracing.TraceID(trace.ContextWithSpan(context.Background(), span))
+}
+
+// publishAgentErrorEvent reports a failed message execution, carrying the
+// trace id so the failure can be looked up in the tracing backend. A non-empty
+// detail is a human-readable explanation, sent as "error".
+func (o *Orchestrator) publishAgentErrorEvent(agentID, reason, detail string, span trace.Span) {
+	if o.client == nil {
+		return
+	}
+
+	event := events.NewAgentError(agentID, reason)
+	event.TraceID = traceID(span)
+	event.Error = detail
+	_ = o.client.PublishJSON(natsbus.TopicEventsAgent(agentID), event)
+}
@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+// OutputTransformer rewrites an agent's reply before it is stored and
+// dispatched to listeners. It only ever sees redacted text. One built
+// transformer serves all of an agent's replies, so Transform must be safe
+// for concurrent use.
+type OutputTransformer interface {
+	Transform(text string) string
+}
+
+// OutputTransformerFunc adapts a function to OutputTransformer.
+type OutputTransformerFunc func(text string) string
+
+func (f OutputTransformerFunc) Transform(text string) string { return f(text) }
+
+// TransformerFactory builds a transformer from one output_transforms entry.
+type TransformerFactory func(t config.OutputTransform) (OutputTransformer, error)
+
+// builtinTransformers maps each config.OutputTransformTypes entry to its
+// factory. A new transformer adds its factory here and its type, with
+// validation of its settings, to the config package.
+var builtinTransformers = map[string]TransformerFactory{
+	"regex_replace": newRegexReplace,
+	"max_length":    newMaxLength,
+	"append":        newAppend,
+}
+
+func newRegexReplace(t config.OutputTransform) (OutputTransformer, error) {
+	re, err := regexp.Compile(t.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	return OutputTransformerFunc(func(text string) string {
+		return re.ReplaceAllString(text, t.Replace)
+	}), nil
+}
+
+func newMaxLength(t config.OutputTransform) (OutputTransformer, error) {
+	return OutputTransformerFunc(func(text string) string {
+		n := 0
+		for i := range text {
+			if n == t.MaxChars {
+				return strings.TrimRight(text[:i], " \t\n") + "…"
+			}
+			n++
+		}
+		return text
+	}), nil
+}
+
+func newAppend(t config.OutputTransform) (OutputTransformer, error) {
+	return OutputTransformerFunc(func(text string) string {
+		if text == "" {
+			return text
+		}
+		return text + "\n\n" + t.Text
+	}), nil
+}
+
+// cachedPipeline is an agent's built output_transforms and the definition
+// entries it was built from.
+type cachedPipeline struct {
+	transforms []config.OutputTransform
+	pipeline   []OutputTransformer
+}
+
+// outputPipeline returns the agent's output_transforms, in config order. The
+// pipeline is built once and reused until a registry update changes the
+// agent's entries. Entries whose type is unknown or fails to build are
+// skipped with a warning.
+func (o *Orchestrator) outputPipeline(agentID string) []OutputTransformer {
+	def, ok := o.registry.GetDefinition(agentID)
+	if !ok || len(def.OutputTransforms) == 0 {
+		return nil
+	}
+	o.mu.RLock()
+	cached, ok := o.pipelines[agentID]
+	o.mu.RUnlock()
+	if ok && slices.Equal(cached.transforms, def.OutputTransforms) {
+		return cached.pipeline
+	}
+
+	pipeline := make([]OutputTransformer, 0, len(def.OutputTransforms))
+	for i, t := range def.OutputTransforms {
+		factory, ok := o.transformers[t.Type]
+		if !ok {
+			slog.Warn("skipping unknown output transform", "agent", agentID, "index", i, "type", t.Type)
+			continue
+		}
+		tr, err := factory(t)
+		if err != nil {
+			slog.Warn("skipping invalid output transform", "agent", agentID, "index", i, "type", t.Type, "error", err)
+			continue
+		}
+		pipeline = append(pipeline, tr)
+	}
+	o.mu.Lock()
+	o.pipelines[agentID] = cachedPipeline{transforms: def.OutputTransforms, pipeline: pipeline}
+	o.mu.Unlock()
+	return pipeline
+}
+
+// processOutput redacts an agent's reply and then runs its output
+// transforms over the result. Transforms never see the raw reply, and
+// whatever they produce is redacted again, so one that reassembles a secret
+// (e.g. by stripping what separated its parts) can't leak it.
+func (o *Orchestrator) processOutput(agentID, content string) string {
+	redact := o.secretRedactor(agentID)
+	content = redact(content)
+	pipeline := o.outputPipeline(agentID)
+	if len(pipeline) == 0 {
+		return content
+	}
+	transformed := content
+	for _, tr := range pipeline {
+		transformed = tr.Transform(transformed)
+	}
+	if transformed == content {
+		return content
+	}
+	return redact(transformed)
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/vault"
+	"github.com/nats-io/nats.go"
+)
+
+func setOutputTransforms(t *testing.T, o *Orchestrator, transforms ...config.OutputTransform) {
+	t.Helper()
+	defs := map[string]config.AgentDefinition{"alpha": {Workspace: "alpha", OutputTransforms: transforms}}
+	if err := o.registry.Update(defs, o.defaults()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuiltinTransformersCoverConfigTypes(t *testing.T) {
+	for _, typ := range config.OutputTransformTypes {
+		if builtinTransformers[typ] == nil {
+			t.Errorf("no built-in transformer for config type %q", typ)
+		}
+	}
+}
+
+func TestOutputTransformOrder(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	strip := config.OutputTransform{Type: "regex_replace", Pattern: `\[internal:[^\]]*\]\s*`}
+	disclaimer := config.OutputTransform{Type: "append", Text: "Not legal advice."}
+	limit := config.OutputTransform{Type: "max_length", MaxChars: 12}
+
+	tests := []struct {
+		name       string
+		transforms []config.OutputTransform
+		want       string
+	}{
+		{"none", nil, "[internal:step 2] Hello there, world"},
+		{"strip", []config.OutputTransform{strip}, "Hello there, world"},
+		{"strip then cap", []config.OutputTransform{strip, limit}, "Hello there,…"},
+		{"cap then strip", []config.OutputTransform{limit, strip}, "[internal:st…"},
+		{"cap then append", []config.OutputTransform{strip, limit, disclaimer}, "Hello there,…\n\nNot legal advice."},
+		{"append then cap", []config.OutputTransform{strip, disclaimer, limit}, "Hello there,…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setOutputTransforms(t, o, tt.transforms...)
+			if got := o.processOutput("alpha", "[internal:step 2] Hello there, world"); got != tt.want {
+				t.Errorf("processOutput = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactionPrecedesTransforms(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	o.vault = vault.New("passphrase")
+	ct, nonce, err := o.vault.Encrypt([]byte("s3cret-value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.store.SaveSecret(&store.Secret{ID: "token", Name: "token", Kind: "string", Value: ct, Nonce: nonce, Global: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	o.transformers["record"] = func(config.OutputTransform) (OutputTransformer, error) {
+		return OutputTransformerFunc(func(text string) string {
+			seen = append(seen, text)
+			return text
+		}), nil
+	}
+	// The first step would expose the secret if it ran before redaction;
+	// the second rejoins one the agent split up.
+	setOutputTransforms(t, o,
+		config.OutputTransform{Type: "record"},
+		config.OutputTransform{Type: "regex_replace", Pattern: `\[REDACTED\]`, Replace: "s3cret-value"},
+		config.OutputTransform{Type: "regex_replace", Pattern: `#`},
+	)
+
+	var sent string
+	o.OnOutput(func(agentID, content string, meta map[string]string) { sent = content })
+	data, _ := json.Marshal(map[string]string{"type": "result", "content": "key s3cret-value, again s3cret#-value", "msg_id": "m1"})
+	o.handleAgentOutput(&nats.Msg{Subject: natsbus.TopicAgentOutput("alpha"), Data: data})
+
+	if len(seen) != 1 || strings.Contains(seen[0], "s3cret-value") {
+		t.Errorf("transformer saw %q, want redacted input", seen)
+	}
+	if strings.Contains(sent, "s3cret-value") {
+		t.Errorf("listeners got %q, which leaks the secret", sent)
+	}
+	msgs, err := o.store.GetMessages("alpha", 10)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("messages = %+v, %v", msgs, err)
+	}
+	if msgs[0].Content != sent || strings.Contains(msgs[0].Content, "s3cret-value") {
+		t.Errorf("stored %q, want the redacted text listeners got (%q)", msgs[0].Content, sent)
+	}
+}
+
+func TestOutputPipelineCached(t *testing.T) {
+	o := newTestOrchestrator(t, "alpha")
+	builds := 0
+	o.transformers["count"] = func(t config.OutputTransform) (OutputTransformer, error) {
+		builds++
+		return OutputTransformerFunc(func(text string) string { return text + t.Text }), nil
+	}
+
+	setOutputTransforms(t, o, config.OutputTransform{Type: "count", Text: "!"})
+	for range 3 {
+		if got := o.processOutput("alpha", "hi"); got != "hi!" {
+			t.Fatalf("processOutput = %q, want hi!", got)
+		}
+	}
+	if builds != 1 {
+		t.Errorf("pipeline built %d times for one definition, want 1", builds)
+	}
+
+	setOutputTransforms(t, o, config.OutputTransform{Type: "count", Text: "?"})
+	if got := o.processOutput("alpha", "hi"); got != "hi?" {
+		t.Errorf("after update processOutput = %q, want hi?", got)
+	}
+	if builds != 2 {
+		t.Errorf("pipeline built %d times after a registry update, want 2", builds)
+	}
+}
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// seedWorkspace copies the agent's workspace_template into its workspace
+// volume when the volume is still empty, so a new agent starts from the
+// team's scaffolding. A volume with anything in it is never touched. Each
+// workspace is checked once per process; failures are logged and the agent
+// starts without the template.
+func (o *Orchestrator) seedWorkspace(ctx context.Context, agentID string) {
+	dir := o.registry.ResolveWorkspaceTemplate(agentID)
+	if dir == "" {
+		return
+	}
+	ag, err := o.registry.Get(agentID)
+	if err != nil || ag == nil {
+		return
+	}
+	o.mu.Lock()
+	done := o.seeded[ag.Workspace]
+	o.mu.Unlock()
+	if done {
+		return
+	}
+
+	image := o.registry.ResolveImage(agentID)
+	empty, err := o.volumeEmpty(ctx, ag.Workspace, image)
+	if err != nil {
+		slog.Warn("workspace template: failed to inspect workspace", "agent", agentID, "error", err)
+		return
+	}
+	o.mu.Lock()
+	o.seeded[ag.Workspace] = true
+	o.mu.Unlock()
+	if !empty {
+		return
+	}
+
+	n, err := o.copyTemplate(ctx, dir, ag.Workspace, image)
+	if err != nil {
+		slog.Warn("workspace template: seeding failed", "agent", agentID, "template", dir, "files", n, "error", err)
+		return
+	}
+	slog.Info("workspace seeded from template", "agent", agentID, "template", dir, "files", n)
+}
+
+// copyTemplate writes every regular file under dir into the workspace
+// volume at the same relative path and permissions, returning how many were
+// written.
+func (o *Orchestrator) copyTemplate(ctx context.Context, dir, workspace, image string) (int, error) {
+	n := 0
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil // directories are created with their files; links are skipped
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err := o.writeVolume(ctx, workspace, filepath.ToSlash(rel), data, info.Mode(), image); err != nil {
+			return fmt.Errorf("write %s: %w", rel, err)
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
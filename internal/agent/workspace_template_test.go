@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+func TestSeedWorkspace(t *testing.T) {
+	tmpl := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpl, "scripts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpl, "README.md"), []byte("# Starter\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpl, "scripts/setup.sh"), []byte("echo hi\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	o := newTestOrchestrator(t, "fresh", "used")
+	defs := map[string]config.AgentDefinition{
+		"fresh": {Workspace: "fresh", WorkspaceTemplate: tmpl},
+		"used":  {Workspace: "used", WorkspaceTemplate: tmpl},
+	}
+	if err := o.registry.Update(defs, o.defaults()); err != nil {
+		t.Fatal(err)
+	}
+
+	written := make(map[string]string)
+	modes := make(map[string]fs.FileMode)
+	checks := 0
+	o.volumeEmpty = func(_ context.Context, workspace, _ string) (bool, error) {
+		checks++
+		return workspace == "fresh", nil
+	}
+	o.writeVolume = func(_ context.Context, workspace, filePath string, data []byte, mode fs.FileMode, _ string) error {
+		written[workspace+":"+filePath] = string(data)
+		modes[workspace+":"+filePath] = mode.Perm()
+		return nil
+	}
+
+	o.seedWorkspace(context.Background(), "fresh")
+	o.seedWorkspace(context.Background(), "used")
+
+	want := map[string]string{"fresh:README.md": "# Starter\n", "fresh:scripts/setup.sh": "echo hi\n"}
+	if len(written) != len(want) {
+		t.Fatalf("written = %v, want %v", written, want)
+	}
+	for k, v := range want {
+		if written[k] != v {
+			t.Errorf("%s = %q, want %q", k, written[k], v)
+		}
+	}
+
+	if modes["fresh:README.md"] != 0o644 || modes["fresh:scripts/setup.sh"] != 0o755 {
+		t.Errorf("modes = %v, want README.md 0644 and scripts/setup.sh 0755", modes)
+	}
+
+	// Later starts don't look at the volume again.
+	o.seedWorkspace(context.Background(), "fresh")
+	if checks != 2 {
+		t.Errorf("volume checked %d times, want 2", checks)
+	}
+}
@@ -28,7 +28,7 @@ type Client struct {
 	apiKey     string
 	registry   *registry.Registry
 	handler    MessageHandler
-	mainChatID int64
+	mainChatID func(agentID string) int64
 	httpClient *http.Client
 
 	mu   sync.Mutex
@@ -41,7 +41,7 @@ type Client struct {
 }
 
 // NewClient creates a new AgentMail WebSocket client.
-func NewClient(apiKey string, reg *registry.Registry, handler MessageHandler, mainChatID int64) *Client {
+func NewClient(apiKey string, reg *registry.Registry, handler MessageHandler, mainChatID func(agentID string) int64) *Client {
 	return &Client{
 		apiKey:     apiKey,
 		registry:   reg,
@@ -357,7 +357,7 @@ func (c *Client) dispatchMessage(ctx context.Context, agentID string, msg *wsMes
 
 	meta := map[string]string{
 		"sender":  "agentmail",
-		"chat_id": fmt.Sprintf("%d", c.mainChatID),
+		"chat_id": fmt.Sprintf("%d", c.mainChatID(agentID)),
 	}
 
 	if err := c.handler(ctx, agentID, prompt, meta); err != nil {
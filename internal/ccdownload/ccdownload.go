@@ -0,0 +1,271 @@
+// Package ccdownload resolves and downloads Claude Code release binaries,
+// verifying each against the checksum published in the release manifest.
+package ccdownload
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// InstallScriptURL is the official bootstrap script; it names the base URL
+// releases are published under.
+const InstallScriptURL = "https://downloads.claude.ai/claude-code-releases/bootstrap.sh"
+
+// Platforms lists the release platforms binaries are published for.
+var Platforms = []string{
+	"linux-x64",
+	"linux-arm64",
+	"linux-x64-musl",
+	"linux-arm64-musl",
+	"darwin-x64",
+	"darwin-arm64",
+}
+
+// goArchToManifest maps Go's runtime.GOARCH values to the manifest naming.
+var goArchToManifest = map[string]string{
+	"amd64": "x64",
+	"arm64": "arm64",
+}
+
+type manifest struct {
+	Platforms map[string]struct {
+		Checksum string `json:"checksum"`
+	} `json:"platforms"`
+}
+
+var versionRe = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+([-.][0-9A-Za-z.-]+)?$`)
+
+// ValidVersion reports whether v is a concrete release version such as
+// "2.1.197", as opposed to "latest".
+func ValidVersion(v string) bool {
+	return versionRe.MatchString(v)
+}
+
+// Release is one downloadable binary.
+type Release struct {
+	Version     string `json:"version"`
+	DownloadURL string `json:"download_url"`
+	SHA256      string `json:"sha256"`
+}
+
+// unameArchToGo maps uname machine names, as reported by docker info, to
+// Go's runtime.GOARCH values.
+var unameArchToGo = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"armv6l":  "arm",
+	"armv7l":  "arm",
+	"i386":    "386",
+	"i686":    "386",
+}
+
+// GoArch returns the GOARCH name for an architecture in Go (amd64), uname
+// (x86_64) or manifest (x64) naming. Unknown names are passed through.
+func GoArch(arch string) string {
+	if goarch, ok := unameArchToGo[arch]; ok {
+		return goarch
+	}
+	for goarch, name := range goArchToManifest {
+		if name == arch {
+			return goarch
+		}
+	}
+	return arch
+}
+
+// Platform returns the release platform for a Go GOOS/GOARCH pair, e.g.
+// linux/arm64 with musl is "linux-arm64-musl". Unknown architectures are
+// passed through, so the result may not be in Platforms.
+func Platform(goos, goarch string, musl bool) string {
+	arch, ok := goArchToManifest[goarch]
+	if !ok {
+		return goos + "-" + goarch
+	}
+	platform := goos + "-" + arch
+	if goos == "linux" && musl {
+		platform += "-musl"
+	}
+	return platform
+}
+
+// IsValidPlatform reports whether p is one of Platforms.
+func IsValidPlatform(p string) bool {
+	return slices.Contains(Platforms, p)
+}
+
+// Resolve looks up version ("" or "latest" for the newest release) for
+// platform and returns its download URL and checksum.
+func Resolve(version, platform string) (Release, error) {
+	if !IsValidPlatform(platform) {
+		return Release{}, fmt.Errorf("unsupported platform %q", platform)
+	}
+	baseURL, err := FetchBaseURL(InstallScriptURL)
+	if err != nil {
+		return Release{}, fmt.Errorf("fetching base URL: %w", err)
+	}
+	if version == "" || version == "latest" {
+		if version, err = FetchVersion(baseURL); err != nil {
+			return Release{}, fmt.Errorf("fetching version: %w", err)
+		}
+	}
+	checksum, err := FetchChecksum(baseURL, version, platform)
+	if err != nil {
+		return Release{}, fmt.Errorf("fetching checksum: %w", err)
+	}
+	return Release{
+		Version:     version,
+		DownloadURL: DownloadURL(baseURL, version, platform),
+		SHA256:      checksum,
+	}, nil
+}
+
+// DownloadURL returns where the binary for version and platform is published.
+func DownloadURL(baseURL, version, platform string) string {
+	return fmt.Sprintf("%s/%s/%s/claude", baseURL, version, platform)
+}
+
+// DownloadAndVerify downloads url to destPath, made executable, failing
+// without touching destPath if the content doesn't match expectedChecksum.
+func DownloadAndVerify(url, expectedChecksum, destPath string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download: unexpected status %d", resp.StatusCode)
+	}
+
+	f, err := os.CreateTemp("", "claude-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	tmpPath := f.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	hasher := sha256.New()
+	w := io.MultiWriter(f, hasher)
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("download: %w", err)
+	}
+	_ = f.Close()
+
+	got := hex.EncodeToString(hasher.Sum(nil))
+	if got != expectedChecksum {
+		return fmt.Errorf("checksum mismatch: got %s, want %s", got, expectedChecksum)
+	}
+
+	if err := os.Chmod(tmpPath, 0o755); err != nil {
+		return fmt.Errorf("chmod: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		// Cross-device rename; fall back to copy
+		src, err2 := os.Open(tmpPath)
+		if err2 != nil {
+			return fmt.Errorf("open temp: %w", err2)
+		}
+		defer func() { _ = src.Close() }()
+
+		dst, err2 := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+		if err2 != nil {
+			return fmt.Errorf("create dest: %w", err2)
+		}
+		if _, err2 := io.Copy(dst, src); err2 != nil {
+			_ = dst.Close()
+			return fmt.Errorf("copy: %w", err2)
+		}
+		_ = dst.Close()
+	}
+
+	return nil
+}
+
+// FetchBaseURL fetches the install script and extracts the DOWNLOAD_BASE_URL value.
+func FetchBaseURL(scriptURL string) (string, error) {
+	resp, err := http.Get(scriptURL)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d fetching install script", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if val, ok := strings.CutPrefix(line, "DOWNLOAD_BASE_URL="); ok {
+			val = strings.Trim(val, `"'`)
+			if val == "" {
+				return "", fmt.Errorf("DOWNLOAD_BASE_URL is empty in install script")
+			}
+			return val, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("reading install script: %w", err)
+	}
+
+	return "", fmt.Errorf("DOWNLOAD_BASE_URL not found in install script")
+}
+
+// FetchVersion returns the latest released version.
+func FetchVersion(baseURL string) (string, error) {
+	resp, err := http.Get(baseURL + "/latest")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d fetching latest version", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+// FetchChecksum returns the SHA-256 of the version's binary for platform.
+func FetchChecksum(baseURL, version, platform string) (string, error) {
+	url := fmt.Sprintf("%s/%s/manifest.json", baseURL, version)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d fetching manifest", resp.StatusCode)
+	}
+
+	var m manifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return "", fmt.Errorf("decoding manifest: %w", err)
+	}
+
+	p, ok := m.Platforms[platform]
+	if !ok {
+		return "", fmt.Errorf("platform %q not found in manifest", platform)
+	}
+
+	return p.Checksum, nil
+}
@@ -0,0 +1,329 @@
+package ccdownload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFetchBaseURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `#!/bin/sh`)
+		_, _ = fmt.Fprintln(w, `DOWNLOAD_DIR="$HOME/.claude/downloads"`)
+		_, _ = fmt.Fprintln(w, `DOWNLOAD_BASE_URL="https://downloads.example.com/claude-code-releases"`)
+		_, _ = fmt.Fprintln(w, `echo "hello"`)
+	}))
+	defer ts.Close()
+
+	got, err := FetchBaseURL(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "https://downloads.example.com/claude-code-releases"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFetchBaseURLSingleQuotes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "DOWNLOAD_BASE_URL='https://example.com/releases'")
+	}))
+	defer ts.Close()
+
+	got, err := FetchBaseURL(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "https://example.com/releases" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestFetchBaseURLMissing(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, `#!/bin/sh`)
+		_, _ = fmt.Fprintln(w, `echo "no base url here"`)
+	}))
+	defer ts.Close()
+
+	_, err := FetchBaseURL(ts.URL)
+	if err == nil {
+		t.Fatal("expected error when DOWNLOAD_BASE_URL is missing")
+	}
+}
+
+func TestFetchBaseURLHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	_, err := FetchBaseURL(ts.URL)
+	if err == nil {
+		t.Fatal("expected error for 404 response")
+	}
+}
+
+func TestIsValidPlatform(t *testing.T) {
+	valid := []string{
+		"linux-x64",
+		"linux-arm64",
+		"linux-x64-musl",
+		"linux-arm64-musl",
+		"darwin-x64",
+		"darwin-arm64",
+	}
+	for _, p := range valid {
+		if !IsValidPlatform(p) {
+			t.Errorf("expected %q to be valid", p)
+		}
+	}
+
+	invalid := []string{
+		"windows-x64",
+		"linux-386",
+		"",
+		"linux",
+		"darwin-arm64-musl",
+	}
+	for _, p := range invalid {
+		if IsValidPlatform(p) {
+			t.Errorf("expected %q to be invalid", p)
+		}
+	}
+}
+
+func TestPlatform(t *testing.T) {
+	tests := []struct {
+		goos, goarch string
+		musl         bool
+		want         string
+	}{
+		{"linux", "amd64", false, "linux-x64"},
+		{"linux", "arm64", true, "linux-arm64-musl"},
+		{"darwin", "arm64", true, "darwin-arm64"},
+		{"linux", "386", false, "linux-386"},
+	}
+	for _, tt := range tests {
+		if got := Platform(tt.goos, tt.goarch, tt.musl); got != tt.want {
+			t.Errorf("Platform(%q, %q, %v) = %q, want %q", tt.goos, tt.goarch, tt.musl, got, tt.want)
+		}
+	}
+}
+
+func TestGoArch(t *testing.T) {
+	for in, want := range map[string]string{
+		"amd64":   "amd64",
+		"x86_64":  "amd64",
+		"x64":     "amd64",
+		"arm64":   "arm64",
+		"aarch64": "arm64",
+		"armv7l":  "arm",
+		"armv6l":  "arm",
+		"i686":    "386",
+		"i386":    "386",
+		"riscv64": "riscv64",
+	} {
+		if got := GoArch(in); got != want {
+			t.Errorf("GoArch(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFetchVersion(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("1.2.3\n"))
+	}))
+	defer ts.Close()
+
+	version, err := FetchVersion(ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != "1.2.3" {
+		t.Errorf("got version %q, want %q", version, "1.2.3")
+	}
+}
+
+func TestFetchVersionHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	_, err := FetchVersion(ts.URL)
+	if err == nil {
+		t.Fatal("expected error for 500 response")
+	}
+}
+
+func testManifest() manifest {
+	var m manifest
+	m.Platforms = map[string]struct {
+		Checksum string `json:"checksum"`
+	}{
+		"linux-x64":    {Checksum: "abc123"},
+		"darwin-arm64": {Checksum: "def456"},
+	}
+	return m
+}
+
+func TestFetchChecksum(t *testing.T) {
+	m := testManifest()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1.2.3/manifest.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(m)
+	}))
+	defer ts.Close()
+
+	checksum, err := FetchChecksum(ts.URL, "1.2.3", "linux-x64")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if checksum != "abc123" {
+		t.Errorf("got checksum %q, want %q", checksum, "abc123")
+	}
+}
+
+func TestFetchChecksumPlatformNotFound(t *testing.T) {
+	m := testManifest()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(m)
+	}))
+	defer ts.Close()
+
+	_, err := FetchChecksum(ts.URL, "1.2.3", "windows-x64")
+	if err == nil {
+		t.Fatal("expected error for missing platform")
+	}
+}
+
+func TestFetchChecksumHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	_, err := FetchChecksum(ts.URL, "9.9.9", "linux-x64")
+	if err == nil {
+		t.Fatal("expected error for 404 response")
+	}
+}
+
+func TestFetchChecksumInvalidJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not json"))
+	}))
+	defer ts.Close()
+
+	_, err := FetchChecksum(ts.URL, "1.2.3", "linux-x64")
+	if err == nil {
+		t.Fatal("expected error for invalid JSON")
+	}
+}
+
+func TestReleaseJSON(t *testing.T) {
+	o := Release{
+		Version:     "1.2.3",
+		DownloadURL: "https://example.com/1.2.3/linux-x64/claude",
+		SHA256:      "abc123",
+	}
+
+	data, err := json.Marshal(o)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got Release
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got != o {
+		t.Errorf("round-trip mismatch: got %+v, want %+v", got, o)
+	}
+
+	// Verify JSON field names.
+	var raw map[string]string
+	_ = json.Unmarshal(data, &raw)
+	for _, key := range []string{"version", "download_url", "sha256"} {
+		if _, ok := raw[key]; !ok {
+			t.Errorf("expected JSON key %q", key)
+		}
+	}
+}
+
+func TestDownloadAndVerify(t *testing.T) {
+	content := []byte("fake claude binary content")
+	h := sha256.Sum256(content)
+	checksum := hex.EncodeToString(h[:])
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	defer ts.Close()
+
+	dest := filepath.Join(t.TempDir(), "claude")
+	err := DownloadAndVerify(ts.URL, checksum, dest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("read dest: %v", err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("content mismatch")
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm()&0o111 == 0 {
+		t.Error("expected executable permission")
+	}
+}
+
+func TestDownloadAndVerifyBadChecksum(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("some content"))
+	}))
+	defer ts.Close()
+
+	dest := filepath.Join(t.TempDir(), "claude")
+	err := DownloadAndVerify(ts.URL, "0000000000000000000000000000000000000000000000000000000000000000", dest)
+	if err == nil {
+		t.Fatal("expected checksum mismatch error")
+	}
+}
+
+func TestDownloadAndVerifyHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	dest := filepath.Join(t.TempDir(), "claude")
+	err := DownloadAndVerify(ts.URL, "abc", dest)
+	if err == nil {
+		t.Fatal("expected error for 404 response")
+	}
+}
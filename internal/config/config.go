@@ -2,10 +2,18 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"net/url"
 	"os"
+	"path"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/mtzanidakis/praktor/internal/ccdownload"
 	"gopkg.in/yaml.v3"
 )
 
@@ -20,6 +28,9 @@ type Config struct {
 	Vault     VaultConfig                `yaml:"vault"`
 	AgentMail AgentMailConfig            `yaml:"agentmail"`
 	Speech    SpeechConfig               `yaml:"speech"`
+	Tracing   TracingConfig              `yaml:"tracing"`
+	// Chat webhooks and email that selected events are sent to.
+	Notifications NotificationsConfig `yaml:"notifications"`
 }
 
 type AgentMailConfig struct {
@@ -33,24 +44,289 @@ type SpeechConfig struct {
 	TTSVoice   string `yaml:"tts_voice"`
 }
 
+// TracingConfig configures OpenTelemetry tracing. Spans are exported over
+// OTLP/HTTP when OTLPEndpoint is set; otherwise tracing is a no-op.
+type TracingConfig struct {
+	OTLPEndpoint string `yaml:"otlp_endpoint"` // e.g. http://localhost:4318
+	ServiceName  string `yaml:"service_name"`
+}
+
+// NotificationsConfig sends selected events to Slack, Discord or email.
+// Each backend receives the event types in its Events list; an empty list
+// means all of NotifyEventTypes.
+type NotificationsConfig struct {
+	Backends []NotifyBackend `yaml:"backends"`
+}
+
+// NotifyBackend is one notification destination. URL is the incoming
+// webhook of a slack or discord backend; email sends through SMTP.
+type NotifyBackend struct {
+	Name   string     `yaml:"name"`
+	Type   string     `yaml:"type"` // "slack", "discord" or "email"
+	URL    string     `yaml:"url"`
+	Events []string   `yaml:"events"` // empty = all of NotifyEventTypes
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+// SMTPConfig is the mail server and envelope of an email backend. Username
+// and Password enable PLAIN auth; Port 0 means 587.
+type SMTPConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// NotifyBackendTypes and NotifyEventTypes are the backend types and event
+// types notifications accepts.
+var (
+	NotifyBackendTypes = []string{"slack", "discord", "email"}
+	NotifyEventTypes   = []string{"agent_error", "task_failed", "swarm_completed", "swarm_failed"}
+)
+
+func validateNotifications(cfg *Config) error {
+	seen := make(map[string]bool, len(cfg.Notifications.Backends))
+	for i, b := range cfg.Notifications.Backends {
+		if b.Name == "" {
+			return fmt.Errorf("notifications.backends[%d].name is required", i)
+		}
+		if seen[b.Name] {
+			return fmt.Errorf("notifications.backends: duplicate name %q", b.Name)
+		}
+		seen[b.Name] = true
+		key := "notifications.backends." + b.Name
+		switch b.Type {
+		case "slack", "discord":
+			u, err := url.Parse(b.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%s.url must be an http(s) webhook URL", key)
+			}
+		case "email":
+			if b.SMTP.Host == "" || b.SMTP.From == "" || len(b.SMTP.To) == 0 {
+				return fmt.Errorf("%s.smtp needs host, from and to", key)
+			}
+			if b.SMTP.Port < 0 || b.SMTP.Port > 65535 {
+				return fmt.Errorf("%s.smtp.port must be between 0 and 65535", key)
+			}
+		default:
+			return fmt.Errorf("%s.type %q must be one of %s", key, b.Type, strings.Join(NotifyBackendTypes, ", "))
+		}
+		for _, ev := range b.Events {
+			if !slices.Contains(NotifyEventTypes, ev) {
+				return fmt.Errorf("%s.events: %q must be one of %s", key, ev, strings.Join(NotifyEventTypes, ", "))
+			}
+		}
+	}
+	return nil
+}
+
 type VaultConfig struct {
 	Passphrase string `yaml:"passphrase"`
+	// Refuse to start the gateway when the passphrase can't decrypt the
+	// stored secrets, instead of only logging it.
+	RequireVerify bool `yaml:"require_verify"`
 }
 
 type TelegramConfig struct {
-	Token      string  `yaml:"token"`
-	AllowFrom  []int64 `yaml:"allow_from"`
-	MainChatID int64   `yaml:"main_chat_id"`
+	Token      string              `yaml:"token"`
+	AllowFrom  []int64             `yaml:"allow_from"`
+	MainChatID int64               `yaml:"main_chat_id"`
+	ParseMode  string              `yaml:"parse_mode"` // "markdown" (MarkdownV2) or "html"
+	Bots       []TelegramBotConfig `yaml:"bots"`       // several bots; replaces token/allow_from
+	Quota      UserQuotaConfig     `yaml:"quota"`
+	Ordering   ReplyOrderConfig    `yaml:"ordering"`
+}
+
+// ReplyOrderConfig makes a chat receive replies in the order of the messages
+// they answer, even when several agents (or runs) answer at once. A reply
+// that finishes early is held until the earlier messages are answered, their
+// runs fail, or Timeout passes; a reply whose turn was skipped is sent as
+// soon as it arrives. Chats limits ordering to those chat ids; empty means
+// every chat. Off by default, which sends each reply as soon as it is ready.
+type ReplyOrderConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Timeout time.Duration `yaml:"timeout"`
+	Chats   []int64       `yaml:"chats"`
+}
+
+// UserQuotaConfig caps the messages each Telegram user may send to agents,
+// counted across all bots: Hourly per clock hour, Daily per rolling 24
+// hours; 0 = unlimited. Admins, who must also be allowed by allow_from, are
+// exempt.
+type UserQuotaConfig struct {
+	Hourly int     `yaml:"hourly"`
+	Daily  int     `yaml:"daily"`
+	Admins []int64 `yaml:"admins"`
+}
+
+// IsAdmin reports whether userID is exempt from the quota.
+func (q UserQuotaConfig) IsAdmin(userID int64) bool {
+	return slices.Contains(q.Admins, userID)
+}
+
+// TelegramBotConfig is one Telegram bot. Agents restricts which agents the
+// bot lists and routes to; empty means all agents.
+type TelegramBotConfig struct {
+	Name       string   `yaml:"name"`
+	Token      string   `yaml:"token"`
+	AllowFrom  []int64  `yaml:"allow_from"`
+	MainChatID int64    `yaml:"main_chat_id"`
+	Agents     []string `yaml:"agents"`
+}
+
+// DefaultBotName names the bot built from the top-level telegram.token.
+const DefaultBotName = "default"
+
+// BotConfigs returns the configured bots: telegram.bots if set, otherwise a
+// single bot named DefaultBotName from the top-level token, or none.
+func (c TelegramConfig) BotConfigs() []TelegramBotConfig {
+	if len(c.Bots) > 0 {
+		return c.Bots
+	}
+	if c.Token == "" {
+		return nil
+	}
+	return []TelegramBotConfig{{
+		Name:       DefaultBotName,
+		Token:      c.Token,
+		AllowFrom:  c.AllowFrom,
+		MainChatID: c.MainChatID,
+	}}
+}
+
+// MainChatIDFor returns the main_chat_id of agentID's home bot, the first
+// bot that may route to it, for output no chat asked for (scheduled tasks,
+// email). Without telegram.bots it is the top-level main_chat_id.
+func (c TelegramConfig) MainChatIDFor(agentID string) int64 {
+	if len(c.Bots) == 0 {
+		return c.MainChatID
+	}
+	for _, bc := range c.Bots {
+		if bc.AllowsAgent(agentID) {
+			return bc.MainChatID
+		}
+	}
+	return 0
+}
+
+// AllowsAgent reports whether the bot may route to agentID.
+func (c TelegramBotConfig) AllowsAgent(agentID string) bool {
+	return len(c.Agents) == 0 || slices.Contains(c.Agents, agentID)
 }
 
 type DefaultsConfig struct {
-	Image           string         `yaml:"image"`
-	Model           string         `yaml:"model"`
-	MaxRunning      int            `yaml:"max_running"`
-	IdleTimeout     time.Duration  `yaml:"idle_timeout"`
-	AnthropicAPIKey string         `yaml:"anthropic_api_key"`
-	OAuthToken      string         `yaml:"oauth_token"`
-	Security        SecurityConfig `yaml:"security"`
+	Image            string           `yaml:"image"`
+	Model            string           `yaml:"model"`
+	MaxRunning       int              `yaml:"max_running"`
+	IdleTimeout      time.Duration    `yaml:"idle_timeout"`
+	AnthropicAPIKey  string           `yaml:"anthropic_api_key"`
+	OAuthToken       string           `yaml:"oauth_token"`
+	Security         SecurityConfig   `yaml:"security"`
+	RateLimit        RateLimitConfig  `yaml:"rate_limit"`
+	MaxFileSizeMB    int64            `yaml:"max_file_size_mb"` // largest file an agent may send; 0 = unlimited
+	FileFilter       FileFilterConfig `yaml:"file_filter"`
+	Heartbeat        HeartbeatConfig  `yaml:"heartbeat"`
+	History          HistoryConfig    `yaml:"history"`
+	MessageOverrides []string         `yaml:"message_overrides"` // "model" / "env.NAME" a message may override at container start
+	// How long a config reload lets a changed agent finish in-flight
+	// messages before its container is restarted; 0 = restart immediately.
+	ReloadDrainTimeout time.Duration `yaml:"reload_drain_timeout"`
+	NixGCConcurrency   int           `yaml:"nix_gc_concurrency"` // agents garbage-collected at a time (0 = 1)
+	// Longest maintenance window an agent may open with enter_maintenance,
+	// during which it is neither stopped when idle nor garbage-collected.
+	MaintenanceMaxDuration time.Duration `yaml:"maintenance_max_duration"`
+	// How long a command the gateway runs inside an agent container (nix GC,
+	// the Telegram /nix command) may take before it is killed.
+	ExecTimeout time.Duration `yaml:"exec_timeout"`
+	// Copy the host's global USER.md and CLAUDE.md into each container at
+	// start, and into running containers when the user profile changes.
+	InjectGlobalContext bool `yaml:"inject_global_context"`
+	// Largest message, in bytes, stored or sent to an agent; 0 = unlimited.
+	// Longer inbound messages are rejected or truncated per OversizedInput;
+	// longer agent replies are truncated, with the full text sent as a file.
+	MaxMessageBytes int    `yaml:"max_message_bytes"`
+	OversizedInput  string `yaml:"oversized_input"` // "reject" (default) or "truncate"
+	// Claude Code version baked into agent images, e.g. "2.1.197"; empty
+	// uses whatever version the image ships.
+	ClaudeVersion string `yaml:"claude_version"`
+	// Host directory whose contents seed a new agent's empty workspace
+	// volume (starter files, scripts); "" = no template.
+	WorkspaceTemplate string `yaml:"workspace_template"`
+	// What stopping an agent does with its container: "remove" (default)
+	// discards it, "stop" keeps it so the next start with an unchanged
+	// spec only restarts it. IdleStopMode overrides it for the idle reaper.
+	StopMode     string `yaml:"stop_mode"`
+	IdleStopMode string `yaml:"idle_stop_mode"` // "" = stop_mode
+	// Extra Docker labels on agent containers. Values may use {agent},
+	// {model} and {workspace}; praktor.* keys are reserved.
+	ContainerLabels map[string]string `yaml:"container_labels"`
+	// How long a swarm may sit in running with no coordinator executing it
+	// before it is marked failed as orphaned; 0 = never.
+	SwarmMaxAge time.Duration `yaml:"swarm_max_age"`
+	// How long finished swarm runs are kept (0 = forever), how many of the
+	// most recent ones are kept regardless, and how often old ones are swept.
+	SwarmRetention     time.Duration `yaml:"swarm_retention"`
+	SwarmKeepRecent    int           `yaml:"swarm_keep_recent"`
+	SwarmSweepInterval time.Duration `yaml:"swarm_sweep_interval"`
+	// Largest artifact an agent may save with save_artifact, in MB
+	// (0 = unlimited), and how long saved artifacts are kept (0 = forever).
+	ArtifactMaxSizeMB int64         `yaml:"artifact_max_size_mb"`
+	ArtifactRetention time.Duration `yaml:"artifact_retention"`
+	// How long recorded agent lifecycle events (the activity feed's
+	// lifecycle items) are kept; 0 = forever.
+	AgentEventRetention time.Duration `yaml:"agent_event_retention"`
+	// How long a starting agent container gets to signal that it is ready
+	// for input; messages are sent anyway once it elapses. Raise it for
+	// slow-starting images.
+	ReadyTimeout time.Duration `yaml:"ready_timeout"`
+	// How often running agents' images are checked for a rebuilt tag
+	// (0 = never), and how many agents an image refresh restarts at a time.
+	ImageCheckInterval      time.Duration `yaml:"image_check_interval"`
+	ImageRefreshConcurrency int           `yaml:"image_refresh_concurrency"` // 0 = 1
+	// What Telegram /start sends the agent to open a conversation (default
+	// "Hello!"), and an optional message the bot sends the user first.
+	GreetingPrompt string `yaml:"greeting_prompt"`
+	WelcomeMessage string `yaml:"welcome_message"`
+}
+
+// HeartbeatConfig controls liveness pings to running agent containers. An
+// agent that misses FailureThreshold consecutive pings (each waiting up to
+// Timeout) is marked unhealthy and stopped. A zero Interval disables pings.
+type HeartbeatConfig struct {
+	Interval         time.Duration `yaml:"interval"`
+	Timeout          time.Duration `yaml:"timeout"`
+	FailureThreshold int           `yaml:"failure_threshold"`
+}
+
+// HistoryConfig injects the last Turns stored messages of a conversation into
+// each shared-context message sent to the agent, so agent images without
+// their own session still see prior turns. MaxChars bounds the injected text;
+// the oldest turns are dropped first. A zero Turns disables injection.
+type HistoryConfig struct {
+	Turns    int `yaml:"turns"`
+	MaxChars int `yaml:"max_chars"` // 0 = 8000
+}
+
+// FileFilterConfig restricts which files agents may send via send_file and
+// users may attach to messages sent from the web UI.
+// Deny lists win over allow lists; an empty allow list allows everything not
+// denied. Extensions match case-insensitively with or without the leading
+// dot. MIME types may use a "type/*" wildcard.
+type FileFilterConfig struct {
+	AllowedMIMETypes  []string `yaml:"allowed_mime_types"`
+	DeniedMIMETypes   []string `yaml:"denied_mime_types"`
+	AllowedExtensions []string `yaml:"allowed_extensions"`
+	DeniedExtensions  []string `yaml:"denied_extensions"`
+}
+
+// RateLimitConfig is a token bucket applied to incoming messages per agent.
+// RatePerMinute is the steady-state refill rate and Burst the bucket size.
+// A zero RatePerMinute disables limiting.
+type RateLimitConfig struct {
+	RatePerMinute float64 `yaml:"rate_per_minute"`
+	Burst         int     `yaml:"burst"` // 0 = max(1, rate_per_minute)
 }
 
 // SecurityConfig controls Docker hardening flags applied to agent containers.
@@ -74,23 +350,191 @@ type SecurityConfig struct {
 }
 
 const (
-	AgentsBasePath = "data/agents"
-	StorePath      = "data/praktor.db"
-	NATSPort       = 4222
+	AgentsBasePath  = "data/agents"
+	StorePath       = "data/praktor.db"
+	ArtifactsPath   = "data/artifacts"
+	AdminTokenPath  = "data/admin.token" // written by the gateway, read by the admin CLI
+	AdminSocketPath = "data/admin.sock"  // served by the gateway for the admin CLI
+	NATSPort        = 4222
 )
 
+// subjectPrefixRe accepts NATS subject tokens without wildcards.
+var subjectPrefixRe = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// agentIDRe is the shape of an agent id: it becomes a NATS subject token
+// (so no dots or wildcards), part of volume and container names, and is
+// compared case-sensitively, so only lowercase is allowed.
+var agentIDRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// reservedAgentIDs are ids that would collide with other NATS subjects:
+// agent.lifecycle.<id> carries every agent's lifecycle events, so an agent
+// named lifecycle would share subjects with them.
+var reservedAgentIDs = map[string]bool{"lifecycle": true}
+
+// ValidateAgentIDs reports every id that doesn't match ^[a-z0-9][a-z0-9-]*$,
+// in sorted order, then any reserved id.
+func ValidateAgentIDs(ids []string) error {
+	var bad, reserved []string
+	for _, id := range slices.Sorted(slices.Values(ids)) {
+		switch {
+		case !agentIDRe.MatchString(id):
+			bad = append(bad, strconv.Quote(id))
+		case reservedAgentIDs[id]:
+			reserved = append(reserved, strconv.Quote(id))
+		}
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("invalid agent id(s) %s: ids must be lowercase letters, digits and '-', starting with a letter or digit", strings.Join(bad, ", "))
+	}
+	if len(reserved) > 0 {
+		return fmt.Errorf("reserved agent id(s) %s: pick another name", strings.Join(reserved, ", "))
+	}
+	return nil
+}
+
 type AgentDefinition struct {
-	Description      string            `yaml:"description"`
-	Model            string            `yaml:"model"`
-	Image            string            `yaml:"image"`
-	ClaudeMD         string            `yaml:"claude_md"`
-	Workspace        string            `yaml:"workspace"`
-	Env              map[string]string `yaml:"env"`
-	Files            []FileMount       `yaml:"files"`
-	AllowedTools     []string          `yaml:"allowed_tools"`
-	NixEnabled       bool              `yaml:"nix_enabled"`
-	AgentMailInboxID string            `yaml:"agentmail_inbox_id"`
-	Security         *SecurityConfig   `yaml:"security"` // nil = inherit defaults.security
+	Description      string                `yaml:"description"`
+	Tags             []string              `yaml:"tags"`  // organizational labels for filtering, e.g. "team:infra"
+	Group            string                `yaml:"group"` // agents started and stopped together; "" = none
+	Model            string                `yaml:"model"`
+	ModelFallbacks   []string              `yaml:"model_fallbacks"` // tried in order on retryable model errors
+	Image            string                `yaml:"image"`
+	ClaudeMD         string                `yaml:"claude_md"`
+	Workspace        string                `yaml:"workspace"`
+	Env              map[string]string     `yaml:"env"`
+	EnvFileSecret    string                `yaml:"env_file_secret"` // secret holding a .env file merged under env
+	Files            []FileMount           `yaml:"files"`
+	AllowedTools     []string              `yaml:"allowed_tools"`
+	NixEnabled       bool                  `yaml:"nix_enabled"`
+	AgentMailInboxID string                `yaml:"agentmail_inbox_id"`
+	Security         *SecurityConfig       `yaml:"security"`         // nil = inherit defaults.security
+	RateLimit        *RateLimitConfig      `yaml:"rate_limit"`       // nil = inherit defaults.rate_limit
+	History          *HistoryConfig        `yaml:"history"`          // nil = inherit defaults.history
+	CacheTTL         time.Duration         `yaml:"cache_ttl"`        // 0 = response caching disabled
+	IdleTimeout      time.Duration         `yaml:"idle_timeout"`     // 0 = defaults.idle_timeout, negative = never stopped when idle
+	WorkspaceQuota   *WorkspaceQuotaConfig `yaml:"workspace_quota"`  // nil = unlimited
+	ClaudeVersion    string                `yaml:"claude_version"`   // "" = defaults.claude_version
+	ContainerLabels  map[string]string     `yaml:"container_labels"` // merged over defaults.container_labels
+	Weight           int                   `yaml:"weight"`           // share of defaults.max_running a running container uses; 0 = 1
+	GreetingPrompt   string                `yaml:"greeting_prompt"`  // "" = defaults.greeting_prompt
+	WelcomeMessage   string                `yaml:"welcome_message"`  // "" = defaults.welcome_message
+	FileAccess       *FileAccessConfig     `yaml:"file_access"`      // nil = web file APIs may use the whole workspace
+	OutputTransforms []OutputTransform     `yaml:"output_transforms"`
+	SlowWarningAfter time.Duration         `yaml:"slow_warning_after"` // warn once when a message has no result after this long; 0 = never
+	// Host directory copied into the workspace volume the first time the
+	// agent starts with an empty workspace; "" = defaults.workspace_template.
+	WorkspaceTemplate string `yaml:"workspace_template"`
+}
+
+// SecretRefs returns the names of the vault secrets the definition uses:
+// secret:name env values, files and env_file_secret. Sorted, without
+// duplicates.
+func (d AgentDefinition) SecretRefs() []string {
+	var names []string
+	for _, v := range d.Env {
+		if name, ok := strings.CutPrefix(v, "secret:"); ok {
+			names = append(names, name)
+		}
+	}
+	for _, fm := range d.Files {
+		names = append(names, fm.Secret)
+	}
+	if d.EnvFileSecret != "" {
+		names = append(names, d.EnvFileSecret)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// WorkspaceQuotaConfig caps the size of an agent's workspace volume. Usage
+// is measured in the background; going over MaxMB logs a warning and
+// publishes a workspace_quota_exceeded event. With Enforce the agent's
+// container is also not started until the workspace is back under quota.
+type WorkspaceQuotaConfig struct {
+	MaxMB   int64 `yaml:"max_mb"`
+	Enforce bool  `yaml:"enforce"`
+}
+
+// FileAccessConfig limits which workspace paths the web UI may read or write
+// through the volume file APIs (AGENT.md). Entries are subtrees relative to
+// the workspace root, e.g. ".git" covers .git/config. Deny wins over Allow;
+// with Allow set, only paths inside one of its entries are permitted.
+type FileAccessConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// Allows reports whether the workspace-relative path p may be accessed.
+// Paths that leave the workspace are never allowed.
+func (f *FileAccessConfig) Allows(p string) bool {
+	p = path.Clean(strings.TrimPrefix(p, "/"))
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return false
+	}
+	if f == nil {
+		return true
+	}
+	within := func(entries []string) bool {
+		return slices.ContainsFunc(entries, func(e string) bool {
+			return e == "." || p == e || strings.HasPrefix(p, e+"/")
+		})
+	}
+	if within(f.Deny) {
+		return false
+	}
+	return len(f.Allow) == 0 || within(f.Allow)
+}
+
+func validateFileAccess(key string, f *FileAccessConfig) error {
+	for _, e := range append(slices.Clone(f.Allow), f.Deny...) {
+		if e == "" || path.IsAbs(e) || path.Clean(e) != e || e == ".." || strings.HasPrefix(e, "../") {
+			return fmt.Errorf("%s: %q must be a clean path relative to the workspace", key, e)
+		}
+	}
+	return nil
+}
+
+// OutputTransform is one step of an agent's reply post-processing. Type
+// selects the transformer; the other fields are its settings:
+//
+//   - regex_replace: Pattern (RE2) is replaced by Replace, which may use $1
+//   - max_length: replies longer than MaxChars runes are cut and end in "…"
+//   - append: Text is added after the reply, separated by a blank line
+type OutputTransform struct {
+	Type     string `yaml:"type"`
+	Pattern  string `yaml:"pattern"`
+	Replace  string `yaml:"replace"`
+	MaxChars int    `yaml:"max_chars"`
+	Text     string `yaml:"text"`
+}
+
+// OutputTransformTypes are the transformer types output_transforms accepts.
+var OutputTransformTypes = []string{"regex_replace", "max_length", "append"}
+
+func validateOutputTransforms(key string, transforms []OutputTransform) error {
+	for i, t := range transforms {
+		k := fmt.Sprintf("%s[%d]", key, i)
+		switch t.Type {
+		case "regex_replace":
+			if t.Pattern == "" {
+				return fmt.Errorf("%s.pattern is required", k)
+			}
+			if _, err := regexp.Compile(t.Pattern); err != nil {
+				return fmt.Errorf("%s.pattern: %w", k, err)
+			}
+		case "max_length":
+			if t.MaxChars <= 0 {
+				return fmt.Errorf("%s.max_chars must be positive", k)
+			}
+		case "append":
+			if t.Text == "" {
+				return fmt.Errorf("%s.text is required", k)
+			}
+		default:
+			return fmt.Errorf("%s.type %q must be one of %s", k, t.Type, strings.Join(OutputTransformTypes, ", "))
+		}
+	}
+	return nil
 }
 
 type FileMount struct {
@@ -101,29 +545,84 @@ type FileMount struct {
 
 type RouterConfig struct {
 	DefaultAgent string `yaml:"default_agent"`
+	// ChatDefaults and UserDefaults override DefaultAgent for messages in a
+	// Telegram chat or from a Telegram user; the chat entry wins.
+	ChatDefaults map[int64]string `yaml:"chat_defaults"`
+	UserDefaults map[int64]string `yaml:"user_defaults"`
 }
 
 type NATSConfig struct {
 	DataDir string `yaml:"data_dir"`
+	// SubjectPrefix namespaces every praktor subject, e.g. "acme" gives
+	// acme.agent.<id>.output. Empty keeps the unprefixed subjects.
+	SubjectPrefix string `yaml:"subject_prefix"`
 }
 
 type WebConfig struct {
-	Enabled bool   `yaml:"enabled"`
-	Port    int    `yaml:"port"`
-	Auth    string `yaml:"auth"`
+	Enabled bool          `yaml:"enabled"`
+	Port    int           `yaml:"port"`
+	Auth    string        `yaml:"auth"`
+	TLS     *WebTLSConfig `yaml:"tls"` // nil = plain HTTP
+	// Gateway log records kept in memory for GET /api/admin/logs.
+	LogBuffer int `yaml:"log_buffer"`
+}
+
+// WebTLSConfig serves the web UI over HTTPS on web.port, either from
+// CertFile/KeyFile or with certificates obtained via ACME. A non-zero
+// HTTPPort adds a plain HTTP listener that redirects to HTTPS and answers
+// ACME HTTP-01 challenges.
+type WebTLSConfig struct {
+	CertFile string         `yaml:"cert_file"`
+	KeyFile  string         `yaml:"key_file"`
+	ACME     *WebACMEConfig `yaml:"acme"`
+	HTTPPort int            `yaml:"http_port"`
+}
+
+// WebACMEConfig obtains and renews the certificate for Domain from Let's
+// Encrypt, caching it in CacheDir.
+type WebACMEConfig struct {
+	Domain   string `yaml:"domain"`
+	Email    string `yaml:"email"`
+	CacheDir string `yaml:"cache_dir"` // "" = data/acme
 }
 
 type SchedulerConfig struct {
 	PollInterval time.Duration `yaml:"poll_interval"`
+	// Concurrency caps the scheduled task runs in flight at once, counted
+	// from dispatch until the agent's result. 0 = unlimited.
+	Concurrency int `yaml:"concurrency"`
 }
 
 func defaults() Config {
 	return Config{
 		Defaults: DefaultsConfig{
-			Image:       "praktor-agent:latest",
-			Model:       "claude-opus-4-7",
-			MaxRunning:  5,
-			IdleTimeout: 10 * time.Minute,
+			Image:                   "praktor-agent:latest",
+			Model:                   "claude-opus-4-7",
+			MaxRunning:              5,
+			IdleTimeout:             10 * time.Minute,
+			MaxFileSizeMB:           50, // Telegram bot upload limit
+			OversizedInput:          "reject",
+			StopMode:                "remove",
+			SwarmMaxAge:             2 * time.Hour,
+			SwarmSweepInterval:      time.Hour,
+			ArtifactMaxSizeMB:       200,
+			ArtifactRetention:       30 * 24 * time.Hour,
+			AgentEventRetention:     30 * 24 * time.Hour,
+			ReadyTimeout:            30 * time.Second,
+			ImageRefreshConcurrency: 2,
+			GreetingPrompt:          "Hello!",
+			ReloadDrainTimeout:      5 * time.Minute,
+			NixGCConcurrency:        1,
+			MaintenanceMaxDuration:  6 * time.Hour,
+			ExecTimeout:             30 * time.Minute,
+			Heartbeat: HeartbeatConfig{
+				Interval:         30 * time.Second,
+				Timeout:          5 * time.Second,
+				FailureThreshold: 3,
+			},
+			FileFilter: FileFilterConfig{
+				DeniedExtensions: []string{".sh", ".bash", ".exe", ".bat", ".cmd", ".com", ".ps1", ".msi", ".scr", ".vbs", ".jar", ".apk", ".dll", ".so"},
+			},
 			// Balanced hardening profile.
 			Security: SecurityConfig{
 				NoNewPrivileges:  true,
@@ -136,12 +635,17 @@ func defaults() Config {
 				ReadonlyRootfs:   false,
 			},
 		},
+		Telegram: TelegramConfig{
+			ParseMode: "markdown",
+			Ordering:  ReplyOrderConfig{Timeout: 2 * time.Minute},
+		},
 		NATS: NATSConfig{
 			DataDir: "data/nats",
 		},
 		Web: WebConfig{
-			Enabled: true,
-			Port:    8080,
+			Enabled:   true,
+			Port:      8080,
+			LogBuffer: 5000,
 		},
 		Scheduler: SchedulerConfig{
 			PollInterval: 30 * time.Second,
@@ -150,6 +654,9 @@ func defaults() Config {
 			TTSMode:  "voice",
 			TTSVoice: "alloy",
 		},
+		Tracing: TracingConfig{
+			ServiceName: "praktor",
+		},
 	}
 }
 
@@ -162,20 +669,24 @@ func Path() string {
 }
 
 func Load() (*Config, error) {
-	cfg := defaults()
-
-	path := os.Getenv("PRAKTOR_CONFIG")
-	if path == "" {
-		path = "config/praktor.yaml"
-	}
-
-	data, err := os.ReadFile(path)
+	data, err := os.ReadFile(Path())
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return nil, fmt.Errorf("read config: %w", err)
 		}
 		// Config file not found, use defaults + env
-	} else {
+		data = nil
+	}
+	return Parse(data)
+}
+
+// Parse builds a config from YAML exactly as Load does for the config file:
+// env expansion, env overrides, agent defaults and validation. Empty data
+// yields defaults + env.
+func Parse(data []byte) (*Config, error) {
+	cfg := defaults()
+
+	if len(data) > 0 {
 		// Expand environment variables in YAML
 		expanded := os.ExpandEnv(string(data))
 		if err := yaml.Unmarshal([]byte(expanded), &cfg); err != nil {
@@ -186,6 +697,10 @@ func Load() (*Config, error) {
 	// Environment variable overrides
 	applyEnv(&cfg)
 
+	if t := cfg.Web.TLS; t != nil && t.ACME != nil && t.ACME.CacheDir == "" {
+		t.ACME.CacheDir = "data/acme"
+	}
+
 	// Apply defaults for agent definitions
 	for name, def := range cfg.Agents {
 		if def.Workspace == "" {
@@ -203,6 +718,9 @@ func Load() (*Config, error) {
 }
 
 func validate(cfg *Config) error {
+	if err := ValidateAgentIDs(slices.Collect(maps.Keys(cfg.Agents))); err != nil {
+		return err
+	}
 	if len(cfg.Agents) > 0 && cfg.Router.DefaultAgent == "" {
 		return fmt.Errorf("router.default_agent is required when agents are defined")
 	}
@@ -211,6 +729,276 @@ func validate(cfg *Config) error {
 			return fmt.Errorf("router.default_agent %q not found in agents map", cfg.Router.DefaultAgent)
 		}
 	}
+	for chatID, agent := range cfg.Router.ChatDefaults {
+		if _, ok := cfg.Agents[agent]; !ok {
+			return fmt.Errorf("router.chat_defaults[%d]: agent %q not found in agents map", chatID, agent)
+		}
+	}
+	for userID, agent := range cfg.Router.UserDefaults {
+		if _, ok := cfg.Agents[agent]; !ok {
+			return fmt.Errorf("router.user_defaults[%d]: agent %q not found in agents map", userID, agent)
+		}
+	}
+	if pm := cfg.Telegram.ParseMode; pm != "" && pm != "markdown" && pm != "html" {
+		return fmt.Errorf("telegram.parse_mode must be 'markdown' or 'html', got %q", cfg.Telegram.ParseMode)
+	}
+	if err := validateTelegramBots(cfg); err != nil {
+		return err
+	}
+	if cfg.Telegram.Ordering.Timeout <= 0 {
+		return fmt.Errorf("telegram.ordering.timeout must be positive")
+	}
+	if err := validateUserQuota(cfg); err != nil {
+		return err
+	}
+	if err := validateWebTLS(cfg); err != nil {
+		return err
+	}
+	if err := validateNotifications(cfg); err != nil {
+		return err
+	}
+	if p := cfg.NATS.SubjectPrefix; p != "" && !subjectPrefixRe.MatchString(p) {
+		return fmt.Errorf("nats.subject_prefix %q must be dot-separated tokens of letters, digits, '-' or '_'", p)
+	}
+	if err := validateRateLimit("defaults.rate_limit", cfg.Defaults.RateLimit); err != nil {
+		return err
+	}
+	if hb := cfg.Defaults.Heartbeat; hb.Interval > 0 {
+		if hb.Timeout <= 0 || hb.Timeout >= hb.Interval {
+			return fmt.Errorf("defaults.heartbeat.timeout must be positive and shorter than interval")
+		}
+		if hb.FailureThreshold < 1 {
+			return fmt.Errorf("defaults.heartbeat.failure_threshold must be at least 1")
+		}
+	}
+	if err := validateHistory("defaults.history", cfg.Defaults.History); err != nil {
+		return err
+	}
+	if cfg.Defaults.ReloadDrainTimeout < 0 {
+		return fmt.Errorf("defaults.reload_drain_timeout must not be negative")
+	}
+	if cfg.Defaults.MaxFileSizeMB < 0 {
+		return fmt.Errorf("defaults.max_file_size_mb must not be negative")
+	}
+	if cfg.Defaults.MaxMessageBytes < 0 {
+		return fmt.Errorf("defaults.max_message_bytes must not be negative")
+	}
+	if m := cfg.Defaults.OversizedInput; m != "" && m != "reject" && m != "truncate" {
+		return fmt.Errorf("defaults.oversized_input must be 'reject' or 'truncate', got %q", m)
+	}
+	for key, m := range map[string]string{"stop_mode": cfg.Defaults.StopMode, "idle_stop_mode": cfg.Defaults.IdleStopMode} {
+		if m != "" && m != "remove" && m != "stop" {
+			return fmt.Errorf("defaults.%s must be 'remove' or 'stop', got %q", key, m)
+		}
+	}
+	if cfg.Defaults.NixGCConcurrency < 0 {
+		return fmt.Errorf("defaults.nix_gc_concurrency must not be negative")
+	}
+	if cfg.Defaults.MaintenanceMaxDuration <= 0 {
+		return fmt.Errorf("defaults.maintenance_max_duration must be positive")
+	}
+	if cfg.Defaults.ExecTimeout <= 0 {
+		return fmt.Errorf("defaults.exec_timeout must be positive")
+	}
+	if v := cfg.Defaults.ClaudeVersion; v != "" && !ccdownload.ValidVersion(v) {
+		return fmt.Errorf("defaults.claude_version %q must be a release version like 2.1.197", v)
+	}
+	if err := validateContainerLabels("defaults.container_labels", cfg.Defaults.ContainerLabels); err != nil {
+		return err
+	}
+	if cfg.Defaults.SwarmMaxAge < 0 {
+		return fmt.Errorf("defaults.swarm_max_age must not be negative")
+	}
+	if cfg.Defaults.SwarmRetention < 0 {
+		return fmt.Errorf("defaults.swarm_retention must not be negative")
+	}
+	if cfg.Defaults.SwarmKeepRecent < 0 {
+		return fmt.Errorf("defaults.swarm_keep_recent must not be negative")
+	}
+	if cfg.Defaults.SwarmSweepInterval <= 0 {
+		return fmt.Errorf("defaults.swarm_sweep_interval must be positive")
+	}
+	if cfg.Defaults.ArtifactMaxSizeMB < 0 {
+		return fmt.Errorf("defaults.artifact_max_size_mb must not be negative")
+	}
+	if cfg.Defaults.ArtifactRetention < 0 {
+		return fmt.Errorf("defaults.artifact_retention must not be negative")
+	}
+	if cfg.Defaults.AgentEventRetention < 0 {
+		return fmt.Errorf("defaults.agent_event_retention must not be negative")
+	}
+	if cfg.Defaults.ReadyTimeout < 0 {
+		return fmt.Errorf("defaults.ready_timeout must not be negative")
+	}
+	if cfg.Web.LogBuffer < 0 {
+		return fmt.Errorf("web.log_buffer must not be negative")
+	}
+	if cfg.Defaults.ImageCheckInterval < 0 {
+		return fmt.Errorf("defaults.image_check_interval must not be negative")
+	}
+	if cfg.Defaults.ImageRefreshConcurrency < 0 {
+		return fmt.Errorf("defaults.image_refresh_concurrency must not be negative")
+	}
+	if cfg.Scheduler.Concurrency < 0 {
+		return fmt.Errorf("scheduler.concurrency must not be negative")
+	}
+	for name, def := range cfg.Agents {
+		if slices.Contains(def.ModelFallbacks, "") {
+			return fmt.Errorf("agents.%s.model_fallbacks must not contain empty entries", name)
+		}
+		if def.Weight < 0 {
+			return fmt.Errorf("agents.%s.weight must not be negative", name)
+		}
+		if def.Weight > cfg.Defaults.MaxRunning {
+			return fmt.Errorf("agents.%s.weight %d exceeds defaults.max_running %d, so it could never start", name, def.Weight, cfg.Defaults.MaxRunning)
+		}
+		if def.Group != "" && !agentIDRe.MatchString(def.Group) {
+			return fmt.Errorf("agents.%s.group %q must be lowercase letters, digits and '-', starting with a letter or digit", name, def.Group)
+		}
+		for _, tag := range def.Tags {
+			if tag == "" || strings.ContainsFunc(tag, unicode.IsSpace) {
+				return fmt.Errorf("agents.%s.tags: %q must be non-empty and contain no whitespace", name, tag)
+			}
+		}
+		if def.History != nil {
+			if err := validateHistory("agents."+name+".history", *def.History); err != nil {
+				return err
+			}
+		}
+		if v := def.ClaudeVersion; v != "" && !ccdownload.ValidVersion(v) {
+			return fmt.Errorf("agents.%s.claude_version %q must be a release version like 2.1.197", name, v)
+		}
+		if err := validateContainerLabels("agents."+name+".container_labels", def.ContainerLabels); err != nil {
+			return err
+		}
+		if def.SlowWarningAfter < 0 {
+			return fmt.Errorf("agents.%s.slow_warning_after must not be negative", name)
+		}
+		if q := def.WorkspaceQuota; q != nil && q.MaxMB <= 0 {
+			return fmt.Errorf("agents.%s.workspace_quota.max_mb must be positive", name)
+		}
+		if def.FileAccess != nil {
+			if err := validateFileAccess("agents."+name+".file_access", def.FileAccess); err != nil {
+				return err
+			}
+		}
+		if err := validateOutputTransforms("agents."+name+".output_transforms", def.OutputTransforms); err != nil {
+			return err
+		}
+		if def.RateLimit == nil {
+			continue
+		}
+		if err := validateRateLimit("agents."+name+".rate_limit", *def.RateLimit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateTelegramBots(cfg *Config) error {
+	if len(cfg.Telegram.Bots) == 0 {
+		return nil
+	}
+	if cfg.Telegram.Token != "" {
+		return fmt.Errorf("telegram.token and telegram.bots are mutually exclusive")
+	}
+	seen := make(map[string]bool, len(cfg.Telegram.Bots))
+	for i, bot := range cfg.Telegram.Bots {
+		if bot.Name == "" {
+			return fmt.Errorf("telegram.bots[%d].name is required", i)
+		}
+		if seen[bot.Name] {
+			return fmt.Errorf("telegram.bots: duplicate name %q", bot.Name)
+		}
+		seen[bot.Name] = true
+		if bot.Token == "" {
+			return fmt.Errorf("telegram.bots.%s.token is required", bot.Name)
+		}
+		for _, agentID := range bot.Agents {
+			if _, ok := cfg.Agents[agentID]; !ok {
+				return fmt.Errorf("telegram.bots.%s.agents: agent %q not found in agents map", bot.Name, agentID)
+			}
+		}
+	}
+	return nil
+}
+
+func validateUserQuota(cfg *Config) error {
+	q := cfg.Telegram.Quota
+	if q.Hourly < 0 || q.Daily < 0 {
+		return fmt.Errorf("telegram.quota.hourly and daily must not be negative")
+	}
+	allowed := make(map[int64]bool)
+	for _, bot := range cfg.Telegram.BotConfigs() {
+		if len(bot.AllowFrom) == 0 {
+			return nil // some bot accepts everyone
+		}
+		for _, id := range bot.AllowFrom {
+			allowed[id] = true
+		}
+	}
+	for _, id := range q.Admins {
+		if !allowed[id] {
+			return fmt.Errorf("telegram.quota.admins: user %d is not in allow_from", id)
+		}
+	}
+	return nil
+}
+
+func validateWebTLS(cfg *Config) error {
+	t := cfg.Web.TLS
+	if t == nil {
+		return nil
+	}
+	hasFiles := t.CertFile != "" || t.KeyFile != ""
+	switch {
+	case hasFiles && t.ACME != nil:
+		return fmt.Errorf("web.tls: cert_file/key_file and acme are mutually exclusive")
+	case hasFiles && (t.CertFile == "" || t.KeyFile == ""):
+		return fmt.Errorf("web.tls: cert_file and key_file must be set together")
+	case !hasFiles && t.ACME == nil:
+		return fmt.Errorf("web.tls requires cert_file and key_file, or acme")
+	case t.ACME != nil && t.ACME.Domain == "":
+		return fmt.Errorf("web.tls.acme.domain is required")
+	}
+	if t.HTTPPort < 0 || t.HTTPPort > 65535 {
+		return fmt.Errorf("web.tls.http_port must be between 0 and 65535")
+	}
+	if t.HTTPPort != 0 && t.HTTPPort == cfg.Web.Port {
+		return fmt.Errorf("web.tls.http_port must differ from web.port")
+	}
+	return nil
+}
+
+func validateRateLimit(key string, rl RateLimitConfig) error {
+	if rl.RatePerMinute < 0 {
+		return fmt.Errorf("%s.rate_per_minute must not be negative", key)
+	}
+	if rl.Burst < 0 {
+		return fmt.Errorf("%s.burst must not be negative", key)
+	}
+	return nil
+}
+
+func validateHistory(key string, h HistoryConfig) error {
+	if h.Turns < 0 {
+		return fmt.Errorf("%s.turns must not be negative", key)
+	}
+	if h.MaxChars < 0 {
+		return fmt.Errorf("%s.max_chars must not be negative", key)
+	}
+	return nil
+}
+
+func validateContainerLabels(key string, labels map[string]string) error {
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		if k == "" || strings.ContainsFunc(k, unicode.IsSpace) {
+			return fmt.Errorf("%s: label %q must be non-empty and contain no whitespace", key, k)
+		}
+		if k == "praktor" || strings.HasPrefix(k, "praktor.") {
+			return fmt.Errorf("%s: label %q is reserved", key, k)
+		}
+	}
 	return nil
 }
 
@@ -244,4 +1032,10 @@ func applyEnv(cfg *Config) {
 	if v := os.Getenv("OPENAI_API_KEY"); v != "" {
 		cfg.Speech.APIKey = v
 	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		cfg.Tracing.OTLPEndpoint = v
+	}
+	if v := os.Getenv("PRAKTOR_NATS_SUBJECT_PREFIX"); v != "" {
+		cfg.NATS.SubjectPrefix = v
+	}
 }
@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -19,6 +21,9 @@ func TestDefaults(t *testing.T) {
 	if cfg.Defaults.IdleTimeout != 10*time.Minute {
 		t.Errorf("expected idle_timeout 10m, got %v", cfg.Defaults.IdleTimeout)
 	}
+	if cfg.Defaults.MaxFileSizeMB != 50 {
+		t.Errorf("expected max_file_size_mb 50, got %d", cfg.Defaults.MaxFileSizeMB)
+	}
 	if AgentsBasePath != "data/agents" {
 		t.Errorf("expected AgentsBasePath data/agents, got %s", AgentsBasePath)
 	}
@@ -46,6 +51,18 @@ func TestDefaults(t *testing.T) {
 	if cfg.Speech.APIKey != "" {
 		t.Errorf("expected empty speech api_key by default, got %s", cfg.Speech.APIKey)
 	}
+	if !slices.Contains(cfg.Defaults.FileFilter.DeniedExtensions, ".sh") {
+		t.Errorf("expected .sh denied by default, got %v", cfg.Defaults.FileFilter.DeniedExtensions)
+	}
+	if hb := cfg.Defaults.Heartbeat; hb.Interval != 30*time.Second || hb.Timeout != 5*time.Second || hb.FailureThreshold != 3 {
+		t.Errorf("expected heartbeat 30s/5s/3, got %+v", hb)
+	}
+	if cfg.Tracing.OTLPEndpoint != "" {
+		t.Errorf("expected tracing disabled by default, got endpoint %s", cfg.Tracing.OTLPEndpoint)
+	}
+	if cfg.Tracing.ServiceName != "praktor" {
+		t.Errorf("expected default tracing service_name praktor, got %s", cfg.Tracing.ServiceName)
+	}
 }
 
 func TestLoadWithEnvOverrides(t *testing.T) {
@@ -220,6 +237,39 @@ agents:
 	}
 }
 
+func TestValidation_AgentIDs(t *testing.T) {
+	for _, id := range []string{"general", "coder-2", "0ps"} {
+		if err := ValidateAgentIDs([]string{id}); err != nil {
+			t.Errorf("ValidateAgentIDs(%q) = %v, want nil", id, err)
+		}
+	}
+
+	// A dot would make agent.<id>.output parse back as a different agent,
+	// and uppercase ids would differ from their lowercase twins only by case.
+	err := ValidateAgentIDs([]string{"general", "team.alpha", "Coder", "my agent", "-x", "a_b", ""})
+	if err == nil {
+		t.Fatal("expected error for invalid agent ids")
+	}
+	want := `invalid agent id(s) "", "-x", "Coder", "a_b", "my agent", "team.alpha"`
+	if !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("error = %v, want prefix %s", err, want)
+	}
+
+	if err := ValidateAgentIDs([]string{"general", "lifecycle"}); err == nil || !strings.Contains(err.Error(), `reserved agent id(s) "lifecycle"`) {
+		t.Errorf("ValidateAgentIDs(lifecycle) = %v, want reserved id error", err)
+	}
+
+	yaml := "agents:\n  Team.Alpha:\n    workspace: team\nrouter:\n  default_agent: Team.Alpha\n"
+	if _, err := Parse([]byte(yaml)); err == nil || !strings.Contains(err.Error(), `"Team.Alpha"`) {
+		t.Errorf("Parse: expected invalid agent id error, got %v", err)
+	}
+
+	dup := "agents:\n  general: {}\n  general: {}\nrouter:\n  default_agent: general\n"
+	if _, err := Parse([]byte(dup)); err == nil {
+		t.Error("Parse: expected error for duplicate agent id")
+	}
+}
+
 func TestValidation_DefaultAgentMustExist(t *testing.T) {
 	dir := t.TempDir()
 	cfgPath := filepath.Join(dir, "config.yaml")
@@ -242,3 +292,611 @@ router:
 		t.Fatal("expected validation error for nonexistent default_agent")
 	}
 }
+
+func TestValidation_NegativeRateLimit(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+
+	yaml := `
+agents:
+  general:
+    description: "General assistant"
+    rate_limit:
+      rate_per_minute: -1
+router:
+  default_agent: general
+`
+	if err := os.WriteFile(cfgPath, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PRAKTOR_CONFIG", cfgPath)
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected validation error for negative rate_per_minute")
+	}
+}
+
+func TestValidation_HeartbeatTimeout(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+
+	yaml := `
+defaults:
+  heartbeat:
+    interval: 10s
+    timeout: 10s
+`
+	if err := os.WriteFile(cfgPath, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PRAKTOR_CONFIG", cfgPath)
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("expected validation error for heartbeat timeout >= interval")
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Setenv("PRAKTOR_TEST_MODEL", "claude-haiku-5")
+	cfg, err := Parse([]byte(`
+agents:
+  general:
+    model: "${PRAKTOR_TEST_MODEL}"
+router:
+  default_agent: general
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Agents["general"].Model; got != "claude-haiku-5" {
+		t.Errorf("expected env-expanded model, got %q", got)
+	}
+	if got := cfg.Agents["general"].Workspace; got != "general" {
+		t.Errorf("expected workspace default to 'general', got %q", got)
+	}
+	if cfg.Defaults.MaxRunning != 5 {
+		t.Errorf("expected defaults applied, got max_running %d", cfg.Defaults.MaxRunning)
+	}
+
+	if _, err := Parse([]byte("agents:\n  general: {}\n")); err == nil {
+		t.Error("expected validation error for missing default_agent")
+	}
+}
+
+func TestValidation_Group(t *testing.T) {
+	cfg, err := Parse([]byte("agents:\n  data:\n    group: analytics\nrouter:\n  default_agent: data\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Agents["data"].Group; got != "analytics" {
+		t.Errorf("group = %q, want analytics", got)
+	}
+	if _, err := Parse([]byte("agents:\n  data:\n    group: Data Team\nrouter:\n  default_agent: data\n")); err == nil {
+		t.Error("expected validation error for group with spaces")
+	}
+}
+
+func TestValidation_ModelFallbacks(t *testing.T) {
+	cfg, err := Parse([]byte(`
+agents:
+  general:
+    model: claude-opus-4-7
+    model_fallbacks: [claude-sonnet-4-6, claude-haiku-4-5]
+router:
+  default_agent: general
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Agents["general"].ModelFallbacks; len(got) != 2 || got[0] != "claude-sonnet-4-6" {
+		t.Errorf("unexpected model_fallbacks %v", got)
+	}
+
+	if _, err := Parse([]byte(`
+agents:
+  general:
+    model_fallbacks: [""]
+router:
+  default_agent: general
+`)); err == nil {
+		t.Error("expected validation error for empty fallback model")
+	}
+}
+
+func TestValidation_Tags(t *testing.T) {
+	cfg, err := Parse([]byte(`
+agents:
+  general:
+    tags: [team:infra, env:prod]
+router:
+  default_agent: general
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Agents["general"].Tags; len(got) != 2 || got[0] != "team:infra" {
+		t.Errorf("unexpected tags %v", got)
+	}
+
+	for _, bad := range []string{`[""]`, `["team infra"]`} {
+		if _, err := Parse([]byte("agents:\n  general:\n    tags: " + bad + "\nrouter:\n  default_agent: general\n")); err == nil {
+			t.Errorf("expected validation error for tags %s", bad)
+		}
+	}
+}
+
+func TestValidation_History(t *testing.T) {
+	cfg, err := Parse([]byte(`
+defaults:
+  history:
+    turns: 6
+agents:
+  general:
+    history:
+      turns: 0
+router:
+  default_agent: general
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Defaults.History.Turns != 6 {
+		t.Errorf("expected defaults.history.turns 6, got %d", cfg.Defaults.History.Turns)
+	}
+	if h := cfg.Agents["general"].History; h == nil || h.Turns != 0 {
+		t.Errorf("expected agent history override with turns 0, got %+v", h)
+	}
+
+	for _, bad := range []string{"turns: -1", "max_chars: -5"} {
+		if _, err := Parse([]byte("defaults:\n  history:\n    " + bad + "\n")); err == nil {
+			t.Errorf("expected validation error for history %s", bad)
+		}
+	}
+}
+
+func TestTelegramBotConfigs(t *testing.T) {
+	single := TelegramConfig{Token: "tok", AllowFrom: []int64{1}, MainChatID: 7}
+	bots := single.BotConfigs()
+	if len(bots) != 1 || bots[0].Name != DefaultBotName || bots[0].Token != "tok" || bots[0].MainChatID != 7 {
+		t.Fatalf("expected single default bot from top-level token, got %+v", bots)
+	}
+	if !bots[0].AllowsAgent("anything") {
+		t.Error("expected default bot to allow all agents")
+	}
+	if got := (TelegramConfig{}).BotConfigs(); len(got) != 0 {
+		t.Errorf("expected no bots without a token, got %+v", got)
+	}
+}
+
+func TestTelegramMainChatIDFor(t *testing.T) {
+	if got := (TelegramConfig{MainChatID: 7}).MainChatIDFor("coder"); got != 7 {
+		t.Errorf("single bot: got %d, want 7", got)
+	}
+	multi := TelegramConfig{MainChatID: 7, Bots: []TelegramBotConfig{
+		{Name: "ops", MainChatID: 10, Agents: []string{"general"}},
+		{Name: "eng", MainChatID: 20, Agents: []string{"coder", "general"}},
+	}}
+	for agent, want := range map[string]int64{"general": 10, "coder": 20, "other": 0} {
+		if got := multi.MainChatIDFor(agent); got != want {
+			t.Errorf("%s: got %d, want %d", agent, got, want)
+		}
+	}
+}
+
+func TestValidation_TelegramBots(t *testing.T) {
+	base := `
+agents:
+  general:
+    description: "General assistant"
+router:
+  default_agent: general
+telegram:
+`
+	tests := map[string]string{
+		"valid": `
+  bots:
+    - name: ops
+      token: t1
+      agents: [general]
+`,
+		"unknown agent": `
+  bots:
+    - name: ops
+      token: t1
+      agents: [missing]
+`,
+		"duplicate name": `
+  bots:
+    - name: ops
+      token: t1
+    - name: ops
+      token: t2
+`,
+		"token and bots": `
+  token: t0
+  bots:
+    - name: ops
+      token: t1
+`,
+	}
+	t.Setenv("PRAKTOR_TELEGRAM_TOKEN", "")
+	for name, yaml := range tests {
+		_, err := Parse([]byte(base + yaml))
+		if name == "valid" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestReloadDrainTimeout(t *testing.T) {
+	cfg, err := Parse([]byte("defaults:\n  image: x\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Defaults.ReloadDrainTimeout != 5*time.Minute {
+		t.Errorf("expected default reload_drain_timeout 5m, got %v", cfg.Defaults.ReloadDrainTimeout)
+	}
+
+	cfg, err = Parse([]byte("defaults:\n  reload_drain_timeout: 0s\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Defaults.ReloadDrainTimeout != 0 {
+		t.Errorf("expected reload_drain_timeout 0, got %v", cfg.Defaults.ReloadDrainTimeout)
+	}
+
+	if _, err := Parse([]byte("defaults:\n  reload_drain_timeout: -1s\n")); err == nil {
+		t.Error("expected validation error for negative reload_drain_timeout")
+	}
+}
+
+func TestNixGCConcurrency(t *testing.T) {
+	cfg, err := Parse([]byte("defaults:\n  image: x\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Defaults.NixGCConcurrency != 1 {
+		t.Errorf("expected default nix_gc_concurrency 1, got %d", cfg.Defaults.NixGCConcurrency)
+	}
+
+	if _, err := Parse([]byte("defaults:\n  nix_gc_concurrency: -1\n")); err == nil {
+		t.Error("expected validation error for negative nix_gc_concurrency")
+	}
+}
+
+func TestNATSSubjectPrefix(t *testing.T) {
+	cfg, err := Parse([]byte("nats:\n  subject_prefix: acme.prod\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.NATS.SubjectPrefix != "acme.prod" {
+		t.Errorf("expected subject_prefix acme.prod, got %q", cfg.NATS.SubjectPrefix)
+	}
+
+	for _, bad := range []string{"acme.*", "acme.>", "acme..prod", ".acme", "acme prod"} {
+		if _, err := Parse([]byte("nats:\n  subject_prefix: \"" + bad + "\"\n")); err == nil {
+			t.Errorf("expected validation error for subject_prefix %q", bad)
+		}
+	}
+}
+
+func TestValidation_WorkspaceQuota(t *testing.T) {
+	cfg, err := Parse([]byte(`
+agents:
+  general:
+    workspace_quota:
+      max_mb: 512
+      enforce: true
+router:
+  default_agent: general
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q := cfg.Agents["general"].WorkspaceQuota; q == nil || q.MaxMB != 512 || !q.Enforce {
+		t.Errorf("unexpected workspace_quota %+v", q)
+	}
+
+	if _, err := Parse([]byte("agents:\n  general:\n    workspace_quota:\n      max_mb: 0\nrouter:\n  default_agent: general\n")); err == nil {
+		t.Error("expected validation error for workspace_quota.max_mb 0")
+	}
+}
+
+func TestValidation_WebTLS(t *testing.T) {
+	cfg, err := Parse([]byte("web:\n  tls:\n    acme:\n      domain: praktor.example.com\n    http_port: 80\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tls := cfg.Web.TLS; tls == nil || tls.ACME.Domain != "praktor.example.com" || tls.ACME.CacheDir != "data/acme" || tls.HTTPPort != 80 {
+		t.Errorf("unexpected web.tls %+v", tls)
+	}
+	if cfg, err := Parse(nil); err != nil || cfg.Web.TLS != nil {
+		t.Errorf("expected plain HTTP by default, got %+v, %v", cfg.Web.TLS, err)
+	}
+
+	for _, bad := range []string{
+		"web:\n  tls: {}\n",
+		"web:\n  tls:\n    cert_file: c.pem\n",
+		"web:\n  tls:\n    cert_file: c.pem\n    key_file: k.pem\n    acme:\n      domain: a.example\n",
+		"web:\n  tls:\n    acme: {}\n",
+		"web:\n  port: 8443\n  tls:\n    cert_file: c.pem\n    key_file: k.pem\n    http_port: 8443\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
+
+func TestValidation_Notifications(t *testing.T) {
+	cfg, err := Parse([]byte("notifications:\n  backends:\n    - name: ops\n      type: slack\n      url: https://hooks.slack.com/services/x\n      events: [agent_error]\n    - name: mail\n      type: email\n      smtp:\n        host: smtp.example.com\n        from: praktor@example.com\n        to: [ops@example.com]\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b := cfg.Notifications.Backends; len(b) != 2 || b[0].Events[0] != "agent_error" || b[1].SMTP.To[0] != "ops@example.com" {
+		t.Errorf("unexpected notifications %+v", b)
+	}
+
+	for _, bad := range []string{
+		"notifications:\n  backends:\n    - type: slack\n      url: https://example.com/hook\n",
+		"notifications:\n  backends:\n    - name: a\n      type: teams\n      url: https://example.com/hook\n",
+		"notifications:\n  backends:\n    - name: a\n      type: discord\n      url: example.com/hook\n",
+		"notifications:\n  backends:\n    - name: a\n      type: email\n      smtp:\n        host: smtp.example.com\n",
+		"notifications:\n  backends:\n    - name: a\n      type: slack\n      url: https://example.com/hook\n      events: [message]\n",
+		"notifications:\n  backends:\n    - name: a\n      type: slack\n      url: https://example.com/a\n    - name: a\n      type: slack\n      url: https://example.com/b\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
+
+func TestValidation_MaxFileSize(t *testing.T) {
+	cfg, err := Parse([]byte("defaults:\n  max_file_size_mb: 0\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Defaults.MaxFileSizeMB != 0 {
+		t.Errorf("max_file_size_mb = %d, want 0 (unlimited)", cfg.Defaults.MaxFileSizeMB)
+	}
+	if _, err := Parse([]byte("defaults:\n  max_file_size_mb: -1\n")); err == nil {
+		t.Error("expected validation error for a negative max_file_size_mb")
+	}
+}
+
+func TestValidation_MaxMessageBytes(t *testing.T) {
+	cfg, err := Parse([]byte("defaults:\n  max_message_bytes: 65536\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Defaults.MaxMessageBytes != 65536 || cfg.Defaults.OversizedInput != "reject" {
+		t.Errorf("got max_message_bytes %d, oversized_input %q", cfg.Defaults.MaxMessageBytes, cfg.Defaults.OversizedInput)
+	}
+	for _, bad := range []string{
+		"defaults:\n  max_message_bytes: -1\n",
+		"defaults:\n  oversized_input: drop\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
+
+func TestValidation_ClaudeVersion(t *testing.T) {
+	cfg, err := Parse([]byte("defaults:\n  claude_version: 2.1.197\nagents:\n  coder:\n    claude_version: 2.2.0-beta.1\nrouter:\n  default_agent: coder\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Defaults.ClaudeVersion != "2.1.197" || cfg.Agents["coder"].ClaudeVersion != "2.2.0-beta.1" {
+		t.Errorf("got defaults %q, agent %q", cfg.Defaults.ClaudeVersion, cfg.Agents["coder"].ClaudeVersion)
+	}
+	for _, bad := range []string{
+		"defaults:\n  claude_version: latest\n",
+		"agents:\n  coder:\n    claude_version: \"2.1\"\nrouter:\n  default_agent: coder\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
+
+func TestValidation_UserQuota(t *testing.T) {
+	t.Setenv("PRAKTOR_TELEGRAM_TOKEN", "")
+	cfg, err := Parse([]byte("telegram:\n  token: t\n  allow_from: [1, 2]\n  quota:\n    hourly: 20\n    daily: 100\n    admins: [1]\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q := cfg.Telegram.Quota; q.Hourly != 20 || q.Daily != 100 || !q.IsAdmin(1) || q.IsAdmin(2) {
+		t.Errorf("unexpected quota %+v", q)
+	}
+	for _, bad := range []string{
+		"telegram:\n  token: t\n  allow_from: [1]\n  quota:\n    admins: [3]\n",
+		"telegram:\n  quota:\n    hourly: -1\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
+
+func TestValidation_StopMode(t *testing.T) {
+	cfg, err := Parse([]byte("defaults:\n  idle_stop_mode: stop\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Defaults.StopMode != "remove" || cfg.Defaults.IdleStopMode != "stop" {
+		t.Errorf("got stop_mode %q, idle_stop_mode %q", cfg.Defaults.StopMode, cfg.Defaults.IdleStopMode)
+	}
+	for _, bad := range []string{"defaults:\n  stop_mode: pause\n", "defaults:\n  idle_stop_mode: kill\n"} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
+
+func TestValidation_ContainerLabels(t *testing.T) {
+	cfg, err := Parse([]byte("defaults:\n  container_labels:\n    team: infra\nagents:\n  coder:\n    container_labels:\n      env: \"{agent}\"\nrouter:\n  default_agent: coder\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Defaults.ContainerLabels["team"] != "infra" || cfg.Agents["coder"].ContainerLabels["env"] != "{agent}" {
+		t.Errorf("got defaults %v, agent %v", cfg.Defaults.ContainerLabels, cfg.Agents["coder"].ContainerLabels)
+	}
+	for _, bad := range []string{
+		"defaults:\n  container_labels:\n    praktor.agent: x\n",
+		"agents:\n  coder:\n    container_labels:\n      praktor.managed: \"false\"\nrouter:\n  default_agent: coder\n",
+		"defaults:\n  container_labels:\n    \"my label\": x\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
+
+func TestValidation_Artifacts(t *testing.T) {
+	cfg, err := Parse([]byte("defaults:\n  artifact_max_size_mb: 10\n  artifact_retention: 24h\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Defaults.ArtifactMaxSizeMB != 10 || cfg.Defaults.ArtifactRetention != 24*time.Hour {
+		t.Errorf("got max %d MB, retention %v", cfg.Defaults.ArtifactMaxSizeMB, cfg.Defaults.ArtifactRetention)
+	}
+	for _, bad := range []string{
+		"defaults:\n  artifact_max_size_mb: -1\n",
+		"defaults:\n  artifact_retention: -1h\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
+
+func TestValidation_ChatDefaults(t *testing.T) {
+	base := "agents:\n  infra:\n    workspace: infra\n  support:\n    workspace: support\nrouter:\n  default_agent: infra\n"
+	cfg, err := Parse([]byte(base + "  chat_defaults:\n    -1001234: support\n  user_defaults:\n    42: support\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Router.ChatDefaults[-1001234] != "support" || cfg.Router.UserDefaults[42] != "support" {
+		t.Errorf("got chat defaults %v, user defaults %v", cfg.Router.ChatDefaults, cfg.Router.UserDefaults)
+	}
+	for _, bad := range []string{
+		base + "  chat_defaults:\n    -1001234: missing\n",
+		base + "  user_defaults:\n    42: missing\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
+
+func TestValidation_ImageRefresh(t *testing.T) {
+	cfg, err := Parse([]byte("defaults:\n  image_check_interval: 10m\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Defaults.ImageCheckInterval != 10*time.Minute || cfg.Defaults.ImageRefreshConcurrency != 2 {
+		t.Errorf("got interval %v, concurrency %d", cfg.Defaults.ImageCheckInterval, cfg.Defaults.ImageRefreshConcurrency)
+	}
+	for _, bad := range []string{
+		"defaults:\n  image_check_interval: -1m\n",
+		"defaults:\n  image_refresh_concurrency: -1\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
+
+func TestValidation_AgentWeight(t *testing.T) {
+	base := "defaults:\n  max_running: 4\nrouter:\n  default_agent: big\nagents:\n  big:\n    workspace: big\n"
+	cfg, err := Parse([]byte(base + "    weight: 3\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Agents["big"].Weight != 3 {
+		t.Errorf("weight = %d, want 3", cfg.Agents["big"].Weight)
+	}
+	for _, bad := range []string{base + "    weight: -1\n", base + "    weight: 5\n"} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
+
+func TestValidation_FileAccess(t *testing.T) {
+	base := "router:\n  default_agent: general\nagents:\n  general:\n    file_access:\n"
+	cfg, err := Parse([]byte(base + "      allow: [AGENT.md, docs]\n      deny: [docs/private]\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fa := cfg.Agents["general"].FileAccess; fa == nil || len(fa.Allow) != 2 || len(fa.Deny) != 1 {
+		t.Errorf("unexpected file_access %+v", fa)
+	}
+	for _, bad := range []string{"/etc", "../other", "docs/", ""} {
+		if _, err := Parse([]byte(base + "      deny: [\"" + bad + "\"]\n")); err == nil {
+			t.Errorf("expected validation error for deny entry %q", bad)
+		}
+	}
+}
+
+func TestFileAccessAllows(t *testing.T) {
+	fa := &FileAccessConfig{Allow: []string{"AGENT.md", "docs"}, Deny: []string{"docs/private"}}
+	tests := []struct {
+		fa   *FileAccessConfig
+		path string
+		want bool
+	}{
+		{nil, "AGENT.md", true},
+		{nil, ".ssh/id_ed25519", true},
+		{nil, "../other/AGENT.md", false},
+		{fa, "AGENT.md", true},
+		{fa, "/AGENT.md", true},
+		{fa, "docs/notes.md", true},
+		{fa, "docs/private/keys", false},
+		{fa, "docs/sub/../private/keys", false},
+		{fa, "docsx/notes.md", false},
+		{fa, ".git/config", false},
+		{&FileAccessConfig{Deny: []string{".git", ".ssh"}}, ".git/config", false},
+		{&FileAccessConfig{Deny: []string{".git", ".ssh"}}, "src/main.go", true},
+	}
+	for _, tt := range tests {
+		if got := tt.fa.Allows(tt.path); got != tt.want {
+			t.Errorf("%+v Allows(%q) = %v, want %v", tt.fa, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestValidation_OutputTransforms(t *testing.T) {
+	base := "router:\n  default_agent: general\nagents:\n  general:\n    output_transforms:\n"
+	cfg, err := Parse([]byte(base +
+		"      - type: regex_replace\n        pattern: '\\[internal:[^\\]]*\\]'\n" +
+		"      - type: max_length\n        max_chars: 2000\n" +
+		"      - type: append\n        text: Not legal advice.\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ts := cfg.Agents["general"].OutputTransforms; len(ts) != 3 || ts[0].Type != "regex_replace" || ts[1].MaxChars != 2000 {
+		t.Errorf("unexpected output_transforms %+v", ts)
+	}
+	for _, bad := range []string{
+		"      - type: uppercase\n",
+		"      - type: regex_replace\n",
+		"      - type: regex_replace\n        pattern: '('\n",
+		"      - type: max_length\n",
+		"      - type: append\n",
+	} {
+		if _, err := Parse([]byte(base + bad)); err == nil {
+			t.Errorf("expected validation error for %q", bad)
+		}
+	}
+}
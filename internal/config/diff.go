@@ -13,6 +13,7 @@ type ConfigDiff struct {
 
 	RouterChanged   bool
 	NewDefaultAgent string
+	NewRouter       RouterConfig
 
 	SchedulerChanged bool
 	NewPollInterval  SchedulerConfig
@@ -65,13 +66,14 @@ func Diff(old, new *Config) ConfigDiff {
 	}
 
 	// Router
-	if old.Router.DefaultAgent != new.Router.DefaultAgent {
+	if !reflect.DeepEqual(old.Router, new.Router) {
 		d.RouterChanged = true
 		d.NewDefaultAgent = new.Router.DefaultAgent
+		d.NewRouter = new.Router
 	}
 
 	// Scheduler
-	if old.Scheduler.PollInterval != new.Scheduler.PollInterval {
+	if old.Scheduler != new.Scheduler {
 		d.SchedulerChanged = true
 		d.NewPollInterval = new.Scheduler
 	}
@@ -86,21 +88,51 @@ func Diff(old, new *Config) ConfigDiff {
 	if old.Telegram.Token != new.Telegram.Token {
 		d.NonReloadable = append(d.NonReloadable, "telegram.token")
 	}
+	if !reflect.DeepEqual(old.Telegram.Bots, new.Telegram.Bots) {
+		d.NonReloadable = append(d.NonReloadable, "telegram.bots")
+	}
+	if old.Telegram.ParseMode != new.Telegram.ParseMode {
+		d.NonReloadable = append(d.NonReloadable, "telegram.parse_mode")
+	}
+	if !reflect.DeepEqual(old.Telegram.Ordering, new.Telegram.Ordering) {
+		d.NonReloadable = append(d.NonReloadable, "telegram.ordering")
+	}
+	if !reflect.DeepEqual(old.Telegram.Quota, new.Telegram.Quota) {
+		d.NonReloadable = append(d.NonReloadable, "telegram.quota")
+	}
 	if old.Web.Port != new.Web.Port {
 		d.NonReloadable = append(d.NonReloadable, "web.port")
 	}
+	if !reflect.DeepEqual(old.Web.TLS, new.Web.TLS) {
+		d.NonReloadable = append(d.NonReloadable, "web.tls")
+	}
+	if old.Web.LogBuffer != new.Web.LogBuffer {
+		d.NonReloadable = append(d.NonReloadable, "web.log_buffer")
+	}
 	if old.NATS.DataDir != new.NATS.DataDir {
 		d.NonReloadable = append(d.NonReloadable, "nats.data_dir")
 	}
+	if old.NATS.SubjectPrefix != new.NATS.SubjectPrefix {
+		d.NonReloadable = append(d.NonReloadable, "nats.subject_prefix")
+	}
 	if old.Vault.Passphrase != new.Vault.Passphrase {
 		d.NonReloadable = append(d.NonReloadable, "vault.passphrase")
 	}
+	if old.Vault.RequireVerify != new.Vault.RequireVerify {
+		d.NonReloadable = append(d.NonReloadable, "vault.require_verify")
+	}
 	if old.AgentMail.APIKey != new.AgentMail.APIKey {
 		d.NonReloadable = append(d.NonReloadable, "agentmail.api_key")
 	}
 	if old.Speech.APIKey != new.Speech.APIKey {
 		d.NonReloadable = append(d.NonReloadable, "speech.api_key")
 	}
+	if !reflect.DeepEqual(old.Notifications, new.Notifications) {
+		d.NonReloadable = append(d.NonReloadable, "notifications")
+	}
+	if old.Tracing != new.Tracing {
+		d.NonReloadable = append(d.NonReloadable, "tracing")
+	}
 
 	return d
 }
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"slices"
 	"testing"
 	"time"
 )
@@ -121,6 +122,13 @@ func TestDiff_RouterChanged(t *testing.T) {
 	if d.NewDefaultAgent != "bot2" {
 		t.Errorf("expected bot2, got %s", d.NewDefaultAgent)
 	}
+
+	old = &Config{Router: RouterConfig{DefaultAgent: "bot"}}
+	new = &Config{Router: RouterConfig{DefaultAgent: "bot", ChatDefaults: map[int64]string{-100: "bot2"}}}
+	d = Diff(old, new)
+	if !d.RouterChanged || d.NewRouter.ChatDefaults[-100] != "bot2" {
+		t.Errorf("expected chat defaults change, got %+v", d)
+	}
 }
 
 func TestDiff_SchedulerChanged(t *testing.T) {
@@ -130,6 +138,11 @@ func TestDiff_SchedulerChanged(t *testing.T) {
 	if !d.SchedulerChanged {
 		t.Error("expected scheduler changed")
 	}
+
+	new = &Config{Scheduler: SchedulerConfig{PollInterval: 30 * time.Second, Concurrency: 2}}
+	if !Diff(old, new).SchedulerChanged {
+		t.Error("expected scheduler changed for concurrency")
+	}
 }
 
 func TestDiff_NonReloadable(t *testing.T) {
@@ -162,6 +175,35 @@ func TestDiff_SpeechAPIKeyNonReloadable(t *testing.T) {
 	}
 }
 
+func TestDiff_NotificationsNonReloadable(t *testing.T) {
+	old := &Config{}
+	new := &Config{Notifications: NotificationsConfig{Backends: []NotifyBackend{{Name: "ops", Type: "slack", URL: "https://example.com"}}}}
+	d := Diff(old, new)
+	if len(d.NonReloadable) != 1 || d.NonReloadable[0] != "notifications" {
+		t.Errorf("expected notifications in non-reloadable, got %v", d.NonReloadable)
+	}
+}
+
+func TestDiff_StartupSettingsNonReloadable(t *testing.T) {
+	old := &Config{}
+	new := &Config{
+		Telegram: TelegramConfig{
+			Ordering: ReplyOrderConfig{Enabled: true},
+			Quota:    UserQuotaConfig{Hourly: 5},
+		},
+		Web:   WebConfig{LogBuffer: 100},
+		Vault: VaultConfig{RequireVerify: true},
+	}
+	d := Diff(old, new)
+	want := []string{"telegram.ordering", "telegram.quota", "web.log_buffer", "vault.require_verify"}
+	if !slices.Equal(d.NonReloadable, want) {
+		t.Errorf("non-reloadable = %v, want %v", d.NonReloadable, want)
+	}
+	if d.HasChanges() {
+		t.Error("startup-only settings should not count as reloadable changes")
+	}
+}
+
 func TestDiff_MainChatIDChanged(t *testing.T) {
 	old := &Config{Telegram: TelegramConfig{MainChatID: 123}}
 	new := &Config{Telegram: TelegramConfig{MainChatID: 456}}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MaskedValue replaces every secret in a Masked config.
+const MaskedValue = "***"
+
+// sensitiveEnvWords mark agent env vars whose values are masked even when
+// they are not secret: references.
+var sensitiveEnvWords = []string{"TOKEN", "KEY", "SECRET", "PASS", "AUTH", "CREDENTIAL"}
+
+// Masked returns a copy of the config with tokens, passwords, API keys,
+// notification webhook URLs and secret-like agent env values replaced by MaskedValue. Empty fields stay
+// empty, so an unset token is still visible as unset.
+func (c *Config) Masked() *Config {
+	m := *c
+	mask(&m.Telegram.Token)
+	m.Telegram.Bots = slices.Clone(c.Telegram.Bots)
+	for i := range m.Telegram.Bots {
+		mask(&m.Telegram.Bots[i].Token)
+	}
+	mask(&m.Defaults.AnthropicAPIKey)
+	mask(&m.Defaults.OAuthToken)
+	mask(&m.Web.Auth)
+	mask(&m.Vault.Passphrase)
+	mask(&m.AgentMail.APIKey)
+	mask(&m.Speech.APIKey)
+	m.Notifications.Backends = slices.Clone(c.Notifications.Backends)
+	for i := range m.Notifications.Backends {
+		mask(&m.Notifications.Backends[i].URL)
+		mask(&m.Notifications.Backends[i].SMTP.Password)
+	}
+
+	m.Agents = maps.Clone(c.Agents)
+	for name, def := range m.Agents {
+		if len(def.Env) == 0 {
+			continue
+		}
+		def.Env = maps.Clone(def.Env)
+		for k, v := range def.Env {
+			if v != "" && (strings.HasPrefix(v, "secret:") || sensitiveEnvName(k)) {
+				def.Env[k] = MaskedValue
+			}
+		}
+		m.Agents[name] = def
+	}
+	return &m
+}
+
+// MaskedMap returns the masked config keyed by its YAML field names, ready
+// to be encoded as JSON for the API.
+func (c *Config) MaskedMap() (map[string]any, error) {
+	data, err := yaml.Marshal(c.Masked())
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	var out map[string]any
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("unmarshal config: %w", err)
+	}
+	return out, nil
+}
+
+func mask(s *string) {
+	if *s != "" {
+		*s = MaskedValue
+	}
+}
+
+func sensitiveEnvName(name string) bool {
+	upper := strings.ToUpper(name)
+	return slices.ContainsFunc(sensitiveEnvWords, func(w string) bool {
+		return strings.Contains(upper, w)
+	})
+}
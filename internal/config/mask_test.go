@@ -0,0 +1,77 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMaskedHidesSecrets(t *testing.T) {
+	secrets := []string{
+		"tg-token-1", "tg-bot-token-2", "sk-ant-key-3", "oauth-token-4", "web-pass-5",
+		"vault-pass-6", "agentmail-key-7", "openai-key-8", "gh-token-9", "db-pass-10", "secret:github-pat",
+		"https://hooks.slack.com/services/T0/B0/x11", "smtp-pass-12",
+	}
+	cfg := &Config{
+		Telegram: TelegramConfig{
+			Token: "tg-token-1",
+			Bots:  []TelegramBotConfig{{Name: "ops", Token: "tg-bot-token-2", MainChatID: 7}},
+		},
+		Defaults:  DefaultsConfig{AnthropicAPIKey: "sk-ant-key-3", OAuthToken: "oauth-token-4", Model: "claude-opus-4-7"},
+		Web:       WebConfig{Auth: "web-pass-5", Port: 8080},
+		Vault:     VaultConfig{Passphrase: "vault-pass-6"},
+		AgentMail: AgentMailConfig{APIKey: "agentmail-key-7"},
+		Speech:    SpeechConfig{APIKey: "openai-key-8", TTSVoice: "alloy"},
+		Notifications: NotificationsConfig{Backends: []NotifyBackend{
+			{Name: "ops", Type: "slack", URL: "https://hooks.slack.com/services/T0/B0/x11"},
+			{Name: "oncall", Type: "email", SMTP: SMTPConfig{Host: "smtp.example.com", Password: "smtp-pass-12"}},
+		}},
+		Agents: map[string]AgentDefinition{
+			"bot": {
+				Workspace: "bot",
+				Env: map[string]string{
+					"GITHUB_TOKEN": "gh-token-9",
+					"DB_PASSWORD":  "db-pass-10",
+					"GH_PAT":       "secret:github-pat",
+					"TZ":           "Europe/Athens",
+				},
+			},
+		},
+	}
+
+	m, err := cfg.MaskedMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, s := range secrets {
+		if strings.Contains(out, s) {
+			t.Errorf("masked config contains %q: %s", s, out)
+		}
+	}
+	for _, want := range []string{`"TZ":"Europe/Athens"`, `"model":"claude-opus-4-7"`, `"port":8080`, `"tts_voice":"alloy"`, `"main_chat_id":7`, `"host":"smtp.example.com"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("masked config lacks %s: %s", want, out)
+		}
+	}
+
+	// The running config itself is left untouched.
+	if cfg.Telegram.Bots[0].Token != "tg-bot-token-2" || cfg.Agents["bot"].Env["GITHUB_TOKEN"] != "gh-token-9" ||
+		cfg.Notifications.Backends[1].SMTP.Password != "smtp-pass-12" {
+		t.Error("Masked modified the original config")
+	}
+}
+
+func TestMaskedKeepsEmptyFieldsEmpty(t *testing.T) {
+	m := (&Config{Vault: VaultConfig{Passphrase: "p"}}).Masked()
+	if m.Vault.Passphrase != MaskedValue {
+		t.Errorf("passphrase = %q, want %q", m.Vault.Passphrase, MaskedValue)
+	}
+	if m.Telegram.Token != "" || m.Defaults.AnthropicAPIKey != "" {
+		t.Error("unset secrets should stay empty")
+	}
+}
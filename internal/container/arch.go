@@ -0,0 +1,70 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+
+	"github.com/moby/moby/client"
+	"github.com/mtzanidakis/praktor/internal/ccdownload"
+)
+
+// ErrImageArchMismatch is matched by the *ImageArchError StartAgent returns
+// when the agent image was built for another CPU architecture than the
+// Docker host's, which would otherwise fail later as an exec format error.
+var ErrImageArchMismatch = errors.New("image architecture does not match host")
+
+// ImageArchError names the mismatched architectures, in Go's naming.
+type ImageArchError struct {
+	Image     string
+	ImageArch string
+	HostArch  string
+}
+
+func (e *ImageArchError) Error() string {
+	return fmt.Sprintf("image %s is %s, host is %s", e.Image, e.ImageArch, e.HostArch)
+}
+
+func (e *ImageArchError) Unwrap() error { return ErrImageArchMismatch }
+
+// archMismatch compares an image's architecture with the host's, either of
+// which may use Docker, uname or Go naming. Unknown (empty) values pass.
+func archMismatch(image, imageArch, hostArch string) error {
+	if imageArch == "" || hostArch == "" {
+		return nil
+	}
+	imageArch, hostArch = ccdownload.GoArch(imageArch), ccdownload.GoArch(hostArch)
+	if imageArch == hostArch {
+		return nil
+	}
+	return &ImageArchError{Image: image, ImageArch: imageArch, HostArch: hostArch}
+}
+
+// checkImageArch returns an *ImageArchError if image can't run on the
+// Docker host. A missing image is left for ContainerCreate to report.
+// Called with m.mu held.
+func (m *Manager) checkImageArch(ctx context.Context, image string) error {
+	res, err := m.docker.ImageInspect(ctx, image)
+	if err != nil {
+		return nil
+	}
+	return archMismatch(image, res.Architecture, m.hostArchLocked(ctx))
+}
+
+// hostArchLocked returns the Docker daemon's architecture, which may differ
+// from the gateway's with a remote DOCKER_HOST, falling back to the
+// gateway's own until the daemon answers. Called with m.mu held.
+func (m *Manager) hostArchLocked(ctx context.Context) string {
+	if m.hostArch != "" {
+		return m.hostArch
+	}
+	res, err := m.docker.Info(ctx, client.InfoOptions{})
+	if err != nil || res.Info.Architecture == "" {
+		slog.Debug("docker info unavailable, assuming the gateway's architecture", "error", err)
+		return runtime.GOARCH
+	}
+	m.hostArch = res.Info.Architecture
+	return m.hostArch
+}
@@ -0,0 +1,36 @@
+package container
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestArchMismatch(t *testing.T) {
+	tests := []struct {
+		imageArch, hostArch string
+		want                string // "" = no error
+	}{
+		{"amd64", "x86_64", ""},
+		{"arm64", "aarch64", ""},
+		{"arm64", "arm64", ""},
+		{"arm", "armv7l", ""},
+		{"386", "i686", ""},
+		{"", "aarch64", ""}, // unknown image architecture
+		{"amd64", "", ""},
+		{"amd64", "aarch64", "image praktor-agent:latest is amd64, host is arm64"},
+		{"arm64", "x86_64", "image praktor-agent:latest is arm64, host is amd64"},
+		{"arm64", "armv7l", "image praktor-agent:latest is arm64, host is arm"},
+	}
+	for _, tt := range tests {
+		err := archMismatch("praktor-agent:latest", tt.imageArch, tt.hostArch)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("archMismatch(%q, %q) = %v, want nil", tt.imageArch, tt.hostArch, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrImageArchMismatch) || err.Error() != tt.want {
+			t.Errorf("archMismatch(%q, %q) = %v, want %q", tt.imageArch, tt.hostArch, err, tt.want)
+		}
+	}
+}
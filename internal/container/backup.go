@@ -0,0 +1,112 @@
+package container
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	dockercontainer "github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+)
+
+// BackupManifestName is written first in every backup and snapshot archive.
+// It lacks the volume prefix, so restores skip it.
+const BackupManifestName = "manifest.json"
+
+// BackupManifest describes a backup or snapshot archive. It is
+// informational for `praktor restore`, which detects the format from the
+// stream itself; snapshot restores check AgentID and Volumes.
+type BackupManifest struct {
+	CreatedAt   time.Time `json:"created_at"`
+	Compression struct {
+		Algorithm string `json:"algorithm"`
+		Level     string `json:"level"`
+	} `json:"compression"`
+	Volumes []string `json:"volumes"`
+	AgentID string   `json:"agent_id,omitempty"` // set on single-agent snapshots
+}
+
+// WriteBackupManifest writes m as the archive's manifest entry.
+func WriteBackupManifest(tw *tar.Writer, m BackupManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    BackupManifestName,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: m.CreatedAt,
+	}); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// BackupVolume appends every file of volume volName to tw, each prefixed
+// with the volume name.
+func BackupVolume(ctx context.Context, docker *client.Client, tw *tar.Writer, volName, image string) error {
+	return readVolume(ctx, docker, volName, image, func(src io.Reader) error {
+		return copyVolumeEntries(tw, volName, src)
+	})
+}
+
+// readVolume passes fn a tar of volume volName's files, read through a
+// temporary container of image that mounts the volume read-only.
+func readVolume(ctx context.Context, docker *client.Client, volName, image string, fn func(src io.Reader) error) error {
+	containerName := fmt.Sprintf("praktor-backup-%d", time.Now().UnixNano())
+
+	resp, err := docker.ContainerCreate(ctx, client.ContainerCreateOptions{
+		Config:     &dockercontainer.Config{Image: image, Entrypoint: []string{"true"}},
+		HostConfig: &dockercontainer.HostConfig{Binds: []string{volName + ":/vol:ro"}},
+		Name:       containerName,
+	})
+	if err != nil {
+		return fmt.Errorf("create temp container: %w", err)
+	}
+	defer func() {
+		_, _ = docker.ContainerRemove(ctx, resp.ID, client.ContainerRemoveOptions{Force: true})
+	}()
+
+	copyResp, err := docker.CopyFromContainer(ctx, resp.ID, client.CopyFromContainerOptions{SourcePath: "/vol/."})
+	if err != nil {
+		return fmt.Errorf("copy from container: %w", err)
+	}
+	defer func() { _ = copyResp.Content.Close() }()
+
+	return fn(copyResp.Content)
+}
+
+// copyVolumeEntries re-writes the tar read from src into tw with every
+// entry prefixed by volName.
+func copyVolumeEntries(tw *tar.Writer, volName string, src io.Reader) error {
+	srcTar := tar.NewReader(src)
+	for {
+		hdr, err := srcTar.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar entry: %w", err)
+		}
+
+		hdr.Name = path.Join(volName, hdr.Name)
+		if hdr.Typeflag == tar.TypeDir && !strings.HasSuffix(hdr.Name, "/") {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("write tar header: %w", err)
+		}
+		if hdr.Size > 0 {
+			if _, err := io.Copy(tw, srcTar); err != nil {
+				return fmt.Errorf("write tar data: %w", err)
+			}
+		}
+	}
+}
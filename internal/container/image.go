@@ -6,9 +6,14 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 
+	cerrdefs "github.com/containerd/errdefs"
 	goarchive "github.com/moby/go-archive"
 	"github.com/moby/moby/client"
+	"github.com/mtzanidakis/praktor/internal/ccdownload"
 )
 
 func BuildAgentImage(ctx context.Context, docker *client.Client, imageName string) error {
@@ -38,3 +43,101 @@ func BuildAgentImage(ctx context.Context, docker *client.Client, imageName strin
 	slog.Info("agent image built", "image", imageName)
 	return nil
 }
+
+// claudeImageDockerfile layers a pinned claude binary over an agent image.
+// The binary's mode comes from the build context, so the image's USER is
+// left alone.
+const claudeImageDockerfile = `ARG BASE_IMAGE
+FROM ${BASE_IMAGE}
+ARG CLAUDE_VERSION
+LABEL praktor.claude_version=${CLAUDE_VERSION}
+COPY claude /usr/local/bin/claude
+`
+
+// ClaudeImageTag names the image built from base with claude version
+// baked in, e.g. praktor-agent:latest → praktor-agent:latest-claude-2.1.197.
+func ClaudeImageTag(base, version string) string {
+	repo, _, _ := strings.Cut(base, "@")
+	tag := "latest"
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo, tag = repo[:i], repo[i+1:]
+	}
+	return repo + ":" + tag + "-claude-" + version
+}
+
+func claudeImageBuildOptions(base, version string) client.ImageBuildOptions {
+	return client.ImageBuildOptions{
+		Tags:       []string{ClaudeImageTag(base, version)},
+		Dockerfile: "Dockerfile",
+		BuildArgs:  map[string]*string{"BASE_IMAGE": &base, "CLAUDE_VERSION": &version},
+		Remove:     true,
+	}
+}
+
+// EnsureClaudeImage returns the tag of base with claude version baked in,
+// building it on first use. The binary is downloaded and checksum-verified
+// on the gateway, for the gateway's architecture.
+func (m *Manager) EnsureClaudeImage(ctx context.Context, base, version string) (string, error) {
+	m.buildMu.Lock()
+	defer m.buildMu.Unlock()
+
+	tag := ClaudeImageTag(base, version)
+	if _, err := m.docker.ImageInspect(ctx, tag); err == nil {
+		return tag, nil
+	}
+
+	slog.Info("building pinned claude image", "image", tag, "base", base)
+	release, err := ccdownload.Resolve(version, ccdownload.Platform("linux", runtime.GOARCH, false))
+	if err != nil {
+		return "", fmt.Errorf("resolve claude %s: %w", version, err)
+	}
+	dir, err := os.MkdirTemp("", "praktor-claude-")
+	if err != nil {
+		return "", fmt.Errorf("create build dir: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	if err := ccdownload.DownloadAndVerify(release.DownloadURL, release.SHA256, filepath.Join(dir, "claude")); err != nil {
+		return "", fmt.Errorf("download claude %s: %w", version, err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "claude"), 0o555); err != nil {
+		return "", fmt.Errorf("chmod claude: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(claudeImageDockerfile), 0o644); err != nil {
+		return "", fmt.Errorf("write Dockerfile: %w", err)
+	}
+
+	tar, err := goarchive.TarWithOptions(dir, &goarchive.TarOptions{})
+	if err != nil {
+		return "", fmt.Errorf("create build context: %w", err)
+	}
+	defer func() { _ = tar.Close() }()
+
+	resp, err := m.docker.ImageBuild(ctx, tar, claudeImageBuildOptions(base, version))
+	if err != nil {
+		return "", fmt.Errorf("build image: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		slog.Warn("error reading build output", "error", err)
+	}
+
+	// Build failures are reported in the output stream, not as an error.
+	if _, err := m.docker.ImageInspect(ctx, tag); err != nil {
+		return "", fmt.Errorf("build image %s: %w", tag, err)
+	}
+	slog.Info("pinned claude image built", "image", tag, "version", version)
+	return tag, nil
+}
+
+// ImageID returns the id of the image ref currently points to, which
+// changes when the tag is rebuilt or pulled.
+func (m *Manager) ImageID(ctx context.Context, ref string) (string, error) {
+	res, err := m.docker.ImageInspect(ctx, ref)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return "", fmt.Errorf("%w: %s", ErrImageNotFound, ref)
+		}
+		return "", fmt.Errorf("inspect image: %w", err)
+	}
+	return res.ID, nil
+}
@@ -0,0 +1,36 @@
+package container
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestClaudeImageTag(t *testing.T) {
+	tests := []struct {
+		base, want string
+	}{
+		{"praktor-agent:latest", "praktor-agent:latest-claude-2.1.197"},
+		{"praktor-agent", "praktor-agent:latest-claude-2.1.197"},
+		{"ghcr.io/acme/agent:v3", "ghcr.io/acme/agent:v3-claude-2.1.197"},
+		{"localhost:5000/agent", "localhost:5000/agent:latest-claude-2.1.197"},
+		{"agent@sha256:abcd", "agent:latest-claude-2.1.197"},
+	}
+	for _, tt := range tests {
+		if got := ClaudeImageTag(tt.base, "2.1.197"); got != tt.want {
+			t.Errorf("ClaudeImageTag(%q) = %q, want %q", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestClaudeImageBuildOptions(t *testing.T) {
+	opts := claudeImageBuildOptions("ghcr.io/acme/agent:v3", "2.1.197")
+	if !slices.Equal(opts.Tags, []string{"ghcr.io/acme/agent:v3-claude-2.1.197"}) {
+		t.Errorf("tags = %v", opts.Tags)
+	}
+	if base := opts.BuildArgs["BASE_IMAGE"]; base == nil || *base != "ghcr.io/acme/agent:v3" {
+		t.Errorf("BASE_IMAGE build arg = %v", base)
+	}
+	if v := opts.BuildArgs["CLAUDE_VERSION"]; v == nil || *v != "2.1.197" {
+		t.Errorf("CLAUDE_VERSION build arg = %v", v)
+	}
+}
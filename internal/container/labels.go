@@ -0,0 +1,26 @@
+package container
+
+import (
+	"maps"
+	"strings"
+)
+
+// buildLabels returns the labels for an agent container: defaults overlaid
+// by the agent's own, with {agent}, {model} and {workspace} expanded in the
+// values. The reserved praktor.* labels always win.
+func buildLabels(defaults, agent map[string]string, agentID, model, workspace string) map[string]string {
+	expand := strings.NewReplacer("{agent}", agentID, "{model}", model, "{workspace}", workspace)
+	labels := make(map[string]string, len(defaults)+len(agent)+2)
+	for k, v := range defaults {
+		labels[k] = expand.Replace(v)
+	}
+	for k, v := range agent {
+		labels[k] = expand.Replace(v)
+	}
+	maps.DeleteFunc(labels, func(k, _ string) bool {
+		return k == labelPrefix || strings.HasPrefix(k, labelPrefix+".")
+	})
+	labels[labelPrefix+".managed"] = "true"
+	labels[labelPrefix+".agent"] = agentID
+	return labels
+}
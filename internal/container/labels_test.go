@@ -0,0 +1,37 @@
+package container
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestBuildLabels(t *testing.T) {
+	defaults := map[string]string{
+		"team":        "platform",
+		"env":         "prod",
+		"cost-center": "{agent}-{model}",
+		"praktor.x":   "nope",
+	}
+	agent := map[string]string{
+		"team":            "research",
+		"workspace":       "{workspace}",
+		"praktor.agent":   "impostor",
+		"praktor.managed": "false",
+	}
+	got := buildLabels(defaults, agent, "coder", "claude-opus-4-6", "code")
+	want := map[string]string{
+		"team":            "research",
+		"env":             "prod",
+		"cost-center":     "coder-claude-opus-4-6",
+		"workspace":       "code",
+		"praktor.managed": "true",
+		"praktor.agent":   "coder",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("buildLabels() = %v, want %v", got, want)
+	}
+
+	if got := buildLabels(nil, nil, "a", "", ""); len(got) != 2 || got["praktor.agent"] != "a" {
+		t.Errorf("buildLabels() without custom labels = %v", got)
+	}
+}
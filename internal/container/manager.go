@@ -4,15 +4,19 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	cerrdefs "github.com/containerd/errdefs"
 	"github.com/moby/moby/api/pkg/stdcopy"
 	dockercontainer "github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/api/types/network"
@@ -32,7 +36,12 @@ type Manager struct {
 	cfg         config.DefaultsConfig
 	mu          sync.RWMutex
 	active      map[string]*ContainerInfo // agentID → container
+	stopped     map[string]*ContainerInfo // agentID → container kept by a stop-mode stop
+	stopping    map[string]chan struct{}  // agentID → closed once its in-flight stop is done
 	networkName string                    // resolved network name
+	buildMu     sync.Mutex                // serializes pinned claude image builds
+	volumes     *volumeHelpers            // helper containers for volume file copies
+	hostArch    string                    // Docker daemon architecture, once known
 }
 
 type ContainerInfo struct {
@@ -42,21 +51,28 @@ type ContainerInfo struct {
 	Status    string    `json:"status"`
 	StartedAt time.Time `json:"started_at"`
 	SessionID string    `json:"session_id"`
+	Image     string    `json:"image"`              // image reference the container was created from
+	ImageID   string    `json:"image_id,omitempty"` // id of the image the container was created from
+	Weight    int       `json:"weight"`             // share of MaxRunning it uses
 }
 
 type AgentOpts struct {
-	AgentID      string
-	Workspace    string
-	Model        string
-	Image        string
-	SessionID    string
-	Mounts       []Mount
-	NATSUrl      string
-	Env          map[string]string
-	SecretFiles  []SecretFile
-	AllowedTools []string
-	NixEnabled   bool
-	Security     *config.SecurityConfig // nil = use manager defaults
+	AgentID         string
+	Workspace       string
+	WorkspaceVolume string // overrides praktor-wk-<workspace>, e.g. for ephemeral swarm members
+	Model           string
+	Image           string
+	SessionID       string
+	Mounts          []Mount
+	NATSUrl         string
+	Env             map[string]string
+	SecretFiles     []SecretFile
+	AllowedTools    []string
+	NixEnabled      bool
+	Security        *config.SecurityConfig // nil = use manager defaults
+	ClaudeVersion   string                 // bake this claude version into the image; "" = the image's own
+	Labels          map[string]string      // merged over defaults.container_labels
+	Weight          int                    // share of MaxRunning the container uses; 0 = 1
 }
 
 type SecretFile struct {
@@ -71,12 +87,16 @@ func NewManager(bus *natsbus.Bus, cfg config.DefaultsConfig) (*Manager, error) {
 		return nil, fmt.Errorf("docker client: %w", err)
 	}
 
-	return &Manager{
-		docker: docker,
-		bus:    bus,
-		cfg:    cfg,
-		active: make(map[string]*ContainerInfo),
-	}, nil
+	m := &Manager{
+		docker:   docker,
+		bus:      bus,
+		cfg:      cfg,
+		active:   make(map[string]*ContainerInfo),
+		stopped:  make(map[string]*ContainerInfo),
+		stopping: make(map[string]chan struct{}),
+	}
+	m.volumes = newVolumeHelpers(m)
+	return m, nil
 }
 
 // UpdateDefaults replaces the defaults config used for new containers.
@@ -109,16 +129,47 @@ func (m *Manager) ensureNetwork(ctx context.Context) error {
 	return nil
 }
 
+var (
+	// ErrMaxContainers is returned by StartAgent when max_running agent
+	// containers are already up.
+	ErrMaxContainers = errors.New("max containers reached")
+	// ErrImageNotFound is returned by StartAgent when the agent image does
+	// not exist locally; images are built or pulled outside the manager.
+	ErrImageNotFound = errors.New("agent image not found")
+	// ErrExecTimeout is returned by Exec when the command is still running
+	// at its timeout.
+	ErrExecTimeout = errors.New("exec timed out")
+)
+
+// StartAgent creates and starts the agent's container. The manager lock is
+// held for the whole start so the MaxRunning check and the active insert are
+// atomic; callers serialize per agent (see Orchestrator.startAgent). A start
+// while the agent's container is still being stopped waits for the stop, so
+// the stop can't remove the new container.
 func (m *Manager) StartAgent(ctx context.Context, opts AgentOpts) (*ContainerInfo, error) {
 	m.mu.Lock()
+	for {
+		done, ok := m.stopping[opts.AgentID]
+		if !ok {
+			break
+		}
+		m.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		m.mu.Lock()
+	}
 	defer m.mu.Unlock()
 
 	if existing, ok := m.active[opts.AgentID]; ok {
 		return existing, nil
 	}
 
-	if len(m.active) >= m.cfg.MaxRunning {
-		return nil, fmt.Errorf("max containers (%d) reached", m.cfg.MaxRunning)
+	weight := max(1, opts.Weight)
+	if err := m.admitLocked(opts.AgentID, weight); err != nil {
+		return nil, err
 	}
 
 	if err := m.ensureNetwork(ctx); err != nil {
@@ -127,15 +178,13 @@ func (m *Manager) StartAgent(ctx context.Context, opts AgentOpts) (*ContainerInf
 
 	containerName := fmt.Sprintf("praktor-agent-%s", opts.AgentID)
 
-	// Remove any stale container with the same name
-	timeout := 5
-	_, _ = m.docker.ContainerStop(ctx, containerName, client.ContainerStopOptions{Timeout: &timeout})
-	_, _ = m.docker.ContainerRemove(ctx, containerName, client.ContainerRemoveOptions{Force: true})
-
 	env := []string{
 		fmt.Sprintf("NATS_URL=%s", opts.NATSUrl),
 		fmt.Sprintf("AGENT_ID=%s", opts.AgentID),
 	}
+	if p := natsbus.SubjectPrefix(); p != "" {
+		env = append(env, fmt.Sprintf("NATS_SUBJECT_PREFIX=%s", p))
+	}
 	if opts.SessionID != "" {
 		env = append(env, fmt.Sprintf("SESSION_ID=%s", opts.SessionID))
 	}
@@ -145,10 +194,12 @@ func (m *Manager) StartAgent(ctx context.Context, opts AgentOpts) (*ContainerInf
 	if m.cfg.OAuthToken != "" {
 		env = append(env, fmt.Sprintf("CLAUDE_CODE_OAUTH_TOKEN=%s", m.cfg.OAuthToken))
 	}
-	if model := opts.Model; model != "" {
+	model := opts.Model
+	if model == "" {
+		model = m.cfg.Model
+	}
+	if model != "" {
 		env = append(env, fmt.Sprintf("CLAUDE_MODEL=%s", model))
-	} else if m.cfg.Model != "" {
-		env = append(env, fmt.Sprintf("CLAUDE_MODEL=%s", m.cfg.Model))
 	}
 	if tz := os.Getenv("TZ"); tz != "" {
 		env = append(env, fmt.Sprintf("TZ=%s", tz))
@@ -169,11 +220,18 @@ func (m *Manager) StartAgent(ctx context.Context, opts AgentOpts) (*ContainerInf
 	if image == "" {
 		image = m.cfg.Image
 	}
+	if opts.ClaudeVersion != "" {
+		pinned, err := m.EnsureClaudeImage(ctx, image, opts.ClaudeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("pin claude version: %w", err)
+		}
+		image = pinned
+	}
 
 	containerCfg := &dockercontainer.Config{
 		Image:  image,
 		Env:    env,
-		Labels: map[string]string{labelPrefix + ".managed": "true", labelPrefix + ".agent": opts.AgentID},
+		Labels: buildLabels(m.cfg.ContainerLabels, opts.Labels, opts.AgentID, model, opts.Workspace),
 	}
 
 	hostCfg := &dockercontainer.HostConfig{
@@ -181,34 +239,56 @@ func (m *Manager) StartAgent(ctx context.Context, opts AgentOpts) (*ContainerInf
 		NetworkMode: dockercontainer.NetworkMode(m.networkName),
 	}
 	m.applySecurity(hostCfg, opts.Security)
-
-	networkCfg := &network.NetworkingConfig{}
-
-	resp, err := m.docker.ContainerCreate(ctx, client.ContainerCreateOptions{
-		Config:           containerCfg,
-		HostConfig:       hostCfg,
-		NetworkingConfig: networkCfg,
-		Name:             containerName,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("create container: %w", err)
-	}
-
-	// Copy secret files into container before starting
-	for _, sf := range opts.SecretFiles {
-		if err := m.copyFileToContainer(ctx, resp.ID, sf); err != nil {
-			_, _ = m.docker.ContainerRemove(ctx, resp.ID, client.ContainerRemoveOptions{Force: true})
-			return nil, fmt.Errorf("copy secret file %s: %w", sf.Target, err)
+	spec := containerSpec(containerCfg, hostCfg, opts.SecretFiles)
+	containerCfg.Labels[labelPrefix+".spec"] = spec
+
+	// A container kept by a stop-mode stop is restarted if nothing it was
+	// created from has changed; anything else with the name is stale.
+	var id string
+	restarted := false
+	if _, kept := m.stopped[opts.AgentID]; kept || m.keepsContainers() {
+		imageID, _ := m.ImageID(ctx, image)
+		id, restarted = m.restartStopped(ctx, containerName, spec, imageID)
+	}
+	delete(m.stopped, opts.AgentID)
+	if !restarted {
+		timeout := 5
+		_, _ = m.docker.ContainerStop(ctx, containerName, client.ContainerStopOptions{Timeout: &timeout})
+		_, _ = m.docker.ContainerRemove(ctx, containerName, client.ContainerRemoveOptions{Force: true})
+
+		if err := m.checkImageArch(ctx, image); err != nil {
+			return nil, err
+		}
+		resp, err := m.docker.ContainerCreate(ctx, client.ContainerCreateOptions{
+			Config:           containerCfg,
+			HostConfig:       hostCfg,
+			NetworkingConfig: &network.NetworkingConfig{},
+			Name:             containerName,
+		})
+		if err != nil {
+			if cerrdefs.IsNotFound(err) {
+				return nil, fmt.Errorf("%w: %s: %w", ErrImageNotFound, image, err)
+			}
+			return nil, fmt.Errorf("create container: %w", err)
+		}
+		id = resp.ID
+
+		// Copy secret files into container before starting
+		for _, sf := range opts.SecretFiles {
+			if err := m.copyFileToContainer(ctx, id, sf); err != nil {
+				_, _ = m.docker.ContainerRemove(ctx, id, client.ContainerRemoveOptions{Force: true})
+				return nil, fmt.Errorf("copy secret file %s: %w", sf.Target, err)
+			}
 		}
-	}
 
-	if _, err := m.docker.ContainerStart(ctx, resp.ID, client.ContainerStartOptions{}); err != nil {
-		return nil, fmt.Errorf("start container: %w", err)
+		if _, err := m.docker.ContainerStart(ctx, id, client.ContainerStartOptions{}); err != nil {
+			return nil, fmt.Errorf("start container: %w", err)
+		}
 	}
 
 	// Ensure volume mount points are owned by praktor (uid 10321).
 	// Docker named volumes may be created with root ownership.
-	chownResp, err := m.docker.ExecCreate(ctx, resp.ID, client.ExecCreateOptions{
+	chownResp, err := m.docker.ExecCreate(ctx, id, client.ExecCreateOptions{
 		User: "root",
 		Cmd:  []string{"chown", "-R", "10321:10321", "/workspace/agent", "/home/praktor"},
 	})
@@ -220,7 +300,7 @@ func (m *Manager) StartAgent(ctx context.Context, opts AgentOpts) (*ContainerInf
 
 	// Start nix-daemon as root via Docker exec (container runs as praktor)
 	if opts.NixEnabled {
-		execResp, err := m.docker.ExecCreate(ctx, resp.ID, client.ExecCreateOptions{
+		execResp, err := m.docker.ExecCreate(ctx, id, client.ExecCreateOptions{
 			User: "root",
 			Cmd:  []string{"nix-daemon"},
 		})
@@ -234,16 +314,23 @@ func (m *Manager) StartAgent(ctx context.Context, opts AgentOpts) (*ContainerInf
 	}
 
 	info := &ContainerInfo{
-		ID:        resp.ID,
+		ID:        id,
 		AgentID:   opts.AgentID,
 		Name:      containerName,
 		Status:    "running",
 		StartedAt: time.Now(),
 		SessionID: opts.SessionID,
+		Image:     image,
+		Weight:    weight,
+	}
+	// The container's own image id, not the tag's current one: a tag
+	// rebuilt since a kept container was created must still read as stale.
+	if res, err := m.docker.ContainerInspect(ctx, id, client.ContainerInspectOptions{}); err == nil {
+		info.ImageID = res.Container.Image
 	}
 	m.active[opts.AgentID] = info
 
-	slog.Info("agent container started", "agent", opts.AgentID, "container", resp.ID[:12])
+	slog.Info("agent container started", "agent", opts.AgentID, "container", id[:12], "restarted", restarted)
 	return info, nil
 }
 
@@ -341,29 +428,71 @@ func (m *Manager) copyFileToContainer(ctx context.Context, containerID string, s
 	return err
 }
 
+// StopAgent stops the agent's container, removing or keeping it as
+// defaults.stop_mode says.
 func (m *Manager) StopAgent(ctx context.Context, agentID string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.mu.RLock()
+	mode := m.cfg.StopMode
+	m.mu.RUnlock()
+	return m.StopAgentWith(ctx, agentID, mode)
+}
 
+// StopAgentWith stops the agent's container and, unless mode is "stop",
+// removes it. The agent is dropped from the active set first so the (up to
+// 10s) docker stop doesn't hold the manager lock and block GetRunning for
+// every other agent; it is marked stopping instead, and StartAgent waits
+// until the container is gone.
+func (m *Manager) StopAgentWith(ctx context.Context, agentID, mode string) error {
+	m.mu.Lock()
 	info, ok := m.active[agentID]
 	if !ok {
+		m.mu.Unlock()
 		return nil
 	}
+	delete(m.active, agentID)
+	done := make(chan struct{})
+	m.stopping[agentID] = done
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.stopping, agentID)
+		m.mu.Unlock()
+		close(done)
+	}()
 
 	timeout := 10
 	if _, err := m.docker.ContainerStop(ctx, info.ID, client.ContainerStopOptions{Timeout: &timeout}); err != nil {
 		slog.Warn("failed to stop container gracefully", "container", info.ID[:12], "error", err)
 	}
 
+	if mode == "stop" {
+		kept := *info
+		kept.Status = "stopped"
+		m.mu.Lock()
+		m.stopped[agentID] = &kept
+		m.mu.Unlock()
+		slog.Info("agent container stopped, kept for restart", "agent", agentID)
+		return nil
+	}
+
 	if _, err := m.docker.ContainerRemove(ctx, info.ID, client.ContainerRemoveOptions{Force: true}); err != nil {
 		slog.Warn("failed to remove container", "container", info.ID[:12], "error", err)
 	}
 
-	delete(m.active, agentID)
 	slog.Info("agent container stopped", "agent", agentID)
 	return nil
 }
 
+// RemoveVolume deletes a named volume. Force makes a missing volume a no-op.
+func (m *Manager) RemoveVolume(ctx context.Context, name string) error {
+	m.volumes.release(ctx, name)
+	if _, err := m.docker.VolumeRemove(ctx, name, client.VolumeRemoveOptions{Force: true}); err != nil {
+		return fmt.Errorf("remove volume %s: %w", name, err)
+	}
+	slog.Info("volume removed", "volume", name)
+	return nil
+}
+
 func (m *Manager) StopAll(ctx context.Context) {
 	m.mu.RLock()
 	agentIDs := make([]string, 0, len(m.active))
@@ -375,6 +504,13 @@ func (m *Manager) StopAll(ctx context.Context) {
 	for _, id := range agentIDs {
 		_ = m.StopAgent(ctx, id)
 	}
+	m.ReleaseVolumeHelpers(ctx)
+}
+
+// ReleaseVolumeHelpers removes every volume helper container, e.g. at
+// shutdown.
+func (m *Manager) ReleaseVolumeHelpers(ctx context.Context) {
+	m.volumes.releaseAll(ctx)
 }
 
 func (m *Manager) ListRunning(ctx context.Context) ([]ContainerInfo, error) {
@@ -388,6 +524,20 @@ func (m *Manager) ListRunning(ctx context.Context) ([]ContainerInfo, error) {
 	return result, nil
 }
 
+// admitLocked checks that a container of weight fits next to the active
+// ones. MaxRunning is a budget of weight, so with every weight 1 it is a
+// container count. m.mu is held.
+func (m *Manager) admitLocked(agentID string, weight int) error {
+	used := 0
+	for _, info := range m.active {
+		used += max(1, info.Weight)
+	}
+	if used+weight > m.cfg.MaxRunning {
+		return fmt.Errorf("%w (%d of %d in use, %s needs %d)", ErrMaxContainers, used, m.cfg.MaxRunning, agentID, weight)
+	}
+	return nil
+}
+
 func (m *Manager) GetRunning(agentID string) *ContainerInfo {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -397,8 +547,11 @@ func (m *Manager) GetRunning(agentID string) *ContainerInfo {
 	return nil
 }
 
-// Exec runs a command inside a running agent container and returns the combined output.
-func (m *Manager) Exec(ctx context.Context, agentID string, cmd []string) (string, error) {
+// Exec runs a command inside a running agent container and returns the
+// combined output. A timeout > 0 bounds the run: once it passes, or ctx is
+// done, the command is killed and Exec returns ErrExecTimeout (or ctx's
+// error) without waiting for Docker to notice.
+func (m *Manager) Exec(ctx context.Context, agentID string, cmd []string, timeout time.Duration) (string, error) {
 	m.mu.RLock()
 	info, ok := m.active[agentID]
 	m.mu.RUnlock()
@@ -406,8 +559,19 @@ func (m *Manager) Exec(ctx context.Context, agentID string, cmd []string) (strin
 		return "", fmt.Errorf("agent %s is not running", agentID)
 	}
 
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// The command runs in the background of a shell that records its pid,
+	// so it can be killed from a second exec if it hangs.
+	pidFile := fmt.Sprintf("/tmp/praktor-exec-%d.pid", time.Now().UnixNano())
+	wrapped := append([]string{"sh", "-c", `"$@" & { echo $! > "$0"; } 2>/dev/null; wait $!; s=$?; rm -f "$0"; exit $s`, pidFile}, cmd...)
+
 	execResp, err := m.docker.ExecCreate(ctx, info.ID, client.ExecCreateOptions{
-		Cmd:          cmd,
+		Cmd:          wrapped,
 		AttachStdout: true,
 		AttachStderr: true,
 	})
@@ -421,21 +585,107 @@ func (m *Manager) Exec(ctx context.Context, agentID string, cmd []string) (strin
 	}
 	defer attach.Close()
 
-	var stdout, stderr bytes.Buffer
-	if _, err := stdcopy.StdCopy(&stdout, &stderr, attach.Reader); err != nil {
-		return "", fmt.Errorf("exec read: %w", err)
+	wait := func() (string, error) {
+		var stdout, stderr bytes.Buffer
+		if _, err := stdcopy.StdCopy(&stdout, &stderr, attach.Reader); err != nil {
+			return "", fmt.Errorf("exec read: %w", err)
+		}
+
+		inspect, err := m.docker.ExecInspect(ctx, execResp.ID, client.ExecInspectOptions{})
+		if err != nil {
+			return "", fmt.Errorf("exec inspect: %w", err)
+		}
+
+		output := stdout.String() + stderr.String()
+		if inspect.ExitCode != 0 {
+			return output, fmt.Errorf("exit code %d: %s", inspect.ExitCode, output)
+		}
+		return output, nil
+	}
+	abort := func() {
+		slog.Warn("killing exec", "agent", agentID, "cmd", strings.Join(cmd, " "), "error", ctx.Err())
+		kctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := m.killExec(kctx, info.ID, pidFile); err != nil {
+			slog.Warn("failed to kill exec", "agent", agentID, "error", err)
+		}
+		// Unblocks the read if the process outlived the kill.
+		attach.Close()
+	}
+	output, err := awaitExec(ctx, wait, abort)
+	if errors.Is(err, ErrExecTimeout) {
+		return output, fmt.Errorf("%w after %s", err, timeout)
+	}
+	return output, err
+}
+
+// killExec kills the command whose pid an Exec wrapper recorded in pidFile.
+func (m *Manager) killExec(ctx context.Context, containerID, pidFile string) error {
+	resp, err := m.docker.ExecCreate(ctx, containerID, client.ExecCreateOptions{
+		Cmd: []string{"sh", "-c", `kill -KILL "$(cat "$0")"; rm -f "$0"`, pidFile},
+	})
+	if err != nil {
+		return fmt.Errorf("exec create: %w", err)
+	}
+	if _, err := m.docker.ExecStart(ctx, resp.ID, client.ExecStartOptions{}); err != nil {
+		return fmt.Errorf("exec start: %w", err)
+	}
+	return nil
+}
+
+// awaitExec returns wait's result, unless ctx is done first: then it calls
+// abort, which must make wait return, and reports ErrExecTimeout for a
+// passed deadline or ctx's error otherwise, leaving wait to finish on its
+// own.
+func awaitExec(ctx context.Context, wait func() (string, error), abort func()) (string, error) {
+	type result struct {
+		output string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := wait()
+		done <- result{output, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-ctx.Done():
+		abort()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", ErrExecTimeout
+		}
+		return "", ctx.Err()
+	}
+}
+
+// Logs returns the last tail lines of a running agent container's combined
+// stdout/stderr. tail <= 0 returns the full log.
+func (m *Manager) Logs(ctx context.Context, agentID string, tail int) (string, error) {
+	m.mu.RLock()
+	info, ok := m.active[agentID]
+	m.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("agent %s is not running", agentID)
 	}
 
-	inspect, err := m.docker.ExecInspect(ctx, execResp.ID, client.ExecInspectOptions{})
+	opts := client.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Timestamps: true}
+	if tail > 0 {
+		opts.Tail = fmt.Sprintf("%d", tail)
+	}
+	rc, err := m.docker.ContainerLogs(ctx, info.ID, opts)
 	if err != nil {
-		return "", fmt.Errorf("exec inspect: %w", err)
+		return "", fmt.Errorf("container logs: %w", err)
 	}
+	defer func() { _ = rc.Close() }()
 
-	output := stdout.String() + stderr.String()
-	if inspect.ExitCode != 0 {
-		return output, fmt.Errorf("exit code %d: %s", inspect.ExitCode, output)
+	// Both streams go to the same buffer to preserve line ordering.
+	var out bytes.Buffer
+	if _, err := stdcopy.StdCopy(&out, &out, rc); err != nil {
+		return "", fmt.Errorf("read logs: %w", err)
 	}
-	return output, nil
+	return out.String(), nil
 }
 
 func (m *Manager) ActiveCount() int {
@@ -445,12 +695,20 @@ func (m *Manager) ActiveCount() int {
 }
 
 func (m *Manager) CleanupStale(ctx context.Context) error {
+	_, err := m.CleanupStaleAgents(ctx, "")
+	return err
+}
+
+// CleanupStaleAgents removes managed containers the manager isn't running
+// whose agent id starts with prefix ("" = any), returning how many it
+// removed.
+func (m *Manager) CleanupStaleAgents(ctx context.Context, prefix string) (int, error) {
 	resp, err := m.docker.ContainerList(ctx, client.ContainerListOptions{
 		All:     true,
 		Filters: make(client.Filters).Add("label", labelPrefix+".managed=true"),
 	})
 	if err != nil {
-		return fmt.Errorf("list containers: %w", err)
+		return 0, fmt.Errorf("list containers: %w", err)
 	}
 
 	m.mu.RLock()
@@ -460,72 +718,186 @@ func (m *Manager) CleanupStale(ctx context.Context) error {
 	}
 	m.mu.RUnlock()
 
+	removed := 0
 	for _, c := range resp.Items {
-		if !activeIDs[c.ID] {
-			slog.Info("cleaning up stale container", "container", c.ID[:12])
-			_, _ = m.docker.ContainerRemove(ctx, c.ID, client.ContainerRemoveOptions{Force: true})
+		if activeIDs[c.ID] || !strings.HasPrefix(c.Labels[labelPrefix+".agent"], prefix) {
+			continue
+		}
+		slog.Info("cleaning up stale container", "container", c.ID[:12])
+		if _, err := m.docker.ContainerRemove(ctx, c.ID, client.ContainerRemoveOptions{Force: true}); err == nil {
+			removed++
 		}
 	}
-	return nil
+	return removed, nil
 }
 
 func (m *Manager) BuildImage(ctx context.Context) error {
 	return BuildAgentImage(ctx, m.docker, m.cfg.Image)
 }
 
-// ReadVolumeFile reads a file from a Docker named volume by creating a
-// temporary container, copying the file out, and removing the container.
+// ReadVolumeFile reads a file from a Docker named volume, copying it out
+// of the volume's helper container.
 func (m *Manager) ReadVolumeFile(ctx context.Context, workspace, filePath, image string) (string, error) {
-	volName := fmt.Sprintf("praktor-wk-%s", sanitizeVolumeName(workspace))
-	containerName := fmt.Sprintf("praktor-vol-tmp-%s-%d", sanitizeVolumeName(workspace), time.Now().UnixNano())
+	volName := fmt.Sprintf("praktor-wk-%s", SanitizeVolumeName(workspace))
+
+	var data []byte
+	err := m.volumes.with(ctx, volName, image, func(id string) error {
+		srcPath := path.Join("/vol", filePath)
+		copyResp, err := m.docker.CopyFromContainer(ctx, id, client.CopyFromContainerOptions{SourcePath: srcPath})
+		if err != nil {
+			return fmt.Errorf("copy from volume: %w", err)
+		}
+		defer func() { _ = copyResp.Content.Close() }()
+
+		tr := tar.NewReader(copyResp.Content)
+		if _, err := tr.Next(); err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+		if data, err = io.ReadAll(tr); err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		return nil
+	})
+	return string(data), err
+}
+
+// VolumeUsage returns the disk space used by a workspace volume, in bytes.
+// It runs `du` in a temporary container with the volume mounted read-only,
+// so it takes a few seconds on large volumes; callers should cache it.
+func (m *Manager) VolumeUsage(ctx context.Context, workspace, image string) (int64, error) {
+	volName := fmt.Sprintf("praktor-wk-%s", SanitizeVolumeName(workspace))
+	containerName := fmt.Sprintf("praktor-vol-tmp-%s-%d", SanitizeVolumeName(workspace), time.Now().UnixNano())
 
 	resp, err := m.docker.ContainerCreate(ctx, client.ContainerCreateOptions{
-		Config:     &dockercontainer.Config{Image: image, Entrypoint: []string{"true"}},
-		HostConfig: &dockercontainer.HostConfig{Binds: []string{volName + ":/vol"}},
+		// -k rather than -b: busybox du only reports blocks
+		Config:     &dockercontainer.Config{Image: image, Entrypoint: []string{"du", "-sk", "/vol"}},
+		HostConfig: &dockercontainer.HostConfig{Binds: []string{volName + ":/vol:ro"}},
 		Name:       containerName,
 	})
 	if err != nil {
-		return "", fmt.Errorf("create temp container: %w", err)
+		return 0, fmt.Errorf("create temp container: %w", err)
 	}
 	defer func() {
 		_, _ = m.docker.ContainerRemove(ctx, resp.ID, client.ContainerRemoveOptions{Force: true})
 	}()
 
-	srcPath := path.Join("/vol", filePath)
-	copyResp, err := m.docker.CopyFromContainer(ctx, resp.ID, client.CopyFromContainerOptions{SourcePath: srcPath})
+	if _, err := m.docker.ContainerStart(ctx, resp.ID, client.ContainerStartOptions{}); err != nil {
+		return 0, fmt.Errorf("start temp container: %w", err)
+	}
+	wait := m.docker.ContainerWait(ctx, resp.ID, client.ContainerWaitOptions{})
+	select {
+	case res := <-wait.Result:
+		if res.StatusCode != 0 {
+			return 0, fmt.Errorf("du exited with code %d", res.StatusCode)
+		}
+	case err := <-wait.Error:
+		return 0, fmt.Errorf("wait for du: %w", err)
+	}
+
+	rc, err := m.docker.ContainerLogs(ctx, resp.ID, client.ContainerLogsOptions{ShowStdout: true})
 	if err != nil {
-		return "", fmt.Errorf("copy from volume: %w", err)
+		return 0, fmt.Errorf("container logs: %w", err)
+	}
+	defer func() { _ = rc.Close() }()
+	var out bytes.Buffer
+	if _, err := stdcopy.StdCopy(&out, io.Discard, rc); err != nil {
+		return 0, fmt.Errorf("read logs: %w", err)
 	}
-	defer func() { _ = copyResp.Content.Close() }()
+	return parseDuOutput(out.String())
+}
+
+// VolumeEmpty reports whether a workspace volume holds no files at all. A
+// volume that doesn't exist yet is created empty by the helper container.
+func (m *Manager) VolumeEmpty(ctx context.Context, workspace, image string) (bool, error) {
+	volName := fmt.Sprintf("praktor-wk-%s", SanitizeVolumeName(workspace))
 
-	tr := tar.NewReader(copyResp.Content)
-	if _, err := tr.Next(); err != nil {
-		return "", fmt.Errorf("read tar: %w", err)
+	empty := true
+	err := m.volumes.with(ctx, volName, image, func(id string) error {
+		copyResp, err := m.docker.CopyFromContainer(ctx, id, client.CopyFromContainerOptions{SourcePath: "/vol/"})
+		if err != nil {
+			return fmt.Errorf("copy from volume: %w", err)
+		}
+		defer func() { _ = copyResp.Content.Close() }()
+
+		// The first entry is /vol itself; any other means there are files.
+		tr := tar.NewReader(copyResp.Content)
+		for range 2 {
+			if _, err := tr.Next(); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("read tar: %w", err)
+			}
+		}
+		empty = false
+		return nil
+	})
+	return empty, err
+}
+
+// parseDuOutput parses the output of `du -sk <path>` into bytes.
+func parseDuOutput(out string) (int64, error) {
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return 0, errors.New("empty du output")
 	}
-	data, err := io.ReadAll(tr)
-	if err != nil {
-		return "", fmt.Errorf("read file: %w", err)
+	kb, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || kb < 0 {
+		return 0, fmt.Errorf("unexpected du output %q", strings.TrimSpace(out))
 	}
-	return string(data), nil
+	return kb * 1024, nil
 }
 
-// WriteVolumeFile writes a file into a Docker named volume by creating a
-// temporary container, copying the file in, and removing the container.
-func (m *Manager) WriteVolumeFile(ctx context.Context, workspace, filePath, content, image string) error {
-	volName := fmt.Sprintf("praktor-wk-%s", sanitizeVolumeName(workspace))
-	containerName := fmt.Sprintf("praktor-vol-tmp-%s-%d", sanitizeVolumeName(workspace), time.Now().UnixNano())
+// ErrFileTooLarge is returned by ReadVolumeBytes when the file exceeds the
+// caller's size limit. The size is checked against the tar header, before
+// any file data is read into memory.
+var ErrFileTooLarge = errors.New("file too large")
 
-	resp, err := m.docker.ContainerCreate(ctx, client.ContainerCreateOptions{
-		Config:     &dockercontainer.Config{Image: image, Entrypoint: []string{"true"}},
-		HostConfig: &dockercontainer.HostConfig{Binds: []string{volName + ":/vol"}},
-		Name:       containerName,
+// ReadVolumeBytes reads a binary file from a Docker named volume. Same
+// helper-container pattern as ReadVolumeFile, but through a read-only mount,
+// and rejects paths that escape the volume root and files larger than
+// maxSize bytes (0 = unlimited).
+func (m *Manager) ReadVolumeBytes(ctx context.Context, workspace, filePath, image string, maxSize int64) ([]byte, error) {
+	srcPath := path.Join("/vol", filePath)
+	if srcPath == "/vol" || !strings.HasPrefix(srcPath, "/vol/") {
+		return nil, fmt.Errorf("invalid file path %q: escapes volume root", filePath)
+	}
+
+	volName := fmt.Sprintf("praktor-wk-%s", SanitizeVolumeName(workspace))
+
+	var data []byte
+	err := m.volumes.withReadOnly(ctx, volName, image, func(id string) error {
+		copyResp, err := m.docker.CopyFromContainer(ctx, id, client.CopyFromContainerOptions{SourcePath: srcPath})
+		if err != nil {
+			return fmt.Errorf("copy from volume: %w", err)
+		}
+		defer func() { _ = copyResp.Content.Close() }()
+
+		tr := tar.NewReader(copyResp.Content)
+		hdr, err := tr.Next()
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return fmt.Errorf("%s is not a regular file", filePath)
+		}
+		if maxSize > 0 && hdr.Size > maxSize {
+			return fmt.Errorf("%w: %d bytes (max %d)", ErrFileTooLarge, hdr.Size, maxSize)
+		}
+		if data, err = io.ReadAll(tr); err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("create temp container: %w", err)
+		return nil, err
 	}
-	defer func() {
-		_, _ = m.docker.ContainerRemove(ctx, resp.ID, client.ContainerRemoveOptions{Force: true})
-	}()
+	return data, nil
+}
+
+// WriteVolumeFile writes a file into a Docker named volume, copying it into
+// the volume's helper container.
+func (m *Manager) WriteVolumeFile(ctx context.Context, workspace, filePath, content, image string) error {
+	volName := fmt.Sprintf("praktor-wk-%s", SanitizeVolumeName(workspace))
 
 	// Build tar archive with the file
 	var buf bytes.Buffer
@@ -545,33 +917,28 @@ func (m *Manager) WriteVolumeFile(ctx context.Context, workspace, filePath, cont
 	}
 
 	dstDir := path.Join("/vol", path.Dir(filePath))
-	if _, err := m.docker.CopyToContainer(ctx, resp.ID, client.CopyToContainerOptions{
-		DestinationPath: dstDir,
-		Content:         &buf,
-	}); err != nil {
-		return fmt.Errorf("copy to volume: %w", err)
-	}
-	return nil
+	return m.volumes.with(ctx, volName, image, func(id string) error {
+		if _, err := m.docker.CopyToContainer(ctx, id, client.CopyToContainerOptions{
+			DestinationPath: dstDir,
+			Content:         bytes.NewReader(buf.Bytes()),
+		}); err != nil {
+			return fmt.Errorf("copy to volume: %w", err)
+		}
+		return nil
+	})
 }
 
 // WriteVolumeBytes writes binary data into a Docker named volume. Same
-// temp-container pattern as WriteVolumeFile but accepts []byte and creates
+// helper-container pattern as WriteVolumeFile but accepts []byte and creates
 // parent directories with correct ownership (uid/gid 10321).
 func (m *Manager) WriteVolumeBytes(ctx context.Context, workspace, filePath string, data []byte, image string) error {
-	volName := fmt.Sprintf("praktor-wk-%s", sanitizeVolumeName(workspace))
-	containerName := fmt.Sprintf("praktor-vol-tmp-%s-%d", sanitizeVolumeName(workspace), time.Now().UnixNano())
+	return m.WriteVolumeBytesMode(ctx, workspace, filePath, data, 0o644, image)
+}
 
-	resp, err := m.docker.ContainerCreate(ctx, client.ContainerCreateOptions{
-		Config:     &dockercontainer.Config{Image: image, Entrypoint: []string{"true"}},
-		HostConfig: &dockercontainer.HostConfig{Binds: []string{volName + ":/vol"}},
-		Name:       containerName,
-	})
-	if err != nil {
-		return fmt.Errorf("create temp container: %w", err)
-	}
-	defer func() {
-		_, _ = m.docker.ContainerRemove(ctx, resp.ID, client.ContainerRemoveOptions{Force: true})
-	}()
+// WriteVolumeBytesMode is WriteVolumeBytes with the file's permission bits,
+// e.g. to keep a copied script executable.
+func (m *Manager) WriteVolumeBytesMode(ctx context.Context, workspace, filePath string, data []byte, mode fs.FileMode, image string) error {
+	volName := fmt.Sprintf("praktor-wk-%s", SanitizeVolumeName(workspace))
 
 	// Build tar archive with directory entries and the file
 	var buf bytes.Buffer
@@ -602,7 +969,7 @@ func (m *Manager) WriteVolumeBytes(ctx context.Context, workspace, filePath stri
 
 	if err := tw.WriteHeader(&tar.Header{
 		Name: targetPath,
-		Mode: 0o644,
+		Mode: int64(mode.Perm()),
 		Size: int64(len(data)),
 		Uid:  10321,
 		Gid:  10321,
@@ -616,11 +983,13 @@ func (m *Manager) WriteVolumeBytes(ctx context.Context, workspace, filePath stri
 		return fmt.Errorf("close tar: %w", err)
 	}
 
-	if _, err := m.docker.CopyToContainer(ctx, resp.ID, client.CopyToContainerOptions{
-		DestinationPath: "/",
-		Content:         &buf,
-	}); err != nil {
-		return fmt.Errorf("copy to volume: %w", err)
-	}
-	return nil
+	return m.volumes.with(ctx, volName, image, func(id string) error {
+		if _, err := m.docker.CopyToContainer(ctx, id, client.CopyToContainerOptions{
+			DestinationPath: "/",
+			Content:         bytes.NewReader(buf.Bytes()),
+		}); err != nil {
+			return fmt.Errorf("copy to volume: %w", err)
+		}
+		return nil
+	})
 }
@@ -0,0 +1,147 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+func TestParseDuOutput(t *testing.T) {
+	tests := []struct {
+		out     string
+		want    int64
+		wantErr bool
+	}{
+		{out: "1024\t/vol\n", want: 1024 * 1024},
+		{out: "0\t/vol", want: 0},
+		{out: "  52 /vol\n", want: 52 * 1024},
+		{out: "", wantErr: true},
+		{out: "du: /vol: No such file or directory\n", wantErr: true},
+		{out: "-4\t/vol", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuOutput(tt.out)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseDuOutput(%q) = %d, want error", tt.out, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseDuOutput(%q) = %d, %v; want %d", tt.out, got, err, tt.want)
+		}
+	}
+}
+
+func TestStartAgentMaxContainers(t *testing.T) {
+	m := &Manager{
+		cfg:    config.DefaultsConfig{MaxRunning: 1},
+		active: map[string]*ContainerInfo{"alpha": {AgentID: "alpha"}},
+	}
+	if _, err := m.StartAgent(context.Background(), AgentOpts{AgentID: "beta"}); !errors.Is(err, ErrMaxContainers) {
+		t.Errorf("got %v, want ErrMaxContainers", err)
+	}
+}
+
+func TestStartAgentWeightedAdmission(t *testing.T) {
+	m := &Manager{
+		cfg: config.DefaultsConfig{MaxRunning: 4},
+		active: map[string]*ContainerInfo{
+			"heavy": {AgentID: "heavy", Weight: 2},
+			"light": {AgentID: "light"}, // started before weights: counts 1
+		},
+	}
+	if _, err := m.StartAgent(context.Background(), AgentOpts{AgentID: "big", Weight: 2}); !errors.Is(err, ErrMaxContainers) {
+		t.Errorf("heavy agent over budget: got %v, want ErrMaxContainers", err)
+	}
+	if err := m.admitLocked("small", 1); err != nil {
+		t.Errorf("light agent within budget rejected: %v", err)
+	}
+
+	m.active["small"] = &ContainerInfo{AgentID: "small", Weight: 1}
+	if err := m.admitLocked("another", 1); !errors.Is(err, ErrMaxContainers) {
+		t.Errorf("full budget: got %v, want ErrMaxContainers", err)
+	}
+}
+
+func TestStartAgentWaitsForStop(t *testing.T) {
+	done := make(chan struct{})
+	m := &Manager{
+		cfg:      config.DefaultsConfig{MaxRunning: 0}, // the start fails admission once it runs
+		active:   map[string]*ContainerInfo{},
+		stopping: map[string]chan struct{}{"alpha": done},
+	}
+	started := make(chan error, 1)
+	go func() {
+		_, err := m.StartAgent(context.Background(), AgentOpts{AgentID: "alpha"})
+		started <- err
+	}()
+	select {
+	case err := <-started:
+		t.Fatalf("start ran during the stop: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	m.mu.Lock()
+	delete(m.stopping, "alpha")
+	m.mu.Unlock()
+	close(done)
+	select {
+	case err := <-started:
+		if !errors.Is(err, ErrMaxContainers) {
+			t.Errorf("got %v, want the start to proceed to admission", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("start still waiting after the stop finished")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.stopping["beta"] = make(chan struct{})
+	if _, err := m.StartAgent(ctx, AgentOpts{AgentID: "beta"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled start: err = %v, want context.Canceled", err)
+	}
+}
+
+func TestAwaitExecTimeout(t *testing.T) {
+	// wait ignores ctx like a Docker attach that never sees the
+	// cancellation; only abort (killing the process) makes it return.
+	killed := make(chan struct{})
+	finished := make(chan struct{})
+	wait := func() (string, error) {
+		defer close(finished)
+		<-killed
+		return "", errors.New("exec read: use of closed connection")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := awaitExec(ctx, wait, func() { close(killed) })
+	if !errors.Is(err, ErrExecTimeout) {
+		t.Fatalf("err = %v, want ErrExecTimeout", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("returned after %s, want about the 20ms timeout", d)
+	}
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Error("wait goroutine still blocked after abort")
+	}
+
+	out, err := awaitExec(context.Background(), func() (string, error) { return "done", nil }, func() { t.Error("abort called for a finished exec") })
+	if out != "done" || err != nil {
+		t.Errorf("finished exec = %q, %v", out, err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	aborted := make(chan struct{})
+	if _, err := awaitExec(ctx, func() (string, error) { <-aborted; return "", nil }, func() { close(aborted) }); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled exec: err = %v, want context.Canceled", err)
+	}
+}
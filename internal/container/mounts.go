@@ -12,11 +12,15 @@ type Mount struct {
 }
 
 func buildMounts(opts AgentOpts) []string {
-	workspace := sanitizeVolumeName(opts.Workspace)
+	workspace := SanitizeVolumeName(opts.Workspace)
 	var binds []string
 
 	// Agent-specific workspace (named volume)
-	binds = append(binds, fmt.Sprintf("praktor-wk-%s:/workspace/agent", workspace))
+	workspaceVolume := opts.WorkspaceVolume
+	if workspaceVolume == "" {
+		workspaceVolume = "praktor-wk-" + workspace
+	}
+	binds = append(binds, workspaceVolume+":/workspace/agent")
 
 	// Global shared instructions (named volume, read-only)
 	binds = append(binds, "praktor-global:/workspace/global:ro")
@@ -41,8 +45,10 @@ func buildMounts(opts AgentOpts) []string {
 	return binds
 }
 
-// sanitizeVolumeName replaces characters not allowed in Docker volume names.
-func sanitizeVolumeName(s string) string {
+// SanitizeVolumeName replaces characters not allowed in Docker volume names.
+// Distinct workspaces can map to the same name; the registry rejects such
+// collisions at sync time.
+func SanitizeVolumeName(s string) string {
 	return strings.Map(func(r rune) rune {
 		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
 			return r
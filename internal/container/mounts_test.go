@@ -0,0 +1,19 @@
+package container
+
+import "testing"
+
+func TestBuildMountsWorkspaceVolume(t *testing.T) {
+	binds := buildMounts(AgentOpts{Workspace: "coder"})
+	if binds[0] != "praktor-wk-coder:/workspace/agent" {
+		t.Errorf("expected default workspace volume, got %s", binds[0])
+	}
+
+	binds = buildMounts(AgentOpts{Workspace: "coder", WorkspaceVolume: "praktor-swarm-abc-reviewer"})
+	if binds[0] != "praktor-swarm-abc-reviewer:/workspace/agent" {
+		t.Errorf("expected overridden workspace volume, got %s", binds[0])
+	}
+	// Home volume still follows the workspace.
+	if binds[2] != "praktor-home-coder:/home/praktor" {
+		t.Errorf("expected home volume to follow workspace, got %s", binds[2])
+	}
+}
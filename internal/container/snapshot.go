@@ -0,0 +1,259 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/moby/moby/api/pkg/stdcopy"
+	dockercontainer "github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+)
+
+// ErrSnapshotMismatch is returned when a snapshot isn't a single-volume
+// snapshot of the agent it is being restored to.
+var ErrSnapshotMismatch = errors.New("snapshot does not match agent")
+
+// restoreStage is where a snapshot is extracted inside the workspace
+// volume. The current files are only replaced once it is complete.
+const restoreStage = "/vol/.praktor-restore"
+
+// SnapshotWorkspace writes a snapshot of the agent's workspace volume to w.
+func (m *Manager) SnapshotWorkspace(ctx context.Context, w io.Writer, agentID, workspace, image string) error {
+	volName := fmt.Sprintf("praktor-wk-%s", SanitizeVolumeName(workspace))
+	return WriteSnapshot(ctx, m.docker, w, agentID, volName, image)
+}
+
+// WriteSnapshot writes a zstd-compressed tar of volume volName to w in the
+// backup format: a manifest naming agentID, then every file under a
+// volName/ prefix. It can be restored with RestoreWorkspace or `praktor
+// restore`.
+func WriteSnapshot(ctx context.Context, docker *client.Client, w io.Writer, agentID, volName, image string) error {
+	return readVolume(ctx, docker, volName, image, func(src io.Reader) error {
+		return writeSnapshot(w, agentID, volName, src)
+	})
+}
+
+// RestoreWorkspace replaces the contents of the agent's workspace volume
+// with a snapshot read from r. The snapshot is extracted next to the
+// current files, which are swapped for it only once all of it has been
+// read, so a truncated or corrupt snapshot leaves the workspace as it was.
+// Files created after the snapshot are removed. The agent should not be
+// running.
+func (m *Manager) RestoreWorkspace(ctx context.Context, r io.Reader, agentID, workspace, image string) error {
+	// Check the manifest before anything is written to the volume.
+	snap, err := openSnapshot(r, agentID)
+	if err != nil {
+		return err
+	}
+	defer snap.close()
+
+	volName := fmt.Sprintf("praktor-wk-%s", SanitizeVolumeName(workspace))
+
+	// Stream into `tar -xf -` like `praktor restore`, rather than
+	// CopyToContainer, whose validator rejects symlinks leaving the volume.
+	extractErr := m.runRestoreHelper(ctx, volName, image,
+		fmt.Sprintf(`rm -rf %[1]s && mkdir %[1]s && tar -xf - -C %[1]s`, restoreStage), snap.copyTo)
+	if extractErr != nil {
+		if err := m.runRestoreHelper(ctx, volName, image, "rm -rf "+restoreStage, nil); err != nil {
+			slog.Warn("remove restore staging directory failed", "volume", volName, "error", err)
+		}
+		return extractErr
+	}
+
+	swap := fmt.Sprintf(`find /vol -mindepth 1 -maxdepth 1 ! -path %[1]s -exec rm -rf {} + && `+
+		`find %[1]s -mindepth 1 -maxdepth 1 -exec mv {} /vol/ \; && rmdir %[1]s`, restoreStage)
+	if err := m.runRestoreHelper(ctx, volName, image, swap, nil); err != nil {
+		return fmt.Errorf("replace workspace: %w", err)
+	}
+	return nil
+}
+
+// runRestoreHelper runs script as root in a temporary container of image
+// with volName mounted at /vol. With feed set, the container's stdin is
+// attached and feed writes to it; an error from feed fails the run even if
+// the script exits cleanly.
+func (m *Manager) runRestoreHelper(ctx context.Context, volName, image, script string, feed func(io.Writer) error) error {
+	containerName := fmt.Sprintf("praktor-restore-%d", time.Now().UnixNano())
+	cfg := &dockercontainer.Config{
+		Image:        image,
+		Entrypoint:   []string{"sh", "-c", script},
+		User:         "0",
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	if feed != nil {
+		cfg.OpenStdin, cfg.StdinOnce, cfg.AttachStdin = true, true, true
+	}
+	resp, err := m.docker.ContainerCreate(ctx, client.ContainerCreateOptions{
+		Config:     cfg,
+		HostConfig: &dockercontainer.HostConfig{Binds: []string{volName + ":/vol"}},
+		Name:       containerName,
+	})
+	if err != nil {
+		return fmt.Errorf("create temp container: %w", err)
+	}
+	defer func() {
+		_, _ = m.docker.ContainerRemove(ctx, resp.ID, client.ContainerRemoveOptions{Force: true})
+	}()
+
+	attach, err := m.docker.ContainerAttach(ctx, resp.ID, client.ContainerAttachOptions{
+		Stream: true, Stdin: feed != nil, Stdout: true, Stderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("attach temp container: %w", err)
+	}
+	defer attach.Close()
+
+	if _, err := m.docker.ContainerStart(ctx, resp.ID, client.ContainerStartOptions{}); err != nil {
+		return fmt.Errorf("start temp container: %w", err)
+	}
+	wait := m.docker.ContainerWait(ctx, resp.ID, client.ContainerWaitOptions{})
+
+	var stderr bytes.Buffer
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		_, _ = stdcopy.StdCopy(io.Discard, &stderr, attach.Reader)
+	}()
+
+	var feedErr error
+	if feed != nil {
+		feedErr = feed(attach.Conn)
+		if feedErr != nil {
+			// Stop the script before it sees the end of a partial input.
+			_, _ = m.docker.ContainerKill(ctx, resp.ID, client.ContainerKillOptions{})
+		}
+		_ = attach.CloseWrite()
+	}
+
+	var exitErr error
+	select {
+	case res := <-wait.Result:
+		if res.StatusCode != 0 {
+			exitErr = fmt.Errorf("restore exited with code %d", res.StatusCode)
+		}
+	case err := <-wait.Error:
+		exitErr = fmt.Errorf("wait for restore: %w", err)
+	}
+	<-drained
+
+	if feedErr != nil {
+		return feedErr
+	}
+	if exitErr != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", exitErr, msg)
+		}
+		return exitErr
+	}
+	return nil
+}
+
+// writeSnapshot compresses the manifest and the volume tar read from src
+// into w, prefixing every entry with volName.
+func writeSnapshot(w io.Writer, agentID, volName string, src io.Reader) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("create compressor: %w", err)
+	}
+	tw := tar.NewWriter(zw)
+
+	manifest := BackupManifest{CreatedAt: time.Now().UTC(), AgentID: agentID, Volumes: []string{volName}}
+	manifest.Compression.Algorithm = "zstd"
+	manifest.Compression.Level = "default"
+	if err := WriteBackupManifest(tw, manifest); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	if err := copyVolumeEntries(tw, volName, src); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("close tar: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("close compressor: %w", err)
+	}
+	return nil
+}
+
+// snapshotReader reads the volume entries of a snapshot after its manifest.
+type snapshotReader struct {
+	zr     *zstd.Decoder
+	tr     *tar.Reader
+	prefix string // the archived volume's name and a slash
+}
+
+// openSnapshot decompresses a snapshot and checks that its manifest is for
+// agentID and holds a single volume. The snapshot may come from another
+// workspace name of the same agent.
+func openSnapshot(r io.Reader, agentID string) (*snapshotReader, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("create zstd reader: %w", err)
+	}
+	tr := tar.NewReader(zr)
+
+	var manifest BackupManifest
+	hdr, err := tr.Next()
+	switch {
+	case err != nil:
+		err = fmt.Errorf("%w: read manifest: %w", ErrSnapshotMismatch, err)
+	case hdr.Name != BackupManifestName:
+		err = fmt.Errorf("%w: missing manifest", ErrSnapshotMismatch)
+	default:
+		if derr := json.NewDecoder(tr).Decode(&manifest); derr != nil {
+			err = fmt.Errorf("%w: decode manifest: %v", ErrSnapshotMismatch, derr)
+		} else if manifest.AgentID != agentID || len(manifest.Volumes) != 1 {
+			err = fmt.Errorf("%w: snapshot of agent %q with %d volume(s)", ErrSnapshotMismatch, manifest.AgentID, len(manifest.Volumes))
+		}
+	}
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	return &snapshotReader{zr: zr, tr: tr, prefix: manifest.Volumes[0] + "/"}, nil
+}
+
+func (s *snapshotReader) close() { s.zr.Close() }
+
+// copyTo writes the volume's entries, with the volume prefix stripped, as a
+// plain tar to dst.
+func (s *snapshotReader) copyTo(dst io.Writer) error {
+	tw := tar.NewWriter(dst)
+	for {
+		hdr, err := s.tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read tar entry: %w", err)
+		}
+		rel, ok := strings.CutPrefix(strings.TrimLeft(hdr.Name, "./"), s.prefix)
+		if !ok {
+			continue
+		}
+		if rel == "" {
+			rel = "./"
+		}
+		hdr.Name = rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("write tar header: %w", err)
+		}
+		if hdr.Size > 0 {
+			if _, err := io.Copy(tw, s.tr); err != nil {
+				return fmt.Errorf("write tar data: %w", err)
+			}
+		}
+	}
+	return tw.Close()
+}
@@ -0,0 +1,91 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	// A volume as CopyFromContainer returns it for /vol/.
+	files := map[string]string{
+		"./notes.md":          "# notes",
+		"./src/main.go":       "package main",
+		"./.config/settings":  "x=1",
+		"./src/empty.txt":     "",
+		"./src/deeply/nested": "data",
+	}
+	var src bytes.Buffer
+	tw := tar.NewWriter(&src)
+	_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "./", Mode: 0o755})
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Uid: 10321}); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = tw.Write([]byte(content))
+	}
+	_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "./link", Linkname: "../../etc/hosts"})
+	_ = tw.Close()
+
+	var archive bytes.Buffer
+	if err := writeSnapshot(&archive, "coder", "praktor-wk-coder", &src); err != nil {
+		t.Fatal(err)
+	}
+	raw := archive.Bytes()
+
+	snap, err := openSnapshot(bytes.NewReader(raw), "coder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.close()
+	var out bytes.Buffer
+	if err := snap.copyTo(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]string{}
+	var link string
+	tr := tar.NewReader(&out)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			data, _ := io.ReadAll(tr)
+			got["./"+hdr.Name] = string(data)
+			if hdr.Uid != 10321 {
+				t.Errorf("%s: uid = %d, want 10321", hdr.Name, hdr.Uid)
+			}
+		case tar.TypeSymlink:
+			link = hdr.Name + " -> " + hdr.Linkname
+		}
+	}
+	if !maps.Equal(got, files) {
+		t.Errorf("restored files = %v, want %v", got, files)
+	}
+	if link != "link -> ../../etc/hosts" {
+		t.Errorf("symlink = %q", link)
+	}
+
+	// A truncated snapshot fails while streaming, before the restore swaps
+	// the staged files in.
+	if cut, err := openSnapshot(bytes.NewReader(raw[:len(raw)-len(raw)/3]), "coder"); err == nil {
+		if err := cut.copyTo(io.Discard); err == nil {
+			t.Error("copyTo of a truncated snapshot: expected an error")
+		}
+		cut.close()
+	}
+
+	// A snapshot is only restored to the agent it was taken of.
+	if _, err := openSnapshot(bytes.NewReader(raw), "other"); !errors.Is(err, ErrSnapshotMismatch) {
+		t.Errorf("restore to another agent: err = %v, want ErrSnapshotMismatch", err)
+	}
+}
@@ -0,0 +1,91 @@
+package container
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"slices"
+
+	dockercontainer "github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+)
+
+// containerSpec fingerprints everything an agent container is created
+// from, so a stopped container is only restarted when a fresh one would be
+// identical. Env order doesn't matter.
+func containerSpec(cfg *dockercontainer.Config, hostCfg *dockercontainer.HostConfig, files []SecretFile) string {
+	type file struct {
+		Target string
+		Mode   int64
+		Sum    [sha256.Size]byte
+	}
+	spec := struct {
+		Config     dockercontainer.Config
+		HostConfig *dockercontainer.HostConfig
+		Files      []file
+	}{Config: *cfg, HostConfig: hostCfg}
+	spec.Config.Env = slices.Sorted(slices.Values(cfg.Env))
+	for _, f := range files {
+		spec.Files = append(spec.Files, file{f.Target, f.Mode, sha256.Sum256(f.Content)})
+	}
+	data, _ := json.Marshal(spec)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// restartable reports whether a container in state with labels, created
+// from image id containerImage, can be started again for spec instead of
+// being recreated. The spec only names the image tag, so a container is
+// also stale once the tag points to another id than imageID (unknown when
+// empty).
+func restartable(state dockercontainer.ContainerState, labels map[string]string, containerImage, spec, imageID string) bool {
+	switch state {
+	case dockercontainer.StateExited, dockercontainer.StateCreated:
+	default:
+		return false
+	}
+	if imageID != "" && containerImage != imageID {
+		return false
+	}
+	return labels[labelPrefix+".spec"] == spec
+}
+
+// keepsContainers reports whether some stop keeps the container around.
+func (m *Manager) keepsContainers() bool {
+	return m.cfg.StopMode == "stop" || m.cfg.IdleStopMode == "stop"
+}
+
+// restartStopped starts the existing container called name if it is
+// stopped and was created from spec and image id imageID, returning its id.
+func (m *Manager) restartStopped(ctx context.Context, name, spec, imageID string) (string, bool) {
+	res, err := m.docker.ContainerInspect(ctx, name, client.ContainerInspectOptions{})
+	if err != nil || res.Container.State == nil || res.Container.Config == nil {
+		return "", false
+	}
+	c := res.Container
+	if !restartable(c.State.Status, c.Config.Labels, c.Image, spec, imageID) {
+		slog.Info("kept container is stale, recreating", "container", name)
+		return "", false
+	}
+	if _, err := m.docker.ContainerStart(ctx, c.ID, client.ContainerStartOptions{}); err != nil {
+		slog.Warn("failed to restart kept container, recreating", "container", name, "error", err)
+		return "", false
+	}
+	return c.ID, true
+}
+
+// RemoveStopped removes the container kept for agentID, if any.
+func (m *Manager) RemoveStopped(ctx context.Context, agentID string) {
+	m.mu.Lock()
+	info, ok := m.stopped[agentID]
+	delete(m.stopped, agentID)
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+	if _, err := m.docker.ContainerRemove(ctx, info.ID, client.ContainerRemoveOptions{Force: true}); err != nil {
+		slog.Warn("failed to remove kept container", "container", info.ID[:12], "error", err)
+	}
+}
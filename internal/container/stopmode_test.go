@@ -0,0 +1,185 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	dockercontainer "github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+func TestContainerSpec(t *testing.T) {
+	spec := func(env []string, files ...SecretFile) string {
+		cfg := &dockercontainer.Config{Image: "praktor-agent:latest", Env: env, Labels: map[string]string{labelPrefix + ".agent": "coder"}}
+		return containerSpec(cfg, &dockercontainer.HostConfig{Binds: []string{"praktor-wk-coder:/workspace/agent"}}, files)
+	}
+	base := spec([]string{"AGENT_ID=coder", "TOKEN=a"}, SecretFile{Target: "/etc/key", Content: []byte("k1")})
+
+	// Per-agent env comes from a map, so its order must not matter.
+	if got := spec([]string{"TOKEN=a", "AGENT_ID=coder"}, SecretFile{Target: "/etc/key", Content: []byte("k1")}); got != base {
+		t.Error("env order changed the spec")
+	}
+	for name, got := range map[string]string{
+		"env":         spec([]string{"AGENT_ID=coder", "TOKEN=b"}, SecretFile{Target: "/etc/key", Content: []byte("k1")}),
+		"secret file": spec([]string{"AGENT_ID=coder", "TOKEN=a"}, SecretFile{Target: "/etc/key", Content: []byte("k2")}),
+	} {
+		if got == base {
+			t.Errorf("changed %s kept the spec", name)
+		}
+	}
+}
+
+func TestRestartable(t *testing.T) {
+	labels := map[string]string{labelPrefix + ".spec": "abc"}
+	tests := []struct {
+		name    string
+		state   dockercontainer.ContainerState
+		spec    string
+		imageID string
+		want    bool
+	}{
+		{"stopped, same spec", dockercontainer.StateExited, "abc", "sha256:1", true},
+		{"created, same spec", dockercontainer.StateCreated, "abc", "sha256:1", true},
+		{"image id unknown", dockercontainer.StateExited, "abc", "", true},
+		{"stale env", dockercontainer.StateExited, "def", "sha256:1", false},
+		{"tag rebuilt", dockercontainer.StateExited, "abc", "sha256:2", false},
+		{"still running", dockercontainer.StateRunning, "abc", "sha256:1", false},
+		{"dead", dockercontainer.StateDead, "abc", "sha256:1", false},
+	}
+	for _, tt := range tests {
+		if got := restartable(tt.state, labels, "sha256:1", tt.spec, tt.imageID); got != tt.want {
+			t.Errorf("%s: restartable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if restartable(dockercontainer.StateExited, map[string]string{}, "sha256:1", "abc", "sha256:1") {
+		t.Error("container without a spec label must be recreated")
+	}
+}
+
+// fakeDocker answers the Docker API calls StartAgent makes for one agent
+// container and records the create and start calls.
+type fakeDocker struct {
+	mu      sync.Mutex
+	created []string // container ids, in order
+	started []string
+	labels  map[string]string // of the current container
+	state   dockercontainer.ContainerState
+}
+
+var apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+`)
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := apiVersionPrefix.ReplaceAllString(r.URL.Path, "")
+	id := ""
+	if n := len(f.created); n > 0 {
+		id = f.created[n-1]
+	}
+	reply := func(v any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	switch {
+	case path == "/_ping":
+		w.Header().Set("Api-Version", "1.47")
+		_, _ = w.Write([]byte("OK"))
+	case strings.HasPrefix(path, "/images/"):
+		reply(map[string]any{"Id": "sha256:image", "Architecture": runtime.GOARCH})
+	case r.Method == http.MethodPost && path == "/containers/create":
+		var body dockercontainer.CreateRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		id = strings.Repeat(string(rune('a'+len(f.created))), 64)
+		f.created = append(f.created, id)
+		f.labels, f.state = body.Labels, dockercontainer.StateCreated
+		reply(map[string]any{"Id": id})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/start") && strings.HasPrefix(path, "/containers/"):
+		f.started = append(f.started, strings.Split(path, "/")[2])
+		f.state = dockercontainer.StateRunning
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/exec"):
+		reply(map[string]any{"Id": "exec"})
+	case strings.HasPrefix(path, "/exec/"):
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/json") && id != "":
+		reply(map[string]any{
+			"Id": id, "Image": "sha256:image",
+			"State":  map[string]any{"Status": f.state},
+			"Config": map[string]any{"Labels": f.labels},
+		})
+	case r.Method == http.MethodDelete:
+		f.state = ""
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+	}
+}
+
+func TestStartAgentRestartsKeptContainer(t *testing.T) {
+	fake := &fakeDocker{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	docker, err := client.New(client.WithHost("tcp://"+srv.Listener.Addr().String()), client.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{
+		docker:      docker,
+		cfg:         config.DefaultsConfig{Image: "praktor-agent:latest", MaxRunning: 5, StopMode: "stop"},
+		active:      map[string]*ContainerInfo{},
+		stopped:     map[string]*ContainerInfo{},
+		stopping:    map[string]chan struct{}{},
+		networkName: networkName,
+		hostArch:    runtime.GOARCH,
+	}
+	ctx := context.Background()
+	opts := AgentOpts{AgentID: "alpha", Workspace: "alpha", Env: map[string]string{"MODE": "a"}}
+
+	// keep simulates a stop-mode stop of the running container.
+	keep := func() {
+		m.mu.Lock()
+		m.stopped["alpha"] = m.active["alpha"]
+		delete(m.active, "alpha")
+		m.mu.Unlock()
+		fake.mu.Lock()
+		fake.state = dockercontainer.StateExited
+		fake.mu.Unlock()
+	}
+
+	first, err := m.StartAgent(ctx, opts)
+	if err != nil {
+		t.Fatalf("first start: %v", err)
+	}
+	keep()
+
+	// Same spec: the kept container is started again, not recreated.
+	again, err := m.StartAgent(ctx, opts)
+	if err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if again.ID != first.ID || len(fake.created) != 1 || len(fake.started) != 2 || fake.started[1] != first.ID {
+		t.Errorf("restart: id %s, created %v, started %v; want %s started twice and created once", again.ID, fake.created, fake.started, first.ID)
+	}
+	if _, kept := m.stopped["alpha"]; kept || m.active["alpha"] != again {
+		t.Error("restarted container not moved back to active")
+	}
+
+	// Changed env: the kept container is stale and replaced.
+	keep()
+	opts.Env = map[string]string{"MODE": "b"}
+	fresh, err := m.StartAgent(ctx, opts)
+	if err != nil {
+		t.Fatalf("start with new env: %v", err)
+	}
+	if fresh.ID == first.ID || len(fake.created) != 2 {
+		t.Errorf("new env: id %s, created %v; want a second container", fresh.ID, fake.created)
+	}
+}
@@ -0,0 +1,226 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	dockercontainer "github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+)
+
+// Volume helpers are long-lived containers, one per volume, that the
+// ReadVolume*/WriteVolume* calls copy files through instead of creating a
+// container per call. A helper is created on first use, removed after
+// volumeHelperIdle without calls, and recreated if it has disappeared.
+// Reads that must not be able to change the volume go through a second
+// helper that mounts it read-only.
+const (
+	volumeHelperIdle     = 5 * time.Minute
+	volumeHelperAttempts = 3                      // container creates tried per call
+	volumeHelperBackoff  = 200 * time.Millisecond // doubled after each failed create
+)
+
+type volumeHelper struct {
+	mu       sync.Mutex // serializes calls for the volume
+	id       string     // container id; "" when there is no helper
+	roID     string     // read-only helper's container id
+	lastUsed time.Time
+	timer    *time.Timer // reaps the helper once idle
+}
+
+type volumeHelpers struct {
+	mu      sync.Mutex
+	helpers map[string]*volumeHelper // volume name → helper
+	idle    time.Duration
+	backoff time.Duration
+
+	create func(ctx context.Context, volName, image string, readOnly bool) (string, error)
+	alive  func(ctx context.Context, id string) bool
+	remove func(ctx context.Context, id string)
+}
+
+func newVolumeHelpers(m *Manager) *volumeHelpers {
+	return &volumeHelpers{
+		helpers: make(map[string]*volumeHelper),
+		idle:    volumeHelperIdle,
+		backoff: volumeHelperBackoff,
+		create:  m.createVolumeHelper,
+		alive:   m.volumeHelperAlive,
+		remove: func(ctx context.Context, id string) {
+			_, _ = m.docker.ContainerRemove(ctx, id, client.ContainerRemoveOptions{Force: true})
+		},
+	}
+}
+
+func (p *volumeHelpers) get(volName string) *volumeHelper {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h, ok := p.helpers[volName]
+	if !ok {
+		h = &volumeHelper{}
+		p.helpers[volName] = h
+	}
+	return h
+}
+
+// with runs fn with the id of volName's helper container, mounting the
+// volume at /vol. Calls for the same volume run one at a time. If fn fails
+// because the helper is gone, a new one is created and fn runs once more.
+func (p *volumeHelpers) with(ctx context.Context, volName, image string, fn func(id string) error) error {
+	return p.run(ctx, volName, image, false, fn)
+}
+
+// withReadOnly is with for a helper that mounts the volume read-only.
+func (p *volumeHelpers) withReadOnly(ctx context.Context, volName, image string, fn func(id string) error) error {
+	return p.run(ctx, volName, image, true, fn)
+}
+
+func (p *volumeHelpers) run(ctx context.Context, volName, image string, readOnly bool, fn func(id string) error) error {
+	h := p.get(volName)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	defer p.touch(h)
+
+	id := &h.id
+	if readOnly {
+		id = &h.roID
+	}
+	for retried := false; ; retried = true {
+		if *id == "" {
+			created, err := p.createWithRetry(ctx, volName, image, readOnly)
+			if err != nil {
+				return err
+			}
+			*id = created
+		}
+		err := fn(*id)
+		if err == nil || retried || p.alive(ctx, *id) {
+			return err
+		}
+		slog.Warn("volume helper gone, recreating", "volume", volName, "error", err)
+		p.remove(ctx, *id)
+		*id = ""
+	}
+}
+
+// createWithRetry creates a helper, retrying transient Docker failures with
+// a doubling backoff.
+func (p *volumeHelpers) createWithRetry(ctx context.Context, volName, image string, readOnly bool) (string, error) {
+	var err error
+	for attempt := range volumeHelperAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(p.backoff << (attempt - 1)):
+			}
+		}
+		var id string
+		if id, err = p.create(ctx, volName, image, readOnly); err == nil {
+			return id, nil
+		}
+		slog.Warn("failed to create volume helper", "volume", volName, "attempt", attempt+1, "error", err)
+	}
+	return "", fmt.Errorf("create volume helper: %w", err)
+}
+
+// touch marks h used and (re)arms its idle timer. h.mu is held.
+func (p *volumeHelpers) touch(h *volumeHelper) {
+	h.lastUsed = time.Now()
+	if h.timer == nil {
+		h.timer = time.AfterFunc(p.idle, func() { p.reap(h) })
+	} else {
+		h.timer.Reset(p.idle)
+	}
+}
+
+func (p *volumeHelpers) reap(h *volumeHelper) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if time.Since(h.lastUsed) < p.idle {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	p.removeLocked(ctx, h)
+}
+
+// removeLocked removes h's helper containers. h.mu is held.
+func (p *volumeHelpers) removeLocked(ctx context.Context, h *volumeHelper) {
+	for _, id := range []*string{&h.id, &h.roID} {
+		if *id != "" {
+			p.remove(ctx, *id)
+			*id = ""
+		}
+	}
+}
+
+// release removes volName's helper, if any, so the volume is no longer in
+// use.
+func (p *volumeHelpers) release(ctx context.Context, volName string) {
+	p.mu.Lock()
+	h, ok := p.helpers[volName]
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	p.removeLocked(ctx, h)
+}
+
+// releaseAll removes every helper.
+func (p *volumeHelpers) releaseAll(ctx context.Context) {
+	p.mu.Lock()
+	names := make([]string, 0, len(p.helpers))
+	for name := range p.helpers {
+		names = append(names, name)
+	}
+	p.mu.Unlock()
+	for _, name := range names {
+		p.release(ctx, name)
+	}
+}
+
+// createVolumeHelper starts a container that mounts volName at /vol, read-
+// only if asked, and sleeps until removed.
+func (m *Manager) createVolumeHelper(ctx context.Context, volName, image string, readOnly bool) (string, error) {
+	name := "praktor-volhelper-" + strings.TrimPrefix(volName, "praktor-")
+	bind := volName + ":/vol"
+	if readOnly {
+		name += "-ro"
+		bind += ":ro"
+	}
+	// A helper left behind by an earlier gateway run holds the name.
+	_, _ = m.docker.ContainerRemove(ctx, name, client.ContainerRemoveOptions{Force: true})
+
+	resp, err := m.docker.ContainerCreate(ctx, client.ContainerCreateOptions{
+		Config: &dockercontainer.Config{
+			Image:      image,
+			Entrypoint: []string{"sleep", "infinity"},
+			Labels:     map[string]string{labelPrefix + ".volume-helper": volName},
+		},
+		HostConfig: &dockercontainer.HostConfig{
+			Binds:       []string{bind},
+			NetworkMode: "none",
+		},
+		Name: name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create helper container: %w", err)
+	}
+	if _, err := m.docker.ContainerStart(ctx, resp.ID, client.ContainerStartOptions{}); err != nil {
+		_, _ = m.docker.ContainerRemove(ctx, resp.ID, client.ContainerRemoveOptions{Force: true})
+		return "", fmt.Errorf("start helper container: %w", err)
+	}
+	return resp.ID, nil
+}
+
+func (m *Manager) volumeHelperAlive(ctx context.Context, id string) bool {
+	res, err := m.docker.ContainerInspect(ctx, id, client.ContainerInspectOptions{})
+	return err == nil && res.Container.State != nil && res.Container.State.Running
+}
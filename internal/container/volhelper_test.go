@@ -0,0 +1,189 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeVolumeHelpers returns a pool whose containers only exist in the
+// returned set, and the number of creates attempted.
+func fakeVolumeHelpers(failCreates int) (*volumeHelpers, *sync.Map, *atomic.Int32) {
+	var live sync.Map
+	var creates atomic.Int32
+	p := &volumeHelpers{
+		helpers: make(map[string]*volumeHelper),
+		idle:    time.Hour,
+		backoff: time.Millisecond,
+		create: func(_ context.Context, volName, _ string, readOnly bool) (string, error) {
+			n := creates.Add(1)
+			if int(n) <= failCreates {
+				return "", errors.New("docker busy")
+			}
+			id := fmt.Sprintf("%s-%d", volName, n)
+			if readOnly {
+				id += "-ro"
+			}
+			live.Store(id, true)
+			return id, nil
+		},
+		alive: func(_ context.Context, id string) bool {
+			_, ok := live.Load(id)
+			return ok
+		},
+		remove: func(_ context.Context, id string) { live.Delete(id) },
+	}
+	return p, &live, &creates
+}
+
+func TestVolumeHelpersRetryCreate(t *testing.T) {
+	ctx := context.Background()
+
+	p, _, creates := fakeVolumeHelpers(2)
+	var got string
+	if err := p.with(ctx, "praktor-wk-a", "img", func(id string) error { got = id; return nil }); err != nil {
+		t.Fatalf("with after transient failures: %v", err)
+	}
+	if creates.Load() != 3 || got != "praktor-wk-a-3" {
+		t.Errorf("creates = %d, id = %q; want 3 attempts", creates.Load(), got)
+	}
+	// The helper is reused.
+	if err := p.with(ctx, "praktor-wk-a", "img", func(id string) error { got = id; return nil }); err != nil || got != "praktor-wk-a-3" || creates.Load() != 3 {
+		t.Errorf("second call: id %q, creates %d, err %v", got, creates.Load(), err)
+	}
+
+	p, _, creates = fakeVolumeHelpers(volumeHelperAttempts)
+	called := false
+	if err := p.with(ctx, "praktor-wk-a", "img", func(string) error { called = true; return nil }); err == nil || called {
+		t.Errorf("with after persistent failures: err %v, fn called %v", err, called)
+	}
+	if creates.Load() != volumeHelperAttempts {
+		t.Errorf("creates = %d, want %d", creates.Load(), volumeHelperAttempts)
+	}
+}
+
+func TestVolumeHelpersRecreateGoneHelper(t *testing.T) {
+	ctx := context.Background()
+	p, live, _ := fakeVolumeHelpers(0)
+
+	var first string
+	_ = p.with(ctx, "praktor-wk-a", "img", func(id string) error { first = id; return nil })
+	live.Delete(first) // removed behind our back
+
+	var ids []string
+	err := p.with(ctx, "praktor-wk-a", "img", func(id string) error {
+		ids = append(ids, id)
+		if id == first {
+			return errors.New("no such container")
+		}
+		return nil
+	})
+	if err != nil || len(ids) != 2 || ids[1] == first {
+		t.Errorf("ids %v, err %v; want a retry on a new helper", ids, err)
+	}
+
+	// An error from a live helper (say, a missing file) is returned as is.
+	calls := 0
+	err = p.with(ctx, "praktor-wk-a", "img", func(string) error { calls++; return errors.New("file not found") })
+	if err == nil || calls != 1 {
+		t.Errorf("err %v after %d calls, want one failed call", err, calls)
+	}
+}
+
+func TestVolumeHelpersSerializePerVolume(t *testing.T) {
+	ctx := context.Background()
+	p, _, creates := fakeVolumeHelpers(0)
+
+	var inFlight, maxInFlight atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			_ = p.with(ctx, "praktor-wk-a", "img", func(string) error {
+				n := inFlight.Add(1)
+				for {
+					m := maxInFlight.Load()
+					if n <= m || maxInFlight.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				inFlight.Add(-1)
+				return nil
+			})
+		})
+	}
+	wg.Wait()
+	if maxInFlight.Load() != 1 {
+		t.Errorf("%d calls ran at once on one volume, want 1", maxInFlight.Load())
+	}
+	if creates.Load() != 1 {
+		t.Errorf("%d helpers created, want 1", creates.Load())
+	}
+
+	// Different volumes don't wait for each other.
+	hold := make(chan struct{})
+	entered := make(chan struct{})
+	go func() {
+		_ = p.with(ctx, "praktor-wk-a", "img", func(string) error { close(entered); <-hold; return nil })
+	}()
+	<-entered
+	done := make(chan error, 1)
+	go func() { done <- p.with(ctx, "praktor-wk-b", "img", func(string) error { return nil }) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("other volume: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("call on another volume blocked behind a busy one")
+	}
+	close(hold)
+}
+
+func TestVolumeHelpersReapIdle(t *testing.T) {
+	ctx := context.Background()
+	p, live, _ := fakeVolumeHelpers(0)
+	p.idle = 20 * time.Millisecond
+
+	var id string
+	_ = p.with(ctx, "praktor-wk-a", "img", func(hid string) error { id = hid; return nil })
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := live.Load(id); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("idle helper was not removed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var next string
+	_ = p.with(ctx, "praktor-wk-a", "img", func(hid string) error { next = hid; return nil })
+	if next == id {
+		t.Error("reaped helper was reused")
+	}
+}
+
+func TestVolumeHelpersReadOnly(t *testing.T) {
+	ctx := context.Background()
+	p, live, creates := fakeVolumeHelpers(0)
+
+	var rw, ro string
+	_ = p.with(ctx, "praktor-wk-a", "img", func(id string) error { rw = id; return nil })
+	_ = p.withReadOnly(ctx, "praktor-wk-a", "img", func(id string) error { ro = id; return nil })
+	if rw == ro || ro != "praktor-wk-a-2-ro" || creates.Load() != 2 {
+		t.Errorf("rw %q, ro %q after %d creates; want a separate read-only helper", rw, ro, creates.Load())
+	}
+
+	p.releaseAll(ctx)
+	for _, id := range []string{rw, ro} {
+		if _, ok := live.Load(id); ok {
+			t.Errorf("helper %s survived releaseAll", id)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package dotenv parses .env files, as used by vault import-env and agent
+// env_file_secret.
+package dotenv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// keyRe matches the accepted keys: environment variable style identifiers,
+// plus dots and dashes.
+var keyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// Var is one KEY=VALUE entry.
+type Var struct {
+	Key   string
+	Value string
+}
+
+// Parse reads KEY=VALUE lines in file order. Blank lines and # comments are
+// skipped and an "export " prefix is allowed. Values may be unquoted (an
+// inline " #" starts a comment), 'single-quoted' (taken literally) or
+// "double-quoted" (\n, \t, \" and \\ are unescaped). A key that appears
+// twice keeps its last value at its first position.
+func Parse(r io.Reader) ([]Var, error) {
+	values := map[string]string{}
+	var order []string
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "export "); ok {
+			line = strings.TrimSpace(rest)
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		key = strings.TrimSpace(key)
+		if !keyRe.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid name %q", n, key)
+		}
+		value, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if _, seen := values[key]; !seen {
+			order = append(order, key)
+		}
+		values[key] = value
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]Var, 0, len(order))
+	for _, key := range order {
+		out = append(out, Var{Key: key, Value: values[key]})
+	}
+	return out, nil
+}
+
+func parseValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	switch quote := raw[0]; quote {
+	case '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single quote")
+		}
+		if err := checkAfterQuote(raw[end+2:]); err != nil {
+			return "", err
+		}
+		return raw[1 : end+1], nil
+	case '"':
+		var b strings.Builder
+		for i := 1; i < len(raw); i++ {
+			c := raw[i]
+			switch {
+			case c == '"':
+				if err := checkAfterQuote(raw[i+1:]); err != nil {
+					return "", err
+				}
+				return b.String(), nil
+			case c == '\\' && i+1 < len(raw):
+				i++
+				switch raw[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case '"', '\\':
+					b.WriteByte(raw[i])
+				default:
+					b.WriteByte('\\')
+					b.WriteByte(raw[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated double quote")
+	default:
+		if i := strings.Index(raw, " #"); i >= 0 {
+			raw = raw[:i]
+		}
+		return strings.TrimSpace(raw), nil
+	}
+}
+
+// checkAfterQuote allows only whitespace and a comment after a closing quote.
+func checkAfterQuote(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected text after closing quote: %q", rest)
+	}
+	return nil
+}
+
+// Map parses data into a key to value map.
+func Map(data []byte) (map[string]string, error) {
+	vars, err := Parse(strings.NewReader(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(vars))
+	for _, v := range vars {
+		m[v.Key] = v.Value
+	}
+	return m, nil
+}
@@ -0,0 +1,62 @@
+package dotenv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := `# service
+export API_URL = https://api.example.com/v1?a=1&b=2
+API_TOKEN="tok \"quoted\"\tend" # trailing comment
+PEM='-----BEGIN KEY-----\nraw'
+PLAIN=value # comment
+HASH=abc#def
+EMPTY=
+
+API_URL=https://override
+`
+	got, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Var{
+		{"API_URL", "https://override"},
+		{"API_TOKEN", "tok \"quoted\"\tend"},
+		{"PEM", `-----BEGIN KEY-----\nraw`},
+		{"PLAIN", "value"},
+		{"HASH", "abc#def"},
+		{"EMPTY", ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("var %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"OK=1\nNOVALUE",
+		"OK=1\n9LIVES=x",
+		`OK=1` + "\n" + `OPEN="unterminated`,
+		`OK=1` + "\n" + `JUNK='value' extra`,
+	} {
+		if _, err := Parse(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("Parse(%q) error = %v, want a line 2 error", input, err)
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	m, err := Map([]byte("A=1\nB='two'\nA=3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m["A"] != "3" || m["B"] != "two" {
+		t.Errorf("Map = %v", m)
+	}
+}
@@ -0,0 +1,214 @@
+// Package events defines the payloads published on the events.> topics and
+// forwarded to the web UI over the WebSocket. Every event carries the schema
+// version in "v" so clients can branch on it. Adding a field keeps the
+// version; renaming or removing one, or changing its meaning, bumps Version.
+package events
+
+import "time"
+
+// Version is the schema version stamped on every event.
+const Version = 1
+
+// now is replaced in tests to pin timestamps.
+var now = time.Now
+
+// Header holds the fields every event starts with. Decode a payload into it
+// to read the type and version before picking the concrete struct.
+type Header struct {
+	V         int    `json:"v"`
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+}
+
+func newHeader(eventType string) Header {
+	return Header{V: Version, Type: eventType, Timestamp: now().UTC().Format(time.RFC3339)}
+}
+
+// Event types published on events.agent.<id>.
+const (
+	TypeMessage                = "message"
+	TypeAgentStarted           = "agent_started"
+	TypeAgentStopped           = "agent_stopped"
+	TypeAgentRestart           = "agent_restart"
+	TypeAgentRestarted         = "agent_restarted"
+	TypeAgentError             = "agent_error"
+	TypeAgentDryRun            = "agent_dry_run"
+	TypeModelFallback          = "model_fallback"
+	TypeSecretDecryptFailed    = "secret_decrypt_failed"
+	TypeWorkspaceQuotaExceeded = "workspace_quota_exceeded"
+	TypeAgentSlow              = "agent_slow"
+)
+
+// Message is a chat message stored for an agent, either direction.
+type Message struct {
+	Header
+	AgentID string      `json:"agent_id"`
+	Data    MessageData `json:"data"`
+}
+
+// MessageData is the message itself; Role is "user" or "assistant" and Time
+// is the local HH:MM it was created.
+type MessageData struct {
+	ID             int64  `json:"id"`
+	Role           string `json:"role"`
+	Text           string `json:"text"`
+	Time           string `json:"time"`
+	TerminalReason string `json:"terminal_reason,omitempty"`
+	ReplyTo        *int64 `json:"reply_to,omitempty"`
+}
+
+func NewMessage(agentID string, data MessageData) Message {
+	return Message{Header: newHeader(TypeMessage), AgentID: agentID, Data: data}
+}
+
+// AgentStarted is published when an agent has started.
+type AgentStarted struct {
+	Header
+	AgentID string `json:"agent_id"`
+}
+
+func NewAgentStarted(agentID string) AgentStarted {
+	return AgentStarted{Header: newHeader(TypeAgentStarted), AgentID: agentID}
+}
+
+// AgentStopped is published after an agent's container is removed.
+type AgentStopped struct {
+	Header
+	AgentID string `json:"agent_id"`
+	Reason  string `json:"reason"`
+}
+
+func NewAgentStopped(agentID, reason string) AgentStopped {
+	return AgentStopped{Header: newHeader(TypeAgentStopped), AgentID: agentID, Reason: reason}
+}
+
+// AgentRestart is published when a drained restart stops an agent; TimedOut
+// is set when in-flight messages were dropped at the drain timeout.
+type AgentRestart struct {
+	Header
+	AgentID  string `json:"agent_id"`
+	Reason   string `json:"reason"`
+	TimedOut bool   `json:"timed_out"`
+}
+
+func NewAgentRestart(agentID, reason string, timedOut bool) AgentRestart {
+	return AgentRestart{Header: newHeader(TypeAgentRestart), AgentID: agentID, Reason: reason, TimedOut: timedOut}
+}
+
+// AgentRestarted is published after a bounce replaced an agent's container.
+type AgentRestarted struct {
+	Header
+	AgentID        string `json:"agent_id"`
+	OldContainerID string `json:"old_container_id"`
+	ContainerID    string `json:"container_id"`
+	SessionCleared bool   `json:"session_cleared"`
+}
+
+func NewAgentRestarted(agentID, oldContainerID, containerID string, sessionCleared bool) AgentRestarted {
+	return AgentRestarted{
+		Header:         newHeader(TypeAgentRestarted),
+		AgentID:        agentID,
+		OldContainerID: oldContainerID,
+		ContainerID:    containerID,
+		SessionCleared: sessionCleared,
+	}
+}
+
+// AgentError reports a failed message execution or start.
+type AgentError struct {
+	Header
+	AgentID string `json:"agent_id"`
+	Reason  string `json:"reason"`
+	TraceID string `json:"trace_id,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func NewAgentError(agentID, reason string) AgentError {
+	return AgentError{Header: newHeader(TypeAgentError), AgentID: agentID, Reason: reason}
+}
+
+// AgentDryRun reports the outcome and timings of a dry run.
+type AgentDryRun struct {
+	Header
+	AgentID        string `json:"agent_id"`
+	OK             bool   `json:"ok"`
+	Error          string `json:"error"`
+	AlreadyRunning bool   `json:"already_running"`
+	StartMs        int64  `json:"start_ms"`
+	ReadyMs        int64  `json:"ready_ms"`
+	AckMs          int64  `json:"ack_ms"`
+	TotalMs        int64  `json:"total_ms"`
+}
+
+func NewAgentDryRun(agentID string) AgentDryRun {
+	return AgentDryRun{Header: newHeader(TypeAgentDryRun), AgentID: agentID}
+}
+
+// ModelFallback is published when a message is retried on a fallback model.
+type ModelFallback struct {
+	Header
+	AgentID   string `json:"agent_id"`
+	MsgID     string `json:"msg_id"`
+	Code      string `json:"code"`
+	FromModel string `json:"from_model"`
+	ToModel   string `json:"to_model"`
+	Attempt   int    `json:"attempt"`
+}
+
+func NewModelFallback(agentID, msgID, code, fromModel, toModel string, attempt int) ModelFallback {
+	return ModelFallback{
+		Header:    newHeader(TypeModelFallback),
+		AgentID:   agentID,
+		MsgID:     msgID,
+		Code:      code,
+		FromModel: fromModel,
+		ToModel:   toModel,
+		Attempt:   attempt,
+	}
+}
+
+// SecretDecryptFailed is published the first time a secret fails to decrypt;
+// FailedSecrets counts the secrets currently failing.
+type SecretDecryptFailed struct {
+	Header
+	AgentID       string `json:"agent_id"`
+	Secret        string `json:"secret"`
+	FailedSecrets int    `json:"failed_secrets"`
+}
+
+func NewSecretDecryptFailed(agentID, secret string, failedSecrets int) SecretDecryptFailed {
+	return SecretDecryptFailed{Header: newHeader(TypeSecretDecryptFailed), AgentID: agentID, Secret: secret, FailedSecrets: failedSecrets}
+}
+
+// WorkspaceQuotaExceeded is published when an agent's workspace outgrows its
+// quota; Enforced is set when the quota blocks starts rather than only warning.
+type WorkspaceQuotaExceeded struct {
+	Header
+	AgentID    string `json:"agent_id"`
+	Bytes      int64  `json:"bytes"`
+	QuotaBytes int64  `json:"quota_bytes"`
+	Enforced   bool   `json:"enforced"`
+}
+
+func NewWorkspaceQuotaExceeded(agentID string, bytes, quotaBytes int64, enforced bool) WorkspaceQuotaExceeded {
+	return WorkspaceQuotaExceeded{
+		Header:     newHeader(TypeWorkspaceQuotaExceeded),
+		AgentID:    agentID,
+		Bytes:      bytes,
+		QuotaBytes: quotaBytes,
+		Enforced:   enforced,
+	}
+}
+
+// AgentSlow is published when a message has had no result for the agent's
+// slow_warning_after; the agent keeps working.
+type AgentSlow struct {
+	Header
+	AgentID   string `json:"agent_id"`
+	MsgID     string `json:"msg_id"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+}
+
+func NewAgentSlow(agentID, msgID string, elapsed time.Duration) AgentSlow {
+	return AgentSlow{Header: newHeader(TypeAgentSlow), AgentID: agentID, MsgID: msgID, ElapsedMs: elapsed.Milliseconds()}
+}
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/*.golden")
+
+// TestGolden pins the JSON of every event type. A failure means a client
+// visible shape changed: if that's intended and not just an added field,
+// bump Version, then regenerate with go test ./internal/events -update.
+func TestGolden(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 5, 11, 7, 30, 0, 0, time.UTC) }
+	t.Cleanup(func() { now = time.Now })
+
+	replyTo := int64(41)
+	agentError := NewAgentError("coder", "start_failed")
+	agentError.TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	agentError.Error = "image is linux/arm64, host is amd64"
+	dryRun := NewAgentDryRun("coder")
+	dryRun.OK = true
+	dryRun.StartMs, dryRun.ReadyMs, dryRun.AckMs, dryRun.TotalMs = 800, 1200, 15, 2015
+
+	tests := []struct {
+		name  string
+		event any
+	}{
+		{"message", NewMessage("coder", MessageData{ID: 42, Role: "assistant", Text: "done", Time: "07:30", TerminalReason: "max_turns", ReplyTo: &replyTo})},
+		{"agent_started", NewAgentStarted("coder")},
+		{"agent_stopped", NewAgentStopped("coder", "idle_timeout")},
+		{"agent_restart", NewAgentRestart("coder", "config_changed", true)},
+		{"agent_restarted", NewAgentRestarted("coder", "abc123", "def456", false)},
+		{"agent_error", agentError},
+		{"agent_dry_run", dryRun},
+		{"model_fallback", NewModelFallback("coder", "msg-1", "overloaded", "claude-opus-4", "claude-sonnet-4", 1)},
+		{"secret_decrypt_failed", NewSecretDecryptFailed("coder", "github-token", 2)},
+		{"workspace_quota_exceeded", NewWorkspaceQuotaExceeded("coder", 2<<30, 1<<30, true)},
+		{"agent_slow", NewAgentSlow("coder", "msg-1", 2*time.Minute)},
+		{"task_executed", NewTaskExecuted("task-1", "daily digest", "success")},
+		{"scheduler_backlog", NewSchedulerBacklog(3, 2, 2)},
+		{"task_failed", NewTaskFailed("task-1", "daily digest", "coder", "agent timed out", 3, true)},
+		{"secret", NewSecret(TypeSecretCreated, "sec-1", "github-token")},
+		{"swarm_started", NewSwarmStarted("sw-1", "review", 3)},
+		{"swarm_failed", NewSwarmFailed("sw-1", SwarmFailedData{Reason: "orphaned"})},
+		{"swarm_agent_started", NewSwarmAgentStarted("sw-1", "reviewer", "swarm-sw-1-reviewer")},
+		{"swarm_agent_completed", NewSwarmAgentCompleted("sw-1", "reviewer", "completed", "looks good")},
+		{"swarm_tier_completed", NewSwarmTierCompleted("sw-1", 0, 2)},
+		{"swarm_finished", NewSwarmFinished("sw-1", "completed_with_errors", SwarmFinishedData{ResultsCount: 3, Succeeded: 2, Total: 3, Policy: "continue"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.MarshalIndent(tt.event, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			path := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s changed:\n got: %s\nwant: %s", path, got, want)
+			}
+		})
+	}
+}
+
+func TestHeaderDecodesAnyEvent(t *testing.T) {
+	data, err := json.Marshal(NewSwarmTierCompleted("sw-1", 1, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h Header
+	if err := json.Unmarshal(data, &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.V != Version || h.Type != TypeSwarmTierCompleted || h.Timestamp == "" {
+		t.Errorf("header = %+v", h)
+	}
+}
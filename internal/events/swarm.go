@@ -0,0 +1,85 @@
+package events
+
+// Swarm event types published on events.swarm.<id>. A finished run is
+// published as "swarm_" + its final status, e.g. swarm_completed.
+const (
+	TypeSwarmStarted        = "swarm_started"
+	TypeSwarmFailed         = "swarm_failed"
+	TypeSwarmAgentStarted   = "swarm_agent_started"
+	TypeSwarmAgentCompleted = "swarm_agent_completed"
+	TypeSwarmTierCompleted  = "swarm_tier_completed"
+)
+
+// Swarm is a swarm run event; D is the event type's data.
+type Swarm[D any] struct {
+	Header
+	SwarmID string `json:"swarm_id"`
+	Data    D      `json:"data"`
+}
+
+func newSwarm[D any](eventType, swarmID string, data D) Swarm[D] {
+	return Swarm[D]{Header: newHeader(eventType), SwarmID: swarmID, Data: data}
+}
+
+type SwarmStartedData struct {
+	Name   string `json:"name"`
+	Agents int    `json:"agents"`
+}
+
+func NewSwarmStarted(swarmID, name string, agents int) Swarm[SwarmStartedData] {
+	return newSwarm(TypeSwarmStarted, swarmID, SwarmStartedData{Name: name, Agents: agents})
+}
+
+// SwarmFailedData carries Error when the run couldn't be planned, or Reason
+// when it was marked failed after the fact (e.g. orphaned by a restart).
+type SwarmFailedData struct {
+	Error  string `json:"error,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func NewSwarmFailed(swarmID string, data SwarmFailedData) Swarm[SwarmFailedData] {
+	return newSwarm(TypeSwarmFailed, swarmID, data)
+}
+
+type SwarmAgentStartedData struct {
+	Role    string `json:"role"`
+	AgentID string `json:"agent_id"`
+}
+
+func NewSwarmAgentStarted(swarmID, role, agentID string) Swarm[SwarmAgentStartedData] {
+	return newSwarm(TypeSwarmAgentStarted, swarmID, SwarmAgentStartedData{Role: role, AgentID: agentID})
+}
+
+// SwarmAgentCompletedData reports a member's result; Output is truncated.
+type SwarmAgentCompletedData struct {
+	Role   string `json:"role"`
+	Status string `json:"status"`
+	Output string `json:"output"`
+}
+
+func NewSwarmAgentCompleted(swarmID, role, status, output string) Swarm[SwarmAgentCompletedData] {
+	return newSwarm(TypeSwarmAgentCompleted, swarmID, SwarmAgentCompletedData{Role: role, Status: status, Output: output})
+}
+
+// SwarmTierCompletedData reports a finished tier, zero-based, out of Total.
+type SwarmTierCompletedData struct {
+	Tier  int `json:"tier"`
+	Total int `json:"total"`
+}
+
+func NewSwarmTierCompleted(swarmID string, tier, total int) Swarm[SwarmTierCompletedData] {
+	return newSwarm(TypeSwarmTierCompleted, swarmID, SwarmTierCompletedData{Tier: tier, Total: total})
+}
+
+type SwarmFinishedData struct {
+	ResultsCount int    `json:"results_count"`
+	Succeeded    int    `json:"succeeded"`
+	Total        int    `json:"total"`
+	Policy       string `json:"policy"`
+}
+
+// NewSwarmFinished returns the event for a run that ended with status. A
+// failed run's swarm_failed carries this data rather than SwarmFailedData.
+func NewSwarmFinished(swarmID, status string, data SwarmFinishedData) Swarm[SwarmFinishedData] {
+	return newSwarm("swarm_"+status, swarmID, data)
+}
@@ -0,0 +1,86 @@
+package events
+
+// Task and secret event types. Secret events are typed by the topic they're
+// published on.
+const (
+	TypeTaskExecuted     = "task_executed"
+	TypeTaskFailed       = "task_failed"
+	TypeSchedulerBacklog = "scheduler_backlog"
+
+	// Secret events keep the subject they were first published on as their
+	// type, without any nats.subject_prefix.
+	TypeSecretCreated = "events.secret.created"
+	TypeSecretUpdated = "events.secret.updated"
+	TypeSecretDeleted = "events.secret.deleted"
+)
+
+// TaskExecuted is published after each scheduled task run.
+type TaskExecuted struct {
+	Header
+	Data TaskData `json:"data"`
+}
+
+// TaskData identifies a task run; Status is the run's outcome.
+type TaskData struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+func NewTaskExecuted(id, name, status string) TaskExecuted {
+	return TaskExecuted{Header: newHeader(TypeTaskExecuted), Data: TaskData{ID: id, Name: name, Status: status}}
+}
+
+// TaskFailed is published on events.task.failed after a failed run, and is
+// the body posted to a task's on_failure webhook.
+type TaskFailed struct {
+	Header
+	TaskID              string `json:"task_id"`
+	Name                string `json:"name"`
+	AgentID             string `json:"agent_id"`
+	Error               string `json:"error"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Paused              bool   `json:"paused"`
+}
+
+func NewTaskFailed(taskID, name, agentID, reason string, consecutiveFailures int, paused bool) TaskFailed {
+	return TaskFailed{
+		Header:              newHeader(TypeTaskFailed),
+		TaskID:              taskID,
+		Name:                name,
+		AgentID:             agentID,
+		Error:               reason,
+		ConsecutiveFailures: consecutiveFailures,
+		Paused:              paused,
+	}
+}
+
+// SchedulerBacklog is published when the number of due tasks waiting for a
+// dispatch slot changes. Concurrency is scheduler.concurrency.
+type SchedulerBacklog struct {
+	Header
+	Depth       int `json:"depth"`
+	InFlight    int `json:"in_flight"`
+	Concurrency int `json:"concurrency"`
+}
+
+func NewSchedulerBacklog(depth, inFlight, concurrency int) SchedulerBacklog {
+	return SchedulerBacklog{Header: newHeader(TypeSchedulerBacklog), Depth: depth, InFlight: inFlight, Concurrency: concurrency}
+}
+
+// Secret is published when a vault secret is created, updated or deleted.
+type Secret struct {
+	Header
+	Data SecretData `json:"data"`
+}
+
+type SecretData struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// NewSecret returns a secret event of eventType, one of the TypeSecret*
+// constants.
+func NewSecret(eventType, id, name string) Secret {
+	return Secret{Header: newHeader(eventType), Data: SecretData{ID: id, Name: name}}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -126,6 +127,23 @@ func Parse(data string) (*AgentExtensions, error) {
 	return &ext, nil
 }
 
+// SecretRefs returns the names of the secrets referenced as secret:name in
+// MCP server env and headers values, sorted and without duplicates.
+func (e *AgentExtensions) SecretRefs() []string {
+	var names []string
+	for _, srv := range e.MCPServers {
+		for _, vals := range []map[string]string{srv.Env, srv.Headers} {
+			for _, v := range vals {
+				if name, ok := strings.CutPrefix(v, "secret:"); ok {
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
 // ResolveSecretRefs resolves secret:name references in MCP server env and
 // headers values using the provided resolver function.
 func (e *AgentExtensions) ResolveSecretRefs(resolve func(name string) (string, error)) error {
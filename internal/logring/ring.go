@@ -0,0 +1,265 @@
+// Package logring keeps the gateway's most recent log records in memory so
+// the web UI can show and tail them without access to the container logs.
+package logring
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSize is the number of records kept when the configured size is 0.
+const DefaultSize = 5000
+
+// Redacted replaces the value of sensitive attributes.
+const Redacted = "***"
+
+// sensitiveWords mark attribute keys whose values are never kept.
+var sensitiveWords = []string{"token", "secret", "password", "passwd", "api_key", "apikey", "authorization", "cookie", "credential"}
+
+// Entry is one log record.
+type Entry struct {
+	Time      time.Time      `json:"time"`
+	Level     string         `json:"level"`
+	Component string         `json:"component"`
+	Message   string         `json:"message"`
+	Attrs     map[string]any `json:"attrs,omitempty"`
+}
+
+// Ring is a fixed-size buffer of the latest entries. The zero value is not
+// usable; create one with New.
+type Ring struct {
+	mu      sync.RWMutex
+	entries []Entry
+	next    int // slot the next entry is written to
+	full    bool
+
+	subMu  sync.RWMutex
+	subs   map[int]func(Entry)
+	nextID int
+}
+
+// New returns a ring holding the last size entries (DefaultSize if size <= 0).
+func New(size int) *Ring {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Ring{entries: make([]Entry, size), subs: make(map[int]func(Entry))}
+}
+
+func (r *Ring) add(e Entry) {
+	r.mu.Lock()
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.mu.Unlock()
+
+	r.subMu.RLock()
+	defer r.subMu.RUnlock()
+	for _, fn := range r.subs {
+		fn(e)
+	}
+}
+
+// Filter selects entries. Zero fields other than Level match everything;
+// the zero Level is info.
+type Filter struct {
+	Level     slog.Level // minimum level
+	Component string     // exact component
+	Query     string     // case-insensitive substring of the message or an attribute value
+	Limit     int        // newest entries returned; 0 = all
+}
+
+// Match reports whether e passes f.
+func (f Filter) Match(e Entry) bool {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(e.Level)); err == nil && level < f.Level {
+		return false
+	}
+	if f.Component != "" && e.Component != f.Component {
+		return false
+	}
+	if f.Query == "" {
+		return true
+	}
+	q := strings.ToLower(f.Query)
+	if strings.Contains(strings.ToLower(e.Message), q) {
+		return true
+	}
+	for _, v := range e.Attrs {
+		if strings.Contains(strings.ToLower(fmt.Sprint(v)), q) {
+			return true
+		}
+	}
+	return false
+}
+
+// Entries returns the matching entries, oldest first.
+func (r *Ring) Entries(f Filter) []Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var ordered []Entry
+	if r.full {
+		ordered = append(ordered, r.entries[r.next:]...)
+	}
+	ordered = append(ordered, r.entries[:r.next]...)
+
+	out := []Entry{}
+	for _, e := range ordered {
+		if f.Match(e) {
+			out = append(out, e)
+		}
+	}
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out
+}
+
+// Subscribe calls fn with every entry added until the returned cancel func
+// is called. fn runs on the logging goroutine, so it must not block or log.
+func (r *Ring) Subscribe(fn func(Entry)) (cancel func()) {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	id := r.nextID
+	r.nextID++
+	r.subs[id] = fn
+	return func() {
+		r.subMu.Lock()
+		defer r.subMu.Unlock()
+		delete(r.subs, id)
+	}
+}
+
+// Handler returns a slog.Handler that passes records to next and also
+// appends them to the ring.
+func (r *Ring) Handler(next slog.Handler) slog.Handler {
+	return &handler{ring: r, next: next}
+}
+
+// SetDefault makes the ring record everything logged through slog's
+// default logger, which keeps its current handler and output format.
+// slog.SetDefault points the log package at the new logger; the stock slog
+// handler itself writes through the log package, so its previous output is
+// restored to keep records from looping back into the ring.
+func (r *Ring) SetDefault() {
+	out := log.Writer()
+	slog.SetDefault(slog.New(r.Handler(slog.Default().Handler())))
+	log.SetOutput(out)
+}
+
+type handler struct {
+	ring   *Ring
+	next   slog.Handler
+	attrs  []slog.Attr // from WithAttrs, keys already qualified by group
+	prefix string      // open groups, "a.b."
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, rec slog.Record) error {
+	e := Entry{
+		Time:      rec.Time,
+		Level:     rec.Level.String(),
+		Component: component(rec.PC),
+		Message:   rec.Message,
+	}
+	if len(h.attrs) > 0 || rec.NumAttrs() > 0 {
+		e.Attrs = make(map[string]any, len(h.attrs)+rec.NumAttrs())
+		for _, a := range h.attrs {
+			addAttr(e.Attrs, "", a)
+		}
+		rec.Attrs(func(a slog.Attr) bool {
+			addAttr(e.Attrs, h.prefix, a)
+			return true
+		})
+		if c, ok := e.Attrs["component"].(string); ok && c != "" {
+			e.Component = c
+		}
+	}
+	h.ring.add(e)
+	return h.next.Handle(ctx, rec)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	qualified := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	qualified = append(qualified, h.attrs...)
+	for _, a := range attrs {
+		qualified = append(qualified, slog.Attr{Key: h.prefix + a.Key, Value: a.Value})
+	}
+	return &handler{ring: h.ring, next: h.next.WithAttrs(attrs), attrs: qualified, prefix: h.prefix}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{ring: h.ring, next: h.next.WithGroup(name), attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+// addAttr stores a under prefix+key, flattening groups and redacting
+// sensitive keys.
+func addAttr(m map[string]any, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		p := prefix
+		if a.Key != "" {
+			p += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			addAttr(m, p, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	key := prefix + a.Key
+	switch {
+	case sensitive(key):
+		m[key] = Redacted
+	case v.Kind() == slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			m[key] = err.Error()
+		} else {
+			m[key] = fmt.Sprint(v.Any())
+		}
+	default:
+		m[key] = v.Any()
+	}
+}
+
+func sensitive(key string) bool {
+	lower := strings.ToLower(key)
+	for _, w := range sensitiveWords {
+		if strings.Contains(lower, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// component names the package that logged the record, e.g. "agent" for
+// github.com/mtzanidakis/praktor/internal/agent.
+func component(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	fn := frame.Function // path/to/pkg.(*Type).Method
+	if i := strings.LastIndex(fn, "/"); i >= 0 {
+		fn = fn[i+1:]
+	}
+	pkg, _, _ := strings.Cut(fn, ".")
+	return pkg
+}
@@ -0,0 +1,114 @@
+package logring
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func newTestLogger(size int) (*Ring, *slog.Logger, *bytes.Buffer) {
+	r := New(size)
+	var out bytes.Buffer
+	next := slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return r, slog.New(r.Handler(next)), &out
+}
+
+func TestRingEviction(t *testing.T) {
+	r, log, _ := newTestLogger(3)
+	for _, msg := range []string{"one", "two", "three", "four", "five"} {
+		log.Info(msg)
+	}
+	got := r.Entries(Filter{})
+	if len(got) != 3 || got[0].Message != "three" || got[2].Message != "five" {
+		t.Fatalf("entries = %v, want three..five", got)
+	}
+	if got := r.Entries(Filter{Limit: 2}); len(got) != 2 || got[0].Message != "four" {
+		t.Errorf("limited entries = %v, want four, five", got)
+	}
+}
+
+func TestRingFilter(t *testing.T) {
+	r, log, out := newTestLogger(10)
+	log.Debug("debug line")
+	log.Info("agent started", "agent", "coder")
+	log.Warn("slow reply", "component", "telegram", "chat", 42)
+	log.Error("send failed", "error", errors.New("connection refused"))
+
+	if !strings.Contains(out.String(), "agent started") {
+		t.Error("record not passed to the next handler")
+	}
+
+	tests := []struct {
+		name string
+		f    Filter
+		want []string
+	}{
+		{"all", Filter{Level: slog.LevelDebug}, []string{"debug line", "agent started", "slow reply", "send failed"}},
+		{"warn and up", Filter{Level: slog.LevelWarn}, []string{"slow reply", "send failed"}},
+		{"explicit component", Filter{Component: "telegram"}, []string{"slow reply"}},
+		{"calling package", Filter{Level: slog.LevelInfo, Component: "logring"}, []string{"agent started", "send failed"}},
+		{"query message", Filter{Query: "STARTED"}, []string{"agent started"}},
+		{"query attr", Filter{Query: "refused"}, []string{"send failed"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range r.Entries(tt.f) {
+			got = append(got, e.Message)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRingRedactsAndFlattensAttrs(t *testing.T) {
+	r, log, _ := newTestLogger(10)
+	log.With("bot_token", "123:abc").WithGroup("req").Info("call", "Authorization", "Bearer x", "path", "/api")
+
+	e := r.Entries(Filter{})[0]
+	if e.Attrs["bot_token"] != Redacted || e.Attrs["req.Authorization"] != Redacted {
+		t.Errorf("sensitive attrs not redacted: %v", e.Attrs)
+	}
+	if e.Attrs["req.path"] != "/api" {
+		t.Errorf("req.path = %v", e.Attrs["req.path"])
+	}
+}
+
+func TestRingSubscribe(t *testing.T) {
+	r, log, _ := newTestLogger(10)
+	var got []string
+	cancel := r.Subscribe(func(e Entry) { got = append(got, e.Message) })
+	log.Info("first")
+	cancel()
+	log.Info("second")
+	if len(got) != 1 || got[0] != "first" {
+		t.Errorf("subscriber got %v, want [first]", got)
+	}
+}
+
+func TestRingSetDefault(t *testing.T) {
+	prev, prevOut, prevFlags := slog.Default(), log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		log.SetOutput(prevOut)
+		log.SetFlags(prevFlags)
+	})
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	log.SetFlags(0)
+
+	r := New(10)
+	r.SetDefault()
+	slog.Info("wrapped", "k", 1)
+	log.Print("plain")
+
+	if got := out.String(); got != "INFO wrapped k=1\nplain\n" {
+		t.Errorf("output = %q, want the default handler's format", got)
+	}
+	if e := r.Entries(Filter{}); len(e) != 1 || e[0].Message != "wrapped" {
+		t.Errorf("ring = %v, want the slog record", e)
+	}
+}
@@ -0,0 +1,59 @@
+package natsbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// CapabilitiesVersion is the schema version of the capabilities message.
+const CapabilitiesVersion = 1
+
+// Capability names an optional agent-runner feature.
+type Capability string
+
+const (
+	// CapReadySignal: the runner publishes on TopicAgentReady once its
+	// subscriptions are registered.
+	CapReadySignal Capability = "ready_signal"
+	// CapHistoryInjection: the runner prepends the "history" input key to
+	// the prompt.
+	CapHistoryInjection Capability = "history_injection"
+	// CapModelFallback: the runner reports retryable model errors as
+	// "error" outputs and honours the "model" input key.
+	CapModelFallback Capability = "model_fallback"
+	// CapSessionResume: the runner resumes the conversation named by
+	// SESSION_ID.
+	CapSessionResume Capability = "session_resume"
+)
+
+// Capabilities is the payload an agent-runner publishes on
+// TopicAgentCapabilities at startup, after its subscriptions are registered
+// and before the ready signal:
+//
+//	{"version": 1, "features": ["ready_signal", "history_injection"]}
+//
+// Feature names the host does not know are kept and ignored, so newer
+// images can announce features older hosts predate.
+type Capabilities struct {
+	Version  int          `json:"version"`
+	Features []Capability `json:"features"`
+}
+
+// ParseCapabilities decodes a capabilities message.
+func ParseCapabilities(data []byte) (*Capabilities, error) {
+	var c Capabilities
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("decode capabilities: %w", err)
+	}
+	if c.Version < 1 {
+		return nil, fmt.Errorf("decode capabilities: missing version")
+	}
+	return &c, nil
+}
+
+// Has reports whether the agent announced f. A nil Capabilities (nothing
+// announced) has no features.
+func (c *Capabilities) Has(f Capability) bool {
+	return c != nil && slices.Contains(c.Features, f)
+}
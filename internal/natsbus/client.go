@@ -3,29 +3,61 @@ package natsbus
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/nats-io/nats.go"
 )
 
+const (
+	reconnectWait    = 500 * time.Millisecond
+	reconnectBufSize = 8 << 20 // publishes buffered while disconnected
+)
+
+// Client is a NATS connection that survives server or network blips: it
+// reconnects indefinitely, buffers publishes while disconnected, and the
+// nats.go client replays every active subscription on reconnect, so handlers
+// registered via Subscribe keep receiving without re-subscribing.
 type Client struct {
-	conn *nats.Conn
+	conn      *nats.Conn
+	connected atomic.Bool
 }
 
 func NewClient(bus *Bus) (*Client, error) {
-	conn, err := nats.Connect(bus.ClientURL())
-	if err != nil {
-		return nil, fmt.Errorf("connect to nats: %w", err)
-	}
-	return &Client{conn: conn}, nil
+	return NewClientFromURL(bus.ClientURL())
 }
 
 func NewClientFromURL(url string) (*Client, error) {
-	conn, err := nats.Connect(url)
+	c := &Client{}
+	conn, err := nats.Connect(url,
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(reconnectWait),
+		nats.ReconnectBufSize(reconnectBufSize),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			c.connected.Store(false)
+			slog.Warn("nats disconnected", "error", err)
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			c.connected.Store(true)
+			slog.Info("nats reconnected", "url", nc.ConnectedUrl(), "subscriptions", nc.NumSubscriptions())
+		}),
+		nats.ClosedHandler(func(*nats.Conn) {
+			c.connected.Store(false)
+		}),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("connect to nats: %w", err)
 	}
-	return &Client{conn: conn}, nil
+	c.conn = conn
+	c.connected.Store(true)
+	return c, nil
+}
+
+// Connected reports whether the client currently has a live connection.
+// It turns false on disconnect and true again once reconnected.
+func (c *Client) Connected() bool {
+	return c.connected.Load()
 }
 
 func (c *Client) Publish(topic string, data []byte) error {
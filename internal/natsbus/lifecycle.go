@@ -0,0 +1,35 @@
+package natsbus
+
+import "time"
+
+// Agent lifecycle event types published on TopicAgentLifecycle. These form
+// a stable contract for external supervisors and are distinct from the
+// UI-oriented events.agent.<id> stream; do not rename or remove fields.
+const (
+	LifecycleStarting  = "agent_starting"  // before the container is created
+	LifecycleStarted   = "agent_started"   // container is running
+	LifecycleReady     = "agent_ready"     // agent-runner completed the ready handshake
+	LifecycleUnhealthy = "agent_unhealthy" // ready handshake timed out
+	LifecycleStopped   = "agent_stopped"   // container was stopped and removed
+)
+
+// LifecycleEvent is the payload published on TopicAgentLifecycle.
+type LifecycleEvent struct {
+	Type        string `json:"type"`
+	AgentID     string `json:"agent_id"`
+	ContainerID string `json:"container_id,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	Timestamp   string `json:"timestamp"`
+}
+
+// now is replaced in tests to pin timestamps.
+var now = time.Now
+
+// NewLifecycleEvent returns a lifecycle event stamped with the current UTC time.
+func NewLifecycleEvent(eventType, agentID string) LifecycleEvent {
+	return LifecycleEvent{
+		Type:      eventType,
+		AgentID:   agentID,
+		Timestamp: now().UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,94 @@
+package natsbus
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLifecycleEventSchema(t *testing.T) {
+	ev := LifecycleEvent{
+		Type:        LifecycleReady,
+		AgentID:     "coder",
+		ContainerID: "abc123",
+		Reason:      "",
+		Timestamp:   "2026-01-02T03:04:05Z",
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"agent_ready","agent_id":"coder","container_id":"abc123","timestamp":"2026-01-02T03:04:05Z"}`
+	if string(data) != want {
+		t.Errorf("schema changed:\n got %s\nwant %s", data, want)
+	}
+
+	ev = LifecycleEvent{Type: LifecycleStopped, AgentID: "coder", Reason: "idle_timeout", Timestamp: ev.Timestamp}
+	data, _ = json.Marshal(ev)
+	want = `{"type":"agent_stopped","agent_id":"coder","reason":"idle_timeout","timestamp":"2026-01-02T03:04:05Z"}`
+	if string(data) != want {
+		t.Errorf("schema changed:\n got %s\nwant %s", data, want)
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite testdata/*.golden")
+
+// TestLifecycleGolden pins the JSON of every lifecycle event type. External
+// supervisors depend on it; regenerate with go test ./internal/natsbus -update
+// only for an added field.
+func TestLifecycleGolden(t *testing.T) {
+	now = func() time.Time { return time.Date(2026, 5, 11, 7, 30, 0, 0, time.UTC) }
+	t.Cleanup(func() { now = time.Now })
+
+	event := func(eventType, containerID, reason string) LifecycleEvent {
+		ev := NewLifecycleEvent(eventType, "coder")
+		ev.ContainerID = containerID
+		ev.Reason = reason
+		return ev
+	}
+	for _, ev := range []LifecycleEvent{
+		event(LifecycleStarting, "", ""),
+		event(LifecycleStarted, "abc123", ""),
+		event(LifecycleReady, "abc123", ""),
+		event(LifecycleUnhealthy, "abc123", "ready_timeout"),
+		event(LifecycleStopped, "", "idle_timeout"),
+	} {
+		t.Run(ev.Type, func(t *testing.T) {
+			got, err := json.MarshalIndent(ev, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			path := filepath.Join("testdata", ev.Type+".golden")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("%s changed:\n got: %s\nwant: %s", path, got, want)
+			}
+		})
+	}
+}
+
+func TestNewLifecycleEvent(t *testing.T) {
+	ev := NewLifecycleEvent(LifecycleStarting, "g1")
+	if ev.Type != LifecycleStarting || ev.AgentID != "g1" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+	if _, err := time.Parse(time.RFC3339, ev.Timestamp); err != nil {
+		t.Errorf("timestamp %q is not RFC3339: %v", ev.Timestamp, err)
+	}
+	if got := TopicAgentLifecycle("g1"); got != "agent.lifecycle.g1" {
+		t.Errorf("expected agent.lifecycle.g1, got %s", got)
+	}
+}
@@ -114,3 +114,69 @@ func TestTopicNames(t *testing.T) {
 		t.Errorf("expected host.ipc.g1, got %s", got)
 	}
 }
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestClientReconnectRestoresDelivery(t *testing.T) {
+	cfg := config.NATSConfig{DataDir: t.TempDir()}
+	bus, err := NewForTest(cfg)
+	if err != nil {
+		t.Fatalf("failed to create bus: %v", err)
+	}
+
+	client, err := NewClient(bus)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	received := make(chan string, 10)
+	if _, err := client.Subscribe("test.reconnect", func(msg *nats.Msg) {
+		received <- string(msg.Data)
+	}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := client.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	// Take the server down and bring it back on the same port.
+	port := bus.Port()
+	bus.Close()
+	waitFor(t, "disconnect", func() bool { return !client.Connected() })
+
+	// Published while disconnected: buffered and sent after reconnect.
+	if err := client.Publish("test.reconnect", []byte("buffered")); err != nil {
+		t.Fatalf("publish while disconnected: %v", err)
+	}
+
+	bus, err = newBus(cfg, port)
+	if err != nil {
+		t.Fatalf("failed to restart bus: %v", err)
+	}
+	defer bus.Close()
+	waitFor(t, "reconnect", client.Connected)
+
+	if err := client.Publish("test.reconnect", []byte("after")); err != nil {
+		t.Fatalf("publish after reconnect: %v", err)
+	}
+	for _, want := range []string{"buffered", "after"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -15,6 +16,9 @@ import (
 // and proceed (publishing to the input topic anyway).
 var ErrReadyTimeout = errors.New("agent ready timeout")
 
+// DefaultReadyTimeout is how long Wait waits when given no timeout.
+const DefaultReadyTimeout = 30 * time.Second
+
 // ReadyWaiter blocks the caller until an agent container is ready to
 // receive input on its NATS subjects. It subscribes to TopicAgentReady
 // before the container is started and resolves when the agent-runner
@@ -29,20 +33,44 @@ var ErrReadyTimeout = errors.New("agent ready timeout")
 // the subscription is registered with the broker first; otherwise the
 // agent could publish ready into the void and the waiter would block
 // until timeout. Callers MUST Close the waiter when done (defer is fine).
+//
+// The waiter also records the agent's Capabilities. An agent that announces
+// capabilities without CapReadySignal will never send the ready marker, so
+// its capabilities message (sent after its subscriptions are registered)
+// resolves the wait instead. Agents that announce nothing get the
+// conservative path: wait for ready until the timeout.
 type ReadyWaiter struct {
 	sub     *nats.Subscription
 	ch      chan struct{}
 	agentID string
+
+	mu   sync.Mutex
+	caps *Capabilities
 }
 
-// PrepareReadyWaiter subscribes to the agent's ready topic and returns a
-// waiter that resolves on the first ready signal.
+// PrepareReadyWaiter subscribes to the agent's ready and capabilities
+// topics and returns a waiter that resolves on the first ready signal.
 func PrepareReadyWaiter(client *Client, agentID string) (*ReadyWaiter, error) {
-	ch := make(chan struct{}, 1)
-	sub, err := client.Subscribe(TopicAgentReady(agentID), func(*nats.Msg) {
-		select {
-		case ch <- struct{}{}:
-		default:
+	w := &ReadyWaiter{ch: make(chan struct{}, 1), agentID: agentID}
+	// One subscription for both subjects keeps them in publish order, so
+	// capabilities are recorded before the ready signal resolves the wait.
+	ready, capsTopic := TopicAgentReady(agentID), TopicAgentCapabilities(agentID)
+	sub, err := client.Subscribe(topicAgentStartup(agentID), func(msg *nats.Msg) {
+		switch msg.Subject {
+		case ready:
+			w.resolve()
+		case capsTopic:
+			caps, err := ParseCapabilities(msg.Data)
+			if err != nil {
+				slog.Warn("invalid agent capabilities", "agent", agentID, "error", err)
+				return
+			}
+			w.mu.Lock()
+			w.caps = caps
+			w.mu.Unlock()
+			if !caps.Has(CapReadySignal) {
+				w.resolve()
+			}
 		}
 	})
 	if err != nil {
@@ -55,13 +83,32 @@ func PrepareReadyWaiter(client *Client, agentID string) (*ReadyWaiter, error) {
 		_ = sub.Unsubscribe()
 		return nil, fmt.Errorf("flush ready subscription: %w", err)
 	}
-	return &ReadyWaiter{sub: sub, ch: ch, agentID: agentID}, nil
+	w.sub = sub
+	return w, nil
+}
+
+func (w *ReadyWaiter) resolve() {
+	select {
+	case w.ch <- struct{}{}:
+	default:
+	}
+}
+
+// Capabilities returns what the agent announced, or nil if it announced
+// nothing (yet).
+func (w *ReadyWaiter) Capabilities() *Capabilities {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.caps
 }
 
 // Wait blocks until the agent is ready, the timeout elapses, or ctx is
-// cancelled. Returns nil on success, ErrReadyTimeout on timeout, or
-// ctx.Err() on cancellation.
+// cancelled. A zero timeout means DefaultReadyTimeout. Returns nil on
+// success, ErrReadyTimeout on timeout, or ctx.Err() on cancellation.
 func (w *ReadyWaiter) Wait(ctx context.Context, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultReadyTimeout
+	}
 	select {
 	case <-w.ch:
 		slog.Info("agent container ready", "agent", w.agentID)
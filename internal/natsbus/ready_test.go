@@ -141,3 +141,131 @@ func TestReadyWaiter_NoRaceWhenAgentsStartSimultaneously(t *testing.T) {
 		t.Errorf("slow agent received %d messages, want 1 — race in readiness check (host published before agent subscribed)", got)
 	}
 }
+
+// newReadyTestWaiter returns a waiter for agentID and a raw connection that
+// plays the agent side.
+func newReadyTestWaiter(t *testing.T, agentID string) (*ReadyWaiter, *nats.Conn) {
+	t.Helper()
+	bus, err := NewForTest(config.NATSConfig{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("new bus: %v", err)
+	}
+	t.Cleanup(bus.Close)
+	hostClient, err := NewClient(bus)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	t.Cleanup(hostClient.Close)
+
+	w, err := PrepareReadyWaiter(hostClient, agentID)
+	if err != nil {
+		t.Fatalf("prepare waiter: %v", err)
+	}
+	t.Cleanup(w.Close)
+
+	nc, err := nats.Connect(bus.ClientURL())
+	if err != nil {
+		t.Fatalf("agent connect: %v", err)
+	}
+	t.Cleanup(nc.Close)
+	return w, nc
+}
+
+func TestReadyWaiter_Wait(t *testing.T) {
+	t.Run("already ready", func(t *testing.T) {
+		w, nc := newReadyTestWaiter(t, "a1")
+		_ = nc.Publish(TopicAgentReady("a1"), []byte(`{"status":"ready"}`))
+		_ = nc.Flush()
+		// A zero timeout falls back to DefaultReadyTimeout instead of
+		// timing out at once.
+		if err := w.Wait(context.Background(), 0); err != nil {
+			t.Fatalf("Wait = %v, want nil", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		w, _ := newReadyTestWaiter(t, "a1")
+		start := time.Now()
+		if err := w.Wait(context.Background(), 100*time.Millisecond); err != ErrReadyTimeout {
+			t.Fatalf("Wait = %v, want ErrReadyTimeout", err)
+		}
+		if d := time.Since(start); d < 100*time.Millisecond {
+			t.Errorf("Wait returned after %v, before the timeout", d)
+		}
+	})
+
+	t.Run("context cancelled", func(t *testing.T) {
+		w, _ := newReadyTestWaiter(t, "a1")
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		if err := w.Wait(ctx, 5*time.Second); err != context.Canceled {
+			t.Fatalf("Wait = %v, want context.Canceled", err)
+		}
+	})
+}
+
+func TestReadyWaiter_CapabilitiesGateReadySignal(t *testing.T) {
+	t.Run("announced without ready_signal", func(t *testing.T) {
+		w, nc := newReadyTestWaiter(t, "a1")
+		_ = nc.Publish(TopicAgentCapabilities("a1"), []byte(`{"version":1,"features":["history_injection"]}`))
+
+		// No ready marker will come; the capabilities message resolves the wait.
+		if err := w.Wait(context.Background(), 5*time.Second); err != nil {
+			t.Fatalf("Wait = %v, want nil", err)
+		}
+		if caps := w.Capabilities(); !caps.Has(CapHistoryInjection) || caps.Has(CapReadySignal) {
+			t.Errorf("capabilities = %+v", caps)
+		}
+	})
+
+	t.Run("announced with ready_signal", func(t *testing.T) {
+		w, nc := newReadyTestWaiter(t, "a1")
+		_ = nc.Publish(TopicAgentCapabilities("a1"), []byte(`{"version":1,"features":["ready_signal"]}`))
+		_ = nc.Flush()
+
+		if err := w.Wait(context.Background(), 200*time.Millisecond); err != ErrReadyTimeout {
+			t.Fatalf("Wait before ready = %v, want ErrReadyTimeout", err)
+		}
+		_ = nc.Publish(TopicAgentReady("a1"), []byte(`{"status":"ready"}`))
+		if err := w.Wait(context.Background(), 5*time.Second); err != nil {
+			t.Fatalf("Wait after ready = %v, want nil", err)
+		}
+		if !w.Capabilities().Has(CapReadySignal) {
+			t.Error("capabilities not recorded")
+		}
+	})
+
+	t.Run("nothing announced", func(t *testing.T) {
+		w, nc := newReadyTestWaiter(t, "a1")
+		// Malformed capabilities count as none: keep waiting for ready.
+		_ = nc.Publish(TopicAgentCapabilities("a1"), []byte(`{"features":["ready_signal"]}`))
+		_ = nc.Flush()
+		if err := w.Wait(context.Background(), 200*time.Millisecond); err != ErrReadyTimeout {
+			t.Fatalf("Wait = %v, want ErrReadyTimeout", err)
+		}
+		_ = nc.Publish(TopicAgentReady("a1"), []byte(`{"status":"ready"}`))
+		if err := w.Wait(context.Background(), 5*time.Second); err != nil {
+			t.Fatalf("Wait after ready = %v, want nil", err)
+		}
+		if caps := w.Capabilities(); caps != nil {
+			t.Errorf("capabilities = %+v, want nil", caps)
+		}
+	})
+}
+
+func TestParseCapabilities(t *testing.T) {
+	caps, err := ParseCapabilities([]byte(`{"version":1,"features":["ready_signal","teleport"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caps.Has(CapReadySignal) || caps.Has(CapModelFallback) || !caps.Has("teleport") {
+		t.Errorf("capabilities = %+v", caps)
+	}
+	if _, err := ParseCapabilities([]byte(`{"features":[]}`)); err == nil {
+		t.Error("expected error for missing version")
+	}
+	var none *Capabilities
+	if none.Has(CapReadySignal) {
+		t.Error("nil capabilities reported a feature")
+	}
+}
@@ -1,23 +1,98 @@
 package natsbus
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
+
+// Topic patterns for NATS pub/sub communication. Every helper applies the
+// deployment's subject prefix, so callers never build subjects by hand.
+
+// subjectPrefix namespaces all subjects; see SetSubjectPrefix.
+var subjectPrefix string
+
+// SetSubjectPrefix places every praktor subject under prefix, e.g. "acme"
+// turns agent.<id>.output into acme.agent.<id>.output, so tenants sharing a
+// NATS server never see each other's traffic. It must be called before any
+// client subscribes; "" (the default) keeps the unprefixed subjects.
+func SetSubjectPrefix(prefix string) {
+	subjectPrefix = strings.TrimSuffix(prefix, ".")
+}
+
+// SubjectPrefix returns the prefix set by SetSubjectPrefix. It is passed to
+// agent containers as NATS_SUBJECT_PREFIX.
+func SubjectPrefix() string {
+	return subjectPrefix
+}
 
-// Topic patterns for NATS pub/sub communication.
+func subject(format string, args ...any) string {
+	s := fmt.Sprintf(format, args...)
+	if subjectPrefix == "" {
+		return s
+	}
+	return subjectPrefix + "." + s
+}
+
+// AgentFromSubject returns the agent id of an agent.<id>.<kind> or
+// host.ipc.<id> subject, or "" for any other subject.
+func AgentFromSubject(s string) string {
+	if subjectPrefix != "" {
+		var ok bool
+		if s, ok = strings.CutPrefix(s, subjectPrefix+"."); !ok {
+			return ""
+		}
+	}
+	if id, ok := strings.CutPrefix(s, "host.ipc."); ok {
+		return id
+	}
+	if rest, ok := strings.CutPrefix(s, "agent."); ok {
+		if i := strings.LastIndexByte(rest, '.'); i > 0 {
+			return rest[:i]
+		}
+	}
+	return ""
+}
+
+// ParseAgentOutputSubject returns the agent id of an agent.<id>.output
+// subject. The id must be a single non-empty token without wildcards;
+// anything else, including other agent subjects, reports false.
+func ParseAgentOutputSubject(s string) (string, bool) {
+	if subjectPrefix != "" {
+		var ok bool
+		if s, ok = strings.CutPrefix(s, subjectPrefix+"."); !ok {
+			return "", false
+		}
+	}
+	rest, ok := strings.CutPrefix(s, "agent.")
+	if !ok {
+		return "", false
+	}
+	id, ok := strings.CutSuffix(rest, ".output")
+	if !ok || id == "" || strings.ContainsAny(id, ".*> \t") {
+		return "", false
+	}
+	return id, true
+}
 
 func TopicAgentInput(agentID string) string {
-	return fmt.Sprintf("agent.%s.input", agentID)
+	return subject("agent.%s.input", agentID)
 }
 
 func TopicAgentOutput(agentID string) string {
-	return fmt.Sprintf("agent.%s.output", agentID)
+	return subject("agent.%s.output", agentID)
+}
+
+// TopicAgentOutputAll matches the output of every agent.
+func TopicAgentOutputAll() string {
+	return subject("agent.*.output")
 }
 
 func TopicAgentControl(agentID string) string {
-	return fmt.Sprintf("agent.%s.control", agentID)
+	return subject("agent.%s.control", agentID)
 }
 
 func TopicAgentRoute(agentID string) string {
-	return fmt.Sprintf("agent.%s.route", agentID)
+	return subject("agent.%s.route", agentID)
 }
 
 // TopicAgentReady is published by the agent-runner once its NATS
@@ -25,44 +100,71 @@ func TopicAgentRoute(agentID string) string {
 // this subject as a per-agent readiness signal (replaces the global
 // NumClients() polling that races on simultaneous starts).
 func TopicAgentReady(agentID string) string {
-	return fmt.Sprintf("agent.%s.ready", agentID)
+	return subject("agent.%s.ready", agentID)
+}
+
+// TopicAgentCapabilities carries the Capabilities an agent-runner
+// announces at startup.
+func TopicAgentCapabilities(agentID string) string {
+	return subject("agent.%s.capabilities", agentID)
+}
+
+// topicAgentStartup matches every agent.<id>.<kind> subject of one agent,
+// so ready and capabilities arrive on a single, ordered subscription.
+func topicAgentStartup(agentID string) string {
+	return subject("agent.%s.*", agentID)
+}
+
+// TopicAgentLifecycle carries LifecycleEvent payloads for external
+// supervisors. Subscribe to TopicAgentLifecycleAll for every agent.
+func TopicAgentLifecycle(agentID string) string {
+	return subject("agent.lifecycle.%s", agentID)
+}
+
+func TopicAgentLifecycleAll() string {
+	return subject("agent.lifecycle.*")
 }
 
 func TopicIPC(agentID string) string {
-	return fmt.Sprintf("host.ipc.%s", agentID)
+	return subject("host.ipc.%s", agentID)
+}
+
+// TopicIPCAll matches the IPC commands of every agent.
+func TopicIPCAll() string {
+	return subject("host.ipc.*")
 }
 
 func TopicSwarmOrchestrate(swarmID string) string {
-	return fmt.Sprintf("swarm.%s.orchestrate", swarmID)
+	return subject("swarm.%s.orchestrate", swarmID)
 }
 
 func TopicSwarmAgent(swarmID, role string) string {
-	return fmt.Sprintf("swarm.%s.%s", swarmID, role)
+	return subject("swarm.%s.%s", swarmID, role)
 }
 
 func TopicSwarmResults(swarmID string) string {
-	return fmt.Sprintf("swarm.%s.results", swarmID)
+	return subject("swarm.%s.results", swarmID)
 }
 
 func TopicSwarmChat(swarmID, groupID string) string {
-	return fmt.Sprintf("swarm.%s.chat.%s", swarmID, groupID)
+	return subject("swarm.%s.chat.%s", swarmID, groupID)
 }
 
 func TopicEventsSwarmID(swarmID string) string {
-	return fmt.Sprintf("events.swarm.%s", swarmID)
+	return subject("events.swarm.%s", swarmID)
 }
 
 func TopicEventsAgent(agentID string) string {
-	return fmt.Sprintf("events.agent.%s", agentID)
-}
-
-const (
-	TopicEventsAll           = "events.>"
-	TopicEventsTask          = "events.task.*"
-	TopicEventsTaskExecuted  = "events.task.executed"
-	TopicEventsSwarm         = "events.swarm.*"
-	TopicEventsSecret        = "events.secret.*"
-	TopicEventsSecretCreated = "events.secret.created"
-	TopicEventsSecretUpdated = "events.secret.updated"
-	TopicEventsSecretDeleted = "events.secret.deleted"
-)
+	return subject("events.agent.%s", agentID)
+}
+
+func TopicEventsAll() string           { return subject("events.>") }
+func TopicEventsTask() string          { return subject("events.task.*") }
+func TopicEventsTaskExecuted() string  { return subject("events.task.executed") }
+func TopicEventsTaskBacklog() string   { return subject("events.task.backlog") }
+func TopicEventsTaskFailed() string    { return subject("events.task.failed") }
+func TopicEventsSwarm() string         { return subject("events.swarm.*") }
+func TopicEventsSecret() string        { return subject("events.secret.*") }
+func TopicEventsSecretCreated() string { return subject("events.secret.created") }
+func TopicEventsSecretUpdated() string { return subject("events.secret.updated") }
+func TopicEventsSecretDeleted() string { return subject("events.secret.deleted") }
@@ -0,0 +1,122 @@
+package natsbus
+
+import "testing"
+
+// withPrefix sets the subject prefix for the duration of a test.
+func withPrefix(t *testing.T, prefix string) {
+	t.Helper()
+	SetSubjectPrefix(prefix)
+	t.Cleanup(func() { SetSubjectPrefix("") })
+}
+
+func allTopics() map[string]string {
+	return map[string]string{
+		"agent.a1.input":        TopicAgentInput("a1"),
+		"agent.a1.output":       TopicAgentOutput("a1"),
+		"agent.*.output":        TopicAgentOutputAll(),
+		"agent.a1.control":      TopicAgentControl("a1"),
+		"agent.a1.route":        TopicAgentRoute("a1"),
+		"agent.a1.ready":        TopicAgentReady("a1"),
+		"agent.lifecycle.a1":    TopicAgentLifecycle("a1"),
+		"agent.lifecycle.*":     TopicAgentLifecycleAll(),
+		"host.ipc.a1":           TopicIPC("a1"),
+		"host.ipc.*":            TopicIPCAll(),
+		"swarm.s1.orchestrate":  TopicSwarmOrchestrate("s1"),
+		"swarm.s1.coder":        TopicSwarmAgent("s1", "coder"),
+		"swarm.s1.results":      TopicSwarmResults("s1"),
+		"swarm.s1.chat.g1":      TopicSwarmChat("s1", "g1"),
+		"events.swarm.s1":       TopicEventsSwarmID("s1"),
+		"events.agent.a1":       TopicEventsAgent("a1"),
+		"events.>":              TopicEventsAll(),
+		"events.task.*":         TopicEventsTask(),
+		"events.task.executed":  TopicEventsTaskExecuted(),
+		"events.task.failed":    TopicEventsTaskFailed(),
+		"events.swarm.*":        TopicEventsSwarm(),
+		"events.secret.*":       TopicEventsSecret(),
+		"events.secret.created": TopicEventsSecretCreated(),
+		"events.secret.updated": TopicEventsSecretUpdated(),
+		"events.secret.deleted": TopicEventsSecretDeleted(),
+	}
+}
+
+func TestTopicsWithoutPrefix(t *testing.T) {
+	for want, got := range allTopics() {
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestTopicsHonorPrefix(t *testing.T) {
+	withPrefix(t, "acme.prod.")
+	if p := SubjectPrefix(); p != "acme.prod" {
+		t.Errorf("SubjectPrefix() = %q, want the trailing dot trimmed", p)
+	}
+	for plain, got := range allTopics() {
+		if want := "acme.prod." + plain; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestAgentFromSubject(t *testing.T) {
+	cases := map[string]string{
+		"agent.a1.output":  "a1",
+		"agent.a.b.output": "a.b",
+		"host.ipc.a1":      "a1",
+		"agent.a1":         "",
+		"events.agent.a1":  "",
+		"other.agent.a1.x": "",
+	}
+	for subj, want := range cases {
+		if got := AgentFromSubject(subj); got != want {
+			t.Errorf("AgentFromSubject(%q) = %q, want %q", subj, got, want)
+		}
+	}
+
+	withPrefix(t, "acme")
+	if got := AgentFromSubject(TopicAgentOutput("a1")); got != "a1" {
+		t.Errorf("prefixed output subject: got %q", got)
+	}
+	if got := AgentFromSubject(TopicIPC("a1")); got != "a1" {
+		t.Errorf("prefixed ipc subject: got %q", got)
+	}
+	if got := AgentFromSubject("agent.a1.output"); got != "" {
+		t.Errorf("unprefixed subject under a prefix resolved to %q", got)
+	}
+}
+
+func TestParseAgentOutputSubject(t *testing.T) {
+	for _, id := range []string{"a1", "general", "swarm-3f2a-coder"} {
+		if got, ok := ParseAgentOutputSubject(TopicAgentOutput(id)); !ok || got != id {
+			t.Errorf("ParseAgentOutputSubject(%q) = %q, %v, want %q", TopicAgentOutput(id), got, ok, id)
+		}
+	}
+
+	for _, subj := range []string{
+		"agent.a.b.output", // dotted id: would be a different agent's subject
+		"agent..output",
+		"agent.output",
+		"agent.*.output",
+		"agent.>.output",
+		"agent.a1.input",
+		"agent.a1.output.x",
+		"agent.lifecycle.a1",
+		"host.ipc.a1",
+		"events.agent.a1",
+		"xagent.a1.output",
+		"",
+	} {
+		if got, ok := ParseAgentOutputSubject(subj); ok {
+			t.Errorf("ParseAgentOutputSubject(%q) = %q, want rejection", subj, got)
+		}
+	}
+
+	withPrefix(t, "acme")
+	if got, ok := ParseAgentOutputSubject(TopicAgentOutput("a1")); !ok || got != "a1" {
+		t.Errorf("prefixed output subject: got %q, %v", got, ok)
+	}
+	if _, ok := ParseAgentOutputSubject("agent.a1.output"); ok {
+		t.Error("unprefixed subject accepted under a prefix")
+	}
+}
@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+const sendTimeout = 10 * time.Second
+
+// discordMaxContent is the longest content Discord accepts in a message.
+const discordMaxContent = 2000
+
+// Backend delivers notifications to one destination.
+type Backend interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// newBackend builds the backend a validated config entry describes.
+func newBackend(cfg config.NotifyBackend, client *http.Client) (Backend, error) {
+	switch cfg.Type {
+	case "slack":
+		return &webhook{url: cfg.URL, client: client, body: slackBody}, nil
+	case "discord":
+		return &webhook{url: cfg.URL, client: client, body: discordBody}, nil
+	case "email":
+		return &email{cfg: cfg.SMTP, send: smtp.SendMail}, nil
+	}
+	return nil, fmt.Errorf("unknown notification backend type %q", cfg.Type)
+}
+
+// webhook posts a JSON message to a chat service's incoming webhook.
+type webhook struct {
+	url    string
+	client *http.Client
+	body   func(Notification) any
+}
+
+// slackBody uses Slack's mrkdwn, where *text* is bold.
+func slackBody(n Notification) any {
+	return map[string]string{"text": "*" + n.Title + "*\n" + n.Text}
+}
+
+func discordBody(n Notification) any {
+	content := "**" + n.Title + "**\n" + n.Text
+	if r := []rune(content); len(r) > discordMaxContent {
+		content = string(r[:discordMaxContent-1]) + "…"
+	}
+	return map[string]string{"content": content}
+}
+
+func (w *webhook) Send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(w.body(n))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// email sends a plain-text mail through an SMTP server. net/smtp has no
+// context support, so a send runs to the server's own timeouts.
+type email struct {
+	cfg  config.SMTPConfig
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (e *email) Send(_ context.Context, n Notification) error {
+	port := e.cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)
+	}
+	addr := net.JoinHostPort(e.cfg.Host, strconv.Itoa(port))
+	if err := e.send(addr, auth, e.cfg.From, e.cfg.To, e.message(n)); err != nil {
+		return fmt.Errorf("send mail: %w", err)
+	}
+	return nil
+}
+
+func (e *email) message(n Notification) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: [praktor] %s\r\n", headerSafe(n.Title))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(n.Text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// headerSafe keeps event text (task names, agent ids) from adding headers.
+func headerSafe(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
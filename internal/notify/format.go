@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mtzanidakis/praktor/internal/events"
+)
+
+// Notification is an event rendered for people; each backend shapes it into
+// its own message format.
+type Notification struct {
+	Event string // event type, e.g. agent_error
+	Title string
+	Text  string
+}
+
+// Format renders a raw event payload. ok is false for event types that
+// notifications don't cover, or payloads that don't decode.
+func Format(data []byte) (n Notification, ok bool) {
+	var h events.Header
+	if json.Unmarshal(data, &h) != nil {
+		return Notification{}, false
+	}
+	n.Event = h.Type
+
+	switch h.Type {
+	case events.TypeAgentError:
+		var ev events.AgentError
+		if json.Unmarshal(data, &ev) != nil {
+			return Notification{}, false
+		}
+		n.Title = fmt.Sprintf("Agent %s failed", ev.AgentID)
+		n.Text = "Reason: " + ev.Reason
+		if ev.Error != "" {
+			n.Text += "\n" + ev.Error
+		}
+		if ev.TraceID != "" {
+			n.Text += "\nTrace: " + ev.TraceID
+		}
+	case events.TypeTaskFailed:
+		var ev events.TaskFailed
+		if json.Unmarshal(data, &ev) != nil {
+			return Notification{}, false
+		}
+		n.Title = fmt.Sprintf("Scheduled task %q failed", ev.Name)
+		n.Text = fmt.Sprintf("Agent: %s\nError: %s\nConsecutive failures: %d", ev.AgentID, ev.Error, ev.ConsecutiveFailures)
+		if ev.Paused {
+			n.Text += "\nThe task has been paused."
+		}
+	case "swarm_completed":
+		var ev events.Swarm[events.SwarmFinishedData]
+		if json.Unmarshal(data, &ev) != nil {
+			return Notification{}, false
+		}
+		n.Title = fmt.Sprintf("Swarm %s completed", ev.SwarmID)
+		n.Text = fmt.Sprintf("%d of %d agents succeeded", ev.Data.Succeeded, ev.Data.Total)
+	case events.TypeSwarmFailed:
+		// Carries SwarmFailedData when the run never started, or
+		// SwarmFinishedData when its agents failed; read both.
+		var ev struct {
+			SwarmID string `json:"swarm_id"`
+			Data    struct {
+				events.SwarmFailedData
+				events.SwarmFinishedData
+			} `json:"data"`
+		}
+		if json.Unmarshal(data, &ev) != nil {
+			return Notification{}, false
+		}
+		n.Title = fmt.Sprintf("Swarm %s failed", ev.SwarmID)
+		d := ev.Data
+		switch {
+		case d.Error != "":
+			n.Text = d.Error
+		case d.Reason != "":
+			n.Text = "Reason: " + d.Reason
+		default:
+			n.Text = fmt.Sprintf("%d of %d agents succeeded", d.Succeeded, d.Total)
+		}
+	default:
+		return Notification{}, false
+	}
+	n.Text = strings.TrimSpace(n.Text)
+	return n, true
+}
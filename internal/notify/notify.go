@@ -0,0 +1,85 @@
+// Package notify sends selected events from the NATS event stream to the
+// notification backends in config: Slack and Discord incoming webhooks, and
+// SMTP email. Each backend is routed the event types it lists.
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/nats-io/nats.go"
+)
+
+type route struct {
+	name    string
+	backend Backend
+}
+
+// Notifier fans events out to the backends routed their type.
+type Notifier struct {
+	bus    *natsbus.Bus
+	routes map[string][]route // event type → backends
+}
+
+// New builds a notifier for the configured backends.
+func New(bus *natsbus.Bus, cfg config.NotificationsConfig) (*Notifier, error) {
+	client := &http.Client{Timeout: sendTimeout}
+	n := &Notifier{bus: bus, routes: make(map[string][]route)}
+	for _, bc := range cfg.Backends {
+		b, err := newBackend(bc, client)
+		if err != nil {
+			return nil, err
+		}
+		n.add(bc.Name, b, bc.Events)
+	}
+	return n, nil
+}
+
+// add routes eventTypes (empty = all of config.NotifyEventTypes) to b.
+func (n *Notifier) add(name string, b Backend, eventTypes []string) {
+	if len(eventTypes) == 0 {
+		eventTypes = config.NotifyEventTypes
+	}
+	for _, t := range eventTypes {
+		n.routes[t] = append(n.routes[t], route{name: name, backend: b})
+	}
+}
+
+// Run subscribes to the event stream and sends notifications until ctx is
+// cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	client, err := natsbus.NewClient(n.bus)
+	if err != nil {
+		slog.Error("notifications nats client failed", "error", err)
+		return
+	}
+	defer client.Close()
+	if _, err := client.Subscribe(natsbus.TopicEventsAll(), func(msg *nats.Msg) {
+		n.handle(ctx, msg.Data)
+	}); err != nil {
+		slog.Error("notifications subscribe failed", "error", err)
+		return
+	}
+	<-ctx.Done()
+}
+
+// handle formats one event and sends it to each of its backends in the
+// background, so a slow backend holds up neither the others nor NATS.
+func (n *Notifier) handle(ctx context.Context, data []byte) {
+	note, ok := Format(data)
+	if !ok {
+		return
+	}
+	for _, r := range n.routes[note.Event] {
+		go func() {
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			defer cancel()
+			if err := r.backend.Send(sendCtx, note); err != nil {
+				slog.Warn("notification failed", "backend", r.name, "event", note.Event, "error", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,200 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/events"
+)
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestFormat(t *testing.T) {
+	agentErr := events.NewAgentError("coder", "execution_failed")
+	agentErr.Error = "container exited"
+
+	tests := []struct {
+		name  string
+		event any
+		title string
+		text  []string
+	}{
+		{"agent error", agentErr, "Agent coder failed", []string{"Reason: execution_failed", "container exited"}},
+		{
+			"task failed", events.NewTaskFailed("t1", "nightly", "coder", "timeout", 3, true),
+			`Scheduled task "nightly" failed`, []string{"Error: timeout", "Consecutive failures: 3", "paused"},
+		},
+		{
+			"swarm completed", events.NewSwarmFinished("s1", "completed", events.SwarmFinishedData{Succeeded: 3, Total: 3}),
+			"Swarm s1 completed", []string{"3 of 3 agents succeeded"},
+		},
+		{
+			"swarm failed to plan", events.NewSwarmFailed("s2", events.SwarmFailedData{Error: "cycle in graph"}),
+			"Swarm s2 failed", []string{"cycle in graph"},
+		},
+		{
+			"swarm failed run", events.NewSwarmFinished("s3", "failed", events.SwarmFinishedData{Succeeded: 1, Total: 3}),
+			"Swarm s3 failed", []string{"1 of 3 agents succeeded"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, ok := Format(mustJSON(t, tt.event))
+			if !ok {
+				t.Fatal("event not formatted")
+			}
+			if n.Title != tt.title {
+				t.Errorf("title = %q, want %q", n.Title, tt.title)
+			}
+			for _, want := range tt.text {
+				if !strings.Contains(n.Text, want) {
+					t.Errorf("text %q does not contain %q", n.Text, want)
+				}
+			}
+		})
+	}
+
+	if _, ok := Format(mustJSON(t, events.NewAgentStarted("coder"))); ok {
+		t.Error("agent_started should not be formatted")
+	}
+	if _, ok := Format([]byte("not json")); ok {
+		t.Error("invalid payload should not be formatted")
+	}
+}
+
+func TestWebhookBackends(t *testing.T) {
+	note := Notification{Event: events.TypeAgentError, Title: "Agent coder failed", Text: "Reason: boom"}
+	tests := []struct {
+		typ, field, want string
+	}{
+		{"slack", "text", "*Agent coder failed*\nReason: boom"},
+		{"discord", "content", "**Agent coder failed**\nReason: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			var got map[string]string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("got %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+				}
+				body, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(body, &got); err != nil {
+					t.Errorf("decode body %q: %v", body, err)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			b, err := newBackend(config.NotifyBackend{Type: tt.typ, URL: srv.URL}, srv.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := b.Send(context.Background(), note); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			if got[tt.field] != tt.want {
+				t.Errorf("%s = %q, want %q", tt.field, got[tt.field], tt.want)
+			}
+		})
+	}
+
+	t.Run("error status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "gone", http.StatusNotFound)
+		}))
+		defer srv.Close()
+		b, _ := newBackend(config.NotifyBackend{Type: "slack", URL: srv.URL}, srv.Client())
+		if err := b.Send(context.Background(), note); err == nil {
+			t.Error("expected an error for a 404 response")
+		}
+	})
+}
+
+func TestDiscordTruncates(t *testing.T) {
+	body := discordBody(Notification{Title: "t", Text: strings.Repeat("x", 3000)}).(map[string]string)
+	if n := len([]rune(body["content"])); n != discordMaxContent || !strings.HasSuffix(body["content"], "…") {
+		t.Errorf("content is %d runes, want %d ending in …", n, discordMaxContent)
+	}
+}
+
+func TestEmailBackend(t *testing.T) {
+	var addr, from string
+	var to []string
+	var msg []byte
+	e := &email{
+		cfg: config.SMTPConfig{Host: "smtp.example.com", From: "praktor@example.com", To: []string{"a@example.com", "b@example.com"}},
+		send: func(a string, _ smtp.Auth, f string, t []string, m []byte) error {
+			addr, from, to, msg = a, f, t, m
+			return nil
+		},
+	}
+	if err := e.Send(context.Background(), Notification{Title: "Swarm s1 failed\r\nBcc: x@example.com", Text: "line one\nline two"}); err != nil {
+		t.Fatal(err)
+	}
+	if addr != "smtp.example.com:587" || from != "praktor@example.com" || len(to) != 2 {
+		t.Errorf("sent to %s from %s to %v", addr, from, to)
+	}
+	s := string(msg)
+	if !strings.Contains(s, "Subject: [praktor] Swarm s1 failed  Bcc: x@example.com\r\n") {
+		t.Errorf("subject not sanitized:\n%s", s)
+	}
+	if !strings.HasSuffix(s, "\r\n\r\nline one\r\nline two\r\n") {
+		t.Errorf("unexpected body:\n%s", s)
+	}
+}
+
+type fakeBackend chan Notification
+
+func (f fakeBackend) Send(_ context.Context, n Notification) error {
+	f <- n
+	return nil
+}
+
+func TestRouting(t *testing.T) {
+	n := &Notifier{routes: make(map[string][]route)}
+	ops, all := make(fakeBackend, 4), make(fakeBackend, 4)
+	n.add("ops", ops, []string{events.TypeTaskFailed})
+	n.add("all", all, nil)
+
+	n.handle(context.Background(), mustJSON(t, events.NewAgentError("coder", "execution_failed")))
+	n.handle(context.Background(), mustJSON(t, events.NewTaskFailed("t1", "nightly", "coder", "timeout", 1, false)))
+	n.handle(context.Background(), mustJSON(t, events.NewAgentStarted("coder")))
+
+	recv := func(b fakeBackend) string {
+		select {
+		case got := <-b:
+			return got.Event
+		case <-time.After(5 * time.Second):
+			return ""
+		}
+	}
+	if got := recv(ops); got != events.TypeTaskFailed {
+		t.Errorf("ops got %q, want task_failed", got)
+	}
+	got := map[string]bool{recv(all): true, recv(all): true}
+	if !got[events.TypeAgentError] || !got[events.TypeTaskFailed] {
+		t.Errorf("all got %v, want agent_error and task_failed", got)
+	}
+	select {
+	case extra := <-ops:
+		t.Errorf("ops got unrouted %q", extra.Event)
+	case extra := <-all:
+		t.Errorf("all got unrouted %q", extra.Event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
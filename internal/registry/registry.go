@@ -1,12 +1,18 @@
 package registry
 
 import (
+	"cmp"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/container"
 	"github.com/mtzanidakis/praktor/internal/store"
 )
 
@@ -38,6 +44,13 @@ func (r *Registry) Update(agents map[string]config.AgentDefinition, defaults con
 }
 
 func (r *Registry) Sync() error {
+	if err := config.ValidateAgentIDs(slices.Collect(maps.Keys(r.agents))); err != nil {
+		return err
+	}
+	if err := validateWorkspaces(r.agents); err != nil {
+		return err
+	}
+
 	ids := make([]string, 0, len(r.agents))
 	for name, def := range r.agents {
 		ids = append(ids, name)
@@ -50,6 +63,8 @@ func (r *Registry) Sync() error {
 			Image:       def.Image,
 			Workspace:   def.Workspace,
 			ClaudeMD:    def.ClaudeMD,
+			Tags:        def.Tags,
+			Group:       def.Group,
 		}
 		if a.Workspace == "" {
 			a.Workspace = name
@@ -75,6 +90,34 @@ func (r *Registry) Sync() error {
 	return nil
 }
 
+// validateWorkspaces rejects workspace names that would escape the agents
+// directory or that sanitize to the same Docker volume name as another
+// agent's, which would silently share files between agents.
+func validateWorkspaces(agents map[string]config.AgentDefinition) error {
+	names := slices.Sorted(maps.Keys(agents))
+	owners := make(map[string]string, len(agents)) // volume name → agent
+	for _, name := range names {
+		ws := agents[name].Workspace
+		if ws == "" {
+			ws = name
+		}
+		if ws == "" || ws == "." || ws == ".." || strings.ContainsAny(ws, `/\`) {
+			return fmt.Errorf("agent %q: invalid workspace %q (must be non-empty and contain no path separators)", name, ws)
+		}
+
+		vol := "praktor-wk-" + container.SanitizeVolumeName(ws)
+		if other, ok := owners[vol]; ok {
+			otherWS := agents[other].Workspace
+			if otherWS == "" {
+				otherWS = other
+			}
+			return fmt.Errorf("agents %q (workspace %q) and %q (workspace %q) both map to volume %s; rename one workspace", other, otherWS, name, ws, vol)
+		}
+		owners[vol] = name
+	}
+	return nil
+}
+
 func (r *Registry) Get(agentID string) (*store.Agent, error) {
 	return r.store.GetAgent(agentID)
 }
@@ -108,6 +151,84 @@ func (r *Registry) ResolveImage(agentID string) string {
 	return r.cfg.Image
 }
 
+// ResolveClaudeVersion returns the Claude Code version to bake into the
+// agent's image, or "" to run the image's own.
+func (r *Registry) ResolveClaudeVersion(agentID string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if def, ok := r.agents[agentID]; ok && def.ClaudeVersion != "" {
+		return def.ClaudeVersion
+	}
+	return r.cfg.ClaudeVersion
+}
+
+// ResolveWorkspaceTemplate returns the host directory that seeds the agent's
+// empty workspace, from its definition or else the defaults ("" = none).
+func (r *Registry) ResolveWorkspaceTemplate(agentID string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if def, ok := r.agents[agentID]; ok && def.WorkspaceTemplate != "" {
+		return def.WorkspaceTemplate
+	}
+	return r.cfg.WorkspaceTemplate
+}
+
+// ResolveGreeting returns the prompt Telegram /start sends the agent and
+// the welcome message shown to the user first ("" = none), each from the
+// agent's definition or else the defaults.
+func (r *Registry) ResolveGreeting(agentID string) (prompt, welcome string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	prompt, welcome = r.cfg.GreetingPrompt, r.cfg.WelcomeMessage
+	if def, ok := r.agents[agentID]; ok {
+		prompt = cmp.Or(def.GreetingPrompt, prompt)
+		welcome = cmp.Or(def.WelcomeMessage, welcome)
+	}
+	return prompt, welcome
+}
+
+// ResolveIdleTimeout returns how long the agent may sit idle before its
+// container is stopped: its own idle_timeout if set, else the default.
+// Zero or negative means never.
+func (r *Registry) ResolveIdleTimeout(agentID string) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if def, ok := r.agents[agentID]; ok && def.IdleTimeout != 0 {
+		return def.IdleTimeout
+	}
+	return r.cfg.IdleTimeout
+}
+
+// SwarmMaxAge returns how long a swarm may be running without a
+// coordinator before it is failed as orphaned; 0 = never.
+func (r *Registry) SwarmMaxAge() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg.SwarmMaxAge
+}
+
+// SwarmRetention returns how long finished swarm runs are kept (0 =
+// forever), how many recent ones are always kept, and the sweep interval.
+func (r *Registry) SwarmRetention() (maxAge time.Duration, keepRecent int, interval time.Duration) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg.SwarmRetention, r.cfg.SwarmKeepRecent, r.cfg.SwarmSweepInterval
+}
+
+// FileFilter returns defaults.file_filter.
+func (r *Registry) FileFilter() config.FileFilterConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg.FileFilter
+}
+
+// ReadyTimeout is how long a starting agent gets to signal readiness.
+func (r *Registry) ReadyTimeout() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cfg.ReadyTimeout
+}
+
 func (r *Registry) GetClaudeMD(agentID string) (string, error) {
 	r.mu.RLock()
 	def, hasDef := r.agents[agentID]
@@ -154,6 +275,13 @@ func (r *Registry) GetGlobalClaudeMD() (string, error) {
 	return string(data), nil
 }
 
+// SaveGlobalClaudeMD writes global/CLAUDE.md, the instructions every agent
+// loads.
+func (r *Registry) SaveGlobalClaudeMD(content string) error {
+	path := filepath.Join(r.basePath, "global", "CLAUDE.md")
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
 func (r *Registry) FindByAgentMailInbox(inboxID string) (string, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -189,6 +317,24 @@ func (r *Registry) AgentDescriptions() map[string]string {
 	return descs
 }
 
+// GroupMembers returns the ids of the agents in group, sorted, or nil if no
+// agent is in it.
+func (r *Registry) GroupMembers(group string) []string {
+	if group == "" {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var ids []string
+	for name, def := range r.agents {
+		if def.Group == group {
+			ids = append(ids, name)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
 func (r *Registry) AgentPath(workspace string) string {
 	return filepath.Join(r.basePath, workspace)
 }
@@ -281,6 +427,9 @@ const agentMDTemplate = `# Agent Identity
 (Areas of specialization)
 `
 
+// GlobalClaudeMDTemplate is the global CLAUDE.md written when none exists.
+const GlobalClaudeMDTemplate = "# Global Instructions\n\nThis file is loaded by all agents.\n"
+
 const userMDTemplate = `# User Profile
 
 ## Name
@@ -310,8 +459,7 @@ func (r *Registry) ensureGlobalDirectory() error {
 
 	claudeMD := filepath.Join(dir, "CLAUDE.md")
 	if _, err := os.Stat(claudeMD); os.IsNotExist(err) {
-		defaultContent := "# Global Instructions\n\nThis file is loaded by all agents.\n"
-		if err := os.WriteFile(claudeMD, []byte(defaultContent), 0o644); err != nil {
+		if err := os.WriteFile(claudeMD, []byte(GlobalClaudeMDTemplate), 0o644); err != nil {
 			return fmt.Errorf("create global CLAUDE.md: %w", err)
 		}
 	}
@@ -2,8 +2,10 @@ package registry
 
 import (
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mtzanidakis/praktor/internal/config"
 	"github.com/mtzanidakis/praktor/internal/store"
@@ -66,6 +68,24 @@ func TestSync(t *testing.T) {
 	}
 }
 
+func TestSyncPersistsTags(t *testing.T) {
+	reg, s := newTestRegistry(t)
+	agents := map[string]config.AgentDefinition{
+		"general": {Workspace: "general", Tags: []string{"team:core"}},
+	}
+	if err := reg.Update(agents, config.DefaultsConfig{}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	a, err := s.GetAgent("general")
+	if err != nil || a == nil {
+		t.Fatalf("get general: %v", err)
+	}
+	if len(a.Tags) != 1 || a.Tags[0] != "team:core" {
+		t.Errorf("expected tags [team:core], got %v", a.Tags)
+	}
+}
+
 func TestSyncDeletesStale(t *testing.T) {
 	reg, s := newTestRegistry(t)
 
@@ -108,6 +128,71 @@ func TestResolveImage(t *testing.T) {
 	}
 }
 
+func TestGroupMembers(t *testing.T) {
+	reg, s := newTestRegistry(t)
+	agents := map[string]config.AgentDefinition{
+		"data":    {Workspace: "data", Group: "analytics"},
+		"analyst": {Workspace: "analyst", Group: "analytics"},
+		"solo":    {Workspace: "solo"},
+	}
+	if err := reg.Update(agents, config.DefaultsConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := reg.GroupMembers("analytics"); !slices.Equal(got, []string{"analyst", "data"}) {
+		t.Errorf("GroupMembers(analytics) = %v", got)
+	}
+	if got := reg.GroupMembers(""); got != nil {
+		t.Errorf("GroupMembers(\"\") = %v, want nil", got)
+	}
+	if a, _ := s.GetAgent("data"); a.Group != "analytics" {
+		t.Errorf("stored group = %q, want analytics", a.Group)
+	}
+}
+
+func TestResolveIdleTimeout(t *testing.T) {
+	reg, _ := newTestRegistry(t)
+	defaults := config.DefaultsConfig{IdleTimeout: 10 * time.Minute}
+	agents := map[string]config.AgentDefinition{
+		"general": {Workspace: "general"},
+		"warm":    {Workspace: "warm", IdleTimeout: 2 * time.Hour},
+		"pinned":  {Workspace: "pinned", IdleTimeout: -1},
+	}
+	if err := reg.Update(agents, defaults); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[string]time.Duration{
+		"general": 10 * time.Minute,
+		"warm":    2 * time.Hour,
+		"pinned":  -1,
+		"unknown": 10 * time.Minute,
+	} {
+		if got := reg.ResolveIdleTimeout(id); got != want {
+			t.Errorf("ResolveIdleTimeout(%s) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestResolveGreeting(t *testing.T) {
+	reg, _ := newTestRegistry(t)
+	defaults := config.DefaultsConfig{GreetingPrompt: "Hello!", WelcomeMessage: "Connecting you to an agent..."}
+	agents := map[string]config.AgentDefinition{
+		"general": {Workspace: "general"},
+		"coder":   {Workspace: "coder", GreetingPrompt: "Introduce yourself and list the repos you can work on.", WelcomeMessage: "Waking up the coder..."},
+	}
+	if err := reg.Update(agents, defaults); err != nil {
+		t.Fatal(err)
+	}
+
+	if p, w := reg.ResolveGreeting("coder"); p != agents["coder"].GreetingPrompt || w != "Waking up the coder..." {
+		t.Errorf("coder greeting = %q, %q", p, w)
+	}
+	if p, w := reg.ResolveGreeting("general"); p != "Hello!" || w != defaults.WelcomeMessage {
+		t.Errorf("general greeting = %q, %q, want the defaults", p, w)
+	}
+}
+
 func TestAgentDescriptions(t *testing.T) {
 	reg, _ := newTestRegistry(t)
 
@@ -225,3 +310,192 @@ func TestUserMDNotExist(t *testing.T) {
 		t.Errorf("expected empty content before sync, got %q", content)
 	}
 }
+
+func TestGlobalClaudeMDReadWrite(t *testing.T) {
+	reg, _ := newTestRegistry(t)
+
+	// Before sync, global/CLAUDE.md doesn't exist
+	content, err := reg.GetGlobalClaudeMD()
+	if err != nil || content != "" {
+		t.Fatalf("before sync: got %q, %v; want empty", content, err)
+	}
+
+	if err := reg.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if content, _ := reg.GetGlobalClaudeMD(); content != GlobalClaudeMDTemplate {
+		t.Errorf("after sync: got %q, want the template", content)
+	}
+
+	custom := "# Global Instructions\n\nAnswer in Greek.\n"
+	if err := reg.SaveGlobalClaudeMD(custom); err != nil {
+		t.Fatalf("save global claude md: %v", err)
+	}
+	content, err = reg.GetGlobalClaudeMD()
+	if err != nil {
+		t.Fatalf("get global claude md: %v", err)
+	}
+	if content != custom {
+		t.Errorf("expected %q, got %q", custom, content)
+	}
+}
+
+func TestSyncRejectsWorkspaceCollision(t *testing.T) {
+	reg, s := newTestRegistry(t)
+	reg.agents = map[string]config.AgentDefinition{
+		"alpha": {Workspace: "team.a"},
+		"beta":  {Workspace: "team a"},
+	}
+
+	err := reg.Sync()
+	if err == nil {
+		t.Fatal("expected sync to fail for colliding workspaces")
+	}
+	for _, want := range []string{`"alpha"`, `"beta"`, `"team.a"`, `"team a"`, "praktor-wk-team-a"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %s, got: %v", want, err)
+		}
+	}
+
+	agents, _ := s.ListAgents()
+	if len(agents) != 0 {
+		t.Errorf("expected no agents saved after failed sync, got %d", len(agents))
+	}
+}
+
+func TestSyncRejectsInvalidAgentIDs(t *testing.T) {
+	reg, s := newTestRegistry(t)
+	reg.agents = map[string]config.AgentDefinition{
+		"general":    {Workspace: "general"},
+		"team.alpha": {Workspace: "team-alpha"},
+		"Coder":      {Workspace: "coder"},
+	}
+
+	err := reg.Sync()
+	if err == nil {
+		t.Fatal("expected sync to fail for invalid agent ids")
+	}
+	for _, want := range []string{`"team.alpha"`, `"Coder"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %s, got: %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), `"general"`) {
+		t.Errorf("valid id listed as invalid: %v", err)
+	}
+	if agents, _ := s.ListAgents(); len(agents) != 0 {
+		t.Errorf("expected no agents saved after failed sync, got %d", len(agents))
+	}
+}
+
+func TestSyncRejectsPathSeparatorWorkspace(t *testing.T) {
+	reg, _ := newTestRegistry(t)
+	reg.agents = map[string]config.AgentDefinition{
+		"general": {Workspace: "../general"},
+	}
+
+	if err := reg.Sync(); err == nil || !strings.Contains(err.Error(), "invalid workspace") {
+		t.Fatalf("expected invalid workspace error, got %v", err)
+	}
+}
+
+func TestSecretUsage(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.New(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	agents := map[string]config.AgentDefinition{
+		"general": {
+			Workspace: "general",
+			Env:       map[string]string{"GITHUB_TOKEN": "secret:github", "PLAIN": "value"},
+		},
+		"coder": {
+			Workspace:     "coder",
+			Files:         []config.FileMount{{Secret: "ssh-key", Target: "/home/praktor/.ssh/id_ed25519"}},
+			EnvFileSecret: "dotenv",
+		},
+		"ops": {Workspace: "ops"},
+	}
+	reg := New(s, agents, config.DefaultsConfig{}, filepath.Join(dir, "agents"))
+	if err := reg.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	for _, sec := range []store.Secret{
+		{ID: "github", Name: "github", Kind: "string"},
+		{ID: "ssh-key", Name: "ssh-key", Kind: "file"},
+		{ID: "dotenv", Name: "dotenv", Kind: "file"},
+		{ID: "mcp-token", Name: "mcp-token", Kind: "string"},
+		{ID: "shared", Name: "shared", Kind: "string", Global: true},
+		{ID: "assigned", Name: "assigned", Kind: "string"},
+		{ID: "orphan", Name: "orphan", Kind: "string"},
+	} {
+		sec.Value, sec.Nonce = []byte("ciphertext"), []byte("nonce")
+		if err := s.SaveSecret(&sec); err != nil {
+			t.Fatalf("save secret %s: %v", sec.ID, err)
+		}
+	}
+	if err := s.AddAgentSecret("ops", "assigned"); err != nil {
+		t.Fatalf("assign secret: %v", err)
+	}
+	if err := s.AddAgentSecret("general", "github"); err != nil {
+		t.Fatalf("assign secret: %v", err)
+	}
+	ext := `{"mcp_servers":{"api":{"type":"http","url":"https://example.com","headers":{"Authorization":"secret:mcp-token","X-Missing":"secret:not-in-vault"}}}}`
+	if err := s.SetAgentExtensions("ops", ext); err != nil {
+		t.Fatalf("set extensions: %v", err)
+	}
+
+	usage, err := reg.SecretUsage()
+	if err != nil {
+		t.Fatalf("secret usage: %v", err)
+	}
+	if len(usage) != 7 {
+		t.Fatalf("expected usage for 7 secrets, got %d", len(usage))
+	}
+
+	tests := []struct {
+		id         string
+		assigned   []string
+		cfg        []string
+		extensions []string
+		agents     []string
+		unused     bool
+	}{
+		{id: "github", assigned: []string{"general"}, cfg: []string{"general"}, extensions: []string{}, agents: []string{"general"}},
+		{id: "ssh-key", assigned: []string{}, cfg: []string{"coder"}, extensions: []string{}, agents: []string{"coder"}},
+		{id: "dotenv", assigned: []string{}, cfg: []string{"coder"}, extensions: []string{}, agents: []string{"coder"}},
+		{id: "mcp-token", assigned: []string{}, cfg: []string{}, extensions: []string{"ops"}, agents: []string{"ops"}},
+		{id: "shared", assigned: []string{}, cfg: []string{}, extensions: []string{}, agents: []string{}},
+		{id: "assigned", assigned: []string{"ops"}, cfg: []string{}, extensions: []string{}, agents: []string{"ops"}},
+		{id: "orphan", assigned: []string{}, cfg: []string{}, extensions: []string{}, agents: []string{}, unused: true},
+	}
+	for _, tt := range tests {
+		u := usage[tt.id]
+		if u == nil {
+			t.Errorf("%s: no usage", tt.id)
+			continue
+		}
+		if !slices.Equal(u.Assigned, tt.assigned) {
+			t.Errorf("%s: assigned = %v, want %v", tt.id, u.Assigned, tt.assigned)
+		}
+		if !slices.Equal(u.Config, tt.cfg) {
+			t.Errorf("%s: config = %v, want %v", tt.id, u.Config, tt.cfg)
+		}
+		if !slices.Equal(u.Extensions, tt.extensions) {
+			t.Errorf("%s: extensions = %v, want %v", tt.id, u.Extensions, tt.extensions)
+		}
+		if !slices.Equal(u.Agents, tt.agents) {
+			t.Errorf("%s: agents = %v, want %v", tt.id, u.Agents, tt.agents)
+		}
+		if u.Unused() != tt.unused {
+			t.Errorf("%s: unused = %v, want %v", tt.id, u.Unused(), tt.unused)
+		}
+	}
+	if !usage["shared"].Global {
+		t.Error("shared: expected global")
+	}
+}
@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/extensions"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+// SecretUsage lists what makes a vault secret available to, or needed by,
+// agents. Agent lists are sorted.
+type SecretUsage struct {
+	Global     bool     `json:"global"`
+	Assigned   []string `json:"assigned"`   // explicit agent_secrets assignments
+	Config     []string `json:"config"`     // agents whose env, files or env_file_secret name it
+	Extensions []string `json:"extensions"` // agents whose MCP server env or headers name it
+	Agents     []string `json:"agents"`     // all of the above
+}
+
+// Unused reports whether nothing assigns or references the secret, so it
+// can be deleted without breaking an agent.
+func (u *SecretUsage) Unused() bool {
+	return !u.Global && len(u.Agents) == 0
+}
+
+// SecretUsage returns the usage of every stored secret, keyed by secret id.
+func (r *Registry) SecretUsage() (map[string]*SecretUsage, error) {
+	r.mu.RLock()
+	agents := r.agents
+	r.mu.RUnlock()
+	return CollectSecretUsage(r.store, agents)
+}
+
+// CollectSecretUsage computes SecretUsage from the vault's assignments and
+// the references in agents' definitions and stored extensions. References
+// are by name; those naming no stored secret are ignored.
+func CollectSecretUsage(s *store.Store, agents map[string]config.AgentDefinition) (map[string]*SecretUsage, error) {
+	secrets, err := s.ListSecrets()
+	if err != nil {
+		return nil, err
+	}
+	usage := make(map[string]*SecretUsage, len(secrets))
+	byName := make(map[string]*SecretUsage, len(secrets))
+	for _, sec := range secrets {
+		assigned, err := s.GetSecretAgentIDs(sec.ID)
+		if err != nil {
+			return nil, err
+		}
+		u := &SecretUsage{Global: sec.Global, Assigned: sortedOrEmpty(assigned)}
+		usage[sec.ID] = u
+		byName[sec.Name] = u
+	}
+
+	for _, agentID := range slices.Sorted(maps.Keys(agents)) {
+		for _, name := range agents[agentID].SecretRefs() {
+			if u := byName[name]; u != nil {
+				u.Config = append(u.Config, agentID)
+			}
+		}
+		extJSON, err := s.GetAgentExtensions(agentID)
+		if err != nil {
+			return nil, err
+		}
+		ext, err := extensions.Parse(extJSON)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %w", agentID, err)
+		}
+		for _, name := range ext.SecretRefs() {
+			if u := byName[name]; u != nil {
+				u.Extensions = append(u.Extensions, agentID)
+			}
+		}
+	}
+
+	for _, u := range usage {
+		u.Config = sortedOrEmpty(u.Config)
+		u.Extensions = sortedOrEmpty(u.Extensions)
+		all := slices.Concat(u.Assigned, u.Config, u.Extensions)
+		slices.Sort(all)
+		u.Agents = sortedOrEmpty(slices.Compact(all))
+	}
+	return usage, nil
+}
+
+func sortedOrEmpty(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/mtzanidakis/praktor/internal/config"
 	"github.com/mtzanidakis/praktor/internal/registry"
+	"github.com/mtzanidakis/praktor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Orchestrator interface {
@@ -15,15 +20,21 @@ type Orchestrator interface {
 }
 
 type Router struct {
-	registry     *registry.Registry
+	registry *registry.Registry
+	orch     Orchestrator
+
+	mu           sync.RWMutex
 	defaultAgent string
-	orch         Orchestrator
+	chatDefaults map[int64]string
+	userDefaults map[int64]string
 }
 
 func New(reg *registry.Registry, cfg config.RouterConfig) *Router {
 	return &Router{
 		registry:     reg,
 		defaultAgent: cfg.DefaultAgent,
+		chatDefaults: cfg.ChatDefaults,
+		userDefaults: cfg.UserDefaults,
 	}
 }
 
@@ -31,7 +42,22 @@ func (r *Router) SetOrchestrator(orch Orchestrator) {
 	r.orch = orch
 }
 
+// Route routes message using the global default agent.
 func (r *Router) Route(ctx context.Context, message string) (agentID string, cleanedMessage string, err error) {
+	return r.RouteFor(ctx, 0, 0, nil, message)
+}
+
+// RouteFor routes a message sent by userID in chatID, falling back to
+// DefaultAgentFor(chatID, userID). A non-empty allowed restricts smart
+// routing and the fallback to those agents; if the default agent isn't one
+// of them, the first allowed agent stands in.
+func (r *Router) RouteFor(ctx context.Context, chatID, userID int64, allowed []string, message string) (agentID string, cleanedMessage string, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "router.route")
+	defer func() {
+		span.SetAttributes(attribute.String("agent.id", agentID))
+		tracing.End(span, err)
+	}()
+
 	// 0. Check for @swarm prefix
 	if strings.HasPrefix(message, "@swarm ") {
 		return "swarm", strings.TrimPrefix(message, "@swarm "), nil
@@ -51,17 +77,22 @@ func (r *Router) Route(ctx context.Context, message string) (agentID string, cle
 		// Unknown agent name in prefix — fall through to smart routing
 	}
 
+	defaultAgent := r.DefaultAgentIn(chatID, userID, allowed)
+
 	// 2. Try smart routing via default agent
-	if r.orch != nil && r.defaultAgent != "" {
+	if r.orch != nil && defaultAgent != "" {
 		descs := r.registry.AgentDescriptions()
+		if len(allowed) > 0 {
+			maps.DeleteFunc(descs, func(name, _ string) bool { return !slices.Contains(allowed, name) })
+		}
 		if len(descs) > 1 {
-			routedAgent, routeErr := r.orch.RouteQuery(ctx, r.defaultAgent, buildRoutingPrompt(descs, message))
+			routedAgent, routeErr := r.orch.RouteQuery(ctx, defaultAgent, buildRoutingPrompt(descs, message))
 			if routeErr != nil {
 				slog.Debug("route query failed, using default agent", "error", routeErr)
 			} else {
-				// Validate the routed agent exists
+				// Validate the routed agent exists and may be routed to
 				routedAgent = strings.TrimSpace(routedAgent)
-				if _, ok := r.registry.GetDefinition(routedAgent); ok {
+				if _, ok := descs[routedAgent]; ok {
 					return routedAgent, message, nil
 				}
 				slog.Debug("route query returned unknown agent, using default", "agent", routedAgent)
@@ -70,21 +101,59 @@ func (r *Router) Route(ctx context.Context, message string) (agentID string, cle
 	}
 
 	// 3. Fall back to default agent
-	if r.defaultAgent == "" {
+	if defaultAgent == "" {
 		return "", message, fmt.Errorf("no default agent configured")
 	}
-	return r.defaultAgent, message, nil
+	return defaultAgent, message, nil
 }
 
 func (r *Router) DefaultAgent() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultAgent
+}
+
+// DefaultAgentFor returns the default agent for a message from userID in
+// chatID: the chat's entry in router.chat_defaults, then the user's entry in
+// router.user_defaults, then router.default_agent. Zero ids match nothing.
+func (r *Router) DefaultAgentFor(chatID, userID int64) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if agent, ok := r.chatDefaults[chatID]; ok && chatID != 0 {
+		return agent
+	}
+	if agent, ok := r.userDefaults[userID]; ok && userID != 0 {
+		return agent
+	}
 	return r.defaultAgent
 }
 
+// DefaultAgentIn is DefaultAgentFor limited to allowed: if the default agent
+// isn't one of them, the first allowed agent is returned. An empty allowed
+// means all agents.
+func (r *Router) DefaultAgentIn(chatID, userID int64, allowed []string) string {
+	agent := r.DefaultAgentFor(chatID, userID)
+	if len(allowed) > 0 && !slices.Contains(allowed, agent) {
+		return allowed[0]
+	}
+	return agent
+}
+
 // SetDefaultAgent updates the default agent used for routing.
 func (r *Router) SetDefaultAgent(agent string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.defaultAgent = agent
 }
 
+// SetChatDefaults replaces the per-chat and per-user default agents.
+func (r *Router) SetChatDefaults(chats, users map[int64]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.chatDefaults = chats
+	r.userDefaults = users
+}
+
 func buildRoutingPrompt(descs map[string]string, message string) string {
 	var sb strings.Builder
 	sb.WriteString("You are a message router. Given the user's message, determine which agent should handle it.\n\n")
@@ -120,3 +120,64 @@ func TestRouteAtPrefixBeatsDefault(t *testing.T) {
 		t.Errorf("expected cleaned message, got %q", msg)
 	}
 }
+
+func TestRouteForChatDefaults(t *testing.T) {
+	rtr := newTestRouter(t)
+	rtr.SetChatDefaults(map[int64]string{-100: "coder"}, map[int64]string{42: "coder"})
+
+	for _, tc := range []struct {
+		chatID, userID int64
+		want           string
+	}{
+		{-100, 7, "coder"},  // chat default
+		{-200, 42, "coder"}, // user default
+		{-200, 7, "general"},
+		{0, 0, "general"},
+	} {
+		if got := rtr.DefaultAgentFor(tc.chatID, tc.userID); got != tc.want {
+			t.Errorf("DefaultAgentFor(%d, %d) = %q, want %q", tc.chatID, tc.userID, got, tc.want)
+		}
+		agentID, _, err := rtr.RouteFor(context.Background(), tc.chatID, tc.userID, nil, "hello")
+		if err != nil || agentID != tc.want {
+			t.Errorf("RouteFor(%d, %d) = %q, %v; want %q", tc.chatID, tc.userID, agentID, err, tc.want)
+		}
+	}
+
+	// A prefix still wins over the chat default.
+	if agentID, _, _ := rtr.RouteFor(context.Background(), -100, 7, nil, "@general hi"); agentID != "general" {
+		t.Errorf("prefix routing in chat with default: got %q", agentID)
+	}
+	// Route ignores chat defaults.
+	if agentID, _, _ := rtr.Route(context.Background(), "hello"); agentID != "general" {
+		t.Errorf("Route = %q, want global default", agentID)
+	}
+}
+
+type fakeOrch struct {
+	reply string
+	calls int
+}
+
+func (f *fakeOrch) RouteQuery(_ context.Context, _, _ string) (string, error) {
+	f.calls++
+	return f.reply, nil
+}
+
+func TestRouteForAllowedAgents(t *testing.T) {
+	rtr := newTestRouter(t)
+	orch := &fakeOrch{reply: "coder"}
+	rtr.SetOrchestrator(orch)
+
+	if agentID, _, _ := rtr.RouteFor(context.Background(), 0, 0, nil, "hello"); agentID != "coder" || orch.calls != 1 {
+		t.Errorf("unrestricted smart routing = %q (%d queries), want coder", agentID, orch.calls)
+	}
+	// Only one candidate left: no routing query, and coder is never picked.
+	if agentID, _, _ := rtr.RouteFor(context.Background(), 0, 0, []string{"general"}, "hello"); agentID != "general" || orch.calls != 1 {
+		t.Errorf("restricted to general = %q (%d queries)", agentID, orch.calls)
+	}
+	// A default agent outside the allowed set is replaced by the first allowed one.
+	orch.reply = "general"
+	if agentID, _, _ := rtr.RouteFor(context.Background(), 0, 0, []string{"coder"}, "hello"); agentID != "coder" {
+		t.Errorf("restricted to coder = %q, want coder", agentID)
+	}
+}
@@ -2,7 +2,10 @@ package schedule
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -10,6 +13,14 @@ import (
 	"github.com/adhocore/gronx"
 )
 
+// ErrScheduleInvalid is wrapped by every error about a malformed schedule.
+var ErrScheduleInvalid = errors.New("invalid schedule")
+
+// yearFieldRe matches the year field of a 6-field cron expression. No
+// weekday value has four digits, so it tells the year form apart from the
+// seconds form; gronx applies the same rule.
+var yearFieldRe = regexp.MustCompile(`\d{4}`)
+
 type Schedule struct {
 	Kind       string `json:"kind"`        // "cron", "interval", "once"
 	CronExpr   string `json:"cron_expr"`   // Cron expression (if kind=cron)
@@ -20,7 +31,7 @@ type Schedule struct {
 func ParseSchedule(raw string) (*Schedule, error) {
 	var s Schedule
 	if err := json.Unmarshal([]byte(raw), &s); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrScheduleInvalid, err)
 	}
 	return &s, nil
 }
@@ -57,6 +68,27 @@ func CalculateNextRun(scheduleJSON string) *time.Time {
 	return &next
 }
 
+// cronForm classifies a cron expression by its fields:
+//
+//	5 fields: minute hour day month weekday
+//	6 fields: minute hour day month weekday year  (last field has 4 digits)
+//	6 fields: second minute hour day month weekday
+//	7 fields: second minute hour day month weekday year
+//
+// It reports whether the expression has a leading seconds field and a
+// trailing year field.
+func cronForm(expr string) (seconds, year bool) {
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 6:
+		year = yearFieldRe.MatchString(fields[5])
+		return !year, year
+	case 7:
+		return true, true
+	}
+	return false, false
+}
+
 // FormatSchedule returns a human-readable description of a schedule JSON string.
 func FormatSchedule(scheduleJSON string) string {
 	s, err := ParseSchedule(scheduleJSON)
@@ -70,11 +102,23 @@ func FormatSchedule(scheduleJSON string) string {
 			return s.CronExpr
 		}
 		fields := strings.Fields(s.CronExpr)
-		if len(fields) == 7 {
+		switch seconds, year := cronForm(s.CronExpr); {
+		case seconds && year:
 			return "Every tick: " + s.CronExpr
-		}
-		if len(fields) == 6 {
+		case year:
 			return "Once: " + s.CronExpr
+		case seconds:
+			if slices.Equal(fields[1:], []string{"*", "*", "*", "*", "*"}) {
+				if fields[0] == "*" {
+					return "Every second"
+				}
+				if step, ok := strings.CutPrefix(fields[0], "*/"); ok {
+					if n, err := strconv.Atoi(step); err == nil {
+						return fmt.Sprintf("Every %d seconds", n)
+					}
+				}
+			}
+			return "Every tick: " + s.CronExpr
 		}
 		return s.CronExpr
 	case "interval":
@@ -131,7 +175,11 @@ func parseRelativeDuration(s string) (time.Duration, bool) {
 // NormalizeSchedule detects plain cron strings and wraps them in JSON format.
 // If the input is already valid JSON with a "kind" field, it is passed through.
 // Relative durations like "+30s", "+5m", "+2h" are converted to a once schedule.
-// Otherwise, it validates as a cron expression and wraps it.
+// Otherwise, it validates as a cron expression and wraps it. A 6-field cron
+// expression is the year form when its last field is a 4-digit year and the
+// seconds form otherwise (see cronForm); "*/30 * * * * *" runs every 30
+// seconds. Sub-minute schedules, cron or interval_ms, only fire as often as
+// the scheduler polls.
 func NormalizeSchedule(raw string) (string, error) {
 	raw = strings.TrimSpace(raw)
 
@@ -152,25 +200,25 @@ func NormalizeSchedule(raw string) (string, error) {
 		switch s.Kind {
 		case "cron":
 			if !gronx.New().IsValid(s.CronExpr) {
-				return "", fmt.Errorf("invalid cron expression: %s", s.CronExpr)
+				return "", fmt.Errorf("%w: invalid cron expression: %s", ErrScheduleInvalid, s.CronExpr)
 			}
 		case "interval":
 			if s.IntervalMs <= 0 {
-				return "", fmt.Errorf("interval_ms must be positive")
+				return "", fmt.Errorf("%w: interval_ms must be positive", ErrScheduleInvalid)
 			}
 		case "once":
 			if s.AtMs <= 0 {
-				return "", fmt.Errorf("at_ms must be positive")
+				return "", fmt.Errorf("%w: at_ms must be positive", ErrScheduleInvalid)
 			}
 		default:
-			return "", fmt.Errorf("unknown schedule kind: %s", s.Kind)
+			return "", fmt.Errorf("%w: unknown schedule kind: %s", ErrScheduleInvalid, s.Kind)
 		}
 		return raw, nil
 	}
 
 	// Not JSON — try as plain cron expression
 	if !gronx.New().IsValid(raw) {
-		return "", fmt.Errorf("invalid schedule: not valid JSON or cron expression: %s", raw)
+		return "", fmt.Errorf("%w: not valid JSON or cron expression: %s", ErrScheduleInvalid, raw)
 	}
 
 	wrapped := Schedule{Kind: "cron", CronExpr: raw}
@@ -1,6 +1,7 @@
 package schedule
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -142,15 +143,15 @@ func TestNormalizeScheduleIntervalJSON(t *testing.T) {
 
 func TestNormalizeScheduleInvalid(t *testing.T) {
 	_, err := NormalizeSchedule("not a cron")
-	if err == nil {
-		t.Error("expected error for invalid input")
+	if !errors.Is(err, ErrScheduleInvalid) {
+		t.Errorf("expected ErrScheduleInvalid for invalid input, got %v", err)
 	}
 }
 
 func TestNormalizeScheduleInvalidCronInJSON(t *testing.T) {
 	_, err := NormalizeSchedule(`{"kind":"cron","cron_expr":"bad"}`)
-	if err == nil {
-		t.Error("expected error for invalid cron in JSON")
+	if !errors.Is(err, ErrScheduleInvalid) {
+		t.Errorf("expected ErrScheduleInvalid for invalid cron in JSON, got %v", err)
 	}
 }
 
@@ -267,6 +268,96 @@ func TestFormatScheduleWithYear(t *testing.T) {
 	}
 }
 
+func TestCronForm(t *testing.T) {
+	tests := []struct {
+		expr          string
+		seconds, year bool
+	}{
+		{"*/5 * * * *", false, false},
+		{"*/30 * * * * *", true, false},
+		{"0 */2 * * * MON-FRI", true, false},
+		{"20 10 17 2 * 2026", false, true},
+		{"0 0 1 1 * 2026-2028", false, true},
+		{"30 0 9 * * * 2026", true, true},
+		{"@daily", false, false},
+	}
+	for _, tt := range tests {
+		seconds, year := cronForm(tt.expr)
+		if seconds != tt.seconds || year != tt.year {
+			t.Errorf("cronForm(%q) = seconds %v, year %v; want %v, %v", tt.expr, seconds, year, tt.seconds, tt.year)
+		}
+	}
+}
+
+func TestNormalizeScheduleSecondsField(t *testing.T) {
+	result, err := NormalizeSchedule("*/30 * * * * *")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, err := ParseSchedule(result)
+	if err != nil {
+		t.Fatalf("result not valid JSON: %v", err)
+	}
+	if s.Kind != "cron" || s.CronExpr != "*/30 * * * * *" {
+		t.Errorf("expected 6-field seconds cron, got %+v", s)
+	}
+
+	if _, err := NormalizeSchedule("*/30 * * * * * * *"); !errors.Is(err, ErrScheduleInvalid) {
+		t.Errorf("expected ErrScheduleInvalid for 8 fields, got %v", err)
+	}
+}
+
+func TestCalculateNextRunSecondsVsYear(t *testing.T) {
+	before := time.Now()
+	next := CalculateNextRun(`{"kind":"cron","cron_expr":"*/30 * * * * *"}`)
+	if next == nil {
+		t.Fatal("expected next run for seconds expression")
+	}
+	if d := next.Sub(before); d <= 0 || d > 31*time.Second {
+		t.Errorf("expected next run within 30s, got %v", d)
+	}
+	if sec := next.Second(); sec != 0 && sec != 30 {
+		t.Errorf("expected next run on :00 or :30, got :%02d", sec)
+	}
+
+	// The same field count with a year in last place runs once, at second 0.
+	year := time.Now().Year() + 1
+	next = CalculateNextRun(fmt.Sprintf(`{"kind":"cron","cron_expr":"30 9 1 1 * %d"}`, year))
+	if next == nil {
+		t.Fatal("expected next run for year expression")
+	}
+	want := time.Date(year, time.January, 1, 9, 30, 0, 0, time.Local)
+	if !next.Equal(want) {
+		t.Errorf("expected %v, got %v", want, next)
+	}
+}
+
+func TestCalculateNextRunSubMinuteInterval(t *testing.T) {
+	before := time.Now()
+	next := CalculateNextRun(`{"kind":"interval","interval_ms":30000}`)
+	if next == nil {
+		t.Fatal("expected next run for 30s interval")
+	}
+	if d := next.Sub(before); d < 30*time.Second || d > 31*time.Second {
+		t.Errorf("expected next run ~30s from now, got %v", d)
+	}
+}
+
+func TestFormatScheduleSecondsField(t *testing.T) {
+	tests := map[string]string{
+		`{"kind":"cron","cron_expr":"*/30 * * * * *"}`: "Every 30 seconds",
+		`{"kind":"cron","cron_expr":"* * * * * *"}`:    "Every second",
+		`{"kind":"cron","cron_expr":"15 */5 * * * *"}`: "Every tick: 15 */5 * * * *",
+		`{"kind":"interval","interval_ms":30000}`:      "Every 30 seconds",
+		`{"kind":"cron","cron_expr":"0 0 1 1 * 2030"}`: "Once: 0 0 1 1 * 2030",
+	}
+	for raw, want := range tests {
+		if got := FormatSchedule(raw); got != want {
+			t.Errorf("FormatSchedule(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
 func TestNormalizeScheduleWithWhitespace(t *testing.T) {
 	result, err := NormalizeSchedule("  */5 * * * *  ")
 	if err != nil {
@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/events"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+)
+
+// inflightTimeout frees the slot of a run whose completion never arrived,
+// e.g. because the gateway lost track of the container.
+const inflightTimeout = time.Hour
+
+// acquire takes a dispatch slot for the task's run. It fails while
+// scheduler.concurrency runs are in flight, or while an earlier run of the
+// same task still is; the task then stays due and waits in the backlog.
+// Without a concurrency limit every run is admitted.
+func (s *Scheduler) acquire(taskID string, now time.Time) bool {
+	s.inflightMu.Lock()
+	defer s.inflightMu.Unlock()
+	for id, at := range s.inflight {
+		if now.Sub(at) > inflightTimeout {
+			slog.Warn("scheduled task run never completed, freeing its slot", "id", id, "dispatched_at", at)
+			delete(s.inflight, id)
+		}
+	}
+	if s.concurrency <= 0 {
+		return true
+	}
+	if _, busy := s.inflight[taskID]; busy || len(s.inflight) >= s.concurrency {
+		return false
+	}
+	s.inflight[taskID] = now
+	return true
+}
+
+// release frees the task's slot once its run has completed or failed to
+// hand off, and wakes the poll loop if tasks are waiting for one.
+func (s *Scheduler) release(taskID string) {
+	s.inflightMu.Lock()
+	_, held := s.inflight[taskID]
+	delete(s.inflight, taskID)
+	waiting := s.backlog > 0
+	s.inflightMu.Unlock()
+	if held && waiting {
+		select {
+		case s.wakeCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// setBacklog records how many due tasks are waiting for a slot and, when
+// that changes, logs it and publishes a scheduler_backlog event.
+func (s *Scheduler) setBacklog(depth int) {
+	s.inflightMu.Lock()
+	prev := s.backlog
+	s.backlog = depth
+	inflight := len(s.inflight)
+	limit := s.concurrency
+	s.inflightMu.Unlock()
+	if depth == prev {
+		return
+	}
+	slog.Info("scheduler backlog", "depth", depth, "in_flight", inflight, "concurrency", limit)
+	if s.natsClient == nil {
+		return
+	}
+	_ = s.natsClient.PublishJSON(natsbus.TopicEventsTaskBacklog(), events.NewSchedulerBacklog(depth, inflight, limit))
+}
@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/agent"
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/events"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+// On-failure actions of a scheduled task.
+const (
+	OnFailureNotify  = "notify_telegram"
+	OnFailureWebhook = "webhook"
+	OnFailureRunTask = "run_task"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// ParseOnFailure splits a task's on_failure setting into its action and
+// argument: "notify_telegram", "webhook:<url>" or "run_task:<task id>".
+// An empty setting means no action.
+func ParseOnFailure(raw string) (action, arg string, err error) {
+	if raw == "" {
+		return "", "", nil
+	}
+	action, arg, _ = strings.Cut(raw, ":")
+	switch action {
+	case OnFailureNotify:
+		if arg != "" {
+			return "", "", fmt.Errorf("on_failure %s takes no argument", action)
+		}
+	case OnFailureWebhook:
+		u, err := url.Parse(arg)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", "", fmt.Errorf("on_failure webhook needs an http(s) URL, e.g. webhook:https://example.com/hook")
+		}
+	case OnFailureRunTask:
+		if arg == "" {
+			return "", "", fmt.Errorf("on_failure run_task needs a task id, e.g. run_task:<id>")
+		}
+	default:
+		return "", "", fmt.Errorf("unknown on_failure action %q (want %s, %s:<url> or %s:<id>)",
+			action, OnFailureNotify, OnFailureWebhook, OnFailureRunTask)
+	}
+	return action, arg, nil
+}
+
+// handleRunComplete tracks the outcome of the scheduler's own messages.
+func (s *Scheduler) handleRunComplete(_ string, meta map[string]string, err error) {
+	taskID := meta["task_id"]
+	if meta["sender"] != "scheduler" || taskID == "" {
+		return
+	}
+	if meta["triggered_by"] == "" {
+		if errors.Is(err, container.ErrMaxContainers) {
+			s.requeue(taskID, attemptFrom(meta))
+			return
+		}
+		s.release(taskID)
+	}
+	if errors.Is(err, agent.ErrMessageDropped) {
+		return // aborted before it ran; neither a success nor a failure
+	}
+	task, gerr := s.store.GetTask(taskID)
+	if gerr != nil || task == nil {
+		return
+	}
+	if err == nil {
+		if task.ConsecutiveFailures > 0 {
+			if err := s.store.ResetTaskFailures(task.ID); err != nil {
+				slog.Error("failed to reset task failures", "id", task.ID, "error", err)
+			}
+		}
+		return
+	}
+	s.runFailed(context.Background(), *task, err.Error(), attemptFrom(meta), meta["triggered_by"] != "")
+}
+
+// taskFailed records a failed run, pauses the task once max_failures
+// consecutive runs have failed, and fires its on_failure action. Runs that
+// were themselves started by a run_task action never start another task,
+// so two tasks remediating each other can't loop.
+func (s *Scheduler) taskFailed(ctx context.Context, task store.ScheduledTask, reason string, triggered bool) {
+	n, err := s.store.RecordTaskFailure(task.ID, reason)
+	if err != nil {
+		slog.Error("failed to record task failure", "id", task.ID, "error", err)
+		return
+	}
+	task.ConsecutiveFailures = n
+
+	paused := false
+	if task.MaxFailures > 0 && n >= task.MaxFailures && task.Status == "active" {
+		why := fmt.Sprintf("paused after %d consecutive failures, last: %s", n, reason)
+		if err := s.store.PauseTask(task.ID, why); err != nil {
+			slog.Error("failed to pause task", "id", task.ID, "error", err)
+		} else {
+			paused = true
+			slog.Warn("task auto-paused", "id", task.ID, "name", task.Name, "failures", n)
+		}
+	}
+
+	if s.natsClient != nil {
+		_ = s.natsClient.PublishJSON(natsbus.TopicEventsTaskFailed(),
+			events.NewTaskFailed(task.ID, task.Name, task.AgentID, reason, n, paused))
+	}
+	if task.OnFailure != "" {
+		go s.runFailureHook(ctx, task, reason, paused, triggered)
+	}
+}
+
+func (s *Scheduler) runFailureHook(ctx context.Context, task store.ScheduledTask, reason string, paused, triggered bool) {
+	action, arg, err := ParseOnFailure(task.OnFailure)
+	if err != nil {
+		slog.Warn("invalid on_failure action", "id", task.ID, "on_failure", task.OnFailure, "error", err)
+		return
+	}
+
+	switch action {
+	case OnFailureNotify:
+		text := fmt.Sprintf("⚠️ Scheduled task %q failed: %s", task.Name, reason)
+		if paused {
+			text += fmt.Sprintf("\n\nThe task was paused after %d consecutive failures.", task.ConsecutiveFailures)
+		}
+		meta := map[string]string{"sender": "scheduler", "task_id": task.ID}
+		s.setChatID(meta, task.AgentID)
+		s.notify(task.AgentID, text, meta)
+	case OnFailureWebhook:
+		if err := s.postFailureWebhook(ctx, arg, task, reason, paused); err != nil {
+			slog.Warn("task failure webhook failed", "id", task.ID, "error", err)
+		}
+	case OnFailureRunTask:
+		if triggered {
+			slog.Info("not chaining run_task from a triggered run", "id", task.ID, "target", arg)
+			return
+		}
+		target, err := s.store.GetTask(arg)
+		if err != nil || target == nil {
+			slog.Warn("on_failure task not found", "id", task.ID, "target", arg, "error", err)
+			return
+		}
+		slog.Info("running on_failure task", "id", task.ID, "target", target.ID)
+		s.run(ctx, *target, task.ID)
+	}
+}
+
+func (s *Scheduler) postFailureWebhook(ctx context.Context, hookURL string, task store.ScheduledTask, reason string, paused bool) error {
+	body, err := json.Marshal(events.NewTaskFailed(task.ID, task.Name, task.AgentID, reason, task.ConsecutiveFailures, paused))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
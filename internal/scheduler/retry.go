@@ -0,0 +1,129 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+// DefaultRetryDelay is the delay before the first retry of a task that
+// sets retry_count without retry_delay.
+const DefaultRetryDelay = time.Minute
+
+// pendingRetry is a failed occurrence waiting for its next attempt.
+type pendingRetry struct {
+	attempt int // the attempt to run next, 2 for the first retry
+	due     time.Time
+}
+
+// retryDelay returns how long to wait after the given failed attempt: the
+// task's retry_delay, doubled for every attempt after the first.
+func retryDelay(task store.ScheduledTask, failedAttempt int) time.Duration {
+	d := task.RetryDelay
+	if d <= 0 {
+		d = DefaultRetryDelay
+	}
+	return d << min(failedAttempt-1, 16)
+}
+
+// runFailed handles a failed attempt of a task run. While retries are left
+// and the next one would start before the task's next scheduled slot, it is
+// queued for the poll loop; otherwise the occurrence is dead-lettered and
+// counts as one failure towards max_failures and on_failure. Runs started by
+// a run_task action are not retried.
+func (s *Scheduler) runFailed(ctx context.Context, task store.ScheduledTask, reason string, attempt int, triggered bool) {
+	if !triggered && attempt <= task.RetryCount {
+		due := time.Now().Add(retryDelay(task, attempt))
+		if task.NextRunAt == nil || due.Before(*task.NextRunAt) {
+			s.retryMu.Lock()
+			s.retries[task.ID] = pendingRetry{attempt: attempt + 1, due: due}
+			s.retryMu.Unlock()
+			slog.Info("task run will be retried", "id", task.ID, "attempt", attempt+1, "of", task.RetryCount+1, "at", due)
+			return
+		}
+		slog.Info("task retry would overlap the next scheduled run", "id", task.ID, "attempt", attempt)
+	}
+
+	run := &store.TaskRun{TaskID: task.ID, Status: store.TaskRunDeadLettered, Attempts: attempt, Error: reason}
+	if err := s.store.AddTaskRun(run); err != nil {
+		slog.Error("failed to dead-letter task run", "id", task.ID, "error", err)
+	} else if task.RetryCount > 0 {
+		slog.Warn("task run dead-lettered", "id", task.ID, "name", task.Name, "attempts", attempt)
+	}
+	s.taskFailed(ctx, task, reason, triggered)
+}
+
+// runDueRetries runs the pending retries that are due at now, in task id
+// order, and returns how many are left waiting for a dispatch slot. A task
+// that was deleted or paused meanwhile drops its retry.
+func (s *Scheduler) runDueRetries(ctx context.Context, now time.Time) (waiting int) {
+	due := map[string]pendingRetry{}
+	s.retryMu.Lock()
+	for id, r := range s.retries {
+		if !r.due.After(now) {
+			due[id] = r
+			delete(s.retries, id)
+		}
+	}
+	s.retryMu.Unlock()
+
+	for _, id := range slices.Sorted(maps.Keys(due)) {
+		attempt := due[id].attempt
+		task, err := s.store.GetTask(id)
+		if err != nil {
+			slog.Error("failed to load task for retry", "id", id, "error", err)
+			continue
+		}
+		if task == nil || task.Status == "paused" {
+			slog.Info("dropping retry of inactive task", "id", id)
+			continue
+		}
+		if !s.acquire(id, now) {
+			s.retryMu.Lock()
+			if _, ok := s.retries[id]; !ok {
+				s.retries[id] = due[id]
+			}
+			s.retryMu.Unlock()
+			waiting++
+			continue
+		}
+		s.runAttempt(ctx, *task, "", attempt)
+	}
+	return waiting
+}
+
+// requeue puts a run that couldn't start because the agents were at
+// defaults.max_running back in line as a retry of the same attempt, so it
+// doesn't count as a failure. Its slot is freed without waking the poll
+// loop, which would only hit the same limit again.
+func (s *Scheduler) requeue(taskID string, attempt int) {
+	s.inflightMu.Lock()
+	delete(s.inflight, taskID)
+	s.inflightMu.Unlock()
+	s.retryMu.Lock()
+	s.retries[taskID] = pendingRetry{attempt: attempt, due: time.Now()}
+	s.retryMu.Unlock()
+	slog.Info("agents at capacity, task run requeued", "id", taskID, "attempt", attempt)
+}
+
+// dropRetry forgets a task's pending retry, when its next scheduled run
+// starts instead.
+func (s *Scheduler) dropRetry(taskID string) {
+	s.retryMu.Lock()
+	delete(s.retries, taskID)
+	s.retryMu.Unlock()
+}
+
+// attemptFrom returns the attempt number recorded in a scheduler message's
+// meta, 1 when absent.
+func attemptFrom(meta map[string]string) int {
+	if n, err := strconv.Atoi(meta["attempt"]); err == nil && n > 1 {
+		return n
+	}
+	return 1
+}
@@ -2,36 +2,64 @@ package scheduler
 
 import (
 	"context"
-	"encoding/json"
 	"log/slog"
+	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/mtzanidakis/praktor/internal/agent"
 	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/events"
 	"github.com/mtzanidakis/praktor/internal/natsbus"
 	"github.com/mtzanidakis/praktor/internal/schedule"
 	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Scheduler struct {
 	store        *store.Store
-	orch         *agent.Orchestrator
 	bus          *natsbus.Bus
 	natsClient   *natsbus.Client
 	pollInterval time.Duration
-	mainChatID   int64
+	mainChatID   func(agentID string) int64 // chat for an agent's task output; 0 = none
 	reloadCh     chan struct{}
+	wakeCh       chan struct{} // a dispatch slot freed up while tasks wait
+	httpClient   *http.Client  // on_failure webhooks
+
+	inflightMu  sync.Mutex
+	concurrency int                  // scheduler.concurrency; 0 = unlimited
+	inflight    map[string]time.Time // dispatch time by task id, until the run completes
+	backlog     int                  // due tasks left waiting at the last poll
+
+	retryMu sync.Mutex
+	retries map[string]pendingRetry // by task id
+
+	// The orchestrator's HandleMessage and Notify.
+	handle func(ctx context.Context, agentID, text string, meta map[string]string) error
+	notify func(agentID, text string, meta map[string]string)
 }
 
-func New(s *store.Store, orch *agent.Orchestrator, bus *natsbus.Bus, cfg config.SchedulerConfig, mainChatID int64) *Scheduler {
+func New(s *store.Store, orch *agent.Orchestrator, bus *natsbus.Bus, cfg config.SchedulerConfig, mainChatID func(agentID string) int64) *Scheduler {
 	sched := &Scheduler{
 		store:        s,
-		orch:         orch,
 		bus:          bus,
 		pollInterval: cfg.PollInterval,
 		mainChatID:   mainChatID,
 		reloadCh:     make(chan struct{}, 1),
+		wakeCh:       make(chan struct{}, 1),
+		concurrency:  cfg.Concurrency,
+		inflight:     make(map[string]time.Time),
+		httpClient:   &http.Client{Timeout: webhookTimeout},
+		retries:      make(map[string]pendingRetry),
+	}
+	if orch != nil {
+		sched.handle = orch.HandleMessage
+		sched.notify = orch.Notify
+		orch.OnRunComplete(sched.handleRunComplete)
+		orch.OnOutput(sched.handleTaskOutput)
 	}
 
 	if bus != nil {
@@ -46,11 +74,14 @@ func New(s *store.Store, orch *agent.Orchestrator, bus *natsbus.Bus, cfg config.
 	return sched
 }
 
-// UpdateConfig updates the scheduler's poll interval and main chat ID,
-// then signals the run loop to reset its ticker.
-func (s *Scheduler) UpdateConfig(pollInterval time.Duration, mainChatID int64) {
-	s.pollInterval = pollInterval
+// UpdateConfig updates the scheduler's poll interval, concurrency and main
+// chat IDs, then signals the run loop to reset its ticker.
+func (s *Scheduler) UpdateConfig(cfg config.SchedulerConfig, mainChatID func(agentID string) int64) {
+	s.pollInterval = cfg.PollInterval
 	s.mainChatID = mainChatID
+	s.inflightMu.Lock()
+	s.concurrency = cfg.Concurrency
+	s.inflightMu.Unlock()
 	select {
 	case s.reloadCh <- struct{}{}:
 	default:
@@ -65,7 +96,7 @@ func (s *Scheduler) Start(ctx context.Context) {
 	ticker := time.NewTicker(s.pollInterval)
 	defer ticker.Stop()
 
-	slog.Info("scheduler started", "poll_interval", s.pollInterval)
+	slog.Info("scheduler started", "poll_interval", s.pollInterval, "concurrency", s.concurrency)
 
 	for {
 		select {
@@ -77,55 +108,96 @@ func (s *Scheduler) Start(ctx context.Context) {
 			slog.Info("scheduler config reloaded", "poll_interval", s.pollInterval)
 		case <-ticker.C:
 			s.poll(ctx)
+		case <-s.wakeCh:
+			s.poll(ctx)
 		}
 	}
 }
 
+// poll dispatches the due retries and tasks, oldest first, while dispatch
+// slots are free. Tasks that don't get one stay due for the next poll.
 func (s *Scheduler) poll(ctx context.Context) {
-	tasks, err := s.store.GetDueTasks(time.Now())
+	now := time.Now()
+	backlog := s.runDueRetries(ctx, now)
+
+	tasks, err := s.store.GetDueTasks(now)
 	if err != nil {
 		slog.Error("failed to get due tasks", "error", err)
 		return
 	}
 
 	for _, task := range tasks {
-		s.execute(ctx, task)
+		if !s.acquire(task.ID, now) {
+			backlog++
+			continue
+		}
+		s.dropRetry(task.ID)
+		s.run(ctx, task, "")
 	}
+	s.setBacklog(backlog)
 }
 
-func (s *Scheduler) execute(ctx context.Context, task store.ScheduledTask) {
-	slog.Info("executing scheduled task", "id", task.ID, "name", task.Name, "agent", task.AgentID)
+// run executes a task. triggeredBy is the id of the failed task whose
+// run_task action started it, empty for scheduled runs.
+func (s *Scheduler) run(ctx context.Context, task store.ScheduledTask, triggeredBy string) {
+	s.runAttempt(ctx, task, triggeredBy, 1)
+}
+
+// runAttempt executes one attempt of a task run. Retries (attempt > 1)
+// leave the task's schedule alone.
+func (s *Scheduler) runAttempt(ctx context.Context, task store.ScheduledTask, triggeredBy string, attempt int) {
+	slog.Info("executing scheduled task", "id", task.ID, "name", task.Name, "agent", task.AgentID, "attempt", attempt)
+
+	ctx, span := tracing.Tracer().Start(ctx, "scheduler.task",
+		trace.WithAttributes(attribute.String("praktor.task_id", task.ID), attribute.String("agent.id", task.AgentID)))
 
 	meta := map[string]string{
-		"sender":  "scheduler",
-		"task_id": task.ID,
+		"sender":       "scheduler",
+		"task_id":      task.ID,
+		"context_mode": task.ContextMode,
 	}
-	if s.mainChatID != 0 {
-		meta["chat_id"] = strconv.FormatInt(s.mainChatID, 10)
+	s.setChatID(meta, task.AgentID)
+	if triggeredBy != "" {
+		meta["triggered_by"] = triggeredBy
+	}
+	if attempt > 1 {
+		meta["attempt"] = strconv.Itoa(attempt)
 	}
 
-	err := s.orch.HandleMessage(ctx, task.AgentID, task.Prompt, meta)
+	err := s.handle(ctx, task.AgentID, taskPrompt(task), meta)
+	tracing.End(span, err)
 
 	var lastStatus, lastError string
 	if err != nil {
 		lastStatus = "error"
 		lastError = err.Error()
 		slog.Error("task execution failed", "id", task.ID, "error", err)
+		if triggeredBy == "" {
+			s.release(task.ID) // no run to wait for
+		}
 	} else {
 		lastStatus = "success"
 	}
 
 	// Calculate next run time
-	nextRun := schedule.CalculateNextRun(task.Schedule)
+	nextRun := task.NextRunAt
+	if attempt == 1 {
+		nextRun = schedule.CalculateNextRun(task.Schedule)
+	}
 
 	if err := s.store.UpdateTaskRun(task.ID, lastStatus, lastError, nextRun); err != nil {
 		slog.Error("failed to update task run", "id", task.ID, "error", err)
 	}
+	task.NextRunAt = nextRun
+	// Failures after a successful hand-off arrive through handleRunComplete.
+	if err != nil {
+		s.runFailed(ctx, task, lastError, attempt, triggeredBy != "")
+	}
 
 	s.publishTaskExecutedEvent(task, lastStatus)
 
 	// Mark one-off tasks as completed when they have no next run
-	if nextRun == nil {
+	if nextRun == nil && attempt == 1 {
 		slog.Info("no next run, marking one-off task as completed", "id", task.ID, "name", task.Name)
 		if err := s.store.UpdateTaskStatus(task.ID, "completed"); err != nil {
 			slog.Error("failed to complete task", "id", task.ID, "error", err)
@@ -138,20 +210,16 @@ func (s *Scheduler) publishTaskExecutedEvent(task store.ScheduledTask, status st
 		return
 	}
 
-	event := map[string]any{
-		"type":      "task_executed",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"data": map[string]any{
-			"id":     task.ID,
-			"name":   task.Name,
-			"status": status,
-		},
-	}
+	_ = s.natsClient.PublishJSON(natsbus.TopicEventsTaskExecuted(), events.NewTaskExecuted(task.ID, task.Name, status))
+}
 
-	data, err := json.Marshal(event)
-	if err != nil {
+// setChatID addresses output for agentID to its home bot's main chat, if it
+// has one.
+func (s *Scheduler) setChatID(meta map[string]string, agentID string) {
+	if s.mainChatID == nil {
 		return
 	}
-
-	_ = s.natsClient.Publish(natsbus.TopicEventsTaskExecuted, data)
+	if id := s.mainChatID(agentID); id != 0 {
+		meta["chat_id"] = strconv.FormatInt(id, 10)
+	}
 }
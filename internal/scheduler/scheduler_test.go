@@ -1 +1,407 @@
 package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/agent"
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+type sentMessage struct {
+	agentID, text string
+	meta          map[string]string
+}
+
+// newTestScheduler returns a scheduler over a fresh store whose agent
+// messages and notices are recorded instead of delivered.
+func newTestScheduler(t *testing.T) (*Scheduler, *store.Store, *[]sentMessage, *[]sentMessage) {
+	t.Helper()
+	db, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.SaveAgent(&store.Agent{ID: "alpha", Name: "Alpha", Workspace: "alpha"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(db, nil, nil, config.SchedulerConfig{}, func(string) int64 { return 42 })
+	var mu sync.Mutex
+	var handled, notified []sentMessage
+	s.handle = func(_ context.Context, agentID, text string, meta map[string]string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, sentMessage{agentID, text, meta})
+		return nil
+	}
+	s.notify = func(agentID, text string, meta map[string]string) {
+		mu.Lock()
+		defer mu.Unlock()
+		notified = append(notified, sentMessage{agentID, text, meta})
+	}
+	return s, db, &handled, &notified
+}
+
+func saveTask(t *testing.T, db *store.Store, task store.ScheduledTask) store.ScheduledTask {
+	t.Helper()
+	if task.AgentID == "" {
+		task.AgentID = "alpha"
+	}
+	if task.Schedule == "" {
+		task.Schedule = `{"kind":"interval","interval_ms":60000}`
+	}
+	if task.Prompt == "" {
+		task.Prompt = "check " + task.ID
+	}
+	if task.Status == "" {
+		task.Status = "active"
+	}
+	if err := db.SaveTask(&task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestParseOnFailure(t *testing.T) {
+	tests := []struct {
+		raw, action, arg string
+		wantErr          bool
+	}{
+		{raw: ""},
+		{raw: "notify_telegram", action: OnFailureNotify},
+		{raw: "webhook:https://example.com/hook?x=1", action: OnFailureWebhook, arg: "https://example.com/hook?x=1"},
+		{raw: "run_task:abc", action: OnFailureRunTask, arg: "abc"},
+		{raw: "notify_telegram:now", wantErr: true},
+		{raw: "webhook:", wantErr: true},
+		{raw: "webhook:ftp://example.com", wantErr: true},
+		{raw: "run_task:", wantErr: true},
+		{raw: "email", wantErr: true},
+	}
+	for _, tt := range tests {
+		action, arg, err := ParseOnFailure(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseOnFailure(%q): expected error", tt.raw)
+			}
+			continue
+		}
+		if err != nil || action != tt.action || arg != tt.arg {
+			t.Errorf("ParseOnFailure(%q) = %q, %q, %v; want %q, %q", tt.raw, action, arg, err, tt.action, tt.arg)
+		}
+	}
+}
+
+func TestAutoPauseAfterMaxFailures(t *testing.T) {
+	s, db, _, _ := newTestScheduler(t)
+	task := saveTask(t, db, store.ScheduledTask{ID: "t1", Name: "probe", MaxFailures: 3})
+
+	for i := 1; i <= 3; i++ {
+		s.taskFailed(context.Background(), task, "agent stopped: timeout", false)
+		got, _ := db.GetTask("t1")
+		if got.ConsecutiveFailures != i {
+			t.Fatalf("after %d failures: consecutive_failures = %d", i, got.ConsecutiveFailures)
+		}
+		if wantPaused := i == 3; (got.Status == "paused") != wantPaused {
+			t.Fatalf("after %d failures: status = %s", i, got.Status)
+		}
+	}
+
+	got, _ := db.GetTask("t1")
+	if got.LastStatus != "error" || !strings.Contains(got.LastError, "paused after 3 consecutive failures") {
+		t.Errorf("last_status = %q, last_error = %q", got.LastStatus, got.LastError)
+	}
+}
+
+func TestSuccessResetsFailures(t *testing.T) {
+	s, db, _, _ := newTestScheduler(t)
+	task := saveTask(t, db, store.ScheduledTask{ID: "t1", Name: "probe", MaxFailures: 2})
+	meta := map[string]string{"sender": "scheduler", "task_id": "t1"}
+
+	s.handleRunComplete("alpha", meta, errors.New("agent stopped: timeout"))
+	s.handleRunComplete("alpha", meta, nil)
+	s.handleRunComplete("alpha", meta, errors.New("agent stopped: timeout"))
+
+	got, _ := db.GetTask(task.ID)
+	if got.ConsecutiveFailures != 1 || got.Status != "active" {
+		t.Errorf("consecutive_failures = %d, status = %s; want 1, active", got.ConsecutiveFailures, got.Status)
+	}
+
+	// Other senders' messages are not the scheduler's business.
+	s.handleRunComplete("alpha", map[string]string{"sender": "user", "task_id": "t1"}, errors.New("boom"))
+	if got, _ := db.GetTask(task.ID); got.ConsecutiveFailures != 1 {
+		t.Errorf("user message counted as a task failure")
+	}
+	// A run dropped by an abort is neither.
+	s.handleRunComplete("alpha", meta, agent.ErrMessageDropped)
+	if got, _ := db.GetTask(task.ID); got.ConsecutiveFailures != 1 {
+		t.Errorf("dropped run counted as a task failure")
+	}
+}
+
+func TestHandleMessageFailureCounts(t *testing.T) {
+	s, db, _, _ := newTestScheduler(t)
+	task := saveTask(t, db, store.ScheduledTask{ID: "t1", Name: "probe", MaxFailures: 1})
+	s.handle = func(context.Context, string, string, map[string]string) error {
+		return errors.New("agent rate limit exceeded")
+	}
+
+	s.run(context.Background(), task, "")
+
+	got, _ := db.GetTask("t1")
+	if got.Status != "paused" || got.ConsecutiveFailures != 1 {
+		t.Errorf("status = %s, consecutive_failures = %d; want paused, 1", got.Status, got.ConsecutiveFailures)
+	}
+}
+
+func TestFailureHookNotify(t *testing.T) {
+	s, db, _, notified := newTestScheduler(t)
+	task := saveTask(t, db, store.ScheduledTask{ID: "t1", Name: "probe", OnFailure: "notify_telegram"})
+	task.ConsecutiveFailures = 3
+
+	s.runFailureHook(context.Background(), task, "agent stopped: timeout", true, false)
+
+	if len(*notified) != 1 {
+		t.Fatalf("got %d notices, want 1", len(*notified))
+	}
+	n := (*notified)[0]
+	if n.agentID != "alpha" || n.meta["chat_id"] != "42" || n.meta["task_id"] != "t1" {
+		t.Errorf("notice = %+v", n)
+	}
+	if !strings.Contains(n.text, `"probe" failed: agent stopped: timeout`) || !strings.Contains(n.text, "paused after 3") {
+		t.Errorf("notice text = %q", n.text)
+	}
+}
+
+func TestFailureHookWebhook(t *testing.T) {
+	got := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got <- body
+	}))
+	defer srv.Close()
+
+	s, db, _, _ := newTestScheduler(t)
+	task := saveTask(t, db, store.ScheduledTask{ID: "t1", Name: "probe", OnFailure: "webhook:" + srv.URL})
+	task.ConsecutiveFailures = 1
+
+	s.runFailureHook(context.Background(), task, "agent stopped: timeout", false, false)
+
+	select {
+	case body := <-got:
+		if body["type"] != "task_failed" || body["task_id"] != "t1" || body["error"] != "agent stopped: timeout" ||
+			body["consecutive_failures"] != float64(1) || body["paused"] != false {
+			t.Errorf("webhook body = %v", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestFailureHookRunTask(t *testing.T) {
+	s, db, handled, _ := newTestScheduler(t)
+	saveTask(t, db, store.ScheduledTask{ID: "fix", Name: "remediate", Prompt: "restart the service", Status: "paused"})
+	task := saveTask(t, db, store.ScheduledTask{ID: "t1", Name: "probe", OnFailure: "run_task:fix"})
+
+	s.runFailureHook(context.Background(), task, "agent stopped: timeout", false, false)
+
+	if len(*handled) != 1 {
+		t.Fatalf("got %d messages, want 1", len(*handled))
+	}
+	m := (*handled)[0]
+	if m.text != "restart the service" || m.meta["task_id"] != "fix" || m.meta["triggered_by"] != "t1" {
+		t.Errorf("remediation message = %+v", m)
+	}
+	if fix, _ := db.GetTask("fix"); fix.Status != "paused" {
+		t.Errorf("remediation task status = %s, want it left paused", fix.Status)
+	}
+
+	// A failing remediation run does not start further tasks.
+	s.runFailureHook(context.Background(), task, "agent stopped: timeout", false, true)
+	if len(*handled) != 1 {
+		t.Errorf("triggered run chained another task")
+	}
+}
+
+func TestRetryThenSucceed(t *testing.T) {
+	s, db, handled, _ := newTestScheduler(t)
+	task := saveTask(t, db, store.ScheduledTask{ID: "t1", Name: "probe", RetryCount: 2, RetryDelay: time.Millisecond})
+	calls := 0
+	s.handle = func(_ context.Context, agentID, text string, meta map[string]string) error {
+		calls++
+		*handled = append(*handled, sentMessage{agentID, text, meta})
+		if calls == 1 {
+			return errors.New("agent rate limit exceeded")
+		}
+		return nil
+	}
+
+	s.run(context.Background(), task, "")
+	if got, _ := db.GetTask("t1"); got.ConsecutiveFailures != 0 {
+		t.Errorf("a retried failure counted towards max_failures")
+	}
+	s.runDueRetries(context.Background(), time.Now().Add(time.Second))
+
+	if len(*handled) != 2 || (*handled)[1].meta["attempt"] != "2" {
+		t.Fatalf("messages = %+v, want the retry as attempt 2", *handled)
+	}
+	got, _ := db.GetTask("t1")
+	if got.LastStatus != "success" || got.ConsecutiveFailures != 0 {
+		t.Errorf("last_status = %s, consecutive_failures = %d", got.LastStatus, got.ConsecutiveFailures)
+	}
+	if runs, _ := db.ListTaskRuns("t1"); len(runs) != 0 {
+		t.Errorf("dead-lettered a run that succeeded on retry: %+v", runs)
+	}
+	if len(s.retries) != 0 {
+		t.Errorf("retries left pending: %v", s.retries)
+	}
+}
+
+func TestRetriesExhaustedDeadLetters(t *testing.T) {
+	s, db, _, _ := newTestScheduler(t)
+	task := saveTask(t, db, store.ScheduledTask{ID: "t1", Name: "probe", RetryCount: 2, RetryDelay: time.Millisecond, MaxFailures: 5})
+	calls := 0
+	s.handle = func(context.Context, string, string, map[string]string) error {
+		calls++
+		return errors.New("agent rate limit exceeded")
+	}
+
+	s.run(context.Background(), task, "")
+	for range 3 {
+		s.runDueRetries(context.Background(), time.Now().Add(time.Minute))
+	}
+
+	if calls != 3 {
+		t.Errorf("ran %d attempts, want 3", calls)
+	}
+	runs, _ := db.ListTaskRuns("t1")
+	if len(runs) != 1 || runs[0].Status != store.TaskRunDeadLettered || runs[0].Attempts != 3 ||
+		runs[0].Error != "agent rate limit exceeded" {
+		t.Fatalf("task runs = %+v, want one dead letter after 3 attempts", runs)
+	}
+	if got, _ := db.GetTask("t1"); got.ConsecutiveFailures != 1 || got.Status != "active" {
+		t.Errorf("consecutive_failures = %d, status = %s; want 1, active", got.ConsecutiveFailures, got.Status)
+	}
+}
+
+func TestRetryAfterAsyncFailure(t *testing.T) {
+	s, db, handled, _ := newTestScheduler(t)
+	saveTask(t, db, store.ScheduledTask{ID: "t1", Name: "probe", RetryCount: 1, RetryDelay: time.Millisecond})
+
+	s.handleRunComplete("alpha", map[string]string{"sender": "scheduler", "task_id": "t1"}, errors.New("agent stopped: timeout"))
+	s.runDueRetries(context.Background(), time.Now().Add(time.Second))
+	if len(*handled) != 1 {
+		t.Fatalf("got %d retries, want 1", len(*handled))
+	}
+
+	s.handleRunComplete("alpha", (*handled)[0].meta, errors.New("agent stopped: timeout"))
+	if runs, _ := db.ListTaskRuns("t1"); len(runs) != 1 || runs[0].Attempts != 2 {
+		t.Errorf("task runs = %+v, want one dead letter after 2 attempts", runs)
+	}
+}
+
+func TestRetryDoesNotOverlapNextRun(t *testing.T) {
+	s, db, _, _ := newTestScheduler(t)
+	task := saveTask(t, db, store.ScheduledTask{ID: "t1", Name: "probe", RetryCount: 3, RetryDelay: time.Hour})
+	s.handle = func(context.Context, string, string, map[string]string) error {
+		return errors.New("agent rate limit exceeded")
+	}
+
+	// The interval is one minute, so an hour's delay would skip a slot.
+	s.run(context.Background(), task, "")
+	if len(s.retries) != 0 {
+		t.Errorf("retry queued past the next scheduled run")
+	}
+	if runs, _ := db.ListTaskRuns("t1"); len(runs) != 1 || runs[0].Attempts != 1 {
+		t.Errorf("task runs = %+v, want an immediate dead letter", runs)
+	}
+}
+
+func TestPollConcurrency(t *testing.T) {
+	s, db, handled, _ := newTestScheduler(t)
+	s.concurrency = 2
+	base := time.Now().Add(-time.Hour)
+	for i, id := range []string{"t3", "t1", "t5", "t2", "t4"} {
+		at := base.Add(time.Duration(i%2) * time.Minute) // t3, t5, t4 are the most overdue
+		saveTask(t, db, store.ScheduledTask{ID: id, Name: id, NextRunAt: &at})
+	}
+
+	done := 0
+	for range 10 {
+		s.poll(context.Background())
+		if inFlight := len(*handled) - done; inFlight > 2 {
+			t.Fatalf("%d runs in flight, want at most 2", inFlight)
+		}
+		if done == len(*handled) {
+			break
+		}
+		s.handleRunComplete("alpha", (*handled)[done].meta, nil)
+		done++
+	}
+
+	var order []string
+	for _, m := range *handled {
+		order = append(order, m.meta["task_id"])
+	}
+	if got := strings.Join(order, ","); got != "t3,t4,t5,t1,t2" {
+		t.Errorf("dispatch order = %s, want t3,t4,t5,t1,t2", got)
+	}
+	if depth, inflight := s.backlog, len(s.inflight); depth != 0 || inflight != 0 {
+		t.Errorf("backlog = %d, in flight = %d after all runs completed", depth, inflight)
+	}
+}
+
+func TestPollBacklogWakes(t *testing.T) {
+	s, db, handled, _ := newTestScheduler(t)
+	s.concurrency = 1
+	past := time.Now().Add(-time.Minute)
+	saveTask(t, db, store.ScheduledTask{ID: "t1", NextRunAt: &past})
+	saveTask(t, db, store.ScheduledTask{ID: "t2", NextRunAt: &past})
+
+	s.poll(context.Background())
+	if len(*handled) != 1 || s.backlog != 1 {
+		t.Fatalf("dispatched %d with backlog %d, want 1 and 1", len(*handled), s.backlog)
+	}
+	s.handleRunComplete("alpha", (*handled)[0].meta, nil)
+	select {
+	case <-s.wakeCh:
+	default:
+		t.Error("a freed slot with tasks waiting didn't wake the poll loop")
+	}
+}
+
+func TestCapacityRequeues(t *testing.T) {
+	s, db, handled, _ := newTestScheduler(t)
+	s.concurrency = 1
+	past := time.Now().Add(-time.Minute)
+	saveTask(t, db, store.ScheduledTask{ID: "t1", NextRunAt: &past, MaxFailures: 1})
+
+	s.poll(context.Background())
+	s.handleRunComplete("alpha", (*handled)[0].meta, fmt.Errorf("start agent: %w", container.ErrMaxContainers))
+	if got, _ := db.GetTask("t1"); got.ConsecutiveFailures != 0 || got.Status != "active" {
+		t.Errorf("capacity counted as a failure: consecutive_failures = %d, status = %s", got.ConsecutiveFailures, got.Status)
+	}
+	if len(s.inflight) != 0 {
+		t.Errorf("slot still held: %v", s.inflight)
+	}
+
+	s.poll(context.Background())
+	if len(*handled) != 2 {
+		t.Fatalf("got %d runs, want the requeued run dispatched again", len(*handled))
+	}
+	if attempt := (*handled)[1].meta["attempt"]; attempt != "" {
+		t.Errorf("requeued run is attempt %q, want the same first attempt", attempt)
+	}
+}
@@ -0,0 +1,65 @@
+package scheduler
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+// ContextSummary is the context_mode that runs a task without the shared
+// conversation, prefixed with a rolling summary of its earlier runs.
+const ContextSummary = "summary"
+
+const (
+	summaryMaxChars   = 4000 // whole summary; the oldest runs are dropped first
+	summaryEntryChars = 600  // one run's output
+)
+
+// taskPrompt returns the text sent for a run of task: in summary mode the
+// stored summary of earlier runs, then the prompt.
+func taskPrompt(task store.ScheduledTask) string {
+	if task.ContextMode != ContextSummary || task.Summary == "" {
+		return task.Prompt
+	}
+	return "Summary of this task's previous runs, oldest first:\n" + task.Summary + "\n\n" + task.Prompt
+}
+
+// handleTaskOutput folds the result of a summary-mode task run into the
+// task's summary.
+func (s *Scheduler) handleTaskOutput(_, content string, meta map[string]string) {
+	taskID := meta["task_id"]
+	if meta["sender"] != "scheduler" || meta["context_mode"] != ContextSummary || taskID == "" || meta["notice"] == "true" {
+		return
+	}
+	task, err := s.store.GetTask(taskID)
+	if err != nil || task == nil {
+		return
+	}
+	summary := appendSummary(task.Summary, time.Now(), content)
+	if err := s.store.UpdateTaskSummary(taskID, summary); err != nil {
+		slog.Error("failed to update task summary", "id", taskID, "error", err)
+	}
+}
+
+// appendSummary adds output as a dated one-line entry, cut to
+// summaryEntryChars, and drops the oldest entries until the summary fits in
+// summaryMaxChars.
+func appendSummary(summary string, at time.Time, output string) string {
+	entry := strings.Join(strings.Fields(output), " ")
+	if entry == "" {
+		return summary
+	}
+	if r := []rune(entry); len(r) > summaryEntryChars {
+		entry = string(r[:summaryEntryChars-1]) + "…"
+	}
+	entries := append(strings.Split(summary, "\n"), at.UTC().Format("2006-01-02 15:04")+": "+entry)
+	if summary == "" {
+		entries = entries[1:]
+	}
+	for len(entries) > 1 && len(strings.Join(entries, "\n")) > summaryMaxChars {
+		entries = entries[1:]
+	}
+	return strings.Join(entries, "\n")
+}
@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+func TestSummaryContextAcrossRuns(t *testing.T) {
+	s, db, handled, _ := newTestScheduler(t)
+	task := saveTask(t, db, store.ScheduledTask{ID: "t1", Name: "report", Prompt: "Write the daily report", ContextMode: ContextSummary})
+
+	s.run(context.Background(), task, "")
+	if got := (*handled)[0]; got.text != "Write the daily report" || got.meta["context_mode"] != ContextSummary {
+		t.Fatalf("first run sent %q (context_mode %q)", got.text, got.meta["context_mode"])
+	}
+	s.handleTaskOutput("alpha", "Revenue up\n3%.", (*handled)[0].meta)
+
+	task1, _ := db.GetTask("t1")
+	if !strings.HasSuffix(task1.Summary, ": Revenue up 3%.") {
+		t.Fatalf("summary after run 1 = %q", task1.Summary)
+	}
+
+	s.run(context.Background(), *task1, "")
+	second := (*handled)[1].text
+	if !strings.Contains(second, "Revenue up 3%.") || !strings.HasSuffix(second, "\n\nWrite the daily report") {
+		t.Errorf("second run sent %q, want the summary then the prompt", second)
+	}
+	s.handleTaskOutput("alpha", "Revenue flat.", (*handled)[1].meta)
+	if got, _ := db.GetTask("t1"); strings.Count(got.Summary, "\n") != 1 || !strings.HasSuffix(got.Summary, ": Revenue flat.") {
+		t.Errorf("summary after run 2 = %q", got.Summary)
+	}
+
+	// Other tasks and senders leave the summary alone.
+	other := saveTask(t, db, store.ScheduledTask{ID: "t2", Name: "probe", ContextMode: "isolated"})
+	s.handleTaskOutput("alpha", "ok", map[string]string{"sender": "scheduler", "task_id": other.ID, "context_mode": "isolated"})
+	s.handleTaskOutput("alpha", "hi", map[string]string{"sender": "user", "task_id": "t1", "context_mode": ContextSummary})
+	if got, _ := db.GetTask("t2"); got.Summary != "" {
+		t.Errorf("isolated task got summary %q", got.Summary)
+	}
+	if got, _ := db.GetTask("t1"); strings.Contains(got.Summary, "hi") {
+		t.Errorf("user output folded into the summary: %q", got.Summary)
+	}
+}
+
+func TestAppendSummaryBounded(t *testing.T) {
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	summary := ""
+	for i := range 50 {
+		summary = appendSummary(summary, at.Add(time.Duration(i)*24*time.Hour), strings.Repeat("x", 1000))
+	}
+	if n := len(summary); n > summaryMaxChars {
+		t.Errorf("summary is %d bytes, want at most %d", n, summaryMaxChars)
+	}
+	lines := strings.Split(summary, "\n")
+	if !strings.HasPrefix(lines[len(lines)-1], "2026-04-19 09:00: ") {
+		t.Errorf("newest entry = %q", lines[len(lines)-1][:30])
+	}
+	if !strings.HasSuffix(lines[0], "…") {
+		t.Error("long output not cut")
+	}
+	if got := appendSummary("old", at, "  \n "); got != "old" {
+		t.Errorf("empty output changed the summary to %q", got)
+	}
+}
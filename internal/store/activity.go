@@ -0,0 +1,190 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Activity item types, one per source table.
+const (
+	ActivityMessage   = "message"
+	ActivityLifecycle = "lifecycle"
+	ActivityTaskRun   = "task_run"
+	ActivitySwarm     = "swarm"
+)
+
+// ActivityTypes lists every activity item type.
+var ActivityTypes = []string{ActivityMessage, ActivityLifecycle, ActivityTaskRun, ActivitySwarm}
+
+// AgentEvent is a recorded agent lifecycle transition.
+type AgentEvent struct {
+	ID          int64     `json:"id"`
+	AgentID     string    `json:"agent_id"`
+	Type        string    `json:"type"`
+	Reason      string    `json:"reason,omitempty"`
+	ContainerID string    `json:"container_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DeleteAgentEventsBefore removes lifecycle events recorded before cutoff
+// and returns how many were removed.
+func (s *Store) DeleteAgentEventsBefore(cutoff time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM agent_events WHERE created_at < ?`, cutoff.UTC().Format(time.DateTime))
+	if err != nil {
+		return 0, fmt.Errorf("delete agent events: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (s *Store) AddAgentEvent(e *AgentEvent) error {
+	res, err := s.db.Exec(`INSERT INTO agent_events (agent_id, type, reason, container_id) VALUES (?, ?, ?, ?)`,
+		e.AgentID, e.Type, e.Reason, e.ContainerID)
+	if err != nil {
+		return fmt.Errorf("add agent event: %w", err)
+	}
+	e.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// ActivityItem is one entry of the activity feed. Type says which source it
+// came from and how to read the other fields:
+//
+//   - message: ID is the message id, Status the sender, Text the content
+//     (truncated).
+//   - lifecycle: Status is the event type (agent_started, ...), Text the
+//     reason.
+//   - task_run: ID is the task id, Name the task name, Status the run status
+//     and Text its error.
+//   - swarm: ID is the swarm id, Name the swarm name, Status "started" or the
+//     final status, Text the task.
+type ActivityItem struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	ID      string    `json:"id"`
+	AgentID string    `json:"agent_id"`
+	Name    string    `json:"name,omitempty"`
+	Status  string    `json:"status"`
+	Text    string    `json:"text,omitempty"`
+	Cursor  string    `json:"cursor"` // ActivityCursor of the item, for paging back
+}
+
+// ActivityCursor is an item's place in the feed's order: time, then the
+// source rowid, then type. Paging by it doesn't skip items that share a
+// second with the last one seen.
+type ActivityCursor struct {
+	Time time.Time
+	Seq  int64
+	Type string
+}
+
+// String encodes c as "<unix seconds>.<seq>.<type>".
+func (c ActivityCursor) String() string {
+	return fmt.Sprintf("%d.%d.%s", c.Time.Unix(), c.Seq, c.Type)
+}
+
+// ParseActivityCursor decodes a cursor produced by ActivityCursor.String.
+func ParseActivityCursor(s string) (ActivityCursor, error) {
+	parts := strings.SplitN(s, ".", 3)
+	if len(parts) != 3 {
+		return ActivityCursor{}, fmt.Errorf("invalid activity cursor %q", s)
+	}
+	sec, err1 := strconv.ParseInt(parts[0], 10, 64)
+	seq, err2 := strconv.ParseInt(parts[1], 10, 64)
+	if err1 != nil || err2 != nil || parts[2] == "" {
+		return ActivityCursor{}, fmt.Errorf("invalid activity cursor %q", s)
+	}
+	return ActivityCursor{Time: time.Unix(sec, 0).UTC(), Seq: seq, Type: parts[2]}, nil
+}
+
+// ActivityFilter narrows ListActivity. Zero values mean no bound.
+type ActivityFilter struct {
+	Since  time.Time       // only items after this
+	Before time.Time       // only items before this
+	After  *ActivityCursor // only items that come after this one, for paging back
+	Types  []string        // only these types; all when empty
+	Limit  int
+}
+
+// activityQuery merges every source into (type, time, seq, id, agent_id,
+// name, status, text). seq is the source rowid, which orders items recorded
+// in the same second. A swarm contributes a "started" item and, once
+// finished, one for its final status. Message content is capped at 200
+// characters.
+const activityQuery = `
+	SELECT 'message' AS type, created_at AS time, id AS seq, CAST(id AS TEXT) AS id, agent_id,
+		'' AS name, sender AS status, substr(content, 1, 200) AS text
+	FROM messages
+	UNION ALL
+	SELECT 'lifecycle', created_at, id, CAST(id AS TEXT), agent_id, '', type, COALESCE(reason, '')
+	FROM agent_events
+	UNION ALL
+	SELECT 'task_run', r.created_at, r.id, r.task_id, t.agent_id, t.name, r.status, COALESCE(r.error, '')
+	FROM task_runs r JOIN scheduled_tasks t ON t.id = r.task_id
+	UNION ALL
+	SELECT 'swarm', started_at, rowid, id, agent_id, COALESCE(name, ''), 'started', task
+	FROM swarm_runs
+	UNION ALL
+	SELECT 'swarm', completed_at, rowid, id, agent_id, COALESCE(name, ''), status, task
+	FROM swarm_runs WHERE completed_at IS NOT NULL`
+
+// ListActivity returns items from messages, agent lifecycle events, task
+// runs and swarm runs, newest first. Times have second resolution, so
+// items sharing a second with f.Before are not returned; page with f.After
+// instead.
+func (s *Store) ListActivity(f ActivityFilter) ([]ActivityItem, error) {
+	var where []string
+	var args []any
+	if !f.Since.IsZero() {
+		where = append(where, "time > ?")
+		args = append(args, f.Since.UTC().Format(time.DateTime))
+	}
+	if !f.Before.IsZero() {
+		where = append(where, "time < ?")
+		args = append(args, f.Before.UTC().Format(time.DateTime))
+	}
+	if c := f.After; c != nil {
+		at := c.Time.UTC().Format(time.DateTime)
+		where = append(where, "(time < ? OR (time = ? AND (seq < ? OR (seq = ? AND type > ?))))")
+		args = append(args, at, at, c.Seq, c.Seq, c.Type)
+	}
+	if len(f.Types) > 0 {
+		where = append(where, "type IN (?"+strings.Repeat(", ?", len(f.Types)-1)+")")
+		for _, t := range f.Types {
+			args = append(args, t)
+		}
+	}
+
+	q := "SELECT type, time, seq, id, agent_id, name, status, text FROM (" + activityQuery + ")"
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY time DESC, seq DESC, type"
+	if f.Limit > 0 {
+		q += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list activity: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var items []ActivityItem
+	for rows.Next() {
+		var it ActivityItem
+		var at *string
+		var seq int64
+		if err := rows.Scan(&it.Type, &at, &seq, &it.ID, &it.AgentID, &it.Name, &it.Status, &it.Text); err != nil {
+			return nil, fmt.Errorf("scan activity: %w", err)
+		}
+		if t := scanTimeString(at); t != nil {
+			it.Time = *t
+		}
+		it.Cursor = ActivityCursor{Time: it.Time, Seq: seq, Type: it.Type}.String()
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
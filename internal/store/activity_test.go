@@ -0,0 +1,132 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListActivity(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SaveAgent(&Agent{ID: "alice", Name: "Alice", Workspace: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveTask(&ScheduledTask{ID: "t1", AgentID: "alice", Name: "Nightly", Schedule: `{"kind":"cron","cron_expr":"0 0 * * *"}`, Prompt: "run", Status: "active"}); err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	at := func(min int) string { return base.Add(time.Duration(min) * time.Minute).Format(time.DateTime) }
+	for _, q := range []struct {
+		sql  string
+		args []any
+	}{
+		{`INSERT INTO messages (agent_id, sender, content, created_at) VALUES ('alice', 'user', 'hello', ?)`, []any{at(0)}},
+		{`INSERT INTO agent_events (agent_id, type, created_at) VALUES ('alice', 'agent_started', ?)`, []any{at(1)}},
+		{`INSERT INTO swarm_runs (id, name, agent_id, task, status, agents, started_at, completed_at) VALUES ('s1', 'Research', 'alice', 'dig', 'completed', '[]', ?, ?)`, []any{at(2), at(5)}},
+		{`INSERT INTO task_runs (task_id, status, attempts, error, created_at) VALUES ('t1', 'dead_letter', 3, 'boom', ?)`, []any{at(3)}},
+		{`INSERT INTO messages (agent_id, sender, content, created_at) VALUES ('alice', 'agent', 'hi', ?)`, []any{at(3)}},
+		{`INSERT INTO agent_events (agent_id, type, reason, created_at) VALUES ('alice', 'agent_stopped', 'idle_timeout', ?)`, []any{at(6)}},
+	} {
+		if _, err := s.db.Exec(q.sql, q.args...); err != nil {
+			t.Fatalf("%s: %v", q.sql, err)
+		}
+	}
+
+	type item struct{ typ, status string }
+	check := func(t *testing.T, f ActivityFilter, want []item) {
+		t.Helper()
+		got, err := s.ListActivity(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %d items %+v, want %d", len(got), got, len(want))
+		}
+		for i, w := range want {
+			if got[i].Type != w.typ || got[i].Status != w.status {
+				t.Errorf("item %d = %s/%s, want %s/%s", i, got[i].Type, got[i].Status, w.typ, w.status)
+			}
+		}
+	}
+
+	t.Run("merged newest first", func(t *testing.T) {
+		check(t, ActivityFilter{}, []item{
+			{ActivityLifecycle, "agent_stopped"},
+			{ActivitySwarm, "completed"},
+			{ActivityMessage, "agent"},
+			{ActivityTaskRun, "dead_letter"},
+			{ActivitySwarm, "started"},
+			{ActivityLifecycle, "agent_started"},
+			{ActivityMessage, "user"},
+		})
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		got, err := s.ListActivity(ActivityFilter{Types: []string{ActivityTaskRun}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 {
+			t.Fatalf("got %d task runs", len(got))
+		}
+		r := got[0]
+		if r.ID != "t1" || r.AgentID != "alice" || r.Name != "Nightly" || r.Text != "boom" || !r.Time.Equal(base.Add(3*time.Minute)) {
+			t.Errorf("task run item = %+v", r)
+		}
+	})
+
+	t.Run("types", func(t *testing.T) {
+		check(t, ActivityFilter{Types: []string{ActivityMessage, ActivitySwarm}}, []item{
+			{ActivitySwarm, "completed"},
+			{ActivityMessage, "agent"},
+			{ActivitySwarm, "started"},
+			{ActivityMessage, "user"},
+		})
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		check(t, ActivityFilter{Limit: 2}, []item{
+			{ActivityLifecycle, "agent_stopped"},
+			{ActivitySwarm, "completed"},
+		})
+		check(t, ActivityFilter{Before: base.Add(5 * time.Minute), Limit: 3}, []item{
+			{ActivityMessage, "agent"},
+			{ActivityTaskRun, "dead_letter"},
+			{ActivitySwarm, "started"},
+		})
+		check(t, ActivityFilter{Since: base.Add(time.Minute), Before: base.Add(5 * time.Minute)}, []item{
+			{ActivityMessage, "agent"},
+			{ActivityTaskRun, "dead_letter"},
+			{ActivitySwarm, "started"},
+		})
+	})
+
+	t.Run("cursor pages", func(t *testing.T) {
+		// The first page ends between two items of the same second.
+		page, err := s.ListActivity(ActivityFilter{Limit: 3})
+		if err != nil || len(page) != 3 {
+			t.Fatalf("first page = %v, %v", page, err)
+		}
+		cursor, err := ParseActivityCursor(page[2].Cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, ActivityFilter{After: &cursor, Limit: 2}, []item{
+			{ActivityTaskRun, "dead_letter"},
+			{ActivitySwarm, "started"},
+		})
+		if _, err := ParseActivityCursor("soon"); err == nil {
+			t.Error("ParseActivityCursor accepted an invalid cursor")
+		}
+	})
+
+	t.Run("pruning lifecycle events", func(t *testing.T) {
+		n, err := s.DeleteAgentEventsBefore(base.Add(5 * time.Minute))
+		if err != nil || n != 1 {
+			t.Fatalf("DeleteAgentEventsBefore = %d, %v; want 1", n, err)
+		}
+		check(t, ActivityFilter{Types: []string{ActivityLifecycle}}, []item{
+			{ActivityLifecycle, "agent_stopped"},
+		})
+	})
+}
@@ -2,8 +2,12 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Agent struct {
@@ -14,14 +18,30 @@ type Agent struct {
 	Image       string    `json:"image,omitempty"`
 	Workspace   string    `json:"workspace"`
 	ClaudeMD    string    `json:"claude_md,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`  // organizational labels, e.g. "team:infra"
+	Group       string    `json:"group,omitempty"` // agents started and stopped together
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// HasTags reports whether the agent carries every one of tags.
+func (a *Agent) HasTags(tags ...string) bool {
+	for _, t := range tags {
+		if !slices.Contains(a.Tags, t) {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Store) SaveAgent(a *Agent) error {
+	tags, _ := json.Marshal(a.Tags)
+	if a.Tags == nil {
+		tags = []byte("[]")
+	}
 	_, err := s.db.Exec(`
-		INSERT INTO agents (id, name, description, model, image, workspace, claude_md, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO agents (id, name, description, model, image, workspace, claude_md, tags, group_name, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		ON CONFLICT(id) DO UPDATE SET
 			name = excluded.name,
 			description = excluded.description,
@@ -29,8 +49,10 @@ func (s *Store) SaveAgent(a *Agent) error {
 			image = excluded.image,
 			workspace = excluded.workspace,
 			claude_md = excluded.claude_md,
+			tags = excluded.tags,
+			group_name = excluded.group_name,
 			updated_at = CURRENT_TIMESTAMP`,
-		a.ID, a.Name, a.Description, a.Model, a.Image, a.Workspace, a.ClaudeMD)
+		a.ID, a.Name, a.Description, a.Model, a.Image, a.Workspace, a.ClaudeMD, string(tags), a.Group)
 	if err != nil {
 		return fmt.Errorf("save agent: %w", err)
 	}
@@ -39,9 +61,9 @@ func (s *Store) SaveAgent(a *Agent) error {
 
 func (s *Store) GetAgent(id string) (*Agent, error) {
 	a := &Agent{}
-	var description, model, image, claudeMD sql.NullString
-	err := s.db.QueryRow(`SELECT id, name, description, model, image, workspace, claude_md, created_at, updated_at FROM agents WHERE id = ?`, id).
-		Scan(&a.ID, &a.Name, &description, &model, &image, &a.Workspace, &claudeMD, &a.CreatedAt, &a.UpdatedAt)
+	var description, model, image, claudeMD, tags, group sql.NullString
+	err := s.db.QueryRow(`SELECT id, name, description, model, image, workspace, claude_md, tags, group_name, created_at, updated_at FROM agents WHERE id = ?`, id).
+		Scan(&a.ID, &a.Name, &description, &model, &image, &a.Workspace, &claudeMD, &tags, &group, &a.CreatedAt, &a.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -52,11 +74,13 @@ func (s *Store) GetAgent(id string) (*Agent, error) {
 	a.Model = model.String
 	a.Image = image.String
 	a.ClaudeMD = claudeMD.String
+	a.Tags = decodeTags(tags)
+	a.Group = group.String
 	return a, nil
 }
 
 func (s *Store) ListAgents() ([]Agent, error) {
-	rows, err := s.db.Query(`SELECT id, name, description, model, image, workspace, claude_md, created_at, updated_at FROM agents ORDER BY created_at`)
+	rows, err := s.db.Query(`SELECT id, name, description, model, image, workspace, claude_md, tags, group_name, created_at, updated_at FROM agents ORDER BY created_at`)
 	if err != nil {
 		return nil, fmt.Errorf("list agents: %w", err)
 	}
@@ -65,19 +89,74 @@ func (s *Store) ListAgents() ([]Agent, error) {
 	var agents []Agent
 	for rows.Next() {
 		var a Agent
-		var description, model, image, claudeMD sql.NullString
-		if err := rows.Scan(&a.ID, &a.Name, &description, &model, &image, &a.Workspace, &claudeMD, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		var description, model, image, claudeMD, tags, group sql.NullString
+		if err := rows.Scan(&a.ID, &a.Name, &description, &model, &image, &a.Workspace, &claudeMD, &tags, &group, &a.CreatedAt, &a.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("scan agent: %w", err)
 		}
 		a.Description = description.String
 		a.Model = model.String
 		a.Image = image.String
 		a.ClaudeMD = claudeMD.String
+		a.Tags = decodeTags(tags)
+		a.Group = group.String
 		agents = append(agents, a)
 	}
 	return agents, rows.Err()
 }
 
+// ListAgentGroups returns the ids of the agents in each group, sorted.
+// Agents without a group are left out.
+func (s *Store) ListAgentGroups() (map[string][]string, error) {
+	rows, err := s.db.Query(`SELECT group_name, id FROM agents WHERE COALESCE(group_name, '') != '' ORDER BY group_name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("list agent groups: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	groups := make(map[string][]string)
+	for rows.Next() {
+		var group, id string
+		if err := rows.Scan(&group, &id); err != nil {
+			return nil, fmt.Errorf("scan agent group: %w", err)
+		}
+		groups[group] = append(groups[group], id)
+	}
+	return groups, rows.Err()
+}
+
+// AgentSessionID returns the Claude session id the agent's containers run
+// under, assigning one on first use. It survives container restarts; only
+// RotateAgentSessionID replaces it.
+func (s *Store) AgentSessionID(agentID string) (string, error) {
+	if _, err := s.db.Exec(`UPDATE agents SET session_id = ? WHERE id = ? AND COALESCE(session_id, '') = ''`,
+		uuid.NewString(), agentID); err != nil {
+		return "", fmt.Errorf("assign session id: %w", err)
+	}
+	var id string
+	err := s.db.QueryRow(`SELECT session_id FROM agents WHERE id = ?`, agentID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("agent %s: %w", agentID, ErrNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("get session id: %w", err)
+	}
+	return id, nil
+}
+
+// RotateAgentSessionID replaces the agent's session id with a fresh one and
+// returns it, so the next conversation starts from scratch.
+func (s *Store) RotateAgentSessionID(agentID string) (string, error) {
+	id := uuid.NewString()
+	res, err := s.db.Exec(`UPDATE agents SET session_id = ? WHERE id = ?`, id, agentID)
+	if err != nil {
+		return "", fmt.Errorf("rotate session id: %w", err)
+	}
+	if err := requireAffected(res, "agent", agentID); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
 func (s *Store) DeleteAgent(id string) error {
 	_, err := s.db.Exec(`DELETE FROM agents WHERE id = ?`, id)
 	return err
@@ -101,3 +180,14 @@ func (s *Store) DeleteAgentsNotIn(ids []string) error {
 	_, err := s.db.Exec(query, args...)
 	return err
 }
+
+func decodeTags(raw sql.NullString) []string {
+	var tags []string
+	if raw.String != "" {
+		_ = json.Unmarshal([]byte(raw.String), &tags)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Artifact is a file an agent saved with save_artifact. The content lives
+// in the artifact store under its SHA-256, so identical artifacts share a
+// blob.
+type Artifact struct {
+	AgentID   string    `json:"agent_id"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SaveArtifact records an artifact, replacing any earlier one the agent
+// saved under the same name.
+func (s *Store) SaveArtifact(a *Artifact) error {
+	_, err := s.db.Exec(`
+		INSERT INTO artifacts (agent_id, name, size, sha256) VALUES (?, ?, ?, ?)
+		ON CONFLICT(agent_id, name) DO UPDATE SET
+			size = excluded.size, sha256 = excluded.sha256, created_at = CURRENT_TIMESTAMP`,
+		a.AgentID, a.Name, a.Size, a.SHA256)
+	if err != nil {
+		return fmt.Errorf("save artifact: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetArtifact(agentID, name string) (*Artifact, error) {
+	a := &Artifact{}
+	err := s.db.QueryRow(`SELECT agent_id, name, size, sha256, created_at FROM artifacts WHERE agent_id = ? AND name = ?`, agentID, name).
+		Scan(&a.AgentID, &a.Name, &a.Size, &a.SHA256, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get artifact: %w", err)
+	}
+	return a, nil
+}
+
+// ListArtifacts returns an agent's artifacts, newest first.
+func (s *Store) ListArtifacts(agentID string) ([]Artifact, error) {
+	rows, err := s.db.Query(`SELECT agent_id, name, size, sha256, created_at FROM artifacts WHERE agent_id = ? ORDER BY created_at DESC, name`, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("list artifacts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var artifacts []Artifact
+	for rows.Next() {
+		var a Artifact
+		if err := rows.Scan(&a.AgentID, &a.Name, &a.Size, &a.SHA256, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan artifact: %w", err)
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
+
+// DeleteArtifactsBefore removes artifacts saved before cutoff and returns
+// how many were removed.
+func (s *Store) DeleteArtifactsBefore(cutoff time.Time) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM artifacts WHERE created_at < ?`, cutoff.UTC().Format(time.DateTime))
+	if err != nil {
+		return 0, fmt.Errorf("delete artifacts: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// ArtifactBlobs returns the set of checksums still referenced by an
+// artifact.
+func (s *Store) ArtifactBlobs() (map[string]bool, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT sha256 FROM artifacts`)
+	if err != nil {
+		return nil, fmt.Errorf("list artifact blobs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	blobs := make(map[string]bool)
+	for rows.Next() {
+		var sum string
+		if err := rows.Scan(&sum); err != nil {
+			return nil, fmt.Errorf("scan artifact blob: %w", err)
+		}
+		blobs[sum] = true
+	}
+	return blobs, rows.Err()
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArtifacts(t *testing.T) {
+	s := newTestStore(t)
+	for _, a := range []*Artifact{
+		{AgentID: "alice", Name: "report.md", Size: 3, SHA256: "aaa"},
+		{AgentID: "alice", Name: "data.csv", Size: 5, SHA256: "bbb"},
+		{AgentID: "bob", Name: "report.md", Size: 3, SHA256: "aaa"},
+	} {
+		if err := s.SaveArtifact(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Saving a name again replaces the artifact.
+	if err := s.SaveArtifact(&Artifact{AgentID: "alice", Name: "data.csv", Size: 7, SHA256: "ccc"}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := s.GetArtifact("alice", "data.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == nil || a.Size != 7 || a.SHA256 != "ccc" {
+		t.Errorf("replaced artifact = %+v", a)
+	}
+	if a, err := s.GetArtifact("bob", "data.csv"); err != nil || a != nil {
+		t.Errorf("other agent's artifact = %+v, %v", a, err)
+	}
+
+	list, err := s.ListArtifacts("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("got %d artifacts for alice, want 2", len(list))
+	}
+
+	blobs, err := s.ArtifactBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 2 || !blobs["aaa"] || !blobs["ccc"] || blobs["bbb"] {
+		t.Errorf("blobs = %v", blobs)
+	}
+
+	if _, err := s.db.Exec(`UPDATE artifacts SET created_at = ? WHERE name = 'report.md'`, "2026-01-01 00:00:00"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.DeleteArtifactsBefore(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("deleted %d artifacts, want 2", n)
+	}
+	if blobs, _ := s.ArtifactBlobs(); len(blobs) != 1 || !blobs["ccc"] {
+		t.Errorf("blobs after delete = %v", blobs)
+	}
+}
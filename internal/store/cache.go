@@ -0,0 +1,60 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CachedResponse is an agent result cached for an identical prompt.
+type CachedResponse struct {
+	AgentID    string
+	PromptHash string
+	Response   string
+	CreatedAt  time.Time
+}
+
+// GetCachedResponse returns the cached response for the prompt hash if it
+// was stored at or after notBefore. Returns nil when missing or expired.
+func (s *Store) GetCachedResponse(agentID, promptHash string, notBefore time.Time) (*CachedResponse, error) {
+	c := &CachedResponse{AgentID: agentID, PromptHash: promptHash}
+	var createdAt string
+	err := s.db.QueryRow(`
+		SELECT response, created_at FROM response_cache
+		WHERE agent_id = ? AND prompt_hash = ?`, agentID, promptHash).Scan(&c.Response, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get cached response: %w", err)
+	}
+	t, err := parseTimeString(createdAt)
+	if err != nil || t.Before(notBefore) {
+		return nil, nil
+	}
+	c.CreatedAt = t
+	return c, nil
+}
+
+// SaveCachedResponse stores (or replaces) the cached response for a prompt hash.
+func (s *Store) SaveCachedResponse(agentID, promptHash, response string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO response_cache (agent_id, prompt_hash, response, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(agent_id, prompt_hash) DO UPDATE SET
+			response = excluded.response,
+			created_at = excluded.created_at`,
+		agentID, promptHash, response, time.Now().UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("save cached response: %w", err)
+	}
+	return nil
+}
+
+// DeleteCachedResponses drops every cached response for an agent.
+func (s *Store) DeleteCachedResponses(agentID string) error {
+	if _, err := s.db.Exec(`DELETE FROM response_cache WHERE agent_id = ?`, agentID); err != nil {
+		return fmt.Errorf("delete cached responses: %w", err)
+	}
+	return nil
+}
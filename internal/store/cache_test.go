@@ -0,0 +1,61 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SaveAgent(&Agent{ID: "general", Name: "General", Workspace: "general"}); err != nil {
+		t.Fatalf("save agent: %v", err)
+	}
+
+	// Miss
+	got, err := s.GetCachedResponse("general", "h1", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("get cached response: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("expected miss, got %+v", got)
+	}
+
+	// Hit
+	if err := s.SaveCachedResponse("general", "h1", "first"); err != nil {
+		t.Fatalf("save cached response: %v", err)
+	}
+	got, err = s.GetCachedResponse("general", "h1", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("get cached response: %v", err)
+	}
+	if got == nil || got.Response != "first" {
+		t.Fatalf("expected hit with 'first', got %+v", got)
+	}
+
+	// Replace
+	if err := s.SaveCachedResponse("general", "h1", "second"); err != nil {
+		t.Fatalf("save cached response: %v", err)
+	}
+	got, _ = s.GetCachedResponse("general", "h1", time.Now().Add(-time.Hour))
+	if got == nil || got.Response != "second" {
+		t.Fatalf("expected replaced response 'second', got %+v", got)
+	}
+
+	// Expired
+	got, err = s.GetCachedResponse("general", "h1", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("get cached response: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("expected expired entry to miss, got %+v", got)
+	}
+
+	// Invalidate
+	if err := s.DeleteCachedResponses("general"); err != nil {
+		t.Fatalf("delete cached responses: %v", err)
+	}
+	got, _ = s.GetCachedResponse("general", "h1", time.Now().Add(-time.Hour))
+	if got != nil {
+		t.Fatalf("expected miss after invalidation, got %+v", got)
+	}
+}
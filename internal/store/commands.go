@@ -0,0 +1,57 @@
+package store
+
+import "fmt"
+
+// AgentCommand is a slash command an agent registered for itself.
+// PromptTemplate is what gets sent to the agent when the command is used.
+type AgentCommand struct {
+	AgentID        string `json:"agent_id"`
+	Command        string `json:"command"`
+	Description    string `json:"description"`
+	PromptTemplate string `json:"prompt_template"`
+}
+
+// SetAgentCommands replaces the agent's registered commands.
+func (s *Store) SetAgentCommands(agentID string, cmds []AgentCommand) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM agent_commands WHERE agent_id = ?`, agentID); err != nil {
+		return fmt.Errorf("clear agent commands: %w", err)
+	}
+	for i, c := range cmds {
+		if _, err := tx.Exec(`
+			INSERT INTO agent_commands (agent_id, command, description, prompt_template, sort_order)
+			VALUES (?, ?, ?, ?, ?)`,
+			agentID, c.Command, c.Description, c.PromptTemplate, i); err != nil {
+			return fmt.Errorf("insert agent command: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListAgentCommands returns the registered commands of all agents, grouped
+// by agent in registration order.
+func (s *Store) ListAgentCommands() ([]AgentCommand, error) {
+	rows, err := s.db.Query(`
+		SELECT agent_id, command, description, prompt_template
+		FROM agent_commands
+		ORDER BY agent_id, sort_order`)
+	if err != nil {
+		return nil, fmt.Errorf("list agent commands: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var cmds []AgentCommand
+	for rows.Next() {
+		var c AgentCommand
+		if err := rows.Scan(&c.AgentID, &c.Command, &c.Description, &c.PromptTemplate); err != nil {
+			return nil, fmt.Errorf("scan agent command: %w", err)
+		}
+		cmds = append(cmds, c)
+	}
+	return cmds, rows.Err()
+}
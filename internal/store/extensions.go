@@ -215,9 +215,5 @@ func (s *Store) SetExtensionStatus(agentID, statusJSON string) error {
 	if err != nil {
 		return fmt.Errorf("set extension status: %w", err)
 	}
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		return fmt.Errorf("agent not found: %s", agentID)
-	}
-	return nil
+	return requireAffected(result, "agent", agentID)
 }
@@ -312,7 +312,7 @@ func TestExtensionsMigrateFromBlob(t *testing.T) {
 	}
 
 	// Run migration
-	if err := s.migrateExtensionsToTables(); err != nil {
+	if err := migrateExtensionsToTables(s.db); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 
@@ -350,7 +350,7 @@ func TestExtensionsMigrateFromBlob(t *testing.T) {
 	}
 
 	// Migration is idempotent — running again should not error
-	if err := s.migrateExtensionsToTables(); err != nil {
+	if err := migrateExtensionsToTables(s.db); err != nil {
 		t.Fatalf("second migrate: %v", err)
 	}
 }
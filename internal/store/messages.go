@@ -1,8 +1,10 @@
 package store
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"time"
 )
 
@@ -12,14 +14,15 @@ type Message struct {
 	Sender    string          `json:"sender"`
 	Content   string          `json:"content"`
 	Metadata  json.RawMessage `json:"metadata,omitempty"`
+	ReplyTo   *int64          `json:"reply_to,omitempty"` // id of the message this one answers
 	CreatedAt time.Time       `json:"created_at"`
 }
 
 func (s *Store) SaveMessage(msg *Message) error {
 	result, err := s.db.Exec(`
-		INSERT INTO messages (agent_id, sender, content, metadata)
-		VALUES (?, ?, ?, ?)`,
-		msg.AgentID, msg.Sender, msg.Content, msg.Metadata)
+		INSERT INTO messages (agent_id, sender, content, metadata, reply_to)
+		VALUES (?, ?, ?, ?, ?)`,
+		msg.AgentID, msg.Sender, msg.Content, msg.Metadata, msg.ReplyTo)
 	if err != nil {
 		return fmt.Errorf("save message: %w", err)
 	}
@@ -32,7 +35,7 @@ func (s *Store) GetMessages(agentID string, limit int) ([]Message, error) {
 		limit = 50
 	}
 	rows, err := s.db.Query(`
-		SELECT id, agent_id, sender, content, metadata, created_at
+		SELECT id, agent_id, sender, content, metadata, reply_to, created_at
 		FROM messages
 		WHERE agent_id = ?
 		ORDER BY created_at DESC
@@ -42,17 +45,9 @@ func (s *Store) GetMessages(agentID string, limit int) ([]Message, error) {
 	}
 	defer func() { _ = rows.Close() }()
 
-	var messages []Message
-	for rows.Next() {
-		var m Message
-		var metadata *string
-		if err := rows.Scan(&m.ID, &m.AgentID, &m.Sender, &m.Content, &metadata, &m.CreatedAt); err != nil {
-			return nil, fmt.Errorf("scan message: %w", err)
-		}
-		if metadata != nil {
-			m.Metadata = json.RawMessage(*metadata)
-		}
-		messages = append(messages, m)
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
 	}
 
 	// Reverse to get chronological order
@@ -60,7 +55,61 @@ func (s *Store) GetMessages(agentID string, limit int) ([]Message, error) {
 		messages[i], messages[j] = messages[j], messages[i]
 	}
 
-	return messages, rows.Err()
+	return messages, nil
+}
+
+// GetMessagesBefore returns up to limit of the agent's messages with an id
+// below before (0 = from the newest), in chronological order. Pass the
+// first returned id as the next before to page backwards.
+func (s *Store) GetMessagesBefore(agentID string, before int64, limit int) ([]Message, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	query := `
+		SELECT id, agent_id, sender, content, metadata, reply_to, created_at
+		FROM messages
+		WHERE agent_id = ?`
+	args := []any{agentID}
+	if before > 0 {
+		query += ` AND id < ?`
+		args = append(args, before)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get messages before: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+// GetLastAgentReply returns the agent's most recent non-empty reply, or nil
+// if it has none.
+func (s *Store) GetLastAgentReply(agentID string) (*Message, error) {
+	rows, err := s.db.Query(`
+		SELECT id, agent_id, sender, content, metadata, reply_to, created_at
+		FROM messages
+		WHERE agent_id = ? AND sender = 'agent' AND content != ''
+		ORDER BY id DESC
+		LIMIT 1`, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("get last agent reply: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	messages, err := scanMessages(rows)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+	return &messages[0], nil
 }
 
 func (s *Store) GetRecentMessages(limit int) ([]Message, error) {
@@ -68,7 +117,7 @@ func (s *Store) GetRecentMessages(limit int) ([]Message, error) {
 		limit = 50
 	}
 	rows, err := s.db.Query(`
-		SELECT id, agent_id, sender, content, metadata, created_at
+		SELECT id, agent_id, sender, content, metadata, reply_to, created_at
 		FROM messages
 		ORDER BY created_at DESC
 		LIMIT ?`, limit)
@@ -77,11 +126,17 @@ func (s *Store) GetRecentMessages(limit int) ([]Message, error) {
 	}
 	defer func() { _ = rows.Close() }()
 
+	return scanMessages(rows)
+}
+
+// scanMessages reads rows selected as (id, agent_id, sender, content,
+// metadata, reply_to, created_at).
+func scanMessages(rows *sql.Rows) ([]Message, error) {
 	var messages []Message
 	for rows.Next() {
 		var m Message
 		var metadata *string
-		if err := rows.Scan(&m.ID, &m.AgentID, &m.Sender, &m.Content, &metadata, &m.CreatedAt); err != nil {
+		if err := rows.Scan(&m.ID, &m.AgentID, &m.Sender, &m.Content, &metadata, &m.ReplyTo, &m.CreatedAt); err != nil {
 			return nil, fmt.Errorf("scan message: %w", err)
 		}
 		if metadata != nil {
@@ -103,7 +158,7 @@ func (s *Store) SearchMessages(agentID, query string, limit int) ([]Message, err
 		limit = 20
 	}
 	rows, err := s.db.Query(`
-		SELECT m.id, m.agent_id, m.sender, m.content, m.metadata, m.created_at
+		SELECT m.id, m.agent_id, m.sender, m.content, m.metadata, m.reply_to, m.created_at
 		FROM messages_fts f
 		JOIN messages m ON m.id = f.rowid
 		WHERE f.content MATCH ? AND m.agent_id = ?
@@ -114,19 +169,7 @@ func (s *Store) SearchMessages(agentID, query string, limit int) ([]Message, err
 	}
 	defer func() { _ = rows.Close() }()
 
-	var messages []Message
-	for rows.Next() {
-		var m Message
-		var metadata *string
-		if err := rows.Scan(&m.ID, &m.AgentID, &m.Sender, &m.Content, &metadata, &m.CreatedAt); err != nil {
-			return nil, fmt.Errorf("scan message: %w", err)
-		}
-		if metadata != nil {
-			m.Metadata = json.RawMessage(*metadata)
-		}
-		messages = append(messages, m)
-	}
-	return messages, rows.Err()
+	return scanMessages(rows)
 }
 
 func (s *Store) GetAgentMessageStats() (map[string]AgentMessageStats, error) {
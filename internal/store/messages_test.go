@@ -1,6 +1,7 @@
 package store
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -93,3 +94,104 @@ func TestSearchMessages(t *testing.T) {
 		t.Errorf("expected 2 results with default limit, got %d", len(results))
 	}
 }
+
+func TestMessageReplyTo(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SaveAgent(&Agent{ID: "alice", Name: "Alice", Workspace: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &Message{AgentID: "alice", Sender: "user", Content: "What time is it?"}
+	if err := s.SaveMessage(req); err != nil {
+		t.Fatal(err)
+	}
+	reply := &Message{AgentID: "alice", Sender: "agent", Content: "Noon.", ReplyTo: &req.ID}
+	if err := s.SaveMessage(reply); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := s.GetMessages("alice", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	for _, m := range msgs {
+		switch m.Sender {
+		case "user":
+			if m.ReplyTo != nil {
+				t.Errorf("expected user message without reply_to, got %d", *m.ReplyTo)
+			}
+		case "agent":
+			if m.ReplyTo == nil || *m.ReplyTo != req.ID {
+				t.Errorf("expected agent reply_to %d, got %v", req.ID, m.ReplyTo)
+			}
+		}
+	}
+}
+
+func TestGetMessagesBefore(t *testing.T) {
+	s := newTestStore(t)
+	for _, id := range []string{"alice", "bob"} {
+		if err := s.SaveAgent(&Agent{ID: id, Name: id, Workspace: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var ids []int64
+	for i := range 5 {
+		m := &Message{AgentID: "alice", Sender: "user", Content: fmt.Sprintf("m%d", i)}
+		if err := s.SaveMessage(m); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, m.ID)
+	}
+	_ = s.SaveMessage(&Message{AgentID: "bob", Sender: "user", Content: "other"})
+
+	latest, err := s.GetMessagesBefore("alice", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 2 || latest[0].Content != "m3" || latest[1].Content != "m4" {
+		t.Fatalf("expected [m3 m4], got %+v", latest)
+	}
+
+	older, err := s.GetMessagesBefore("alice", latest[0].ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(older) != 3 || older[0].ID != ids[0] || older[2].Content != "m2" {
+		t.Errorf("expected [m0 m1 m2], got %+v", older)
+	}
+}
+
+func TestGetLastAgentReply(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SaveAgent(&Agent{ID: "alice", Name: "alice", Workspace: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := s.GetLastAgentReply("alice"); err != nil || got != nil {
+		t.Fatalf("expected no reply, got %+v, %v", got, err)
+	}
+
+	for _, m := range []Message{
+		{Sender: "user", Content: "q1"},
+		{Sender: "agent", Content: "a1"},
+		{Sender: "user", Content: "q2"},
+		{Sender: "agent", Content: ""}, // aborted run
+	} {
+		m.AgentID = "alice"
+		if err := s.SaveMessage(&m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := s.GetLastAgentReply("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Content != "a1" {
+		t.Errorf("expected a1, got %+v", got)
+	}
+}
@@ -0,0 +1,484 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/extensions"
+)
+
+// dbtx is the subset of *sql.DB and *sql.Tx used by migrations.
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// migration is one schema change. Versions are consecutive from 1; never
+// edit or reorder an applied migration, append a new one instead.
+type migration struct {
+	version     int
+	description string
+	up          func(tx dbtx) error
+}
+
+// migrations reproduce the schema that used to be built ad hoc on every
+// start. Each step is written to also succeed against a database created by
+// that older code (IF NOT EXISTS, addColumn), so existing installs, which
+// start at version 0, upgrade cleanly.
+var migrations = []migration{
+	{1, "core tables", migrateCoreTables},
+	{2, "swarm graph columns", func(tx dbtx) error {
+		for _, c := range [][3]string{
+			{"swarm_runs", "name", "TEXT DEFAULT ''"},
+			{"swarm_runs", "synapses", "TEXT DEFAULT '[]'"},
+			{"swarm_runs", "lead_agent", "TEXT DEFAULT ''"},
+		} {
+			if err := addColumn(tx, c[0], c[1], c[2]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{3, "agent extension columns", func(tx dbtx) error {
+		if err := addColumn(tx, "agents", "extensions", "TEXT DEFAULT '{}'"); err != nil {
+			return err
+		}
+		return addColumn(tx, "agents", "extension_status", "TEXT DEFAULT '{}'")
+	}},
+	{4, "normalized extension tables", migrateExtensionTables},
+	{5, "message full-text search", migrateMessagesFTS},
+	{6, "drop legacy vector routing tables", func(tx dbtx) error {
+		// vec0 virtual tables can't be dropped without the extension
+		// loaded; they are harmless if left behind.
+		for _, table := range []string{"agent_embeddings", "learned_embeddings"} {
+			_, _ = tx.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %s`, table))
+		}
+		return nil
+	}},
+	{7, "message reply_to", func(tx dbtx) error {
+		return addColumn(tx, "messages", "reply_to", "INTEGER REFERENCES messages(id)")
+	}},
+	{8, "swarm failure policy", func(tx dbtx) error {
+		return addColumn(tx, "swarm_runs", "failure_policy", "TEXT DEFAULT ''")
+	}},
+	{9, "agent tags", func(tx dbtx) error {
+		return addColumn(tx, "agents", "tags", "TEXT DEFAULT '[]'")
+	}},
+	{10, "agent commands", func(tx dbtx) error {
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS agent_commands (
+			agent_id        TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+			command         TEXT NOT NULL,
+			description     TEXT NOT NULL,
+			prompt_template TEXT NOT NULL,
+			sort_order      INTEGER DEFAULT 0,
+			PRIMARY KEY (agent_id, command)
+		)`)
+		return err
+	}},
+	{11, "task failure hooks", func(tx dbtx) error {
+		for _, c := range [][2]string{
+			{"on_failure", "TEXT DEFAULT ''"},
+			{"max_failures", "INTEGER DEFAULT 0"},
+			{"consecutive_failures", "INTEGER DEFAULT 0"},
+		} {
+			if err := addColumn(tx, "scheduled_tasks", c[0], c[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{12, "agent session id", func(tx dbtx) error {
+		return addColumn(tx, "agents", "session_id", "TEXT DEFAULT ''")
+	}},
+	{13, "agent group", func(tx dbtx) error {
+		return addColumn(tx, "agents", "group_name", "TEXT DEFAULT ''")
+	}},
+	{14, "task retries and dead letters", func(tx dbtx) error {
+		for _, c := range [][2]string{
+			{"retry_count", "INTEGER DEFAULT 0"},
+			{"retry_delay_ms", "INTEGER DEFAULT 0"},
+		} {
+			if err := addColumn(tx, "scheduled_tasks", c[0], c[1]); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS task_runs (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id    TEXT NOT NULL REFERENCES scheduled_tasks(id) ON DELETE CASCADE,
+			status     TEXT NOT NULL,
+			attempts   INTEGER NOT NULL,
+			error      TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`)
+		return err
+	}},
+	{15, "telegram user usage", func(tx dbtx) error {
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS user_usage (
+			user_id      INTEGER NOT NULL,
+			period_start INTEGER NOT NULL,
+			messages     INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_id, period_start)
+		)`)
+		return err
+	}},
+	{16, "agent lifecycle events", func(tx dbtx) error {
+		for _, stmt := range []string{
+			`CREATE TABLE IF NOT EXISTS agent_events (
+				id           INTEGER PRIMARY KEY AUTOINCREMENT,
+				agent_id     TEXT NOT NULL,
+				type         TEXT NOT NULL,
+				reason       TEXT DEFAULT '',
+				container_id TEXT DEFAULT '',
+				created_at   DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_agent_events_created ON agent_events(created_at)`,
+		} {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{17, "swarm run reason", func(tx dbtx) error {
+		return addColumn(tx, "swarm_runs", "reason", "TEXT DEFAULT ''")
+	}},
+	{18, "artifacts", func(tx dbtx) error {
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS artifacts (
+			agent_id   TEXT NOT NULL,
+			name       TEXT NOT NULL,
+			size       INTEGER NOT NULL,
+			sha256     TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (agent_id, name)
+		)`)
+		return err
+	}},
+	{19, "task run summary", func(tx dbtx) error {
+		return addColumn(tx, "scheduled_tasks", "summary", "TEXT DEFAULT ''")
+	}},
+}
+
+// LatestSchemaVersion is the schema version this binary migrates to.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the highest migration version applied to the
+// database.
+func (s *Store) SchemaVersion() (int, error) {
+	var v int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&v); err != nil {
+		return 0, fmt.Errorf("get schema version: %w", err)
+	}
+	return v, nil
+}
+
+// migrate applies every migration newer than the recorded schema version,
+// each in its own transaction together with its schema_migrations row, so a
+// failed step leaves the database at the previous version.
+func (s *Store) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at DATETIME NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if latest := LatestSchemaVersion(); current > latest {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+		slog.Info("applied schema migration", "version", m.version, "description", m.description)
+	}
+	return nil
+}
+
+func (s *Store) applyMigration(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+		m.version, time.Now().UTC()); err != nil {
+		return fmt.Errorf("record version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// addColumn adds a column unless it already exists, which is the case for
+// databases upgraded by the pre-versioning migration code.
+func addColumn(tx dbtx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT name FROM pragma_table_info('%s')`, table))
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	exists := false
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if name == column {
+			exists = true
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return fmt.Errorf("add %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+func execAll(tx dbtx, stmts []string) error {
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateCoreTables(tx dbtx) error {
+	return execAll(tx, []string{
+		`CREATE TABLE IF NOT EXISTS agents (
+			id          TEXT PRIMARY KEY,
+			name        TEXT NOT NULL,
+			description TEXT,
+			model       TEXT,
+			image       TEXT,
+			workspace   TEXT NOT NULL UNIQUE,
+			claude_md   TEXT,
+			created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS messages (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			agent_id    TEXT NOT NULL REFERENCES agents(id),
+			sender      TEXT NOT NULL,
+			content     TEXT NOT NULL,
+			metadata    TEXT,
+			created_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_agent ON messages(agent_id, created_at)`,
+		`CREATE TABLE IF NOT EXISTS scheduled_tasks (
+			id           TEXT PRIMARY KEY,
+			agent_id     TEXT NOT NULL REFERENCES agents(id),
+			name         TEXT NOT NULL,
+			schedule     TEXT NOT NULL,
+			prompt       TEXT NOT NULL,
+			context_mode TEXT DEFAULT 'isolated',
+			status       TEXT DEFAULT 'active',
+			next_run_at  DATETIME,
+			last_run_at  DATETIME,
+			last_status  TEXT,
+			last_error   TEXT,
+			created_at   DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_tasks_next_run ON scheduled_tasks(status, next_run_at)`,
+		`CREATE TABLE IF NOT EXISTS agent_sessions (
+			id           TEXT PRIMARY KEY,
+			agent_id     TEXT NOT NULL REFERENCES agents(id),
+			container_id TEXT,
+			status       TEXT DEFAULT 'active',
+			started_at   DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_active  DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS swarm_runs (
+			id           TEXT PRIMARY KEY,
+			agent_id     TEXT NOT NULL REFERENCES agents(id),
+			task         TEXT NOT NULL,
+			status       TEXT DEFAULT 'running',
+			agents       TEXT NOT NULL,
+			results      TEXT,
+			started_at   DATETIME DEFAULT CURRENT_TIMESTAMP,
+			completed_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS secrets (
+			id          TEXT PRIMARY KEY,
+			name        TEXT NOT NULL UNIQUE,
+			description TEXT,
+			kind        TEXT NOT NULL,
+			filename    TEXT,
+			value       BLOB NOT NULL,
+			nonce       BLOB NOT NULL,
+			global      INTEGER DEFAULT 0,
+			created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at  DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS agent_secrets (
+			agent_id   TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+			secret_id  TEXT NOT NULL REFERENCES secrets(id) ON DELETE CASCADE,
+			PRIMARY KEY (agent_id, secret_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS response_cache (
+			agent_id    TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+			prompt_hash TEXT NOT NULL,
+			response    TEXT NOT NULL,
+			created_at  DATETIME NOT NULL,
+			PRIMARY KEY (agent_id, prompt_hash)
+		)`,
+		`CREATE TABLE IF NOT EXISTS settings (
+			key        TEXT PRIMARY KEY,
+			value      TEXT NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`,
+	})
+}
+
+func migrateExtensionTables(tx dbtx) error {
+	if err := execAll(tx, []string{
+		`CREATE TABLE IF NOT EXISTS agent_mcp_servers (
+			agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+			name     TEXT NOT NULL,
+			config   TEXT NOT NULL,
+			PRIMARY KEY (agent_id, name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS agent_marketplaces (
+			agent_id   TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+			source     TEXT NOT NULL,
+			name       TEXT DEFAULT '',
+			sort_order INTEGER DEFAULT 0,
+			PRIMARY KEY (agent_id, source)
+		)`,
+		`CREATE TABLE IF NOT EXISTS agent_plugins (
+			agent_id   TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+			name       TEXT NOT NULL,
+			disabled   INTEGER DEFAULT 0,
+			requires   TEXT DEFAULT '[]',
+			sort_order INTEGER DEFAULT 0,
+			PRIMARY KEY (agent_id, name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS agent_skills (
+			agent_id    TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+			name        TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			content     TEXT NOT NULL DEFAULT '',
+			requires    TEXT DEFAULT '[]',
+			files       TEXT DEFAULT '{}',
+			PRIMARY KEY (agent_id, name)
+		)`,
+	}); err != nil {
+		return err
+	}
+	// One-time data migration from the JSON blob column.
+	return migrateExtensionsToTables(tx)
+}
+
+func migrateMessagesFTS(tx dbtx) error {
+	if err := execAll(tx, []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+			content,
+			content=messages,
+			content_rowid=id
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages BEGIN
+			INSERT INTO messages_fts(rowid, content) VALUES (new.id, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		END`,
+	}); err != nil {
+		return err
+	}
+	// Populate the index for pre-existing messages.
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO messages_fts(rowid, content) SELECT id, content FROM messages`); err != nil {
+		return fmt.Errorf("populate fts: %w", err)
+	}
+	return nil
+}
+
+// migrateExtensionsToTables migrates extension data from the agents.extensions
+// JSON blob column into the normalized extension tables. It is idempotent —
+// uses INSERT OR IGNORE so it can safely run more than once.
+func migrateExtensionsToTables(db dbtx) error {
+	rows, err := db.Query(`SELECT id, extensions FROM agents WHERE extensions IS NOT NULL AND extensions != '' AND extensions != '{}'`)
+	if err != nil {
+		return fmt.Errorf("query agents: %w", err)
+	}
+	blobs := map[string]string{}
+	for rows.Next() {
+		var agentID, extJSON string
+		if err := rows.Scan(&agentID, &extJSON); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan agent: %w", err)
+		}
+		blobs[agentID] = extJSON
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for agentID, extJSON := range blobs {
+		ext, err := extensions.Parse(extJSON)
+		if err != nil {
+			slog.Warn("skipping malformed extensions during migration", "agent", agentID, "error", err)
+			continue
+		}
+
+		if ext.IsEmpty() {
+			continue
+		}
+
+		for name, srv := range ext.MCPServers {
+			cfgJSON, err := json.Marshal(srv)
+			if err != nil {
+				continue
+			}
+			_, _ = db.Exec(`INSERT OR IGNORE INTO agent_mcp_servers (agent_id, name, config) VALUES (?, ?, ?)`,
+				agentID, name, string(cfgJSON))
+		}
+
+		for i, m := range ext.Marketplaces {
+			_, _ = db.Exec(`INSERT OR IGNORE INTO agent_marketplaces (agent_id, source, name, sort_order) VALUES (?, ?, ?, ?)`,
+				agentID, m.Source, m.Name, i)
+		}
+
+		for i, p := range ext.Plugins {
+			reqJSON, _ := json.Marshal(p.Requires)
+			disabled := 0
+			if p.Disabled {
+				disabled = 1
+			}
+			_, _ = db.Exec(`INSERT OR IGNORE INTO agent_plugins (agent_id, name, disabled, requires, sort_order) VALUES (?, ?, ?, ?, ?)`,
+				agentID, p.Name, disabled, string(reqJSON), i)
+		}
+
+		for name, skill := range ext.Skills {
+			reqJSON, _ := json.Marshal(skill.Requires)
+			filesJSON, _ := json.Marshal(skill.Files)
+			_, _ = db.Exec(`INSERT OR IGNORE INTO agent_skills (agent_id, name, description, content, requires, files) VALUES (?, ?, ?, ?, ?, ?)`,
+				agentID, name, skill.Description, skill.Content, string(reqJSON), string(filesJSON))
+		}
+
+		slog.Info("migrated extensions to tables", "agent", agentID)
+	}
+
+	return nil
+}
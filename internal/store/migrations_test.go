@@ -0,0 +1,78 @@
+package store
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrationVersionsAreSequential(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Fatalf("migration %d has version %d, want %d", i, m.version, i+1)
+		}
+	}
+}
+
+func TestSchemaVersionFreshDatabase(t *testing.T) {
+	s := newTestStore(t)
+	v, err := s.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != LatestSchemaVersion() {
+		t.Errorf("schema version = %d, want %d", v, LatestSchemaVersion())
+	}
+}
+
+// TestMigrateUnversionedDatabase simulates a database built by the ad-hoc
+// migration code: the full schema exists but schema_migrations does not.
+func TestMigrateUnversionedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.SaveAgent(&Agent{ID: "a1", Name: "Agent 1", Workspace: "a1"})
+	_ = s.SaveMessage(&Message{AgentID: "a1", Sender: "user", Content: "hello world"})
+	if _, err := s.db.Exec(`DROP TABLE schema_migrations`); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+
+	s, err = New(path)
+	if err != nil {
+		t.Fatalf("reopen unversioned database: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if v, _ := s.SchemaVersion(); v != LatestSchemaVersion() {
+		t.Errorf("schema version = %d, want %d", v, LatestSchemaVersion())
+	}
+	msgs, err := s.GetMessages("a1", 10)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected existing message to survive, got %d (%v)", len(msgs), err)
+	}
+	// The FTS backfill must not duplicate already indexed rows.
+	hits, err := s.SearchMessages("a1", "hello", 10)
+	if err != nil || len(hits) != 1 {
+		t.Errorf("expected 1 search hit, got %d (%v)", len(hits), err)
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, CURRENT_TIMESTAMP)`, LatestSchemaVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+
+	_, err = New(path)
+	if err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Fatalf("expected newer schema error, got %v", err)
+	}
+}
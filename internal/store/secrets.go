@@ -71,11 +71,11 @@ func (s *Store) ListSecrets() ([]Secret, error) {
 }
 
 func (s *Store) DeleteSecret(id string) error {
-	_, err := s.db.Exec(`DELETE FROM secrets WHERE id = ?`, id)
+	res, err := s.db.Exec(`DELETE FROM secrets WHERE id = ?`, id)
 	if err != nil {
 		return fmt.Errorf("delete secret: %w", err)
 	}
-	return nil
+	return requireAffected(res, "secret", id)
 }
 
 func (s *Store) GetAgentSecrets(agentID string) ([]Secret, error) {
@@ -193,6 +193,59 @@ func (s *Store) SetSecretAgents(secretID string, agentIDs []string) error {
 	return tx.Commit()
 }
 
+// ImportedSecret is a secret to import with the agents it is assigned to.
+type ImportedSecret struct {
+	Secret
+	Agents []string
+}
+
+// ImportSecrets saves secrets in one transaction, so a failure leaves the
+// store as it was. Secrets that already exist are skipped unless overwrite
+// is set; an overwritten secret keeps its current assignments and gains the
+// imported ones. It returns how many secrets were saved and skipped.
+func (s *Store) ImportSecrets(secrets []ImportedSecret, overwrite bool) (imported, skipped int, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, sec := range secrets {
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM secrets WHERE id = ?`, sec.ID).Scan(&exists); err != nil {
+			return 0, 0, fmt.Errorf("check secret: %w", err)
+		}
+		if exists > 0 && !overwrite {
+			skipped++
+			continue
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO secrets (id, name, description, kind, filename, value, nonce, global)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				name=excluded.name, description=excluded.description,
+				kind=excluded.kind, filename=excluded.filename,
+				value=excluded.value, nonce=excluded.nonce,
+				global=excluded.global, updated_at=CURRENT_TIMESTAMP`,
+			sec.ID, sec.Name, sec.Description, sec.Kind, sec.Filename,
+			sec.Value, sec.Nonce, boolToInt(sec.Global)); err != nil {
+			return 0, 0, fmt.Errorf("save secret: %w", err)
+		}
+		for _, aid := range sec.Agents {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO agent_secrets (agent_id, secret_id) VALUES (?, ?)`,
+				aid, sec.ID); err != nil {
+				return 0, 0, fmt.Errorf("insert secret agent: %w", err)
+			}
+		}
+		imported++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("commit: %w", err)
+	}
+	return imported, skipped, nil
+}
+
 func (s *Store) GetSecretAgentIDs(secretID string) ([]string, error) {
 	rows, err := s.db.Query(`SELECT agent_id FROM agent_secrets WHERE secret_id = ?`, secretID)
 	if err != nil {
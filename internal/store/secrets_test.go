@@ -147,3 +147,30 @@ func TestGetAgentSecretByNameDenied(t *testing.T) {
 		t.Fatal("expected nil (access denied), got secret")
 	}
 }
+
+func TestImportSecretsIsAtomic(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SaveAgent(&Agent{ID: "general", Name: "General", Workspace: "general"}); err != nil {
+		t.Fatalf("save agent: %v", err)
+	}
+	secret := func(id string, agents ...string) ImportedSecret {
+		return ImportedSecret{Secret: Secret{ID: id, Name: id, Kind: "string", Value: []byte("v"), Nonce: []byte("n")}, Agents: agents}
+	}
+
+	// The second secret names an agent the store doesn't have; the first
+	// must not be left behind.
+	if _, _, err := s.ImportSecrets([]ImportedSecret{secret("a", "general"), secret("b", "missing")}, false); err == nil {
+		t.Fatal("expected the unknown agent to fail the import")
+	}
+	if list, _ := s.ListSecrets(); len(list) != 0 {
+		t.Errorf("failed import left %d secrets", len(list))
+	}
+
+	imported, skipped, err := s.ImportSecrets([]ImportedSecret{secret("a", "general")}, false)
+	if err != nil || imported != 1 || skipped != 0 {
+		t.Fatalf("import = %d/%d, %v; want 1/0", imported, skipped, err)
+	}
+	if imported, skipped, _ = s.ImportSecrets([]ImportedSecret{secret("a")}, false); imported != 0 || skipped != 1 {
+		t.Errorf("reimport = %d/%d, want 0/1", imported, skipped)
+	}
+}
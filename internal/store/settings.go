@@ -0,0 +1,77 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Setting is a persisted runtime setting. Value is an opaque JSON document;
+// callers own its shape.
+type Setting struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GetSetting returns the setting for key, or nil if it has never been set.
+func (s *Store) GetSetting(key string) (*Setting, error) {
+	st := &Setting{Key: key}
+	var updatedAt string
+	err := s.db.QueryRow(`SELECT value, updated_at FROM settings WHERE key = ?`, key).Scan(&st.Value, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get setting: %w", err)
+	}
+	st.UpdatedAt, _ = parseTimeString(updatedAt)
+	return st, nil
+}
+
+// GetSettingValue returns the value for key, or fallback if it is not set.
+func (s *Store) GetSettingValue(key, fallback string) (string, error) {
+	st, err := s.GetSetting(key)
+	if err != nil {
+		return "", err
+	}
+	if st == nil {
+		return fallback, nil
+	}
+	return st.Value, nil
+}
+
+// SetSetting creates or replaces the value for key.
+func (s *Store) SetSetting(key, value string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET
+			value = excluded.value,
+			updated_at = excluded.updated_at`,
+		key, value, time.Now().UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return fmt.Errorf("set setting: %w", err)
+	}
+	return nil
+}
+
+// ListSettings returns all settings ordered by key.
+func (s *Store) ListSettings() ([]Setting, error) {
+	rows, err := s.db.Query(`SELECT key, value, updated_at FROM settings ORDER BY key`)
+	if err != nil {
+		return nil, fmt.Errorf("list settings: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var settings []Setting
+	for rows.Next() {
+		var st Setting
+		var updatedAt string
+		if err := rows.Scan(&st.Key, &st.Value, &updatedAt); err != nil {
+			return nil, fmt.Errorf("scan setting: %w", err)
+		}
+		st.UpdatedAt, _ = parseTimeString(updatedAt)
+		settings = append(settings, st)
+	}
+	return settings, rows.Err()
+}
@@ -0,0 +1,66 @@
+package store
+
+import "testing"
+
+func TestSettingsMissingKey(t *testing.T) {
+	s := newTestStore(t)
+
+	got, err := s.GetSetting("paused")
+	if err != nil {
+		t.Fatalf("get setting: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("expected nil for missing key, got %+v", got)
+	}
+
+	v, err := s.GetSettingValue("paused", "false")
+	if err != nil {
+		t.Fatalf("get setting value: %v", err)
+	}
+	if v != "false" {
+		t.Errorf("expected fallback false, got %q", v)
+	}
+}
+
+func TestSettingsUpsert(t *testing.T) {
+	s := newTestStore(t)
+
+	if err := s.SetSetting("paused", "true"); err != nil {
+		t.Fatalf("set setting: %v", err)
+	}
+	got, err := s.GetSetting("paused")
+	if err != nil {
+		t.Fatalf("get setting: %v", err)
+	}
+	if got == nil || got.Value != "true" {
+		t.Fatalf("expected true, got %+v", got)
+	}
+	if got.UpdatedAt.IsZero() {
+		t.Error("expected updated_at to be set")
+	}
+
+	if err := s.SetSetting("paused", "false"); err != nil {
+		t.Fatalf("overwrite setting: %v", err)
+	}
+	v, err := s.GetSettingValue("paused", "true")
+	if err != nil {
+		t.Fatalf("get setting value: %v", err)
+	}
+	if v != "false" {
+		t.Errorf("expected overwritten value false, got %q", v)
+	}
+
+	if err := s.SetSetting("flags", `{"beta":true}`); err != nil {
+		t.Fatalf("set setting: %v", err)
+	}
+	list, err := s.ListSettings()
+	if err != nil {
+		t.Fatalf("list settings: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 settings after upsert, got %d", len(list))
+	}
+	if list[0].Key != "flags" || list[1].Key != "paused" {
+		t.Errorf("expected settings ordered by key, got %s, %s", list[0].Key, list[1].Key)
+	}
+}
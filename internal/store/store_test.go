@@ -2,7 +2,10 @@ package store
 
 import (
 	"encoding/json"
+	"errors"
+	"maps"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -80,6 +83,73 @@ func TestAgentCRUD(t *testing.T) {
 	}
 }
 
+func TestAgentTags(t *testing.T) {
+	s := newTestStore(t)
+
+	_ = s.SaveAgent(&Agent{ID: "infra", Name: "Infra", Workspace: "infra", Tags: []string{"team:infra", "env:prod"}})
+	_ = s.SaveAgent(&Agent{ID: "plain", Name: "Plain", Workspace: "plain"})
+
+	got, err := s.GetAgent("infra")
+	if err != nil {
+		t.Fatalf("get agent: %v", err)
+	}
+	if len(got.Tags) != 2 || got.Tags[0] != "team:infra" || got.Tags[1] != "env:prod" {
+		t.Errorf("unexpected tags %v", got.Tags)
+	}
+	if plain, _ := s.GetAgent("plain"); plain.Tags != nil {
+		t.Errorf("expected no tags, got %v", plain.Tags)
+	}
+
+	agents, _ := s.ListAgents()
+	var matched []string
+	for _, a := range agents {
+		if a.HasTags("team:infra") {
+			matched = append(matched, a.ID)
+		}
+	}
+	if len(matched) != 1 || matched[0] != "infra" {
+		t.Errorf("agents tagged team:infra = %v, want [infra]", matched)
+	}
+	if !got.HasTags() {
+		t.Error("HasTags with no tags should match every agent")
+	}
+	if got.HasTags("team:infra", "env:dev") {
+		t.Error("HasTags should require every tag")
+	}
+
+	// Clearing the tags on update removes them.
+	_ = s.SaveAgent(&Agent{ID: "infra", Name: "Infra", Workspace: "infra"})
+	if got, _ := s.GetAgent("infra"); got.Tags != nil {
+		t.Errorf("expected tags cleared, got %v", got.Tags)
+	}
+}
+
+func TestListAgentGroups(t *testing.T) {
+	s := newTestStore(t)
+
+	_ = s.SaveAgent(&Agent{ID: "data", Name: "data", Workspace: "data", Group: "analytics"})
+	_ = s.SaveAgent(&Agent{ID: "analyst", Name: "analyst", Workspace: "analyst", Group: "analytics"})
+	_ = s.SaveAgent(&Agent{ID: "ops", Name: "ops", Workspace: "ops", Group: "infra"})
+	_ = s.SaveAgent(&Agent{ID: "plain", Name: "plain", Workspace: "plain"})
+
+	if got, _ := s.GetAgent("data"); got.Group != "analytics" {
+		t.Errorf("group = %q, want analytics", got.Group)
+	}
+	groups, err := s.ListAgentGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || !slices.Equal(groups["analytics"], []string{"analyst", "data"}) || !slices.Equal(groups["infra"], []string{"ops"}) {
+		t.Errorf("groups = %v", groups)
+	}
+
+	// Leaving the group on update removes the agent from it.
+	_ = s.SaveAgent(&Agent{ID: "ops", Name: "ops", Workspace: "ops"})
+	if groups, _ := s.ListAgentGroups(); groups["infra"] != nil {
+		t.Errorf("expected infra group gone, got %v", groups)
+	}
+}
+
 func TestMessageCRUD(t *testing.T) {
 	s := newTestStore(t)
 
@@ -222,6 +292,18 @@ func TestScheduledTaskCRUD(t *testing.T) {
 		t.Error("expected LastRunAt to be set after update")
 	}
 
+	// The run summary is kept across SaveTask, which doesn't write it
+	if err := s.UpdateTaskSummary("task-1", "run 1: all green"); err != nil {
+		t.Fatalf("update task summary: %v", err)
+	}
+	got.Name = "Renamed Task"
+	if err := s.SaveTask(got); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = s.GetTask("task-1"); got.Summary != "run 1: all green" || got.Name != "Renamed Task" {
+		t.Errorf("after save: summary %q, name %q", got.Summary, got.Name)
+	}
+
 	// Pause
 	_ = s.UpdateTaskStatus("task-1", "paused")
 	due, _ = s.GetDueTasks(time.Now())
@@ -230,6 +312,93 @@ func TestScheduledTaskCRUD(t *testing.T) {
 	}
 }
 
+func TestTaskRuns(t *testing.T) {
+	s := newTestStore(t)
+	_ = s.SaveAgent(&Agent{ID: "a1", Name: "Agent 1", Workspace: "a1"})
+	task := &ScheduledTask{ID: "task-1", AgentID: "a1", Name: "t", Schedule: `{"kind":"interval","interval_ms":60000}`,
+		Prompt: "p", Status: "active", RetryCount: 3, RetryDelay: 90 * time.Second}
+	if err := s.SaveTask(task); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := s.GetTask("task-1")
+	if got.RetryCount != 3 || got.RetryDelay != 90*time.Second {
+		t.Errorf("retry_count = %d, retry_delay = %v; want 3, 1m30s", got.RetryCount, got.RetryDelay)
+	}
+
+	for _, e := range []string{"first", "second"} {
+		if err := s.AddTaskRun(&TaskRun{TaskID: "task-1", Status: TaskRunDeadLettered, Attempts: 4, Error: e}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := s.ListTaskRuns("task-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].Error != "second" || runs[0].Attempts != 4 || runs[0].CreatedAt.IsZero() {
+		t.Fatalf("runs = %+v", runs)
+	}
+
+	// Runs go with their task.
+	if err := s.DeleteTask("task-1"); err != nil {
+		t.Fatal(err)
+	}
+	if runs, _ := s.ListTaskRuns("task-1"); len(runs) != 0 {
+		t.Errorf("%d runs left after deleting the task", len(runs))
+	}
+}
+
+func TestDeleteMissingNotFound(t *testing.T) {
+	s := newTestStore(t)
+	for name, del := range map[string]func(string) error{
+		"task":      s.DeleteTask,
+		"secret":    s.DeleteSecret,
+		"swarm run": s.DeleteSwarmRun,
+	} {
+		if err := del("nope"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("delete missing %s: got %v, want ErrNotFound", name, err)
+		}
+	}
+	if err := s.SetExtensionStatus("nope", "{}"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("extension status for missing agent: got %v, want ErrNotFound", err)
+	}
+
+	_ = s.SaveAgent(&Agent{ID: "a1", Name: "Agent 1", Workspace: "a1"})
+	_ = s.SaveTask(&ScheduledTask{ID: "task-1", AgentID: "a1", Name: "t", Schedule: `{"kind":"interval","interval_ms":60000}`, Prompt: "p", Status: "active"})
+	if err := s.DeleteTask("task-1"); err != nil {
+		t.Errorf("delete existing task: %v", err)
+	}
+}
+
+func TestAgentSessionID(t *testing.T) {
+	s := newTestStore(t)
+	_ = s.SaveAgent(&Agent{ID: "a1", Name: "Agent 1", Workspace: "a1"})
+
+	first, err := s.AgentSessionID("a1")
+	if err != nil || first == "" {
+		t.Fatalf("AgentSessionID = %q, %v", first, err)
+	}
+	// Registry syncs upsert the agent; they must not reset the session.
+	_ = s.SaveAgent(&Agent{ID: "a1", Name: "Renamed", Workspace: "a1"})
+	if again, _ := s.AgentSessionID("a1"); again != first {
+		t.Errorf("session id changed from %q to %q", first, again)
+	}
+
+	rotated, err := s.RotateAgentSessionID("a1")
+	if err != nil || rotated == first {
+		t.Fatalf("RotateAgentSessionID = %q, %v; want a new id", rotated, err)
+	}
+	if got, _ := s.AgentSessionID("a1"); got != rotated {
+		t.Errorf("AgentSessionID = %q after rotation, want %q", got, rotated)
+	}
+
+	if _, err := s.AgentSessionID("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AgentSessionID(missing) = %v, want ErrNotFound", err)
+	}
+	if _, err := s.RotateAgentSessionID("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RotateAgentSessionID(missing) = %v, want ErrNotFound", err)
+	}
+}
+
 func TestScheduledTaskNonStandardTimezone(t *testing.T) {
 	s := newTestStore(t)
 	_ = s.SaveAgent(&Agent{ID: "a1", Name: "Agent 1", Workspace: "a1"})
@@ -355,3 +524,157 @@ func TestSwarmRunCRUD(t *testing.T) {
 		t.Errorf("expected status 'completed', got '%s'", got.Status)
 	}
 }
+
+func TestDeleteSwarmRunsOlderThan(t *testing.T) {
+	s := newTestStore(t)
+	_ = s.SaveAgent(&Agent{ID: "a1", Name: "Agent 1", Workspace: "a1"})
+	agents, _ := json.Marshal([]map[string]string{{"role": "researcher"}})
+	// id → status, started_at, completed_at
+	for id, r := range map[string][3]string{
+		"old-done":    {"completed", "2026-01-01 00:00:00", "2026-01-01 00:10:00"},
+		"old-failed":  {"failed", "2026-01-02 00:00:00", "2026-01-02 00:10:00"},
+		"old-partial": {"completed_with_errors", "2026-01-03 00:00:00", "2026-01-03 00:10:00"},
+		"old-running": {"running", "2026-01-04 00:00:00", ""},
+		"new-done":    {"completed", "2026-03-01 00:00:00", "2026-03-01 00:10:00"},
+	} {
+		if err := s.SaveSwarmRun(&SwarmRun{ID: id, AgentID: "a1", Task: "t", Status: r[0], Agents: agents}); err != nil {
+			t.Fatal(err)
+		}
+		var completed any
+		if r[2] != "" {
+			completed = r[2]
+		}
+		if _, err := s.db.Exec(`UPDATE swarm_runs SET started_at = ?, completed_at = ? WHERE id = ?`, r[1], completed, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cutoff := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	// The newest finished run and the one before it are kept, so only the
+	// two oldest finished runs go; running ones are never touched.
+	ids, err := s.DeleteSwarmRunsOlderThan(cutoff, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"old-done", "old-failed"}) {
+		t.Errorf("deleted %v, want [old-done old-failed]", ids)
+	}
+
+	ids, err = s.DeleteSwarmRunsOlderThan(cutoff, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids, []string{"old-partial"}) {
+		t.Errorf("deleted %v, want [old-partial]", ids)
+	}
+	runs, _ := s.ListSwarmRuns()
+	var left []string
+	for _, r := range runs {
+		left = append(left, r.ID)
+	}
+	if !slices.Equal(left, []string{"new-done", "old-running"}) {
+		t.Errorf("remaining runs %v, want [new-done old-running]", left)
+	}
+}
+
+func TestBulkTaskOperations(t *testing.T) {
+	interval := `{"kind":"interval","interval_ms":60000}`
+	setup := func(t *testing.T) *Store {
+		t.Helper()
+		s := newTestStore(t)
+		_ = s.SaveAgent(&Agent{ID: "a1", Name: "Agent 1", Workspace: "a1"})
+		_ = s.SaveAgent(&Agent{ID: "a2", Name: "Agent 2", Workspace: "a2"})
+		next := time.Now().Add(time.Minute)
+		for _, task := range []ScheduledTask{
+			{ID: "a1-active", AgentID: "a1", Status: "active", NextRunAt: &next},
+			{ID: "a1-paused", AgentID: "a1", Status: "paused"},
+			{ID: "a1-done", AgentID: "a1", Status: "completed"},
+			{ID: "a2-active", AgentID: "a2", Status: "active", NextRunAt: &next},
+			{ID: "a2-paused", AgentID: "a2", Status: "paused"},
+		} {
+			task.Name, task.Schedule, task.Prompt = task.ID, interval, "p"
+			if err := s.SaveTask(&task); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return s
+	}
+	status := func(t *testing.T, s *Store) map[string]string {
+		t.Helper()
+		tasks, err := s.ListTasks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make(map[string]string, len(tasks))
+		for _, task := range tasks {
+			out[task.ID] = task.Status
+		}
+		return out
+	}
+
+	t.Run("pause", func(t *testing.T) {
+		s := setup(t)
+		n, err := s.PauseTasks(TaskFilter{AgentID: "a1"})
+		if err != nil || n != 1 {
+			t.Fatalf("PauseTasks = %d, %v; want 1", n, err)
+		}
+		want := map[string]string{"a1-active": "paused", "a1-paused": "paused", "a1-done": "completed", "a2-active": "active", "a2-paused": "paused"}
+		if got := status(t, s); !maps.Equal(got, want) {
+			t.Errorf("statuses = %v, want %v", got, want)
+		}
+		if got, _ := s.GetTask("a1-active"); got.NextRunAt != nil {
+			t.Errorf("paused task next_run_at = %v, want none", got.NextRunAt)
+		}
+		if got, _ := s.GetTask("a2-active"); got.NextRunAt == nil {
+			t.Error("unmatched task lost its next_run_at")
+		}
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		s := setup(t)
+		if _, err := s.RecordTaskFailure("a2-paused", "boom"); err != nil {
+			t.Fatal(err)
+		}
+		next := time.Now().Add(time.Hour).Truncate(time.Second)
+		var schedules []string
+		n, err := s.ResumeTasks(TaskFilter{Status: "paused"}, func(schedule string) *time.Time {
+			schedules = append(schedules, schedule)
+			return &next
+		})
+		if err != nil || n != 2 {
+			t.Fatalf("ResumeTasks = %d, %v; want 2", n, err)
+		}
+		if len(schedules) != 2 || schedules[0] != interval {
+			t.Errorf("next run computed from %v, want the two tasks' schedules", schedules)
+		}
+		want := map[string]string{"a1-active": "active", "a1-paused": "active", "a1-done": "completed", "a2-active": "active", "a2-paused": "active"}
+		if got := status(t, s); !maps.Equal(got, want) {
+			t.Errorf("statuses = %v, want %v", got, want)
+		}
+		for _, id := range []string{"a1-paused", "a2-paused"} {
+			got, _ := s.GetTask(id)
+			if got.NextRunAt == nil || !got.NextRunAt.Equal(next) {
+				t.Errorf("%s next_run_at = %v, want %v", id, got.NextRunAt, next)
+			}
+			if got.ConsecutiveFailures != 0 {
+				t.Errorf("%s consecutive_failures = %d, want 0", id, got.ConsecutiveFailures)
+			}
+		}
+		if got, _ := s.GetTask("a1-done"); got.NextRunAt != nil {
+			t.Errorf("completed task next_run_at = %v, want none", got.NextRunAt)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s := setup(t)
+		n, err := s.DeleteTasks(TaskFilter{AgentID: "a2", Status: "paused"})
+		if err != nil || n != 1 {
+			t.Fatalf("DeleteTasks = %d, %v; want 1", n, err)
+		}
+		want := map[string]string{"a1-active": "active", "a1-paused": "paused", "a1-done": "completed", "a2-active": "active"}
+		if got := status(t, s); !maps.Equal(got, want) {
+			t.Errorf("statuses = %v, want %v", got, want)
+		}
+	})
+}
@@ -8,25 +8,27 @@ import (
 )
 
 type SwarmRun struct {
-	ID          string          `json:"id"`
-	Name        string          `json:"name"`
-	AgentID     string          `json:"agent_id"`
-	LeadAgent   string          `json:"lead_agent"`
-	Task        string          `json:"task"`
-	Status      string          `json:"status"`
-	Agents      json.RawMessage `json:"agents"`
-	Synapses    json.RawMessage `json:"synapses,omitempty"`
-	Results     json.RawMessage `json:"results,omitempty"`
-	StartedAt   time.Time       `json:"started_at"`
-	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	AgentID       string          `json:"agent_id"`
+	LeadAgent     string          `json:"lead_agent"`
+	Task          string          `json:"task"`
+	FailurePolicy string          `json:"failure_policy,omitempty"`
+	Status        string          `json:"status"`
+	Agents        json.RawMessage `json:"agents"`
+	Synapses      json.RawMessage `json:"synapses,omitempty"`
+	Results       json.RawMessage `json:"results,omitempty"`
+	StartedAt     time.Time       `json:"started_at"`
+	CompletedAt   *time.Time      `json:"completed_at,omitempty"`
+	Reason        string          `json:"reason,omitempty"` // why a run failed outside the coordinator, e.g. "orphaned"
 }
 
 func scanSwarmRun(scanner interface {
 	Scan(dest ...any) error
 }) (*SwarmRun, error) {
 	r := &SwarmRun{}
-	var results, synapses *string
-	err := scanner.Scan(&r.ID, &r.Name, &r.AgentID, &r.LeadAgent, &r.Task, &r.Status, &r.Agents, &synapses, &results, &r.StartedAt, &r.CompletedAt)
+	var results, synapses, reason *string
+	err := scanner.Scan(&r.ID, &r.Name, &r.AgentID, &r.LeadAgent, &r.Task, &r.FailurePolicy, &r.Status, &r.Agents, &synapses, &results, &r.StartedAt, &r.CompletedAt, &reason)
 	if err != nil {
 		return nil, err
 	}
@@ -36,20 +38,23 @@ func scanSwarmRun(scanner interface {
 	if synapses != nil {
 		r.Synapses = json.RawMessage(*synapses)
 	}
+	if reason != nil {
+		r.Reason = *reason
+	}
 	return r, nil
 }
 
-const swarmColumns = `id, name, agent_id, lead_agent, task, status, agents, synapses, results, started_at, completed_at`
+const swarmColumns = `id, name, agent_id, lead_agent, task, failure_policy, status, agents, synapses, results, started_at, completed_at, reason`
 
 func (s *Store) SaveSwarmRun(r *SwarmRun) error {
 	_, err := s.db.Exec(`
-		INSERT INTO swarm_runs (id, name, agent_id, lead_agent, task, status, agents, synapses, results)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO swarm_runs (id, name, agent_id, lead_agent, task, failure_policy, status, agents, synapses, results)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			status = excluded.status,
 			results = excluded.results,
-			completed_at = CASE WHEN excluded.status IN ('completed', 'failed') THEN CURRENT_TIMESTAMP ELSE completed_at END`,
-		r.ID, r.Name, r.AgentID, r.LeadAgent, r.Task, r.Status, r.Agents, r.Synapses, r.Results)
+			completed_at = CASE WHEN excluded.status IN ('completed', 'completed_with_errors', 'failed') THEN CURRENT_TIMESTAMP ELSE completed_at END`,
+		r.ID, r.Name, r.AgentID, r.LeadAgent, r.Task, r.FailurePolicy, r.Status, r.Agents, r.Synapses, r.Results)
 	if err != nil {
 		return fmt.Errorf("save swarm run: %w", err)
 	}
@@ -87,15 +92,109 @@ func (s *Store) ListSwarmRuns() ([]SwarmRun, error) {
 }
 
 func (s *Store) DeleteSwarmRun(id string) error {
-	_, err := s.db.Exec(`DELETE FROM swarm_runs WHERE id = ?`, id)
-	return err
+	res, err := s.db.Exec(`DELETE FROM swarm_runs WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res, "swarm run", id)
 }
 
 func (s *Store) UpdateSwarmRun(id string, status string, results json.RawMessage) error {
 	_, err := s.db.Exec(`
 		UPDATE swarm_runs
 		SET status = ?, results = ?,
-		    completed_at = CASE WHEN ? IN ('completed', 'failed') THEN CURRENT_TIMESTAMP ELSE completed_at END
+		    completed_at = CASE WHEN ? IN ('completed', 'completed_with_errors', 'failed') THEN CURRENT_TIMESTAMP ELSE completed_at END
 		WHERE id = ?`, status, results, status, id)
 	return err
 }
+
+// ListStaleSwarmRuns returns the runs still running that started before
+// cutoff, oldest first.
+func (s *Store) ListStaleSwarmRuns(cutoff time.Time) ([]SwarmRun, error) {
+	rows, err := s.db.Query(`SELECT `+swarmColumns+` FROM swarm_runs
+		WHERE status = 'running' AND started_at < ? ORDER BY started_at`, cutoff.UTC().Format(time.DateTime))
+	if err != nil {
+		return nil, fmt.Errorf("list stale swarm runs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var runs []SwarmRun
+	for rows.Next() {
+		r, err := scanSwarmRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan swarm run: %w", err)
+		}
+		runs = append(runs, *r)
+	}
+	return runs, rows.Err()
+}
+
+// FailSwarmRun marks a run that is still running as failed for reason,
+// reporting whether it was.
+func (s *Store) FailSwarmRun(id, reason string) (bool, error) {
+	res, err := s.db.Exec(`
+		UPDATE swarm_runs SET status = 'failed', reason = ?, completed_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = 'running'`, reason, id)
+	if err != nil {
+		return false, fmt.Errorf("fail swarm run: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// CountSwarmRunsByReason returns how many runs failed for reason.
+func (s *Store) CountSwarmRunsByReason(reason string) (int, error) {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM swarm_runs WHERE reason = ?`, reason).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count swarm runs: %w", err)
+	}
+	return n, nil
+}
+
+// finishedSwarmStatuses are the statuses of runs that are no longer executing.
+const finishedSwarmStatuses = `('completed', 'completed_with_errors', 'failed')`
+
+// DeleteSwarmRunsOlderThan deletes finished runs that completed at or before
+// cutoff, except the keep most recently started finished runs, and returns
+// the ids it deleted. A run's results are stored with it, so nothing else is
+// left behind in the database.
+func (s *Store) DeleteSwarmRunsOlderThan(cutoff time.Time, keep int) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.Query(`SELECT id FROM swarm_runs
+		WHERE status IN `+finishedSwarmStatuses+` AND COALESCE(completed_at, started_at) <= ?
+		AND id NOT IN (
+			SELECT id FROM swarm_runs WHERE status IN `+finishedSwarmStatuses+`
+			ORDER BY started_at DESC, id DESC LIMIT ?
+		)`, cutoff.UTC().Format(time.DateTime), keep)
+	if err != nil {
+		return nil, fmt.Errorf("select old swarm runs: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("scan swarm run: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("swarm run rows: %w", err)
+	}
+
+	for _, id := range ids {
+		if _, err := tx.Exec(`DELETE FROM swarm_runs WHERE id = ?`, id); err != nil {
+			return nil, fmt.Errorf("delete swarm run %s: %w", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return ids, nil
+}
@@ -1,8 +1,10 @@
 package store
 
 import (
+	"cmp"
 	"database/sql"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
@@ -70,19 +72,51 @@ type ScheduledTask struct {
 	LastStatus  string     `json:"last_status,omitempty"`
 	LastError   string     `json:"last_error,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
+
+	OnFailure           string `json:"on_failure,omitempty"`   // notify_telegram, webhook:<url> or run_task:<id>
+	MaxFailures         int    `json:"max_failures,omitempty"` // consecutive failures before auto-pause; 0 = never
+	ConsecutiveFailures int    `json:"consecutive_failures,omitempty"`
+
+	RetryCount int           `json:"retry_count,omitempty"` // extra attempts of a failed run before it is dead-lettered
+	RetryDelay time.Duration `json:"retry_delay,omitempty"` // delay before the first retry, doubled for each further one
+
+	// Summary is the rolling digest of earlier runs that context_mode
+	// "summary" prepends to the prompt; maintained by the scheduler, not
+	// written by SaveTask.
+	Summary string `json:"summary,omitempty"`
 }
 
+const taskColumns = `id, agent_id, name, schedule, prompt, context_mode, status, next_run_at, last_run_at, last_status, last_error, created_at, on_failure, max_failures, consecutive_failures, retry_count, retry_delay_ms, summary`
+
 func scanTask(scanner interface {
 	Scan(dest ...any) error
 }) (*ScheduledTask, error) {
 	t := &ScheduledTask{}
-	var lastStatus, lastError *string
+	var lastStatus, lastError, onFailure, summary *string
 	var nextRunAt, lastRunAt, createdAt *string
+	var maxFailures, failures, retryCount *int
+	var retryDelayMs *int64
 	err := scanner.Scan(&t.ID, &t.AgentID, &t.Name, &t.Schedule, &t.Prompt, &t.ContextMode, &t.Status,
-		&nextRunAt, &lastRunAt, &lastStatus, &lastError, &createdAt)
+		&nextRunAt, &lastRunAt, &lastStatus, &lastError, &createdAt,
+		&onFailure, &maxFailures, &failures, &retryCount, &retryDelayMs, &summary)
 	if err != nil {
 		return nil, err
 	}
+	if onFailure != nil {
+		t.OnFailure = *onFailure
+	}
+	if maxFailures != nil {
+		t.MaxFailures = *maxFailures
+	}
+	if failures != nil {
+		t.ConsecutiveFailures = *failures
+	}
+	if retryCount != nil {
+		t.RetryCount = *retryCount
+	}
+	if retryDelayMs != nil {
+		t.RetryDelay = time.Duration(*retryDelayMs) * time.Millisecond
+	}
 	t.NextRunAt = scanTimeString(nextRunAt)
 	t.LastRunAt = scanTimeString(lastRunAt)
 	if createdAt != nil {
@@ -96,6 +130,9 @@ func scanTask(scanner interface {
 	if lastError != nil {
 		t.LastError = *lastError
 	}
+	if summary != nil {
+		t.Summary = *summary
+	}
 	return t, nil
 }
 
@@ -111,8 +148,9 @@ func timeToUTC(t *time.Time) *string {
 
 func (s *Store) SaveTask(t *ScheduledTask) error {
 	_, err := s.db.Exec(`
-		INSERT INTO scheduled_tasks (id, agent_id, name, schedule, prompt, context_mode, status, next_run_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO scheduled_tasks (id, agent_id, name, schedule, prompt, context_mode, status, next_run_at,
+			on_failure, max_failures, retry_count, retry_delay_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(id) DO UPDATE SET
 			agent_id = excluded.agent_id,
 			name = excluded.name,
@@ -120,8 +158,13 @@ func (s *Store) SaveTask(t *ScheduledTask) error {
 			prompt = excluded.prompt,
 			context_mode = excluded.context_mode,
 			status = excluded.status,
-			next_run_at = excluded.next_run_at`,
-		t.ID, t.AgentID, t.Name, t.Schedule, t.Prompt, t.ContextMode, t.Status, timeToUTC(t.NextRunAt))
+			next_run_at = excluded.next_run_at,
+			on_failure = excluded.on_failure,
+			max_failures = excluded.max_failures,
+			retry_count = excluded.retry_count,
+			retry_delay_ms = excluded.retry_delay_ms`,
+		t.ID, t.AgentID, t.Name, t.Schedule, t.Prompt, t.ContextMode, t.Status, timeToUTC(t.NextRunAt),
+		t.OnFailure, t.MaxFailures, t.RetryCount, t.RetryDelay.Milliseconds())
 	if err != nil {
 		return fmt.Errorf("save task: %w", err)
 	}
@@ -129,10 +172,7 @@ func (s *Store) SaveTask(t *ScheduledTask) error {
 }
 
 func (s *Store) GetTask(id string) (*ScheduledTask, error) {
-	row := s.db.QueryRow(`
-		SELECT id, agent_id, name, schedule, prompt, context_mode, status,
-		       next_run_at, last_run_at, last_status, last_error, created_at
-		FROM scheduled_tasks WHERE id = ?`, id)
+	row := s.db.QueryRow(`SELECT `+taskColumns+` FROM scheduled_tasks WHERE id = ?`, id)
 	t, err := scanTask(row)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -144,10 +184,7 @@ func (s *Store) GetTask(id string) (*ScheduledTask, error) {
 }
 
 func (s *Store) ListTasks() ([]ScheduledTask, error) {
-	rows, err := s.db.Query(`
-		SELECT id, agent_id, name, schedule, prompt, context_mode, status,
-		       next_run_at, last_run_at, last_status, last_error, created_at
-		FROM scheduled_tasks ORDER BY created_at`)
+	rows, err := s.db.Query(`SELECT ` + taskColumns + ` FROM scheduled_tasks ORDER BY created_at`)
 	if err != nil {
 		return nil, fmt.Errorf("list tasks: %w", err)
 	}
@@ -165,10 +202,7 @@ func (s *Store) ListTasks() ([]ScheduledTask, error) {
 }
 
 func (s *Store) ListTasksForAgent(agentID string) ([]ScheduledTask, error) {
-	rows, err := s.db.Query(`
-		SELECT id, agent_id, name, schedule, prompt, context_mode, status,
-		       next_run_at, last_run_at, last_status, last_error, created_at
-		FROM scheduled_tasks WHERE agent_id = ? ORDER BY created_at`, agentID)
+	rows, err := s.db.Query(`SELECT `+taskColumns+` FROM scheduled_tasks WHERE agent_id = ? ORDER BY created_at`, agentID)
 	if err != nil {
 		return nil, fmt.Errorf("list tasks for agent: %w", err)
 	}
@@ -185,15 +219,13 @@ func (s *Store) ListTasksForAgent(agentID string) ([]ScheduledTask, error) {
 	return tasks, rows.Err()
 }
 
+// GetDueTasks returns the active tasks due at now, longest overdue first
+// and then by id.
 func (s *Store) GetDueTasks(now time.Time) ([]ScheduledTask, error) {
 	// Fetch all active tasks with a next_run_at and filter in Go, because
 	// the DB may contain mixed timestamp formats (pre-fix vs RFC3339) that
 	// break SQLite's lexicographic string comparison.
-	rows, err := s.db.Query(`
-		SELECT id, agent_id, name, schedule, prompt, context_mode, status,
-		       next_run_at, last_run_at, last_status, last_error, created_at
-		FROM scheduled_tasks
-		WHERE status = 'active' AND next_run_at IS NOT NULL`)
+	rows, err := s.db.Query(`SELECT ` + taskColumns + ` FROM scheduled_tasks WHERE status = 'active' AND next_run_at IS NOT NULL`)
 	if err != nil {
 		return nil, fmt.Errorf("get due tasks: %w", err)
 	}
@@ -210,6 +242,9 @@ func (s *Store) GetDueTasks(now time.Time) ([]ScheduledTask, error) {
 			tasks = append(tasks, *t)
 		}
 	}
+	slices.SortFunc(tasks, func(a, b ScheduledTask) int {
+		return cmp.Or(a.NextRunAt.Compare(*b.NextRunAt), cmp.Compare(a.ID, b.ID))
+	})
 	return tasks, rows.Err()
 }
 
@@ -221,14 +256,104 @@ func (s *Store) UpdateTaskRun(id string, lastStatus string, lastError string, ne
 	return err
 }
 
+// RecordTaskFailure marks the task's last run as failed and returns the
+// number of consecutive failures, this one included.
+func (s *Store) RecordTaskFailure(id, lastError string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`
+		UPDATE scheduled_tasks
+		SET last_status = 'error', last_error = ?, consecutive_failures = COALESCE(consecutive_failures, 0) + 1
+		WHERE id = ?
+		RETURNING consecutive_failures`, lastError, id).Scan(&n)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("task %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("record task failure: %w", err)
+	}
+	return n, nil
+}
+
+// ResetTaskFailures clears the consecutive failure count after a successful run.
+func (s *Store) ResetTaskFailures(id string) error {
+	_, err := s.db.Exec(`UPDATE scheduled_tasks SET consecutive_failures = 0 WHERE id = ?`, id)
+	return err
+}
+
+// UpdateTaskSummary replaces a task's rolling run summary.
+func (s *Store) UpdateTaskSummary(id, summary string) error {
+	_, err := s.db.Exec(`UPDATE scheduled_tasks SET summary = ? WHERE id = ?`, summary, id)
+	return err
+}
+
+// PauseTask pauses a task and records why in last_error.
+func (s *Store) PauseTask(id, reason string) error {
+	_, err := s.db.Exec(`UPDATE scheduled_tasks SET status = 'paused', last_error = ? WHERE id = ?`, reason, id)
+	return err
+}
+
+// TaskRunDeadLettered marks an occurrence that failed on every attempt.
+const TaskRunDeadLettered = "dead_lettered"
+
+// TaskRun records the outcome of one scheduled occurrence of a task,
+// across all of its attempts.
+type TaskRun struct {
+	ID        int64     `json:"id"`
+	TaskID    string    `json:"task_id"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *Store) AddTaskRun(r *TaskRun) error {
+	res, err := s.db.Exec(`INSERT INTO task_runs (task_id, status, attempts, error) VALUES (?, ?, ?, ?)`,
+		r.TaskID, r.Status, r.Attempts, r.Error)
+	if err != nil {
+		return fmt.Errorf("add task run: %w", err)
+	}
+	r.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// ListTaskRuns returns a task's recorded runs, newest first.
+func (s *Store) ListTaskRuns(taskID string) ([]TaskRun, error) {
+	rows, err := s.db.Query(`SELECT id, task_id, status, attempts, error, created_at FROM task_runs
+		WHERE task_id = ? ORDER BY id DESC`, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("list task runs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var runs []TaskRun
+	for rows.Next() {
+		var r TaskRun
+		var runErr, createdAt *string
+		if err := rows.Scan(&r.ID, &r.TaskID, &r.Status, &r.Attempts, &runErr, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan task run: %w", err)
+		}
+		if runErr != nil {
+			r.Error = *runErr
+		}
+		if ct := scanTimeString(createdAt); ct != nil {
+			r.CreatedAt = *ct
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
 func (s *Store) UpdateTaskStatus(id string, status string) error {
 	_, err := s.db.Exec(`UPDATE scheduled_tasks SET status = ? WHERE id = ?`, status, id)
 	return err
 }
 
 func (s *Store) DeleteTask(id string) error {
-	_, err := s.db.Exec(`DELETE FROM scheduled_tasks WHERE id = ?`, id)
-	return err
+	res, err := s.db.Exec(`DELETE FROM scheduled_tasks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res, "task", id)
 }
 
 func (s *Store) DeleteCompletedTasks() (int64, error) {
@@ -238,3 +363,87 @@ func (s *Store) DeleteCompletedTasks() (int64, error) {
 	}
 	return res.RowsAffected()
 }
+
+// TaskFilter selects the tasks a bulk operation applies to. Empty fields
+// match every task.
+type TaskFilter struct {
+	AgentID string
+	Status  string
+}
+
+func (f TaskFilter) where() (string, []any) {
+	clause, args := "1=1", []any{}
+	if f.AgentID != "" {
+		clause += " AND agent_id = ?"
+		args = append(args, f.AgentID)
+	}
+	if f.Status != "" {
+		clause += " AND status = ?"
+		args = append(args, f.Status)
+	}
+	return clause, args
+}
+
+// PauseTasks pauses the matching active tasks and clears their next run,
+// returning how many were paused.
+func (s *Store) PauseTasks(f TaskFilter) (int64, error) {
+	where, args := f.where()
+	res, err := s.db.Exec(`UPDATE scheduled_tasks SET status = 'paused', next_run_at = NULL
+		WHERE status = 'active' AND `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("pause tasks: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ResumeTasks activates the matching paused tasks in one transaction,
+// setting each next run to nextRun(schedule) and resetting its consecutive
+// failures. It returns how many were resumed.
+func (s *Store) ResumeTasks(f TaskFilter, nextRun func(schedule string) *time.Time) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	where, args := f.where()
+	rows, err := tx.Query(`SELECT id, schedule FROM scheduled_tasks WHERE status = 'paused' AND `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("select paused tasks: %w", err)
+	}
+	type paused struct{ id, schedule string }
+	var tasks []paused
+	for rows.Next() {
+		var p paused
+		if err := rows.Scan(&p.id, &p.schedule); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("scan task: %w", err)
+		}
+		tasks = append(tasks, p)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("task rows: %w", err)
+	}
+
+	for _, p := range tasks {
+		if _, err := tx.Exec(`UPDATE scheduled_tasks SET status = 'active', next_run_at = ?, consecutive_failures = 0 WHERE id = ?`,
+			timeToUTC(nextRun(p.schedule)), p.id); err != nil {
+			return 0, fmt.Errorf("resume task %s: %w", p.id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return int64(len(tasks)), nil
+}
+
+// DeleteTasks deletes the matching tasks, returning how many were deleted.
+func (s *Store) DeleteTasks(f TaskFilter) (int64, error) {
+	where, args := f.where()
+	res, err := s.db.Exec(`DELETE FROM scheduled_tasks WHERE `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("delete tasks: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// UserUsage is how many messages a Telegram user sent to agents in the
+// current clock hour and in the rolling 24 hours ending with it.
+type UserUsage struct {
+	UserID int64
+	Hour   int
+	Day    int
+}
+
+// Usage is counted in hourly buckets keyed by the start of the hour (unix
+// seconds); the daily count sums the last 24 of them.
+const usageBuckets = 24
+
+func usagePeriod(at time.Time) (current, oldest int64) {
+	current = at.Truncate(time.Hour).Unix()
+	return current, current - (usageBuckets-1)*int64(time.Hour/time.Second)
+}
+
+const userUsageQuery = `
+	SELECT COALESCE(SUM(CASE WHEN period_start = ? THEN messages END), 0), COALESCE(SUM(messages), 0)
+	FROM user_usage WHERE user_id = ? AND period_start BETWEEN ? AND ?`
+
+// GetUserUsage returns userID's usage as of at.
+func (s *Store) GetUserUsage(userID int64, at time.Time) (UserUsage, error) {
+	current, oldest := usagePeriod(at)
+	u := UserUsage{UserID: userID}
+	if err := s.db.QueryRow(userUsageQuery, current, userID, oldest, current).Scan(&u.Hour, &u.Day); err != nil {
+		return UserUsage{}, fmt.Errorf("get user usage: %w", err)
+	}
+	return u, nil
+}
+
+// CountUserMessage records a message from userID at the given time unless
+// it would exceed hourlyLimit or dailyLimit (0 = unlimited). It returns the
+// usage, including the message if it was counted, and whether it was.
+// Buckets that dropped out of the daily window are pruned.
+func (s *Store) CountUserMessage(userID int64, at time.Time, hourlyLimit, dailyLimit int) (UserUsage, bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return UserUsage{}, false, fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	current, oldest := usagePeriod(at)
+	if _, err := tx.Exec(`DELETE FROM user_usage WHERE user_id = ? AND period_start < ?`, userID, oldest); err != nil {
+		return UserUsage{}, false, fmt.Errorf("prune user usage: %w", err)
+	}
+	u := UserUsage{UserID: userID}
+	if err := tx.QueryRow(userUsageQuery, current, userID, oldest, current).Scan(&u.Hour, &u.Day); err != nil {
+		return UserUsage{}, false, fmt.Errorf("get user usage: %w", err)
+	}
+	if (hourlyLimit > 0 && u.Hour >= hourlyLimit) || (dailyLimit > 0 && u.Day >= dailyLimit) {
+		return u, false, nil
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO user_usage (user_id, period_start, messages) VALUES (?, ?, 1)
+		ON CONFLICT(user_id, period_start) DO UPDATE SET messages = messages + 1`,
+		userID, current); err != nil {
+		return UserUsage{}, false, fmt.Errorf("count user message: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return UserUsage{}, false, fmt.Errorf("commit: %w", err)
+	}
+	u.Hour++
+	u.Day++
+	return u, true, nil
+}
+
+// ListUserUsage returns the usage as of at of every user who sent a
+// message in the daily window, busiest first.
+func (s *Store) ListUserUsage(at time.Time) ([]UserUsage, error) {
+	current, oldest := usagePeriod(at)
+	rows, err := s.db.Query(`
+		SELECT user_id, COALESCE(SUM(CASE WHEN period_start = ? THEN messages END), 0), SUM(messages) AS day
+		FROM user_usage WHERE period_start BETWEEN ? AND ?
+		GROUP BY user_id ORDER BY day DESC, user_id`, current, oldest, current)
+	if err != nil {
+		return nil, fmt.Errorf("list user usage: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var usage []UserUsage
+	for rows.Next() {
+		var u UserUsage
+		if err := rows.Scan(&u.UserID, &u.Hour, &u.Day); err != nil {
+			return nil, fmt.Errorf("scan user usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountUserMessage(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Date(2026, 3, 1, 10, 15, 0, 0, time.UTC)
+
+	// Hourly limit of 2: the third message in the hour is refused and not counted.
+	for i, want := range []bool{true, true, false} {
+		u, ok, err := s.CountUserMessage(42, start.Add(time.Duration(i)*time.Minute), 2, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != want {
+			t.Errorf("message %d: allowed = %v, want %v", i+1, ok, want)
+		}
+		if u.Hour != min(i+1, 2) {
+			t.Errorf("message %d: hour = %d", i+1, u.Hour)
+		}
+	}
+
+	// The next clock hour starts a fresh hourly count; the day keeps adding up.
+	u, ok, err := s.CountUserMessage(42, start.Add(50*time.Minute), 2, 0)
+	if err != nil || !ok {
+		t.Fatalf("next hour: ok = %v, err = %v", ok, err)
+	}
+	if u.Hour != 1 || u.Day != 3 {
+		t.Errorf("next hour usage = %+v, want hour 1, day 3", u)
+	}
+
+	// The daily limit counts the rolling 24 hours.
+	if _, ok, _ := s.CountUserMessage(42, start.Add(2*time.Hour), 0, 3); ok {
+		t.Error("expected daily limit to refuse a 4th message")
+	}
+	// 10:xx drops out of the window at 10:00 the next day.
+	u, ok, err = s.CountUserMessage(42, start.Add(24*time.Hour), 0, 3)
+	if err != nil || !ok {
+		t.Fatalf("after rollover: ok = %v, err = %v", ok, err)
+	}
+	if u.Day != 2 {
+		t.Errorf("after rollover day = %d, want 2 (11:xx plus this one)", u.Day)
+	}
+
+	// Other users are counted separately.
+	if u, _ := s.GetUserUsage(7, start); u.Day != 0 {
+		t.Errorf("user 7 usage = %+v, want none", u)
+	}
+}
+
+func TestListUserUsage(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Date(2026, 3, 1, 10, 15, 0, 0, time.UTC)
+	for _, c := range []struct {
+		user int64
+		at   time.Time
+	}{
+		{1, now}, {2, now}, {2, now.Add(-time.Hour)}, {2, now}, {3, now.Add(-25 * time.Hour)},
+	} {
+		if _, _, err := s.CountUserMessage(c.user, c.at, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := s.ListUserUsage(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []UserUsage{{UserID: 2, Hour: 2, Day: 3}, {UserID: 1, Hour: 1, Day: 1}}
+	if len(usage) != len(want) {
+		t.Fatalf("usage = %+v, want %+v", usage, want)
+	}
+	for i := range want {
+		if usage[i] != want[i] {
+			t.Errorf("usage[%d] = %+v, want %+v", i, usage[i], want[i])
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"maps"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -13,6 +14,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/mtzanidakis/praktor/internal/config"
 	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/dotenv"
+	"github.com/mtzanidakis/praktor/internal/events"
 	"github.com/mtzanidakis/praktor/internal/natsbus"
 	"github.com/mtzanidakis/praktor/internal/registry"
 	"github.com/mtzanidakis/praktor/internal/store"
@@ -37,6 +40,12 @@ type Coordinator struct {
 
 	swarmMembers map[string]SwarmMembership // containerAgentID -> membership
 	membersMu    sync.RWMutex
+
+	live sync.Map // swarm id -> struct{}, for runs executing in this process
+
+	// runAgent runs one swarm member to completion; runSwarmAgent outside
+	// tests.
+	runAgent func(ctx context.Context, swarmID string, agent SwarmAgent, prompt, chatTopic string) AgentResult
 }
 
 func NewCoordinator(bus *natsbus.Bus, ctr *container.Manager, s *store.Store, reg *registry.Registry, v *vault.Vault) *Coordinator {
@@ -48,6 +57,7 @@ func NewCoordinator(bus *natsbus.Bus, ctr *container.Manager, s *store.Store, re
 		vault:        v,
 		swarmMembers: make(map[string]SwarmMembership),
 	}
+	c.runAgent = c.runSwarmAgent
 
 	client, err := natsbus.NewClient(bus)
 	if err != nil {
@@ -69,29 +79,35 @@ func (c *Coordinator) RunSwarm(ctx context.Context, req SwarmRequest) (*store.Sw
 	if err != nil {
 		return nil, fmt.Errorf("invalid swarm graph: %w", err)
 	}
+	if !ValidFailurePolicy(req.FailurePolicy) {
+		return nil, fmt.Errorf("unknown failure policy %q", req.FailurePolicy)
+	}
+	for _, a := range req.Agents {
+		if err := validateParams(a.Params); err != nil {
+			return nil, fmt.Errorf("agent %q: %w", a.Role, err)
+		}
+	}
 
 	agentsJSON, _ := json.Marshal(req.Agents)
 	synapsesJSON, _ := json.Marshal(req.Synapses)
 
 	run := &store.SwarmRun{
-		ID:        req.ID,
-		Name:      req.Name,
-		AgentID:   req.LeadAgent,
-		LeadAgent: req.LeadAgent,
-		Task:      req.Task,
-		Status:    "running",
-		Agents:    agentsJSON,
-		Synapses:  synapsesJSON,
+		ID:            req.ID,
+		Name:          req.Name,
+		AgentID:       req.LeadAgent,
+		LeadAgent:     req.LeadAgent,
+		Task:          req.Task,
+		FailurePolicy: req.policy(),
+		Status:        "running",
+		Agents:        agentsJSON,
+		Synapses:      synapsesJSON,
 	}
 
 	if err := c.store.SaveSwarmRun(run); err != nil {
 		return nil, fmt.Errorf("save swarm run: %w", err)
 	}
 
-	c.publishEvent(req.ID, "swarm_started", map[string]any{
-		"name":   req.Name,
-		"agents": len(req.Agents),
-	})
+	c.publishEvent(req.ID, events.NewSwarmStarted(req.ID, req.Name, len(req.Agents)))
 
 	// Use a background context so the swarm outlives the HTTP request.
 	go c.executeSwarm(context.Background(), req)
@@ -100,13 +116,16 @@ func (c *Coordinator) RunSwarm(ctx context.Context, req SwarmRequest) (*store.Sw
 }
 
 func (c *Coordinator) executeSwarm(ctx context.Context, req SwarmRequest) {
+	c.live.Store(req.ID, struct{}{})
+	defer c.live.Delete(req.ID)
+
 	slog.Info("starting swarm", "id", req.ID, "agents", len(req.Agents), "synapses", len(req.Synapses))
 
 	plan, err := BuildPlan(req.Agents, req.Synapses, req.LeadAgent)
 	if err != nil {
 		slog.Error("swarm plan failed", "id", req.ID, "error", err)
 		_ = c.store.UpdateSwarmRun(req.ID, "failed", nil)
-		c.publishEvent(req.ID, "swarm_failed", map[string]any{"error": err.Error()})
+		c.publishEvent(req.ID, events.NewSwarmFailed(req.ID, events.SwarmFailedData{Error: err.Error()}))
 		return
 	}
 
@@ -129,19 +148,31 @@ func (c *Coordinator) executeSwarm(ctx context.Context, req SwarmRequest) {
 	results := make(map[string]AgentResult)
 	var resultsMu sync.Mutex
 
-	allOK := true
+	policy := req.policy()
+	failed := 0
+	aborted := false
 	for tierIdx, tier := range plan.Tiers {
-		slog.Info("executing tier", "swarm", req.ID, "tier", tierIdx, "agents", tier.Agents)
+		roles := tier.Agents
+		if policy == PolicyContinue && failed > 0 {
+			// Don't ask the lead to synthesize an incomplete picture.
+			roles = slices.DeleteFunc(slices.Clone(roles), func(r string) bool { return r == req.LeadAgent })
+			if len(roles) == 0 {
+				continue
+			}
+		}
+		slog.Info("executing tier", "swarm", req.ID, "tier", tierIdx, "agents", roles)
 
 		var wg sync.WaitGroup
-		for _, role := range tier.Agents {
+		for _, role := range roles {
 			wg.Add(1)
 			go func(role string) {
 				defer wg.Done()
 				agent := roleAgent[role]
 
 				// Build prompt with pipeline context
-				prompt := buildAgentPrompt(agent, req.Task, role, plan, results, &resultsMu, req.LeadAgent)
+				resultsMu.Lock()
+				prompt := buildAgentPrompt(agent, req.Task, role, plan, results, req.LeadAgent)
+				resultsMu.Unlock()
 
 				// Determine collab chat topic
 				var chatTopic string
@@ -149,21 +180,16 @@ func (c *Coordinator) executeSwarm(ctx context.Context, req SwarmRequest) {
 					chatTopic = natsbus.TopicSwarmChat(req.ID, gid)
 				}
 
-				result := c.runSwarmAgent(ctx, req.ID, agent, prompt, chatTopic)
+				result := c.runAgent(ctx, req.ID, agent, prompt, chatTopic)
 
 				resultsMu.Lock()
 				results[role] = result
-				resultsMu.Unlock()
-
-				c.publishEvent(req.ID, "swarm_agent_completed", map[string]any{
-					"role":   role,
-					"status": result.Status,
-					"output": truncate(result.Output, 200),
-				})
-
 				if result.Status == "error" {
-					allOK = false
+					failed++
 				}
+				resultsMu.Unlock()
+
+				c.publishEvent(req.ID, events.NewSwarmAgentCompleted(req.ID, role, result.Status, truncate(result.Output, 200)))
 			}(role)
 		}
 
@@ -177,46 +203,77 @@ func (c *Coordinator) executeSwarm(ctx context.Context, req SwarmRequest) {
 		select {
 		case <-done:
 			slog.Info("tier completed", "swarm", req.ID, "tier", tierIdx)
-			c.publishEvent(req.ID, "swarm_tier_completed", map[string]any{
-				"tier":  tierIdx,
-				"total": len(plan.Tiers),
-			})
+			c.publishEvent(req.ID, events.NewSwarmTierCompleted(req.ID, tierIdx, len(plan.Tiers)))
 		case <-time.After(30 * time.Minute):
 			slog.Warn("swarm tier timed out", "swarm", req.ID, "tier", tierIdx)
-			allOK = false
+			aborted = true
 		case <-ctx.Done():
 			slog.Info("swarm cancelled", "swarm", req.ID)
-			allOK = false
+			aborted = true
 		}
 
-		if !allOK {
+		resultsMu.Lock()
+		stop := aborted || (policy == PolicyFailFast && failed > 0)
+		resultsMu.Unlock()
+		if stop {
 			break
 		}
 	}
 
-	// Collect all results in order
-	var allResults []AgentResult
-	for _, a := range req.Agents {
-		if r, ok := results[a.Role]; ok {
-			allResults = append(allResults, r)
+	// Collect all results in plan order (tier by tier, roles in request
+	// order within a tier), so reruns report them identically; members that
+	// never ran are reported as skipped so the run accounts for every agent.
+	resultsMu.Lock()
+	allResults := make([]AgentResult, 0, len(req.Agents))
+	succeeded := 0
+	for _, role := range plan.order() {
+		r, ok := results[role]
+		if !ok {
+			r = AgentResult{Role: role, MemberID: memberAgentID(req.ID, role), Status: "skipped"}
 		}
+		if r.Status == "completed" {
+			succeeded++
+		}
+		allResults = append(allResults, r)
 	}
+	resultsMu.Unlock()
 
+	status := swarmStatus(policy, aborted, succeeded, len(allResults))
 	resultsJSON, _ := json.Marshal(allResults)
-	status := "completed"
-	if !allOK {
-		status = "failed"
-	}
 	_ = c.store.UpdateSwarmRun(req.ID, status, resultsJSON)
 
-	c.publishEvent(req.ID, "swarm_"+status, map[string]any{
-		"results_count": len(allResults),
-	})
+	c.publishEvent(req.ID, events.NewSwarmFinished(req.ID, status, events.SwarmFinishedData{
+		ResultsCount: len(allResults),
+		Succeeded:    succeeded,
+		Total:        len(allResults),
+		Policy:       policy,
+	}))
 
-	slog.Info("swarm finished", "id", req.ID, "status", status)
+	slog.Info("swarm finished", "id", req.ID, "status", status,
+		"succeeded", succeeded, "total", len(allResults), "policy", policy)
 }
 
-func buildAgentPrompt(agent SwarmAgent, task, role string, plan *ExecutionPlan, results map[string]AgentResult, mu *sync.Mutex, leadAgent string) string {
+// swarmStatus is the final status of a run: completed when every member
+// succeeded, completed_with_errors when a continue or best_effort run got
+// partial results, failed otherwise.
+func swarmStatus(policy string, aborted bool, succeeded, total int) string {
+	switch {
+	case aborted:
+		return "failed"
+	case succeeded == total:
+		return "completed"
+	case policy == PolicyFailFast || succeeded == 0:
+		return "failed"
+	default:
+		return "completed_with_errors"
+	}
+}
+
+// buildAgentPrompt assembles what the member playing role receives: the
+// task, its role instructions, its pipeline predecessors' outputs and, for
+// the lead, every other member's result. It only reads its arguments, so
+// PreviewPrompts can call it without a run.
+func buildAgentPrompt(agent SwarmAgent, task, role string, plan *ExecutionPlan, results map[string]AgentResult, leadAgent string) string {
 	var sb strings.Builder
 
 	// Base: swarm task
@@ -233,19 +290,16 @@ func buildAgentPrompt(agent SwarmAgent, task, role string, plan *ExecutionPlan,
 
 	// Pipeline context: include outputs from predecessors
 	if preds, ok := plan.PipelineInputs[role]; ok && len(preds) > 0 {
-		mu.Lock()
 		sb.WriteString("## Context from Previous Agents\n\n")
 		for _, pred := range preds {
 			if r, ok := results[pred]; ok && r.Output != "" {
 				fmt.Fprintf(&sb, "### Output from %s\n\n%s\n\n", pred, r.Output)
 			}
 		}
-		mu.Unlock()
 	}
 
 	// Lead agent synthesis prompt
 	if role == leadAgent {
-		mu.Lock()
 		hasResults := false
 		for _, r := range results {
 			if r.Output != "" {
@@ -255,51 +309,78 @@ func buildAgentPrompt(agent SwarmAgent, task, role string, plan *ExecutionPlan,
 		}
 		if hasResults {
 			sb.WriteString("## Results from All Agents\n\nSynthesize the following results into a cohesive response:\n\n")
-			for r, res := range results {
-				if r != role && res.Output != "" {
+			for _, r := range plan.order() {
+				if res := results[r]; r != role && res.Output != "" {
 					fmt.Fprintf(&sb, "### %s\n\n%s\n\n", r, res.Output)
 				}
 			}
 		}
-		mu.Unlock()
+		// Only a best_effort run reaches the lead after a failure.
+		var failed []string
+		for r, res := range results {
+			if res.Status == "error" {
+				failed = append(failed, r)
+			}
+		}
+		if len(failed) > 0 {
+			slices.Sort(failed)
+			fmt.Fprintf(&sb, "## Missing Results\n\nThese agents failed and produced no output: %s. Work with what is available.\n\n", strings.Join(failed, ", "))
+		}
 	}
 
 	return sb.String()
 }
 
-func (c *Coordinator) runSwarmAgent(ctx context.Context, swarmID string, agent SwarmAgent, prompt, chatTopic string) AgentResult {
-	agentID := fmt.Sprintf("swarm-%s-%s", swarmID[:8], agent.Role)
-
-	result := AgentResult{
-		Role:   agent.Role,
-		Status: "running",
-	}
+// memberAgentID is the agent id of a swarm member, which also names its
+// container. It carries the full swarm id: the container manager replaces
+// a container of the same name, so members of concurrent swarms must never
+// share one.
+func memberAgentID(swarmID, role string) string {
+	return memberPrefix(swarmID) + container.SanitizeVolumeName(role)
+}
 
-	c.publishEvent(swarmID, "swarm_agent_started", map[string]any{
-		"role":     agent.Role,
-		"agent_id": agentID,
-	})
+// memberPrefix starts the agent id of every member of swarmID.
+func memberPrefix(swarmID string) string {
+	return "swarm-" + swarmID + "-"
+}
 
-	// Resolve agent config from registry
+// memberOpts resolves the container options of a swarm member from the
+// agent definition it references, then applies the member's own model and
+// params.
+func (c *Coordinator) memberOpts(swarmID string, agent SwarmAgent, chatTopic string) container.AgentOpts {
 	opts := container.AgentOpts{
-		AgentID:   agentID,
+		AgentID:   memberAgentID(swarmID, agent.Role),
 		Workspace: agent.Workspace,
 		NATSUrl:   c.bus.AgentNATSURL(),
 		Env:       make(map[string]string),
 	}
+	if !agent.PersistWorkspace {
+		opts.WorkspaceVolume = swarmWorkspaceVolume(swarmID, agent.Role)
+	}
 
+	// Resolve agent config from registry
 	if agent.AgentID != "" {
 		opts.Model = c.registry.ResolveModel(agent.AgentID)
 		opts.Image = c.registry.ResolveImage(agent.AgentID)
+		opts.ClaudeVersion = c.registry.ResolveClaudeVersion(agent.AgentID)
 		if def, hasDef := c.registry.GetDefinition(agent.AgentID); hasDef {
 			maps.Copy(opts.Env, def.Env)
 			opts.AllowedTools = def.AllowedTools
 
 			opts.NixEnabled = def.NixEnabled
 			opts.Security = def.Security
+			opts.Labels = def.ContainerLabels
+			opts.Weight = def.Weight
 			c.resolveSecrets(&opts, agent.AgentID, def)
 		}
 	}
+	// Per-member settings let one base agent run in several variants.
+	if agent.Model != "" {
+		opts.Model = agent.Model
+	}
+	for name, value := range agent.Params {
+		opts.Env[memberParams[name]] = value
+	}
 
 	// Swarm-specific env vars
 	opts.Env["SWARM_ID"] = swarmID
@@ -307,6 +388,21 @@ func (c *Coordinator) runSwarmAgent(ctx context.Context, swarmID string, agent S
 	if chatTopic != "" {
 		opts.Env["SWARM_CHAT_TOPIC"] = chatTopic
 	}
+	return opts
+}
+
+func (c *Coordinator) runSwarmAgent(ctx context.Context, swarmID string, agent SwarmAgent, prompt, chatTopic string) AgentResult {
+	agentID := memberAgentID(swarmID, agent.Role)
+
+	result := AgentResult{
+		Role:     agent.Role,
+		MemberID: agentID,
+		Status:   "running",
+	}
+
+	c.publishEvent(swarmID, events.NewSwarmAgentStarted(swarmID, agent.Role, agentID))
+
+	opts := c.memberOpts(swarmID, agent, chatTopic)
 
 	waiter, err := natsbus.PrepareReadyWaiter(c.client, agentID)
 	if err != nil {
@@ -316,12 +412,31 @@ func (c *Coordinator) runSwarmAgent(ctx context.Context, swarmID string, agent S
 	}
 	defer waiter.Close()
 
-	if _, err := c.containers.StartAgent(ctx, opts); err != nil {
+	// Cleanup runs on a fresh context so it still happens after cancel.
+	// Deferred before StartAgent so the ephemeral volume is removed even if
+	// the start fails half-way, and after the container stop below.
+	if opts.WorkspaceVolume != "" {
+		defer func() {
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := c.containers.RemoveVolume(cleanupCtx, opts.WorkspaceVolume); err != nil {
+				slog.Warn("failed to remove swarm workspace volume", "swarm", swarmID, "role", agent.Role, "error", err)
+			}
+		}()
+	}
+
+	info, err := c.containers.StartAgent(ctx, opts)
+	if err != nil {
 		result.Status = "error"
 		result.Error = err.Error()
 		return result
 	}
-	defer func() { _ = c.containers.StopAgent(ctx, agentID) }()
+	result.ContainerID = info.ID
+	defer func() {
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		_ = c.containers.StopAgent(cleanupCtx, agentID)
+	}()
 
 	// Register swarm membership
 	if chatTopic != "" {
@@ -339,7 +454,7 @@ func (c *Coordinator) runSwarmAgent(ctx context.Context, swarmID string, agent S
 		}()
 	}
 
-	if err := waiter.Wait(ctx, 30*time.Second); err != nil {
+	if err := waiter.Wait(ctx, c.registry.ReadyTimeout()); err != nil {
 		if ctx.Err() != nil {
 			result.Status = "error"
 			result.Error = "cancelled"
@@ -351,6 +466,9 @@ func (c *Coordinator) runSwarmAgent(ctx context.Context, swarmID string, agent S
 	// Subscribe for result
 	resultCh := make(chan string, 1)
 	sub, err := c.client.Subscribe(natsbus.TopicAgentOutput(agentID), func(msg *nats.Msg) {
+		if id, ok := natsbus.ParseAgentOutputSubject(msg.Subject); !ok || id != agentID {
+			return
+		}
 		var output struct {
 			Type    string `json:"type"`
 			Content string `json:"content"`
@@ -395,6 +513,12 @@ func (c *Coordinator) runSwarmAgent(ctx context.Context, swarmID string, agent S
 	return result
 }
 
+// swarmWorkspaceVolume returns the ephemeral workspace volume for a swarm
+// member, unique per run and role.
+func swarmWorkspaceVolume(swarmID, role string) string {
+	return "praktor-swarm-" + container.SanitizeVolumeName(swarmID) + "-" + container.SanitizeVolumeName(role)
+}
+
 // resolveSecrets resolves secret:name references in env vars and prepares
 // file secrets for the container. Mirrors orchestrator's resolveSecrets pattern.
 func (c *Coordinator) resolveSecrets(opts *container.AgentOpts, agentID string, def config.AgentDefinition) {
@@ -436,6 +560,10 @@ func (c *Coordinator) resolveSecrets(opts *container.AgentOpts, agentID string,
 		opts.Env[k] = string(plaintext)
 	}
 
+	if name := def.EnvFileSecret; name != "" {
+		c.resolveEnvFile(opts, agentID, def, accessible[name])
+	}
+
 	for _, fm := range def.Files {
 		if !accessible[fm.Secret] {
 			slog.Error("swarm: secret access denied: agent not authorized",
@@ -461,6 +589,37 @@ func (c *Coordinator) resolveSecrets(opts *container.AgentOpts, agentID string,
 	}
 }
 
+// resolveEnvFile merges the base agent's env_file_secret into opts.Env
+// under its env entries, like the orchestrator does.
+func (c *Coordinator) resolveEnvFile(opts *container.AgentOpts, agentID string, def config.AgentDefinition, accessible bool) {
+	name := def.EnvFileSecret
+	if !accessible {
+		slog.Error("swarm: secret access denied: agent not authorized",
+			"agent", agentID, "env_file_secret", name)
+		return
+	}
+	sec, err := c.store.GetAgentSecretByName(agentID, name)
+	if err != nil || sec == nil {
+		slog.Warn("swarm: failed to resolve env file secret", "agent", agentID, "secret", name)
+		return
+	}
+	plaintext, err := c.vault.Decrypt(sec.Value, sec.Nonce)
+	if err != nil {
+		slog.Warn("swarm: failed to decrypt env file secret", "agent", agentID, "secret", name, "error", err)
+		return
+	}
+	vars, err := dotenv.Map(plaintext)
+	if err != nil {
+		slog.Warn("swarm: failed to parse env file secret", "agent", agentID, "secret", name, "error", err)
+		return
+	}
+	for k, v := range vars {
+		if _, set := def.Env[k]; !set {
+			opts.Env[k] = v
+		}
+	}
+}
+
 // GetSwarmChatTopic returns the swarm ID and chat topic for a container agent ID.
 func (c *Coordinator) GetSwarmChatTopic(containerAgentID string) (swarmID, chatTopic string, ok bool) {
 	c.membersMu.RLock()
@@ -476,22 +635,11 @@ func (c *Coordinator) GetStatus(swarmID string) (*store.SwarmRun, error) {
 	return c.store.GetSwarmRun(swarmID)
 }
 
-func (c *Coordinator) publishEvent(swarmID, eventType string, data map[string]any) {
+func (c *Coordinator) publishEvent(swarmID string, event any) {
 	if c.client == nil {
 		return
 	}
-
-	event := map[string]any{
-		"type":      eventType,
-		"swarm_id":  swarmID,
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"data":      data,
-	}
-	payload, err := json.Marshal(event)
-	if err != nil {
-		return
-	}
-	_ = c.client.Publish(natsbus.TopicEventsSwarmID(swarmID), payload)
+	_ = c.client.PublishJSON(natsbus.TopicEventsSwarmID(swarmID), event)
 }
 
 // PublishSwarmChat publishes a message to a swarm collaborative chat topic.
@@ -0,0 +1,302 @@
+package swarm
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+func TestSwarmWorkspaceVolume(t *testing.T) {
+	got := swarmWorkspaceVolume("1f3c9a2e-77aa-4b1e-9d0c-5e8f0a1b2c3d", "code reviewer")
+	want := "praktor-swarm-1f3c9a2e-77aa-4b1e-9d0c-5e8f0a1b2c3d-code-reviewer"
+	if got != want {
+		t.Errorf("swarmWorkspaceVolume = %q, want %q", got, want)
+	}
+	if swarmWorkspaceVolume("id", "a") == swarmWorkspaceVolume("id", "b") {
+		t.Error("expected distinct volumes per role")
+	}
+}
+
+// runPolicySwarm runs a pipeline a > b > c with a separate lead d, where
+// member b fails, and returns the stored run, its results by role and the
+// prompts each member received.
+func runPolicySwarm(t *testing.T, policy string) (*store.SwarmRun, map[string]AgentResult, map[string]string) {
+	t.Helper()
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	var mu sync.Mutex
+	prompts := map[string]string{}
+	c := &Coordinator{store: s, swarmMembers: make(map[string]SwarmMembership)}
+	c.runAgent = func(_ context.Context, _ string, agent SwarmAgent, prompt, _ string) AgentResult {
+		mu.Lock()
+		prompts[agent.Role] = prompt
+		mu.Unlock()
+		if agent.Role == "b" {
+			return AgentResult{Role: agent.Role, Status: "error", Error: "boom"}
+		}
+		return AgentResult{Role: agent.Role, Status: "completed", Output: "output of " + agent.Role}
+	}
+
+	req := SwarmRequest{
+		ID:        "11111111-2222-3333-4444-555555555555",
+		LeadAgent: "d",
+		Agents:    []SwarmAgent{{Role: "a"}, {Role: "b"}, {Role: "c"}, {Role: "d"}},
+		Synapses: []Synapse{
+			{From: "a", To: "b"},
+			{From: "b", To: "c"},
+		},
+		Task:          "task",
+		FailurePolicy: policy,
+	}
+	if err := s.SaveAgent(&store.Agent{ID: "d", Name: "d", Workspace: "d"}); err != nil {
+		t.Fatalf("save agent: %v", err)
+	}
+	if err := s.SaveSwarmRun(&store.SwarmRun{ID: req.ID, AgentID: "d", LeadAgent: req.LeadAgent, Task: req.Task, FailurePolicy: req.policy(), Status: "running", Agents: json.RawMessage(`[]`)}); err != nil {
+		t.Fatalf("save swarm run: %v", err)
+	}
+	c.executeSwarm(context.Background(), req)
+
+	run, err := s.GetSwarmRun(req.ID)
+	if err != nil || run == nil {
+		t.Fatalf("get swarm run: %v", err)
+	}
+	var list []AgentResult
+	if err := json.Unmarshal(run.Results, &list); err != nil {
+		t.Fatalf("decode results: %v", err)
+	}
+	if len(list) != len(req.Agents) {
+		t.Fatalf("got %d results, want one per agent", len(list))
+	}
+	results := make(map[string]AgentResult, len(list))
+	for _, r := range list {
+		results[r.Role] = r
+	}
+	return run, results, prompts
+}
+
+func assertStatuses(t *testing.T, results map[string]AgentResult, want map[string]string) {
+	t.Helper()
+	for role, status := range want {
+		if got := results[role].Status; got != status {
+			t.Errorf("%s status = %q, want %q", role, got, status)
+		}
+	}
+}
+
+func TestExecuteSwarmFailFast(t *testing.T) {
+	run, results, _ := runPolicySwarm(t, "")
+	if run.Status != "failed" {
+		t.Errorf("status = %q, want failed", run.Status)
+	}
+	if run.FailurePolicy != PolicyFailFast {
+		t.Errorf("failure_policy = %q, want %q", run.FailurePolicy, PolicyFailFast)
+	}
+	if run.CompletedAt == nil {
+		t.Error("expected completed_at to be set")
+	}
+	assertStatuses(t, results, map[string]string{"a": "completed", "b": "error", "c": "skipped", "d": "skipped"})
+}
+
+func TestExecuteSwarmContinue(t *testing.T) {
+	run, results, _ := runPolicySwarm(t, PolicyContinue)
+	if run.Status != "completed_with_errors" {
+		t.Errorf("status = %q, want completed_with_errors", run.Status)
+	}
+	if run.CompletedAt == nil {
+		t.Error("expected completed_at to be set")
+	}
+	assertStatuses(t, results, map[string]string{"a": "completed", "b": "error", "c": "completed", "d": "skipped"})
+}
+
+func TestExecuteSwarmBestEffort(t *testing.T) {
+	run, results, prompts := runPolicySwarm(t, PolicyBestEffort)
+	if run.Status != "completed_with_errors" {
+		t.Errorf("status = %q, want completed_with_errors", run.Status)
+	}
+	assertStatuses(t, results, map[string]string{"a": "completed", "b": "error", "c": "completed", "d": "completed"})
+
+	lead := prompts["d"]
+	if !strings.Contains(lead, "output of c") {
+		t.Errorf("lead prompt lacks successful results:\n%s", lead)
+	}
+	if !strings.Contains(lead, "produced no output: b.") {
+		t.Errorf("lead prompt doesn't mention the failed member:\n%s", lead)
+	}
+}
+
+func TestSwarmStatus(t *testing.T) {
+	tests := []struct {
+		policy    string
+		aborted   bool
+		succeeded int
+		want      string
+	}{
+		{PolicyFailFast, false, 4, "completed"},
+		{PolicyBestEffort, false, 4, "completed"},
+		{PolicyFailFast, false, 3, "failed"},
+		{PolicyContinue, false, 3, "completed_with_errors"},
+		{PolicyBestEffort, false, 3, "completed_with_errors"},
+		{PolicyContinue, false, 0, "failed"},
+		{PolicyBestEffort, true, 3, "failed"},
+	}
+	for _, tt := range tests {
+		if got := swarmStatus(tt.policy, tt.aborted, tt.succeeded, 4); got != tt.want {
+			t.Errorf("swarmStatus(%s, aborted=%v, %d/4) = %q, want %q", tt.policy, tt.aborted, tt.succeeded, got, tt.want)
+		}
+	}
+}
+
+func TestValidFailurePolicy(t *testing.T) {
+	for _, p := range []string{"", PolicyFailFast, PolicyContinue, PolicyBestEffort} {
+		if !ValidFailurePolicy(p) {
+			t.Errorf("ValidFailurePolicy(%q) = false", p)
+		}
+	}
+	if ValidFailurePolicy("retry") {
+		t.Error("ValidFailurePolicy accepted an unknown policy")
+	}
+}
+
+func newSwarmTestCoordinator(t *testing.T) (*Coordinator, *store.Store) {
+	t.Helper()
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	if err := s.SaveAgent(&store.Agent{ID: "lead", Name: "lead", Workspace: "lead"}); err != nil {
+		t.Fatalf("save agent: %v", err)
+	}
+	return &Coordinator{store: s, swarmMembers: make(map[string]SwarmMembership)}, s
+}
+
+func saveSwarmRun(t *testing.T, s *store.Store, req SwarmRequest) {
+	t.Helper()
+	if err := s.SaveSwarmRun(&store.SwarmRun{ID: req.ID, AgentID: "lead", LeadAgent: req.LeadAgent, Task: req.Task, FailurePolicy: req.policy(), Status: "running", Agents: json.RawMessage(`[]`)}); err != nil {
+		t.Fatalf("save swarm run: %v", err)
+	}
+}
+
+func TestMemberAgentIDUniqueAcrossConcurrentSwarms(t *testing.T) {
+	c, s := newSwarmTestCoordinator(t)
+
+	var mu sync.Mutex
+	seen := map[string]string{} // member id → swarm id
+	c.runAgent = func(_ context.Context, swarmID string, agent SwarmAgent, _, _ string) AgentResult {
+		id := memberAgentID(swarmID, agent.Role)
+		mu.Lock()
+		if other, ok := seen[id]; ok && other != swarmID {
+			t.Errorf("member id %q used by swarms %s and %s", id, other, swarmID)
+		}
+		seen[id] = swarmID
+		mu.Unlock()
+		return AgentResult{Role: agent.Role, MemberID: id, Status: "completed"}
+	}
+
+	// Both ids share their first 8 characters, which used to be all the
+	// member names carried.
+	reqs := []SwarmRequest{
+		{ID: "1f3c9a2e-0000-4000-8000-000000000001"},
+		{ID: "1f3c9a2e-0000-4000-8000-000000000002"},
+	}
+	var wg sync.WaitGroup
+	for _, req := range reqs {
+		req.Task = "task"
+		req.Agents = []SwarmAgent{{Role: "researcher"}, {Role: "writer"}}
+		saveSwarmRun(t, s, req)
+		wg.Go(func() { c.executeSwarm(context.Background(), req) })
+	}
+	wg.Wait()
+
+	if len(seen) != 4 {
+		t.Errorf("got %d distinct member ids, want 4: %v", len(seen), seen)
+	}
+	if memberAgentID(reqs[0].ID, "code reviewer") != "swarm-"+reqs[0].ID+"-code-reviewer" {
+		t.Errorf("memberAgentID = %q", memberAgentID(reqs[0].ID, "code reviewer"))
+	}
+}
+
+func TestExecuteSwarmResultsInPlanOrder(t *testing.T) {
+	c, s := newSwarmTestCoordinator(t)
+	c.runAgent = func(_ context.Context, swarmID string, agent SwarmAgent, _, _ string) AgentResult {
+		return AgentResult{Role: agent.Role, MemberID: memberAgentID(swarmID, agent.Role), Status: "completed"}
+	}
+
+	// Listed lead first and against the pipeline direction.
+	req := SwarmRequest{
+		ID:        "22222222-2222-4222-8222-222222222222",
+		LeadAgent: "lead",
+		Agents:    []SwarmAgent{{Role: "lead"}, {Role: "edit"}, {Role: "draft"}, {Role: "facts"}},
+		Synapses:  []Synapse{{From: "draft", To: "edit"}},
+		Task:      "task",
+	}
+	saveSwarmRun(t, s, req)
+	c.executeSwarm(context.Background(), req)
+
+	run, err := s.GetSwarmRun(req.ID)
+	if err != nil || run == nil {
+		t.Fatalf("get swarm run: %v", err)
+	}
+	var list []AgentResult
+	if err := json.Unmarshal(run.Results, &list); err != nil {
+		t.Fatalf("decode results: %v", err)
+	}
+	var order []string
+	for _, r := range list {
+		order = append(order, r.Role)
+		if r.MemberID != memberAgentID(req.ID, r.Role) {
+			t.Errorf("%s member_id = %q", r.Role, r.MemberID)
+		}
+	}
+	if got, want := strings.Join(order, ","), "draft,facts,edit,lead"; got != want {
+		t.Errorf("result order = %s, want %s", got, want)
+	}
+}
+
+func TestReconcileOrphans(t *testing.T) {
+	c, s := newSwarmTestCoordinator(t)
+	for _, id := range []string{"old", "live", "done"} {
+		saveSwarmRun(t, s, SwarmRequest{ID: id, LeadAgent: "lead", Task: "t"})
+	}
+	if err := s.UpdateSwarmRun("done", "completed", nil); err != nil {
+		t.Fatal(err)
+	}
+	c.live.Store("live", struct{}{})
+
+	ctx := context.Background()
+	if n, err := c.ReconcileOrphans(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Fatalf("recent runs: reconciled %d, %v; want 0", n, err)
+	}
+	if n, err := c.ReconcileOrphans(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Fatalf("reconciled %d, %v; want 1", n, err)
+	}
+
+	for id, want := range map[string]string{"old": "failed", "live": "running", "done": "completed"} {
+		run, err := s.GetSwarmRun(id)
+		if err != nil || run == nil {
+			t.Fatalf("get %s: %v", id, err)
+		}
+		if run.Status != want {
+			t.Errorf("%s status = %q, want %q", id, run.Status, want)
+		}
+	}
+	if run, _ := s.GetSwarmRun("old"); run.Reason != OrphanedReason || run.CompletedAt == nil {
+		t.Errorf("orphaned run = reason %q, completed_at %v", run.Reason, run.CompletedAt)
+	}
+	if n, err := s.CountSwarmRunsByReason(OrphanedReason); err != nil || n != 1 {
+		t.Errorf("orphan count = %d, %v; want 1", n, err)
+	}
+	if n, _ := c.ReconcileOrphans(ctx, time.Now().Add(time.Minute)); n != 0 {
+		t.Errorf("second sweep reconciled %d, want 0", n)
+	}
+}
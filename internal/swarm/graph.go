@@ -1,8 +1,9 @@
 package swarm
 
 import (
-	"errors"
 	"fmt"
+	"slices"
+	"strings"
 )
 
 // ExecutionPlan describes the order and grouping of agents for a swarm run.
@@ -17,8 +18,46 @@ type ExecutionTier struct {
 	Agents []string
 }
 
+// order returns every role of the plan, tier by tier.
+func (p *ExecutionPlan) order() []string {
+	var roles []string
+	for _, t := range p.Tiers {
+		roles = append(roles, t.Agents...)
+	}
+	return roles
+}
+
+// Kinds of GraphError.
+const (
+	GraphErrCycle         = "cycle"
+	GraphErrUnknownRole   = "unknown_role"
+	GraphErrLeadNotMember = "lead_not_member"
+)
+
+// GraphError explains why BuildPlan rejected a swarm graph, so callers can
+// point at the offending role or synapse.
+type GraphError struct {
+	Kind  string   `json:"kind"`
+	Role  string   `json:"role,omitempty"`  // unknown role or non-member lead
+	Edge  *Synapse `json:"edge,omitempty"`  // synapse naming the unknown role
+	Cycle []string `json:"cycle,omitempty"` // roles around the cycle, in edge order
+}
+
+func (e *GraphError) Error() string {
+	switch e.Kind {
+	case GraphErrCycle:
+		return fmt.Sprintf("directed graph contains a cycle: %s", strings.Join(append(e.Cycle, e.Cycle[0]), " -> "))
+	case GraphErrUnknownRole:
+		return fmt.Sprintf("synapse %s -> %s references unknown role %q", e.Edge.From, e.Edge.To, e.Role)
+	case GraphErrLeadNotMember:
+		return fmt.Sprintf("lead agent %q is not a member of the swarm", e.Role)
+	}
+	return "invalid swarm graph"
+}
+
 // BuildPlan analyzes the swarm graph and produces an execution plan.
-// It returns an error if the directed graph contains cycles or references unknown roles.
+// It returns a *GraphError if the directed graph contains cycles or
+// references unknown roles.
 func BuildPlan(agents []SwarmAgent, synapses []Synapse, leadAgent string) (*ExecutionPlan, error) {
 	// Build role set
 	roleSet := make(map[string]bool, len(agents))
@@ -27,16 +66,15 @@ func BuildPlan(agents []SwarmAgent, synapses []Synapse, leadAgent string) (*Exec
 	}
 
 	if leadAgent != "" && !roleSet[leadAgent] {
-		return nil, fmt.Errorf("lead agent %q is not a member of the swarm", leadAgent)
+		return nil, &GraphError{Kind: GraphErrLeadNotMember, Role: leadAgent}
 	}
 
 	// Validate synapse references
 	for _, s := range synapses {
-		if !roleSet[s.From] {
-			return nil, fmt.Errorf("synapse references unknown role %q", s.From)
-		}
-		if !roleSet[s.To] {
-			return nil, fmt.Errorf("synapse references unknown role %q", s.To)
+		for _, role := range []string{s.From, s.To} {
+			if !roleSet[role] {
+				return nil, &GraphError{Kind: GraphErrUnknownRole, Role: role, Edge: &s}
+			}
 		}
 	}
 
@@ -151,7 +189,7 @@ func BuildPlan(agents []SwarmAgent, synapses []Synapse, leadAgent string) (*Exec
 	}
 
 	if processed != len(nodeSet) {
-		return nil, errors.New("directed graph contains a cycle")
+		return nil, &GraphError{Kind: GraphErrCycle, Cycle: findCycle(agents, directed, roleToNode, collapsedInDegree)}
 	}
 
 	// Build pipeline inputs (per-role, which predecessor roles feed into it)
@@ -203,3 +241,49 @@ func BuildPlan(agents []SwarmAgent, synapses []Synapse, leadAgent string) (*Exec
 		PipelineInputs: pipelineInputs,
 	}, nil
 }
+
+// findCycle returns the roles around one cycle left in the collapsed graph
+// after a topological sort, where the nodes still with in-degree > 0 are
+// unordered. Each of them has an unordered predecessor, so walking
+// predecessors from one must come back round. A collab group on the cycle
+// is named by the member the walk reached. The cycle starts at its role
+// listed first in agents.
+func findCycle(agents []SwarmAgent, directed map[string][]string, roleToNode map[string]string, inDegree map[string]int) []string {
+	unordered := func(role string) bool { return inDegree[roleToNode[role]] > 0 }
+	pred := make(map[string]string) // node -> an unordered predecessor role
+	for _, a := range agents {
+		for _, to := range directed[a.Role] {
+			if unordered(a.Role) && unordered(to) && roleToNode[a.Role] != roleToNode[to] {
+				if _, ok := pred[roleToNode[to]]; !ok {
+					pred[roleToNode[to]] = a.Role
+				}
+			}
+		}
+	}
+
+	var start string
+	for _, a := range agents {
+		if unordered(a.Role) {
+			start = a.Role
+			break
+		}
+	}
+	seen := make(map[string]int) // node -> index in path
+	var path []string
+	for role := start; ; role = pred[roleToNode[role]] {
+		if i, ok := seen[roleToNode[role]]; ok {
+			path = path[i:]
+			break
+		}
+		seen[roleToNode[role]] = len(path)
+		path = append(path, role)
+	}
+	slices.Reverse(path)
+	// Start from the first cycle role in agent order.
+	for _, a := range agents {
+		if i := slices.Index(path, a.Role); i >= 0 {
+			return slices.Concat(path[i:], path[:i])
+		}
+	}
+	return path
+}
@@ -1,6 +1,8 @@
 package swarm
 
 import (
+	"errors"
+	"slices"
 	"testing"
 )
 
@@ -108,6 +110,44 @@ func TestBuildPlan_CycleDetection(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected cycle error")
 	}
+	var gerr *GraphError
+	if !errors.As(err, &gerr) || gerr.Kind != GraphErrCycle {
+		t.Fatalf("expected cycle GraphError, got %v", err)
+	}
+	if !slices.Equal(gerr.Cycle, []string{"a", "b", "c"}) {
+		t.Errorf("cycle = %v, want a -> b -> c", gerr.Cycle)
+	}
+}
+
+func TestBuildPlan_CycleFedFromOutside(t *testing.T) {
+	synapses := []Synapse{
+		{From: "a", To: "b"},
+		{From: "b", To: "c"},
+		{From: "c", To: "a"},
+		{From: "d", To: "a"},
+	}
+	_, err := BuildPlan(agents("a", "b", "c", "d"), synapses, "")
+	var gerr *GraphError
+	if !errors.As(err, &gerr) || gerr.Kind != GraphErrCycle {
+		t.Fatalf("expected cycle GraphError, got %v", err)
+	}
+	// d feeds the cycle but isn't on it.
+	if !slices.Equal(gerr.Cycle, []string{"a", "b", "c"}) {
+		t.Errorf("cycle = %v, want a -> b -> c", gerr.Cycle)
+	}
+}
+
+func TestBuildPlan_CycleThroughCollabGroup(t *testing.T) {
+	synapses := []Synapse{
+		{From: "a", To: "b", Bidirectional: true},
+		{From: "b", To: "c"},
+		{From: "c", To: "a"},
+	}
+	_, err := BuildPlan(agents("a", "b", "c"), synapses, "")
+	var gerr *GraphError
+	if !errors.As(err, &gerr) || gerr.Kind != GraphErrCycle || len(gerr.Cycle) != 2 || !slices.Contains(gerr.Cycle, "c") {
+		t.Fatalf("expected a two-node cycle through c, got %v", err)
+	}
 }
 
 func TestBuildPlan_LeadAgentPlacement(t *testing.T) {
@@ -127,15 +167,20 @@ func TestBuildPlan_UnknownRole(t *testing.T) {
 		{From: "a", To: "unknown"},
 	}
 	_, err := BuildPlan(agents("a", "b"), synapses, "a")
-	if err == nil {
-		t.Fatal("expected error for unknown role")
+	var gerr *GraphError
+	if !errors.As(err, &gerr) || gerr.Kind != GraphErrUnknownRole {
+		t.Fatalf("expected unknown role GraphError, got %v", err)
+	}
+	if gerr.Role != "unknown" || gerr.Edge == nil || *gerr.Edge != synapses[0] {
+		t.Errorf("role %q, edge %v", gerr.Role, gerr.Edge)
 	}
 }
 
 func TestBuildPlan_UnknownLeadAgent(t *testing.T) {
 	_, err := BuildPlan(agents("a", "b"), nil, "unknown")
-	if err == nil {
-		t.Fatal("expected error for unknown lead agent")
+	var gerr *GraphError
+	if !errors.As(err, &gerr) || gerr.Kind != GraphErrLeadNotMember || gerr.Role != "unknown" {
+		t.Fatalf("expected lead not member GraphError, got %v", err)
 	}
 }
 
@@ -0,0 +1,79 @@
+package swarm
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/events"
+)
+
+// OrphanedReason is the reason recorded on runs failed by the orphan sweep.
+const OrphanedReason = "orphaned"
+
+// orphanSweepInterval is how often StartOrphanSweep looks for orphans.
+const orphanSweepInterval = 5 * time.Minute
+
+// StartOrphanSweep reconciles orphaned runs at startup and then
+// periodically until ctx is done.
+func (c *Coordinator) StartOrphanSweep(ctx context.Context) {
+	ticker := time.NewTicker(orphanSweepInterval)
+	defer ticker.Stop()
+
+	c.sweepOrphans(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.sweepOrphans(ctx)
+		}
+	}
+}
+
+func (c *Coordinator) sweepOrphans(ctx context.Context) {
+	maxAge := c.registry.SwarmMaxAge()
+	if maxAge <= 0 {
+		return
+	}
+	if _, err := c.ReconcileOrphans(ctx, time.Now().Add(-maxAge)); err != nil {
+		slog.Error("swarm orphan sweep failed", "error", err)
+	}
+}
+
+// ReconcileOrphans fails every run still running that started before
+// cutoff and isn't executing in this process, e.g. after a crash, and
+// removes its members' leftover containers. It returns how many runs it
+// failed.
+func (c *Coordinator) ReconcileOrphans(ctx context.Context, cutoff time.Time) (int, error) {
+	runs, err := c.store.ListStaleSwarmRuns(cutoff)
+	if err != nil {
+		return 0, err
+	}
+	failed := 0
+	for _, run := range runs {
+		if _, live := c.live.Load(run.ID); live {
+			continue
+		}
+		ok, err := c.store.FailSwarmRun(run.ID, OrphanedReason)
+		if err != nil {
+			return failed, err
+		}
+		if !ok {
+			continue
+		}
+		failed++
+		slog.Warn("swarm run orphaned, marked failed", "id", run.ID, "started_at", run.StartedAt)
+		c.publishEvent(run.ID, events.NewSwarmFailed(run.ID, events.SwarmFailedData{Reason: OrphanedReason}))
+
+		if c.containers == nil {
+			continue
+		}
+		if n, err := c.containers.CleanupStaleAgents(ctx, memberPrefix(run.ID)); err != nil {
+			slog.Warn("failed to clean up orphaned swarm containers", "id", run.ID, "error", err)
+		} else if n > 0 {
+			slog.Info("removed orphaned swarm containers", "id", run.ID, "containers", n)
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,33 @@
+package swarm
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// memberParams maps the params a swarm member may set to the env var the
+// agent runner or Claude Code reads each one from. All take positive
+// integers.
+var memberParams = map[string]string{
+	"max_turns":           "MAX_TURNS",
+	"max_tokens":          "CLAUDE_CODE_MAX_OUTPUT_TOKENS",
+	"max_thinking_tokens": "MAX_THINKING_TOKENS",
+}
+
+// validateParams rejects unknown params and values that aren't positive
+// integers.
+func validateParams(params map[string]string) error {
+	for name, value := range params {
+		if _, ok := memberParams[name]; !ok {
+			known := slices.Sorted(maps.Keys(memberParams))
+			return fmt.Errorf("unknown param %q (known: %s)", name, strings.Join(known, ", "))
+		}
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return fmt.Errorf("param %s must be a positive integer, got %q", name, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package swarm
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/mtzanidakis/praktor/internal/registry"
+)
+
+func TestMemberOptsVariants(t *testing.T) {
+	c, s := newSwarmTestCoordinator(t)
+	defaults := config.DefaultsConfig{Image: "praktor-agent:latest", Model: "claude-opus-4-7"}
+	agents := map[string]config.AgentDefinition{
+		"writer": {Workspace: "writer", Model: "claude-sonnet-4-6", Env: map[string]string{"MAX_TURNS": "50", "STYLE": "plain"}},
+	}
+	c.registry = registry.New(s, agents, defaults, filepath.Join(t.TempDir(), "agents"))
+	if err := c.registry.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	c.bus = &natsbus.Bus{}
+
+	creative := c.memberOpts("s1", SwarmAgent{AgentID: "writer", Role: "creative", Model: "claude-opus-4-7", Params: map[string]string{"max_turns": "80", "max_tokens": "16000"}}, "")
+	precise := c.memberOpts("s1", SwarmAgent{AgentID: "writer", Role: "precise", Params: map[string]string{"max_thinking_tokens": "4000"}}, "")
+
+	if creative.Model != "claude-opus-4-7" || precise.Model != "claude-sonnet-4-6" {
+		t.Errorf("models = %q, %q; want the member override and the agent's model", creative.Model, precise.Model)
+	}
+	if creative.Env["MAX_TURNS"] != "80" || creative.Env["CLAUDE_CODE_MAX_OUTPUT_TOKENS"] != "16000" || creative.Env["MAX_THINKING_TOKENS"] != "" {
+		t.Errorf("creative env = %v", creative.Env)
+	}
+	if precise.Env["MAX_TURNS"] != "50" || precise.Env["MAX_THINKING_TOKENS"] != "4000" || precise.Env["CLAUDE_CODE_MAX_OUTPUT_TOKENS"] != "" {
+		t.Errorf("precise env = %v", precise.Env)
+	}
+	if creative.Env["STYLE"] != "plain" || precise.Env["STYLE"] != "plain" {
+		t.Error("agent env not inherited")
+	}
+	if creative.AgentID == precise.AgentID {
+		t.Errorf("both members got agent id %q", creative.AgentID)
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	if err := validateParams(map[string]string{"max_turns": "10", "max_tokens": "8000"}); err != nil {
+		t.Errorf("valid params rejected: %v", err)
+	}
+	for _, bad := range []map[string]string{
+		{"temperature": "0.7"},
+		{"max_turns": "0"},
+		{"max_tokens": "lots"},
+	} {
+		if err := validateParams(bad); err == nil {
+			t.Errorf("params %v accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package swarm
+
+import "fmt"
+
+// PromptPreview is the prompt one member of a swarm would receive.
+type PromptPreview struct {
+	Role    string `json:"role"`
+	AgentID string `json:"agent_id"`
+	Tier    int    `json:"tier"`
+	Prompt  string `json:"prompt"`
+}
+
+// PreviewPrompts builds, without running anything, the prompt each member of
+// req would receive, in plan order. outputs stands in for the members'
+// results, by role; a member missing from it is given a placeholder output
+// so the sections that quote it still show. It returns BuildPlan's error
+// for an invalid graph.
+func PreviewPrompts(req SwarmRequest, outputs map[string]string) ([]PromptPreview, error) {
+	plan, err := BuildPlan(req.Agents, req.Synapses, req.LeadAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	roleAgent := make(map[string]SwarmAgent, len(req.Agents))
+	results := make(map[string]AgentResult, len(req.Agents))
+	for _, a := range req.Agents {
+		roleAgent[a.Role] = a
+		out, ok := outputs[a.Role]
+		if !ok {
+			out = fmt.Sprintf("[output of %s]", a.Role)
+		}
+		results[a.Role] = AgentResult{Role: a.Role, Status: "completed", Output: out}
+	}
+
+	var previews []PromptPreview
+	for tierIdx, tier := range plan.Tiers {
+		for _, role := range tier.Agents {
+			agent := roleAgent[role]
+			previews = append(previews, PromptPreview{
+				Role:    role,
+				AgentID: agent.AgentID,
+				Tier:    tierIdx,
+				Prompt:  buildAgentPrompt(agent, req.Task, role, plan, results, req.LeadAgent),
+			})
+		}
+	}
+	return previews, nil
+}
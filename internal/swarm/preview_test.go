@@ -0,0 +1,74 @@
+package swarm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPreviewPrompts(t *testing.T) {
+	members := agents("research", "write", "lead")
+	members[1].Prompt = "Write it up in plain English."
+	req := SwarmRequest{
+		Task:      "Summarize the incident",
+		Agents:    members,
+		Synapses:  []Synapse{{From: "research", To: "write"}},
+		LeadAgent: "lead",
+	}
+
+	previews, err := PreviewPrompts(req, map[string]string{"research": "Disk filled at 03:00."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompts := make(map[string]string)
+	for i, p := range previews {
+		prompts[p.Role] = p.Prompt
+		if i > 0 && p.Tier < previews[i-1].Tier {
+			t.Errorf("previews out of plan order: %+v", previews)
+		}
+	}
+	if len(prompts) != 3 {
+		t.Fatalf("previews = %+v, want one per member", previews)
+	}
+
+	for role, p := range prompts {
+		if !strings.HasPrefix(p, "## Swarm Task\n\nSummarize the incident") {
+			t.Errorf("%s prompt does not start with the task:\n%s", role, p)
+		}
+	}
+	if p := prompts["research"]; strings.Contains(p, "## Context from Previous Agents") || strings.Contains(p, "## Results from All Agents") {
+		t.Errorf("first pipeline stage got context it has no source for:\n%s", p)
+	}
+
+	for _, want := range []string{
+		"## Your Role Instructions\n\nWrite it up in plain English.",
+		"## Context from Previous Agents\n\n### Output from research\n\nDisk filled at 03:00.",
+	} {
+		if !strings.Contains(prompts["write"], want) {
+			t.Errorf("pipeline prompt lacks %q:\n%s", want, prompts["write"])
+		}
+	}
+
+	lead := prompts["lead"]
+	for _, want := range []string{
+		"## Results from All Agents",
+		"### research\n\nDisk filled at 03:00.",
+		"### write\n\n[output of write]",
+	} {
+		if !strings.Contains(lead, want) {
+			t.Errorf("lead prompt lacks %q:\n%s", want, lead)
+		}
+	}
+	if strings.Contains(lead, "### lead") {
+		t.Errorf("lead prompt quotes the lead's own output:\n%s", lead)
+	}
+	if strings.Index(lead, "### research") > strings.Index(lead, "### write") {
+		t.Errorf("lead prompt lists results out of plan order:\n%s", lead)
+	}
+
+	req.Synapses = append(req.Synapses, Synapse{From: "write", To: "research"})
+	var gerr *GraphError
+	if _, err := PreviewPrompts(req, nil); !errors.As(err, &gerr) || gerr.Kind != GraphErrCycle {
+		t.Errorf("cyclic graph: err = %v, want a cycle GraphError", err)
+	}
+}
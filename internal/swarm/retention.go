@@ -0,0 +1,50 @@
+package swarm
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// StartRetentionSweep deletes finished runs older than
+// defaults.swarm_retention every defaults.swarm_sweep_interval until ctx is
+// done. Both are read on each pass, so reloads apply from the next one.
+func (c *Coordinator) StartRetentionSweep(ctx context.Context) {
+	for {
+		maxAge, keep, interval := c.registry.SwarmRetention()
+		if maxAge > 0 {
+			if _, err := c.PruneRuns(ctx, time.Now().Add(-maxAge), keep); err != nil {
+				slog.Error("swarm retention sweep failed", "error", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// PruneRuns deletes the finished runs that completed at or before cutoff,
+// except the keep most recent, and removes any containers their members
+// left behind. It returns how many runs it deleted.
+func (c *Coordinator) PruneRuns(ctx context.Context, cutoff time.Time, keep int) (int, error) {
+	ids, err := c.store.DeleteSwarmRunsOlderThan(cutoff, keep)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) > 0 {
+		slog.Info("old swarm runs removed", "count", len(ids))
+	}
+	if c.containers == nil {
+		return len(ids), nil
+	}
+	for _, id := range ids {
+		if n, err := c.containers.CleanupStaleAgents(ctx, memberPrefix(id)); err != nil {
+			slog.Warn("failed to clean up swarm containers", "id", id, "error", err)
+		} else if n > 0 {
+			slog.Info("removed leftover swarm containers", "id", id, "containers", n)
+		}
+	}
+	return len(ids), nil
+}
@@ -6,25 +6,58 @@ type Synapse struct {
 	Bidirectional bool   `json:"bidirectional"` // false=pipeline, true=collaborative
 }
 
+// Failure policies decide what happens to the rest of a swarm when a member
+// fails.
+const (
+	PolicyFailFast   = "fail_fast"   // stop after the failing tier (default)
+	PolicyContinue   = "continue"    // run the remaining tiers but skip the lead
+	PolicyBestEffort = "best_effort" // run everything; the lead synthesizes whatever succeeded
+)
+
+// ValidFailurePolicy reports whether p is a known failure policy. Empty means
+// the default, fail_fast.
+func ValidFailurePolicy(p string) bool {
+	switch p {
+	case "", PolicyFailFast, PolicyContinue, PolicyBestEffort:
+		return true
+	}
+	return false
+}
+
 type SwarmRequest struct {
-	ID        string       `json:"id"`
-	Name      string       `json:"name"`
-	LeadAgent string       `json:"lead_agent"` // role of lead agent
-	Agents    []SwarmAgent `json:"agents"`
-	Synapses  []Synapse    `json:"synapses"`
-	Task      string       `json:"task"`
+	ID            string       `json:"id"`
+	Name          string       `json:"name"`
+	LeadAgent     string       `json:"lead_agent"` // role of lead agent
+	Agents        []SwarmAgent `json:"agents"`
+	Synapses      []Synapse    `json:"synapses"`
+	Task          string       `json:"task"`
+	FailurePolicy string       `json:"failure_policy,omitempty"`
+}
+
+func (r SwarmRequest) policy() string {
+	if r.FailurePolicy == "" {
+		return PolicyFailFast
+	}
+	return r.FailurePolicy
 }
 
 type SwarmAgent struct {
-	AgentID   string `json:"agent_id"` // references config agent name
-	Role      string `json:"role"`     // display label in swarm
-	Prompt    string `json:"prompt"`   // per-agent instructions
-	Workspace string `json:"workspace"`
+	AgentID          string `json:"agent_id"` // references config agent name
+	Role             string `json:"role"`     // display label in swarm
+	Prompt           string `json:"prompt"`   // per-agent instructions
+	Workspace        string `json:"workspace"`
+	PersistWorkspace bool   `json:"persist_workspace"` // mount the real praktor-wk-<workspace> volume instead of an ephemeral one
+	// Model and Params override the referenced agent's settings for this
+	// member only, so one agent can run as several variants in a swarm.
+	Model  string            `json:"model,omitempty"`
+	Params map[string]string `json:"params,omitempty"` // see memberParams
 }
 
 type AgentResult struct {
-	Role   string `json:"role"`
-	Status string `json:"status"`
-	Output string `json:"output"`
-	Error  string `json:"error,omitempty"`
+	Role        string `json:"role"`
+	MemberID    string `json:"member_id,omitempty"`    // swarm-<swarm id>-<role>, the member's agent id and container name suffix
+	ContainerID string `json:"container_id,omitempty"` // docker container id, once started
+	Status      string `json:"status"`                 // completed, error, or skipped when the failure policy didn't run it
+	Output      string `json:"output"`
+	Error       string `json:"error,omitempty"`
 }
@@ -0,0 +1,73 @@
+package telegram
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+// chatAgentSettingPrefix namespaces per-chat agent bindings in the settings
+// table: telegram.chat_agent.<chatID> → "<agentID>" (a JSON string). Bots
+// other than the default one add their name: telegram.chat_agent.<bot>.<chatID>.
+const chatAgentSettingPrefix = "telegram.chat_agent."
+
+// chatAgentPrefix returns this bot's binding key prefix. Private chat IDs
+// are user IDs, so bots must not share bindings.
+func (b *Bot) chatAgentPrefix() string {
+	if b.cfg.Name == "" || b.cfg.Name == config.DefaultBotName {
+		return chatAgentSettingPrefix
+	}
+	return chatAgentSettingPrefix + b.cfg.Name + "."
+}
+
+// loadChatAgents restores the chat → agent bindings persisted by
+// setChatAgent, so replies and /stop, /reset keep targeting the right agent
+// across restarts.
+func (b *Bot) loadChatAgents() {
+	if b.store == nil {
+		return
+	}
+	settings, err := b.store.ListSettings()
+	if err != nil {
+		slog.Warn("failed to load chat agent bindings", "error", err)
+		return
+	}
+
+	b.chatAgentMu.Lock()
+	defer b.chatAgentMu.Unlock()
+	for _, st := range settings {
+		rest, ok := strings.CutPrefix(st.Key, b.chatAgentPrefix())
+		if !ok {
+			continue
+		}
+		chatID, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			continue
+		}
+		var agentID string
+		if json.Unmarshal([]byte(st.Value), &agentID) != nil || agentID == "" {
+			continue
+		}
+		b.chatAgent[chatID] = agentID
+	}
+}
+
+// setChatAgent records the agent a chat last talked to and persists the
+// binding when it changes.
+func (b *Bot) setChatAgent(chatID int64, agentID string) {
+	b.chatAgentMu.Lock()
+	changed := b.chatAgent[chatID] != agentID
+	b.chatAgent[chatID] = agentID
+	b.chatAgentMu.Unlock()
+
+	if !changed || b.store == nil {
+		return
+	}
+	value, _ := json.Marshal(agentID)
+	if err := b.store.SetSetting(b.chatAgentPrefix()+strconv.FormatInt(chatID, 10), string(value)); err != nil {
+		slog.Warn("failed to persist chat agent binding", "chat", chatID, "error", err)
+	}
+}
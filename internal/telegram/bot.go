@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"path"
@@ -15,16 +16,21 @@ import (
 
 	"github.com/mtzanidakis/praktor/internal/agent"
 	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/events"
 	"github.com/mtzanidakis/praktor/internal/natsbus"
 	"github.com/mtzanidakis/praktor/internal/registry"
 	"github.com/mtzanidakis/praktor/internal/router"
 	"github.com/mtzanidakis/praktor/internal/speech"
 	"github.com/mtzanidakis/praktor/internal/store"
 	"github.com/mtzanidakis/praktor/internal/swarm"
+	"github.com/mtzanidakis/praktor/internal/tracing"
 	"github.com/mymmrac/telego"
 	th "github.com/mymmrac/telego/telegohandler"
 	tu "github.com/mymmrac/telego/telegoutil"
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Bot struct {
@@ -33,12 +39,17 @@ type Bot struct {
 	orch       *agent.Orchestrator
 	router     *router.Router
 	store      *store.Store
-	cfg        config.TelegramConfig
+	cfg        config.TelegramBotConfig
+	parseMode  string
 	cancel     context.CancelFunc
 	swarmCoord *swarm.Coordinator
 	registry   *registry.Registry
 	bus        *natsbus.Bus
 
+	// homeBot names the bot that delivers output for an agent when the
+	// message did not come from Telegram (scheduler, web, swarms).
+	homeBot func(agentID string) string
+
 	// Track chat_id → agentID mapping for responses
 	chatAgentMu sync.RWMutex
 	chatAgent   map[int64]string // chatID → agentID that last handled a message
@@ -62,14 +73,51 @@ type Bot struct {
 	// Buffer media group messages so albums are routed together
 	mediaGroupMu sync.Mutex
 	mediaGroups  map[string]*mediaGroupBuffer // mediaGroupID → buffer
+
+	// Commands registered by agents, by slash name (e.g. coder_deploy)
+	agentCmdMu sync.RWMutex
+	agentCmds  map[string]store.AgentCommand
+
+	// Per-user message quota, shared by all bots
+	quota config.UserQuotaConfig
+
+	// Holds replies back so each chat gets them in message order; nil = off
+	order *replyOrder
 }
 
 type mediaGroupBuffer struct {
 	messages []telego.Message
 	timer    *time.Timer
+	refused  bool // over quota; the album is dropped
 }
 
-func NewBot(cfg config.TelegramConfig, orch *agent.Orchestrator, rtr *router.Router, sc *swarm.Coordinator, reg *registry.Registry, bus *natsbus.Bus, s *store.Store, speechClient *speech.Client, speechCfg config.SpeechConfig) (*Bot, error) {
+// NewBots creates one Bot per entry of cfg.BotConfigs(). All bots share the
+// router and orchestrator; each only lists and routes to its own agents.
+func NewBots(cfg config.TelegramConfig, orch *agent.Orchestrator, rtr *router.Router, sc *swarm.Coordinator, reg *registry.Registry, bus *natsbus.Bus, s *store.Store, speechClient *speech.Client, speechCfg config.SpeechConfig) ([]*Bot, error) {
+	botCfgs := cfg.BotConfigs()
+	homeBot := func(agentID string) string {
+		for _, bc := range botCfgs {
+			if bc.AllowsAgent(agentID) {
+				return bc.Name
+			}
+		}
+		return ""
+	}
+
+	bots := make([]*Bot, 0, len(botCfgs))
+	for _, bc := range botCfgs {
+		b, err := newBot(bc, cfg.ParseMode, homeBot, orch, rtr, sc, reg, bus, s, speechClient, speechCfg)
+		if err != nil {
+			return nil, fmt.Errorf("bot %s: %w", bc.Name, err)
+		}
+		b.quota = cfg.Quota
+		b.order = newReplyOrder(cfg.Ordering)
+		bots = append(bots, b)
+	}
+	return bots, nil
+}
+
+func newBot(cfg config.TelegramBotConfig, parseMode string, homeBot func(string) string, orch *agent.Orchestrator, rtr *router.Router, sc *swarm.Coordinator, reg *registry.Registry, bus *natsbus.Bus, s *store.Store, speechClient *speech.Client, speechCfg config.SpeechConfig) (*Bot, error) {
 	bot, err := telego.NewBot(cfg.Token)
 	if err != nil {
 		return nil, fmt.Errorf("create telegram bot: %w", err)
@@ -81,6 +129,8 @@ func NewBot(cfg config.TelegramConfig, orch *agent.Orchestrator, rtr *router.Rou
 		router:      rtr,
 		store:       s,
 		cfg:         cfg,
+		parseMode:   parseMode,
+		homeBot:     homeBot,
 		swarmCoord:  sc,
 		registry:    reg,
 		bus:         bus,
@@ -92,21 +142,19 @@ func NewBot(cfg config.TelegramConfig, orch *agent.Orchestrator, rtr *router.Rou
 		voiceChat:   make(map[int64]bool),
 		mediaGroups: make(map[string]*mediaGroupBuffer),
 	}
+	b.loadChatAgents()
 
-	// Register bot commands with Telegram so they appear in the menu
-	_ = bot.SetMyCommands(context.Background(), &telego.SetMyCommandsParams{
-		Commands: []telego.BotCommand{
-			{Command: "agents", Description: "List available agents"},
-			{Command: "commands", Description: "Show available commands"},
-			{Command: "start", Description: "Say hello to an agent"},
-			{Command: "stop", Description: "Abort the active agent run"},
-			{Command: "reset", Description: "Reset conversation session"},
-			{Command: "nix", Description: "Manage nix packages in agent container"},
-		},
-	})
+	// Register bot commands with Telegram so they appear in the menu,
+	// again whenever an agent registers its own
+	b.syncCommands(context.Background())
+	orch.OnCommands(func(string) { b.syncCommands(context.Background()) })
 
 	// Register output listener to send responses back to Telegram
 	orch.OnOutput(func(agentID, content string, meta map[string]string) {
+		if !b.deliversFor(agentID, meta) {
+			return
+		}
+
 		// Try to get chat_id from meta
 		chatIDStr := ""
 		if meta != nil {
@@ -133,54 +181,45 @@ func NewBot(cfg config.TelegramConfig, orch *agent.Orchestrator, rtr *router.Rou
 		if err != nil {
 			return
 		}
+		userID := senderUserID(meta, chatID)
+		b.order.deliver(chatID, meta, func() { b.sendOutput(agentID, chatID, userID, content) })
+	})
 
-		// Check if we should respond with voice (TTS)
-		shouldTTS := false
-		if b.speech != nil && b.speechCfg.TTSEnabled {
-			switch b.speechCfg.TTSMode {
-			case "always":
-				shouldTTS = true
-			case "voice":
-				b.voiceChatMu.RLock()
-				shouldTTS = b.voiceChat[chatID]
-				b.voiceChatMu.RUnlock()
-			}
+	// Tell the chat when its message failed because the agent can't start
+	orch.OnRunComplete(func(agentID string, meta map[string]string, err error) {
+		if !b.deliversFor(agentID, meta) {
+			return
 		}
-
-		if shouldTTS && len(content) <= 4096 {
-			if audio, err := b.speech.Synthesize(context.Background(), content, b.speechCfg.TTSVoice); err == nil {
-				// Clear voice tracking for this chat
-				b.voiceChatMu.Lock()
-				delete(b.voiceChat, chatID)
-				b.voiceChatMu.Unlock()
-
-				if err := b.SendVoice(context.Background(), chatID, audio); err != nil {
-					slog.Error("failed to send voice response, falling back to text", "chat", chatID, "error", err)
-				} else {
-					return
-				}
-			} else {
-				slog.Warn("tts synthesis failed, falling back to text", "error", err)
-			}
+		chatID, perr := strconv.ParseInt(meta["chat_id"], 10, 64)
+		if perr != nil {
+			return
 		}
+		if notice := startFailureNotice(agentID, err); notice != "" {
+			b.order.deliver(chatID, meta, func() { _ = b.SendMessage(context.Background(), chatID, notice) })
+			return
+		}
+		// The run is over, so later replies need not wait for this one
+		b.order.complete(chatID, meta)
+	})
 
-		// Clear voice tracking even if we didn't TTS (response too long, etc.)
-		b.voiceChatMu.Lock()
-		delete(b.voiceChat, chatID)
-		b.voiceChatMu.Unlock()
-
-		// Prefix with agent name for attribution (skip for default agent)
-		attributed := content
-		if agentID != rtr.DefaultAgent() {
-			attributed = fmt.Sprintf("_%s:_ %s", agentID, content)
+	// Tell the chat its message is taking longer than slow_warning_after
+	orch.OnSlow(func(agentID string, meta map[string]string, elapsed time.Duration) {
+		if !b.deliversFor(agentID, meta) {
+			return
 		}
-		if err := b.sendAgentMessage(context.Background(), chatID, attributed, agentID); err != nil {
-			slog.Error("failed to send telegram message", "chat", chatID, "error", err)
+		chatID, err := strconv.ParseInt(meta["chat_id"], 10, 64)
+		if err != nil {
+			return
 		}
+		notice := fmt.Sprintf("%s is still working on your message (%s so far)…", agentID, elapsed.Round(time.Second))
+		_ = b.SendMessage(context.Background(), chatID, notice)
 	})
 
 	// Register file listener to send files back to Telegram
-	orch.OnFile(func(agentID string, chatID int64, data []byte, name, mimeType, caption string) {
+	orch.OnFile(func(agentID string, chatID int64, data []byte, name, mimeType, caption string, meta map[string]string) {
+		if !b.deliversFor(agentID, meta) {
+			return
+		}
 		ctx := context.Background()
 		if strings.HasPrefix(mimeType, "image/") {
 			if err := b.SendPhoto(ctx, chatID, data, name, caption); err != nil {
@@ -197,15 +236,79 @@ func NewBot(cfg config.TelegramConfig, orch *agent.Orchestrator, rtr *router.Rou
 	if bus != nil && sc != nil {
 		client, cerr := natsbus.NewClient(bus)
 		if cerr == nil {
-			_, _ = client.Subscribe(natsbus.TopicEventsSwarm, func(msg *nats.Msg) {
+			if _, err := client.Subscribe(natsbus.TopicEventsSwarm(), func(msg *nats.Msg) {
 				b.handleSwarmEvent(msg)
-			})
+			}); err != nil {
+				slog.Error("telegram swarm events subscribe failed", "error", err)
+			}
 		}
 	}
 
 	return b, nil
 }
 
+// senderUserID returns the Telegram user id a message's meta carries, or
+// chatID, which is the user's id in a private chat, for meta without one
+// (e.g. scheduled tasks).
+func senderUserID(meta map[string]string, chatID int64) int64 {
+	if id, err := strconv.ParseInt(meta["user_id"], 10, 64); err == nil {
+		return id
+	}
+	return chatID
+}
+
+// sendOutput sends an agent's reply to chatID, as voice when text-to-speech
+// applies to the chat. userID is the sender of the message being answered.
+func (b *Bot) sendOutput(agentID string, chatID, userID int64, content string) {
+	// Check if we should respond with voice (TTS)
+	shouldTTS := false
+	if b.speech != nil && b.speechCfg.TTSEnabled {
+		switch b.speechCfg.TTSMode {
+		case "always":
+			shouldTTS = true
+		case "voice":
+			b.voiceChatMu.RLock()
+			shouldTTS = b.voiceChat[chatID]
+			b.voiceChatMu.RUnlock()
+		}
+	}
+
+	if shouldTTS && len(content) <= 4096 {
+		if audio, err := b.speech.Synthesize(context.Background(), content, b.speechCfg.TTSVoice); err == nil {
+			// Clear voice tracking for this chat
+			b.voiceChatMu.Lock()
+			delete(b.voiceChat, chatID)
+			b.voiceChatMu.Unlock()
+
+			if err := b.SendVoice(context.Background(), chatID, audio); err != nil {
+				slog.Error("failed to send voice response, falling back to text", "chat", chatID, "error", err)
+			} else {
+				return
+			}
+		} else {
+			slog.Warn("tts synthesis failed, falling back to text", "error", err)
+		}
+	}
+
+	// Clear voice tracking even if we didn't TTS (response too long, etc.)
+	b.voiceChatMu.Lock()
+	delete(b.voiceChat, chatID)
+	b.voiceChatMu.Unlock()
+
+	if err := b.sendAgentMessage(context.Background(), chatID, b.attribute(agentID, chatID, userID, content), agentID); err != nil {
+		slog.Error("failed to send telegram message", "chat", chatID, "error", err)
+	}
+}
+
+// attribute prefixes a reply with the agent's name unless the agent is the
+// default for the chat and sender.
+func (b *Bot) attribute(agentID string, chatID, userID int64, content string) string {
+	if agentID == b.router.DefaultAgentIn(chatID, userID, b.cfg.Agents) {
+		return content
+	}
+	return fmt.Sprintf("_%s:_ %s", agentID, content)
+}
+
 func (b *Bot) Start(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	b.cancel = cancel
@@ -255,7 +358,35 @@ func (b *Bot) Start(ctx context.Context) error {
 		if !b.allowedUser(message) {
 			return nil
 		}
-		b.cmdAgents(ctx, message.Chat.ID)
+		_, _, payload := tu.ParseCommandPayload(message.Text)
+		b.cmdRestart(ctx, message.Chat.ID, payload)
+		return nil
+	}, th.CommandEqual("restart"))
+
+	handler.HandleMessage(func(hctx *th.Context, message telego.Message) error {
+		if !b.allowedUser(message) {
+			return nil
+		}
+		_, _, payload := tu.ParseCommandPayload(message.Text)
+		b.cmdExport(ctx, message.Chat.ID, payload)
+		return nil
+	}, th.CommandEqual("export"))
+
+	handler.HandleMessage(func(hctx *th.Context, message telego.Message) error {
+		if !b.allowedUser(message) {
+			return nil
+		}
+		_, _, payload := tu.ParseCommandPayload(message.Text)
+		b.cmdAgain(ctx, message.Chat.ID, message.From.ID, payload)
+		return nil
+	}, th.CommandEqual("again"))
+
+	handler.HandleMessage(func(hctx *th.Context, message telego.Message) error {
+		if !b.allowedUser(message) {
+			return nil
+		}
+		_, _, payload := tu.ParseCommandPayload(message.Text)
+		b.cmdAgents(ctx, message.Chat.ID, payload)
 		return nil
 	}, th.CommandEqual("agents"))
 
@@ -267,15 +398,32 @@ func (b *Bot) Start(ctx context.Context) error {
 		return nil
 	}, th.CommandEqual("commands"))
 
+	handler.HandleMessage(func(hctx *th.Context, message telego.Message) error {
+		if !b.allowedUser(message) {
+			return nil
+		}
+		b.cmdUsage(ctx, message)
+		return nil
+	}, th.CommandEqual("usage"))
+
 	handler.HandleMessage(func(hctx *th.Context, message telego.Message) error {
 		if !b.allowedUser(message) {
 			return nil
 		}
 		_, _, payload := tu.ParseCommandPayload(message.Text)
-		b.cmdPkg(ctx, message.Chat.ID, payload)
+		b.cmdPkg(ctx, message.Chat.ID, message.From.ID, payload)
 		return nil
 	}, th.CommandEqual("nix"))
 
+	// Commands registered by agents; other unknown commands fall through
+	// to the catch-all as before
+	handler.HandleMessage(func(hctx *th.Context, message telego.Message) error {
+		if !b.runAgentCommand(ctx, message) {
+			b.handleMessage(ctx, message)
+		}
+		return nil
+	}, th.AnyCommand())
+
 	// Catch-all for regular messages
 	handler.HandleMessage(func(hctx *th.Context, message telego.Message) error {
 		b.handleMessage(ctx, message)
@@ -305,7 +453,8 @@ func (b *Bot) handleMessage(ctx context.Context, msg telego.Message) {
 
 	// Buffer media group messages (albums) so all images are routed together.
 	// Telegram sends each image as a separate message; only the first carries
-	// the caption. We collect them for 500ms then process the batch.
+	// the caption. We collect them for 500ms then process the batch. An album
+	// counts as one message towards the sender's quota.
 	if msg.MediaGroupID != "" {
 		b.mediaGroupMu.Lock()
 		buf, ok := b.mediaGroups[msg.MediaGroupID]
@@ -323,14 +472,23 @@ func (b *Bot) handleMessage(ctx context.Context, msg telego.Message) {
 			batch := b.mediaGroups[mgID]
 			delete(b.mediaGroups, mgID)
 			b.mediaGroupMu.Unlock()
-			if batch != nil {
+			if batch != nil && !batch.refused {
 				b.processMediaGroup(ctx, batch.messages)
 			}
 		})
 		b.mediaGroupMu.Unlock()
+
+		if !ok && !b.admitUser(ctx, msg) {
+			b.mediaGroupMu.Lock()
+			buf.refused = true
+			b.mediaGroupMu.Unlock()
+		}
 		return
 	}
 
+	if !b.admitUser(ctx, msg) {
+		return
+	}
 	b.processMessage(ctx, msg)
 }
 
@@ -342,6 +500,11 @@ func (b *Bot) processMediaGroup(ctx context.Context, msgs []telego.Message) {
 		return
 	}
 
+	ctx, span := tracing.Tracer().Start(ctx, "telegram.media_group",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.Int64("telegram.chat_id", msgs[0].Chat.ID), attribute.Int("telegram.messages", len(msgs))))
+	defer span.End()
+
 	// Find the caption (only one message in the group has it).
 	var caption string
 	for _, m := range msgs {
@@ -384,7 +547,7 @@ func (b *Bot) processMediaGroup(ctx context.Context, msgs []telego.Message) {
 			routeText = fmt.Sprintf("I'm sending you %d files", len(msgs))
 		}
 		var err error
-		agentID, cleanedMessage, err = b.router.Route(ctx, routeText)
+		agentID, cleanedMessage, err = b.route(ctx, chatID, userID, routeText)
 		if err != nil {
 			slog.Error("routing failed", "error", err)
 			_ = b.SendMessage(ctx, chatID, "Sorry, I couldn't route your message to an agent.")
@@ -399,10 +562,11 @@ func (b *Bot) processMediaGroup(ctx context.Context, msgs []telego.Message) {
 		b.handleSwarmCommand(ctx, chatID, cleanedMessage)
 		return
 	}
+	if !b.checkAgent(ctx, chatID, agentID) {
+		return
+	}
 
-	b.chatAgentMu.Lock()
-	b.chatAgent[chatID] = agentID
-	b.chatAgentMu.Unlock()
+	b.setChatAgent(chatID, agentID)
 
 	_ = b.sendChatAction(ctx, chatID)
 
@@ -442,11 +606,27 @@ func (b *Bot) processMediaGroup(ctx context.Context, msgs []telego.Message) {
 	}
 
 	meta := map[string]string{
-		"sender":  fmt.Sprintf("user:%s", senderID),
-		"chat_id": chatIDStr,
+		"sender":       fmt.Sprintf("user:%s", senderID),
+		"user_id":      senderID,
+		"chat_id":      chatIDStr,
+		"telegram_bot": b.cfg.Name,
 	}
+	b.order.tag(chatID, meta)
 
 	if err := b.orch.HandleMessage(ctx, agentID, cleanedMessage, meta); err != nil {
+		b.order.complete(chatID, meta)
+		if errors.Is(err, agent.ErrRateLimited) {
+			_ = b.SendMessage(ctx, chatID, fmt.Sprintf("Slow down — %s is receiving too many messages. Try again in a moment.", agentID))
+			return
+		}
+		if errors.Is(err, agent.ErrMessageTooLarge) {
+			_ = b.SendMessage(ctx, chatID, "That message is too long. Try sending it as a file instead.")
+			return
+		}
+		if errors.Is(err, agent.ErrSafeMode) {
+			_ = b.SendMessage(ctx, chatID, safeModeNotice)
+			return
+		}
 		slog.Error("handle message failed", "agent", agentID, "error", err)
 		_ = b.SendMessage(ctx, chatID, "Sorry, I encountered an error processing your message.")
 	}
@@ -456,6 +636,11 @@ func (b *Bot) processMessage(ctx context.Context, msg telego.Message) {
 	chatID := msg.Chat.ID
 	userID := msg.From.ID
 
+	ctx, span := tracing.Tracer().Start(ctx, "telegram.message",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.Int64("telegram.chat_id", chatID)))
+	defer span.End()
+
 	// Extract text from message or caption
 	text := msg.Text
 	if text == "" {
@@ -501,7 +686,7 @@ func (b *Bot) processMessage(ctx context.Context, msg telego.Message) {
 	// Fall back to normal routing
 	if agentID == "" {
 		var err error
-		agentID, cleanedMessage, err = b.router.Route(ctx, text)
+		agentID, cleanedMessage, err = b.route(ctx, chatID, userID, text)
 		if err != nil {
 			slog.Error("routing failed", "error", err)
 			_ = b.SendMessage(ctx, chatID, "Sorry, I couldn't route your message to an agent.")
@@ -517,11 +702,12 @@ func (b *Bot) processMessage(ctx context.Context, msg telego.Message) {
 		b.handleSwarmCommand(ctx, chatID, cleanedMessage)
 		return
 	}
+	if !b.checkAgent(ctx, chatID, agentID) {
+		return
+	}
 
 	// Track which chat is talking to which agent
-	b.chatAgentMu.Lock()
-	b.chatAgent[chatID] = agentID
-	b.chatAgentMu.Unlock()
+	b.setChatAgent(chatID, agentID)
 
 	// Send thinking indicator
 	_ = b.sendChatAction(ctx, chatID)
@@ -583,11 +769,27 @@ func (b *Bot) processMessage(ctx context.Context, msg telego.Message) {
 	}
 
 	meta := map[string]string{
-		"sender":  fmt.Sprintf("user:%s", senderID),
-		"chat_id": chatIDStr,
+		"sender":       fmt.Sprintf("user:%s", senderID),
+		"user_id":      senderID,
+		"chat_id":      chatIDStr,
+		"telegram_bot": b.cfg.Name,
 	}
+	b.order.tag(chatID, meta)
 
 	if err := b.orch.HandleMessage(ctx, agentID, cleanedMessage, meta); err != nil {
+		b.order.complete(chatID, meta)
+		if errors.Is(err, agent.ErrRateLimited) {
+			_ = b.SendMessage(ctx, chatID, fmt.Sprintf("Slow down — %s is receiving too many messages. Try again in a moment.", agentID))
+			return
+		}
+		if errors.Is(err, agent.ErrMessageTooLarge) {
+			_ = b.SendMessage(ctx, chatID, "That message is too long. Try sending it as a file instead.")
+			return
+		}
+		if errors.Is(err, agent.ErrSafeMode) {
+			_ = b.SendMessage(ctx, chatID, safeModeNotice)
+			return
+		}
 		slog.Error("handle message failed", "agent", agentID, "error", err)
 		_ = b.SendMessage(ctx, chatID, "Sorry, I encountered an error processing your message.")
 	}
@@ -695,18 +897,28 @@ func (b *Bot) SendMessage(ctx context.Context, chatID int64, text string) error
 
 // sendMessage sends a (possibly chunked) message and returns the IDs of the sent Telegram messages.
 func (b *Bot) sendMessage(ctx context.Context, chatID int64, text string) ([]int, error) {
-	text = toTelegramMarkdown(text)
+	parseMode := telego.ModeMarkdownV2
+	if b.parseMode == ParseModeHTML {
+		parseMode = telego.ModeHTML
+		text = toTelegramHTML(text)
+	} else {
+		text = toTelegramMarkdown(text)
+	}
 	chunks := chunkMessage(text)
 	var ids []int
 	for _, chunk := range chunks {
 		msg := tu.Message(tu.ID(chatID), chunk)
-		msg.ParseMode = telego.ModeMarkdownV2
+		msg.ParseMode = parseMode
 		sent, err := b.bot.SendMessage(ctx, msg)
 		if err != nil {
-			// Markdown parsing can fail on unescaped characters;
-			// retry as plain text so the message still gets delivered.
-			slog.Warn("MarkdownV2 parse failed, retrying as plain text", "error", err)
+			// Markup parsing can fail on unescaped characters (or a tag
+			// split across chunks); retry as plain text so the message
+			// still gets delivered.
+			slog.Warn("formatted message rejected, retrying as plain text", "parse_mode", parseMode, "error", err)
 			msg.ParseMode = ""
+			if parseMode == telego.ModeHTML {
+				msg.Text = htmlToPlain(chunk)
+			}
 			sent, err = b.bot.SendMessage(ctx, msg)
 		}
 		if err != nil {
@@ -846,6 +1058,15 @@ func (b *Bot) handleSwarmCommand(ctx context.Context, chatID int64, message stri
 		Task:      task,
 	}
 
+	if _, err := swarm.BuildPlan(agents, synapses, leadAgent); err != nil {
+		_ = b.SendMessage(ctx, chatID, swarmGraphMessage(err))
+		return
+	}
+
+	if b.orch.SafeMode() {
+		_ = b.SendMessage(ctx, chatID, safeModeNotice)
+		return
+	}
 	_ = b.SendMessage(ctx, chatID, fmt.Sprintf("Launching swarm with %d agents...", len(agents)))
 
 	run, err := b.swarmCoord.RunSwarm(ctx, req)
@@ -860,6 +1081,25 @@ func (b *Bot) handleSwarmCommand(ctx context.Context, chatID int64, message stri
 	b.swarmChatMu.Unlock()
 }
 
+// swarmGraphMessage explains a graph BuildPlan rejected in terms of the
+// swarm syntax.
+func swarmGraphMessage(err error) string {
+	var gerr *swarm.GraphError
+	if !errors.As(err, &gerr) {
+		return fmt.Sprintf("Invalid swarm: %s", err)
+	}
+	switch gerr.Kind {
+	case swarm.GraphErrCycle:
+		return fmt.Sprintf("Invalid swarm: the pipeline loops back on itself (%s>%s). Remove one of the > links.",
+			strings.Join(gerr.Cycle, ">"), gerr.Cycle[0])
+	case swarm.GraphErrUnknownRole:
+		return fmt.Sprintf("Invalid swarm: %s in %s>%s is not one of the swarm's agents.", gerr.Role, gerr.Edge.From, gerr.Edge.To)
+	case swarm.GraphErrLeadNotMember:
+		return fmt.Sprintf("Invalid swarm: lead agent %s is not in the swarm.", gerr.Role)
+	}
+	return fmt.Sprintf("Invalid swarm: %s", err)
+}
+
 func (b *Bot) parseSwarmSpec(spec string) ([]swarm.SwarmAgent, []swarm.Synapse, string, error) {
 	var agents []swarm.SwarmAgent
 	var synapses []swarm.Synapse
@@ -874,7 +1114,7 @@ func (b *Bot) parseSwarmSpec(spec string) ([]swarm.SwarmAgent, []swarm.Synapse,
 		if seen[name] {
 			return nil
 		}
-		if _, ok := b.registry.GetDefinition(name); !ok {
+		if _, ok := b.registry.GetDefinition(name); !ok || !b.allowsAgent(name) {
 			return fmt.Errorf("unknown agent: %s", name)
 		}
 		seen[name] = true
@@ -939,6 +1179,41 @@ func (b *Bot) parseSwarmSpec(spec string) ([]swarm.SwarmAgent, []swarm.Synapse,
 	return agents, synapses, leadAgent, nil
 }
 
+// route picks the agent for an unbound message, choosing only among this
+// bot's agents unless the message names one with an @prefix.
+func (b *Bot) route(ctx context.Context, chatID, userID int64, text string) (string, string, error) {
+	return b.router.RouteFor(ctx, chatID, userID, b.cfg.Agents, text)
+}
+
+// allowsAgent reports whether this bot may list and route to agentID.
+func (b *Bot) allowsAgent(agentID string) bool {
+	return b.cfg.AllowsAgent(agentID)
+}
+
+// checkAgent reports whether this bot may route to agentID, telling the chat
+// when it may not.
+func (b *Bot) checkAgent(ctx context.Context, chatID int64, agentID string) bool {
+	if b.allowsAgent(agentID) {
+		return true
+	}
+	slog.Warn("agent not available on this telegram bot", "bot", b.cfg.Name, "agent", agentID, "chat", chatID)
+	_ = b.SendMessage(ctx, chatID, fmt.Sprintf("Agent *%s* is not available here.", agentID))
+	return false
+}
+
+// deliversFor reports whether this bot should deliver agent output. Messages
+// from Telegram carry the receiving bot's name; anything else (scheduler,
+// web, swarms) is delivered by the agent's home bot.
+func (b *Bot) deliversFor(agentID string, meta map[string]string) bool {
+	if name := meta["telegram_bot"]; name != "" {
+		return name == b.cfg.Name
+	}
+	if b.homeBot != nil {
+		return b.homeBot(agentID) == b.cfg.Name
+	}
+	return b.allowsAgent(agentID)
+}
+
 // allowedUser checks whether the message sender is in the allow list.
 func (b *Bot) allowedUser(msg telego.Message) bool {
 	if len(b.cfg.AllowFrom) == 0 {
@@ -969,31 +1244,70 @@ func (b *Bot) cmdStart(ctx context.Context, msg telego.Message, payload string)
 		agentID = strings.TrimPrefix(f[0], "@")
 	}
 	if agentID == "" {
-		agentID = b.router.DefaultAgent()
+		agentID = b.router.DefaultAgentIn(chatID, msg.From.ID, b.cfg.Agents)
+	}
+	if !b.checkAgent(ctx, chatID, agentID) {
+		return
 	}
 
-	b.chatAgentMu.Lock()
-	b.chatAgent[chatID] = agentID
-	b.chatAgentMu.Unlock()
+	b.setChatAgent(chatID, agentID)
 
+	prompt, welcome := b.startGreeting(agentID)
+	if welcome != "" {
+		_ = b.SendMessage(ctx, chatID, welcome)
+	}
 	_ = b.sendChatAction(ctx, chatID)
 
 	meta := map[string]string{
-		"sender":  fmt.Sprintf("user:%d", msg.From.ID),
-		"chat_id": strconv.FormatInt(chatID, 10),
-	}
-	if err := b.orch.HandleMessage(ctx, agentID, "Hello!", meta); err != nil {
+		"sender":       fmt.Sprintf("user:%d", msg.From.ID),
+		"user_id":      strconv.FormatInt(msg.From.ID, 10),
+		"chat_id":      strconv.FormatInt(chatID, 10),
+		"telegram_bot": b.cfg.Name,
+	}
+	if err := b.orch.HandleMessage(ctx, agentID, prompt, meta); err != nil {
+		if errors.Is(err, agent.ErrSafeMode) {
+			_ = b.SendMessage(ctx, chatID, safeModeNotice)
+			return
+		}
 		slog.Error("handle start failed", "agent", agentID, "error", err)
 		_ = b.SendMessage(ctx, chatID, "Sorry, I encountered an error starting the conversation.")
 	}
 }
 
+// safeModeNotice answers messages while the gateway runs in safe mode.
+const safeModeNotice = "Praktor is in safe mode for maintenance, so agents aren't running right now. Try again later."
+
+// startFailureNotice explains a start error the user can't fix by retrying,
+// or returns "" for other errors.
+func startFailureNotice(agentID string, err error) string {
+	var archErr *container.ImageArchError
+	if errors.As(err, &archErr) {
+		return fmt.Sprintf("Agent *%s* can't start: its image `%s` is built for %s but this host is %s.", agentID, archErr.Image, archErr.ImageArch, archErr.HostArch)
+	}
+	return ""
+}
+
+// startGreeting returns the prompt /start sends agentID and the optional
+// welcome message sent to the user before the agent replies.
+func (b *Bot) startGreeting(agentID string) (prompt, welcome string) {
+	if b.registry != nil {
+		prompt, welcome = b.registry.ResolveGreeting(agentID)
+	}
+	if prompt == "" {
+		prompt = "Hello!"
+	}
+	return prompt, welcome
+}
+
 func (b *Bot) cmdStop(ctx context.Context, chatID int64, payload string) {
 	agentID := b.resolveAgent(chatID, payload)
 	if agentID == "" {
 		_ = b.SendMessage(ctx, chatID, "Usage: /stop [agent]")
 		return
 	}
+	if !b.checkAgent(ctx, chatID, agentID) {
+		return
+	}
 	if err := b.orch.AbortSession(ctx, agentID); err != nil {
 		_ = b.SendMessage(ctx, chatID, fmt.Sprintf("Failed to stop *%s*: %s", agentID, err))
 		return
@@ -1007,6 +1321,9 @@ func (b *Bot) cmdReset(ctx context.Context, chatID int64, payload string) {
 		_ = b.SendMessage(ctx, chatID, "Usage: /reset [agent]")
 		return
 	}
+	if !b.checkAgent(ctx, chatID, agentID) {
+		return
+	}
 	if err := b.orch.ClearSession(ctx, agentID); err != nil {
 		_ = b.SendMessage(ctx, chatID, fmt.Sprintf("Failed to clear session for *%s*: %s", agentID, err))
 		return
@@ -1014,20 +1331,70 @@ func (b *Bot) cmdReset(ctx context.Context, chatID int64, payload string) {
 	_ = b.SendMessage(ctx, chatID, fmt.Sprintf("New session started for *%s*.", agentID))
 }
 
+func (b *Bot) cmdRestart(ctx context.Context, chatID int64, payload string) {
+	agentID := b.resolveAgent(chatID, payload)
+	if agentID == "" {
+		_ = b.SendMessage(ctx, chatID, "Usage: /restart [agent]")
+		return
+	}
+	if !b.checkAgent(ctx, chatID, agentID) {
+		return
+	}
+	_ = b.sendChatAction(ctx, chatID)
+	if _, err := b.orch.BounceAgent(ctx, agentID, false); err != nil {
+		slog.Error("restart failed", "agent", agentID, "error", err)
+		_ = b.SendMessage(ctx, chatID, fmt.Sprintf("Failed to restart *%s*: %s", agentID, err))
+		return
+	}
+	_ = b.SendMessage(ctx, chatID, fmt.Sprintf("Restarted *%s*.", agentID))
+}
+
+func (b *Bot) cmdAgain(ctx context.Context, chatID, userID int64, payload string) {
+	agentID := b.resolveAgent(chatID, payload)
+	if agentID == "" {
+		_ = b.SendMessage(ctx, chatID, "Usage: /again [agent]")
+		return
+	}
+	if !b.checkAgent(ctx, chatID, agentID) {
+		return
+	}
+	meta := map[string]string{
+		"user_id":      strconv.FormatInt(userID, 10),
+		"chat_id":      strconv.FormatInt(chatID, 10),
+		"telegram_bot": b.cfg.Name,
+	}
+	if err := b.orch.ReplayLast(agentID, meta); err != nil {
+		if errors.Is(err, agent.ErrNothingToReplay) {
+			_ = b.SendMessage(ctx, chatID, fmt.Sprintf("No reply from *%s* to resend.", agentID))
+			return
+		}
+		slog.Error("replay failed", "agent", agentID, "error", err)
+		_ = b.SendMessage(ctx, chatID, fmt.Sprintf("Failed to resend the last reply of *%s*.", agentID))
+	}
+}
+
 func (b *Bot) cmdCommands(ctx context.Context, chatID int64) {
 	text := "*Commands*\n\n" +
-		"  /agents — List available agents\n" +
+		"  /agents \\[tag...] — List available agents\n" +
 		"  /commands — Show available commands\n" +
 		"  /start \\[agent] — Say hello to an agent\n" +
 		"  /stop \\[agent] — Abort the active agent run\n" +
 		"  /reset \\[agent] — Reset conversation session\n" +
+		"  /restart \\[agent] — Restart the agent container, keeping the session\n" +
+		"  /export \\[agent] — Send the conversation transcript as a file\n" +
+		"  /again \\[agent] — Resend the agent's last reply\n" +
 		"  /nix <action> \\[package] \\[@agent] — Manage nix packages\n" +
+		"  /usage — Show your message usage and quota\n" +
+		b.agentCommandsHelp() +
 		"\n@agent\\_name prefix or smart routing for regular messages.\n" +
 		"@swarm prefix for swarm orchestration."
 	_ = b.SendMessage(ctx, chatID, text)
 }
 
-func (b *Bot) cmdAgents(ctx context.Context, chatID int64) {
+// cmdAgents lists the bot's agents; payload optionally holds tags
+// ("team:infra env:prod") an agent must all carry to be listed.
+func (b *Bot) cmdAgents(ctx context.Context, chatID int64, payload string) {
+	tags := strings.Fields(payload)
 	agents, err := b.store.ListAgents()
 	if err != nil {
 		_ = b.SendMessage(ctx, chatID, "Failed to list agents.")
@@ -1044,7 +1411,12 @@ func (b *Bot) cmdAgents(ctx context.Context, chatID int64) {
 
 	var sb strings.Builder
 	sb.WriteString("*Agents*\n\n")
+	listed := 0
 	for _, a := range agents {
+		if !b.allowsAgent(a.ID) || !a.HasTags(tags...) {
+			continue
+		}
+		listed++
 		status := "stopped"
 		if runningSet[a.ID] {
 			if as := b.orch.PingAgent(a.ID); as != nil {
@@ -1070,6 +1442,9 @@ func (b *Bot) cmdAgents(ctx context.Context, chatID int64) {
 			fmt.Fprintf(&sb, " — %s", a.Description)
 		}
 		fmt.Fprintf(&sb, "\n  Status: `%s` | Model: `%s`", status, model)
+		if len(a.Tags) > 0 {
+			fmt.Fprintf(&sb, " | Tags: `%s`", strings.Join(a.Tags, " "))
+		}
 
 		if def, ok := b.registry.GetDefinition(a.ID); ok && def.NixEnabled {
 			sb.WriteString(" | Nix: `enabled`")
@@ -1081,14 +1456,16 @@ func (b *Bot) cmdAgents(ctx context.Context, chatID int64) {
 		sb.WriteString("\n\n")
 	}
 
-	if len(agents) == 0 {
+	if listed == 0 && len(tags) > 0 {
+		sb.WriteString("No agents match those tags.")
+	} else if listed == 0 {
 		sb.WriteString("No agents configured.")
 	}
 
 	_ = b.SendMessage(ctx, chatID, sb.String())
 }
 
-func (b *Bot) cmdPkg(ctx context.Context, chatID int64, payload string) {
+func (b *Bot) cmdPkg(ctx context.Context, chatID, userID int64, payload string) {
 	usage := "Usage: /nix <search|add|list|remove|upgrade> \\[package] \\[@agent]"
 
 	args := strings.Fields(payload)
@@ -1111,7 +1488,10 @@ func (b *Bot) cmdPkg(ctx context.Context, chatID int64, payload string) {
 	action := cleanArgs[0]
 	agentID := agentHint
 	if agentID == "" {
-		agentID = b.router.DefaultAgent()
+		agentID = b.router.DefaultAgentIn(chatID, userID, b.cfg.Agents)
+	}
+	if !b.checkAgent(ctx, chatID, agentID) {
+		return
 	}
 
 	var cmd []string
@@ -1245,18 +1625,22 @@ func parseNixSearchResults(jsonOutput string) string {
 
 // handleSwarmEvent handles swarm completion events and delivers results to Telegram.
 func (b *Bot) handleSwarmEvent(msg *nats.Msg) {
-	var event struct {
-		Type    string          `json:"type"`
-		SwarmID string          `json:"swarm_id"`
-		Data    json.RawMessage `json:"data"`
-	}
+	var event events.Swarm[events.SwarmFinishedData]
 	if err := json.Unmarshal(msg.Data, &event); err != nil {
 		return
 	}
 
-	if event.Type != "swarm_completed" && event.Type != "swarm_failed" {
+	switch event.Type {
+	case "swarm_completed", "swarm_completed_with_errors", events.TypeSwarmFailed:
+	default:
 		return
 	}
+	counts := event.Data
+	// e.g. " (3/4 agents succeeded)"; empty when every agent succeeded.
+	var partial string
+	if counts.Total > 0 && counts.Succeeded < counts.Total {
+		partial = fmt.Sprintf(" (%d/%d agents succeeded)", counts.Succeeded, counts.Total)
+	}
 
 	b.swarmChatMu.RLock()
 	chatID, ok := b.swarmChat[event.SwarmID]
@@ -1267,7 +1651,7 @@ func (b *Bot) handleSwarmEvent(msg *nats.Msg) {
 		b.swarmChatMu.Lock()
 		delete(b.swarmChat, event.SwarmID)
 		b.swarmChatMu.Unlock()
-	} else if b.cfg.MainChatID != 0 {
+	} else if b.cfg.MainChatID != 0 && b.deliversSwarm(event.SwarmID) {
 		// Swarm launched from Mission Control — deliver to main chat
 		chatID = b.cfg.MainChatID
 	} else {
@@ -1277,7 +1661,7 @@ func (b *Bot) handleSwarmEvent(msg *nats.Msg) {
 	ctx := context.Background()
 
 	if event.Type == "swarm_failed" {
-		_ = b.SendMessage(ctx, chatID, "Swarm failed.")
+		_ = b.SendMessage(ctx, chatID, "Swarm failed"+partial+".")
 		return
 	}
 
@@ -1303,11 +1687,11 @@ func (b *Bot) handleSwarmEvent(msg *nats.Msg) {
 	}
 
 	if leadResult != "" {
-		_ = b.SendMessage(ctx, chatID, fmt.Sprintf("*Swarm Result* (%s):\n\n%s", run.Name, leadResult))
+		_ = b.SendMessage(ctx, chatID, fmt.Sprintf("*Swarm Result* (%s)%s:\n\n%s", run.Name, partial, leadResult))
 	} else {
 		// Send all results if no lead result
 		var sb strings.Builder
-		fmt.Fprintf(&sb, "*Swarm Complete* (%s):\n\n", run.Name)
+		fmt.Fprintf(&sb, "*Swarm Complete* (%s)%s:\n\n", run.Name, partial)
 		for _, r := range results {
 			fmt.Fprintf(&sb, "*%s* [%s]", r.Role, r.Status)
 			if r.Output != "" {
@@ -1322,3 +1706,16 @@ func (b *Bot) handleSwarmEvent(msg *nats.Msg) {
 		_ = b.SendMessage(ctx, chatID, sb.String())
 	}
 }
+
+// deliversSwarm reports whether this bot is the home bot of a swarm's lead
+// agent, so Mission Control swarms are announced by exactly one bot.
+func (b *Bot) deliversSwarm(swarmID string) bool {
+	if b.homeBot == nil {
+		return true
+	}
+	run, err := b.swarmCoord.GetStatus(swarmID)
+	if err != nil || run == nil {
+		return false
+	}
+	return b.homeBot(run.LeadAgent) == b.cfg.Name
+}
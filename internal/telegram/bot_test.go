@@ -1,10 +1,21 @@
 package telegram
 
 import (
+	"fmt"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 	"unicode/utf8"
 
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/registry"
+	"github.com/mtzanidakis/praktor/internal/router"
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/swarm"
 	"github.com/mymmrac/telego"
 )
 
@@ -128,6 +139,72 @@ func TestToTelegramMarkdown(t *testing.T) {
 	}
 }
 
+func TestEscapeHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		in, want string
+	}{
+		{"plain text", "hello world", "hello world"},
+		{"angle brackets", "a < b > c", "a &lt; b &gt; c"},
+		{"ampersand", "R&D", "R&amp;D"},
+		{"entity not double escaped", "&lt;", "&amp;lt;"},
+		{"markdown specials untouched", "file.txt (copy)!", "file.txt (copy)!"},
+		{"pre-escaped underscore", `photo\_2024.jpg`, "photo_2024.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := escapeHTML(tt.in)
+			if got != tt.want {
+				t.Errorf("escapeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToTelegramHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		in, want string
+	}{
+		{"bold", "**bold**", "<b>bold</b>"},
+		{"bold inline", "hello **world**!", "hello <b>world</b>!"},
+		{"single star bold", "*Total: 5 files*", "<b>Total: 5 files</b>"},
+		{"bold escapes content", "**a<b>**", "<b>a&lt;b&gt;</b>"},
+		{"italic", "_agent:_ done", "<i>agent:</i> done"},
+		{"snake case not italic", "set my_var_name now", "set my_var_name now"},
+		{"header", "## Section", "<b>Section</b>"},
+		{"horizontal rule", "above\n---\nbelow", "above\n\nbelow"},
+		{"bullet", "- item one\n* item two", "• item one\n• item two"},
+		{"inline code", "run `ls -la` now", "run <code>ls -la</code> now"},
+		{"inline code escapes", "`a<b && c>d`", "<code>a&lt;b &amp;&amp; c&gt;d</code>"},
+		{"inline code keeps markdown", "`**x** and a_b_c`", "<code>**x** and a_b_c</code>"},
+		{"inline code keeps backslash", "`C:\\path`", "<code>C:\\path</code>"},
+		{"link", "[click & go](https://example.com/?a=1&b=2)", `<a href="https://example.com/?a=1&amp;b=2">click &amp; go</a>`},
+		{"image embed", "![shot](https://example.com/i.png)", `<a href="https://example.com/i.png">shot</a>`},
+		{"brackets in text", "arr[0] = x", "arr[0] = x"},
+		{"code block", "```\nif a < b {}\n```", "<pre>if a &lt; b {}</pre>"},
+		{"code block with lang", "```go\nfmt.Println(\"hi\")\n```", `<pre><code class="language-go">fmt.Println("hi")</code></pre>`},
+		{"code block protected", "```\n## header\n**bold**\n```", "<pre>## header\n**bold**</pre>"},
+		{"unterminated code block", "a ``` b", "a ``` b"},
+		{"table", "| A | B |\n|---|---|\n| 1 | 2 |", "<pre>A │ B\n──┼──\n1 │ 2</pre>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toTelegramHTML(tt.in)
+			if got != tt.want {
+				t.Errorf("toTelegramHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLToPlain(t *testing.T) {
+	in := toTelegramHTML("**R&D** uses `a<b`")
+	if got, want := htmlToPlain(in), "R&D uses a<b"; got != want {
+		t.Errorf("htmlToPlain(%q) = %q, want %q", in, got, want)
+	}
+}
+
 func TestConvertMarkdownTables(t *testing.T) {
 	input := "Here is a table:\n| Name | Value |\n|---|---|\n| BTC | $67,671 |\n| ETH | $3,052 |\n\nEnd."
 	got := convertMarkdownTables(input)
@@ -309,3 +386,410 @@ func TestExtractAttachment(t *testing.T) {
 		t.Errorf("expected largest photo (FileID=large), got %q", got.FileID)
 	}
 }
+
+func TestBotAgentFiltering(t *testing.T) {
+	cfg := config.TelegramConfig{Bots: []config.TelegramBotConfig{
+		{Name: "ops", Token: "t1", Agents: []string{"general", "deploy"}},
+		{Name: "research", Token: "t2", Agents: []string{"general", "papers"}},
+	}}
+	botCfgs := cfg.BotConfigs()
+	homeBot := func(agentID string) string {
+		for _, bc := range botCfgs {
+			if bc.AllowsAgent(agentID) {
+				return bc.Name
+			}
+		}
+		return ""
+	}
+	ops := &Bot{cfg: botCfgs[0], homeBot: homeBot}
+	research := &Bot{cfg: botCfgs[1], homeBot: homeBot}
+
+	if !ops.allowsAgent("deploy") || ops.allowsAgent("papers") {
+		t.Error("ops bot should allow deploy but not papers")
+	}
+
+	// Telegram-originated output goes back through the bot that received it.
+	meta := map[string]string{"chat_id": "1", "telegram_bot": "research"}
+	if ops.deliversFor("general", meta) || !research.deliversFor("general", meta) {
+		t.Error("expected only the receiving bot to deliver")
+	}
+
+	// Other output (scheduler, web) is delivered once, by the home bot.
+	meta = map[string]string{"chat_id": "1"}
+	if !ops.deliversFor("general", meta) || research.deliversFor("general", meta) {
+		t.Error("expected shared agent output to be delivered by the first bot only")
+	}
+	if ops.deliversFor("papers", meta) || !research.deliversFor("papers", meta) {
+		t.Error("expected papers output to be delivered by the research bot")
+	}
+}
+
+func TestBotRoutesToOwnAgents(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.New(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	reg := registry.New(s, map[string]config.AgentDefinition{
+		"general": {Description: "General assistant", Workspace: "general"},
+		"deploy":  {Description: "Deployments", Workspace: "deploy"},
+	}, config.DefaultsConfig{}, filepath.Join(dir, "agents"))
+	_ = reg.Sync()
+
+	b := &Bot{
+		cfg:    config.TelegramBotConfig{Name: "ops", Agents: []string{"deploy"}},
+		router: router.New(reg, config.RouterConfig{DefaultAgent: "general"}),
+	}
+
+	// The global default isn't on this bot, so its own agent takes the message.
+	agentID, msg, err := b.route(t.Context(), 1, 7, "ship it")
+	if err != nil || agentID != "deploy" || msg != "ship it" {
+		t.Errorf("unprefixed message routed to %q (%q, %v), want deploy", agentID, msg, err)
+	}
+	if !b.allowsAgent(agentID) {
+		t.Errorf("routed to %q, which the bot doesn't serve", agentID)
+	}
+	// Replies from the bot's stand-in default carry no agent prefix.
+	if got := b.attribute("deploy", 1, 7, "done"); got != "done" {
+		t.Errorf("stand-in default reply = %q, want no prefix", got)
+	}
+}
+
+func TestReplyAttribution(t *testing.T) {
+	b := &Bot{router: router.New(nil, config.RouterConfig{
+		DefaultAgent: "general",
+		UserDefaults: map[int64]string{42: "coder"},
+	})}
+
+	// In a group the sender's user default decides, not the chat id.
+	userID := senderUserID(map[string]string{"chat_id": "-100", "user_id": "42"}, -100)
+	if got := b.attribute("coder", -100, userID, "done"); got != "done" {
+		t.Errorf("user default reply = %q, want no prefix", got)
+	}
+	if got := b.attribute("general", -100, userID, "done"); got != "_general:_ done" {
+		t.Errorf("other agent reply = %q, want the agent prefix", got)
+	}
+
+	// Without a user id (e.g. a scheduled task) the chat id stands in.
+	if id := senderUserID(map[string]string{"chat_id": "42"}, 42); id != 42 {
+		t.Errorf("senderUserID without user_id = %d, want the chat id", id)
+	}
+}
+
+func TestChatAgentPrefix(t *testing.T) {
+	if got := (&Bot{cfg: config.TelegramBotConfig{Name: config.DefaultBotName}}).chatAgentPrefix(); got != "telegram.chat_agent." {
+		t.Errorf("default bot prefix = %q", got)
+	}
+	if got := (&Bot{cfg: config.TelegramBotConfig{Name: "ops"}}).chatAgentPrefix(); got != "telegram.chat_agent.ops." {
+		t.Errorf("named bot prefix = %q", got)
+	}
+}
+
+func TestBuildTranscript(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	if err := s.SaveAgent(&store.Agent{ID: "alpha", Name: "alpha", Workspace: "alpha"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, n, err := buildTranscript(s, "alpha", time.Now(), maxTranscriptBytes); err != nil || n != 0 || data != nil {
+		t.Fatalf("empty history: got %d messages, err %v", n, err)
+	}
+
+	// More than one page, to exercise paging.
+	total := transcriptPageSize + 5
+	for i := range total {
+		sender := "user"
+		if i%2 == 1 {
+			sender = "agent"
+		}
+		if err := s.SaveMessage(&store.Message{AgentID: "alpha", Sender: sender, Content: fmt.Sprintf("message %03d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, n, err := buildTranscript(s, "alpha", time.Now(), maxTranscriptBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != total {
+		t.Errorf("got %d messages, want %d", n, total)
+	}
+	doc := string(data)
+	if !strings.HasPrefix(doc, "# Conversation with alpha\n") {
+		t.Errorf("unexpected header: %q", doc[:min(len(doc), 60)])
+	}
+	first, last := strings.Index(doc, "message 000"), strings.Index(doc, fmt.Sprintf("message %03d", total-1))
+	if first < 0 || last < 0 || first > last {
+		t.Error("transcript is not in chronological order")
+	}
+	if strings.Contains(doc, "omitted") {
+		t.Error("full transcript marked as truncated")
+	}
+
+	// A small cap keeps only the newest messages.
+	data, n, err = buildTranscript(s, "alpha", time.Now(), 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc = string(data)
+	if n == 0 || n >= total {
+		t.Fatalf("capped transcript has %d messages", n)
+	}
+	if strings.Contains(doc, "message 000") || !strings.Contains(doc, fmt.Sprintf("message %03d", total-1)) {
+		t.Error("capped transcript should keep the newest messages")
+	}
+	if !strings.Contains(doc, "older messages omitted") {
+		t.Error("capped transcript not marked as truncated")
+	}
+}
+
+func TestAgentCommandDispatch(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	for _, id := range []string{"coder", "papers"} {
+		if err := s.SaveAgent(&store.Agent{ID: id, Name: id, Workspace: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = s.SetAgentCommands("coder", []store.AgentCommand{{Command: "deploy", Description: "Deploy", PromptTemplate: "Deploy {{args}} to prod"}})
+	_ = s.SetAgentCommands("papers", []store.AgentCommand{{Command: "digest", Description: "Digest", PromptTemplate: "Summarize new papers"}})
+
+	// A bot only picks up commands of the agents it serves.
+	b := &Bot{store: s, cfg: config.TelegramBotConfig{Name: "ops", Agents: []string{"coder"}}}
+	menu := b.loadAgentCommands()
+	if len(menu) != 1 || menu[0].Command != "coder_deploy" || menu[0].Description != "Deploy" {
+		t.Fatalf("unexpected menu %+v", menu)
+	}
+
+	c, prompt, ok := b.matchAgentCommand("/coder_deploy@praktor_bot api")
+	if !ok || c.AgentID != "coder" || prompt != "Deploy api to prod" {
+		t.Errorf("match = %+v, %q, %v", c, prompt, ok)
+	}
+	for _, text := range []string{"/papers_digest", "/agents", "/unknown_cmd"} {
+		if _, _, ok := b.matchAgentCommand(text); ok {
+			t.Errorf("%s should not match an agent command", text)
+		}
+	}
+}
+
+func TestStartGreeting(t *testing.T) {
+	dir := t.TempDir()
+	s, err := store.New(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	agents := map[string]config.AgentDefinition{
+		"general": {Workspace: "general"},
+		"coder":   {Workspace: "coder", GreetingPrompt: "Introduce yourself as the coding agent.", WelcomeMessage: "Starting the coder..."},
+	}
+	reg := registry.New(s, agents, config.DefaultsConfig{GreetingPrompt: "Hi there"}, filepath.Join(dir, "agents"))
+
+	b := &Bot{registry: reg}
+	if prompt, welcome := b.startGreeting("coder"); prompt != "Introduce yourself as the coding agent." || welcome != "Starting the coder..." {
+		t.Errorf("coder: prompt %q, welcome %q", prompt, welcome)
+	}
+	if prompt, welcome := b.startGreeting("general"); prompt != "Hi there" || welcome != "" {
+		t.Errorf("general: prompt %q, welcome %q, want the default prompt and no welcome", prompt, welcome)
+	}
+	if prompt, _ := (&Bot{}).startGreeting("general"); prompt != "Hello!" {
+		t.Errorf("without a registry: prompt %q, want Hello!", prompt)
+	}
+}
+
+func TestStartFailureNotice(t *testing.T) {
+	err := fmt.Errorf("start agent: %w", &container.ImageArchError{Image: "praktor-agent:latest", ImageArch: "amd64", HostArch: "arm64"})
+	if got := startFailureNotice("coder", err); !strings.Contains(got, "built for amd64 but this host is arm64") {
+		t.Errorf("notice = %q", got)
+	}
+	if got := startFailureNotice("coder", fmt.Errorf("start agent: %w", container.ErrMaxContainers)); got != "" {
+		t.Errorf("notice for a transient error = %q, want none", got)
+	}
+}
+
+func TestUserQuota(t *testing.T) {
+	s, err := store.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	b := &Bot{store: s, quota: config.UserQuotaConfig{Hourly: 2, Daily: 3, Admins: []int64{1}}}
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	for i, want := range []bool{true, true, false} {
+		if _, ok, err := b.countMessage(2, now); err != nil || ok != want {
+			t.Errorf("user message %d: allowed = %v (err %v), want %v", i+1, ok, err, want)
+		}
+	}
+	u, _, _ := b.countMessage(2, now)
+	if got := b.quotaRefusal(u); !strings.Contains(got, "2 messages per hour") {
+		t.Errorf("refusal = %q", got)
+	}
+
+	// An hour later the hourly count has rolled over but the daily one hasn't.
+	if _, ok, _ := b.countMessage(2, now.Add(time.Hour)); !ok {
+		t.Error("expected a message in the next hour to be allowed")
+	}
+	u, ok, _ := b.countMessage(2, now.Add(time.Hour))
+	if ok || !strings.Contains(b.quotaRefusal(u), "3 messages per day") {
+		t.Errorf("expected the daily limit to refuse, got allowed = %v, %q", ok, b.quotaRefusal(u))
+	}
+
+	// Admins are counted but never refused.
+	for range 5 {
+		if _, ok, _ := b.countMessage(1, now); !ok {
+			t.Fatal("admin message refused")
+		}
+	}
+	if u, _ := s.GetUserUsage(1, now); u.Hour != 5 {
+		t.Errorf("admin usage = %+v, want 5 this hour", u)
+	}
+}
+
+func TestReplyOrder(t *testing.T) {
+	const chat = 42
+	var mu sync.Mutex
+	var sent []string
+	sender := func(text string) func() {
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, text)
+		}
+	}
+	got := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(sent)
+	}
+	tagged := func(r *replyOrder, n int) []map[string]string {
+		metas := make([]map[string]string, n)
+		for i := range metas {
+			metas[i] = map[string]string{"chat_id": "42"}
+			r.tag(chat, metas[i])
+		}
+		return metas
+	}
+
+	t.Run("off", func(t *testing.T) {
+		sent = nil
+		r := newReplyOrder(config.ReplyOrderConfig{Timeout: time.Minute})
+		metas := tagged(r, 2)
+		r.deliver(chat, metas[1], sender("second"))
+		r.deliver(chat, metas[0], sender("first"))
+		if want := []string{"second", "first"}; !slices.Equal(got(), want) {
+			t.Errorf("sent %q, want %q", got(), want)
+		}
+	})
+
+	t.Run("out of order completions", func(t *testing.T) {
+		sent = nil
+		r := newReplyOrder(config.ReplyOrderConfig{Enabled: true, Timeout: time.Minute})
+		metas := tagged(r, 4)
+		r.deliver(chat, metas[2], sender("third"))
+		r.deliver(chat, metas[1], sender("second"))
+		r.deliver(7, map[string]string{}, sender("other chat"))
+		if want := []string{"other chat"}; !slices.Equal(got(), want) {
+			t.Fatalf("sent %q before the first reply, want %q", got(), want)
+		}
+		r.deliver(chat, metas[0], sender("first"))
+		r.complete(chat, metas[0]) // its run ending afterwards changes nothing
+		// The fourth message's run fails; nothing waits on it afterwards.
+		r.complete(chat, metas[3])
+		r.deliver(chat, metas[3], sender("late fourth"))
+		want := []string{"other chat", "first", "second", "third", "late fourth"}
+		if !slices.Equal(got(), want) {
+			t.Errorf("sent %q, want %q", got(), want)
+		}
+	})
+
+	t.Run("unordered chat", func(t *testing.T) {
+		sent = nil
+		r := newReplyOrder(config.ReplyOrderConfig{Enabled: true, Timeout: time.Minute, Chats: []int64{7}})
+		metas := tagged(r, 2)
+		r.deliver(chat, metas[1], sender("second"))
+		if want := []string{"second"}; !slices.Equal(got(), want) {
+			t.Errorf("sent %q, want %q", got(), want)
+		}
+	})
+
+	t.Run("stuck reply times out", func(t *testing.T) {
+		sent = nil
+		r := newReplyOrder(config.ReplyOrderConfig{Enabled: true, Timeout: 20 * time.Millisecond})
+		metas := tagged(r, 3)
+		r.deliver(chat, metas[2], sender("third"))
+		r.deliver(chat, metas[1], sender("second"))
+		deadline := time.Now().Add(2 * time.Second)
+		for len(got()) < 2 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		r.deliver(chat, metas[0], sender("first"))
+		if want := []string{"second", "third", "first"}; !slices.Equal(got(), want) {
+			t.Errorf("sent %q, want %q", got(), want)
+		}
+	})
+	t.Run("slow send holds no lock", func(t *testing.T) {
+		sent = nil
+		r := newReplyOrder(config.ReplyOrderConfig{Enabled: true, Timeout: time.Minute})
+		metas := tagged(r, 2)
+		sending, release := make(chan struct{}), make(chan struct{})
+		go r.deliver(chat, metas[0], func() {
+			close(sending)
+			<-release
+			sender("first")()
+		})
+		<-sending
+
+		// Tagging and delivering proceed while the first send is stuck;
+		// the second reply still goes out after it.
+		done := make(chan struct{})
+		go func() {
+			tagged(r, 1)
+			r.deliver(chat, metas[1], sender("second"))
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("tag/deliver blocked behind a send in progress")
+		}
+		if len(got()) != 0 {
+			t.Errorf("sent %q before the first send finished", got())
+		}
+		close(release)
+		deadline := time.Now().Add(2 * time.Second)
+		for len(got()) < 2 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if want := []string{"first", "second"}; !slices.Equal(got(), want) {
+			t.Errorf("sent %q, want %q", got(), want)
+		}
+	})
+}
+
+func TestSwarmGraphMessage(t *testing.T) {
+	ag := []swarm.SwarmAgent{{AgentID: "a", Role: "a"}, {AgentID: "b", Role: "b"}}
+	tests := []struct {
+		synapses []swarm.Synapse
+		lead     string
+		want     string
+	}{
+		{[]swarm.Synapse{{From: "a", To: "b"}, {From: "b", To: "a"}}, "", "loops back on itself (a>b>a)"},
+		{[]swarm.Synapse{{From: "a", To: "x"}}, "", "x in a>x is not one of the swarm's agents"},
+		{nil, "x", "lead agent x is not in the swarm"},
+	}
+	for _, tt := range tests {
+		_, err := swarm.BuildPlan(ag, tt.synapses, tt.lead)
+		if got := swarmGraphMessage(err); !strings.Contains(got, tt.want) {
+			t.Errorf("swarmGraphMessage(%v) = %q, want it to contain %q", err, got, tt.want)
+		}
+	}
+}
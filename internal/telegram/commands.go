@@ -0,0 +1,147 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mtzanidakis/praktor/internal/agent"
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+// maxMenuCommands is Telegram's limit for SetMyCommands.
+const maxMenuCommands = 100
+
+// builtinCommands are the bot's own commands, listed first in the menu.
+var builtinCommands = []telego.BotCommand{
+	{Command: "agents", Description: "List available agents"},
+	{Command: "commands", Description: "Show available commands"},
+	{Command: "start", Description: "Say hello to an agent"},
+	{Command: "stop", Description: "Abort the active agent run"},
+	{Command: "reset", Description: "Reset conversation session"},
+	{Command: "restart", Description: "Restart the agent container"},
+	{Command: "export", Description: "Send the conversation transcript as a file"},
+	{Command: "again", Description: "Resend the agent's last reply"},
+	{Command: "nix", Description: "Manage nix packages in agent container"},
+	{Command: "usage", Description: "Show your message usage and quota"},
+}
+
+// syncCommands reloads the agent-registered commands and publishes the
+// menu. Called at startup and whenever an agent registers commands.
+func (b *Bot) syncCommands(ctx context.Context) {
+	menu := slices.Concat(builtinCommands, b.loadAgentCommands())
+	if len(menu) > maxMenuCommands {
+		slog.Warn("too many telegram commands, menu truncated", "bot", b.cfg.Name, "commands", len(menu))
+		menu = menu[:maxMenuCommands]
+	}
+	if err := b.bot.SetMyCommands(ctx, &telego.SetMyCommandsParams{Commands: menu}); err != nil {
+		slog.Warn("failed to set telegram commands", "bot", b.cfg.Name, "error", err)
+	}
+}
+
+// loadAgentCommands reads the commands of the agents this bot serves into
+// the dispatch table and returns their menu entries.
+func (b *Bot) loadAgentCommands() []telego.BotCommand {
+	cmds, err := b.store.ListAgentCommands()
+	if err != nil {
+		slog.Error("failed to load agent commands", "error", err)
+		return nil
+	}
+	table := make(map[string]store.AgentCommand, len(cmds))
+	var menu []telego.BotCommand
+	for _, c := range cmds {
+		if !b.allowsAgent(c.AgentID) {
+			continue
+		}
+		name := agent.CommandName(c.AgentID, c.Command)
+		table[name] = c
+		menu = append(menu, telego.BotCommand{Command: name, Description: c.Description})
+	}
+
+	b.agentCmdMu.Lock()
+	b.agentCmds = table
+	b.agentCmdMu.Unlock()
+	return menu
+}
+
+// matchAgentCommand resolves a "/agent_command args" message to the
+// registered command and the prompt it expands to.
+func (b *Bot) matchAgentCommand(text string) (store.AgentCommand, string, bool) {
+	name, _, args := tu.ParseCommandPayload(text)
+	b.agentCmdMu.RLock()
+	c, ok := b.agentCmds[name]
+	b.agentCmdMu.RUnlock()
+	if !ok {
+		return store.AgentCommand{}, "", false
+	}
+	return c, agent.ExpandCommandPrompt(c.PromptTemplate, args), true
+}
+
+// runAgentCommand handles a message that is an agent-registered command,
+// reporting false for anything else so it is treated as a regular message.
+func (b *Bot) runAgentCommand(ctx context.Context, msg telego.Message) bool {
+	c, prompt, ok := b.matchAgentCommand(msg.Text)
+	if !ok {
+		return false
+	}
+	if !b.allowedUser(msg) || !b.admitUser(ctx, msg) {
+		return true
+	}
+	chatID := msg.Chat.ID
+	if !b.checkAgent(ctx, chatID, c.AgentID) {
+		return true
+	}
+
+	b.setChatAgent(chatID, c.AgentID)
+	_ = b.sendChatAction(ctx, chatID)
+
+	senderID := strconv.FormatInt(msg.From.ID, 10)
+	meta := map[string]string{
+		"sender":       fmt.Sprintf("user:%s", senderID),
+		"user_id":      senderID,
+		"chat_id":      strconv.FormatInt(chatID, 10),
+		"telegram_bot": b.cfg.Name,
+		"command":      c.Command,
+	}
+	b.order.tag(chatID, meta)
+
+	if err := b.orch.HandleMessage(ctx, c.AgentID, prompt, meta); err != nil {
+		b.order.complete(chatID, meta)
+		if errors.Is(err, agent.ErrRateLimited) {
+			_ = b.SendMessage(ctx, chatID, fmt.Sprintf("Slow down — %s is receiving too many messages. Try again in a moment.", c.AgentID))
+			return true
+		}
+		if errors.Is(err, agent.ErrMessageTooLarge) {
+			_ = b.SendMessage(ctx, chatID, "That message is too long. Try sending it as a file instead.")
+			return true
+		}
+		if errors.Is(err, agent.ErrSafeMode) {
+			_ = b.SendMessage(ctx, chatID, safeModeNotice)
+			return true
+		}
+		slog.Error("agent command failed", "agent", c.AgentID, "command", c.Command, "error", err)
+		_ = b.SendMessage(ctx, chatID, "Sorry, I encountered an error processing your message.")
+	}
+	return true
+}
+
+// agentCommandsHelp lists the agent-registered commands for /commands.
+func (b *Bot) agentCommandsHelp() string {
+	b.agentCmdMu.RLock()
+	defer b.agentCmdMu.RUnlock()
+	if len(b.agentCmds) == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(b.agentCmds))
+	for name, c := range b.agentCmds {
+		lines = append(lines, fmt.Sprintf("  /%s — %s", strings.ReplaceAll(name, "_", "\\_"), c.Description))
+	}
+	slices.Sort(lines)
+	return "\n*Agent commands*\n\n" + strings.Join(lines, "\n") + "\n"
+}
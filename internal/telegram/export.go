@@ -0,0 +1,98 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+const (
+	// maxTranscriptBytes caps an /export document; older messages beyond it
+	// are left out. Well under Telegram's 50 MB bot upload limit.
+	maxTranscriptBytes = 5 << 20
+	transcriptPageSize = 200
+)
+
+func (b *Bot) cmdExport(ctx context.Context, chatID int64, payload string) {
+	agentID := b.resolveAgent(chatID, payload)
+	if agentID == "" {
+		_ = b.SendMessage(ctx, chatID, "Usage: /export [agent]")
+		return
+	}
+	if !b.checkAgent(ctx, chatID, agentID) {
+		return
+	}
+
+	data, count, err := buildTranscript(b.store, agentID, time.Now(), maxTranscriptBytes)
+	if err != nil {
+		slog.Error("failed to build transcript", "agent", agentID, "error", err)
+		_ = b.SendMessage(ctx, chatID, fmt.Sprintf("Failed to export *%s*.", agentID))
+		return
+	}
+	if count == 0 {
+		_ = b.SendMessage(ctx, chatID, fmt.Sprintf("No messages for *%s* yet.", agentID))
+		return
+	}
+
+	name := fmt.Sprintf("%s-transcript-%s.md", agentID, time.Now().UTC().Format("20060102-150405"))
+	caption := fmt.Sprintf("Transcript of %s (%d messages)", agentID, count)
+	if err := b.SendDocument(ctx, chatID, data, name, caption); err != nil {
+		slog.Error("failed to send transcript", "chat", chatID, "agent", agentID, "error", err)
+		_ = b.SendMessage(ctx, chatID, "Failed to send the transcript.")
+	}
+}
+
+// buildTranscript renders an agent's conversation as markdown, oldest first.
+// Messages are read newest-first a page at a time, so only the part that fits
+// in maxBytes is ever held; when the cap is hit the oldest messages are left
+// out and the header says so. It returns the document and the number of
+// messages in it.
+func buildTranscript(s *store.Store, agentID string, now time.Time, maxBytes int) ([]byte, int, error) {
+	var blocks []string
+	size := 0
+	truncated := false
+	var before int64
+
+pages:
+	for {
+		page, err := s.GetMessagesBefore(agentID, before, transcriptPageSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, m := range slices.Backward(page) {
+			block := fmt.Sprintf("**%s** · %s\n\n%s\n\n---\n\n", m.Sender, m.CreatedAt.UTC().Format(time.DateTime), m.Content)
+			if size+len(block) > maxBytes {
+				truncated = true
+				break pages
+			}
+			size += len(block)
+			blocks = append(blocks, block)
+		}
+		if len(page) < transcriptPageSize {
+			break
+		}
+		before = page[0].ID
+	}
+	if len(blocks) == 0 {
+		return nil, 0, nil
+	}
+	slices.Reverse(blocks)
+
+	var sb strings.Builder
+	sb.Grow(size + 256)
+	fmt.Fprintf(&sb, "# Conversation with %s\n\n", agentID)
+	fmt.Fprintf(&sb, "_Exported %s UTC, %d messages", now.UTC().Format(time.DateTime), len(blocks))
+	if truncated {
+		sb.WriteString("; older messages omitted to stay under the size limit")
+	}
+	sb.WriteString("._\n\n")
+	for _, block := range blocks {
+		sb.WriteString(block)
+	}
+	return []byte(sb.String()), len(blocks), nil
+}
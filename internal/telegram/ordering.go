@@ -0,0 +1,201 @@
+package telegram
+
+import (
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+// replySeqKey is the message meta key holding a message's place in its
+// chat's reply order.
+const replySeqKey = "reply_seq"
+
+// replyOrder releases each chat's replies in the order of the messages they
+// answer (see config.ReplyOrderConfig). A nil replyOrder sends every reply
+// immediately.
+type replyOrder struct {
+	timeout time.Duration
+	only    map[int64]bool // chats to order; nil = all
+
+	mu    sync.Mutex
+	chats map[int64]*chatOrder
+}
+
+// chatOrder is one chat's position in its reply order. A message is done
+// once its first reply is delivered or its run ends without one.
+type chatOrder struct {
+	mu      sync.Mutex
+	next    uint64              // seq for the next message
+	head    uint64              // lowest seq not yet done
+	held    map[uint64][]func() // seq → deliveries waiting for head
+	done    map[uint64]bool     // seqs after head that are done
+	out     []func()            // released deliveries not yet sent, in order
+	sending bool                // a goroutine is sending out; see flush
+	timer   *time.Timer
+	gen     uint64 // invalidates a timer that fired while being stopped
+}
+
+func newReplyOrder(cfg config.ReplyOrderConfig) *replyOrder {
+	if !cfg.Enabled {
+		return nil
+	}
+	r := &replyOrder{timeout: cfg.Timeout, chats: make(map[int64]*chatOrder)}
+	if len(cfg.Chats) > 0 {
+		r.only = make(map[int64]bool, len(cfg.Chats))
+		for _, id := range cfg.Chats {
+			r.only[id] = true
+		}
+	}
+	return r
+}
+
+// chat returns chatID's order, or nil if the chat is not ordered.
+func (r *replyOrder) chat(chatID int64) *chatOrder {
+	if r == nil || (r.only != nil && !r.only[chatID]) {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.chats[chatID]
+	if !ok {
+		c = &chatOrder{held: make(map[uint64][]func()), done: make(map[uint64]bool)}
+		r.chats[chatID] = c
+	}
+	return c
+}
+
+// tag gives a message sent to chatID the next place in the chat's order,
+// recording it in meta so its reply can be matched to it.
+func (r *replyOrder) tag(chatID int64, meta map[string]string) {
+	c := r.chat(chatID)
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta[replySeqKey] = strconv.FormatUint(c.next, 10)
+	c.next++
+}
+
+// deliver sends a reply to chatID once every earlier message in the chat
+// is done, holding it until then. Replies to untagged messages, and to
+// messages whose turn has passed, are sent immediately.
+func (r *replyOrder) deliver(chatID int64, meta map[string]string, send func()) {
+	c := r.chat(chatID)
+	seq, ok := replySeq(meta)
+	if c == nil || !ok {
+		send()
+		return
+	}
+	c.mu.Lock()
+	switch {
+	case seq < c.head:
+		c.out = append(c.out, send)
+	case seq == c.head:
+		c.out = append(c.out, send)
+		c.advance(r.timeout)
+	default:
+		c.held[seq] = append(c.held[seq], send)
+		c.done[seq] = true
+		if c.timer == nil {
+			c.arm(r.timeout)
+		}
+	}
+	c.mu.Unlock()
+	c.flush()
+}
+
+// complete marks a message to chatID done without a reply, e.g. because
+// its run failed, releasing the replies waiting on it.
+func (r *replyOrder) complete(chatID int64, meta map[string]string) {
+	c := r.chat(chatID)
+	seq, ok := replySeq(meta)
+	if c == nil || !ok {
+		return
+	}
+	c.mu.Lock()
+	switch {
+	case seq == c.head:
+		c.advance(r.timeout)
+	case seq > c.head:
+		c.done[seq] = true
+	}
+	c.mu.Unlock()
+	c.flush()
+}
+
+// advance moves past the head message, releasing the held replies of each
+// following message that is done to c.out. The caller holds c.mu and
+// flushes after unlocking it.
+func (c *chatOrder) advance(timeout time.Duration) {
+	c.head++
+	for c.done[c.head] {
+		c.out = append(c.out, c.held[c.head]...)
+		delete(c.held, c.head)
+		delete(c.done, c.head)
+		c.head++
+	}
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+		c.gen++
+	}
+	if len(c.held) > 0 {
+		c.arm(timeout)
+	}
+}
+
+// arm starts the timeout after which the head message stops holding up the
+// replies behind it. The caller holds c.mu.
+func (c *chatOrder) arm(timeout time.Duration) {
+	c.gen++
+	gen := c.gen
+	c.timer = time.AfterFunc(timeout, func() {
+		c.mu.Lock()
+		if c.gen != gen {
+			c.mu.Unlock()
+			return
+		}
+		slog.Warn("reply order timed out, releasing later replies", "seq", c.head, "held", len(c.held))
+		c.timer = nil
+		c.advance(timeout)
+		c.mu.Unlock()
+		c.flush()
+	})
+}
+
+// flush sends the released deliveries in c.out, in order, without holding
+// c.mu, so a slow Telegram send doesn't hold up the chat's other messages.
+// One goroutine sends at a time; a flush while another is sending leaves
+// its deliveries to that one.
+func (c *chatOrder) flush() {
+	c.mu.Lock()
+	if c.sending {
+		c.mu.Unlock()
+		return
+	}
+	c.sending = true
+	for len(c.out) > 0 {
+		batch := c.out
+		c.out = nil
+		c.mu.Unlock()
+		for _, send := range batch {
+			send()
+		}
+		c.mu.Lock()
+	}
+	c.sending = false
+	c.mu.Unlock()
+}
+
+func replySeq(meta map[string]string) (uint64, bool) {
+	s, ok := meta[replySeqKey]
+	if !ok {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(s, 10, 64)
+	return seq, err == nil
+}
@@ -0,0 +1,86 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mymmrac/telego"
+)
+
+// countMessage counts a message from userID towards telegram.quota. Admins
+// are counted but never refused.
+func (b *Bot) countMessage(userID int64, now time.Time) (store.UserUsage, bool, error) {
+	if b.quota.IsAdmin(userID) {
+		return b.store.CountUserMessage(userID, now, 0, 0)
+	}
+	return b.store.CountUserMessage(userID, now, b.quota.Hourly, b.quota.Daily)
+}
+
+// admitUser counts the message before it is routed, replying with a polite
+// refusal and reporting false when the sender is over quota. If usage can't
+// be counted the message goes through.
+func (b *Bot) admitUser(ctx context.Context, msg telego.Message) bool {
+	u, ok, err := b.countMessage(msg.From.ID, time.Now())
+	if err != nil {
+		slog.Error("failed to count user message", "user_id", msg.From.ID, "error", err)
+		return true
+	}
+	if !ok {
+		slog.Info("telegram user over quota", "user_id", msg.From.ID, "hour", u.Hour, "day", u.Day)
+		_ = b.SendMessage(ctx, msg.Chat.ID, b.quotaRefusal(u))
+	}
+	return ok
+}
+
+func (b *Bot) quotaRefusal(u store.UserUsage) string {
+	if b.quota.Hourly > 0 && u.Hour >= b.quota.Hourly {
+		return fmt.Sprintf("You've reached your limit of %d messages per hour. Please try again later.", b.quota.Hourly)
+	}
+	return fmt.Sprintf("You've reached your limit of %d messages per day. Please try again later.", b.quota.Daily)
+}
+
+func quotaLimit(n int) string {
+	if n == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprint(n)
+}
+
+// cmdUsage shows the sender's message counts against their quota; admins
+// also get every user's usage for the last 24 hours.
+func (b *Bot) cmdUsage(ctx context.Context, msg telego.Message) {
+	now := time.Now()
+	u, err := b.store.GetUserUsage(msg.From.ID, now)
+	if err != nil {
+		_ = b.SendMessage(ctx, msg.Chat.ID, "Failed to get usage.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("*Usage*\n\n")
+	admin := b.quota.IsAdmin(msg.From.ID)
+	if admin {
+		fmt.Fprintf(&sb, "This hour: %d messages\nLast 24h: %d messages\nYou are exempt from quotas.\n", u.Hour, u.Day)
+	} else {
+		fmt.Fprintf(&sb, "This hour: %d / %s messages\nLast 24h: %d / %s messages\n",
+			u.Hour, quotaLimit(b.quota.Hourly), u.Day, quotaLimit(b.quota.Daily))
+	}
+
+	if admin {
+		all, err := b.store.ListUserUsage(now)
+		if err != nil {
+			slog.Error("failed to list user usage", "error", err)
+		}
+		if len(all) > 0 {
+			sb.WriteString("\n*All users, last 24h*\n\n")
+			for _, o := range all {
+				fmt.Fprintf(&sb, "  %d — %d (%d this hour)\n", o.UserID, o.Day, o.Hour)
+			}
+		}
+	}
+	_ = b.SendMessage(ctx, msg.Chat.ID, sb.String())
+}
@@ -0,0 +1,209 @@
+package telegram
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Parse modes selectable via telegram.parse_mode.
+const (
+	ParseModeMarkdown = "markdown"
+	ParseModeHTML     = "html"
+)
+
+var (
+	reCodeLang = regexp.MustCompile(`^[A-Za-z0-9_+#.-]+$`)
+	reHTMLTag  = regexp.MustCompile(`<[^>]+>`)
+)
+
+// escapeHTML escapes the characters Telegram's HTML parse mode requires
+// (<, > and &). Existing Markdown backslash escapes are dropped first, just
+// like escapeMarkdownV2 does.
+func escapeHTML(text string) string {
+	text = stripMarkdownEscapes(text)
+	return escapeHTMLRaw(text)
+}
+
+// escapeHTMLRaw escapes <, > and & without touching backslashes. Used for
+// code, where backslashes are literal.
+func escapeHTMLRaw(text string) string {
+	text = strings.ReplaceAll(text, "&", "&amp;")
+	text = strings.ReplaceAll(text, "<", "&lt;")
+	text = strings.ReplaceAll(text, ">", "&gt;")
+	return text
+}
+
+// escapeHTMLAttr escapes a value used inside a double-quoted HTML attribute.
+func escapeHTMLAttr(text string) string {
+	return strings.ReplaceAll(escapeHTMLRaw(text), `"`, "&quot;")
+}
+
+// toTelegramHTML converts standard Markdown to Telegram HTML. It handles the
+// same constructs as toTelegramMarkdown (bold, italic, headers, horizontal
+// rules, bullet lists, links, image embeds, inline code, code blocks and
+// tables), but HTML only needs <, > and & escaped, so identifiers with
+// underscores or brackets no longer break parsing.
+func toTelegramHTML(text string) string {
+	text = convertMarkdownTables(text)
+
+	parts := strings.Split(text, "```")
+	// An unterminated code block is rendered as regular text.
+	if len(parts)%2 == 0 {
+		parts[len(parts)-2] += "```" + parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+	for i := 0; i < len(parts); i++ {
+		if i%2 == 1 {
+			parts[i] = htmlCodeBlock(parts[i])
+			continue
+		}
+		parts[i] = convertHTMLSegment(parts[i])
+	}
+	return strings.Join(parts, "")
+}
+
+// htmlCodeBlock renders the inside of a ``` block as <pre>, honoring an
+// optional language tag on the opening line.
+func htmlCodeBlock(code string) string {
+	lang := ""
+	if first, rest, ok := strings.Cut(code, "\n"); ok && reCodeLang.MatchString(first) {
+		lang, code = first, rest
+	}
+	code = strings.TrimPrefix(code, "\n")
+	code = strings.TrimSuffix(code, "\n")
+	if lang != "" {
+		return `<pre><code class="language-` + escapeHTMLAttr(lang) + `">` + escapeHTMLRaw(code) + "</code></pre>"
+	}
+	return "<pre>" + escapeHTMLRaw(code) + "</pre>"
+}
+
+// convertHTMLSegment applies Markdown-to-HTML conversions on a segment that
+// is known to be outside code blocks.
+func convertHTMLSegment(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if m := reHeader.FindStringSubmatch(trimmed); m != nil {
+			lines[i] = "<b>" + escapeInlineHTML(m[1]) + "</b>"
+			continue
+		}
+
+		if reHR.MatchString(trimmed) {
+			lines[i] = ""
+			continue
+		}
+
+		if loc := reBullet.FindStringSubmatchIndex(line); loc != nil {
+			indent := line[loc[2]:loc[3]]
+			rest := line[loc[1]:]
+			lines[i] = indent + "• " + escapeInlineHTML(rest)
+			continue
+		}
+
+		lines[i] = escapeInlineHTML(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeInlineHTML escapes a line for Telegram HTML while converting inline
+// formatting: **bold**, *bold*, _italic_, [text](url), ![alt](url), `code`.
+func escapeInlineHTML(line string) string {
+	var spans []span
+
+	for _, m := range reImageEmb.FindAllStringSubmatchIndex(line, -1) {
+		alt := line[m[2]:m[3]]
+		url := line[m[4]:m[5]]
+		if alt == "" {
+			alt = url
+		}
+		spans = append(spans, span{m[0], m[1], `<a href="` + escapeHTMLAttr(url) + `">` + escapeHTML(alt) + "</a>"})
+	}
+
+	for _, m := range reLink.FindAllStringSubmatchIndex(line, -1) {
+		if m[0] > 0 && line[m[0]-1] == '!' {
+			continue
+		}
+		if overlaps(spans, m[0], m[1]) {
+			continue
+		}
+		text := line[m[2]:m[3]]
+		url := line[m[4]:m[5]]
+		spans = append(spans, span{m[0], m[1], `<a href="` + escapeHTMLAttr(url) + `">` + escapeHTML(text) + "</a>"})
+	}
+
+	// Inline code before emphasis so `a_b_c` and `**x**` stay literal.
+	for _, m := range reInline.FindAllStringIndex(line, -1) {
+		if overlaps(spans, m[0], m[1]) {
+			continue
+		}
+		code := line[m[0]+1 : m[1]-1]
+		spans = append(spans, span{m[0], m[1], "<code>" + escapeHTMLRaw(code) + "</code>"})
+	}
+
+	for _, m := range reBold.FindAllStringSubmatchIndex(line, -1) {
+		if overlaps(spans, m[0], m[1]) {
+			continue
+		}
+		spans = append(spans, span{m[0], m[1], "<b>" + escapeHTML(line[m[2]:m[3]]) + "</b>"})
+	}
+
+	for _, m := range reBoldSingle.FindAllStringSubmatchIndex(line, -1) {
+		start := strings.Index(line[m[0]:m[1]], "*") + m[0]
+		end := strings.LastIndex(line[m[0]:m[1]], "*") + m[0] + 1
+		if overlaps(spans, start, end) {
+			continue
+		}
+		spans = append(spans, span{start, end, "<b>" + escapeHTML(line[m[2]:m[3]]) + "</b>"})
+	}
+
+	for _, m := range reItalic.FindAllStringSubmatchIndex(line, -1) {
+		start := strings.Index(line[m[0]:m[1]], "_") + m[0]
+		end := m[1]
+		if overlaps(spans, start, end) {
+			continue
+		}
+		inner := line[m[2]:m[3]]
+		// snake_case identifiers are not italics.
+		if isWordChar(line, start-1) || isWordChar(line, end) {
+			continue
+		}
+		spans = append(spans, span{start, end, "<i>" + escapeHTML(inner) + "</i>"})
+	}
+
+	sortSpans(spans)
+
+	var sb strings.Builder
+	pos := 0
+	for _, s := range spans {
+		if s.start > pos {
+			sb.WriteString(escapeHTML(line[pos:s.start]))
+		}
+		sb.WriteString(s.replacement)
+		pos = s.end
+	}
+	if pos < len(line) {
+		sb.WriteString(escapeHTML(line[pos:]))
+	}
+	return sb.String()
+}
+
+// isWordChar reports whether line[i] is an ASCII letter or digit.
+func isWordChar(line string, i int) bool {
+	if i < 0 || i >= len(line) {
+		return false
+	}
+	c := line[i]
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// htmlToPlain strips tags and unescapes entities produced by toTelegramHTML,
+// for the plain-text fallback when Telegram rejects the HTML.
+func htmlToPlain(text string) string {
+	text = reHTMLTag.ReplaceAllString(text, "")
+	text = strings.ReplaceAll(text, "&lt;", "<")
+	text = strings.ReplaceAll(text, "&gt;", ">")
+	text = strings.ReplaceAll(text, "&quot;", `"`)
+	text = strings.ReplaceAll(text, "&amp;", "&")
+	return text
+}
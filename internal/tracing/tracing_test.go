@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtract(t *testing.T) {
+	if _, err := Setup(context.Background(), config.TracingConfig{}); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	payload := map[string]string{"text": "hi"}
+	Inject(ctx, payload)
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if payload["traceparent"] != want {
+		t.Fatalf("traceparent = %q, want %q", payload["traceparent"], want)
+	}
+
+	got := TraceID(Extract(context.Background(), payload))
+	if got != traceID.String() {
+		t.Errorf("extracted trace id = %q, want %q", got, traceID.String())
+	}
+}
+
+func TestTraceID_NoSpan(t *testing.T) {
+	if id := TraceID(context.Background()); id != "" {
+		t.Errorf("expected empty trace id, got %q", id)
+	}
+}
@@ -5,11 +5,16 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 
 	"golang.org/x/crypto/argon2"
 )
 
+// ErrVaultLocked is returned by a nil Vault, i.e. when no passphrase was
+// configured to unlock it.
+var ErrVaultLocked = errors.New("vault locked")
+
 // Vault provides AES-256-GCM encryption/decryption with a passphrase-derived key.
 type Vault struct {
 	key [32]byte
@@ -29,6 +34,9 @@ func New(passphrase string) *Vault {
 
 // Encrypt encrypts plaintext using AES-256-GCM with a random nonce.
 func (v *Vault) Encrypt(plaintext []byte) (ciphertext, nonce []byte, err error) {
+	if v == nil {
+		return nil, nil, ErrVaultLocked
+	}
 	block, err := aes.NewCipher(v.key[:])
 	if err != nil {
 		return nil, nil, fmt.Errorf("create cipher: %w", err)
@@ -50,6 +58,9 @@ func (v *Vault) Encrypt(plaintext []byte) (ciphertext, nonce []byte, err error)
 
 // Decrypt decrypts ciphertext using AES-256-GCM with the provided nonce.
 func (v *Vault) Decrypt(ciphertext, nonce []byte) ([]byte, error) {
+	if v == nil {
+		return nil, ErrVaultLocked
+	}
 	block, err := aes.NewCipher(v.key[:])
 	if err != nil {
 		return nil, fmt.Errorf("create cipher: %w", err)
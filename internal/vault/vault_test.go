@@ -2,6 +2,7 @@ package vault
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -65,3 +66,13 @@ func TestEmptyPlaintext(t *testing.T) {
 		t.Fatalf("expected empty, got %d bytes", len(decrypted))
 	}
 }
+
+func TestNilVaultLocked(t *testing.T) {
+	var v *Vault
+	if _, _, err := v.Encrypt([]byte("x")); !errors.Is(err, ErrVaultLocked) {
+		t.Errorf("Encrypt on nil vault: got %v, want ErrVaultLocked", err)
+	}
+	if _, err := v.Decrypt([]byte("x"), make([]byte, 12)); !errors.Is(err, ErrVaultLocked) {
+		t.Errorf("Decrypt on nil vault: got %v, want ErrVaultLocked", err)
+	}
+}
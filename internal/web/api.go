@@ -1,13 +1,22 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mtzanidakis/praktor/internal/registry"
 	"github.com/mtzanidakis/praktor/internal/schedule"
+	"github.com/mtzanidakis/praktor/internal/scheduler"
 	"github.com/mtzanidakis/praktor/internal/store"
 	"github.com/mtzanidakis/praktor/internal/swarm"
 )
@@ -29,6 +38,7 @@ func (s *Server) registerAPI(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/agents/definitions", s.listAgentDefinitions)
 	mux.HandleFunc("GET /api/agents/definitions/{id}", s.getAgentDefinition)
 	mux.HandleFunc("GET /api/agents/definitions/{id}/messages", s.getAgentMessages)
+	mux.HandleFunc("POST /api/agents/definitions/{id}/messages", s.sendAgentMessage)
 	mux.HandleFunc("GET /api/agents/definitions/{id}/messages/search", s.searchAgentMessages)
 	mux.HandleFunc("GET /api/agents/definitions/{id}/agent-md", s.getAgentMD)
 	mux.HandleFunc("PUT /api/agents/definitions/{id}/agent-md", s.updateAgentMD)
@@ -38,21 +48,36 @@ func (s *Server) registerAPI(mux *http.ServeMux) {
 	// Agent lifecycle
 	mux.HandleFunc("POST /api/agents/definitions/{id}/start", s.startAgent)
 	mux.HandleFunc("POST /api/agents/definitions/{id}/stop", s.stopAgent)
+	mux.HandleFunc("POST /api/agents/definitions/{id}/restart", s.restartAgent)
+	mux.HandleFunc("POST /api/agents/definitions/{id}/snapshot", s.snapshotAgent)
+	mux.HandleFunc("POST /api/agents/definitions/{id}/restore-snapshot", s.restoreAgentSnapshot)
+	mux.HandleFunc("POST /api/agents/definitions/{id}/ping", s.pingAgent)
+	mux.HandleFunc("POST /api/agents/definitions/{id}/replay", s.replayAgent)
+	mux.HandleFunc("GET /api/agents/definitions/{id}/artifacts", s.listAgentArtifacts)
+	mux.HandleFunc("GET /api/agents/definitions/{id}/artifacts/{name}", s.getAgentArtifact)
 
 	// Running agent containers
 	mux.HandleFunc("GET /api/agents", s.listRunningAgents)
 
+	// Agent groups (lifecycle only, unlike swarms)
+	mux.HandleFunc("GET /api/groups", s.listGroups)
+	mux.HandleFunc("POST /api/groups/{name}/start", s.startGroup)
+	mux.HandleFunc("POST /api/groups/{name}/stop", s.stopGroup)
+
 	// Tasks
 	mux.HandleFunc("GET /api/tasks", s.listTasks)
 	mux.HandleFunc("POST /api/tasks", s.createTask)
 	mux.HandleFunc("PUT /api/tasks/{id}", s.updateTask)
+	mux.HandleFunc("GET /api/tasks/{id}/runs", s.listTaskRuns)
 	mux.HandleFunc("DELETE /api/tasks/completed", s.deleteCompletedTasks)
+	mux.HandleFunc("POST /api/tasks/bulk", s.bulkTasks)
 	mux.HandleFunc("DELETE /api/tasks/{id}", s.deleteTask)
 
 	// Secrets
 	mux.HandleFunc("GET /api/secrets", s.listSecrets)
 	mux.HandleFunc("POST /api/secrets", s.createSecret)
 	mux.HandleFunc("GET /api/secrets/{id}", s.getSecret)
+	mux.HandleFunc("GET /api/secrets/{id}/usage", s.getSecretUsage)
 	mux.HandleFunc("PUT /api/secrets/{id}", s.updateSecret)
 	mux.HandleFunc("DELETE /api/secrets/{id}", s.deleteSecret)
 	mux.HandleFunc("GET /api/agents/definitions/{id}/secrets", s.getAgentSecrets)
@@ -63,21 +88,41 @@ func (s *Server) registerAPI(mux *http.ServeMux) {
 	// Swarms
 	mux.HandleFunc("GET /api/swarms", s.listSwarms)
 	mux.HandleFunc("POST /api/swarms", s.createSwarm)
+	mux.HandleFunc("POST /api/swarms/plan/prompts", s.previewSwarmPrompts)
 	mux.HandleFunc("GET /api/swarms/{id}", s.getSwarm)
+	mux.HandleFunc("DELETE /api/swarms/completed", s.deleteCompletedSwarms)
 	mux.HandleFunc("DELETE /api/swarms/{id}", s.deleteSwarm)
 
+	// Activity feed
+	mux.HandleFunc("GET /api/activity", s.listActivity)
+
 	// User profile
 	mux.HandleFunc("GET /api/user-profile", s.getUserProfile)
 	mux.HandleFunc("PUT /api/user-profile", s.updateUserProfile)
+	mux.HandleFunc("GET /api/global-instructions", s.getGlobalInstructions)
+	mux.HandleFunc("PUT /api/global-instructions", s.updateGlobalInstructions)
+
+	// Runtime settings
+	mux.HandleFunc("GET /api/settings", s.listSettings)
+	mux.HandleFunc("GET /api/settings/{key}", s.getSetting)
+	mux.HandleFunc("PUT /api/settings/{key}", s.updateSetting)
 
 	// System
 	mux.HandleFunc("GET /api/status", s.getStatus)
+	mux.HandleFunc("GET /api/status/db", s.getDBStatus)
+
+	// Admin
+	mux.HandleFunc("GET /api/admin/config", s.getLoadedConfig)
+	mux.HandleFunc("POST /api/admin/reload-config", s.reloadConfig)
+	mux.HandleFunc("POST /api/admin/config/preview", s.previewConfig)
+	mux.HandleFunc("POST /api/admin/agents/refresh-image", s.refreshImage)
+	mux.HandleFunc("GET /api/admin/logs", s.getLogs)
 }
 
 func (s *Server) listAgentDefinitions(w http.ResponseWriter, r *http.Request) {
 	agents, err := s.store.ListAgents()
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -90,13 +135,22 @@ func (s *Server) listAgentDefinitions(w http.ResponseWriter, r *http.Request) {
 
 	msgStats, _ := s.store.GetAgentMessageStats()
 
+	// ?tag=team:infra, repeatable; an agent must carry every tag given.
+	tags := r.URL.Query()["tag"]
+
 	out := make([]map[string]any, 0, len(agents))
 	for _, a := range agents {
+		if !a.HasTags(tags...) {
+			continue
+		}
 		agentStatus := "stopped"
 		if runningSet[a.ID] {
 			agentStatus = "running"
 		}
 
+		if a.Tags == nil {
+			a.Tags = []string{}
+		}
 		entry := map[string]any{
 			"id":            a.ID,
 			"name":          a.Name,
@@ -104,10 +158,15 @@ func (s *Server) listAgentDefinitions(w http.ResponseWriter, r *http.Request) {
 			"model":         s.registry.ResolveModel(a.ID),
 			"image":         s.registry.ResolveImage(a.ID),
 			"workspace":     a.Workspace,
+			"tags":          a.Tags,
 			"agent_status":  agentStatus,
 			"default_agent": a.ID == s.router.DefaultAgent(),
 		}
 
+		if n, ok := s.orch.WorkspaceUsage(a.ID); ok {
+			entry["workspace_bytes"] = n
+		}
+
 		if stats, ok := msgStats[a.ID]; ok {
 			entry["message_count"] = stats.MessageCount
 			entry["last_active"] = formatMessageTime(stats.LastActive)
@@ -124,7 +183,7 @@ func (s *Server) getAgentDefinition(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	a, err := s.store.GetAgent(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if a == nil {
@@ -138,11 +197,11 @@ func (s *Server) getAgentMessages(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	messages, err := s.store.GetMessages(id, 100)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	// Transform to frontend Message interface: {id, role, text, time, terminal_reason?}
+	// Transform to frontend Message interface: {id, role, text, time, terminal_reason?, reply_to?}
 	out := make([]map[string]string, 0, len(messages))
 	for _, m := range messages {
 		msg := map[string]string{
@@ -154,11 +213,88 @@ func (s *Server) getAgentMessages(w http.ResponseWriter, r *http.Request) {
 		if tr := extractTerminalReason(m.Metadata); tr != "" {
 			msg["terminal_reason"] = tr
 		}
+		if m.ReplyTo != nil {
+			msg["reply_to"] = fmt.Sprintf("%d", *m.ReplyTo)
+		}
 		out = append(out, msg)
 	}
 	jsonResponse(w, out)
 }
 
+// sendMessageRequest is the body of POST
+// /api/agents/definitions/{id}/messages, as JSON or as multipart form fields
+// (see readMessageForm).
+type sendMessageRequest struct {
+	Text          string            `json:"text"`
+	OverrideModel string            `json:"override_model"`
+	OverrideEnv   map[string]string `json:"override_env"`
+}
+
+// sendAgentMessage queues a message for an agent. override_model and
+// override_env only take effect if the message starts the container and the
+// keys are allowed by defaults.message_overrides.
+func (s *Server) sendAgentMessage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := s.registry.GetDefinition(id); !ok {
+		jsonError(w, "agent not found", http.StatusNotFound)
+		return
+	}
+	var body sendMessageRequest
+	var file *messageFile
+	if isMultipart(r) {
+		var err error
+		if body, file, err = s.readMessageForm(w, r); err != nil {
+			messageFormError(w, err)
+			return
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		jsonError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(body.Text) == "" && file == nil {
+		jsonError(w, "text is required", http.StatusBadRequest)
+		return
+	}
+
+	meta := map[string]string{"sender": "user", "source": "web"}
+	if body.OverrideModel != "" {
+		meta["override_model"] = body.OverrideModel
+	}
+	for k, v := range body.OverrideEnv {
+		meta["override_env."+k] = v
+	}
+
+	resp := map[string]string{"status": "queued"}
+	if file != nil {
+		a, err := s.store.GetAgent(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if a == nil {
+			jsonError(w, "agent not found", http.StatusNotFound)
+			return
+		}
+		containerPath, err := s.saveMessageFile(r.Context(), a, file)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		body.Text = strings.TrimSpace(fmt.Sprintf("%s\n\n[File received: %s (%s, %d bytes) saved to %s]",
+			body.Text, file.name, file.mimeType, len(file.data), containerPath))
+		meta["attachment_path"] = containerPath
+		resp["path"] = containerPath
+	}
+
+	// The message outlives the request, so don't tie it to its context.
+	ctx := context.WithoutCancel(r.Context())
+	if err := s.agents.HandleMessage(ctx, id, body.Text, meta); err != nil {
+		writeError(w, err)
+		return
+	}
+	jsonResponse(w, resp)
+}
+
 func (s *Server) searchAgentMessages(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	q := r.URL.Query().Get("q")
@@ -176,7 +312,7 @@ func (s *Server) searchAgentMessages(w http.ResponseWriter, r *http.Request) {
 
 	messages, err := s.store.SearchMessages(id, q, limit)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -191,6 +327,9 @@ func (s *Server) searchAgentMessages(w http.ResponseWriter, r *http.Request) {
 		if tr := extractTerminalReason(m.Metadata); tr != "" {
 			msg["terminal_reason"] = tr
 		}
+		if m.ReplyTo != nil {
+			msg["reply_to"] = fmt.Sprintf("%d", *m.ReplyTo)
+		}
 		out = append(out, msg)
 	}
 	jsonResponse(w, out)
@@ -199,7 +338,7 @@ func (s *Server) searchAgentMessages(w http.ResponseWriter, r *http.Request) {
 func (s *Server) listRunningAgents(w http.ResponseWriter, r *http.Request) {
 	agents, err := s.orch.ListRunning(r.Context())
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, agents)
@@ -209,15 +348,24 @@ func (s *Server) startAgent(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	a, err := s.store.GetAgent(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if a == nil {
 		jsonError(w, "agent not found", http.StatusNotFound)
 		return
 	}
+	if withGroup, _ := strconv.ParseBool(r.URL.Query().Get("with_group")); withGroup && a.Group != "" {
+		members, err := s.orch.StartGroup(r.Context(), a.Group)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		jsonResponse(w, map[string]any{"status": "started", "agents": members})
+		return
+	}
 	if err := s.orch.EnsureAgent(r.Context(), id); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, map[string]string{"status": "started"})
@@ -227,24 +375,188 @@ func (s *Server) stopAgent(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	a, err := s.store.GetAgent(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if a == nil {
 		jsonError(w, "agent not found", http.StatusNotFound)
 		return
 	}
+	if withGroup, _ := strconv.ParseBool(r.URL.Query().Get("with_group")); withGroup && a.Group != "" {
+		members, err := s.orch.StopGroup(r.Context(), a.Group)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		jsonResponse(w, map[string]any{"status": "stopped", "agents": members})
+		return
+	}
 	if err := s.orch.StopAgent(r.Context(), id); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, map[string]string{"status": "stopped"})
 }
 
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := s.store.ListAgentGroups()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	type group struct {
+		Name   string   `json:"name"`
+		Agents []string `json:"agents"`
+	}
+	out := make([]group, 0, len(groups))
+	for _, name := range slices.Sorted(maps.Keys(groups)) {
+		out = append(out, group{Name: name, Agents: groups[name]})
+	}
+	jsonResponse(w, out)
+}
+
+func (s *Server) startGroup(w http.ResponseWriter, r *http.Request) {
+	members, err := s.orch.StartGroup(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	jsonResponse(w, map[string]any{"status": "started", "agents": members})
+}
+
+func (s *Server) stopGroup(w http.ResponseWriter, r *http.Request) {
+	members, err := s.orch.StopGroup(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	jsonResponse(w, map[string]any{"status": "stopped", "agents": members})
+}
+
+// restartAgent stops the agent and starts a fresh container right away.
+// ?clear=true also starts a new conversation session.
+func (s *Server) restartAgent(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	clear, _ := strconv.ParseBool(r.URL.Query().Get("clear"))
+	containerID, err := s.orch.BounceAgent(r.Context(), id, clear)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	jsonResponse(w, map[string]any{"status": "restarted", "container_id": containerID, "session_cleared": clear})
+}
+
+// pingAgent starts the agent if needed and round-trips a control ping,
+// reporting how long each step took. ?timeout= bounds the ping (default 5s).
+func (s *Server) pingAgent(w http.ResponseWriter, r *http.Request) {
+	timeout := 5 * time.Second
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			jsonError(w, "invalid timeout", http.StatusBadRequest)
+			return
+		}
+		timeout = d
+	}
+	res, err := s.orch.DryRunAgent(r.Context(), r.PathValue("id"), timeout)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	jsonResponse(w, res)
+}
+
+// snapshotAgent streams a tar.zst archive of the agent's workspace volume.
+func (s *Server) snapshotAgent(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := s.registry.GetDefinition(id); !ok {
+		jsonError(w, "agent not found", http.StatusNotFound)
+		return
+	}
+	name := fmt.Sprintf("%s-%s.tar.zst", id, time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zstd")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	// The status is sent with the first byte, so later failures can only
+	// cut the stream short.
+	if err := s.orch.SnapshotAgent(r.Context(), id, w); err != nil {
+		slog.Error("agent snapshot failed", "agent", id, "error", err)
+	}
+}
+
+// maxSnapshotUploadBytes caps the snapshot a restore-snapshot request may
+// upload. The restore is staged inside the workspace volume, so the cap
+// bounds the disk an incomplete upload can take.
+const maxSnapshotUploadBytes = 4 << 30
+
+// restoreAgentSnapshot replaces the agent's workspace with the snapshot in
+// the request body.
+func (s *Server) restoreAgentSnapshot(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	body := http.MaxBytesReader(w, r.Body, maxSnapshotUploadBytes)
+	if err := s.orch.RestoreAgentSnapshot(r.Context(), id, body); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			jsonError(w, fmt.Sprintf("snapshot exceeds %d GB", maxSnapshotUploadBytes>>30), http.StatusRequestEntityTooLarge)
+			return
+		}
+		writeError(w, err)
+		return
+	}
+	jsonResponse(w, map[string]string{"status": "restored"})
+}
+
+// replayAgent resends the agent's last reply to the chat it last talked to.
+func (s *Server) replayAgent(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := s.registry.GetDefinition(id); !ok {
+		jsonError(w, "agent not found", http.StatusNotFound)
+		return
+	}
+	if err := s.orch.ReplayLast(id, nil); err != nil {
+		writeError(w, err)
+		return
+	}
+	jsonResponse(w, map[string]string{"status": "replayed"})
+}
+
+func (s *Server) listAgentArtifacts(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := s.registry.GetDefinition(id); !ok {
+		jsonError(w, "agent not found", http.StatusNotFound)
+		return
+	}
+	artifacts, err := s.orch.ListArtifacts(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if artifacts == nil {
+		artifacts = []store.Artifact{}
+	}
+	jsonResponse(w, artifacts)
+}
+
+// getAgentArtifact downloads an artifact the agent saved. Its content is
+// verified against the stored checksum first, which is also sent as the
+// ETag.
+func (s *Server) getAgentArtifact(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	a, f, err := s.orch.OpenArtifact(id, r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", a.Name))
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+	http.ServeContent(w, r, a.Name, a.CreatedAt, f)
+}
+
 func (s *Server) listTasks(w http.ResponseWriter, r *http.Request) {
 	tasks, err := s.store.ListTasks()
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	agentNames := s.agentNameMap()
@@ -263,6 +575,10 @@ func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
 		Prompt      string `json:"prompt"`
 		ContextMode string `json:"context_mode"`
 		Enabled     *bool  `json:"enabled"`
+		OnFailure   string `json:"on_failure"`
+		MaxFailures int    `json:"max_failures"`
+		RetryCount  int    `json:"retry_count"`
+		RetryDelay  string `json:"retry_delay"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		jsonError(w, "invalid request body", http.StatusBadRequest)
@@ -272,11 +588,20 @@ func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "agent_id, name, schedule, and prompt are required", http.StatusBadRequest)
 		return
 	}
+	if err := validateFailureSettings(body.OnFailure, body.MaxFailures); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	retryDelay, err := parseRetrySettings(body.RetryCount, body.RetryDelay)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Normalize schedule (handles plain cron strings)
 	normalized, err := schedule.NormalizeSchedule(body.Schedule)
 	if err != nil {
-		jsonError(w, fmt.Sprintf("invalid schedule: %v", err), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
@@ -293,6 +618,10 @@ func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
 		Prompt:      body.Prompt,
 		ContextMode: body.ContextMode,
 		Status:      status,
+		OnFailure:   body.OnFailure,
+		MaxFailures: body.MaxFailures,
+		RetryCount:  body.RetryCount,
+		RetryDelay:  retryDelay,
 	}
 	if t.ContextMode == "" {
 		t.ContextMode = "isolated"
@@ -304,7 +633,7 @@ func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.store.SaveTask(&t); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, taskToAPI(t, s.agentNameMap()))
@@ -315,7 +644,7 @@ func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
 
 	existing, err := s.store.GetTask(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if existing == nil {
@@ -331,6 +660,10 @@ func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
 		ContextMode *string `json:"context_mode"`
 		Enabled     *bool   `json:"enabled"`
 		Status      *string `json:"status"`
+		OnFailure   *string `json:"on_failure"`
+		MaxFailures *int    `json:"max_failures"`
+		RetryCount  *int    `json:"retry_count"`
+		RetryDelay  *string `json:"retry_delay"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		jsonError(w, "invalid request body", http.StatusBadRequest)
@@ -350,8 +683,34 @@ func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
 	if body.ContextMode != nil {
 		existing.ContextMode = *body.ContextMode
 	}
+	if body.OnFailure != nil {
+		existing.OnFailure = *body.OnFailure
+	}
+	if body.MaxFailures != nil {
+		existing.MaxFailures = *body.MaxFailures
+	}
+	if err := validateFailureSettings(existing.OnFailure, existing.MaxFailures); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.RetryCount != nil || body.RetryDelay != nil {
+		count, delay := existing.RetryCount, existing.RetryDelay.String()
+		if body.RetryCount != nil {
+			count = *body.RetryCount
+		}
+		if body.RetryDelay != nil {
+			delay = *body.RetryDelay
+		}
+		d, err := parseRetrySettings(count, delay)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		existing.RetryCount, existing.RetryDelay = count, d
+	}
 
 	// Handle enabled bool → status mapping
+	wasActive := existing.Status == "active"
 	if body.Enabled != nil {
 		if *body.Enabled {
 			existing.Status = "active"
@@ -366,7 +725,7 @@ func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
 	if body.Schedule != nil {
 		normalized, err := schedule.NormalizeSchedule(*body.Schedule)
 		if err != nil {
-			jsonError(w, fmt.Sprintf("invalid schedule: %v", err), http.StatusBadRequest)
+			writeError(w, err)
 			return
 		}
 		existing.Schedule = normalized
@@ -380,16 +739,24 @@ func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.store.SaveTask(existing); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+	// A resumed task starts counting towards max_failures afresh.
+	if !wasActive && existing.Status == "active" && existing.ConsecutiveFailures > 0 {
+		if err := s.store.ResetTaskFailures(existing.ID); err != nil {
+			writeError(w, err)
+			return
+		}
+		existing.ConsecutiveFailures = 0
+	}
 	jsonResponse(w, taskToAPI(*existing, s.agentNameMap()))
 }
 
 func (s *Server) deleteTask(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if err := s.store.DeleteTask(id); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, map[string]string{"status": "deleted"})
@@ -398,16 +765,63 @@ func (s *Server) deleteTask(w http.ResponseWriter, r *http.Request) {
 func (s *Server) deleteCompletedTasks(w http.ResponseWriter, r *http.Request) {
 	count, err := s.store.DeleteCompletedTasks()
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, map[string]any{"status": "deleted", "count": count})
 }
 
+// bulkTasks pauses, resumes or deletes every task matching the filter.
+// Pause only touches active tasks and resume only paused ones; resumed tasks
+// get a fresh next_run_at and failure count, as with PUT /api/tasks/{id}.
+func (s *Server) bulkTasks(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Action string `json:"action"`
+		Filter struct {
+			AgentID string `json:"agent_id"`
+			Status  string `json:"status"`
+		} `json:"filter"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		jsonError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	f := store.TaskFilter{AgentID: body.Filter.AgentID, Status: body.Filter.Status}
+	if f.AgentID == "" && f.Status == "" {
+		jsonError(w, "filter needs agent_id or status", http.StatusBadRequest)
+		return
+	}
+	switch f.Status {
+	case "", "active", "paused", "completed":
+	default:
+		jsonError(w, fmt.Sprintf("invalid status %q", f.Status), http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	var err error
+	switch body.Action {
+	case "pause":
+		count, err = s.store.PauseTasks(f)
+	case "resume":
+		count, err = s.store.ResumeTasks(f, schedule.CalculateNextRun)
+	case "delete":
+		count, err = s.store.DeleteTasks(f)
+	default:
+		jsonError(w, "action must be pause, resume or delete", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	jsonResponse(w, map[string]any{"action": body.Action, "count": count})
+}
+
 func (s *Server) listSwarms(w http.ResponseWriter, r *http.Request) {
 	runs, err := s.store.ListSwarmRuns()
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, runs)
@@ -426,32 +840,85 @@ func (s *Server) createSwarm(w http.ResponseWriter, r *http.Request) {
 
 	// Validate graph before launching
 	if _, err := swarm.BuildPlan(req.Agents, req.Synapses, req.LeadAgent); err != nil {
-		jsonError(w, fmt.Sprintf("invalid swarm graph: %v", err), http.StatusBadRequest)
+		swarmGraphError(w, err)
+		return
+	}
+	if !swarm.ValidFailurePolicy(req.FailurePolicy) {
+		jsonError(w, fmt.Sprintf("unknown failure_policy %q", req.FailurePolicy), http.StatusBadRequest)
 		return
 	}
 
 	run, err := s.swarmCoord.RunSwarm(r.Context(), req)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, run)
 }
 
+// previewSwarmPrompts returns the prompt each member of a swarm request
+// would receive, without running it. "outputs" optionally stands in for the
+// members' results, by role.
+func (s *Server) previewSwarmPrompts(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		swarm.SwarmRequest
+		Outputs map[string]string `json:"outputs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Agents) == 0 {
+		jsonError(w, "agents are required", http.StatusBadRequest)
+		return
+	}
+	previews, err := swarm.PreviewPrompts(req.SwarmRequest, req.Outputs)
+	if err != nil {
+		swarmGraphError(w, err)
+		return
+	}
+	jsonResponse(w, previews)
+}
+
+// swarmGraphError answers 400 for a graph BuildPlan rejected, with the
+// *swarm.GraphError under "graph" so the UI can highlight the offending role
+// or synapse.
+func swarmGraphError(w http.ResponseWriter, err error) {
+	body := map[string]any{"error": fmt.Sprintf("invalid swarm graph: %v", err)}
+	var gerr *swarm.GraphError
+	if errors.As(err, &gerr) {
+		body["graph"] = gerr
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
 func (s *Server) deleteSwarm(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if err := s.store.DeleteSwarmRun(id); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, map[string]string{"status": "deleted"})
 }
 
+// deleteCompletedSwarms removes every finished run (completed, with errors
+// or failed) and its members' leftover containers.
+func (s *Server) deleteCompletedSwarms(w http.ResponseWriter, r *http.Request) {
+	count, err := s.swarmCoord.PruneRuns(r.Context(), time.Now(), 0)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	jsonResponse(w, map[string]any{"status": "deleted", "count": count})
+}
+
 func (s *Server) getSwarm(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	run, err := s.swarmCoord.GetStatus(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if run == nil {
@@ -465,6 +932,7 @@ func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
 	agents, _ := s.orch.ListRunning(r.Context())
 	agentDefs, _ := s.store.ListAgents()
 	tasks, _ := s.store.ListTasks()
+	orphanedSwarms, _ := s.store.CountSwarmRunsByReason(swarm.OrphanedReason)
 
 	pendingTasks := 0
 	for _, t := range tasks {
@@ -500,41 +968,60 @@ func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
 		if tr := extractTerminalReason(m.Metadata); tr != "" {
 			msg["terminal_reason"] = tr
 		}
+		if m.ReplyTo != nil {
+			msg["reply_to"] = fmt.Sprintf("%d", *m.ReplyTo)
+		}
 		recentOut = append(recentOut, msg)
 	}
 
 	status := map[string]any{
-		"status":          "ok",
-		"active_agents":   len(agents),
-		"agents_count":    len(agentDefs),
-		"pending_tasks":   pendingTasks,
-		"uptime":          uptime,
-		"recent_messages": recentOut,
-		"nats":            "ok",
-		"timestamp":       time.Now().UTC(),
-		"version":         s.version,
+		"status":                  "ok",
+		"active_agents":           len(agents),
+		"agents_count":            len(agentDefs),
+		"pending_tasks":           pendingTasks,
+		"orphaned_swarms":         orphanedSwarms,
+		"secret_decrypt_failures": len(s.orch.DecryptFailures()),
+		"uptime":                  uptime,
+		"recent_messages":         recentOut,
+		"nats":                    "ok",
+		"timestamp":               time.Now().UTC(),
+		"version":                 s.version,
+		"safe_mode":               s.safeMode,
 	}
 
 	jsonResponse(w, status)
 }
 
+func (s *Server) getDBStatus(w http.ResponseWriter, r *http.Request) {
+	version, err := s.store.SchemaVersion()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	latest := store.LatestSchemaVersion()
+	jsonResponse(w, map[string]any{
+		"schema_version":        version,
+		"latest_schema_version": latest,
+		"up_to_date":            version == latest,
+	})
+}
+
 func (s *Server) getAgentMD(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	a, err := s.store.GetAgent(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if a == nil {
 		jsonError(w, "agent not found", http.StatusNotFound)
 		return
 	}
-	workspace := a.Workspace
-	if workspace == "" {
-		workspace = id
+	content, err := s.readAgentFile(r.Context(), a, "AGENT.md")
+	if errors.Is(err, ErrPathDenied) {
+		writeError(w, err)
+		return
 	}
-	image := s.registry.ResolveImage(id)
-	content, err := s.orch.ReadVolumeFile(r.Context(), workspace, "AGENT.md", image)
 	if err != nil || content == "" {
 		// Volume or file doesn't exist yet — return template
 		content = agentMDTemplate
@@ -546,7 +1033,7 @@ func (s *Server) updateAgentMD(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	a, err := s.store.GetAgent(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if a == nil {
@@ -560,13 +1047,8 @@ func (s *Server) updateAgentMD(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
-	workspace := a.Workspace
-	if workspace == "" {
-		workspace = id
-	}
-	image := s.registry.ResolveImage(id)
-	if err := s.orch.WriteVolumeFile(r.Context(), workspace, "AGENT.md", body.Content, image); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+	if err := s.writeAgentFile(r.Context(), a, "AGENT.md", body.Content); err != nil {
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, map[string]string{"status": "saved"})
@@ -575,7 +1057,7 @@ func (s *Server) updateAgentMD(w http.ResponseWriter, r *http.Request) {
 func (s *Server) getUserProfile(w http.ResponseWriter, r *http.Request) {
 	content, err := s.registry.GetUserMD()
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, map[string]string{"content": content})
@@ -589,8 +1071,44 @@ func (s *Server) updateUserProfile(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
-	if err := s.registry.SaveUserMD(body.Content); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+	if err := s.orch.SaveUserProfile(body.Content); err != nil {
+		writeError(w, err)
+		return
+	}
+	jsonResponse(w, map[string]string{"status": "saved"})
+}
+
+// maxGlobalInstructionsBytes caps the global CLAUDE.md, which goes into
+// every agent's context.
+const maxGlobalInstructionsBytes = 64 << 10
+
+func (s *Server) getGlobalInstructions(w http.ResponseWriter, r *http.Request) {
+	content, err := s.registry.GetGlobalClaudeMD()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if content == "" {
+		// Not created yet (or emptied) — return template
+		content = registry.GlobalClaudeMDTemplate
+	}
+	jsonResponse(w, map[string]string{"content": content})
+}
+
+func (s *Server) updateGlobalInstructions(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		jsonError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(body.Content) > maxGlobalInstructionsBytes {
+		jsonError(w, fmt.Sprintf("global instructions must be at most %d KB", maxGlobalInstructionsBytes>>10), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := s.orch.SaveGlobalInstructions(body.Content); err != nil {
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, map[string]string{"status": "saved"})
@@ -605,6 +1123,119 @@ func (s *Server) agentNameMap() map[string]string {
 	return m
 }
 
+// validateFailureSettings checks a task's on_failure action and max_failures.
+func validateFailureSettings(onFailure string, maxFailures int) error {
+	if _, _, err := scheduler.ParseOnFailure(onFailure); err != nil {
+		return err
+	}
+	if maxFailures < 0 {
+		return fmt.Errorf("max_failures must not be negative")
+	}
+	return nil
+}
+
+// maxTaskRetries caps retry_count, so a broken task can't keep its agent
+// busy until the next scheduled run.
+const maxTaskRetries = 10
+
+// parseRetrySettings checks a task's retry_count and parses its retry_delay
+// ("30s", "5m"; empty for the scheduler default).
+func parseRetrySettings(count int, delay string) (time.Duration, error) {
+	if count < 0 || count > maxTaskRetries {
+		return 0, fmt.Errorf("retry_count must be between 0 and %d", maxTaskRetries)
+	}
+	if delay == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(delay)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("retry_delay must be a duration such as 30s or 5m")
+	}
+	return d, nil
+}
+
+func (s *Server) listTaskRuns(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	task, err := s.store.GetTask(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if task == nil {
+		jsonError(w, "task not found", http.StatusNotFound)
+		return
+	}
+	runs, err := s.store.ListTaskRuns(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if runs == nil {
+		runs = []store.TaskRun{}
+	}
+	jsonResponse(w, runs)
+}
+
+// listActivity returns messages, agent lifecycle events, task runs and swarm
+// runs merged newest first. since and before are RFC 3339 timestamps; page
+// back by passing the oldest item's cursor as cursor. types is a
+// comma-separated subset of store.ActivityTypes.
+func (s *Server) listActivity(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.ActivityFilter{Limit: 50}
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			jsonError(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		f.Limit = min(n, 500)
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &f.Since}, {"before", &f.Before}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			jsonError(w, p.name+" must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		*p.dst = t
+	}
+	if v := q.Get("cursor"); v != "" {
+		c, err := store.ParseActivityCursor(v)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.After = &c
+	}
+	if v := q.Get("types"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(store.ActivityTypes, t) {
+				jsonError(w, fmt.Sprintf("unknown activity type %q", t), http.StatusBadRequest)
+				return
+			}
+			f.Types = append(f.Types, t)
+		}
+	}
+
+	items, err := s.store.ListActivity(f)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if items == nil {
+		items = []store.ActivityItem{}
+	}
+	jsonResponse(w, items)
+}
+
 func taskToAPI(t store.ScheduledTask, agentNames map[string]string) map[string]any {
 	m := map[string]any{
 		"id":               t.ID,
@@ -615,6 +1246,23 @@ func taskToAPI(t store.ScheduledTask, agentNames map[string]string) map[string]a
 		"prompt":           t.Prompt,
 		"enabled":          t.Status == "active",
 		"status":           t.Status,
+		"on_failure":       t.OnFailure,
+		"max_failures":     t.MaxFailures,
+	}
+	if t.LastStatus != "" {
+		m["last_status"] = t.LastStatus
+	}
+	if t.LastError != "" {
+		m["last_error"] = t.LastError
+	}
+	if t.ConsecutiveFailures > 0 {
+		m["consecutive_failures"] = t.ConsecutiveFailures
+	}
+	if t.RetryCount > 0 {
+		m["retry_count"] = t.RetryCount
+		if t.RetryDelay > 0 {
+			m["retry_delay"] = t.RetryDelay.String()
+		}
 	}
 	if name, ok := agentNames[t.AgentID]; ok {
 		m["agent_name"] = name
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+)
+
+// ErrReloadInProgress is returned by a ConfigReloader when another reload
+// (SIGHUP, file watcher or API) is still being applied.
+var ErrReloadInProgress = errors.New("config reload already in progress")
+
+// maxConfigPreviewSize caps the YAML body accepted by the preview endpoint.
+const maxConfigPreviewSize = 1 << 20
+
+// ConfigReloader applies the on-disk config the same way SIGHUP does and
+// reports what changed. PreviewConfig diffs a candidate config (the file
+// on disk when data is empty) against the running one without applying it.
+// LoadedConfig returns the config currently in effect.
+type ConfigReloader interface {
+	ReloadConfig(ctx context.Context) (config.ConfigDiff, error)
+	PreviewConfig(data []byte) (config.ConfigDiff, error)
+	LoadedConfig() LoadedConfig
+}
+
+// LoadedConfig is the running config together with where it came from.
+// Hash is the hex SHA-256 of the file as loaded, empty when there was none.
+type LoadedConfig struct {
+	Config   *config.Config
+	Path     string
+	Hash     string
+	LoadedAt time.Time
+}
+
+// SetConfigReloader enables the /api/admin config, reload and preview endpoints.
+func (s *Server) SetConfigReloader(r ConfigReloader) {
+	s.reloader = r
+}
+
+// diffToAPI summarizes a config diff for the UI. Slices are never null so
+// clients can iterate without checks.
+func diffToAPI(d config.ConfigDiff) map[string]any {
+	nonNil := func(v []string) []string {
+		if v == nil {
+			return []string{}
+		}
+		return v
+	}
+	return map[string]any{
+		"has_changes":          d.HasChanges(),
+		"agents_added":         nonNil(d.AgentsAdded),
+		"agents_removed":       nonNil(d.AgentsRemoved),
+		"agents_changed":       nonNil(d.AgentsChanged),
+		"defaults_changed":     d.DefaultsChanged,
+		"router_changed":       d.RouterChanged,
+		"scheduler_changed":    d.SchedulerChanged,
+		"main_chat_id_changed": d.MainChatIDChanged,
+		"non_reloadable":       nonNil(d.NonReloadable),
+	}
+}
+
+func (s *Server) reloadConfig(w http.ResponseWriter, r *http.Request) {
+	if s.reloader == nil {
+		jsonError(w, "config reload not available", http.StatusServiceUnavailable)
+		return
+	}
+	diff, err := s.reloader.ReloadConfig(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	slog.Info("config reloaded via API", "changes", diff.HasChanges())
+	jsonResponse(w, diffToAPI(diff))
+}
+
+func (s *Server) previewConfig(w http.ResponseWriter, r *http.Request) {
+	if s.reloader == nil {
+		jsonError(w, "config reload not available", http.StatusServiceUnavailable)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigPreviewSize))
+	if err != nil {
+		jsonError(w, "config body too large or unreadable", http.StatusBadRequest)
+		return
+	}
+	diff, err := s.reloader.PreviewConfig(data)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jsonResponse(w, diffToAPI(diff))
+}
+
+// getLoadedConfig returns the effective config (after env overrides and
+// defaults) with secrets masked, so operators can confirm a reload landed.
+func (s *Server) getLoadedConfig(w http.ResponseWriter, r *http.Request) {
+	if s.reloader == nil {
+		jsonError(w, "config not available", http.StatusServiceUnavailable)
+		return
+	}
+	loaded := s.reloader.LoadedConfig()
+	cfg, err := loaded.Config.MaskedMap()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	jsonResponse(w, map[string]any{
+		"config":    cfg,
+		"path":      loaded.Path,
+		"hash":      loaded.Hash,
+		"loaded_at": loaded.LoadedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// refreshImage restarts running agents created from an older build of
+// ?image= (the default image when absent). ?eager=true starts them again at
+// once instead of on their next message.
+func (s *Server) refreshImage(w http.ResponseWriter, r *http.Request) {
+	eager, _ := strconv.ParseBool(r.URL.Query().Get("eager"))
+	res, err := s.orch.RefreshImage(r.Context(), r.URL.Query().Get("image"), eager)
+	if err != nil && res == nil {
+		writeError(w, err)
+		return
+	}
+	body := map[string]any{"image": res.Image, "image_id": res.ImageID, "restarted": res.Restarted}
+	if err != nil {
+		body["error"] = err.Error()
+	}
+	jsonResponse(w, body)
+}
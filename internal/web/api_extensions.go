@@ -12,7 +12,7 @@ func (s *Server) getAgentExtensions(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	a, err := s.store.GetAgent(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if a == nil {
@@ -22,13 +22,13 @@ func (s *Server) getAgentExtensions(w http.ResponseWriter, r *http.Request) {
 
 	data, err := s.store.GetAgentExtensions(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
 	statusData, err := s.store.GetExtensionStatus(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -48,7 +48,7 @@ func (s *Server) updateAgentExtensions(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	a, err := s.store.GetAgent(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if a == nil {
@@ -84,7 +84,7 @@ func (s *Server) updateAgentExtensions(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.store.SetAgentExtensions(id, string(data)); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -0,0 +1,151 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/agent"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+// ErrPathDenied is returned for workspace paths outside an agent's
+// file_access.
+var ErrPathDenied = errors.New("path not permitted by file_access")
+
+// volumeFiles is the part of the orchestrator the workspace file APIs use.
+type volumeFiles interface {
+	ReadVolumeFile(ctx context.Context, workspace, filePath, image string) (string, error)
+	WriteVolumeFile(ctx context.Context, workspace, filePath, content, image string) error
+	WriteVolumeBytes(ctx context.Context, workspace, filePath string, data []byte, image string) error
+}
+
+// readAgentFile reads filePath, relative to the root of a's workspace
+// volume, once the agent's file_access permits it.
+func (s *Server) readAgentFile(ctx context.Context, a *store.Agent, filePath string) (string, error) {
+	workspace, image, err := s.agentFileTarget(a, filePath)
+	if err != nil {
+		return "", err
+	}
+	return s.files.ReadVolumeFile(ctx, workspace, filePath, image)
+}
+
+// writeAgentFile is the write counterpart of readAgentFile.
+func (s *Server) writeAgentFile(ctx context.Context, a *store.Agent, filePath, content string) error {
+	workspace, image, err := s.agentFileTarget(a, filePath)
+	if err != nil {
+		return err
+	}
+	return s.files.WriteVolumeFile(ctx, workspace, filePath, content, image)
+}
+
+func (s *Server) agentFileTarget(a *store.Agent, filePath string) (workspace, image string, err error) {
+	def, _ := s.registry.GetDefinition(a.ID)
+	if !def.FileAccess.Allows(filePath) {
+		return "", "", fmt.Errorf("%w: %s", ErrPathDenied, filePath)
+	}
+	workspace = a.Workspace
+	if workspace == "" {
+		workspace = a.ID
+	}
+	return workspace, s.registry.ResolveImage(a.ID), nil
+}
+
+// maxUploadBytes caps a file attached to a web message, the same 20 MB the
+// Telegram bot API lets bots download.
+const maxUploadBytes = 20 << 20
+
+var errUploadTooLarge = fmt.Errorf("file exceeds %d MB", maxUploadBytes>>20)
+
+// messageFile is a file attached to a web message.
+type messageFile struct {
+	name     string
+	mimeType string
+	data     []byte
+}
+
+func isMultipart(r *http.Request) bool {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mt == "multipart/form-data"
+}
+
+// readMessageForm reads a multipart send-message request: text,
+// override_model and override_env (a JSON object) as form fields, and an
+// optional "file" part, which must fit maxUploadBytes and pass
+// defaults.file_filter.
+func (s *Server) readMessageForm(w http.ResponseWriter, r *http.Request) (sendMessageRequest, *messageFile, error) {
+	var req sendMessageRequest
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		return req, nil, err
+	}
+	req.Text = r.FormValue("text")
+	req.OverrideModel = r.FormValue("override_model")
+	if env := r.FormValue("override_env"); env != "" {
+		if err := json.Unmarshal([]byte(env), &req.OverrideEnv); err != nil {
+			return req, nil, fmt.Errorf("override_env must be a JSON object")
+		}
+	}
+
+	part, header, err := r.FormFile("file")
+	if errors.Is(err, http.ErrMissingFile) {
+		return req, nil, nil
+	}
+	if err != nil {
+		return req, nil, err
+	}
+	defer func() { _ = part.Close() }()
+	if header.Size > maxUploadBytes {
+		return req, nil, errUploadTooLarge
+	}
+
+	name, err := agent.SanitizeFileName(header.Filename)
+	if err != nil {
+		return req, nil, err
+	}
+	mimeType := header.Header.Get("Content-Type")
+	data, err := io.ReadAll(part)
+	if err != nil {
+		return req, nil, err
+	}
+	if err := agent.CheckFileFilter(s.registry.FileFilter(), name, mimeType, data); err != nil {
+		return req, nil, err
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return req, &messageFile{name: name, mimeType: mimeType, data: data}, nil
+}
+
+// messageFormError answers for a form readMessageForm refused: 413 for an
+// oversized request or file, 400 otherwise.
+func messageFormError(w http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) || errors.Is(err, errUploadTooLarge) {
+		jsonError(w, fmt.Sprintf("file exceeds %d MB", maxUploadBytes>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	jsonError(w, err.Error(), http.StatusBadRequest)
+}
+
+// saveMessageFile writes f under uploads/ in a's workspace volume, as the
+// Telegram bot does for attachments, and returns its path inside the
+// agent's container.
+func (s *Server) saveMessageFile(ctx context.Context, a *store.Agent, f *messageFile) (string, error) {
+	volumePath := fmt.Sprintf("uploads/%d_%s", time.Now().Unix(), f.name)
+	workspace, image, err := s.agentFileTarget(a, volumePath)
+	if err != nil {
+		return "", err
+	}
+	if err := s.files.WriteVolumeBytes(ctx, workspace, volumePath, f.data, image); err != nil {
+		return "", err
+	}
+	slog.Info("web upload saved", "agent", a.ID, "name", f.name, "size", len(f.data), "path", volumePath)
+	return "/workspace/agent/" + volumePath, nil
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/registry"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+// fakeFiles is an in-memory workspace volume keyed by workspace/path.
+type fakeFiles map[string]string
+
+func (f fakeFiles) ReadVolumeFile(ctx context.Context, workspace, filePath, image string) (string, error) {
+	return f[workspace+"/"+filePath], nil
+}
+
+func (f fakeFiles) WriteVolumeFile(ctx context.Context, workspace, filePath, content, image string) error {
+	f[workspace+"/"+filePath] = content
+	return nil
+}
+
+func (f fakeFiles) WriteVolumeBytes(ctx context.Context, workspace, filePath string, data []byte, image string) error {
+	f[workspace+"/"+filePath] = string(data)
+	return nil
+}
+
+func newFilesTestServer(t *testing.T, agents map[string]config.AgentDefinition) (*Server, fakeFiles) {
+	t.Helper()
+	dir := t.TempDir()
+	st, err := store.New(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	reg := registry.New(st, agents, config.DefaultsConfig{}, filepath.Join(dir, "agents"))
+	if err := reg.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(st, nil, nil, reg, nil, nil, config.WebConfig{}, nil, "test")
+	files := fakeFiles{}
+	s.files = files
+	return s, files
+}
+
+func TestAgentMDFileAccess(t *testing.T) {
+	s, files := newFilesTestServer(t, map[string]config.AgentDefinition{
+		"open":    {Workspace: "open"},
+		"allowed": {Workspace: "allowed", FileAccess: &config.FileAccessConfig{Allow: []string{"AGENT.md", "docs"}}},
+		"denied":  {Workspace: "denied", FileAccess: &config.FileAccessConfig{Deny: []string{"AGENT.md", ".git"}}},
+		"outside": {Workspace: "outside", FileAccess: &config.FileAccessConfig{Allow: []string{"docs"}}},
+	})
+
+	tests := []struct {
+		agent string
+		want  int
+	}{
+		{"open", http.StatusOK},
+		{"allowed", http.StatusOK},
+		{"denied", http.StatusForbidden},
+		{"outside", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		put := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"content":"# `+tt.agent+`"}`))
+		put.SetPathValue("id", tt.agent)
+		rec := httptest.NewRecorder()
+		s.updateAgentMD(rec, put)
+		if rec.Code != tt.want {
+			t.Errorf("PUT %s: status = %d, want %d", tt.agent, rec.Code, tt.want)
+		}
+
+		get := httptest.NewRequest(http.MethodGet, "/", nil)
+		get.SetPathValue("id", tt.agent)
+		rec = httptest.NewRecorder()
+		s.getAgentMD(rec, get)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s: status = %d, want %d", tt.agent, rec.Code, tt.want)
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Content != "# "+tt.agent {
+			t.Errorf("GET %s: content = %q", tt.agent, resp.Content)
+		}
+	}
+	if _, ok := files["denied/AGENT.md"]; ok {
+		t.Error("denied write reached the volume")
+	}
+}
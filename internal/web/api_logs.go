@@ -0,0 +1,110 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/mtzanidakis/praktor/internal/logring"
+)
+
+const (
+	defaultLogLimit = 500
+	logTailBuffer   = 256 // entries queued per tailing socket before new ones are dropped
+)
+
+// SetLogRing enables GET /api/admin/logs and the tail_logs socket command.
+func (s *Server) SetLogRing(r *logring.Ring) {
+	s.logs = r
+}
+
+// parseLogFilter reads level, component, q and limit (default 500).
+func parseLogFilter(q func(string) string) (logring.Filter, error) {
+	f := logring.Filter{Component: q("component"), Query: q("q"), Limit: defaultLogLimit}
+	if v := q("level"); v != "" {
+		if err := f.Level.UnmarshalText([]byte(v)); err != nil {
+			return f, fmt.Errorf("invalid level %q", v)
+		}
+	}
+	if v := q("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, fmt.Errorf("invalid limit %q", v)
+		}
+		f.Limit = n
+	}
+	return f, nil
+}
+
+// getLogs returns the newest gateway log entries matching ?level= (minimum,
+// default info), ?component= (logging package, e.g. agent), ?q= (substring
+// of the message or an attribute) and ?limit=, oldest first.
+func (s *Server) getLogs(w http.ResponseWriter, r *http.Request) {
+	if s.logs == nil {
+		jsonError(w, "log buffer not available", http.StatusServiceUnavailable)
+		return
+	}
+	f, err := parseLogFilter(r.URL.Query().Get)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jsonResponse(w, s.logs.Entries(f))
+}
+
+// tailLogs streams entries matching the command's filter to client as "log"
+// events until the returned func is called. Entries are dropped while the
+// client is too slow to keep up; nothing here logs, as that would feed the
+// tail itself.
+func (s *Server) tailLogs(client *wsClient, cmd wsCommand) (stop func(), err error) {
+	if s.logs == nil {
+		return nil, fmt.Errorf("log buffer not available")
+	}
+	f, err := parseLogFilter(func(key string) string {
+		switch key {
+		case "level":
+			return cmd.Level
+		case "component":
+			return cmd.Component
+		case "q":
+			return cmd.Text
+		}
+		return ""
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan logring.Entry, logTailBuffer)
+	done := make(chan struct{})
+	cancel := s.logs.Subscribe(func(e logring.Entry) {
+		if !f.Match(e) {
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case e := <-ch:
+				data, err := json.Marshal(newEvent("log", e))
+				if err != nil {
+					continue
+				}
+				if client.write(data) != nil {
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		close(done)
+	}, nil
+}
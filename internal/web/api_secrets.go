@@ -2,22 +2,29 @@ package web
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
-	"time"
 
+	"github.com/mtzanidakis/praktor/internal/events"
 	"github.com/mtzanidakis/praktor/internal/natsbus"
 	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/vault"
 )
 
 func (s *Server) listSecrets(w http.ResponseWriter, r *http.Request) {
 	secrets, err := s.store.ListSecrets()
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if secrets == nil {
 		secrets = []store.Secret{}
 	}
+	usage, err := s.registry.SecretUsage()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
 	// Enrich with agent assignments
 	out := make([]map[string]any, 0, len(secrets))
@@ -34,6 +41,7 @@ func (s *Server) listSecrets(w http.ResponseWriter, r *http.Request) {
 			"filename":    sec.Filename,
 			"global":      sec.Global,
 			"agent_ids":   agentIDs,
+			"unused":      usage[sec.ID] != nil && usage[sec.ID].Unused(),
 			"created_at":  sec.CreatedAt,
 			"updated_at":  sec.UpdatedAt,
 		})
@@ -41,6 +49,32 @@ func (s *Server) listSecrets(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, out)
 }
 
+// getSecretUsage lists the agents that can or need to read a secret: those
+// it is assigned to, those whose config or extensions reference it by name,
+// and whether it is global.
+func (s *Server) getSecretUsage(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	usage, err := s.registry.SecretUsage()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	u := usage[id]
+	if u == nil {
+		jsonError(w, "secret not found", http.StatusNotFound)
+		return
+	}
+	jsonResponse(w, map[string]any{
+		"id":         id,
+		"global":     u.Global,
+		"assigned":   u.Assigned,
+		"config":     u.Config,
+		"extensions": u.Extensions,
+		"agents":     u.Agents,
+		"unused":     u.Unused(),
+	})
+}
+
 func (s *Server) createSecret(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		Name        string   `json:"name"`
@@ -69,7 +103,7 @@ func (s *Server) createSecret(w http.ResponseWriter, r *http.Request) {
 
 	ciphertext, nonce, err := s.vault.Encrypt([]byte(body.Value))
 	if err != nil {
-		jsonError(w, "encryption failed", http.StatusInternalServerError)
+		writeError(w, fmt.Errorf("encryption failed: %w", err))
 		return
 	}
 
@@ -84,14 +118,14 @@ func (s *Server) createSecret(w http.ResponseWriter, r *http.Request) {
 		Global:      body.Global,
 	}
 	if err := s.store.SaveSecret(sec); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
 	// Set agent assignments
 	_ = s.store.SetSecretAgents(body.Name, body.AgentIDs)
 
-	s.publishSecretEvent(natsbus.TopicEventsSecretCreated, sec.ID, sec.Name)
+	s.publishSecretEvent(natsbus.TopicEventsSecretCreated(), events.TypeSecretCreated, sec.ID, sec.Name)
 
 	jsonResponse(w, map[string]any{
 		"id":          sec.ID,
@@ -106,7 +140,7 @@ func (s *Server) getSecret(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	sec, err := s.store.GetSecret(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if sec == nil {
@@ -132,14 +166,14 @@ func (s *Server) getSecret(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) updateSecret(w http.ResponseWriter, r *http.Request) {
 	if s.vault == nil {
-		jsonError(w, "vault not configured", http.StatusServiceUnavailable)
+		writeError(w, vault.ErrVaultLocked)
 		return
 	}
 
 	id := r.PathValue("id")
 	existing, err := s.store.GetSecret(id)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if existing == nil {
@@ -177,7 +211,7 @@ func (s *Server) updateSecret(w http.ResponseWriter, r *http.Request) {
 	if body.Value != nil {
 		ciphertext, nonce, err := s.vault.Encrypt([]byte(*body.Value))
 		if err != nil {
-			jsonError(w, "encryption failed", http.StatusInternalServerError)
+			writeError(w, fmt.Errorf("encryption failed: %w", err))
 			return
 		}
 		existing.Value = ciphertext
@@ -185,7 +219,7 @@ func (s *Server) updateSecret(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := s.store.SaveSecret(existing); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -194,7 +228,7 @@ func (s *Server) updateSecret(w http.ResponseWriter, r *http.Request) {
 		_ = s.store.SetSecretAgents(id, body.AgentIDs)
 	}
 
-	s.publishSecretEvent(natsbus.TopicEventsSecretUpdated, existing.ID, existing.Name)
+	s.publishSecretEvent(natsbus.TopicEventsSecretUpdated(), events.TypeSecretUpdated, existing.ID, existing.Name)
 
 	jsonResponse(w, map[string]any{
 		"id":          existing.ID,
@@ -208,10 +242,10 @@ func (s *Server) updateSecret(w http.ResponseWriter, r *http.Request) {
 func (s *Server) deleteSecret(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if err := s.store.DeleteSecret(id); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
-	s.publishSecretEvent(natsbus.TopicEventsSecretDeleted, id, id)
+	s.publishSecretEvent(natsbus.TopicEventsSecretDeleted(), events.TypeSecretDeleted, id, id)
 	jsonResponse(w, map[string]string{"status": "deleted"})
 }
 
@@ -219,7 +253,7 @@ func (s *Server) getAgentSecrets(w http.ResponseWriter, r *http.Request) {
 	agentID := r.PathValue("id")
 	secrets, err := s.store.GetAgentSecrets(agentID)
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	if secrets == nil {
@@ -238,7 +272,7 @@ func (s *Server) setAgentSecrets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err := s.store.SetAgentSecrets(agentID, body.SecretIDs); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, map[string]string{"status": "updated"})
@@ -248,7 +282,7 @@ func (s *Server) addAgentSecret(w http.ResponseWriter, r *http.Request) {
 	agentID := r.PathValue("id")
 	secretID := r.PathValue("secretId")
 	if err := s.store.AddAgentSecret(agentID, secretID); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, map[string]string{"status": "added"})
@@ -258,27 +292,15 @@ func (s *Server) removeAgentSecret(w http.ResponseWriter, r *http.Request) {
 	agentID := r.PathValue("id")
 	secretID := r.PathValue("secretId")
 	if err := s.store.RemoveAgentSecret(agentID, secretID); err != nil {
-		jsonError(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 	jsonResponse(w, map[string]string{"status": "removed"})
 }
 
-func (s *Server) publishSecretEvent(topic, secretID, name string) {
+func (s *Server) publishSecretEvent(topic, eventType, secretID, name string) {
 	if s.nats == nil {
 		return
 	}
-	event := map[string]any{
-		"type":      topic,
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"data": map[string]any{
-			"id":   secretID,
-			"name": name,
-		},
-	}
-	data, err := json.Marshal(event)
-	if err != nil {
-		return
-	}
-	_ = s.nats.Publish(topic, data)
+	_ = s.nats.PublishJSON(topic, events.NewSecret(eventType, secretID, name))
 }
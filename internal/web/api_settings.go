@@ -0,0 +1,69 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+// settingToAPI returns the setting with its value embedded as raw JSON;
+// values are opaque documents owned by the feature that writes them.
+func settingToAPI(st store.Setting) map[string]any {
+	return map[string]any{
+		"key":        st.Key,
+		"value":      json.RawMessage(st.Value),
+		"updated_at": st.UpdatedAt,
+	}
+}
+
+func (s *Server) listSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := s.store.ListSettings()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	out := make([]map[string]any, 0, len(settings))
+	for _, st := range settings {
+		out = append(out, settingToAPI(st))
+	}
+	jsonResponse(w, out)
+}
+
+func (s *Server) getSetting(w http.ResponseWriter, r *http.Request) {
+	st, err := s.store.GetSetting(r.PathValue("key"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if st == nil {
+		jsonError(w, "setting not found", http.StatusNotFound)
+		return
+	}
+	jsonResponse(w, settingToAPI(*st))
+}
+
+func (s *Server) updateSetting(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	var body struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		jsonError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(body.Value) == 0 {
+		jsonError(w, "value is required", http.StatusBadRequest)
+		return
+	}
+	if err := s.store.SetSetting(key, string(body.Value)); err != nil {
+		writeError(w, err)
+		return
+	}
+	st, err := s.store.GetSetting(key)
+	if err != nil || st == nil {
+		jsonError(w, "failed to read back setting", http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, settingToAPI(*st))
+}
@@ -0,0 +1,205 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/registry"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+func TestBulkTasks(t *testing.T) {
+	s, _ := newFilesTestServer(t, map[string]config.AgentDefinition{"a1": {Workspace: "a1"}, "a2": {Workspace: "a2"}})
+	for _, task := range []store.ScheduledTask{
+		{ID: "t1", AgentID: "a1", Status: "paused"},
+		{ID: "t2", AgentID: "a2", Status: "paused"},
+	} {
+		task.Name, task.Schedule, task.Prompt = task.ID, `{"kind":"interval","interval_ms":60000}`, "p"
+		if err := s.store.SaveTask(&task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.bulkTasks(rec, httptest.NewRequest(http.MethodPost, "/api/tasks/bulk", strings.NewReader(body)))
+		return rec
+	}
+	for _, bad := range []string{
+		`{"action":"pause","filter":{}}`,
+		`{"action":"archive","filter":{"agent_id":"a1"}}`,
+		`{"action":"pause","filter":{"status":"running"}}`,
+	} {
+		if rec := post(bad); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", bad, rec.Code)
+		}
+	}
+
+	before := time.Now()
+	rec := post(`{"action":"resume","filter":{"agent_id":"a1"}}`)
+	var resp struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusOK || resp.Count != 1 {
+		t.Fatalf("resume: status %d, count %d, %v", rec.Code, resp.Count, err)
+	}
+	got, _ := s.store.GetTask("t1")
+	if got.Status != "active" || got.NextRunAt == nil || got.NextRunAt.Before(before.Add(59*time.Second)) || got.NextRunAt.After(time.Now().Add(61*time.Second)) {
+		t.Errorf("resumed task: status %s, next_run_at %v, want active in about a minute", got.Status, got.NextRunAt)
+	}
+	if got, _ := s.store.GetTask("t2"); got.Status != "paused" || got.NextRunAt != nil {
+		t.Errorf("unmatched task: status %s, next_run_at %v, want still paused", got.Status, got.NextRunAt)
+	}
+}
+
+func TestGlobalInstructions(t *testing.T) {
+	s, _ := newFilesTestServer(t, map[string]config.AgentDefinition{"a1": {Workspace: "a1"}})
+	get := func() string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.getGlobalInstructions(rec, httptest.NewRequest(http.MethodGet, "/api/global-instructions", nil))
+		var resp struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("get: status %d, %v", rec.Code, err)
+		}
+		return resp.Content
+	}
+
+	if got := get(); got != registry.GlobalClaudeMDTemplate {
+		t.Errorf("initial content = %q, want the template", got)
+	}
+	custom := "# Global Instructions\n\nAlways cite sources.\n"
+	if err := s.registry.SaveGlobalClaudeMD(custom); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(); got != custom {
+		t.Errorf("content = %q, want %q", got, custom)
+	}
+	if err := s.registry.SaveGlobalClaudeMD(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(); got != registry.GlobalClaudeMDTemplate {
+		t.Errorf("emptied content = %q, want the template", got)
+	}
+
+	body, _ := json.Marshal(map[string]string{"content": strings.Repeat("x", maxGlobalInstructionsBytes+1)})
+	rec := httptest.NewRecorder()
+	s.updateGlobalInstructions(rec, httptest.NewRequest(http.MethodPut, "/api/global-instructions", strings.NewReader(string(body))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized update: status = %d, want 413", rec.Code)
+	}
+	if got := get(); got != registry.GlobalClaudeMDTemplate {
+		t.Errorf("oversized update changed content to %d bytes", len(got))
+	}
+}
+
+func TestSendAgentMessageWithFile(t *testing.T) {
+	agents := map[string]config.AgentDefinition{"a1": {Workspace: "ws1"}}
+	s, files := newFilesTestServer(t, agents)
+	if err := s.registry.Update(agents, config.DefaultsConfig{FileFilter: config.FileFilterConfig{DeniedExtensions: []string{"exe"}}}); err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeAgents{}
+	s.agents = fake
+
+	post := func(text, name string, content []byte) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		_ = mw.WriteField("text", text)
+		_ = mw.WriteField("override_env", `{"DEBUG":"1"}`)
+		if name != "" {
+			fw, _ := mw.CreateFormFile("file", name)
+			_, _ = fw.Write(content)
+		}
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/agents/definitions/a1/messages", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.SetPathValue("id", "a1")
+		rec := httptest.NewRecorder()
+		s.sendAgentMessage(rec, req)
+		return rec
+	}
+
+	rec := post("Summarize this", "../../re\tport.csv", []byte("a,b\n1,2\n"))
+	var resp struct {
+		Status string `json:"status"`
+		Path   string `json:"path"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("upload: status %d, %v", rec.Code, err)
+	}
+	volumePath, ok := strings.CutPrefix(resp.Path, "/workspace/agent/")
+	if !ok || !strings.HasPrefix(volumePath, "uploads/") || !strings.HasSuffix(volumePath, "_report.csv") {
+		t.Fatalf("path = %q, want /workspace/agent/uploads/<ts>_report.csv", resp.Path)
+	}
+	if got := files["ws1/"+volumePath]; got != "a,b\n1,2\n" {
+		t.Errorf("workspace file = %q, want the upload", got)
+	}
+	if len(fake.sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(fake.sent))
+	}
+	msg := fake.sent[0]
+	if msg.meta["attachment_path"] != resp.Path || msg.meta["override_env.DEBUG"] != "1" {
+		t.Errorf("meta = %v, want attachment_path %s and the env override", msg.meta, resp.Path)
+	}
+	if !strings.HasPrefix(msg.text, "Summarize this\n\n[File received: report.csv (") || !strings.Contains(msg.text, "saved to "+resp.Path+"]") {
+		t.Errorf("text = %q, want the message followed by the file notice", msg.text)
+	}
+
+	if rec := post("", "   ", []byte("x")); rec.Code != http.StatusBadRequest {
+		t.Errorf("blank file name: status = %d, want 400", rec.Code)
+	}
+	if rec := post("", "tool.exe", []byte("MZ")); rec.Code != http.StatusBadRequest {
+		t.Errorf("filtered type: status = %d, want 400", rec.Code)
+	}
+	if rec := post("", "big.bin", make([]byte, maxUploadBytes+1)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized file: status = %d, want 413", rec.Code)
+	}
+	if rec := post(" ", "", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("no text or file: status = %d, want 400", rec.Code)
+	}
+	if len(fake.sent) != 1 {
+		t.Errorf("rejected uploads sent %d more messages", len(fake.sent)-1)
+	}
+}
+
+func TestSafeModeReadOnly(t *testing.T) {
+	s := NewServer(nil, nil, nil, nil, nil, nil, config.WebConfig{}, nil, "test")
+	s.SetSafeMode(true)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := s.withMiddleware(ok)
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/api/agents", http.StatusOK},
+		{http.MethodPost, "/api/login", http.StatusOK},
+		{http.MethodPost, "/api/agents/definitions/general/messages", http.StatusServiceUnavailable},
+		{http.MethodPut, "/api/global-instructions", http.StatusServiceUnavailable},
+		{http.MethodDelete, "/api/tasks/t1", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+
+	s.SetSafeMode(false)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/agents/definitions/general/messages", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("POST outside safe mode = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/mtzanidakis/praktor/internal/agent"
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/natsbus"
+	"github.com/mtzanidakis/praktor/internal/schedule"
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/vault"
+)
+
+// errorStatuses maps the sentinel errors of the packages behind the API to
+// HTTP status codes. The first match wins.
+var errorStatuses = []struct {
+	err  error
+	code int
+}{
+	{agent.ErrAgentNotFound, http.StatusNotFound},
+	{agent.ErrNothingToReplay, http.StatusNotFound},
+	{agent.ErrGroupNotFound, http.StatusNotFound},
+	{agent.ErrArtifactNotFound, http.StatusNotFound},
+	{store.ErrNotFound, http.StatusNotFound},
+	{schedule.ErrScheduleInvalid, http.StatusBadRequest},
+	{container.ErrSnapshotMismatch, http.StatusBadRequest},
+	{ErrPathDenied, http.StatusForbidden},
+	{agent.ErrWorkspaceOverQuota, http.StatusConflict},
+	{ErrReloadInProgress, http.StatusConflict},
+	{agent.ErrRestoreInProgress, http.StatusConflict},
+	{container.ErrFileTooLarge, http.StatusRequestEntityTooLarge},
+	{agent.ErrMessageTooLarge, http.StatusRequestEntityTooLarge},
+	{agent.ErrRateLimited, http.StatusTooManyRequests},
+	{agent.ErrSafeMode, http.StatusServiceUnavailable},
+	{container.ErrMaxContainers, http.StatusServiceUnavailable},
+	{container.ErrImageNotFound, http.StatusServiceUnavailable},
+	{container.ErrImageArchMismatch, http.StatusServiceUnavailable},
+	{vault.ErrVaultLocked, http.StatusServiceUnavailable},
+	{natsbus.ErrReadyTimeout, http.StatusGatewayTimeout},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout},
+}
+
+// errorStatus returns the HTTP status code for err, 500 for anything
+// unrecognized.
+func errorStatus(err error) int {
+	for _, e := range errorStatuses {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError responds with err's message and the status code it maps to.
+func writeError(w http.ResponseWriter, err error) {
+	jsonError(w, err.Error(), errorStatus(err))
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mtzanidakis/praktor/internal/agent"
+	"github.com/mtzanidakis/praktor/internal/container"
+	"github.com/mtzanidakis/praktor/internal/schedule"
+	"github.com/mtzanidakis/praktor/internal/store"
+	"github.com/mtzanidakis/praktor/internal/swarm"
+	"github.com/mtzanidakis/praktor/internal/vault"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: coder", agent.ErrAgentNotFound), http.StatusNotFound},
+		{fmt.Errorf("task t1: %w", store.ErrNotFound), http.StatusNotFound},
+		{fmt.Errorf("%w: bad cron", schedule.ErrScheduleInvalid), http.StatusBadRequest},
+		{fmt.Errorf("%w: coder", agent.ErrRateLimited), http.StatusTooManyRequests},
+		{fmt.Errorf("%w: .git/config", ErrPathDenied), http.StatusForbidden},
+		{ErrReloadInProgress, http.StatusConflict},
+		{fmt.Errorf("%w: coder", agent.ErrRestoreInProgress), http.StatusConflict},
+		{fmt.Errorf("start agent: %w (5)", container.ErrMaxContainers), http.StatusServiceUnavailable},
+		{fmt.Errorf("start agent: %w: praktor-agent:latest", container.ErrImageNotFound), http.StatusServiceUnavailable},
+		{fmt.Errorf("start agent: %w", &container.ImageArchError{Image: "praktor-agent:latest", ImageArch: "amd64", HostArch: "arm64"}), http.StatusServiceUnavailable},
+		{fmt.Errorf("encryption failed: %w", vault.ErrVaultLocked), http.StatusServiceUnavailable},
+		{errors.New("disk on fire"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := errorStatus(tt.err); got != tt.want {
+			t.Errorf("errorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestCreateSwarmGraphError(t *testing.T) {
+	body := `{"task":"t","agents":[{"agent_id":"a","role":"a"},{"agent_id":"b","role":"b"}],"synapses":[{"from":"a","to":"c"}]}`
+	rec := httptest.NewRecorder()
+	(&Server{}).createSwarm(rec, httptest.NewRequest(http.MethodPost, "/api/swarms", strings.NewReader(body)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	var resp struct {
+		Error string            `json:"error"`
+		Graph *swarm.GraphError `json:"graph"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Graph == nil || resp.Graph.Kind != swarm.GraphErrUnknownRole || resp.Graph.Role != "c" ||
+		resp.Graph.Edge == nil || resp.Graph.Edge.From != "a" {
+		t.Errorf("graph error = %+v", resp.Graph)
+	}
+	if !strings.HasPrefix(resp.Error, "invalid swarm graph: ") {
+		t.Errorf("error = %q", resp.Error)
+	}
+}
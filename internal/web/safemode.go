@@ -0,0 +1,31 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// safeModeMessage is the error for requests and socket commands refused in
+// safe mode.
+const safeModeMessage = "praktor is in safe mode: the API is read-only"
+
+// SetSafeMode makes the API read-only: while the gateway runs in safe mode
+// only reads, login and logout are served, the WebSocket only tails logs,
+// and /api/status reports
+// safe_mode so the UI can show a banner.
+func (s *Server) SetSafeMode(on bool) {
+	s.safeMode = on
+}
+
+// blockedBySafeMode reports whether r changes state and must be refused in
+// safe mode.
+func (s *Server) blockedBySafeMode(r *http.Request) bool {
+	if !s.safeMode || !strings.HasPrefix(r.URL.Path, "/api/") {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return false
+	}
+	return r.URL.Path != "/api/login" && r.URL.Path != "/api/logout"
+}
@@ -16,6 +16,8 @@ import (
 
 	"github.com/mtzanidakis/praktor/internal/agent"
 	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/events"
+	"github.com/mtzanidakis/praktor/internal/logring"
 	"github.com/mtzanidakis/praktor/internal/natsbus"
 	"github.com/mtzanidakis/praktor/internal/registry"
 	"github.com/mtzanidakis/praktor/internal/router"
@@ -38,14 +40,19 @@ type Server struct {
 	bus        *natsbus.Bus
 	nats       *natsbus.Client
 	orch       *agent.Orchestrator
+	agents     agentController // orch; replaced in tests
+	files      volumeFiles     // orch; replaced in tests
 	registry   *registry.Registry
 	router     *router.Router
 	swarmCoord *swarm.Coordinator
 	vault      *vault.Vault
+	reloader   ConfigReloader
+	logs       *logring.Ring
 	hub        *Hub
 	cfg        config.WebConfig
 	version    string
 	startedAt  time.Time
+	safeMode   bool // read-only API; see SetSafeMode
 
 	sessionMu sync.Mutex
 	sessions  map[string]time.Time // token → expiry
@@ -56,6 +63,8 @@ func NewServer(s *store.Store, bus *natsbus.Bus, orch *agent.Orchestrator, reg *
 		store:      s,
 		bus:        bus,
 		orch:       orch,
+		agents:     orch,
+		files:      orch,
 		registry:   reg,
 		router:     rtr,
 		swarmCoord: swarmCoord,
@@ -81,6 +90,9 @@ func (s *Server) Start(ctx context.Context) error {
 	mux.HandleFunc("POST /api/logout", s.handleLogout)
 	mux.HandleFunc("GET /api/auth/check", s.handleAuthCheck)
 
+	// Readiness probe (public)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+
 	// API routes
 	s.registerAPI(mux)
 
@@ -101,20 +113,7 @@ func (s *Server) Start(ctx context.Context) error {
 		fileServer.ServeHTTP(w, r)
 	})
 
-	handler := s.withMiddleware(mux)
-	addr := fmt.Sprintf(":%d", s.cfg.Port)
-	server := &http.Server{Addr: addr, Handler: handler}
-
-	go func() {
-		<-ctx.Done()
-		_ = server.Close()
-	}()
-
-	slog.Info("web server listening", "addr", addr)
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
-		return err
-	}
-	return nil
+	return s.serve(ctx, s.withMiddleware(mux))
 }
 
 func (s *Server) withMiddleware(next http.Handler) http.Handler {
@@ -141,6 +140,11 @@ func (s *Server) withMiddleware(next http.Handler) http.Handler {
 			}
 		}
 
+		if s.blockedBySafeMode(r) {
+			jsonError(w, safeModeMessage, http.StatusServiceUnavailable)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -195,6 +199,7 @@ func (s *Server) setSessionCookie(w http.ResponseWriter, token string) {
 		Path:     "/",
 		MaxAge:   int(sessionMaxAge.Seconds()),
 		HttpOnly: true,
+		Secure:   s.cfg.TLS != nil,
 		SameSite: http.SameSiteStrictMode,
 	})
 }
@@ -242,6 +247,7 @@ func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 		Path:     "/",
 		MaxAge:   -1,
 		HttpOnly: true,
+		Secure:   s.cfg.TLS != nil,
 		SameSite: http.SameSiteStrictMode,
 	})
 	jsonResponse(w, map[string]string{"status": "ok"})
@@ -286,12 +292,27 @@ func (s *Server) subscribeEvents() {
 	s.nats = client
 
 	// Forward all event topics to WebSocket as raw JSON
-	_, _ = client.Subscribe(natsbus.TopicEventsAll, func(msg *nats.Msg) {
-		var event Event
-		if err := json.Unmarshal(msg.Data, &event); err != nil {
-			slog.Warn("invalid NATS event payload", "error", err)
+	_, err = client.Subscribe(natsbus.TopicEventsAll(), func(msg *nats.Msg) {
+		var header events.Header
+		if err := json.Unmarshal(msg.Data, &header); err != nil || header.Type == "" {
+			slog.Warn("invalid NATS event payload", "subject", msg.Subject, "error", err)
 			return
 		}
-		s.hub.Broadcast(event)
+		s.hub.Broadcast(msg.Data)
 	})
+	if err != nil {
+		slog.Error("web server nats subscribe failed", "error", err)
+	}
+}
+
+// handleReadyz reports whether the server can serve traffic: 200 while the
+// NATS connection is up, 503 while it is down or reconnecting.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if s.nats == nil || !s.nats.Connected() {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "nats disconnected"})
+		return
+	}
+	jsonResponse(w, map[string]string{"status": "ok"})
 }
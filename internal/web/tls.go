@@ -0,0 +1,94 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// serve runs handler on web.port until ctx is done: plain HTTP, or HTTPS
+// when web.tls is set, plus the optional HTTP→HTTPS redirect listener.
+func (s *Server) serve(ctx context.Context, handler http.Handler) error {
+	addr := fmt.Sprintf(":%d", s.cfg.Port)
+	server := &http.Server{Addr: addr, Handler: handler}
+	tlsCfg := s.cfg.TLS
+
+	var redirect *http.Server
+	if tlsCfg != nil {
+		var acme *autocert.Manager
+		if tlsCfg.ACME != nil {
+			acme = &autocert.Manager{
+				Prompt:     autocert.AcceptTOS,
+				Cache:      autocert.DirCache(tlsCfg.ACME.CacheDir),
+				HostPolicy: autocert.HostWhitelist(tlsCfg.ACME.Domain),
+				Email:      tlsCfg.ACME.Email,
+			}
+			server.TLSConfig = acme.TLSConfig()
+		}
+		if tlsCfg.HTTPPort != 0 {
+			h := httpsRedirect(s.cfg.Port)
+			if acme != nil {
+				h = acme.HTTPHandler(h)
+			}
+			redirect = &http.Server{
+				Addr:              fmt.Sprintf(":%d", tlsCfg.HTTPPort),
+				Handler:           h,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			go func() {
+				slog.Info("web redirect listening", "addr", redirect.Addr)
+				if err := redirect.ListenAndServe(); err != http.ErrServerClosed {
+					slog.Error("web redirect listener error", "error", err)
+				}
+			}()
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+		if redirect != nil {
+			_ = redirect.Close()
+		}
+	}()
+
+	var err error
+	if tlsCfg != nil {
+		slog.Info("web server listening", "addr", addr, "tls", true, "acme", tlsCfg.ACME != nil)
+		// With ACME the certificate comes from TLSConfig, so no files are named.
+		err = server.ListenAndServeTLS(tlsCfg.CertFile, tlsCfg.KeyFile)
+	} else {
+		slog.Info("web server listening", "addr", addr)
+		err = server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// httpsRedirect permanently redirects every request to the same host and
+// path on the HTTPS port.
+func httpsRedirect(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]")
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
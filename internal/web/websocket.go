@@ -5,30 +5,73 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 
 	"github.com/gorilla/websocket"
+	"github.com/mtzanidakis/praktor/internal/events"
+	"golang.org/x/time/rate"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+var upgrader = websocket.Upgrader{CheckOrigin: sameOrigin}
+
+// sameOrigin allows the upgrade from the dashboard itself, or from clients
+// that send no Origin (CLIs, scripts). A page on another site could
+// otherwise drive agents through the operator's session cookie.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
 }
 
+const (
+	wsMaxCommandBytes = 64 << 10
+	wsCommandRate     = rate.Limit(1) // commands per second per connection
+	wsCommandBurst    = 10
+)
+
+// Event is a message the server writes to one socket, such as a command
+// reply or a log line. Bus events are forwarded as published.
 type Event struct {
+	V       int    `json:"v"`
 	Type    string `json:"type"`
 	Payload any    `json:"payload"`
 }
 
+func newEvent(eventType string, payload any) Event {
+	return Event{V: events.Version, Type: eventType, Payload: payload}
+}
+
+// wsClient serializes writes to one connection: hub broadcasts and command
+// replies come from different goroutines.
+type wsClient struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (c *wsClient) write(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
 type Hub struct {
-	clients   map[*websocket.Conn]bool
-	broadcast chan Event
+	clients   map[*websocket.Conn]*wsClient
+	broadcast chan []byte
 	mu        sync.RWMutex
 }
 
 func NewHub() *Hub {
 	return &Hub{
-		clients:   make(map[*websocket.Conn]bool),
-		broadcast: make(chan Event, 256),
+		clients:   make(map[*websocket.Conn]*wsClient),
+		broadcast: make(chan []byte, 256),
 	}
 }
 
@@ -37,36 +80,38 @@ func (h *Hub) Run(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
-		case event := <-h.broadcast:
-			data, err := json.Marshal(event)
-			if err != nil {
-				continue
-			}
-
+		case data := <-h.broadcast:
+			var failed []*websocket.Conn
 			h.mu.RLock()
-			for client := range h.clients {
-				if err := client.WriteMessage(websocket.TextMessage, data); err != nil {
-					_ = client.Close()
-					delete(h.clients, client)
+			for conn, client := range h.clients {
+				if err := client.write(data); err != nil {
+					failed = append(failed, conn)
 				}
 			}
 			h.mu.RUnlock()
+			for _, conn := range failed {
+				_ = conn.Close()
+				h.Unregister(conn)
+			}
 		}
 	}
 }
 
-func (h *Hub) Broadcast(event Event) {
+// Broadcast writes an encoded event to every connected socket.
+func (h *Hub) Broadcast(data []byte) {
 	select {
-	case h.broadcast <- event:
+	case h.broadcast <- data:
 	default:
 		slog.Warn("websocket broadcast channel full, dropping event")
 	}
 }
 
-func (h *Hub) Register(conn *websocket.Conn) {
+func (h *Hub) Register(conn *websocket.Conn) *wsClient {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.clients[conn] = true
+	client := &wsClient{conn: conn}
+	h.clients[conn] = client
+	return client
 }
 
 func (h *Hub) Unregister(conn *websocket.Conn) {
@@ -75,6 +120,29 @@ func (h *Hub) Unregister(conn *websocket.Conn) {
 	delete(h.clients, conn)
 }
 
+// agentController is the part of the orchestrator that socket commands
+// drive.
+type agentController interface {
+	HandleMessage(ctx context.Context, agentID, text string, meta map[string]string) error
+	AbortSession(ctx context.Context, agentID string) error
+	ClearSession(ctx context.Context, agentID string) error
+}
+
+// wsCommand is a client → server message. ID is echoed back in the
+// command_result event so the client can match replies.
+type wsCommand struct {
+	ID    string `json:"id,omitempty"`
+	Cmd   string `json:"cmd"`
+	Agent string `json:"agent"`
+	Text  string `json:"text,omitempty"`
+
+	// tail_logs filter; Text is the search query.
+	Level     string `json:"level,omitempty"`
+	Component string `json:"component,omitempty"`
+}
+
+// The socket sits under /api/, so withMiddleware has already checked the
+// session cookie (or Basic Auth) before the upgrade.
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -82,17 +150,94 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.hub.Register(conn)
+	client := s.hub.Register(conn)
+	var stopTail func()
 	defer func() {
+		if stopTail != nil {
+			stopTail()
+		}
 		s.hub.Unregister(conn)
 		_ = conn.Close()
 	}()
 
-	// Keep connection alive, read messages (for future client → server)
+	conn.SetReadLimit(wsMaxCommandBytes)
+	limiter := rate.NewLimiter(wsCommandRate, wsCommandBurst)
 	for {
-		_, _, err := conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		var cmd wsCommand
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			s.replyCommand(client, cmd, "invalid command")
+			continue
+		}
+		if !limiter.Allow() {
+			s.replyCommand(client, cmd, "rate limited, slow down")
+			continue
+		}
+		switch cmd.Cmd {
+		case "tail_logs", "untail_logs":
+			// One tail per socket; tail_logs again replaces the filter.
+			if stopTail != nil {
+				stopTail()
+				stopTail = nil
+			}
+			errText := ""
+			if cmd.Cmd == "tail_logs" {
+				var err error
+				if stopTail, err = s.tailLogs(client, cmd); err != nil {
+					errText = err.Error()
+				}
+			}
+			s.replyCommand(client, cmd, errText)
+		default:
+			s.replyCommand(client, cmd, s.dispatchCommand(r.Context(), cmd))
+		}
+	}
+}
+
+// dispatchCommand runs one socket command and returns the error text to
+// report, or "" on success. Every command changes state, so none run in
+// safe mode.
+func (s *Server) dispatchCommand(ctx context.Context, cmd wsCommand) string {
+	if s.safeMode {
+		return safeModeMessage
+	}
+	if _, ok := s.registry.GetDefinition(cmd.Agent); !ok {
+		return "agent not found"
+	}
+	// A queued message outlives the socket, so don't tie it to its context.
+	ctx = context.WithoutCancel(ctx)
+
+	var err error
+	switch cmd.Cmd {
+	case "send_message":
+		if strings.TrimSpace(cmd.Text) == "" {
+			return "text is required"
+		}
+		err = s.agents.HandleMessage(ctx, cmd.Agent, cmd.Text, map[string]string{"sender": "user", "source": "web"})
+	case "abort":
+		err = s.agents.AbortSession(ctx, cmd.Agent)
+	case "clear":
+		err = s.agents.ClearSession(ctx, cmd.Agent)
+	default:
+		return "unknown command: " + cmd.Cmd
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func (s *Server) replyCommand(client *wsClient, cmd wsCommand, errText string) {
+	payload := map[string]string{"id": cmd.ID, "cmd": cmd.Cmd, "agent": cmd.Agent, "status": "ok"}
+	if errText != "" {
+		payload["status"] = "error"
+		payload["error"] = errText
+	}
+	data, _ := json.Marshal(newEvent("command_result", payload))
+	if err := client.write(data); err != nil {
+		slog.Debug("websocket command reply failed", "error", err)
 	}
 }
@@ -0,0 +1,265 @@
+package web
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mtzanidakis/praktor/internal/config"
+	"github.com/mtzanidakis/praktor/internal/logring"
+	"github.com/mtzanidakis/praktor/internal/registry"
+	"github.com/mtzanidakis/praktor/internal/store"
+)
+
+type sentMessage struct {
+	agentID, text string
+	meta          map[string]string
+}
+
+// fakeAgents records the commands dispatched from the socket.
+type fakeAgents struct {
+	mu      sync.Mutex
+	sent    []sentMessage
+	aborted []string
+}
+
+func (f *fakeAgents) HandleMessage(ctx context.Context, agentID, text string, meta map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, sentMessage{agentID, text, meta})
+	return nil
+}
+
+func (f *fakeAgents) AbortSession(ctx context.Context, agentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.aborted = append(f.aborted, agentID)
+	return nil
+}
+
+func (f *fakeAgents) ClearSession(ctx context.Context, agentID string) error { return nil }
+
+func newWSTestServer(t *testing.T) (*Server, *fakeAgents, string) {
+	t.Helper()
+	dir := t.TempDir()
+	st, err := store.New(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	reg := registry.New(st, map[string]config.AgentDefinition{"general": {Workspace: "general"}}, config.DefaultsConfig{}, filepath.Join(dir, "agents"))
+	if err := reg.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeAgents{}
+	s := NewServer(st, nil, nil, reg, nil, nil, config.WebConfig{Auth: "pw"}, nil, "test")
+	s.agents = fake
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/ws", s.handleWebSocket)
+	ts := httptest.NewServer(s.withMiddleware(mux))
+	t.Cleanup(ts.Close)
+	return s, fake, "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+}
+
+func dialWS(t *testing.T, s *Server, url string) *websocket.Conn {
+	t.Helper()
+	token, err := s.createSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{"Cookie": {sessionCookieName + "=" + token}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func readResult(t *testing.T, conn *websocket.Conn) map[string]string {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var ev struct {
+		Type    string            `json:"type"`
+		Payload map[string]string `json:"payload"`
+	}
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if ev.Type != "command_result" {
+		t.Fatalf("event type = %q, want command_result", ev.Type)
+	}
+	return ev.Payload
+}
+
+func TestWebSocketSendMessage(t *testing.T) {
+	s, fake, url := newWSTestServer(t)
+	conn := dialWS(t, s, url)
+
+	if err := conn.WriteJSON(map[string]string{"id": "1", "cmd": "send_message", "agent": "general", "text": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if res := readResult(t, conn); res["status"] != "ok" || res["id"] != "1" {
+		t.Fatalf("result = %v", res)
+	}
+	fake.mu.Lock()
+	sent := fake.sent
+	fake.mu.Unlock()
+	if len(sent) != 1 || sent[0].agentID != "general" || sent[0].text != "hello" || sent[0].meta["source"] != "web" {
+		t.Errorf("orchestrator got %+v", sent)
+	}
+
+	for _, cmd := range []map[string]string{
+		{"cmd": "send_message", "agent": "nope", "text": "hi"},
+		{"cmd": "send_message", "agent": "general", "text": "  "},
+		{"cmd": "reboot", "agent": "general"},
+	} {
+		if err := conn.WriteJSON(cmd); err != nil {
+			t.Fatal(err)
+		}
+		if res := readResult(t, conn); res["status"] != "error" {
+			t.Errorf("%v: result = %v, want error", cmd, res)
+		}
+	}
+
+	if err := conn.WriteJSON(map[string]string{"cmd": "abort", "agent": "general"}); err != nil {
+		t.Fatal(err)
+	}
+	if res := readResult(t, conn); res["status"] != "ok" {
+		t.Errorf("abort result = %v", res)
+	}
+	if len(fake.aborted) != 1 {
+		t.Errorf("aborted = %v", fake.aborted)
+	}
+}
+
+func TestWebSocketSafeMode(t *testing.T) {
+	s, fake, url := newWSTestServer(t)
+	s.SetSafeMode(true)
+	conn := dialWS(t, s, url)
+
+	for _, cmd := range []map[string]string{
+		{"cmd": "send_message", "agent": "general", "text": "hi"},
+		{"cmd": "abort", "agent": "general"},
+		{"cmd": "clear", "agent": "general"},
+	} {
+		if err := conn.WriteJSON(cmd); err != nil {
+			t.Fatal(err)
+		}
+		if res := readResult(t, conn); res["status"] != "error" || res["error"] != safeModeMessage {
+			t.Errorf("%v: result = %v, want the safe mode error", cmd, res)
+		}
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.sent) != 0 || len(fake.aborted) != 0 {
+		t.Errorf("dispatched in safe mode: sent %v, aborted %v", fake.sent, fake.aborted)
+	}
+}
+
+func TestWebSocketRateLimit(t *testing.T) {
+	s, fake, url := newWSTestServer(t)
+	conn := dialWS(t, s, url)
+
+	var limited int
+	for range wsCommandBurst + 5 {
+		if err := conn.WriteJSON(map[string]string{"cmd": "send_message", "agent": "general", "text": "x"}); err != nil {
+			t.Fatal(err)
+		}
+		if res := readResult(t, conn); res["status"] == "error" && strings.Contains(res["error"], "rate limited") {
+			limited++
+		}
+	}
+	if limited == 0 {
+		t.Error("expected commands past the burst to be rate limited")
+	}
+	if len(fake.sent) > wsCommandBurst+1 {
+		t.Errorf("%d messages dispatched, want at most %d", len(fake.sent), wsCommandBurst+1)
+	}
+}
+
+func TestWebSocketRequiresAuth(t *testing.T) {
+	_, _, url := newWSTestServer(t)
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("expected unauthenticated dial to fail")
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("response = %v, want 401", resp)
+	}
+}
+
+func TestWebSocketChecksOrigin(t *testing.T) {
+	s, _, url := newWSTestServer(t)
+	token, err := s.createSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := strings.TrimPrefix(strings.TrimSuffix(url, "/api/ws"), "ws://")
+	for origin, ok := range map[string]bool{
+		"":                       true,
+		"http://" + host:         true,
+		"https://evil.example":   false,
+		"http://" + host + ".io": false,
+	} {
+		header := http.Header{"Cookie": {sessionCookieName + "=" + token}}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+		if ok && err != nil {
+			t.Errorf("origin %q: dial failed: %v", origin, err)
+		}
+		if !ok && (err == nil || resp == nil || resp.StatusCode != http.StatusForbidden) {
+			t.Errorf("origin %q: response = %v, want 403", origin, resp)
+		}
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}
+}
+
+func TestWebSocketTailLogs(t *testing.T) {
+	s, _, url := newWSTestServer(t)
+	ring := logring.New(10)
+	s.SetLogRing(ring)
+	log := slog.New(ring.Handler(slog.NewTextHandler(io.Discard, nil)))
+	conn := dialWS(t, s, url)
+
+	if err := conn.WriteJSON(map[string]string{"cmd": "tail_logs", "level": "nope"}); err != nil {
+		t.Fatal(err)
+	}
+	if res := readResult(t, conn); res["status"] != "error" {
+		t.Fatalf("bad level result = %v", res)
+	}
+	if err := conn.WriteJSON(map[string]string{"cmd": "tail_logs", "level": "warn"}); err != nil {
+		t.Fatal(err)
+	}
+	if res := readResult(t, conn); res["status"] != "ok" {
+		t.Fatalf("tail result = %v", res)
+	}
+
+	log.Info("quiet")
+	log.Warn("loud", "api_key", "sk-123")
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var ev struct {
+		Type    string        `json:"type"`
+		Payload logring.Entry `json:"payload"`
+	}
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if ev.Type != "log" || ev.Payload.Message != "loud" || ev.Payload.Attrs["api_key"] != logring.Redacted {
+		t.Errorf("event = %+v", ev)
+	}
+}
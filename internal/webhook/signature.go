@@ -0,0 +1,169 @@
+// Package webhook verifies HMAC signatures of inbound webhook payloads.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature schemes.
+const (
+	SchemeGitHub = "github" // X-Hub-Signature-256: sha256=<hex>
+	SchemeStripe = "stripe" // Stripe-Signature: t=<unix>,v1=<hex>[,v1=<hex>...]
+)
+
+const (
+	// DefaultTolerance is how old a Stripe signature timestamp may be.
+	DefaultTolerance = 5 * time.Minute
+
+	maxBodyBytes = 1 << 20
+)
+
+// ErrInvalidSignature is returned when a payload's signature is missing,
+// malformed or doesn't match.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Verifier checks payloads signed with HMAC-SHA256 over the raw body.
+type Verifier struct {
+	Scheme string `yaml:"scheme"` // github or stripe
+	Secret string `yaml:"secret"`
+	Header string `yaml:"header"` // defaults to the scheme's header
+
+	// Tolerance bounds the age of timestamped (Stripe) signatures;
+	// 0 means DefaultTolerance.
+	Tolerance time.Duration `yaml:"tolerance"`
+
+	now func() time.Time // replaced in tests
+}
+
+// Validate checks the scheme and that a secret is set.
+func (v Verifier) Validate() error {
+	switch v.Scheme {
+	case SchemeGitHub, SchemeStripe:
+	default:
+		return fmt.Errorf("unknown signature scheme %q (want %s or %s)", v.Scheme, SchemeGitHub, SchemeStripe)
+	}
+	if v.Secret == "" {
+		return fmt.Errorf("signature secret is required")
+	}
+	return nil
+}
+
+// HeaderName returns the header carrying the signature.
+func (v Verifier) HeaderName() string {
+	if v.Header != "" {
+		return v.Header
+	}
+	if v.Scheme == SchemeStripe {
+		return "Stripe-Signature"
+	}
+	return "X-Hub-Signature-256"
+}
+
+// Verify checks the signature in h against body. Every failure wraps
+// ErrInvalidSignature.
+func (v Verifier) Verify(h http.Header, body []byte) error {
+	sig := h.Get(v.HeaderName())
+	if sig == "" {
+		return fmt.Errorf("%w: missing %s header", ErrInvalidSignature, v.HeaderName())
+	}
+	switch v.Scheme {
+	case SchemeGitHub:
+		return v.verifyGitHub(sig, body)
+	case SchemeStripe:
+		return v.verifyStripe(sig, body)
+	default:
+		return fmt.Errorf("%w: unknown scheme %q", ErrInvalidSignature, v.Scheme)
+	}
+}
+
+// Middleware rejects requests whose body isn't validly signed with 401 and
+// passes the others on with the body restored.
+func (v Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := v.Verify(r.Header, body); err != nil {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v Verifier) verifyGitHub(sig string, body []byte) error {
+	hexSig, ok := strings.CutPrefix(sig, "sha256=")
+	if !ok {
+		return fmt.Errorf("%w: expected sha256=<hex>", ErrInvalidSignature)
+	}
+	if !v.matches(hexSig, body) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// verifyStripe checks a "t=<unix>,v1=<hex>" header, signed over
+// "<t>.<body>". Any of several v1 signatures may match, as during a
+// secret rotation.
+func (v Verifier) verifyStripe(sig string, body []byte) error {
+	var ts string
+	var sigs []string
+	for part := range strings.SplitSeq(sig, ",") {
+		k, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = val
+		case "v1":
+			sigs = append(sigs, val)
+		}
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return fmt.Errorf("%w: expected t=<unix>,v1=<hex>", ErrInvalidSignature)
+	}
+
+	tolerance := v.Tolerance
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	if age := now().Sub(time.Unix(sec, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	payload := append([]byte(ts+"."), body...)
+	for _, s := range sigs {
+		if v.matches(s, payload) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// matches reports whether hexSig is the HMAC-SHA256 of payload, comparing
+// in constant time.
+func (v Verifier) matches(hexSig string, payload []byte) bool {
+	got, err := hex.DecodeString(hexSig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(v.Secret))
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
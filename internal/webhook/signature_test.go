@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret = "whsec_test"
+	testBody   = `{"action":"opened","number":7}`
+)
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyGitHub(t *testing.T) {
+	v := Verifier{Scheme: SchemeGitHub, Secret: testSecret}
+	tests := []struct {
+		name, header, body string
+		ok                 bool
+	}{
+		{"valid", "sha256=" + sign(testSecret, testBody), testBody, true},
+		{"tampered body", "sha256=" + sign(testSecret, testBody), strings.Replace(testBody, "7", "8", 1), false},
+		{"wrong secret", "sha256=" + sign("other", testBody), testBody, false},
+		{"no prefix", sign(testSecret, testBody), testBody, false},
+		{"not hex", "sha256=zz", testBody, false},
+		{"missing", "", testBody, false},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.header != "" {
+			h.Set("X-Hub-Signature-256", tt.header)
+		}
+		err := v.Verify(h, []byte(tt.body))
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: err = %v, want ErrInvalidSignature", tt.name, err)
+		}
+	}
+}
+
+func TestVerifyStripe(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	v := Verifier{Scheme: SchemeStripe, Secret: testSecret, now: func() time.Time { return now }}
+	stripeHeader := func(ts int64, secret, body string) string {
+		return fmt.Sprintf("t=%d,v1=%s", ts, sign(secret, fmt.Sprintf("%d.%s", ts, body)))
+	}
+	ts := now.Unix()
+	tests := []struct {
+		name, header, body string
+		ok                 bool
+	}{
+		{"valid", stripeHeader(ts, testSecret, testBody), testBody, true},
+		{"rotated secret", stripeHeader(ts, "old", testBody) + ",v1=" + sign(testSecret, fmt.Sprintf("%d.%s", ts, testBody)), testBody, true},
+		{"tampered body", stripeHeader(ts, testSecret, testBody), testBody + " ", false},
+		{"tampered timestamp", strings.Replace(stripeHeader(ts, testSecret, testBody), fmt.Sprint(ts), fmt.Sprint(ts+1), 1), testBody, false},
+		{"too old", stripeHeader(ts-600, testSecret, testBody), testBody, false},
+		{"no timestamp", "v1=" + sign(testSecret, testBody), testBody, false},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		h.Set("Stripe-Signature", tt.header)
+		err := v.Verify(h, []byte(tt.body))
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: err = %v, want ErrInvalidSignature", tt.name, err)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	v := Verifier{Scheme: SchemeGitHub, Secret: testSecret, Header: "X-Signature"}
+	var got string
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	}))
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{testBody, http.StatusOK},
+		{testBody + "x", http.StatusUnauthorized},
+	} {
+		got = ""
+		r := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(tt.body))
+		r.Header.Set("X-Signature", "sha256="+sign(testSecret, testBody))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("body %q: status = %d, want %d", tt.body, w.Code, tt.want)
+		}
+		if tt.want == http.StatusOK && got != tt.body {
+			t.Errorf("handler read %q, want the restored body", got)
+		}
+		if tt.want != http.StatusOK && got != "" {
+			t.Errorf("handler ran for a rejected request")
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Verifier{Scheme: SchemeStripe, Secret: "s"}).Validate(); err != nil {
+		t.Errorf("valid verifier: %v", err)
+	}
+	if err := (Verifier{Scheme: "gitlab", Secret: "s"}).Validate(); err == nil {
+		t.Error("unknown scheme accepted")
+	}
+	if err := (Verifier{Scheme: SchemeGitHub}).Validate(); err == nil {
+		t.Error("empty secret accepted")
+	}
+}
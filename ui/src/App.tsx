@@ -142,6 +142,7 @@ function App() {
   });
   const [authState, setAuthState] = useState<'loading' | 'authenticated' | 'unauthenticated'>('loading');
   const [sidebarOpen, setSidebarOpen] = useState(false);
+  const [safeMode, setSafeMode] = useState(false);
 
   const closeSidebar = useCallback(() => setSidebarOpen(false), []);
 
@@ -165,6 +166,14 @@ function App() {
     });
   }, []);
 
+  useEffect(() => {
+    if (authState !== 'authenticated') return;
+    fetch('/api/status')
+      .then((res) => (res.ok ? res.json() : null))
+      .then((data) => setSafeMode(Boolean(data?.safe_mode)))
+      .catch(() => {});
+  }, [authState]);
+
   const toggleTheme = useCallback(() => {
     setTheme((t) => (t === 'dark' ? 'light' : 'dark'));
   }, []);
@@ -370,6 +379,20 @@ function App() {
         maxHeight: '100vh',
         minHeight: '100vh',
       }}>
+        {safeMode && (
+          <div role="alert" style={{
+            marginBottom: 24,
+            padding: '12px 16px',
+            borderRadius: 8,
+            border: '1px solid var(--amber)',
+            background: 'var(--amber-muted)',
+            color: 'var(--amber)',
+            fontSize: 15,
+            fontWeight: 600,
+          }}>
+            Safe mode — agents, scheduled tasks and background jobs are not running, and changes are disabled. Restart the gateway without --safe-mode to resume.
+          </div>
+        )}
         <Suspense fallback={null}>
           <Routes>
             <Route path="/" element={<Dashboard />} />
@@ -64,4 +64,8 @@ describe("swarmToLaunchData", () => {
       { from: "b", to: "c", bidirectional: true },
     ]);
   });
+
+  it("carries the failure policy over", () => {
+    expect(swarmToLaunchData({ ...baseSwarm, failure_policy: "best_effort" }).failure_policy).toBe("best_effort");
+  });
 });
@@ -23,8 +23,9 @@ export interface SwarmLaunchData {
   name: string;
   task: string;
   lead_agent: string;
-  agents: { agent_id: string; role: string; prompt: string; workspace: string }[];
+  agents: { agent_id: string; role: string; prompt: string; workspace: string; persist_workspace?: boolean }[];
   synapses: { from: string; to: string; bidirectional: boolean }[];
+  failure_policy?: string;
 }
 interface Props {
   onLaunch: (data: SwarmLaunchData) => void;
@@ -84,6 +85,7 @@ export default function SwarmGraph({ onLaunch, initialData, launchLabel }: Props
   const [selectedEdge, setSelectedEdge] = useState<number | null>(null);
   const [name, setName] = useState('');
   const [task, setTask] = useState('');
+  const [failurePolicy, setFailurePolicy] = useState('fail_fast');
   const [initialized, setInitialized] = useState(false);
 
   // Drag state
@@ -108,6 +110,7 @@ export default function SwarmGraph({ onLaunch, initialData, launchLabel }: Props
     if (initialized || !initialData || agents.length === 0) return;
     setName(initialData.name || '');
     setTask(initialData.task || '');
+    setFailurePolicy(initialData.failure_policy || 'fail_fast');
     // Build role→agent_id map from initial data
     const roleToId = new Map(initialData.agents.map((a) => [a.role, a.agent_id]));
     const initNodes: GraphNode[] = initialData.agents.map((a, i) => {
@@ -275,8 +278,9 @@ export default function SwarmGraph({ onLaunch, initialData, launchLabel }: Props
           bidirectional: e.bidirectional,
         };
       }),
+      failure_policy: failurePolicy,
     });
-  }, [nodes, edges, name, task, onLaunch]);
+  }, [nodes, edges, name, task, failurePolicy, onLaunch]);
 
   /* ── Helper: get edge path ── */
   const getEdgePath = (from: GraphNode, to: GraphNode) => {
@@ -516,6 +520,20 @@ export default function SwarmGraph({ onLaunch, initialData, launchLabel }: Props
             placeholder="Describe what this swarm should accomplish..."
           />
         </div>
+        <div>
+          <label style={{ fontSize: 14, color: 'var(--text-tertiary)', display: 'block', marginBottom: 4 }}>
+            On Agent Failure
+          </label>
+          <select
+            style={inputStyle}
+            value={failurePolicy}
+            onChange={(e) => setFailurePolicy(e.target.value)}
+          >
+            <option value="fail_fast">Stop the swarm</option>
+            <option value="continue">Continue, skip the lead</option>
+            <option value="best_effort">Continue, lead uses what succeeded</option>
+          </select>
+        </div>
 
         {/* Selected node properties */}
         {selectedNodeObj && (
@@ -1,6 +1,7 @@
 import { useState, useEffect, useRef, useCallback } from 'react';
 
 interface WsEvent {
+  v: number; // schema version (internal/events.Version)
   type: string;
   agent_id?: string;
   data: unknown;
@@ -9,6 +10,13 @@ interface WsEvent {
 
 type ConnectionStatus = 'connecting' | 'connected' | 'disconnected';
 
+// Commands the server dispatches to the orchestrator. Replies arrive as
+// `command_result` events carrying the same id.
+export type WsCommand =
+  | { id?: string; cmd: 'send_message'; agent: string; text: string }
+  | { id?: string; cmd: 'abort'; agent: string }
+  | { id?: string; cmd: 'clear'; agent: string };
+
 export function useWebSocket() {
   const [events, setEvents] = useState<WsEvent[]>([]);
   const [status, setStatus] = useState<ConnectionStatus>('disconnected');
@@ -57,5 +65,13 @@ export function useWebSocket() {
 
   const clearEvents = useCallback(() => setEvents([]), []);
 
-  return { events, status, clearEvents };
+  // Returns false when the socket isn't open, so callers can fall back to HTTP.
+  const sendCommand = useCallback((command: WsCommand): boolean => {
+    const ws = wsRef.current;
+    if (ws?.readyState !== WebSocket.OPEN) return false;
+    ws.send(JSON.stringify(command));
+    return true;
+  }, []);
+
+  return { events, status, clearEvents, sendCommand };
 }
@@ -9,12 +9,26 @@ interface Agent {
   model?: string;
   image?: string;
   workspace?: string;
+  workspace_bytes?: number;
+  tags?: string[];
   agent_status?: string;
   default_agent?: boolean;
   message_count?: number;
   last_active?: string;
 }
 
+function formatBytes(n: number): string {
+  if (n < 1024) return `${n} B`;
+  const units = ['KB', 'MB', 'GB', 'TB'];
+  let v = n / 1024;
+  let i = 0;
+  while (v >= 1024 && i < units.length - 1) {
+    v /= 1024;
+    i++;
+  }
+  return `${v.toFixed(v < 10 ? 1 : 0)} ${units[i]}`;
+}
+
 const card: React.CSSProperties = {
   background: 'var(--bg-card)',
   border: '1px solid var(--border)',
@@ -157,6 +171,18 @@ function Agents() {
             {agent.model && (
               <div style={{ fontSize: 14, color: 'var(--text-muted)', marginBottom: 4 }}>Model: {agent.model}</div>
             )}
+            {agent.tags && agent.tags.length > 0 && (
+              <div style={{ display: 'flex', flexWrap: 'wrap', gap: 4, marginBottom: 6 }}>
+                {agent.tags.map((tag) => (
+                  <span
+                    key={tag}
+                    style={{ fontSize: 13, padding: '1px 8px', borderRadius: 999, background: 'var(--accent-muted)', color: 'var(--accent)' }}
+                  >
+                    {tag}
+                  </span>
+                ))}
+              </div>
+            )}
             <div style={{ display: 'flex', justifyContent: 'space-between', fontSize: 15, color: 'var(--text-tertiary)' }}>
               <span>{agent.message_count ?? 0} messages</span>
               {agent.last_active && <span>{agent.last_active}</span>}
@@ -195,7 +221,10 @@ function Agents() {
               {selected.workspace && (
                 <div>
                   <span style={{ color: 'var(--text-tertiary)' }}>Workspace: </span>
-                  <span style={{ color: 'var(--text-primary)' }}>{selected.workspace}</span>
+                  <span style={{ color: 'var(--text-primary)' }}>
+                    {selected.workspace}
+                    {selected.workspace_bytes !== undefined && ` (${formatBytes(selected.workspace_bytes)})`}
+                  </span>
                 </div>
               )}
               <div>
@@ -12,6 +12,7 @@ interface Message {
   text: string;
   time: string;
   terminal_reason?: string;
+  reply_to?: string;
 }
 
 const card: React.CSSProperties = {
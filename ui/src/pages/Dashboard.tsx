@@ -7,6 +7,7 @@ interface StatusData {
   active_agents?: number;
   agents_count?: number;
   pending_tasks?: number;
+  secret_decrypt_failures?: number;
   recent_messages?: { id: string; agent: string; role: string; text: string; time: string; terminal_reason?: string }[];
 }
 
@@ -90,6 +91,12 @@ function Dashboard() {
         )}
       </div>
 
+      {(status.secret_decrypt_failures ?? 0) > 0 && (
+        <div style={{ ...card, marginBottom: 20, color: 'var(--red-light)', borderColor: 'var(--red-light)' }}>
+          {status.secret_decrypt_failures} {status.secret_decrypt_failures === 1 ? 'secret' : 'secrets'} failed to decrypt — check the vault passphrase.
+        </div>
+      )}
+
       <div className="stats-grid" style={{ display: 'grid', gridTemplateColumns: 'repeat(3, 1fr)', gap: 16, marginBottom: 28 }}>
         {stats.map((s) => (
           <div key={s.label} style={card}>
@@ -9,6 +9,7 @@ interface Secret {
   filename?: string;
   global: boolean;
   agent_ids: string[];
+  unused?: boolean;
   created_at: string;
   updated_at: string;
 }
@@ -366,6 +367,11 @@ function Secrets() {
                       global
                     </span>
                   )}
+                  {secret.unused && (
+                    <span style={badge('var(--amber)', 'var(--amber-muted)')} title="Not assigned to or referenced by any agent">
+                      unused
+                    </span>
+                  )}
                 </div>
 
                 {secret.description && (
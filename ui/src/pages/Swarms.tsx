@@ -4,6 +4,8 @@ import SwarmGraph, { type SwarmLaunchData } from '../components/SwarmGraph';
 
 interface SwarmAgentResult {
   role: string;
+  member_id?: string;
+  container_id?: string;
   status: string;
   output?: string;
   error?: string;
@@ -21,7 +23,8 @@ export interface Swarm {
   lead_agent: string;
   status: string;
   task: string;
-  agents?: Array<{ agent_id: string; role: string; prompt: string; workspace: string }>;
+  failure_policy?: string;
+  agents?: Array<{ agent_id: string; role: string; prompt: string; workspace: string; persist_workspace?: boolean }>;
   synapses?: SwarmSynapse[];
   results?: SwarmAgentResult[];
   started_at?: string;
@@ -60,6 +63,7 @@ const btnSmall: React.CSSProperties = {
 const statusColors: Record<string, { color: string; bg: string }> = {
   running: { color: 'var(--green)', bg: 'var(--green-muted)' },
   completed: { color: 'var(--accent)', bg: 'var(--accent-muted)' },
+  completed_with_errors: { color: 'var(--amber)', bg: 'var(--amber-muted)' },
   failed: { color: 'var(--red)', bg: 'var(--red-muted)' },
   pending: { color: 'var(--amber)', bg: 'var(--amber-muted)' },
 };
@@ -69,11 +73,13 @@ export function swarmToLaunchData(swarm: Swarm): SwarmLaunchData {
     name: swarm.name || 'Swarm',
     task: swarm.task,
     lead_agent: swarm.lead_agent,
+    failure_policy: swarm.failure_policy,
     agents: (swarm.agents || []).map((a) => ({
       agent_id: a.agent_id,
       role: a.role,
       prompt: a.prompt || '',
       workspace: a.workspace || a.agent_id,
+      persist_workspace: a.persist_workspace,
     })),
     synapses: (swarm.synapses || []).map((s) => ({
       from: s.from,
@@ -239,6 +245,11 @@ function Swarms() {
                     </div>
                     <div style={{ fontSize: 14, color: 'var(--text-muted)', marginTop: 6, display: 'flex', gap: 16 }}>
                       {agents.length > 0 && <span>{agents.length} agent(s)</span>}
+                      {swarm.status === 'completed_with_errors' && (
+                        <span>
+                          {results.filter((r) => r.status === 'completed').length}/{results.length} agents succeeded
+                        </span>
+                      )}
                       {synapses.length > 0 && <span>{synapses.length} connection(s)</span>}
                       {swarm.started_at && <span>Started: {swarm.started_at}</span>}
                       {swarm.completed_at && <span>Completed: {swarm.completed_at}</span>}
@@ -13,6 +13,11 @@ interface Task {
   status: string;
   last_run?: string;
   next_run?: string;
+  last_status?: string;
+  last_error?: string;
+  on_failure?: string;
+  max_failures?: number;
+  consecutive_failures?: number;
 }
 
 interface TaskForm {
@@ -21,6 +26,8 @@ interface TaskForm {
   agent_id: string;
   prompt: string;
   enabled: boolean;
+  on_failure: string;
+  max_failures: number;
 }
 
 interface Agent {
@@ -28,7 +35,7 @@ interface Agent {
   name: string;
 }
 
-const emptyForm: TaskForm = { name: '', schedule: '', agent_id: '', prompt: '', enabled: true };
+const emptyForm: TaskForm = { name: '', schedule: '', agent_id: '', prompt: '', enabled: true, on_failure: '', max_failures: 0 };
 
 const card: React.CSSProperties = {
   background: 'var(--bg-card)',
@@ -180,6 +187,8 @@ function Tasks() {
       agent_id: task.agent_id ?? '',
       prompt: task.prompt ?? '',
       enabled: task.enabled,
+      on_failure: task.on_failure ?? '',
+      max_failures: task.max_failures ?? 0,
     });
     setEditing(task.id);
     setShowForm(true);
@@ -285,6 +294,25 @@ function Tasks() {
                 Enabled
               </label>
             </div>
+            <div>
+              <label style={{ fontSize: 15, color: 'var(--text-tertiary)', display: 'block', marginBottom: 4 }}>On failure</label>
+              <input
+                style={inputStyle}
+                value={form.on_failure}
+                onChange={(e) => setForm({ ...form, on_failure: e.target.value })}
+                placeholder="notify_telegram, webhook:https://..., run_task:<id>"
+              />
+            </div>
+            <div>
+              <label style={{ fontSize: 15, color: 'var(--text-tertiary)', display: 'block', marginBottom: 4 }}>Pause after failures (0 = never)</label>
+              <input
+                style={inputStyle}
+                type="number"
+                min={0}
+                value={form.max_failures}
+                onChange={(e) => setForm({ ...form, max_failures: Number(e.target.value) || 0 })}
+              />
+            </div>
           </div>
           <div style={{ marginBottom: 16 }}>
             <label style={{ fontSize: 15, color: 'var(--text-tertiary)', display: 'block', marginBottom: 4 }}>Prompt</label>
@@ -340,7 +368,14 @@ function Tasks() {
                 <div style={{ fontSize: 14, color: 'var(--text-muted)', display: 'flex', gap: 16 }}>
                   {task.last_run && <span>Last run: {task.last_run}</span>}
                   {task.next_run && <span>Next run: {task.next_run}</span>}
+                  {task.consecutive_failures ? <span>Failures in a row: {task.consecutive_failures}</span> : null}
                 </div>
+
+                {task.last_status === 'error' && task.last_error && (
+                  <div style={{ fontSize: 14, color: 'var(--red-light)', marginTop: 6 }}>
+                    {task.last_error}
+                  </div>
+                )}
               </div>
 
               <div style={{ display: 'flex', gap: 6, flexShrink: 0, marginLeft: 16 }}>